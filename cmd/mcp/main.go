@@ -0,0 +1,66 @@
+// cmd/mcp/main.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/app"
+	"github.com/Corphon/SceneIntruderMCP/internal/config"
+	"github.com/Corphon/SceneIntruderMCP/internal/mcp"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+// SceneIntruderMCP 的 stdio MCP 入口：stdout 只输出协议消息，日志写入文件与 stderr。
+func main() {
+	log.SetOutput(os.Stderr)
+
+	logger := utils.GetLogger()
+	logger.SetConsoleOutput(os.Stderr)
+	logFile := filepath.Join("logs", fmt.Sprintf("mcp_%s.log", time.Now().Format("2006-01-02")))
+	if err := utils.InitLogger(logFile); err != nil {
+		log.Printf("WARNING: 无法初始化结构化日志: %v", err)
+	}
+
+	baseConfig, err := config.Load()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	for _, dir := range []string{
+		baseConfig.DataDir,
+		filepath.Join(baseConfig.DataDir, "scenes"),
+		filepath.Join(baseConfig.DataDir, "users"),
+		"temp",
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatalf("创建目录失败 %s: %v", dir, err)
+		}
+	}
+
+	if err := config.InitConfig(baseConfig.DataDir); err != nil {
+		log.Fatalf("初始化配置系统失败: %v", err)
+	}
+
+	if err := app.InitServices(); err != nil {
+		log.Fatalf("初始化服务失败: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	logger.Info("MCP stdio server starting", map[string]interface{}{"protocol_version": mcp.ProtocolVersion})
+
+	server := mcp.NewSceneServer()
+	if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+		log.Fatalf("MCP stdio 服务异常退出: %v", err)
+	}
+
+	logger.Info("MCP stdio server stopped", nil)
+}
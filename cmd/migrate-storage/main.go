@@ -0,0 +1,211 @@
+// cmd/migrate-storage/main.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/storage"
+)
+
+// 默认迁移的数据域：结构化 JSON 数据。漫画/视频等媒体产物按路径直接提供下载，仍保留在文件系统中。
+const defaultDomains = "scenes,stories,scripts,users"
+
+// skippedPaths 不迁移的子树（相对数据根目录）：账号凭据始终以 0600 权限保存在磁盘上
+var skippedPaths = []string{"users/auth"}
+
+type migrateStats struct {
+	copied  int
+	skipped int
+	failed  int
+}
+
+// migrate-storage 将已有的 data/ 文件树迁移到 SQLite 存储后端。
+// 每个实体目录（如 scenes/<id>）在一个事务中写入，中途失败不会留下半个场景。
+func main() {
+	dataDir := flag.String("data", envOr("DATA_DIR", "data"), "数据根目录")
+	dbPath := flag.String("db", os.Getenv("STORAGE_SQLITE_PATH"), "SQLite 数据库路径（默认 <data>/scene_intruder.db）")
+	domains := flag.String("domains", defaultDomains, "要迁移的数据域，逗号分隔")
+	overwrite := flag.Bool("overwrite", false, "覆盖数据库中已存在的条目")
+	dryRun := flag.Bool("dry-run", false, "只统计将要迁移的文件，不写入数据库")
+	flag.Parse()
+
+	if strings.TrimSpace(*dbPath) == "" {
+		*dbPath = filepath.Join(*dataDir, "scene_intruder.db")
+	}
+
+	total := migrateStats{}
+	for _, domain := range strings.Split(*domains, ",") {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+		stats, err := migrateDomain(*dataDir, *dbPath, domain, *overwrite, *dryRun)
+		if err != nil {
+			log.Fatalf("迁移 %s 失败: %v", domain, err)
+		}
+		log.Printf("%-8s 复制 %d，跳过 %d，失败 %d", domain, stats.copied, stats.skipped, stats.failed)
+		total.copied += stats.copied
+		total.skipped += stats.skipped
+		total.failed += stats.failed
+	}
+
+	if err := storage.CloseSQLiteStorages(); err != nil {
+		log.Printf("关闭数据库失败: %v", err)
+	}
+
+	mode := ""
+	if *dryRun {
+		mode = "（dry-run，未写入）"
+	}
+	log.Printf("完成%s：复制 %d，跳过 %d，失败 %d -> %s", mode, total.copied, total.skipped, total.failed, *dbPath)
+	if total.failed > 0 {
+		os.Exit(1)
+	}
+	if !*dryRun {
+		log.Printf("设置 STORAGE_BACKEND=sqlite 后重启服务即可使用新后端；原 data/ 目录未做任何修改，可作为备份保留")
+	}
+}
+
+// migrateDomain 迁移一个数据域（data/<domain>），按一级子目录分组提交事务
+func migrateDomain(dataDir, dbPath, domain string, overwrite, dryRun bool) (migrateStats, error) {
+	var stats migrateStats
+	root := filepath.Join(dataDir, domain)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return stats, nil
+	}
+
+	groups, err := collectFiles(root, domain)
+	if err != nil {
+		return stats, err
+	}
+
+	if dryRun {
+		for _, files := range groups {
+			stats.copied += len(files)
+		}
+		return stats, nil
+	}
+
+	store, err := storage.OpenSQLiteStorage(dbPath, domain)
+	if err != nil {
+		return stats, err
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		files := groups[key]
+		var copied []string
+		skipped := 0
+		err := store.WithTx(func(tx storage.Tx) error {
+			copied, skipped = nil, 0
+			for _, rel := range files {
+				dir, name := filepath.Split(rel)
+				if !overwrite {
+					if _, err := tx.LoadTextFile(dir, name); err == nil {
+						skipped++
+						continue
+					} else if !storage.IsNotExist(err) {
+						return err
+					}
+				}
+				content, err := os.ReadFile(filepath.Join(root, rel))
+				if err != nil {
+					return err
+				}
+				if err := tx.SaveTextFile(dir, name, content); err != nil {
+					return err
+				}
+				copied = append(copied, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("迁移 %s/%s 失败: %v", domain, key, err)
+			stats.failed += len(files)
+			continue
+		}
+
+		// 回读校验：提交后的内容必须与源文件逐字节一致
+		for _, rel := range copied {
+			dir, name := filepath.Split(rel)
+			content, err := store.LoadTextFile(dir, name)
+			expected, readErr := os.ReadFile(filepath.Join(root, rel))
+			if err != nil || readErr != nil || !bytes.Equal(content, expected) {
+				log.Printf("校验 %s/%s 失败", domain, rel)
+				stats.failed++
+				continue
+			}
+			stats.copied++
+		}
+		stats.skipped += skipped
+	}
+	return stats, nil
+}
+
+// collectFiles 收集 root 下的全部文件（相对路径），按一级目录分组；根目录下的文件归入 "" 组
+func collectFiles(root, domain string) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if isSkipped(filepath.ToSlash(filepath.Join(domain, rel))) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+
+		group := ""
+		if parts := strings.SplitN(filepath.ToSlash(rel), "/", 2); len(parts) == 2 {
+			group = parts[0]
+		}
+		groups[group] = append(groups[group], rel)
+		return nil
+	})
+	return groups, err
+}
+
+func isSkipped(slashPath string) bool {
+	for _, p := range skippedPaths {
+		if slashPath == p || strings.HasPrefix(slashPath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func init() {
+	log.SetFlags(0)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: migrate-storage [-data data] [-db data/scene_intruder.db] [-domains %s] [-overwrite] [-dry-run]\n", defaultDomains)
+		flag.PrintDefaults()
+	}
+}
@@ -68,13 +68,54 @@ func InitializeAuth() error {
 	}
 
 	tokenConfig = &auth.TokenConfig{
-		Secret:     secret,
-		Expiration: 24 * time.Hour, // Token expires in 24 hours
+		Secret:            secret,
+		Expiration:        24 * time.Hour,      // Access token expires in 24 hours
+		RefreshExpiration: 30 * 24 * time.Hour, // Refresh token expires in 30 days
 	}
 
+	registrationEnabled = !strings.EqualFold(strings.TrimSpace(os.Getenv("AUTH_ALLOW_REGISTRATION")), "false")
+
+	bootstrapAdminAccount()
+
 	return nil
 }
 
+// registrationEnabled controls POST /api/auth/register (AUTH_ALLOW_REGISTRATION=false disables it)
+var registrationEnabled = true
+
+// bootstrapAdminAccount creates the account named by AUTH_ADMIN_USERNAME/AUTH_ADMIN_PASSWORD
+// if it does not exist yet, so a server with registration disabled still has a way in.
+func bootstrapAdminAccount() {
+	username := strings.TrimSpace(os.Getenv("AUTH_ADMIN_USERNAME"))
+	password := os.Getenv("AUTH_ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return
+	}
+
+	userService := userServiceFromContainer()
+	if userService == nil || userService.HasAccount(username) {
+		return
+	}
+
+	if _, err := userService.Register(username, password, ""); err != nil {
+		utils.GetLogger().Error("failed to create bootstrap admin account", map[string]interface{}{
+			"username": username,
+			"err":      err.Error(),
+		})
+		return
+	}
+	utils.GetLogger().Info("bootstrap admin account created", map[string]interface{}{"username": username})
+}
+
+func userServiceFromContainer() *services.UserService {
+	container := di.GetContainer()
+	if container == nil {
+		return nil
+	}
+	userService, _ := container.Get("user").(*services.UserService)
+	return userService
+}
+
 // AuthMiddleware provides authentication for API endpoints
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -117,8 +158,31 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Personal API tokens are opaque and checked against the user store
+		if auth.IsAPIToken(token) {
+			userID, err := validateAPIToken(token)
+			if err != nil {
+				utils.GetLogger().Warn("auth invalid api token; downgraded to guest", map[string]interface{}{
+					"method":    c.Request.Method,
+					"path":      c.Request.URL.Path,
+					"client_ip": c.ClientIP(),
+					"err":       err.Error(),
+				})
+				c.Set("user_id", "console_user")
+				c.Set("user_authenticated", false)
+				c.Set("auth_error", err.Error())
+				c.Next()
+				return
+			}
+			c.Set("user_id", userID)
+			c.Set("user_authenticated", true)
+			c.Set("auth_method", "api_token")
+			c.Next()
+			return
+		}
+
 		// Parse and validate token
-		parsedToken, err := auth.ParseToken(token, tokenConfig)
+		parsedToken, err := parseSessionToken(token)
 		if err != nil {
 			// Do not log the token itself.
 			utils.GetLogger().Warn("auth invalid token; downgraded to guest", map[string]interface{}{
@@ -138,6 +202,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Add user info to context for use in handlers
 		c.Set("user_id", parsedToken.UserID)
 		c.Set("user_authenticated", true)
+		c.Set("auth_method", "session")
 
 		c.Next()
 	}
@@ -157,6 +222,8 @@ func isPublicEndpoint(c *gin.Context) bool {
 		"/register",                     // Register page
 		"/api/auth/login",               // Login API endpoint
 		"/api/auth/logout",              // Logout API endpoint
+		"/api/auth/register",            // Registration API endpoint
+		"/api/auth/refresh",             // Token refresh API endpoint
 		"/api/settings/test-connection", // Test connection should be accessible without auth for initial setup
 	}
 
@@ -198,6 +265,139 @@ func GenerateUserToken(userID string) (string, error) {
 	return auth.GenerateToken(userID, tokenConfig)
 }
 
+// SessionTokens is the access/refresh pair returned by login, register and refresh
+type SessionTokens struct {
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// IssueSessionTokens creates a new access/refresh token pair for a user
+func IssueSessionTokens(userID string) (*SessionTokens, error) {
+	if tokenConfig == nil {
+		return nil, fmt.Errorf("auth not initialized")
+	}
+
+	access, accessToken, err := auth.IssueToken(userID, auth.TokenKindAccess, tokenConfig.Expiration, tokenConfig)
+	if err != nil {
+		return nil, err
+	}
+	refresh, refreshToken, err := auth.IssueToken(userID, auth.TokenKindRefresh, tokenConfig.RefreshExpiration, tokenConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionTokens{
+		AccessToken:      access,
+		ExpiresAt:        time.Unix(accessToken.ExpiresAt, 0),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: time.Unix(refreshToken.ExpiresAt, 0),
+	}, nil
+}
+
+// parseSessionToken validates an access token, including the server-side revocation list
+func parseSessionToken(token string) (*auth.Token, error) {
+	return parseTokenOfKind(token, auth.TokenKindAccess)
+}
+
+// parseTokenOfKind validates signature, expiry, kind and revocation of a session token
+func parseTokenOfKind(token string, kind string) (*auth.Token, error) {
+	if tokenConfig == nil {
+		return nil, fmt.Errorf("auth not initialized")
+	}
+
+	parsed, err := auth.ParseToken(token, tokenConfig)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Kind != kind {
+		return nil, fmt.Errorf("unexpected token kind %q", parsed.Kind)
+	}
+	if userService := userServiceFromContainer(); userService != nil {
+		if userService.IsSessionTokenRevoked(parsed.UserID, parsed.ID, parsed.IssuedTime()) {
+			return nil, fmt.Errorf("token has been revoked")
+		}
+	}
+	return parsed, nil
+}
+
+func validateAPIToken(token string) (string, error) {
+	userService := userServiceFromContainer()
+	if userService == nil {
+		return "", fmt.Errorf("user service not available")
+	}
+	return userService.ValidateAPIToken(token)
+}
+
+// authenticatedUserFromRequest returns the user authenticated by AuthMiddleware, or the owner of a
+// valid bearer token on routes that skip it. Guests and invalid tokens return "".
+func authenticatedUserFromRequest(c *gin.Context) string {
+	if userID, authenticated := GetUserFromContext(c); authenticated {
+		return userID
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if token == "" || tokenConfig == nil {
+		return ""
+	}
+	if auth.IsAPIToken(token) {
+		if userID, err := validateAPIToken(token); err == nil {
+			return userID
+		}
+		return ""
+	}
+	if parsed, err := parseSessionToken(token); err == nil {
+		return parsed.UserID
+	}
+	return ""
+}
+
+// isAdminRequest reports whether the caller is listed in AUTH_ADMIN_USERS (or is the
+// AUTH_ADMIN_USERNAME bootstrap account), matched by user ID or login username.
+func isAdminRequest(c *gin.Context) bool {
+	userID := authenticatedUserFromRequest(c)
+	if userID == "" {
+		return false
+	}
+	cfg := config.GetCurrentConfig()
+	if cfg == nil || len(cfg.AdminUsers) == 0 {
+		return false
+	}
+
+	username := ""
+	if userService := userServiceFromContainer(); userService != nil {
+		username, _ = userService.LoginUsername(userID)
+	}
+	for _, admin := range cfg.AdminUsers {
+		if admin == userID || (username != "" && strings.EqualFold(admin, username)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAdmin restricts operator endpoints (prices, quotas) to admin accounts.
+// Guests, including the console_user fallback, are always rejected.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			NewResponseHelper().Forbidden(c, "需要管理员权限", "set AUTH_ADMIN_USERS to grant operator access")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// JobOwner tags the request context with the caller so that queued jobs record who
+// submitted them; /api/jobs only lists and controls a job for its submitter (or an admin).
+func JobOwner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(services.WithJobUser(c.Request.Context(), usageUserFromRequest(c, "")))
+		c.Next()
+	}
+}
+
 // GetUserFromContext retrieves the authenticated user from the context
 func GetUserFromContext(c *gin.Context) (string, bool) {
 	userID, exists := c.Get("user_id")
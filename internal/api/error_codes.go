@@ -38,6 +38,7 @@ const (
 	ErrorLLMConfigInvalid      = "LLM_CONFIG_INVALID"
 	ErrorConnectionFailed      = "CONNECTION_FAILED"
 	ErrorLLMNotReady           = "LLM_NOT_READY"
+	ErrorQuotaExceeded         = "QUOTA_EXCEEDED"
 
 	// Comic（v2）相关错误
 	ErrorComicServiceNotReady     = "COMIC_SERVICE_NOT_READY"
@@ -47,11 +48,19 @@ const (
 	ErrorComicImageNotFound       = "COMIC_IMAGE_NOT_FOUND"
 	ErrorComicReferencesNotFound  = "COMIC_REFERENCES_NOT_FOUND"
 	ErrorComicReferenceNotFound   = "COMIC_REFERENCE_NOT_FOUND"
+	ErrorComicPagesNotFound       = "COMIC_PAGES_NOT_FOUND"
+	ErrorComicSheetNotFound       = "COMIC_CHARACTER_SHEET_NOT_FOUND"
+	ErrorComicVersionNotFound     = "COMIC_FRAME_VERSION_NOT_FOUND"
 	ErrorVideoServiceNotReady     = "VIDEO_SERVICE_NOT_READY"
 	ErrorVideoTimelineNotFound    = "VIDEO_TIMELINE_NOT_FOUND"
 	ErrorVideoOverviewNotFound    = "VIDEO_OVERVIEW_NOT_FOUND"
 	ErrorVideoFrameNotFound       = "VIDEO_FRAME_NOT_FOUND"
 
+	// 任务队列相关错误
+	ErrorJobQueueNotReady = "JOB_QUEUE_NOT_READY"
+	ErrorJobNotFound      = "JOB_NOT_FOUND"
+	ErrorJobStateConflict = "JOB_STATE_CONFLICT"
+
 	// 文件相关错误
 	ErrorFileUploadFailed = "FILE_UPLOAD_FAILED"
 	ErrorFileInvalid      = "FILE_INVALID"
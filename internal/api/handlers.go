@@ -3,6 +3,7 @@ package api
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -22,9 +23,12 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/Corphon/SceneIntruderMCP/internal/auth"
 	"github.com/Corphon/SceneIntruderMCP/internal/config"
 	"github.com/Corphon/SceneIntruderMCP/internal/di"
+	"github.com/Corphon/SceneIntruderMCP/internal/importer"
 	"github.com/Corphon/SceneIntruderMCP/internal/llm"
 	"github.com/Corphon/SceneIntruderMCP/internal/models"
 	"github.com/Corphon/SceneIntruderMCP/internal/services"
@@ -173,6 +177,10 @@ func (h *Handler) BuildComicVideoTimeline(c *gin.Context) {
 			status = http.StatusBadRequest
 			code = ErrorBadRequest
 			message = "视频参考图 URL 不可用"
+		case errors.Is(err, services.ErrVideoNarrationVoiceInvalid):
+			status = http.StatusBadRequest
+			code = ErrorBadRequest
+			message = "配音音色不可用"
 		case errors.Is(err, services.ErrVideoSourceNotReady), isStorageNotFound(err):
 			status = http.StatusBadRequest
 			code = ErrorBadRequest
@@ -225,6 +233,10 @@ func (h *Handler) UpdateComicVideoFrame(c *gin.Context) {
 		ReferenceImageURL *string                         `json:"reference_image_url,omitempty"`
 		ImageURL          *string                         `json:"image_url,omitempty"`
 		ImgURL            *string                         `json:"img_url,omitempty"`
+		Narration         *string                         `json:"narration,omitempty"`
+		CameraMotion      *string                         `json:"camera_motion,omitempty"`
+		Transition        *string                         `json:"transition,omitempty"`
+		DurationSec       *float64                        `json:"duration_sec,omitempty"`
 		PromptOptions     *models.VideoPromptOptionsPatch `json:"prompt_options,omitempty"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -238,7 +250,8 @@ func (h *Handler) UpdateComicVideoFrame(c *gin.Context) {
 			req.ReferenceImageURL = req.ImgURL
 		}
 	}
-	if req.Prompt == nil && req.NegativePrompt == nil && req.ReferenceImageURL == nil && req.PromptOptions == nil {
+	if req.Prompt == nil && req.NegativePrompt == nil && req.ReferenceImageURL == nil && req.Narration == nil &&
+		req.CameraMotion == nil && req.Transition == nil && req.DurationSec == nil && req.PromptOptions == nil {
 		h.Response.BadRequest(c, "至少需要提供一个可编辑字段")
 		return
 	}
@@ -253,10 +266,16 @@ func (h *Handler) UpdateComicVideoFrame(c *gin.Context) {
 		Prompt:            req.Prompt,
 		NegativePrompt:    req.NegativePrompt,
 		ReferenceImageURL: req.ReferenceImageURL,
+		Narration:         req.Narration,
+		CameraMotion:      req.CameraMotion,
+		Transition:        req.Transition,
+		DurationSec:       req.DurationSec,
 		PromptOptions:     req.PromptOptions,
 	})
 	if err != nil {
 		switch {
+		case errors.Is(err, services.ErrVideoClipPatchInvalid):
+			h.Response.BadRequest(c, "视频分镜参数无效", err.Error())
 		case errors.Is(err, services.ErrVideoRepositoryNotReady), errors.Is(err, services.ErrVideoServiceNotReady):
 			h.Response.Error(c, http.StatusServiceUnavailable, ErrorVideoServiceNotReady, "VideoService 未就绪", err.Error())
 		case errors.Is(err, services.ErrVideoTimelineNotFound), isStorageNotFound(err):
@@ -545,6 +564,10 @@ func (h *Handler) StartComicVideoGenerate(c *gin.Context) {
 			status = http.StatusBadRequest
 			code = ErrorBadRequest
 			message = "视频参考图 URL 不可用"
+		} else if errors.Is(err, services.ErrVideoNarrationVoiceInvalid) {
+			status = http.StatusBadRequest
+			code = ErrorBadRequest
+			message = "配音音色不可用"
 		}
 		h.Response.Error(c, status, code, message, err.Error())
 		return
@@ -639,8 +662,8 @@ func (h *Handler) ExportComicVideo(c *gin.Context) {
 	}
 
 	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "zip")))
-	if format != "zip" && format != "html" && format != "mp4" {
-		h.Response.Error(c, http.StatusBadRequest, ErrorExportFormatInvalid, "不支持的导出格式", "支持的格式: zip/html/mp4")
+	if format != "zip" && format != "html" && format != "mp4" && format != "srt" && format != "vtt" {
+		h.Response.Error(c, http.StatusBadRequest, ErrorExportFormatInvalid, "不支持的导出格式", "支持的格式: zip/html/mp4/srt/vtt")
 		return
 	}
 
@@ -666,6 +689,39 @@ func (h *Handler) ExportComicVideo(c *gin.Context) {
 		return
 	}
 
+	if format == "srt" || format == "vtt" {
+		relPath := ""
+		if meta != nil {
+			relPath = map[string]string{"srt": meta.SubtitleSRTPath, "vtt": meta.SubtitleVTTPath}[format]
+		}
+		relPath = filepath.ToSlash(strings.TrimSpace(relPath))
+		if relPath == "" {
+			h.Response.Error(c, http.StatusNotFound, ErrorExportDataEmpty, "当前没有可导出的字幕", "字幕在合成视频时根据分镜旁白生成")
+			return
+		}
+		content, err := videoSvc.Repo.FileStorage.LoadTextFile(filepath.ToSlash(filepath.Dir(relPath)), filepath.Base(relPath))
+		if err != nil {
+			if isStorageNotFound(err) {
+				h.Response.Error(c, http.StatusNotFound, ErrorExportDataEmpty, "字幕文件不存在")
+				return
+			}
+			h.Response.InternalError(c, "读取字幕文件失败", err.Error())
+			return
+		}
+		ts := time.Now().Format("20060102_150405")
+		filename := fmt.Sprintf("video_%s_%s.%s", sceneID, ts, format)
+		contentType := "application/x-subrip; charset=utf-8"
+		if format == "vtt" {
+			contentType = "text/vtt; charset=utf-8"
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.Write(content)
+		return
+	}
+
 	if format == "html" || format == "mp4" {
 		if meta == nil || strings.TrimSpace(meta.RenderArtifactPath) == "" {
 			h.Response.Error(c, http.StatusNotFound, ErrorExportDataEmpty, "当前没有可导出的 video render artifact")
@@ -781,8 +837,8 @@ func (h *Handler) ExportComicVideo(c *gin.Context) {
 			})
 		}
 	}
-	for _, subdir := range []string{"clips", "renders"} {
-		absSubdir := filepath.Join(absVideoDir, subdir)
+	for _, subdir := range []string{"clips", "renders", "audio/narration"} {
+		absSubdir := filepath.Join(absVideoDir, filepath.FromSlash(subdir))
 		entries, err := os.ReadDir(absSubdir)
 		if err != nil {
 			if !isStorageNotFound(err) {
@@ -862,6 +918,9 @@ type videoConfigRequest struct {
 	AudioURL          string `json:"audio_url,omitempty"`
 	ImageURL          string `json:"image_url,omitempty"`
 	ImgURL            string `json:"img_url,omitempty"`
+	NarrationEnabled  *bool  `json:"narration_enabled,omitempty"`
+	NarrationVoice    string `json:"narration_voice,omitempty"`
+	BurnSubtitles     *bool  `json:"burn_subtitles,omitempty"`
 	PromptOptions     struct {
 		Language            string `json:"language,omitempty"`
 		DialogueStyle       string `json:"dialogue_style,omitempty"`
@@ -919,6 +978,9 @@ func (r videoConfigRequest) ToModel() models.VideoConfig {
 		PromptExtend:      promptExtend,
 		AudioURL:          strings.TrimSpace(r.AudioURL),
 		ImageURL:          imageURL,
+		NarrationEnabled:  r.NarrationEnabled != nil && *r.NarrationEnabled,
+		NarrationVoice:    strings.TrimSpace(r.NarrationVoice),
+		BurnSubtitles:     r.BurnSubtitles != nil && *r.BurnSubtitles,
 		PromptOptions: models.VideoPromptOptions{
 			Language:            strings.TrimSpace(r.PromptOptions.Language),
 			DialogueStyle:       strings.TrimSpace(r.PromptOptions.DialogueStyle),
@@ -1555,6 +1617,205 @@ func (h *Handler) DeleteComicReference(c *gin.Context) {
 	h.Response.Success(c, idx, "参考图删除成功")
 }
 
+// respondComicCharacterSheetError maps character sheet service errors to HTTP responses.
+func (h *Handler) respondComicCharacterSheetError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCharacterSheet), errors.Is(err, services.ErrInvalidSceneID):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "角色设定参数不合法", err.Error())
+	case errors.Is(err, services.ErrCharacterSheetNotFound):
+		h.Response.Error(c, http.StatusNotFound, ErrorComicSheetNotFound, "角色设定图不存在", err.Error())
+	case errors.Is(err, services.ErrComicServiceNotReady), errors.Is(err, services.ErrComicRepositoryNotReady):
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未就绪", err.Error())
+	case isStorageNotFound(err):
+		h.Response.Error(c, http.StatusNotFound, ErrorComicSheetNotFound, "角色设定图不存在")
+	default:
+		h.Response.InternalError(c, action, err.Error())
+	}
+}
+
+// GetComicCharacterSheets lists the per-character reference sheets of a scene.
+// Route: GET /api/scenes/:id/comic/character_sheets
+func (h *Handler) GetComicCharacterSheets(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	idx, err := comicSvc.LoadCharacterSheets(sceneID)
+	if err != nil {
+		h.respondComicCharacterSheetError(c, err, "读取角色设定图失败")
+		return
+	}
+	h.Response.Success(c, idx, "角色设定图获取成功")
+}
+
+// UpdateComicCharacterSheet edits the name, aliases and appearance used to match a character to frames.
+// Route: PUT /api/scenes/:id/comic/character_sheets/:elementID
+func (h *Handler) UpdateComicCharacterSheet(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var req services.ComicCharacterSheetPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "无效请求", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	sheet, err := comicSvc.UpdateCharacterSheet(sceneID, c.Param("elementID"), req)
+	if err != nil {
+		h.respondComicCharacterSheetError(c, err, "更新角色设定失败")
+		return
+	}
+	h.Response.Success(c, sheet, "角色设定更新成功")
+}
+
+// UploadComicCharacterSheetView uploads one view of a character sheet.
+// Form fields: view=<front|three_quarter|profile|back|expressions|custom>, file=<image>
+// Route: POST /api/scenes/:id/comic/character_sheets/:elementID/views
+func (h *Handler) UploadComicCharacterSheetView(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	const maxSheetSize = 5 << 20 // 5MB
+	fh, err := c.FormFile("file")
+	if err != nil {
+		h.Response.BadRequest(c, "缺少上传文件", err.Error())
+		return
+	}
+	if fh.Size > maxSheetSize {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileInvalid, "设定图过大（最大 5MB）")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileUploadFailed, "打开上传文件失败", err.Error())
+		return
+	}
+	data, readErr := io.ReadAll(io.LimitReader(f, maxSheetSize+1))
+	_ = f.Close()
+	if readErr != nil {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileUploadFailed, "读取上传文件失败", readErr.Error())
+		return
+	}
+	if int64(len(data)) > maxSheetSize {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileInvalid, "设定图过大（最大 5MB）")
+		return
+	}
+
+	sheet, err := comicSvc.SaveCharacterSheetView(sceneID, c.Param("elementID"), c.PostForm("view"), data, models.ComicSheetSourceUploaded, "")
+	if err != nil {
+		h.respondComicCharacterSheetError(c, err, "保存设定图失败")
+		return
+	}
+	h.Response.Success(c, sheet, "设定图上传成功")
+}
+
+// StartComicCharacterSheetGenerate generates the missing views of a character sheet with the vision provider.
+// Route: POST /api/scenes/:id/comic/character_sheets/:elementID/generate
+func (h *Handler) StartComicCharacterSheetGenerate(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var req services.ComicCharacterSheetGenerateOptions
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.Response.BadRequest(c, "无效请求", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	taskID, err := comicSvc.GenerateCharacterSheetAsync(c.Request.Context(), sceneID, c.Param("elementID"), req)
+	if err != nil {
+		h.respondComicCharacterSheetError(c, err, "启动设定图生成失败")
+		return
+	}
+	h.Response.Accepted(c, gin.H{"task_id": taskID}, "设定图生成任务已受理")
+}
+
+// GetComicCharacterSheetImage serves one view of a character sheet.
+// Route: GET /api/scenes/:id/comic/character_sheets/:elementID/views/:view/image
+func (h *Handler) GetComicCharacterSheetImage(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	data, ct, err := comicSvc.LoadCharacterSheetViewImage(sceneID, c.Param("elementID"), c.Param("view"))
+	if err != nil {
+		h.respondComicCharacterSheetError(c, err, "读取设定图失败")
+		return
+	}
+	if strings.TrimSpace(ct) == "" {
+		ct = "application/octet-stream"
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, ct, data)
+}
+
+// DeleteComicCharacterSheet deletes a character sheet, or a single view with ?view=<view>.
+// Route: DELETE /api/scenes/:id/comic/character_sheets/:elementID
+func (h *Handler) DeleteComicCharacterSheet(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	idx, err := comicSvc.DeleteCharacterSheetView(sceneID, c.Param("elementID"), c.Query("view"))
+	if err != nil {
+		h.respondComicCharacterSheetError(c, err, "删除设定图失败")
+		return
+	}
+	h.Response.Success(c, idx, "设定图删除成功")
+}
+
 func (h *Handler) StartComicKeyElements(c *gin.Context) {
 	sceneID := c.Param("id")
 	if strings.TrimSpace(sceneID) == "" {
@@ -1787,25 +2048,39 @@ func (h *Handler) StartComicRegenerateFrame(c *gin.Context) {
 	h.Response.Accepted(c, gin.H{"task_id": taskID}, "图片重绘任务已受理")
 }
 
-type comicGenerateFramesRequest struct {
-	FrameIDs []string `json:"frame_ids"`
+// respondComicFrameEditError maps inpaint/undo/version errors to HTTP responses.
+func (h *Handler) respondComicFrameEditError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidInpaintRequest), errors.Is(err, services.ErrInvalidSceneID):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "局部重绘参数不合法", err.Error())
+	case errors.Is(err, services.ErrInvalidFrameVersionGCPolicy):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "版本回收策略不合法", err.Error())
+	case errors.Is(err, services.ErrFrameVersionNotFound):
+		h.Response.Error(c, http.StatusNotFound, ErrorComicVersionNotFound, "帧版本不存在")
+	case errors.Is(err, services.ErrInvalidSeedSettings):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "种子策略不合法", err.Error())
+	case errors.Is(err, services.ErrFrameNotReproducible):
+		h.Response.Error(c, http.StatusConflict, ErrorConflict, "该图片没有记录种子与提示词，无法复现", err.Error())
+	case errors.Is(err, services.ErrInpaintingUnsupported):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "当前图像模型不支持局部重绘（可用 sdwebui / openai）", err.Error())
+	case errors.Is(err, services.ErrNoFrameVersionToRestore):
+		h.Response.Error(c, http.StatusNotFound, ErrorComicVersionNotFound, "没有可撤销的历史版本")
+	case errors.Is(err, services.ErrComicServiceNotReady), errors.Is(err, services.ErrComicRepositoryNotReady):
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未就绪", err.Error())
+	case isStorageNotFound(err):
+		h.Response.Error(c, http.StatusNotFound, ErrorComicImageNotFound, "图片不存在")
+	default:
+		h.Response.InternalError(c, action, err.Error())
+	}
 }
 
-// StartComicGenerateFrames starts async jobs to regenerate multiple frames in parallel.
-func (h *Handler) StartComicGenerateFrames(c *gin.Context) {
+// StartComicInpaintFrame repaints the masked region of one frame (multipart form).
+// Fields: mask=<image, white = edit>, prompt, negative_prompt, model, denoising_strength.
+// Route: POST /api/scenes/:id/comic/frames/:frameID/inpaint
+func (h *Handler) StartComicInpaintFrame(c *gin.Context) {
 	sceneID := c.Param("id")
-	if strings.TrimSpace(sceneID) == "" {
-		h.Response.BadRequest(c, "缺少场景ID")
-		return
-	}
-
-	var req comicGenerateFramesRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.Response.BadRequest(c, "无效请求", err.Error())
-		return
-	}
-	if len(req.FrameIDs) == 0 {
-		h.Response.BadRequest(c, "缺少帧ID列表")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
 		return
 	}
 
@@ -1815,89 +2090,544 @@ func (h *Handler) StartComicGenerateFrames(c *gin.Context) {
 		return
 	}
 
-	repo := h.getComicRepo()
-	if repo == nil {
-		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+	const maxMaskSize = 5 << 20 // 5MB
+	fh, err := c.FormFile("mask")
+	if err != nil {
+		h.Response.BadRequest(c, "缺少遮罩文件（mask）", err.Error())
+		return
+	}
+	if fh.Size > maxMaskSize {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileInvalid, "遮罩过大（最大 5MB）")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileUploadFailed, "打开上传文件失败", err.Error())
+		return
+	}
+	mask, readErr := io.ReadAll(io.LimitReader(f, maxMaskSize+1))
+	_ = f.Close()
+	if readErr != nil {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileUploadFailed, "读取上传文件失败", readErr.Error())
+		return
+	}
+	if int64(len(mask)) > maxMaskSize {
+		h.Response.Error(c, http.StatusBadRequest, ErrorFileInvalid, "遮罩过大（最大 5MB）")
 		return
 	}
 
-	for _, frameID := range req.FrameIDs {
-		frameID = strings.TrimSpace(frameID)
-		if frameID == "" {
-			continue
-		}
-		if _, err := repo.LoadPrompt(sceneID, frameID); err != nil {
-			if isStorageNotFound(err) {
-				h.Response.Error(c, http.StatusNotFound, ErrorComicPromptsNotFound, "提示词不存在")
-				return
-			}
-			h.Response.InternalError(c, "读取提示词失败", err.Error())
+	opts := services.ComicInpaintOptions{
+		Prompt:         c.PostForm("prompt"),
+		NegativePrompt: c.PostForm("negative_prompt"),
+		Model:          strings.TrimSpace(c.PostForm("model")),
+		Mask:           mask,
+	}
+	if raw := strings.TrimSpace(c.PostForm("denoising_strength")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			h.Response.BadRequest(c, "denoising_strength 不合法", err.Error())
 			return
 		}
+		opts.DenoisingStrength = v
 	}
 
-	tasks, err := comicSvc.GenerateFramesAsync(c.Request.Context(), sceneID, req.FrameIDs)
+	taskID, err := comicSvc.InpaintFrameAsync(c.Request.Context(), sceneID, c.Param("frameID"), opts)
 	if err != nil {
-		if errors.Is(err, services.ErrComicServiceNotReady) || errors.Is(err, services.ErrComicRepositoryNotReady) {
-			h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未就绪", err.Error())
-			return
-		}
-		h.Response.InternalError(c, "启动批量生成失败", err.Error())
+		h.respondComicFrameEditError(c, err, "启动局部重绘失败")
 		return
 	}
-
-	h.Response.Accepted(c, gin.H{"tasks": tasks}, "批量生成任务已受理")
+	h.Response.Accepted(c, gin.H{"task_id": taskID}, "局部重绘任务已受理")
 }
 
-// GetComicOverview returns a lightweight overview of a scene's comic artifacts.
-func (h *Handler) GetComicOverview(c *gin.Context) {
+// UndoComicFrameEdit restores the version before the current frame image.
+// Route: POST /api/scenes/:id/comic/frames/:frameID/undo
+func (h *Handler) UndoComicFrameEdit(c *gin.Context) {
 	sceneID := c.Param("id")
-	if strings.TrimSpace(sceneID) == "" {
-		h.Response.BadRequest(c, "缺少场景ID")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
 		return
 	}
 
-	repo := h.getComicRepo()
-	if repo == nil {
-		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
 		return
 	}
 
-	sceneDir := filepath.Join("scene_" + sceneID)
-	absSceneDir := filepath.Join(repo.BaseDir, sceneDir)
-
-	hasAnalysis := false
-	if _, err := repo.LoadAnalysis(sceneID); err == nil {
-		hasAnalysis = true
-	} else if !isStorageNotFound(err) {
-		h.Response.InternalError(c, "读取分镜分析失败", err.Error())
+	versions, err := comicSvc.UndoFrameEdit(sceneID, c.Param("frameID"))
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "撤销失败")
 		return
 	}
+	h.Response.Success(c, versions, "已恢复上一版本")
+}
 
-	// prompts
-	hasPrompts := false
-	{
-		absPromptsDir := filepath.Join(absSceneDir, "prompts")
-		entries, err := os.ReadDir(absPromptsDir)
-		if err == nil {
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
-				}
-				if strings.HasSuffix(strings.ToLower(e.Name()), ".json") {
-					hasPrompts = true
-					break
-				}
-			}
-		} else if !isStorageNotFound(err) {
-			h.Response.InternalError(c, "读取提示词目录失败", err.Error())
-			return
-		}
+// parseComicFrameVersion parses a positive frame version number.
+func parseComicFrameVersion(raw string) (int, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid version: %q", raw)
 	}
+	return v, nil
+}
 
-	// key elements
-	hasKeyElements := false
-	if _, err := repo.LoadKeyElements(sceneID); err == nil {
+// GetComicFrameVersions lists all stored image versions of a frame.
+// Route: GET /api/scenes/:id/comic/frames/:frameID/versions
+func (h *Handler) GetComicFrameVersions(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	versions, err := comicSvc.ListFrameVersions(sceneID, c.Param("frameID"))
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "读取帧版本失败")
+		return
+	}
+	h.Response.Success(c, versions, "获取帧版本成功")
+}
+
+// GetComicFrameVersionImage returns the image bytes of one frame version.
+// Route: GET /api/scenes/:id/comic/frames/:frameID/versions/:version/image
+func (h *Handler) GetComicFrameVersionImage(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+	version, err := parseComicFrameVersion(c.Param("version"))
+	if err != nil {
+		h.Response.BadRequest(c, "版本号不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	data, err := comicSvc.LoadFrameVersionImage(sceneID, c.Param("frameID"), version)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "读取版本图片失败")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// PinComicFrameVersion makes a version the current frame image and protects it from GC.
+// Route: POST /api/scenes/:id/comic/frames/:frameID/versions/:version/pin
+func (h *Handler) PinComicFrameVersion(c *gin.Context) {
+	h.setComicFrameVersionPinned(c, true)
+}
+
+// UnpinComicFrameVersion clears the pinned flag of a version without changing the current image.
+// Route: DELETE /api/scenes/:id/comic/frames/:frameID/versions/:version/pin
+func (h *Handler) UnpinComicFrameVersion(c *gin.Context) {
+	h.setComicFrameVersionPinned(c, false)
+}
+
+func (h *Handler) setComicFrameVersionPinned(c *gin.Context, pinned bool) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+	version, err := parseComicFrameVersion(c.Param("version"))
+	if err != nil {
+		h.Response.BadRequest(c, "版本号不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	versions, err := comicSvc.PinFrameVersion(sceneID, c.Param("frameID"), version, pinned)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "更新版本固定状态失败")
+		return
+	}
+	if pinned {
+		h.Response.Success(c, versions, "已固定该版本并设为当前图片")
+		return
+	}
+	h.Response.Success(c, versions, "已取消固定")
+}
+
+// CompareComicFrameVersions compares two versions of a frame (?a=<version>&b=<version>).
+// Route: GET /api/scenes/:id/comic/frames/:frameID/versions/compare
+func (h *Handler) CompareComicFrameVersions(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+	a, err := parseComicFrameVersion(c.Query("a"))
+	if err != nil {
+		h.Response.BadRequest(c, "参数 a 不合法", err.Error())
+		return
+	}
+	b, err := parseComicFrameVersion(c.Query("b"))
+	if err != nil {
+		h.Response.BadRequest(c, "参数 b 不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	cmp, err := comicSvc.CompareFrameVersions(sceneID, c.Param("frameID"), a, b)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "对比帧版本失败")
+		return
+	}
+	h.Response.Success(c, cmp, "对比完成")
+}
+
+// GCComicFrameVersions removes old frame versions by policy (JSON body, all fields optional).
+// Route: POST /api/scenes/:id/comic/versions/gc
+func (h *Handler) GCComicFrameVersions(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var policy services.ComicFrameVersionGCPolicy
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&policy); err != nil {
+			h.Response.BadRequest(c, "请求参数不合法", err.Error())
+			return
+		}
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	result, err := comicSvc.GCFrameVersions(sceneID, policy)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "回收帧版本失败")
+		return
+	}
+	h.Response.Success(c, result, "帧版本回收完成")
+}
+
+// GetComicSeedSettings returns the scene seed strategy (random when never set).
+// Route: GET /api/scenes/:id/comic/seed
+func (h *Handler) GetComicSeedSettings(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	settings, err := comicSvc.GetSeedSettings(sceneID)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "读取种子策略失败")
+		return
+	}
+	h.Response.Success(c, settings, "获取种子策略成功")
+}
+
+// UpdateComicSeedSettings sets the scene seed strategy: {"strategy":"fixed|per_frame|random","base_seed":123}.
+// Route: PUT /api/scenes/:id/comic/seed
+func (h *Handler) UpdateComicSeedSettings(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var req models.ComicSeedSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "请求参数不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	settings, err := comicSvc.UpdateSeedSettings(sceneID, req)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "保存种子策略失败")
+		return
+	}
+	h.Response.Success(c, settings, "种子策略已保存")
+}
+
+// StartComicReproduceFrame re-renders a frame with its recorded prompt, model, size and seed.
+// Body (optional): {"version": 3, "prompt": "..."}; a prompt replaces only the prompt for A/B comparison.
+// Route: POST /api/scenes/:id/comic/frames/:frameID/reproduce
+func (h *Handler) StartComicReproduceFrame(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var req services.ComicReproduceOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.Response.BadRequest(c, "请求参数不合法", err.Error())
+			return
+		}
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	taskID, err := comicSvc.ReproduceFrameAsync(c.Request.Context(), sceneID, c.Param("frameID"), req)
+	if err != nil {
+		h.respondComicFrameEditError(c, err, "启动复现失败")
+		return
+	}
+	h.Response.Accepted(c, gin.H{"task_id": taskID}, "复现任务已受理")
+}
+
+// respondComicCampaignError maps campaign errors to HTTP responses.
+func (h *Handler) respondComicCampaignError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidComicCampaign):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "批量任务参数不合法", err.Error())
+	case errors.Is(err, services.ErrComicCampaignNotFound):
+		h.Response.Error(c, http.StatusNotFound, ErrorNotFound, "批量任务不存在")
+	case errors.Is(err, services.ErrComicServiceNotReady), errors.Is(err, services.ErrComicRepositoryNotReady):
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未就绪", err.Error())
+	default:
+		h.Response.InternalError(c, action, err.Error())
+	}
+}
+
+// StartComicCampaign runs a range of comic steps over many scenes.
+// The returned campaign id is also the task id of the aggregated progress stream (/api/progress/:taskID).
+// Route: POST /api/comic/campaigns
+func (h *Handler) StartComicCampaign(c *gin.Context) {
+	var req services.ComicCampaignOptions
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "请求参数不合法", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	campaign, err := comicSvc.StartComicCampaign(c.GetString("user_id"), req)
+	if err != nil {
+		h.respondComicCampaignError(c, err, "启动批量任务失败")
+		return
+	}
+	h.Response.Accepted(c, gin.H{"campaign": campaign, "task_id": campaign.ID}, "批量任务已受理")
+}
+
+// ListComicCampaigns lists the caller's running and recently finished campaigns (all of them for admins).
+// Route: GET /api/comic/campaigns
+func (h *Handler) ListComicCampaigns(c *gin.Context) {
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+	campaigns := make([]models.ComicCampaign, 0)
+	for _, campaign := range comicSvc.ListComicCampaigns() {
+		if callerOwns(c, campaign.CreatedBy) {
+			campaigns = append(campaigns, campaign)
+		}
+	}
+	h.Response.Success(c, campaigns, "获取批量任务成功")
+}
+
+// ownedComicCampaign loads a campaign and hides campaigns created by other users behind a 404.
+func (h *Handler) ownedComicCampaign(c *gin.Context, comicSvc *services.ComicService, action string) (*models.ComicCampaign, bool) {
+	campaign, err := comicSvc.GetComicCampaign(c.Param("id"))
+	if err == nil && !callerOwns(c, campaign.CreatedBy) {
+		err = services.ErrComicCampaignNotFound
+	}
+	if err != nil {
+		h.respondComicCampaignError(c, err, action)
+		return nil, false
+	}
+	return campaign, true
+}
+
+// GetComicCampaign returns the per-scene status of a campaign.
+// Route: GET /api/comic/campaigns/:id
+func (h *Handler) GetComicCampaign(c *gin.Context) {
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+	campaign, ok := h.ownedComicCampaign(c, comicSvc, "获取批量任务失败")
+	if !ok {
+		return
+	}
+	h.Response.Success(c, campaign, "获取批量任务成功")
+}
+
+// CancelComicCampaign stops a campaign; running step jobs are cancelled.
+// Route: POST /api/comic/campaigns/:id/cancel
+func (h *Handler) CancelComicCampaign(c *gin.Context) {
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+	if _, ok := h.ownedComicCampaign(c, comicSvc, "取消批量任务失败"); !ok {
+		return
+	}
+	campaign, err := comicSvc.CancelComicCampaign(c.Param("id"))
+	if err != nil {
+		h.respondComicCampaignError(c, err, "取消批量任务失败")
+		return
+	}
+	h.Response.Success(c, campaign, "已请求取消批量任务")
+}
+
+type comicGenerateFramesRequest struct {
+	FrameIDs []string `json:"frame_ids"`
+}
+
+// StartComicGenerateFrames starts async jobs to regenerate multiple frames in parallel.
+func (h *Handler) StartComicGenerateFrames(c *gin.Context) {
+	sceneID := c.Param("id")
+	if strings.TrimSpace(sceneID) == "" {
+		h.Response.BadRequest(c, "缺少场景ID")
+		return
+	}
+
+	var req comicGenerateFramesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "无效请求", err.Error())
+		return
+	}
+	if len(req.FrameIDs) == 0 {
+		h.Response.BadRequest(c, "缺少帧ID列表")
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	repo := h.getComicRepo()
+	if repo == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+		return
+	}
+
+	for _, frameID := range req.FrameIDs {
+		frameID = strings.TrimSpace(frameID)
+		if frameID == "" {
+			continue
+		}
+		if _, err := repo.LoadPrompt(sceneID, frameID); err != nil {
+			if isStorageNotFound(err) {
+				h.Response.Error(c, http.StatusNotFound, ErrorComicPromptsNotFound, "提示词不存在")
+				return
+			}
+			h.Response.InternalError(c, "读取提示词失败", err.Error())
+			return
+		}
+	}
+
+	tasks, err := comicSvc.GenerateFramesAsync(c.Request.Context(), sceneID, req.FrameIDs)
+	if err != nil {
+		if errors.Is(err, services.ErrComicServiceNotReady) || errors.Is(err, services.ErrComicRepositoryNotReady) {
+			h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未就绪", err.Error())
+			return
+		}
+		h.Response.InternalError(c, "启动批量生成失败", err.Error())
+		return
+	}
+
+	h.Response.Accepted(c, gin.H{"tasks": tasks}, "批量生成任务已受理")
+}
+
+// GetComicOverview returns a lightweight overview of a scene's comic artifacts.
+func (h *Handler) GetComicOverview(c *gin.Context) {
+	sceneID := c.Param("id")
+	if strings.TrimSpace(sceneID) == "" {
+		h.Response.BadRequest(c, "缺少场景ID")
+		return
+	}
+
+	repo := h.getComicRepo()
+	if repo == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+		return
+	}
+
+	sceneDir := filepath.Join("scene_" + sceneID)
+	absSceneDir := filepath.Join(repo.BaseDir, sceneDir)
+
+	hasAnalysis := false
+	if _, err := repo.LoadAnalysis(sceneID); err == nil {
+		hasAnalysis = true
+	} else if !isStorageNotFound(err) {
+		h.Response.InternalError(c, "读取分镜分析失败", err.Error())
+		return
+	}
+
+	// prompts
+	hasPrompts := false
+	{
+		absPromptsDir := filepath.Join(absSceneDir, "prompts")
+		entries, err := os.ReadDir(absPromptsDir)
+		if err == nil {
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				if strings.HasSuffix(strings.ToLower(e.Name()), ".json") {
+					hasPrompts = true
+					break
+				}
+			}
+		} else if !isStorageNotFound(err) {
+			h.Response.InternalError(c, "读取提示词目录失败", err.Error())
+			return
+		}
+	}
+
+	// key elements
+	hasKeyElements := false
+	if _, err := repo.LoadKeyElements(sceneID); err == nil {
 		hasKeyElements = true
 	} else if !isStorageNotFound(err) {
 		h.Response.InternalError(c, "读取关键元素失败", err.Error())
@@ -1932,6 +2662,24 @@ func (h *Handler) GetComicOverview(c *gin.Context) {
 		}
 	}
 
+	// composed pages
+	pages := make([]string, 0)
+	{
+		absPagesDir := filepath.Join(absSceneDir, "pages")
+		entries, err := os.ReadDir(absPagesDir)
+		if err == nil {
+			for _, e := range entries {
+				if !e.IsDir() && strings.HasSuffix(strings.ToLower(e.Name()), ".png") {
+					pages = append(pages, e.Name())
+				}
+			}
+			sort.Strings(pages)
+		} else if !isStorageNotFound(err) {
+			h.Response.InternalError(c, "读取页面目录失败", err.Error())
+			return
+		}
+	}
+
 	h.Response.Success(c, gin.H{
 		"scene_id":         sceneID,
 		"has_analysis":     hasAnalysis,
@@ -1939,6 +2687,7 @@ func (h *Handler) GetComicOverview(c *gin.Context) {
 		"has_key_elements": hasKeyElements,
 		"reference_count":  refCount,
 		"images":           images,
+		"pages":            pages,
 	}, "comics 概览获取成功")
 }
 
@@ -1960,6 +2709,7 @@ func validateComicSceneID(sceneID string) error {
 // - analysis.json, key_elements.json, metrics.json
 // - prompts/*.json
 // - images/*.png
+// - pages/layout.json, pages/*.png (composed pages)
 // - references/* (including index.json)
 func (h *Handler) ExportComic(c *gin.Context) {
 	sceneID := c.Param("id")
@@ -1969,8 +2719,8 @@ func (h *Handler) ExportComic(c *gin.Context) {
 	}
 
 	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "zip")))
-	if format != "zip" && format != "html" {
-		h.Response.Error(c, http.StatusBadRequest, ErrorExportFormatInvalid, "不支持的导出格式", "支持的格式: zip/html")
+	if format != "zip" && format != "html" && format != "cbz" && format != "pdf" {
+		h.Response.Error(c, http.StatusBadRequest, ErrorExportFormatInvalid, "不支持的导出格式", "支持的格式: zip/html/cbz/pdf")
 		return
 	}
 
@@ -1990,6 +2740,12 @@ func (h *Handler) ExportComic(c *gin.Context) {
 		return
 	}
 
+	// CBZ / print-ready PDF: composed pages (or raw frames) in reading order
+	if format == "cbz" || format == "pdf" {
+		h.exportComicBook(c, sceneID, format)
+		return
+	}
+
 	// HTML export: a self-contained page with embedded images
 	if format == "html" {
 		type frameItem struct {
@@ -2132,97 +2888,373 @@ func (h *Handler) ExportComic(c *gin.Context) {
 		}
 	}
 
-	// images/*.png
-	{
-		absImagesDir := filepath.Join(absSceneDir, "images")
-		entries, err := os.ReadDir(absImagesDir)
-		if err != nil {
-			if !isStorageNotFound(err) {
-				h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
-				return
-			}
-		} else {
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
-				}
-				name := e.Name()
-				if strings.HasSuffix(strings.ToLower(name), ".png") {
-					items = append(items, zipItem{
-						absPath: filepath.Join(absImagesDir, name),
-						zipName: filepath.ToSlash(filepath.Join(zipRoot, "images", name)),
-					})
-				}
-			}
-		}
+	// images/*.png
+	{
+		absImagesDir := filepath.Join(absSceneDir, "images")
+		entries, err := os.ReadDir(absImagesDir)
+		if err != nil {
+			if !isStorageNotFound(err) {
+				h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
+				return
+			}
+		} else {
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				name := e.Name()
+				if strings.HasSuffix(strings.ToLower(name), ".png") {
+					items = append(items, zipItem{
+						absPath: filepath.Join(absImagesDir, name),
+						zipName: filepath.ToSlash(filepath.Join(zipRoot, "images", name)),
+					})
+				}
+			}
+		}
+	}
+
+	// pages/layout.json + pages/*.png
+	{
+		absPagesDir := filepath.Join(absSceneDir, "pages")
+		entries, err := os.ReadDir(absPagesDir)
+		if err != nil {
+			if !isStorageNotFound(err) {
+				h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
+				return
+			}
+		} else {
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				name := e.Name()
+				lower := strings.ToLower(name)
+				if lower == "layout.json" || strings.HasSuffix(lower, ".png") {
+					items = append(items, zipItem{
+						absPath: filepath.Join(absPagesDir, name),
+						zipName: filepath.ToSlash(filepath.Join(zipRoot, "pages", name)),
+					})
+				}
+			}
+		}
+	}
+
+	// references/*
+	{
+		absRefsDir := filepath.Join(absSceneDir, "references")
+		entries, err := os.ReadDir(absRefsDir)
+		if err != nil {
+			if !isStorageNotFound(err) {
+				h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
+				return
+			}
+		} else {
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				name := e.Name()
+				items = append(items, zipItem{
+					absPath: filepath.Join(absRefsDir, name),
+					zipName: filepath.ToSlash(filepath.Join(zipRoot, "references", name)),
+				})
+			}
+		}
+	}
+
+	if len(items) == 0 {
+		h.Response.Error(c, http.StatusNotFound, ErrorExportDataEmpty, "没有可导出的 comics 数据")
+		return
+	}
+
+	ts := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("comic_%s_%s.zip", sceneID, ts)
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	defer func() { _ = zw.Close() }()
+
+	for _, it := range items {
+		st, err := os.Stat(it.absPath)
+		if err != nil {
+			continue
+		}
+		if st.IsDir() {
+			continue
+		}
+
+		hdr, err := zip.FileInfoHeader(st)
+		if err != nil {
+			continue
+		}
+		hdr.Name = it.zipName
+		hdr.Method = zip.Deflate
+
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			continue
+		}
+		f, err := os.Open(it.absPath)
+		if err != nil {
+			continue
+		}
+		_, _ = io.Copy(w, f)
+		_ = f.Close()
+	}
+}
+
+// exportComicBook writes a CBZ (with ComicInfo.xml) or a print-ready PDF.
+// Query: source=pages|frames, and for PDF page_size, width_mm, height_mm, bleed_mm, dpi, fit.
+func (h *Handler) exportComicBook(c *gin.Context, sceneID string, format string) {
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorExportServiceUnavailable, "导出服务未就绪", "ComicService 未初始化")
+		return
+	}
+
+	pdfOpts := services.ComicPDFOptions{
+		PageSize: c.Query("page_size"),
+		Fit:      c.Query("fit"),
+	}
+	if format == "pdf" {
+		parseFloat := func(name string, dst *float64) bool {
+			raw := strings.TrimSpace(c.Query(name))
+			if raw == "" {
+				return true
+			}
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "导出参数不合法", name+" 必须为数字")
+				return false
+			}
+			*dst = v
+			return true
+		}
+		if !parseFloat("width_mm", &pdfOpts.WidthMM) || !parseFloat("height_mm", &pdfOpts.HeightMM) {
+			return
+		}
+		if raw := strings.TrimSpace(c.Query("bleed_mm")); raw != "" {
+			var bleed float64
+			if !parseFloat("bleed_mm", &bleed) {
+				return
+			}
+			pdfOpts.BleedMM = &bleed
+		}
+		if raw := strings.TrimSpace(c.Query("dpi")); raw != "" {
+			dpi, err := strconv.Atoi(raw)
+			if err != nil {
+				h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "导出参数不合法", "dpi 必须为整数")
+				return
+			}
+			pdfOpts.DPI = dpi
+		}
+		if err := services.ValidateComicPDFOptions(pdfOpts); err != nil {
+			h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "导出参数不合法", err.Error())
+			return
+		}
+	}
+
+	images, _, err := comicSvc.LoadComicExportImages(sceneID, c.Query("source"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidComicExportOptions):
+			h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "导出参数不合法", err.Error())
+		case errors.Is(err, services.ErrComicExportEmpty):
+			h.Response.Error(c, http.StatusNotFound, ErrorExportDataEmpty, "没有可导出的 comics 数据", "缺少已渲染的页面或分镜图片")
+		default:
+			h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "application/vnd.comicbook+zip"
+	if format == "cbz" {
+		info, err := comicSvc.BuildComicInfoXML(sceneID, images)
+		if err == nil {
+			err = services.WriteComicCBZ(&buf, images, info)
+		}
+		if err != nil {
+			h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
+			return
+		}
+	} else {
+		contentType = "application/pdf"
+		pdfOpts.Title = comicSvc.ComicExportTitle(sceneID)
+		if err := services.WriteComicPDF(&buf, images, pdfOpts); err != nil {
+			h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
+			return
+		}
+	}
+
+	ts := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("comic_%s_%s.%s", sceneID, ts, format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	_, _ = c.Writer.Write(buf.Bytes())
+}
+
+// respondComicPagesError maps page composer errors to HTTP responses.
+func (h *Handler) respondComicPagesError(c *gin.Context, err error, notFoundCode string, notFoundMessage string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidComicPageLayout), errors.Is(err, services.ErrInvalidSceneID):
+		h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "页面排版参数不合法", err.Error())
+	case errors.Is(err, services.ErrComicRepositoryNotReady):
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+	case isStorageNotFound(err):
+		h.Response.Error(c, http.StatusNotFound, notFoundCode, notFoundMessage)
+	default:
+		h.Response.InternalError(c, "漫画页面渲染失败", err.Error())
+	}
+}
+
+// GetComicPageTemplates lists the built-in panel grids.
+func (h *Handler) GetComicPageTemplates(c *gin.Context) {
+	h.Response.Success(c, gin.H{"templates": services.ListComicPageTemplates()}, "页面模板获取成功")
+}
+
+// ComposeComicPages arranges analysed frames into pages with captions and balloons and renders them.
+func (h *Handler) ComposeComicPages(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var req services.ComicPageComposeOptions
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.Response.BadRequest(c, "无效请求", err.Error())
+		return
+	}
+
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
+
+	layout, err := comicSvc.ComposePages(sceneID, req)
+	if err != nil {
+		h.respondComicPagesError(c, err, ErrorComicAnalysisNotFound, "请先生成分镜分析（analysis.json）")
+		return
+	}
+	h.Response.Success(c, layout, "漫画页面生成成功")
+}
+
+// GetComicPages returns the editable page layout.
+func (h *Handler) GetComicPages(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	repo := h.getComicRepo()
+	if repo == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+		return
+	}
+
+	layout, err := repo.LoadPageLayout(sceneID)
+	if err != nil {
+		h.respondComicPagesError(c, err, ErrorComicPagesNotFound, "漫画页面尚未生成")
+		return
+	}
+	h.Response.Success(c, layout, "漫画页面获取成功")
+}
+
+// UpdateComicPages replaces the page layout with an edited version and re-renders every page.
+func (h *Handler) UpdateComicPages(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+
+	var layout models.ComicPageLayout
+	if err := c.ShouldBindJSON(&layout); err != nil {
+		h.Response.BadRequest(c, "无效请求", err.Error())
+		return
 	}
 
-	// references/*
-	{
-		absRefsDir := filepath.Join(absSceneDir, "references")
-		entries, err := os.ReadDir(absRefsDir)
-		if err != nil {
-			if !isStorageNotFound(err) {
-				h.Response.Error(c, http.StatusInternalServerError, ErrorExportFailed, "导出失败", err.Error())
-				return
-			}
-		} else {
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
-				}
-				name := e.Name()
-				items = append(items, zipItem{
-					absPath: filepath.Join(absRefsDir, name),
-					zipName: filepath.ToSlash(filepath.Join(zipRoot, "references", name)),
-				})
-			}
-		}
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
 	}
 
-	if len(items) == 0 {
-		h.Response.Error(c, http.StatusNotFound, ErrorExportDataEmpty, "没有可导出的 comics 数据")
+	out, err := comicSvc.RenderPages(sceneID, &layout)
+	if err != nil {
+		h.respondComicPagesError(c, err, ErrorComicPagesNotFound, "漫画页面尚未生成")
 		return
 	}
+	h.Response.Success(c, out, "漫画页面已更新")
+}
 
-	ts := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("comic_%s_%s.zip", sceneID, ts)
+// RerenderComicPages renders the stored layout again (e.g. after frames were regenerated).
+func (h *Handler) RerenderComicPages(c *gin.Context) {
+	sceneID := c.Param("id")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
 
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
-	c.Header("Cache-Control", "no-store")
-	c.Status(http.StatusOK)
+	comicSvc := h.getComicService()
+	if comicSvc == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicService 未初始化")
+		return
+	}
 
-	zw := zip.NewWriter(c.Writer)
-	defer func() { _ = zw.Close() }()
+	out, err := comicSvc.RerenderPages(sceneID)
+	if err != nil {
+		h.respondComicPagesError(c, err, ErrorComicPagesNotFound, "漫画页面尚未生成")
+		return
+	}
+	h.Response.Success(c, out, "漫画页面已重新渲染")
+}
 
-	for _, it := range items {
-		st, err := os.Stat(it.absPath)
-		if err != nil {
-			continue
-		}
-		if st.IsDir() {
-			continue
-		}
+// GetComicPageImage returns a rendered page PNG.
+func (h *Handler) GetComicPageImage(c *gin.Context) {
+	sceneID := c.Param("id")
+	pageID := c.Param("pageID")
+	if err := validateComicSceneID(sceneID); err != nil {
+		h.Response.BadRequest(c, "场景ID不合法", err.Error())
+		return
+	}
+	if strings.TrimSpace(pageID) == "" {
+		h.Response.BadRequest(c, "缺少 pageID")
+		return
+	}
 
-		hdr, err := zip.FileInfoHeader(st)
-		if err != nil {
-			continue
-		}
-		hdr.Name = it.zipName
-		hdr.Method = zip.Deflate
+	repo := h.getComicRepo()
+	if repo == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorComicServiceNotReady, "ComicRepository 未初始化")
+		return
+	}
 
-		w, err := zw.CreateHeader(hdr)
-		if err != nil {
-			continue
+	data, err := repo.LoadPageImage(sceneID, pageID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSceneID) {
+			h.Response.Error(c, http.StatusBadRequest, ErrorBadRequest, "非法 pageID", err.Error())
+			return
 		}
-		f, err := os.Open(it.absPath)
-		if err != nil {
-			continue
+		if isStorageNotFound(err) {
+			h.Response.Error(c, http.StatusNotFound, ErrorComicPagesNotFound, "页面不存在")
+			return
 		}
-		_, _ = io.Copy(w, f)
-		_ = f.Close()
+		h.Response.InternalError(c, "读取页面失败", err.Error())
+		return
 	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "image/png", data)
 }
 
 // Handler 处理API请求
@@ -3019,8 +4051,10 @@ func (h *Handler) ScriptGenerate(c *gin.Context) {
 	// 创建进度跟踪器
 	tracker := h.ProgressService.CreateTracker(taskID)
 
+	// 后台任务不随请求取消，但保留请求上下文中的用量归属
+	usageCtx := context.WithoutCancel(c.Request.Context())
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		ctx, cancel := context.WithTimeout(usageCtx, 5*time.Minute)
 		defer cancel()
 
 		tracker.UpdateProgress(1, "任务开始")
@@ -3259,29 +4293,7 @@ func (h *Handler) CreateScene(c *gin.Context) {
 	}
 
 	// 自动初始化故事数据
-	storyService := h.getStoryService()
-	if storyService != nil {
-		// 使用默认偏好设置
-		defaultPrefs := &models.UserPreferences{
-			CreativityLevel: models.CreativityBalanced,
-			AllowPlotTwists: true,
-		}
-
-		// 异步初始化故事，避免阻塞响应
-		go func() {
-			_, err := storyService.InitializeStoryForScene(scene.ID, defaultPrefs)
-			if err != nil {
-				h.Logger.Error("Failed to auto-initialize story for new scene", map[string]interface{}{
-					"scene_id": scene.ID,
-					"error":    err.Error(),
-				})
-			} else {
-				h.Logger.Info("Successfully auto-initialized story for new scene", map[string]interface{}{
-					"scene_id": scene.ID,
-				})
-			}
-		}()
-	}
+	h.autoInitializeStory(scene.ID)
 
 	duration := time.Since(startTime)
 	h.Logger.Info("Scene created successfully", map[string]interface{}{
@@ -3297,6 +4309,121 @@ func (h *Handler) CreateScene(c *gin.Context) {
 	h.Response.Created(c, scene, "场景创建成功")
 }
 
+// ImportScene 从上传的书籍文件（txt/md/html/epub/docx/pdf）创建场景。
+// 章节保存为原文片段；全书按章节逐块分析，在后台执行并返回进度任务 ID。
+func (h *Handler) ImportScene(c *gin.Context) {
+	startTime := time.Now()
+
+	filename, doc, truncated, ok := h.importUploadedFile(c)
+	if !ok {
+		return
+	}
+
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = doc.Title
+	}
+	if runes := []rune(title); len(runes) > 200 {
+		title = string(runes[:200])
+	}
+
+	userID, isAuthenticated := GetUserFromContext(c)
+	if !isAuthenticated {
+		userID = "anonymous"
+	}
+
+	h.Logger.Info("Importing scene from file", map[string]interface{}{
+		"title":     title,
+		"filename":  filename,
+		"format":    doc.Format,
+		"chapters":  len(doc.Chapters),
+		"truncated": truncated,
+		"client_ip": c.ClientIP(),
+	})
+
+	if h.AnalyzerService == nil || h.AnalyzerService.LLMService == nil || !h.AnalyzerService.LLMService.IsReady() {
+		h.Metrics.RecordError("llm_not_ready", "llm_service")
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorLLMNotReady,
+			MessageLLMNotReady, "请在设置中配置LLM API密钥后重试")
+		return
+	}
+
+	jq, ok := h.jobQueue(c)
+	if !ok {
+		return
+	}
+
+	// 整本书逐章分析耗时较长，作为 JobQueue 任务在后台执行并通过进度流推送每个分块的进度
+	taskID := fmt.Sprintf("import_%d", time.Now().UnixNano())
+	tracker := h.ProgressService.CreateTracker(taskID)
+	tracker.UpdateProgress(0, fmt.Sprintf("开始导入《%s》：%d 个章节", title, len(doc.Chapters)))
+
+	text, segments := doc.Text(), doc.Segments()
+	opts := services.JobOptions{Type: services.SceneJobTypeImport, UserID: services.JobUserFromContext(c.Request.Context())}
+	if err := jq.SubmitJob(taskID, opts, func(jobCtx context.Context) error {
+		scene, err := h.SceneService.CreateSceneFromTextWithProgress(jobCtx, userID, text, title, segments, tracker)
+		if err != nil {
+			h.Logger.Error("Failed to import scene", map[string]interface{}{
+				"task_id":  taskID,
+				"title":    title,
+				"filename": filename,
+				"error":    err.Error(),
+			})
+			h.Metrics.RecordError("scene_import_failed", "scene_service")
+			tracker.Fail(err.Error())
+			return err
+		}
+
+		h.autoInitializeStory(scene.ID)
+		h.Metrics.RecordSceneInteraction(scene.ID, "scene_created")
+		tracker.EmitProgressEventWithMeta(99, "场景已创建", "scene_created", "", &services.ProgressEventMeta{SceneID: scene.ID})
+		tracker.Complete(fmt.Sprintf("导入完成，场景已创建: %s", scene.ID))
+		return nil
+	}); err != nil {
+		tracker.Fail(err.Error())
+		h.Response.InternalError(c, "提交导入任务失败", err.Error())
+		return
+	}
+
+	duration := time.Since(startTime)
+	h.Metrics.RecordAPIRequest("import_scene", "POST", http.StatusAccepted, duration)
+
+	h.Response.Accepted(c, gin.H{
+		"task_id":   taskID,
+		"format":    doc.Format,
+		"chapters":  len(doc.Chapters),
+		"truncated": truncated,
+	}, "场景导入已开始，请订阅进度更新")
+}
+
+// autoInitializeStory 使用默认偏好异步初始化新场景的故事数据，避免阻塞响应
+func (h *Handler) autoInitializeStory(sceneID string) {
+	storyService := h.getStoryService()
+	if storyService == nil {
+		return
+	}
+
+	// 使用默认偏好设置
+	defaultPrefs := &models.UserPreferences{
+		CreativityLevel: models.CreativityBalanced,
+		AllowPlotTwists: true,
+	}
+
+	go func() {
+		_, err := storyService.InitializeStoryForScene(sceneID, defaultPrefs)
+		if err != nil {
+			h.Logger.Error("Failed to auto-initialize story for new scene", map[string]interface{}{
+				"scene_id": sceneID,
+				"error":    err.Error(),
+			})
+		} else {
+			h.Logger.Info("Successfully auto-initialized story for new scene", map[string]interface{}{
+				"scene_id": sceneID,
+			})
+		}
+	}()
+}
+
 // DeleteScene 删除指定场景
 func (h *Handler) DeleteScene(c *gin.Context) {
 	sceneID := c.Param("id")
@@ -3398,18 +4525,140 @@ func (h *Handler) Chat(c *gin.Context) {
 		return
 	}
 
-	duration := time.Since(startTime)
-	h.Logger.Info("Chat request completed successfully", map[string]interface{}{
-		"scene_id":     req.SceneID,
-		"character_id": req.CharacterID,
-		"duration_ms":  duration.Milliseconds(),
-		"client_ip":    c.ClientIP(),
-	})
+	duration := time.Since(startTime)
+	h.Logger.Info("Chat request completed successfully", map[string]interface{}{
+		"scene_id":     req.SceneID,
+		"character_id": req.CharacterID,
+		"duration_ms":  duration.Milliseconds(),
+		"client_ip":    c.ClientIP(),
+	})
+
+	h.Metrics.RecordAPIRequest("chat", "POST", http.StatusOK, duration)
+	h.Metrics.RecordSceneInteraction(req.SceneID, "chat")
+
+	h.Response.Success(c, response, "回应生成成功")
+}
+
+// AddCharacterMemoryRequest 手动添加角色记忆的请求
+type AddCharacterMemoryRequest struct {
+	Key        string `json:"key"`
+	Details    string `json:"details" binding:"required"`
+	Importance *int   `json:"importance"` // 1-10，省略时为 5
+}
+
+func (h *Handler) getMemoryService() *services.MemoryService {
+	container := di.GetContainer()
+	memoryService, ok := container.Get("memory").(*services.MemoryService)
+	if !ok {
+		utils.GetLogger().Warn("cannot get memory service from container", map[string]interface{}{})
+		return nil
+	}
+	return memoryService
+}
+
+// GetCharacterMemories 获取角色的长期记忆；带 q 参数时按相关度检索前 limit 条
+func (h *Handler) GetCharacterMemories(c *gin.Context) {
+	sceneID := c.Param("id")
+	characterID := c.Param("character_id")
+
+	memoryService := h.getMemoryService()
+	if memoryService == nil {
+		h.Response.InternalError(c, "记忆服务未初始化", "无法获取记忆服务实例")
+		return
+	}
+	if _, err := h.CharacterService.GetCharacter(sceneID, characterID); err != nil {
+		h.Response.NotFound(c, "角色", "角色ID: "+characterID)
+		return
+	}
+
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+		results, err := memoryService.Retrieve(sceneID, characterID, query, limit)
+		if err != nil {
+			h.Response.InternalError(c, "检索角色记忆失败", err.Error())
+			return
+		}
+		h.Response.Success(c, results, "角色记忆检索成功")
+		return
+	}
+
+	memories, err := memoryService.ListMemories(sceneID, characterID)
+	if err != nil {
+		h.Response.InternalError(c, "获取角色记忆失败", err.Error())
+		return
+	}
+	h.Response.Success(c, memories, "角色记忆获取成功")
+}
+
+// AddCharacterMemory 手动为角色添加一条记忆（与已有记忆重复时合并）
+func (h *Handler) AddCharacterMemory(c *gin.Context) {
+	sceneID := c.Param("id")
+	characterID := c.Param("character_id")
+
+	var req AddCharacterMemoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "请求参数无效", err.Error())
+		return
+	}
+	importance := 0 // 由记忆服务取默认重要性
+	if req.Importance != nil {
+		if *req.Importance < 1 || *req.Importance > 10 {
+			h.Response.BadRequest(c, "importance 必须在 1-10 之间")
+			return
+		}
+		importance = *req.Importance
+	}
+
+	memoryService := h.getMemoryService()
+	if memoryService == nil {
+		h.Response.InternalError(c, "记忆服务未初始化", "无法获取记忆服务实例")
+		return
+	}
+	if _, err := h.CharacterService.GetCharacter(sceneID, characterID); err != nil {
+		h.Response.NotFound(c, "角色", "角色ID: "+characterID)
+		return
+	}
+
+	stored, err := memoryService.AddMemories(sceneID, characterID, []models.Memory{{
+		Key:        req.Key,
+		Details:    req.Details,
+		Importance: importance,
+	}})
+	if err != nil {
+		h.Response.InternalError(c, "添加角色记忆失败", err.Error())
+		return
+	}
+	if len(stored) == 0 {
+		h.Response.BadRequest(c, "记忆内容不能为空")
+		return
+	}
+	h.Response.Created(c, stored[0], "角色记忆添加成功")
+}
 
-	h.Metrics.RecordAPIRequest("chat", "POST", http.StatusOK, duration)
-	h.Metrics.RecordSceneInteraction(req.SceneID, "chat")
+// DeleteCharacterMemory 删除角色的一条记忆
+func (h *Handler) DeleteCharacterMemory(c *gin.Context) {
+	sceneID := c.Param("id")
+	characterID := c.Param("character_id")
+	memoryID := c.Param("memory_id")
 
-	h.Response.Success(c, response, "回应生成成功")
+	memoryService := h.getMemoryService()
+	if memoryService == nil {
+		h.Response.InternalError(c, "记忆服务未初始化", "无法获取记忆服务实例")
+		return
+	}
+	if _, err := h.CharacterService.GetCharacter(sceneID, characterID); err != nil {
+		h.Response.NotFound(c, "角色", "角色ID: "+characterID)
+		return
+	}
+	if err := memoryService.DeleteMemory(sceneID, characterID, memoryID); err != nil {
+		if errors.Is(err, services.ErrMemoryNotFound) {
+			h.Response.NotFound(c, "记忆", "记忆ID: "+memoryID)
+			return
+		}
+		h.Response.InternalError(c, "删除角色记忆失败", err.Error())
+		return
+	}
+	h.Response.Success(c, nil, "角色记忆删除成功")
 }
 
 // GetConversations 获取对话历史
@@ -3450,26 +4699,62 @@ func (h *Handler) GetConversations(c *gin.Context) {
 	}
 }
 
-// UploadFile 处理文件上传
+// 文件导入限制：书籍按章节流式提取，超过 maxImportRunes 的部分不再读取（返回 truncated=true）
+const (
+	maxImportFileSize = 50 << 20  // 50 MB
+	maxImportRunes    = 3_000_000 // 约 300 万字
+)
+
+// UploadFile 处理文件上传：支持 txt/md/html/epub/docx/pdf，返回按章节提取的文本
 func (h *Handler) UploadFile(c *gin.Context) {
+	filename, doc, truncated, ok := h.importUploadedFile(c)
+	if !ok {
+		return
+	}
+
+	chapters := make([]map[string]interface{}, 0, len(doc.Chapters))
+	for _, ch := range doc.Chapters {
+		chapters = append(chapters, map[string]interface{}{
+			"title":  ch.Title,
+			"length": utf8.RuneCountInString(ch.Text),
+		})
+	}
+	content := doc.Text()
+
+	// Prepare response data
+	responseData := map[string]interface{}{
+		"filename":  filename,
+		"content":   content,
+		"size":      len(content),
+		"format":    doc.Format,
+		"title":     doc.Title,
+		"chapters":  chapters,
+		"truncated": truncated,
+	}
+
+	// Return success response
+	h.Response.Success(c, responseData, "文件上传成功")
+}
+
+// importUploadedFile 保存上传的文件到临时目录并提取章节；失败时已写出错误响应并返回 ok=false
+func (h *Handler) importUploadedFile(c *gin.Context) (string, *importer.Document, bool, bool) {
 	file, err := c.FormFile("file")
 	if err != nil {
 		h.Response.BadRequest(c, "获取上传文件失败", err.Error())
-		return
+		return "", nil, false, false
 	}
 
-	// 检查文件大小 (limit to 10MB)
-	const maxFileSize = 10 << 20 // 10 MB
-	if file.Size > maxFileSize {
-		h.Response.BadRequest(c, "文件过大", "文件大小不能超过10MB")
-		return
+	// 检查文件大小
+	if file.Size > maxImportFileSize {
+		h.Response.BadRequest(c, "文件过大", fmt.Sprintf("文件大小不能超过%dMB", maxImportFileSize>>20))
+		return "", nil, false, false
 	}
 
 	// 检查文件类型
-	ext := filepath.Ext(file.Filename)
-	if ext != ".txt" && ext != ".md" {
-		h.Response.BadRequest(c, "不支持的文件类型", "只支持.txt或.md文件")
-		return
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !importer.IsSupported(file.Filename) {
+		h.Response.BadRequest(c, "不支持的文件类型", "只支持"+strings.Join(importer.SupportedExtensions(), "、")+"文件")
+		return "", nil, false, false
 	}
 
 	// Generate secure filename to prevent path traversal
@@ -3477,81 +4762,78 @@ func (h *Handler) UploadFile(c *gin.Context) {
 	secureFilename = filepath.Clean(secureFilename) // Clean to prevent path traversal
 
 	// Validate that the cleaned filename still has the correct extension
-	if filepath.Ext(secureFilename) != ext {
+	if strings.ToLower(filepath.Ext(secureFilename)) != ext {
 		h.Response.BadRequest(c, "无效的文件名", "文件名包含不允许的字符")
-		return
+		return "", nil, false, false
 	}
 
 	// Store temporary file with secure name
 	tempPath := filepath.Join("temp", secureFilename)
 	if err := c.SaveUploadedFile(file, tempPath); err != nil {
 		h.Response.InternalError(c, "保存文件失败", err.Error())
-		return
-	}
-
-	// Read file content
-	content, err := os.ReadFile(tempPath)
-	if err != nil {
-		h.Response.InternalError(c, "读取文件失败", err.Error())
-		// Clean up the temp file in case of error
-		os.Remove(tempPath)
-		return
+		return "", nil, false, false
 	}
+	// Delete temporary file after reading
+	defer os.Remove(tempPath)
 
 	// Validate file content based on extension
 	if ext == ".txt" || ext == ".md" {
-		// Basic text file validation: check for null bytes and other binary indicators
-		if strings.Contains(string(content), "\x00") {
-			h.Response.BadRequest(c, "文件格式无效", "文件包含二进制内容")
-			os.Remove(tempPath)
-			return
+		if !h.validateTextUpload(c, tempPath, ext) {
+			return "", nil, false, false
 		}
+	}
 
-		// Limit content size to prevent memory exhaustion
-		const maxContentLength = 1000000 // 1MB of content
-		if len(content) > maxContentLength {
-			h.Response.BadRequest(c, "文件内容过大", "文件内容不能超过1MB")
-			os.Remove(tempPath)
-			return
+	doc, truncated, err := importer.ExtractFile(tempPath, maxImportRunes)
+	if err != nil {
+		if errors.Is(err, importer.ErrNoText) {
+			h.Response.BadRequest(c, "文件中没有可提取的文本", "扫描版PDF等无文本层的文件暂不支持")
+		} else {
+			h.Response.BadRequest(c, "文件格式无效", err.Error())
 		}
+		return "", nil, false, false
+	}
+	if doc.Title == strings.TrimSuffix(secureFilename, filepath.Ext(secureFilename)) {
+		doc.Title = strings.TrimSuffix(filepath.Base(file.Filename), filepath.Ext(file.Filename))
+	}
+	return file.Filename, doc, truncated, true
+}
+
+// validateTextUpload 纯文本/Markdown 的安全检查：拒绝二进制内容、Markdown 中的脚本与异常长行
+func (h *Handler) validateTextUpload(c *gin.Context, path, ext string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		h.Response.InternalError(c, "读取文件失败", err.Error())
+		return false
+	}
 
-		// Additional security checks for text files
-		contentStr := string(content)
+	// Basic text file validation: check for null bytes and other binary indicators
+	if strings.Contains(string(content), "\x00") {
+		h.Response.BadRequest(c, "文件格式无效", "文件包含二进制内容")
+		return false
+	}
 
-		// Check for potential script tags in markdown files
-		if ext == ".md" {
-			if strings.Contains(strings.ToLower(contentStr), "<script") ||
-				strings.Contains(strings.ToLower(contentStr), "javascript:") ||
-				strings.Contains(strings.ToLower(contentStr), "data:text/html") {
-				h.Response.BadRequest(c, "文件内容不安全", "文件包含潜在危险内容")
-				os.Remove(tempPath)
-				return
-			}
-		}
+	// Additional security checks for text files
+	contentStr := string(content)
 
-		// Check for extremely long lines that could indicate binary content or be a DoS vector
-		lines := strings.Split(contentStr, "\n")
-		for _, line := range lines {
-			if len(line) > 10000 { // 10KB per line is excessive for text
-				h.Response.BadRequest(c, "文件内容格式异常", "文件包含过长的单行内容")
-				os.Remove(tempPath)
-				return
-			}
+	// Check for potential script tags in markdown files
+	if ext == ".md" {
+		if strings.Contains(strings.ToLower(contentStr), "<script") ||
+			strings.Contains(strings.ToLower(contentStr), "javascript:") ||
+			strings.Contains(strings.ToLower(contentStr), "data:text/html") {
+			h.Response.BadRequest(c, "文件内容不安全", "文件包含潜在危险内容")
+			return false
 		}
 	}
 
-	// Prepare response data
-	responseData := map[string]interface{}{
-		"filename": file.Filename,
-		"content":  string(content),
-		"size":     len(content),
+	// Check for extremely long lines that could indicate binary content or be a DoS vector
+	lines := strings.Split(contentStr, "\n")
+	for _, line := range lines {
+		if len(line) > 10000 { // 10KB per line is excessive for text
+			h.Response.BadRequest(c, "文件内容格式异常", "文件包含过长的单行内容")
+			return false
+		}
 	}
-
-	// Delete temporary file after reading
-	_ = os.Remove(tempPath)
-
-	// Return success response
-	h.Response.Success(c, responseData, "文件上传成功")
+	return true
 }
 
 // AnalyzeTextWithProgress 处理文本分析请求，返回任务ID
@@ -3603,10 +4885,11 @@ func (h *Handler) AnalyzeTextWithProgress(c *gin.Context) {
 	// 创建进度跟踪器
 	tracker := h.ProgressService.CreateTracker(taskID)
 
-	// 启动后台分析
+	// 启动后台分析（不随请求取消，但保留请求上下文中的用量归属）
+	usageCtx := context.WithoutCancel(c.Request.Context())
 	go func() {
-		// Create a context with timeout for the analysis
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		// Create a context with timeout for the analysis (long texts are analyzed chunk by chunk)
+		ctx, cancel := context.WithTimeout(usageCtx, h.AnalyzerService.AnalysisTimeout(req.Text))
 		defer cancel()
 
 		// Log the start of the analysis
@@ -3812,6 +5095,125 @@ func (h *Handler) CancelAnalysisTask(c *gin.Context) {
 	h.Response.Success(c, nil, "任务已标记为取消")
 }
 
+func (h *Handler) jobQueue(c *gin.Context) (*services.JobQueue, bool) {
+	if container := di.GetContainer(); container != nil {
+		if jq, ok := container.Get("job_queue").(*services.JobQueue); ok && jq != nil {
+			return jq, true
+		}
+	}
+	h.Response.Error(c, http.StatusServiceUnavailable, ErrorJobQueueNotReady, "任务队列未就绪")
+	return nil, false
+}
+
+// ListJobs 列出任务队列中的任务（含最近结束的任务），可用 status/type 过滤
+func (h *Handler) ListJobs(c *gin.Context) {
+	jq, ok := h.jobQueue(c)
+	if !ok {
+		return
+	}
+
+	status := strings.TrimSpace(c.Query("status"))
+	jobType := strings.TrimSpace(c.Query("type"))
+
+	jobs := make([]services.JobRecord, 0)
+	for _, rec := range jq.List() {
+		if !callerOwns(c, rec.UserID) {
+			continue
+		}
+		if status != "" && string(rec.Status) != status {
+			continue
+		}
+		if jobType != "" && rec.Type != jobType {
+			continue
+		}
+		jobs = append(jobs, rec)
+	}
+
+	h.Response.Success(c, gin.H{"jobs": jobs, "count": len(jobs)})
+}
+
+// GetJob 获取单个任务状态
+func (h *Handler) GetJob(c *gin.Context) {
+	jq, ok := h.jobQueue(c)
+	if !ok {
+		return
+	}
+
+	rec, exists := jq.Get(c.Param("id"))
+	if !exists || !callerOwns(c, rec.UserID) {
+		h.Response.Error(c, http.StatusNotFound, ErrorJobNotFound, "任务不存在")
+		return
+	}
+	h.Response.Success(c, rec)
+}
+
+// callerOwns 判断资源是否属于当前调用方；管理员可访问所有资源（包括未记录所有者的资源）
+func callerOwns(c *gin.Context, ownerID string) bool {
+	if ownerID != "" && ownerID == c.GetString("user_id") {
+		return true
+	}
+	return isAdminRequest(c)
+}
+
+// PauseJob 暂停任务；运行中的任务会中断当前尝试，恢复后重新执行
+func (h *Handler) PauseJob(c *gin.Context) {
+	h.controlJob(c, "pause")
+}
+
+// ResumeJob 恢复已暂停的任务
+func (h *Handler) ResumeJob(c *gin.Context) {
+	h.controlJob(c, "resume")
+}
+
+// CancelJob 取消任务
+func (h *Handler) CancelJob(c *gin.Context) {
+	h.controlJob(c, "cancel")
+}
+
+func (h *Handler) controlJob(c *gin.Context, action string) {
+	jq, ok := h.jobQueue(c)
+	if !ok {
+		return
+	}
+
+	taskID := c.Param("id")
+	if rec, exists := jq.Get(taskID); !exists || !callerOwns(c, rec.UserID) {
+		h.Response.Error(c, http.StatusNotFound, ErrorJobNotFound, "任务不存在")
+		return
+	}
+
+	var err error
+	switch action {
+	case "pause":
+		err = jq.Pause(taskID)
+	case "resume":
+		err = jq.Resume(taskID)
+	case "cancel":
+		if !jq.Cancel(taskID) {
+			if _, exists := jq.Get(taskID); exists {
+				err = services.ErrJobStateConflict
+			} else {
+				err = services.ErrJobNotFound
+			}
+		}
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			h.Response.Error(c, http.StatusNotFound, ErrorJobNotFound, "任务不存在")
+		case errors.Is(err, services.ErrJobStateConflict):
+			h.Response.Error(c, http.StatusConflict, ErrorJobStateConflict, "当前任务状态不支持该操作")
+		default:
+			h.Response.InternalError(c, "任务操作失败", err.Error())
+		}
+		return
+	}
+
+	rec, _ := jq.Get(taskID)
+	h.Response.Success(c, rec, "任务操作已受理")
+}
+
 // ChatWithEmotion 处理带情绪的聊天请求
 func (h *Handler) ChatWithEmotion(c *gin.Context) {
 	var req struct {
@@ -3872,6 +5274,66 @@ func (h *Handler) ChatWithEmotion(c *gin.Context) {
 	h.Response.Success(c, response, "情绪化回应生成成功")
 }
 
+// ChatWithEmotionStream 以SSE流式返回带情绪的角色回应
+// 事件：delta（增量文本）、done（完整的 EmotionalResponse）、error
+func (h *Handler) ChatWithEmotionStream(c *gin.Context) {
+	var req struct {
+		SceneID     string `json:"scene_id" binding:"required"`
+		CharacterID string `json:"character_id" binding:"required"`
+		Message     string `json:"message" binding:"required"`
+	}
+
+	startTime := time.Now()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Metrics.RecordError("invalid_request", "emotional_chat_stream_endpoint")
+		h.Response.BadRequest(c, "请求参数错误", err.Error())
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	writeEvent := func(event string, payload interface{}) {
+		data, _ := json.Marshal(payload)
+		fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, string(data))
+		c.Writer.Flush()
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+
+	response, err := h.CharacterService.StreamResponseWithEmotion(ctx, req.SceneID, req.CharacterID, req.Message, func(delta string) {
+		writeEvent("delta", map[string]interface{}{"text": delta})
+	})
+	if err != nil {
+		h.Logger.Error("Failed to stream emotional response", map[string]interface{}{
+			"scene_id":     req.SceneID,
+			"character_id": req.CharacterID,
+			"error":        err.Error(),
+			"client_ip":    c.ClientIP(),
+		})
+		h.Metrics.RecordError("emotional_response_stream_failed", "character_service")
+		event := map[string]interface{}{"error": err.Error()}
+		var streamErr *services.ChatStreamError
+		if errors.As(err, &streamErr) && streamErr.Partial != "" {
+			event["partial_text"] = streamErr.Partial
+		}
+		writeEvent("error", event)
+		return
+	}
+
+	duration := time.Since(startTime)
+	h.StatsService.RecordAPIRequest(response.TokensUsed)
+	h.Metrics.RecordAPIRequest("chat_emotion_stream", "POST", http.StatusOK, duration)
+	h.Metrics.RecordSceneInteraction(req.SceneID, "emotional_chat")
+
+	writeEvent("done", response)
+}
+
 // GetStoryData 获取指定场景的故事数据
 func (h *Handler) GetStoryData(c *gin.Context) {
 	sceneID := c.Param("id")
@@ -4324,7 +5786,7 @@ func (h *Handler) HandleSceneCommand(c *gin.Context) {
 		ExtraParams: extraParams,
 	}
 
-	resp, err := llmService.CreateChatCompletion(ctx, request)
+	resp, err := llmService.CreateChatCompletion(services.WithLLMScene(services.WithLLMTask(ctx, services.LLMTaskStory), sceneID), request)
 	fallbackUsed := false
 	var answer string
 	var rawLLMContent string
@@ -5135,37 +6597,191 @@ func (h *Handler) getStoryService() *services.StoryService {
 		utils.GetLogger().Warn("cannot get story service from container", map[string]interface{}{})
 		return nil
 	}
-	return storyService
+	return storyService
+}
+
+func (h *Handler) getComicService() *services.ComicService {
+	container := di.GetContainer()
+	comicService, ok := container.Get("comic").(*services.ComicService)
+	if !ok {
+		utils.GetLogger().Warn("cannot get comic service from container", map[string]interface{}{})
+		return nil
+	}
+	return comicService
+}
+
+func (h *Handler) getComicRepo() *services.ComicRepository {
+	container := di.GetContainer()
+	repo, ok := container.Get("comic_repo").(*services.ComicRepository)
+	if !ok {
+		utils.GetLogger().Warn("cannot get comic repo from container", map[string]interface{}{})
+		return nil
+	}
+	return repo
+}
+
+func (h *Handler) getVideoService() *services.VideoService {
+	container := di.GetContainer()
+	videoService, ok := container.Get("video").(*services.VideoService)
+	if !ok {
+		utils.GetLogger().Warn("cannot get video service from container", map[string]interface{}{})
+		return nil
+	}
+	return videoService
+}
+
+// ========================================
+// 存档 API
+// ========================================
+
+// SaveSlotRequest 创建或覆盖存档的请求
+type SaveSlotRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (h *Handler) getSaveService() *services.SaveService {
+	container := di.GetContainer()
+	saveService, ok := container.Get("save").(*services.SaveService)
+	if !ok {
+		utils.GetLogger().Warn("cannot get save service from container", map[string]interface{}{})
+		return nil
+	}
+	return saveService
+}
+
+// respondSaveError 把存档服务的错误映射为 HTTP 响应
+func (h *Handler) respondSaveError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrSaveSlotNotFound):
+		h.Response.NotFound(c, "存档", "存档ID: "+c.Param("save_id"))
+	case errors.Is(err, services.ErrSaveSlotLimit):
+		h.Response.Conflict(c, message, err.Error())
+	case errors.Is(err, services.ErrSaveSlotReserved):
+		h.Response.BadRequest(c, message, err.Error())
+	default:
+		h.Response.InternalError(c, message, err.Error())
+	}
+}
+
+// ListSaveSlots 列出场景存档
+func (h *Handler) ListSaveSlots(c *gin.Context) {
+	saveService := h.getSaveService()
+	if saveService == nil {
+		h.Response.InternalError(c, "存档服务未初始化")
+		return
+	}
+	slots, err := saveService.ListSlots(c.Param("id"))
+	if err != nil {
+		h.respondSaveError(c, "获取存档列表失败", err)
+		return
+	}
+	h.Response.Success(c, slots, "存档列表获取成功")
+}
+
+// CreateSaveSlot 把当前进度保存为新存档
+func (h *Handler) CreateSaveSlot(c *gin.Context) {
+	h.writeSaveSlot(c, "")
+}
+
+// OverwriteSaveSlot 用当前进度覆盖已有存档
+func (h *Handler) OverwriteSaveSlot(c *gin.Context) {
+	h.writeSaveSlot(c, c.Param("save_id"))
+}
+
+func (h *Handler) writeSaveSlot(c *gin.Context, slotID string) {
+	var req SaveSlotRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.Response.BadRequest(c, "请求参数无效", err.Error())
+			return
+		}
+	}
+	saveService := h.getSaveService()
+	if saveService == nil {
+		h.Response.InternalError(c, "存档服务未初始化")
+		return
+	}
+
+	slot, err := saveService.CreateSlot(c.Param("id"), slotID, req.Name, req.Description)
+	if err != nil {
+		h.respondSaveError(c, "保存存档失败", err)
+		return
+	}
+	if slotID == "" {
+		h.Response.Created(c, slot, "存档成功")
+		return
+	}
+	h.Response.Success(c, slot, "存档已覆盖")
+}
+
+// GetSaveSlot 获取完整存档（含快照）
+func (h *Handler) GetSaveSlot(c *gin.Context) {
+	saveService := h.getSaveService()
+	if saveService == nil {
+		h.Response.InternalError(c, "存档服务未初始化")
+		return
+	}
+	slot, err := saveService.GetSlot(c.Param("id"), c.Param("save_id"))
+	if err != nil {
+		h.respondSaveError(c, "获取存档失败", err)
+		return
+	}
+	h.Response.Success(c, slot, "存档获取成功")
 }
 
-func (h *Handler) getComicService() *services.ComicService {
-	container := di.GetContainer()
-	comicService, ok := container.Get("comic").(*services.ComicService)
-	if !ok {
-		utils.GetLogger().Warn("cannot get comic service from container", map[string]interface{}{})
-		return nil
+// LoadSaveSlot 读档；当前进度会先备份到 autosave
+func (h *Handler) LoadSaveSlot(c *gin.Context) {
+	saveService := h.getSaveService()
+	if saveService == nil {
+		h.Response.InternalError(c, "存档服务未初始化")
+		return
 	}
-	return comicService
+	sceneID := c.Param("id")
+	slot, backup, err := saveService.LoadSlot(sceneID, c.Param("save_id"))
+	if err != nil {
+		h.respondSaveError(c, "读档失败", err)
+		return
+	}
+
+	response := gin.H{"save": slot, "backup": backup}
+	if storyService := h.getStoryService(); storyService != nil {
+		if storyData, err := storyService.GetStoryForScene(sceneID); err == nil {
+			response["story"] = storyData
+			response["branch_view"] = buildStoryBranchView(storyData)
+		}
+	}
+	h.Response.Success(c, response, "读档成功")
 }
 
-func (h *Handler) getComicRepo() *services.ComicRepository {
-	container := di.GetContainer()
-	repo, ok := container.Get("comic_repo").(*services.ComicRepository)
-	if !ok {
-		utils.GetLogger().Warn("cannot get comic repo from container", map[string]interface{}{})
-		return nil
+// DiffSaveSlot 比较存档与另一个存档或当前进度（against 默认为 current）
+func (h *Handler) DiffSaveSlot(c *gin.Context) {
+	saveService := h.getSaveService()
+	if saveService == nil {
+		h.Response.InternalError(c, "存档服务未初始化")
+		return
 	}
-	return repo
+	against := strings.TrimSpace(c.DefaultQuery("against", services.CurrentSaveState))
+	diff, err := saveService.DiffSlots(c.Param("id"), c.Param("save_id"), against)
+	if err != nil {
+		h.respondSaveError(c, "比较存档失败", err)
+		return
+	}
+	h.Response.Success(c, diff, "存档比较完成")
 }
 
-func (h *Handler) getVideoService() *services.VideoService {
-	container := di.GetContainer()
-	videoService, ok := container.Get("video").(*services.VideoService)
-	if !ok {
-		utils.GetLogger().Warn("cannot get video service from container", map[string]interface{}{})
-		return nil
+// DeleteSaveSlot 删除存档
+func (h *Handler) DeleteSaveSlot(c *gin.Context) {
+	saveService := h.getSaveService()
+	if saveService == nil {
+		h.Response.InternalError(c, "存档服务未初始化")
+		return
 	}
-	return videoService
+	if err := saveService.DeleteSlot(c.Param("id"), c.Param("save_id")); err != nil {
+		h.respondSaveError(c, "删除存档失败", err)
+		return
+	}
+	h.Response.Success(c, nil, "存档删除成功")
 }
 
 // 构建故事分支视图结构
@@ -5375,6 +6991,8 @@ func (h *Handler) GetSettings(c *gin.Context) {
 		"debug_mode":             cfg.DebugMode,
 		"port":                   cfg.Port,
 		"llm_config":             llmConfig,
+		"llm_fallbacks":          describeLLMFallbacks(cfg.LLMFallbacks),
+		"llm_routes":             cfg.LLMRoutes,
 		"vision_provider":        cfg.VisionProvider,
 		"vision_default_model":   cfg.VisionDefaultModel,
 		"vision_config":          visionConfig,
@@ -5400,6 +7018,11 @@ func (h *Handler) GetSettings(c *gin.Context) {
 		"video_model_providers": cfg.VideoModelProviders,
 		"video_models":          cfg.VideoModels,
 	}
+	// 价格表与配额只对管理员可见
+	if isAdminRequest(c) {
+		data["llm_prices"] = cfg.LLMPrices
+		data["usage_quota"] = cfg.UsageQuota
+	}
 
 	h.Response.Success(c, data, "设置获取成功")
 }
@@ -5691,14 +7314,124 @@ func (h *Handler) GetLLMStatus(c *gin.Context) {
 	}
 
 	// Determine if the service is configured but not ready for other reasons
-	cfgReady := cfg.LLMProvider != "" && cfg.LLMConfig != nil && cfg.LLMConfig["api_key"] != ""
+	cfgReady := services.LLMConfigComplete(cfg.LLMProvider, cfg.LLMConfig)
 	if !llmService.IsReady() {
 		status["configured"] = cfgReady
 	}
 
+	// provider 链（主 provider + 备用 provider）的熔断与健康状态
+	status["fallbacks"] = describeLLMFallbacks(cfg.LLMFallbacks)
+	status["backends"] = llmService.GetBackendHealth()
+	status["routes"] = llmService.GetRoutes()
+
 	h.Response.Success(c, status, "LLM状态获取成功")
 }
 
+// UpdateLLMFallbacks 替换备用 LLM 提供商链；传空数组表示清除
+func (h *Handler) UpdateLLMFallbacks(c *gin.Context) {
+	var req struct {
+		Fallbacks []struct {
+			Provider string            `json:"provider"`
+			Model    string            `json:"model"`
+			Config   map[string]string `json:"config"`
+		} `json:"fallbacks"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "无效的请求格式", err.Error())
+		return
+	}
+
+	fallbacks := make([]config.LLMFallbackInfo, 0, len(req.Fallbacks))
+	for _, fb := range req.Fallbacks {
+		fallbacks = append(fallbacks, config.LLMFallbackInfo{Provider: fb.Provider, Model: fb.Model, Config: fb.Config})
+	}
+	if err := h.ConfigService.UpdateLLMFallbacks(fallbacks, "web_api"); err != nil {
+		h.Response.BadRequest(c, "备用提供商配置无效", err.Error())
+		return
+	}
+
+	h.Response.Success(c, gin.H{
+		"fallbacks": describeLLMFallbacks(config.GetCurrentConfig().LLMFallbacks),
+	}, "备用提供商已更新")
+}
+
+// UpdateLLMRoutes 替换按任务类别的模型路由表；传空对象表示清除
+func (h *Handler) UpdateLLMRoutes(c *gin.Context) {
+	var req struct {
+		Routes map[string]config.LLMRouteInfo `json:"routes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "无效的请求格式", err.Error())
+		return
+	}
+
+	if err := h.ConfigService.UpdateLLMRoutes(req.Routes, "web_api"); err != nil {
+		h.Response.BadRequest(c, "任务路由配置无效", err.Error())
+		return
+	}
+
+	h.Response.Success(c, gin.H{
+		"routes":       config.GetCurrentConfig().LLMRoutes,
+		"task_classes": services.LLMTaskClasses,
+	}, "任务路由已更新")
+}
+
+// UpdateLLMPrices 替换 LLM 价格表（每百万 token 的美元价）；传空对象表示清除
+func (h *Handler) UpdateLLMPrices(c *gin.Context) {
+	var req struct {
+		Prices map[string]config.LLMPriceInfo `json:"prices"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "无效的请求格式", err.Error())
+		return
+	}
+
+	if err := h.ConfigService.UpdateLLMPrices(req.Prices, "web_api"); err != nil {
+		h.Response.BadRequest(c, "价格表无效", err.Error())
+		return
+	}
+
+	h.Response.Success(c, gin.H{
+		"prices": config.GetCurrentConfig().LLMPrices,
+	}, "价格表已更新")
+}
+
+// UpdateUsageQuotas 替换默认用量配额与按用户的配额；各项为 0 表示不限制
+func (h *Handler) UpdateUsageQuotas(c *gin.Context) {
+	var req struct {
+		Default config.UsageQuotaInfo            `json:"default"`
+		Users   map[string]config.UsageQuotaInfo `json:"users"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "无效的请求格式", err.Error())
+		return
+	}
+
+	if err := h.ConfigService.UpdateUsageQuotas(req.Default, req.Users, "web_api"); err != nil {
+		h.Response.BadRequest(c, "用量配额无效", err.Error())
+		return
+	}
+
+	cfg := config.GetCurrentConfig()
+	h.Response.Success(c, gin.H{
+		"default": cfg.UsageQuota,
+		"users":   cfg.UserUsageQuotas,
+	}, "用量配额已更新")
+}
+
+// describeLLMFallbacks 返回备用提供商列表（不含密钥）
+func describeLLMFallbacks(fallbacks []config.LLMFallbackInfo) []gin.H {
+	out := make([]gin.H, 0, len(fallbacks))
+	for _, fb := range fallbacks {
+		out = append(out, gin.H{
+			"provider":    fb.Provider,
+			"model":       fb.Model,
+			"has_api_key": fb.Config["api_key"] != "",
+		})
+	}
+	return out
+}
+
 // UpdateLLMConfig 更新LLM配置
 func (h *Handler) UpdateLLMConfig(c *gin.Context) {
 	var req struct {
@@ -6604,7 +8337,7 @@ func (h *Handler) ExportInteractionSummary(c *gin.Context) {
 	h.Response.ExportResponse(c, result, format)
 }
 
-// Login 处理用户登录请求
+// Login 处理用户登录请求：校验密码哈希并签发访问令牌与刷新令牌
 func (h *Handler) Login(c *gin.Context) {
 	// 从请求体获取登录凭据
 	var req struct {
@@ -6617,77 +8350,263 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	// 在开发环境中，简化登录流程，后续可扩展为真正的用户认证
-	// 建议在生产环境中实现安全的用户认证系统
-	cfg := config.GetCurrentConfig()
+	user, err := h.UserService.Authenticate(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			h.Logger.Warn("User login failed", map[string]interface{}{
+				"username":  req.Username,
+				"client_ip": c.ClientIP(),
+			})
+			h.Response.Error(c, http.StatusUnauthorized, "INVALID_CREDENTIALS",
+				"用户名或密码错误", "请检查您的登录凭据")
+			return
+		}
+		h.Response.InternalError(c, "登录失败", err.Error())
+		return
+	}
 
-	// 检查是否为默认开发账户
-	isValid := false
-	if cfg.DebugMode {
-		// 开发模式下，接受默认凭据
-		isValid = (req.Username == "admin" && req.Password == "admin") ||
-			(req.Username == "user" && req.Password == "user")
-	} else {
-		// 生产模式下，可以使用配置的服务来验证用户
-		// 简单起见，这里直接获取用户，如果存在则认为有效
-		_, err := h.UserService.GetUser(req.Username)
-		isValid = (err == nil)
+	h.respondWithSession(c, user, "登录成功")
+	h.Logger.Info("User login successful", map[string]interface{}{
+		"user_id":   user.ID,
+		"username":  user.Username,
+		"client_ip": c.ClientIP(),
+	})
+}
+
+// Register 处理用户注册请求
+func (h *Handler) Register(c *gin.Context) {
+	if !registrationEnabled {
+		h.Response.Forbidden(c, "注册已关闭", "请联系管理员创建账户")
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Email    string `json:"email,omitempty"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "注册信息格式错误", err.Error())
+		return
+	}
+
+	user, err := h.UserService.Register(req.Username, req.Password, req.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUsernameTaken):
+			h.Response.Conflict(c, "用户名已被注册")
+		case errors.Is(err, services.ErrInvalidUsername):
+			h.Response.BadRequest(c, "用户名格式无效", err.Error())
+		case errors.Is(err, auth.ErrWeakPassword):
+			h.Response.BadRequest(c, "密码不符合要求", err.Error())
+		default:
+			h.Response.InternalError(c, "注册失败", err.Error())
+		}
+		return
+	}
+
+	h.Logger.Info("User registered", map[string]interface{}{
+		"user_id":   user.ID,
+		"username":  user.Username,
+		"client_ip": c.ClientIP(),
+	})
+	h.respondWithSession(c, user, "注册成功")
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对（旧刷新令牌随即吊销）
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "缺少刷新令牌", err.Error())
+		return
+	}
+
+	parsed, err := parseTokenOfKind(strings.TrimSpace(req.RefreshToken), auth.TokenKindRefresh)
+	if err != nil {
+		h.Response.Error(c, http.StatusUnauthorized, ErrorUnauthorized, "刷新令牌无效或已过期")
+		return
+	}
+
+	user, err := h.UserService.GetUser(parsed.UserID)
+	if err != nil {
+		h.Response.Error(c, http.StatusUnauthorized, ErrorUnauthorized, "用户不存在")
+		return
 	}
 
-	if !isValid {
-		h.Response.Error(c, http.StatusUnauthorized, "INVALID_CREDENTIALS",
-			"用户名或密码错误", "请检查您的登录凭据")
+	if err := h.UserService.RevokeSessionToken(parsed.UserID, parsed.ID, time.Unix(parsed.ExpiresAt, 0)); err != nil {
+		h.Response.InternalError(c, "刷新令牌失败", err.Error())
 		return
 	}
 
-	// 生成认证令牌
-	token, err := GenerateUserToken(req.Username)
+	h.respondWithSession(c, user, "令牌已刷新")
+}
+
+func (h *Handler) respondWithSession(c *gin.Context, user *models.User, message string) {
+	tokens, err := IssueSessionTokens(user.ID)
 	if err != nil {
 		h.Response.InternalError(c, "生成认证令牌失败", err.Error())
 		return
 	}
 
-	// 更新用户最后登录时间
-	user, err := h.UserService.GetUser(req.Username)
-	if err == nil {
-		// 更新用户的最后登录时间
-		user.LastLogin = time.Now()
-		h.UserService.SaveUser(user)
-		h.Logger.Info("User login successful", map[string]interface{}{
-			"username":  req.Username,
-			"client_ip": c.ClientIP(),
-		})
-	} else {
-		// 如果用户不存在但在开发模式下有效，创建临时用户
-		if cfg.DebugMode {
-			h.Logger.Info("Development login successful", map[string]interface{}{
-				"username":  req.Username,
+	h.Response.Success(c, gin.H{
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.ExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"user_id":            user.ID,
+		"user": gin.H{
+			"id":           user.ID,
+			"username":     user.Username,
+			"email":        user.Email,
+			"display_name": user.DisplayName,
+			"avatar":       user.Avatar,
+		},
+	}, message)
+}
+
+// Logout 处理用户登出请求：吊销当前访问令牌（以及请求体中的刷新令牌）
+func (h *Handler) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token,omitempty"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	bearer := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if bearer != "" && !auth.IsAPIToken(bearer) {
+		if parsed, err := parseSessionToken(bearer); err == nil {
+			if err := h.UserService.RevokeSessionToken(parsed.UserID, parsed.ID, time.Unix(parsed.ExpiresAt, 0)); err != nil {
+				h.Response.InternalError(c, "登出失败", err.Error())
+				return
+			}
+			h.Logger.Info("User logged out", map[string]interface{}{
+				"user_id":   parsed.UserID,
 				"client_ip": c.ClientIP(),
 			})
 		}
 	}
 
-	// 返回成功响应
-	h.Response.Success(c, gin.H{
-		"token":   token,
-		"user_id": req.Username,
-	}, "登录成功")
+	if refresh := strings.TrimSpace(req.RefreshToken); refresh != "" {
+		if parsed, err := parseTokenOfKind(refresh, auth.TokenKindRefresh); err == nil {
+			_ = h.UserService.RevokeSessionToken(parsed.UserID, parsed.ID, time.Unix(parsed.ExpiresAt, 0))
+		}
+	}
+
+	h.Response.Success(c, nil, "登出成功")
 }
 
-// Logout 处理用户登出请求
-func (h *Handler) Logout(c *gin.Context) {
-	// 在实际实现中，可能需要将令牌加入黑名单
-	// 但现在我们简单地删除令牌并返回成功
+// requireAuthenticatedUser 返回已登录用户ID；访客请求返回 401
+func (h *Handler) requireAuthenticatedUser(c *gin.Context) (string, bool) {
+	userID, authenticated := GetUserFromContext(c)
+	if !authenticated {
+		h.Response.Error(c, http.StatusUnauthorized, ErrorUnauthorized, "需要登录")
+		return "", false
+	}
+	return userID, true
+}
 
-	userID, isAuthenticated := GetUserFromContext(c)
-	if isAuthenticated {
-		h.Logger.Info("User logged out", map[string]interface{}{
-			"user_id":   userID,
-			"client_ip": c.ClientIP(),
-		})
+// ChangePassword 修改当前用户密码，修改后其他会话全部失效
+func (h *Handler) ChangePassword(c *gin.Context) {
+	userID, ok := h.requireAuthenticatedUser(c)
+	if !ok {
+		return
 	}
 
-	h.Response.Success(c, nil, "登出成功")
+	var req struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "请求格式错误", err.Error())
+		return
+	}
+
+	if err := h.UserService.ChangePassword(userID, req.OldPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			h.Response.Error(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "原密码错误")
+		case errors.Is(err, auth.ErrWeakPassword):
+			h.Response.BadRequest(c, "新密码不符合要求", err.Error())
+		default:
+			h.Response.InternalError(c, "修改密码失败", err.Error())
+		}
+		return
+	}
+
+	user, err := h.UserService.GetUser(userID)
+	if err != nil {
+		h.Response.Success(c, nil, "密码已修改，请重新登录")
+		return
+	}
+	h.respondWithSession(c, user, "密码已修改")
+}
+
+// ListAPITokens 列出当前用户的个人 API 令牌
+func (h *Handler) ListAPITokens(c *gin.Context) {
+	userID, ok := h.requireAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.UserService.ListAPITokens(userID)
+	if err != nil {
+		h.Response.InternalError(c, "获取 API 令牌失败", err.Error())
+		return
+	}
+	h.Response.Success(c, gin.H{"tokens": tokens})
+}
+
+// CreateAPIToken 创建个人 API 令牌（明文只在本次响应中返回）
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	userID, ok := h.requireAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name          string `json:"name"`
+		ExpiresInDays int    `json:"expires_in_days,omitempty"` // 0 表示永不过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Response.BadRequest(c, "请求格式错误", err.Error())
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		h.Response.BadRequest(c, "expires_in_days 不能为负数")
+		return
+	}
+
+	plain, token, err := h.UserService.CreateAPIToken(userID, req.Name, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		h.Response.InternalError(c, "创建 API 令牌失败", err.Error())
+		return
+	}
+
+	h.Response.Success(c, gin.H{
+		"token":      plain,
+		"token_info": token,
+	}, "API 令牌已创建，请妥善保存（仅显示一次）")
+}
+
+// RevokeAPIToken 吊销个人 API 令牌
+func (h *Handler) RevokeAPIToken(c *gin.Context) {
+	userID, ok := h.requireAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.UserService.RevokeAPIToken(userID, c.Param("token_id")); err != nil {
+		if errors.Is(err, services.ErrAPITokenNotFound) {
+			h.Response.NotFound(c, "API 令牌")
+			return
+		}
+		h.Response.InternalError(c, "吊销 API 令牌失败", err.Error())
+		return
+	}
+	h.Response.Success(c, nil, "API 令牌已吊销")
 }
 
 // ========================================
@@ -6795,6 +8714,39 @@ func (h *Handler) GetUserPreferences(c *gin.Context) {
 	h.Response.Success(c, preferences, "用户偏好获取成功")
 }
 
+// GetUserUsage 返回用户某月（month=YYYY-MM，默认本月）的 LLM 用量、费用与配额余量
+func (h *Handler) GetUserUsage(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	usageService := h.getUsageService()
+	if usageService == nil {
+		h.Response.Error(c, http.StatusServiceUnavailable, ErrorInternalError, "用量服务不可用", "")
+		return
+	}
+
+	report, err := usageService.GetUserReport(userID, c.Query("month"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUsageMonth) {
+			h.Response.BadRequest(c, "无效的月份", err.Error())
+			return
+		}
+		h.Response.InternalError(c, "获取用量失败", err.Error())
+		return
+	}
+
+	h.Response.Success(c, report, "用量获取成功")
+}
+
+func (h *Handler) getUsageService() *services.UsageService {
+	container := di.GetContainer()
+	usageService, ok := container.Get("usage").(*services.UsageService)
+	if !ok {
+		utils.GetLogger().Warn("cannot get usage service from container", map[string]interface{}{})
+		return nil
+	}
+	return usageService
+}
+
 // UpdateUserPreferences 更新用户偏好
 func (h *Handler) UpdateUserPreferences(c *gin.Context) {
 	userID := c.Param("user_id")
@@ -2,11 +2,16 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Corphon/SceneIntruderMCP/internal/auth"
+	"github.com/Corphon/SceneIntruderMCP/internal/di"
+	"github.com/Corphon/SceneIntruderMCP/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -175,3 +180,72 @@ func DefaultRateLimit() gin.HandlerFunc {
 	// 300 requests per minute by IP to avoid normal browsing/list pages being throttled too aggressively
 	return RateLimitByIP(300, time.Minute)
 }
+
+// AuthRateLimit limits credential endpoints (login/register/refresh) to slow down password guessing
+func AuthRateLimit() gin.HandlerFunc {
+	// 20 attempts per minute per IP, counted separately from the default API budget
+	return RateLimitMiddleware(20, time.Minute, func(c *gin.Context) string {
+		return "auth:" + c.ClientIP()
+	})
+}
+
+// UsageQuota enforces the per-user daily/monthly LLM quotas and tags the request context so
+// that LLM usage is attributed to the caller (and to the scene for /scenes/:id routes).
+// Requests on a scene without a known caller are checked against the scene owner's quota.
+func UsageQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usageService, ok := di.GetContainer().Get("usage").(*services.UsageService)
+		if !ok || usageService == nil {
+			c.Next()
+			return
+		}
+
+		sceneID := ""
+		if strings.HasPrefix(c.FullPath(), "/api/scenes/:id") {
+			sceneID = c.Param("id")
+		}
+		userID := usageUserFromRequest(c, sceneID)
+		c.Request = c.Request.WithContext(services.WithLLMUsageScope(c.Request.Context(), userID, sceneID))
+
+		if err := usageService.CheckQuota(usageService.ResolveUser(userID, sceneID)); err != nil {
+			var quotaErr *services.UsageQuotaError
+			if errors.As(err, &quotaErr) {
+				retryAfter := int(time.Until(quotaErr.ResetAt).Seconds())
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
+				c.Header("X-Quota-Limit", quotaErr.Limit)
+				c.Header("X-Quota-Reset", fmt.Sprintf("%d", quotaErr.ResetAt.Unix()))
+			}
+			NewResponseHelper().Error(c, http.StatusTooManyRequests, ErrorQuotaExceeded, "Usage quota exceeded", err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// usageUserFromRequest returns the user set by AuthMiddleware, or the owner of a valid bearer
+// token on routes that skip it. Scene routes without credentials return "" so usage falls back
+// to the scene owner; other anonymous requests count as console_user.
+func usageUserFromRequest(c *gin.Context, sceneID string) string {
+	if userID, _ := GetUserFromContext(c); userID != "" {
+		return userID
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if token != "" {
+		if auth.IsAPIToken(token) {
+			if userID, err := validateAPIToken(token); err == nil {
+				return userID
+			}
+		} else if parsed, err := parseSessionToken(token); err == nil {
+			return parsed.UserID
+		}
+	}
+	if sceneID != "" {
+		return ""
+	}
+	return "console_user"
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/Corphon/SceneIntruderMCP/internal/config"
 	"github.com/Corphon/SceneIntruderMCP/internal/di"
+	"github.com/Corphon/SceneIntruderMCP/internal/mcp"
 	"github.com/Corphon/SceneIntruderMCP/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -117,6 +118,10 @@ func SetupRouter() (*gin.Engine, error) {
 	r.GET("/ws/scene/:id", handler.SceneWebSocket)
 	r.GET("/ws/user/status", handler.UserStatusWebSocket)
 
+	// MCP（Model Context Protocol）Streamable HTTP 端点；stdio 入口见 cmd/mcp
+	mcpHandler := mcp.NewHTTPHandler(mcp.NewSceneServer())
+	r.Any("/mcp", DefaultRateLimit(), AuthMiddleware(), serveMCP(mcpHandler))
+
 	// ===============================
 	// API路由组 - 添加默认速率限制
 	// ===============================
@@ -125,15 +130,21 @@ func SetupRouter() (*gin.Engine, error) {
 	{
 		// 聚合API端点
 		api.GET("/scenes/:id/aggregate", RequireAuthForScene(), handler.GetSceneAggregate)
-		api.POST("/interactions/aggregate", AuthMiddleware(), handler.ProcessInteractionAggregate)
+		api.POST("/interactions/aggregate", AuthMiddleware(), UsageQuota(), handler.ProcessInteractionAggregate)
 
 		// ===============================
 		// 登录相关路由
 		// ===============================
 		authGroup := api.Group("/auth")
 		{
-			authGroup.POST("/login", handler.Login)
+			authGroup.POST("/login", AuthRateLimit(), handler.Login)
+			authGroup.POST("/register", AuthRateLimit(), handler.Register)
+			authGroup.POST("/refresh", AuthRateLimit(), handler.RefreshToken)
 			authGroup.POST("/logout", handler.Logout) // Remove AuthMiddleware for logout since token is needed to verify
+			authGroup.POST("/password", AuthMiddleware(), handler.ChangePassword)
+			authGroup.GET("/tokens", AuthMiddleware(), handler.ListAPITokens)
+			authGroup.POST("/tokens", AuthMiddleware(), handler.CreateAPIToken)
+			authGroup.DELETE("/tokens/:token_id", AuthMiddleware(), handler.RevokeAPIToken)
 		}
 
 		// ===============================
@@ -154,6 +165,9 @@ func SetupRouter() (*gin.Engine, error) {
 			llmGroup.GET("/status", handler.GetLLMStatus)
 			llmGroup.GET("/models", handler.GetLLMModels)
 			llmGroup.PUT("/config", AuthMiddleware(), handler.UpdateLLMConfig) // Keep AuthMiddleware for UpdateLLMConfig to protect sensitive config changes
+			llmGroup.PUT("/fallbacks", AuthMiddleware(), RequireAdmin(), handler.UpdateLLMFallbacks)
+			llmGroup.PUT("/routes", AuthMiddleware(), RequireAdmin(), handler.UpdateLLMRoutes)
+			llmGroup.PUT("/prices", AuthMiddleware(), RequireAdmin(), handler.UpdateLLMPrices)
 		}
 
 		// ===============================
@@ -162,26 +176,43 @@ func SetupRouter() (*gin.Engine, error) {
 		scenesGroup := api.Group("/scenes")
 		{
 			scenesGroup.GET("", handler.GetScenes)
-			scenesGroup.POST("", AuthMiddleware(), handler.CreateScene)
+			scenesGroup.POST("", AuthMiddleware(), UsageQuota(), handler.CreateScene)
 			scenesGroup.POST("/shell", AuthMiddleware(), handler.CreateSceneShell)
+			scenesGroup.POST("/import", AuthMiddleware(), AnalysisRateLimit(), UsageQuota(), handler.ImportScene)
 			scenesGroup.GET("/:id", RequireAuthForScene(), handler.GetScene)
 			scenesGroup.DELETE("/:id", RequireAuthForScene(), handler.DeleteScene)
 			scenesGroup.GET("/:id/characters", RequireAuthForScene(), handler.GetCharacters)
+			scenesGroup.GET("/:id/characters/:character_id/memories", RequireAuthForScene(), handler.GetCharacterMemories)
+			scenesGroup.POST("/:id/characters/:character_id/memories", RequireAuthForScene(), handler.AddCharacterMemory)
+			scenesGroup.DELETE("/:id/characters/:character_id/memories/:memory_id", RequireAuthForScene(), handler.DeleteCharacterMemory)
 			scenesGroup.GET("/:id/conversations", RequireAuthForScene(), handler.GetConversations)
+
+			// 存档：命名快照，读档前自动备份当前进度
+			savesGroup := scenesGroup.Group("/:id/saves")
+			savesGroup.Use(RequireAuthForScene())
+			{
+				savesGroup.GET("", handler.ListSaveSlots)
+				savesGroup.POST("", handler.CreateSaveSlot)
+				savesGroup.GET("/:save_id", handler.GetSaveSlot)
+				savesGroup.PUT("/:save_id", handler.OverwriteSaveSlot)
+				savesGroup.DELETE("/:save_id", handler.DeleteSaveSlot)
+				savesGroup.POST("/:save_id/load", handler.LoadSaveSlot)
+				savesGroup.GET("/:save_id/diff", handler.DiffSaveSlot)
+			}
 			scenesGroup.GET("/:id/nodes/:node_id/content", RequireAuthForScene(), handler.GetStoryNodeContent)
 
 			// v2 comics（Phase2）：分镜/提示词/关键元素
 			comicGroup := scenesGroup.Group("/:id/comic")
-			comicGroup.Use(RequireAuthForScene())
+			comicGroup.Use(RequireAuthForScene(), JobOwner())
 			{
 				comicGroup.DELETE("", handler.DeleteComic)
-				comicGroup.POST("/analysis", handler.StartComicAnalysis)
+				comicGroup.POST("/analysis", UsageQuota(), handler.StartComicAnalysis)
 				comicGroup.GET("/analysis", handler.GetComicAnalysis)
 				comicGroup.PUT("/analysis", handler.UpdateComicAnalysis)
-				comicGroup.POST("/prompts", handler.StartComicPrompts)
+				comicGroup.POST("/prompts", UsageQuota(), handler.StartComicPrompts)
 				comicGroup.GET("/prompts", handler.GetComicPrompts)
 				comicGroup.PUT("/prompts/:frameID", handler.UpdateComicPrompt)
-				comicGroup.POST("/key_elements", handler.StartComicKeyElements)
+				comicGroup.POST("/key_elements", UsageQuota(), handler.StartComicKeyElements)
 				comicGroup.GET("/key_elements", handler.GetComicKeyElements)
 				comicGroup.PUT("/key_elements", handler.UpdateComicKeyElements)
 				// Phase3：参考图上传
@@ -189,10 +220,30 @@ func SetupRouter() (*gin.Engine, error) {
 				comicGroup.GET("/references", handler.GetComicReferences)
 				comicGroup.GET("/references/:elementID/image", handler.GetComicReferenceImage)
 				comicGroup.DELETE("/references/:elementID", handler.DeleteComicReference)
+				// 角色设定图：多视角参考，生成时按分镜自动匹配
+				comicGroup.GET("/character_sheets", handler.GetComicCharacterSheets)
+				comicGroup.PUT("/character_sheets/:elementID", handler.UpdateComicCharacterSheet)
+				comicGroup.DELETE("/character_sheets/:elementID", handler.DeleteComicCharacterSheet)
+				comicGroup.POST("/character_sheets/:elementID/views", handler.UploadComicCharacterSheetView)
+				comicGroup.POST("/character_sheets/:elementID/generate", handler.StartComicCharacterSheetGenerate)
+				comicGroup.GET("/character_sheets/:elementID/views/:view/image", handler.GetComicCharacterSheetImage)
 				// Phase3：生成与重绘
 				comicGroup.POST("/generate", handler.StartComicGenerate)
 				comicGroup.POST("/frames/generate", handler.StartComicGenerateFrames)
 				comicGroup.POST("/frames/:frameID/regenerate", handler.StartComicRegenerateFrame)
+				comicGroup.POST("/frames/:frameID/inpaint", handler.StartComicInpaintFrame)
+				comicGroup.POST("/frames/:frameID/undo", handler.UndoComicFrameEdit)
+				// 帧图片版本：列表/对比/固定/回收
+				comicGroup.GET("/frames/:frameID/versions", handler.GetComicFrameVersions)
+				comicGroup.GET("/frames/:frameID/versions/compare", handler.CompareComicFrameVersions)
+				comicGroup.GET("/frames/:frameID/versions/:version/image", handler.GetComicFrameVersionImage)
+				comicGroup.POST("/frames/:frameID/versions/:version/pin", handler.PinComicFrameVersion)
+				comicGroup.DELETE("/frames/:frameID/versions/:version/pin", handler.UnpinComicFrameVersion)
+				comicGroup.POST("/versions/gc", handler.GCComicFrameVersions)
+				// 种子策略与按记录参数复现
+				comicGroup.GET("/seed", handler.GetComicSeedSettings)
+				comicGroup.PUT("/seed", handler.UpdateComicSeedSettings)
+				comicGroup.POST("/frames/:frameID/reproduce", handler.StartComicReproduceFrame)
 				// Phase3：comics 概览
 				comicGroup.GET("", handler.GetComicOverview)
 				// v2.1.0：独立 Video 模块（首版）
@@ -208,6 +259,13 @@ func SetupRouter() (*gin.Engine, error) {
 				comicGroup.GET("/video/export", handler.ExportComicVideo)
 				// Phase4：图片直出（用于前端预览）
 				comicGroup.GET("/images/:frameID", handler.GetComicFrameImage)
+				// 页面排版：分格、旁白框与对白气泡
+				comicGroup.GET("/pages/templates", handler.GetComicPageTemplates)
+				comicGroup.POST("/pages", handler.ComposeComicPages)
+				comicGroup.GET("/pages", handler.GetComicPages)
+				comicGroup.PUT("/pages", handler.UpdateComicPages)
+				comicGroup.POST("/pages/render", handler.RerenderComicPages)
+				comicGroup.GET("/pages/:pageID/image", handler.GetComicPageImage)
 				// Phase3：导出（ZIP）
 				comicGroup.GET("/export", handler.ExportComic)
 			}
@@ -225,21 +283,21 @@ func SetupRouter() (*gin.Engine, error) {
 			storyGroup := scenesGroup.Group("/:id/story")
 			{
 				storyGroup.GET("", RequireAuthForScene(), handler.GetStoryData)
-				storyGroup.POST("/choice", RequireAuthForScene(), handler.MakeStoryChoice)
-				storyGroup.POST("/advance", RequireAuthForScene(), handler.AdvanceStory)
-				storyGroup.POST("/command", RequireAuthForScene(), handler.HandleSceneCommand)
-				storyGroup.POST("/nodes/:node_id/insert", RequireAuthForScene(), handler.InsertStoryNode)
+				storyGroup.POST("/choice", RequireAuthForScene(), UsageQuota(), handler.MakeStoryChoice)
+				storyGroup.POST("/advance", RequireAuthForScene(), UsageQuota(), handler.AdvanceStory)
+				storyGroup.POST("/command", RequireAuthForScene(), UsageQuota(), handler.HandleSceneCommand)
+				storyGroup.POST("/nodes/:node_id/insert", RequireAuthForScene(), UsageQuota(), handler.InsertStoryNode)
 				storyGroup.POST("/rewind", RequireAuthForScene(), handler.RewindStory)
 				storyGroup.GET("/branches", RequireAuthForScene(), handler.GetStoryBranches)
 				storyGroup.GET("/choices", RequireAuthForScene(), handler.GetAvailableStoryChoices)
-				storyGroup.POST("/batch", RequireAuthForScene(), handler.BatchStoryOperations)
+				storyGroup.POST("/batch", RequireAuthForScene(), UsageQuota(), handler.BatchStoryOperations)
 
 				// 任务目标完成
 				storyGroup.POST("/tasks/:task_id/objectives/:objective_id/complete", RequireAuthForScene(), handler.CompleteTaskObjective)
 
 				// 地点管理
 				storyGroup.POST("/locations/:location_id/unlock", RequireAuthForScene(), handler.UnlockStoryLocation)
-				storyGroup.POST("/locations/:location_id/explore", RequireAuthForScene(), handler.ExploreStoryLocation)
+				storyGroup.POST("/locations/:location_id/explore", RequireAuthForScene(), UsageQuota(), handler.ExploreStoryLocation)
 			}
 
 			// 导出相关路由 - 保持默认 rate limit
@@ -268,8 +326,8 @@ func SetupRouter() (*gin.Engine, error) {
 			scriptsGroup.PUT("/:id/items", handler.PutScriptItems)
 			scriptsGroup.PUT("/:id/chapter_draft", handler.PutScriptChapterDraft)
 			scriptsGroup.PUT("/:id/draft", handler.PutScriptDraft)
-			scriptsGroup.POST("/:id/generate", handler.ScriptGenerate)
-			scriptsGroup.POST("/:id/command", handler.ScriptCommand)
+			scriptsGroup.POST("/:id/generate", UsageQuota(), handler.ScriptGenerate)
+			scriptsGroup.POST("/:id/command", UsageQuota(), handler.ScriptCommand)
 			scriptsGroup.POST("/:id/rewind", handler.ScriptRewind)
 			scriptsGroup.GET("/:id/export", handler.ScriptExport)
 		}
@@ -280,8 +338,9 @@ func SetupRouter() (*gin.Engine, error) {
 		chatGroup := api.Group("/chat")
 		chatGroup.Use(ChatRateLimit()) // Apply stricter rate limiting for chat endpoints
 		{
-			chatGroup.POST("", AuthMiddleware(), handler.Chat)
-			chatGroup.POST("/emotion", AuthMiddleware(), handler.ChatWithEmotion)
+			chatGroup.POST("", AuthMiddleware(), UsageQuota(), handler.Chat)
+			chatGroup.POST("/emotion", AuthMiddleware(), UsageQuota(), handler.ChatWithEmotion)
+			chatGroup.POST("/emotion/stream", AuthMiddleware(), UsageQuota(), handler.ChatWithEmotionStream) // SSE 增量输出
 		}
 
 		// ===============================
@@ -290,8 +349,8 @@ func SetupRouter() (*gin.Engine, error) {
 		interactions := api.Group("/interactions")
 		interactions.Use(ChatRateLimit()) // Apply chat rate limiting
 		{
-			interactions.POST("/trigger", AuthMiddleware(), handler.TriggerCharacterInteraction)
-			interactions.POST("/simulate", AuthMiddleware(), handler.SimulateCharactersConversation)
+			interactions.POST("/trigger", AuthMiddleware(), UsageQuota(), handler.TriggerCharacterInteraction)
+			interactions.POST("/simulate", AuthMiddleware(), UsageQuota(), handler.SimulateCharactersConversation)
 			interactions.GET("/:scene_id", RequireAuthForScene(), handler.GetCharacterInteractions)
 			interactions.GET("/:scene_id/:character1_id/:character2_id", RequireAuthForScene(), handler.GetCharacterToCharacterInteractions)
 		}
@@ -305,18 +364,48 @@ func SetupRouter() (*gin.Engine, error) {
 			configGroup.GET("/metrics", AuthMiddleware(), handler.GetConfigMetrics)
 		}
 
+		// ===============================
+		// 用量配额（用量报告见 /users/:user_id/usage）
+		// ===============================
+		api.PUT("/usage/quotas", AuthMiddleware(), RequireAdmin(), handler.UpdateUsageQuotas)
+
 		// ===============================
 		// 文件上传 - use analysis rate limit as it's resource-intensive
 		// ===============================
-		api.POST("/upload", AuthMiddleware(), AnalysisRateLimit(), handler.UploadFile)
+		api.POST("/upload", AuthMiddleware(), AnalysisRateLimit(), UsageQuota(), handler.UploadFile)
 
 		// ===============================
 		// 分析和进度相关 - stricter rate limiting as these are resource-intensive
 		// ===============================
-		api.POST("/analyze", AuthMiddleware(), AnalysisRateLimit(), handler.AnalyzeTextWithProgress)
+		api.POST("/analyze", AuthMiddleware(), AnalysisRateLimit(), UsageQuota(), handler.AnalyzeTextWithProgress)
 		api.GET("/progress/:taskID", handler.SubscribeProgress) // No rate limiting for progress since it's SSE
 		api.POST("/cancel/:taskID", AuthMiddleware(), handler.CancelAnalysisTask)
 
+		// ===============================
+		// 任务队列管理（列出/暂停/恢复/取消异步任务）
+		// ===============================
+		jobsGroup := api.Group("/jobs")
+		jobsGroup.Use(AuthMiddleware())
+		{
+			jobsGroup.GET("", handler.ListJobs)
+			jobsGroup.GET("/:id", handler.GetJob)
+			jobsGroup.POST("/:id/pause", handler.PauseJob)
+			jobsGroup.POST("/:id/resume", handler.ResumeJob)
+			jobsGroup.POST("/:id/cancel", handler.CancelJob)
+		}
+
+		// ===============================
+		// 批量漫画任务（多场景 analysis → prompts → key_elements → generate）
+		// ===============================
+		campaignsGroup := api.Group("/comic/campaigns")
+		campaignsGroup.Use(AuthMiddleware())
+		{
+			campaignsGroup.GET("", handler.ListComicCampaigns)
+			campaignsGroup.POST("", UsageQuota(), handler.StartComicCampaign)
+			campaignsGroup.GET("/:id", handler.GetComicCampaign)
+			campaignsGroup.POST("/:id/cancel", handler.CancelComicCampaign)
+		}
+
 		// ===============================
 		// 用户管理路由
 		// ===============================
@@ -328,6 +417,7 @@ func SetupRouter() (*gin.Engine, error) {
 			usersGroup.PUT("", handler.UpdateUserProfile)
 			usersGroup.GET("/preferences", handler.GetUserPreferences)
 			usersGroup.PUT("/preferences", handler.UpdateUserPreferences)
+			usersGroup.GET("/usage", handler.GetUserUsage)
 
 			// 道具管理
 			itemsGroup := usersGroup.Group("/items")
@@ -361,6 +451,16 @@ func SetupRouter() (*gin.Engine, error) {
 	return r, nil
 }
 
+// serveMCP 将认证得到的用户ID传入 MCP 工具上下文
+func serveMCP(h *mcp.HTTPHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := GetUserFromContext(c)
+		ctx := services.WithLLMUsageScope(c.Request.Context(), userID, "")
+		c.Request = c.Request.WithContext(mcp.WithUserID(ctx, userID))
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
 func registerNoRoute(r *gin.Engine, spaHandler gin.HandlerFunc, rh *ResponseHelper) {
 	r.NoRoute(func(c *gin.Context) {
 		path := c.Request.URL.Path
@@ -395,7 +495,7 @@ func corsMiddleware() gin.HandlerFunc {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key, Mcp-Session-Id")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 
@@ -38,13 +38,14 @@ var upgrader = websocket.Upgrader{
 
 // WebSocketClient 表示一个 WebSocket 客户端连接
 type WebSocketClient struct {
-	conn      WebSocketConnection
-	sceneID   string
-	userID    string
-	send      chan []byte
-	closed    int32     // 原子操作标志，0=开启，1=关闭
-	lastPing  time.Time // 最后一次ping时间
-	createdAt time.Time // 创建时间
+	conn        WebSocketConnection
+	sceneID     string
+	userID      string
+	usageUserID string // 用量归属用户：连接时认证的用户，未认证时为空（按场景所有者计）
+	send        chan []byte
+	closed      int32     // 原子操作标志，0=开启，1=关闭
+	lastPing    time.Time // 最后一次ping时间
+	createdAt   time.Time // 创建时间
 }
 
 // WebSocketManager 管理所有 WebSocket 连接
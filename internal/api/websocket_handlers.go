@@ -2,7 +2,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sync/atomic"
@@ -56,13 +58,14 @@ func (wh *WebSocketHandler) SceneWebSocket(c *gin.Context) {
 
 	// 创建客户端
 	client := &WebSocketClient{
-		conn:      &WebSocketConnWrapper{conn},
-		sceneID:   sceneID,
-		userID:    userID,
-		send:      make(chan []byte, 256),
-		closed:    0,
-		lastPing:  time.Now(),
-		createdAt: time.Now(),
+		conn:        &WebSocketConnWrapper{conn},
+		sceneID:     sceneID,
+		userID:      userID,
+		usageUserID: usageUserFromRequest(c, sceneID), // user_id 查询参数不可信，用量按认证信息归属
+		send:        make(chan []byte, 256),
+		closed:      0,
+		lastPing:    time.Now(),
+		createdAt:   time.Now(),
 	}
 
 	// 注册客户端
@@ -338,6 +341,17 @@ func (wh *WebSocketHandler) handleCharacterInteraction(client *WebSocketClient,
 		return
 	}
 
+	if err := wh.checkUsageQuota(client); err != nil {
+		wh.sendError(client, "生成回应失败: "+err.Error())
+		return
+	}
+
+	// 流式模式：增量文本以 conversation:delta 推送，结束后广播完整对话
+	if stream, _ := message["stream"].(bool); stream {
+		go wh.streamCharacterInteraction(client, characterID, userMessage)
+		return
+	}
+
 	// 生成角色回应
 	response, err := wh.characterService.GenerateResponse(client.sceneID, characterID, userMessage)
 	if err != nil {
@@ -358,6 +372,49 @@ func (wh *WebSocketHandler) handleCharacterInteraction(client *WebSocketClient,
 	wsManager.BroadcastToScene(client.sceneID, conversationMsg)
 }
 
+// streamCharacterInteraction 流式生成角色回应并推送增量文本
+func (wh *WebSocketHandler) streamCharacterInteraction(client *WebSocketClient, characterID, userMessage string) {
+	ctx, cancel := context.WithTimeout(services.WithLLMUsageScope(context.Background(), client.usageUserID, client.sceneID), 90*time.Second)
+	defer cancel()
+
+	streamID := fmt.Sprintf("stream_%d", time.Now().UnixNano())
+	client.SendMessage(map[string]interface{}{
+		"type":         "conversation:stream_start",
+		"scene_id":     client.sceneID,
+		"character_id": characterID,
+		"stream_id":    streamID,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	})
+
+	response, err := wh.characterService.StreamResponseWithEmotion(ctx, client.sceneID, characterID, userMessage, func(delta string) {
+		if client.IsClosed() {
+			cancel()
+			return
+		}
+		wsManager.BroadcastToScene(client.sceneID, map[string]interface{}{
+			"type":         "conversation:delta",
+			"scene_id":     client.sceneID,
+			"character_id": characterID,
+			"stream_id":    streamID,
+			"delta":        delta,
+		})
+	})
+	if err != nil {
+		wh.sendError(client, "生成回应失败: "+err.Error())
+		return
+	}
+
+	wsManager.BroadcastToScene(client.sceneID, map[string]interface{}{
+		"type":         "conversation:new",
+		"scene_id":     client.sceneID,
+		"character_id": characterID,
+		"speaker_id":   characterID,
+		"stream_id":    streamID,
+		"conversation": response,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	})
+}
+
 // handleStoryChoice 处理故事选择消息
 func (wh *WebSocketHandler) handleStoryChoice(client *WebSocketClient, message map[string]interface{}) {
 	nodeID, ok := message["node_id"].(string)
@@ -398,6 +455,11 @@ func (wh *WebSocketHandler) handleStoryChoice(client *WebSocketClient, message m
 		preferences = &models.UserPreferences{}
 	}
 
+	if err := wh.checkUsageQuota(client); err != nil {
+		wh.sendError(client, "执行故事选择失败: "+err.Error())
+		return
+	}
+
 	// 执行故事选择
 	nextNode, err := wh.storyService.MakeChoice(client.sceneID, nodeID, choiceID, preferences)
 	if err != nil {
@@ -499,6 +561,15 @@ func (wh *WebSocketHandler) startHeartbeat(c *gin.Context, client *WebSocketClie
 	}
 }
 
+// checkUsageQuota 检查连接归属用户的 LLM 用量配额；会调用 LLM 的消息在处理前检查
+func (wh *WebSocketHandler) checkUsageQuota(client *WebSocketClient) error {
+	usageService, ok := di.GetContainer().Get("usage").(*services.UsageService)
+	if !ok || usageService == nil {
+		return nil
+	}
+	return usageService.CheckQuota(usageService.ResolveUser(client.usageUserID, client.sceneID))
+}
+
 // sendError 发送错误消息
 func (wh *WebSocketHandler) sendError(client *WebSocketClient, errorMsg string) {
 	errorResponse := map[string]interface{}{
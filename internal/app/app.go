@@ -27,9 +27,11 @@ import (
 	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/grok"
 	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/mistral"
 	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/nvidia"
+	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/ollama"
 	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/openai"
 	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/openrouter"
 	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/qwen"
+	_ "github.com/Corphon/SceneIntruderMCP/internal/llm/providers/replay"
 )
 
 // App 代表应用程序实例
@@ -61,6 +63,16 @@ func GetApp() *App {
 // 初始化服务
 func InitServices() error {
 	container := di.GetContainer()
+	cfg := config.GetCurrentConfig()
+
+	// 0. 存储后端：必须在创建任何持久化服务之前确定
+	if err := storage.Configure(storage.Options{
+		Backend:    cfg.StorageBackend,
+		DataDir:    cfg.DataDir,
+		SQLitePath: cfg.StorageSQLitePath,
+	}); err != nil {
+		return fmt.Errorf("配置存储后端失败: %w", err)
+	}
 
 	// 1. 基础服务（无依赖）
 	llmService, err := services.NewLLMService()
@@ -74,12 +86,14 @@ func InitServices() error {
 	container.Register("progress", progressService)
 	progressService.StartAutoCleanup()
 
-	// Phase1: 最小 JobQueue（异步任务执行与取消的统一底座）
+	// JobQueue：异步任务执行、优先级、重试与取消的统一底座
 	jobQueue := services.NewJobQueue(runtime.NumCPU(), 256)
+	jobQueue.Progress = progressService
 	container.Register("job_queue", jobQueue)
 
 	statsService := services.NewStatsService()
 	container.Register("stats", statsService)
+	llmService.Stats = statsService
 
 	configService := services.NewConfigService()
 	container.Register("config", configService)
@@ -87,7 +101,10 @@ func InitServices() error {
 	userService := services.NewUserService()
 	container.Register("user", userService)
 
-	cfg := config.GetCurrentConfig()
+	// 任务日志：未完成的持久化任务在所有服务注册完任务类型后恢复
+	if err := jobQueue.EnableJournal(cfg.DataDir + "/jobs"); err != nil {
+		utils.GetLogger().Warn("failed to enable job journal; jobs will not survive restarts", map[string]interface{}{"err": err.Error()})
+	}
 
 	itemService := services.NewItemService(cfg.DataDir + "/scenes")
 	container.Register("item", itemService)
@@ -97,7 +114,24 @@ func InitServices() error {
 	sceneService.ItemService = itemService
 	container.Register("scene", sceneService)
 
+	// 用量记账：按用户/场景/任务累计 token 与费用；没有用户上下文的调用按场景所有者归属
+	usageService := services.NewUsageService(cfg.DataDir + "/usage")
+	usageService.SceneOwner = func(sceneID string) string {
+		sceneData, err := sceneService.LoadScene(sceneID)
+		if err != nil || sceneData == nil {
+			return ""
+		}
+		return sceneData.Scene.UserID
+	}
+	container.Register("usage", usageService)
+	llmService.Usage = usageService
+
+	// 角色长期记忆：对话后提炼要点，生成回复前按相关度检索
+	memoryService := services.NewMemoryService(cfg.DataDir+"/scenes", llmService)
+	container.Register("memory", memoryService)
+
 	contextService := services.NewContextService(sceneService)
+	contextService.MemoryService = memoryService
 	container.Register("context", contextService)
 
 	// 3. 依赖多个服务的服务
@@ -112,7 +146,7 @@ func InitServices() error {
 	storyService.ItemService = itemService
 	storyService.CharacterService = characterService
 	storyService.BasePath = cfg.DataDir + "/stories"
-	if fs, err := storage.NewFileStorage(storyService.BasePath); err == nil {
+	if fs, err := storage.Open(storyService.BasePath); err == nil {
 		storyService.FileStorage = fs
 	}
 	container.Register("story", storyService)
@@ -163,6 +197,7 @@ func InitServices() error {
 		sceneService,
 		storyService,
 	)
+	comicService.FontPath = cfg.ComicFontPath
 	container.Register("comic", comicService)
 
 	videoService := services.NewVideoService(videoRepo, comicRepo, jobQueue, progressService, nil)
@@ -171,6 +206,10 @@ func InitServices() error {
 	}
 	container.Register("video", videoService)
 
+	// 存档：故事进度、对话、物品与角色状态的命名快照
+	saveService := services.NewSaveService(sceneService, storyService, itemService, contextService, characterService, memoryService)
+	container.Register("save", saveService)
+
 	sceneAggregateService := services.NewSceneAggregateService(
 		sceneService, characterService, contextService, storyService, progressService)
 	container.Register("scene_aggregate", sceneAggregateService)
@@ -185,6 +224,10 @@ func InitServices() error {
 	)
 	container.Register("interaction_aggregate", interactionAggregateService)
 
+	jobQueue.RestorePending()
+	// 批量漫画任务在任务队列恢复之后再恢复，以便继续等待已恢复的步骤任务
+	comicService.RestoreComicCampaigns()
+
 	return nil
 }
 
@@ -230,6 +273,13 @@ func ReinitializeLLMService() error {
 		llmService = services.NewEmptyLLMService()
 	}
 
+	if statsSvc, ok := container.Get("stats").(*services.StatsService); ok {
+		llmService.Stats = statsSvc
+	}
+	if usageSvc, ok := container.Get("usage").(*services.UsageService); ok {
+		llmService.Usage = usageSvc
+	}
+
 	// 更新容器中的LLM服务
 	container.Register("llm", llmService)
 
@@ -256,12 +306,20 @@ func ReinitializeLLMService() error {
 		storyService.BasePath = cfg.DataDir + "/stories"
 	}
 	if storyService.BasePath != "" {
-		if fs, err := storage.NewFileStorage(storyService.BasePath); err == nil {
+		if fs, err := storage.Open(storyService.BasePath); err == nil {
 			storyService.FileStorage = fs
 		}
 	}
 	container.Register("story", storyService)
 
+	// 持有旧实例引用的服务同步切换
+	if memSvc, ok := container.Get("memory").(*services.MemoryService); ok {
+		memSvc.LLMService = llmService
+	}
+	if saveSvc, ok := container.Get("save").(*services.SaveService); ok {
+		saveSvc.StoryService = storyService
+	}
+
 	return nil
 }
 
@@ -357,6 +415,18 @@ func (a *App) cleanup() {
 		utils.GetLogger().Info("JobQueue 已停止", nil)
 	}
 
+	// 写出未保存的用量账本
+	if usageService, ok := container.Get("usage").(*services.UsageService); ok && usageService != nil {
+		if err := usageService.Close(); err != nil {
+			utils.GetLogger().Warn("保存用量账本失败", map[string]interface{}{"err": err.Error()})
+		}
+	}
+
+	// 停止记忆写回协程并写回角色记忆的访问时间
+	if memoryService, ok := container.Get("memory").(*services.MemoryService); ok && memoryService != nil {
+		memoryService.Close()
+	}
+
 	// 清理LLM服务缓存
 	if llmService, ok := container.Get("llm").(*services.LLMService); ok && llmService != nil {
 		// 如果需要，可以调用LLM服务的清理方法
@@ -377,8 +447,10 @@ func (a *App) cleanup() {
 		utils.GetLogger().Info("旧任务数据已清理", nil)
 	}
 
-	// 关闭可能的数据库连接
-	// db.Close() // 如果将来添加数据库
+	// 关闭 SQLite 存储连接（文件后端时为空操作）
+	if err := storage.CloseSQLiteStorages(); err != nil {
+		utils.GetLogger().Warn("关闭SQLite存储失败", map[string]interface{}{"err": err.Error()})
+	}
 
 	utils.GetLogger().Info("资源清理完成", nil)
 }
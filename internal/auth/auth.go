@@ -6,40 +6,82 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
 )
 
+// Token kinds. Access tokens authorize API calls; refresh tokens can only be
+// exchanged for a new token pair.
+const (
+	TokenKindAccess  = "access"
+	TokenKindRefresh = "refresh"
+)
+
 // TokenConfig holds the configuration for token generation
 type TokenConfig struct {
-	Secret     []byte
-	Expiration time.Duration
+	Secret            []byte
+	Expiration        time.Duration
+	RefreshExpiration time.Duration
 }
 
 // Token represents an authentication token
 type Token struct {
+	ID        string            `json:"id,omitempty"`
+	Kind      string            `json:"kind"`
 	UserID    string            `json:"user_id"`
 	ExpiresAt int64             `json:"expires_at"`
 	IssuedAt  int64             `json:"issued_at"`
 	Claims    map[string]string `json:"claims,omitempty"`
+
+	// IssuedAtNano is the issue time in nanoseconds; zero for tokens issued before it was signed.
+	IssuedAtNano int64 `json:"issued_at_nano,omitempty"`
 }
 
-// GenerateToken creates a new authentication token
+// IssuedTime returns the issue time at the best precision the token carries.
+func (t *Token) IssuedTime() time.Time {
+	if t.IssuedAtNano != 0 {
+		return time.Unix(0, t.IssuedAtNano)
+	}
+	return time.Unix(t.IssuedAt, 0)
+}
+
+// GenerateToken creates a new access token
 func GenerateToken(userID string, config *TokenConfig) (string, error) {
+	tokenString, _, err := IssueToken(userID, TokenKindAccess, config.Expiration, config)
+	return tokenString, err
+}
+
+// IssueToken creates a signed token of the given kind with a random ID that
+// can be revoked server-side.
+func IssueToken(userID string, kind string, ttl time.Duration, config *TokenConfig) (string, *Token, error) {
 	if len(config.Secret) == 0 {
-		return "", fmt.Errorf("secret key is required")
+		return "", nil, fmt.Errorf("secret key is required")
+	}
+	if strings.Contains(userID, "|") {
+		return "", nil, fmt.Errorf("invalid user id")
 	}
 
+	idBytes, err := GenerateSecureKey(12)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
 	token := &Token{
+		ID:        hex.EncodeToString(idBytes),
+		Kind:      kind,
 		UserID:    userID,
-		ExpiresAt: time.Now().Add(config.Expiration).Unix(),
-		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		IssuedAt:  now.Unix(),
 		Claims:    make(map[string]string),
+
+		IssuedAtNano: now.UnixNano(),
 	}
 
 	// Create the token payload
-	payload := fmt.Sprintf("%s|%d|%d", token.UserID, token.ExpiresAt, token.IssuedAt)
+	payload := fmt.Sprintf("%s|%d|%d|%s|%s|%d", token.UserID, token.ExpiresAt, token.IssuedAt, token.ID, token.Kind, token.IssuedAtNano)
 
 	// Create HMAC signature
 	h := hmac.New(sha256.New, config.Secret)
@@ -53,7 +95,7 @@ func GenerateToken(userID string, config *TokenConfig) (string, error) {
 	// Combine payload and signature
 	tokenString := fmt.Sprintf("%s.%s", encodedPayload, encodedSignature)
 
-	return tokenString, nil
+	return tokenString, token, nil
 }
 
 // ParseToken parses and validates a token
@@ -93,7 +135,8 @@ func ParseToken(tokenString string, config *TokenConfig) (*Token, error) {
 	// Parse payload
 	payload := string(payloadBytes)
 	payloadParts := strings.Split(payload, "|")
-	if len(payloadParts) != 3 {
+	// 3 parts: tokens issued before token IDs existed (always access tokens)
+	if len(payloadParts) != 3 && len(payloadParts) != 6 {
 		return nil, fmt.Errorf("invalid payload format")
 	}
 
@@ -106,11 +149,19 @@ func ParseToken(tokenString string, config *TokenConfig) (*Token, error) {
 		return nil, fmt.Errorf("token has expired")
 	}
 
-	return &Token{
+	token := &Token{
+		Kind:      TokenKindAccess,
 		UserID:    userID,
 		ExpiresAt: expiresAt,
 		IssuedAt:  issuedAt,
-	}, nil
+	}
+	if len(payloadParts) == 6 {
+		token.ID = payloadParts[3]
+		token.Kind = payloadParts[4]
+		token.IssuedAtNano = parseTimestamp(payloadParts[5])
+	}
+
+	return token, nil
 }
 
 // parseTimestamp converts string timestamp to int64
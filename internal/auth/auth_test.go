@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIssueAndParseToken(t *testing.T) {
+	cfg := &TokenConfig{Secret: []byte("0123456789abcdef0123456789abcdef"), Expiration: time.Hour}
+
+	tokenString, issued, err := IssueToken("user_1", TokenKindRefresh, time.Hour, cfg)
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+	parsed, err := ParseToken(tokenString, cfg)
+	if err != nil {
+		t.Fatalf("ParseToken error: %v", err)
+	}
+	if parsed.UserID != "user_1" || parsed.Kind != TokenKindRefresh || parsed.ID == "" || parsed.ID != issued.ID {
+		t.Fatalf("unexpected token: %+v", parsed)
+	}
+	if !parsed.IssuedTime().Equal(issued.IssuedTime()) || parsed.IssuedAtNano == 0 {
+		t.Fatalf("issue time lost precision: %v vs %v", parsed.IssuedTime(), issued.IssuedTime())
+	}
+
+	if _, err := ParseToken(tokenString, &TokenConfig{Secret: []byte("another-secret-another-secret-xx")}); err == nil {
+		t.Fatalf("expected signature mismatch")
+	}
+
+	expired, _, err := IssueToken("user_1", TokenKindAccess, -time.Minute, cfg)
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+	if _, err := ParseToken(expired, cfg); err == nil {
+		t.Fatalf("expected expired token to be rejected")
+	}
+}
+
+func TestParseLegacyToken(t *testing.T) {
+	cfg := &TokenConfig{Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+	payload := fmt.Sprintf("admin|%d|%d", time.Now().Add(time.Hour).Unix(), time.Now().Unix())
+	mac := hmac.New(sha256.New, cfg.Secret)
+	mac.Write([]byte(payload))
+	legacy := base64.URLEncoding.EncodeToString([]byte(payload)) + "." + base64.URLEncoding.EncodeToString(mac.Sum(nil))
+
+	parsed, err := ParseToken(legacy, cfg)
+	if err != nil {
+		t.Fatalf("ParseToken error: %v", err)
+	}
+	if parsed.UserID != "admin" || parsed.Kind != TokenKindAccess || parsed.ID != "" || parsed.IssuedAtNano != 0 {
+		t.Fatalf("unexpected legacy token: %+v", parsed)
+	}
+}
+
+func TestPasswordAndAPITokenHelpers(t *testing.T) {
+	if _, err := HashPassword("short"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("expected ErrWeakPassword, got %v", err)
+	}
+
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	if !CheckPassword(hash, "correct horse") || CheckPassword(hash, "wrong horse") {
+		t.Fatalf("password check mismatch")
+	}
+
+	plain, stored, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken error: %v", err)
+	}
+	if !IsAPIToken(plain) || HashAPIToken(plain) != stored {
+		t.Fatalf("api token helpers disagree: %q %q", plain, stored)
+	}
+}
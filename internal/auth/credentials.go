@@ -0,0 +1,71 @@
+// internal/auth/credentials.go
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// APITokenPrefix marks long-lived personal API tokens so they can be told
+// apart from signed session tokens without parsing.
+const APITokenPrefix = "sit_"
+
+// MinPasswordLength is the minimum accepted password length
+const MinPasswordLength = 8
+
+// ErrWeakPassword is returned when a password does not meet the length rules
+var ErrWeakPassword = errors.New("password does not meet requirements")
+
+// HashPassword hashes a password with bcrypt
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, MinPasswordLength)
+	}
+	// bcrypt ignores input past 72 bytes; reject it instead of silently truncating
+	if len(password) > 72 {
+		return "", fmt.Errorf("%w: must be at most 72 bytes", ErrWeakPassword)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the bcrypt hash
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// GenerateAPIToken creates a random personal API token and returns the
+// plaintext (shown to the user once) together with the hash to store.
+func GenerateAPIToken() (plain string, hash string, err error) {
+	key, err := GenerateSecureKey(32)
+	if err != nil {
+		return "", "", err
+	}
+	plain = APITokenPrefix + base64.RawURLEncoding.EncodeToString(key)
+	return plain, HashAPIToken(plain), nil
+}
+
+// HashAPIToken returns the storage hash for a personal API token. Tokens carry
+// 256 bits of entropy, so a fast hash is sufficient.
+func HashAPIToken(plain string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(plain)))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken reports whether the bearer value looks like a personal API token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
@@ -0,0 +1,378 @@
+// internal/cassette/cassette.go
+
+// Package cassette stores recorded provider request/response pairs on disk so that
+// LLM and vision calls can be replayed offline in tests and demos.
+package cassette
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode controls how a replay provider uses its cassette.
+type Mode string
+
+const (
+	// ModeReplay serves recorded responses. A miss is forwarded to the upstream provider and
+	// recorded when one is configured, otherwise it fails.
+	ModeReplay Mode = "replay"
+	// ModeRecord always calls the upstream provider and records the result, replacing older
+	// recordings of the same request.
+	ModeRecord Mode = "record"
+	// ModeStrict serves recorded responses only; a miss is an error.
+	ModeStrict Mode = "strict"
+)
+
+const fileVersion = 1
+
+var (
+	// ErrNoMatch is returned when no recorded interaction matches a request.
+	ErrNoMatch = errors.New("no recorded interaction matches the request")
+	// ErrInvalidMode is returned by ParseMode for an unknown mode.
+	ErrInvalidMode = errors.New("invalid cassette mode")
+	// ErrInvalidPath is returned for a configured cassette path outside the cassette directory.
+	ErrInvalidPath = errors.New("invalid cassette path")
+)
+
+// CheckName validates a configured cassette path: it must be relative and must not contain "..".
+func CheckName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%w: empty path", ErrInvalidPath)
+	}
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return fmt.Errorf("%w: %q must be relative", ErrInvalidPath, name)
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return fmt.Errorf("%w: %q must not contain \"..\"", ErrInvalidPath, name)
+		}
+	}
+	return nil
+}
+
+// ResolvePath turns a configured cassette path into a file path under dir (normally
+// $DATA_DIR/cassettes). The name is taken relative to dir; a name that already starts with
+// dir, such as "data/cassettes/llm.json", is used as is.
+func ResolvePath(dir, name string) (string, error) {
+	if err := CheckName(name); err != nil {
+		return "", err
+	}
+	dir = filepath.Clean(dir)
+	name = filepath.Clean(strings.TrimSpace(name))
+
+	path := filepath.Join(dir, name)
+	if rel, err := filepath.Rel(dir, name); err == nil && !filepath.IsAbs(dir) && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		path = name
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q is outside %s", ErrInvalidPath, name, dir)
+	}
+	return path, nil
+}
+
+// ParseMode parses a mode name; an empty string means ModeReplay.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case "", ModeReplay:
+		return ModeReplay, nil
+	case ModeRecord:
+		return ModeRecord, nil
+	case ModeStrict:
+		return ModeStrict, nil
+	}
+	return "", fmt.Errorf("%w: %q (replay / record / strict)", ErrInvalidMode, s)
+}
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Key        string          `json:"key"`
+	Request    json.RawMessage `json:"request"`
+	Response   json.RawMessage `json:"response"`
+	RecordedAt time.Time       `json:"recorded_at"`
+}
+
+type cassetteFile struct {
+	Version      int            `json:"version"`
+	Kind         string         `json:"kind"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Cassette is a file of recorded interactions. Interactions with the same key are served in
+// recorded order; once they are used up the last one keeps being served.
+type Cassette struct {
+	path string
+	kind string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	cursor       map[string]int
+	rerecorded   map[string]bool
+}
+
+// Open loads the cassette at path, or starts an empty one if the file does not exist.
+// kind ("llm", "vision") guards against pointing a provider at the wrong cassette.
+func Open(path string, kind string) (*Cassette, error) {
+	c := &Cassette{
+		path:       path,
+		kind:       kind,
+		cursor:     make(map[string]int),
+		rerecorded: make(map[string]bool),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cassette %s: %w", path, err)
+	}
+
+	var f cassetteFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	// Recording rewrites the whole file, so never adopt a JSON file that is not a cassette.
+	if f.Version == 0 || f.Kind == "" {
+		return nil, fmt.Errorf("%s is not a cassette file (missing version or kind)", path)
+	}
+	if f.Kind != kind {
+		return nil, fmt.Errorf("cassette %s holds %s interactions, not %s", path, f.Kind, kind)
+	}
+	c.interactions = f.Interactions
+	return c, nil
+}
+
+// Path returns the cassette file path.
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+// Exists reports whether the cassette file is present on disk.
+func (c *Cassette) Exists() bool {
+	_, err := os.Stat(c.path)
+	return err == nil
+}
+
+// Len returns the number of recorded interactions.
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.interactions)
+}
+
+// Interactions returns a copy of the recorded interactions.
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Interaction, 0, len(c.interactions))
+	for _, it := range c.interactions {
+		out = append(out, *it)
+	}
+	return out
+}
+
+// Lookup returns the next recorded response for key.
+func (c *Cassette) Lookup(key string) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*Interaction
+	for _, it := range c.interactions {
+		if it.Key == key {
+			matches = append(matches, it)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	i := c.cursor[key]
+	if i >= len(matches) {
+		i = len(matches) - 1
+	}
+	c.cursor[key] = i + 1
+	return matches[i].Response, true
+}
+
+// Record appends an interaction and saves the cassette. The first recording of a key in
+// this session drops older recordings of the same key, so re-recording a run replaces it.
+func (c *Cassette) Record(key string, request, response interface{}) error {
+	reqData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encode cassette request: %w", err)
+	}
+	respData, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("encode cassette response: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.rerecorded[key] {
+		kept := c.interactions[:0]
+		for _, it := range c.interactions {
+			if it.Key != key {
+				kept = append(kept, it)
+			}
+		}
+		c.interactions = kept
+		c.rerecorded[key] = true
+	}
+	c.interactions = append(c.interactions, &Interaction{
+		Key:        key,
+		Request:    reqData,
+		Response:   respData,
+		RecordedAt: time.Now().UTC(),
+	})
+	return c.saveLocked()
+}
+
+func (c *Cassette) saveLocked() error {
+	data, err := json.MarshalIndent(cassetteFile{Version: fileVersion, Kind: c.kind, Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("create cassette dir: %w", err)
+	}
+	tempFile := c.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	if err := os.Rename(tempFile, c.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("replace cassette: %w", err)
+	}
+	return nil
+}
+
+// AssetDir returns the directory that holds binary payloads (such as images) next to the
+// cassette file: "vision.json" keeps its assets in "vision.assets/".
+func (c *Cassette) AssetDir() string {
+	return strings.TrimSuffix(c.path, filepath.Ext(c.path)) + ".assets"
+}
+
+// WriteAsset stores data under a content-addressed name and returns that name.
+func (c *Cassette) WriteAsset(data []byte, ext string) (string, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:16])
+	if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); ext != "" {
+		name += "." + ext
+	}
+	dir := c.AssetDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create cassette asset dir: %w", err)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return name, nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("write cassette asset: %w", err)
+	}
+	return name, nil
+}
+
+// ReadAsset reads a payload written by WriteAsset.
+func (c *Cassette) ReadAsset(name string) ([]byte, error) {
+	if name == "" || name != filepath.Base(name) {
+		return nil, fmt.Errorf("invalid cassette asset name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(c.AssetDir(), name))
+	if err != nil {
+		return nil, fmt.Errorf("read cassette asset: %w", err)
+	}
+	return data, nil
+}
+
+// Key hashes the canonical JSON encoding of a normalized request.
+func Key(request interface{}) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("encode cassette key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HashBytes returns a short content hash for binary request fields such as reference images.
+func HashBytes(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Normalizer canonicalizes request text before hashing: whitespace runs collapse to a single
+// space and matches of the ignore patterns (timestamps, generated IDs, ...) are masked.
+type Normalizer struct {
+	ignore []*regexp.Regexp
+}
+
+// NewNormalizer compiles the ignore patterns.
+func NewNormalizer(patterns []string) (*Normalizer, error) {
+	n := &Normalizer{}
+	for _, p := range patterns {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+		n.ignore = append(n.ignore, re)
+	}
+	return n, nil
+}
+
+// ParsePatterns parses ignore patterns given as a JSON array of strings; empty input means none.
+func ParsePatterns(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var patterns []string
+	if err := json.Unmarshal([]byte(raw), &patterns); err != nil {
+		return nil, fmt.Errorf("ignore_patterns must be a JSON array of regular expressions: %w", err)
+	}
+	return patterns, nil
+}
+
+// Text returns the normalized form of s.
+func (n *Normalizer) Text(s string) string {
+	if n != nil {
+		for _, re := range n.ignore {
+			s = re.ReplaceAllString(s, "<ignored>")
+		}
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Describe returns a short single-line preview of s for miss errors.
+func Describe(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > limit {
+		return string(r[:limit]) + "..."
+	}
+	return s
+}
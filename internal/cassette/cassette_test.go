@@ -0,0 +1,124 @@
+package cassette
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCassette_RecordLookupAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm.json")
+
+	c, err := Open(path, "llm")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if c.Exists() {
+		t.Fatalf("expected no file before first record")
+	}
+
+	key, _ := Key(map[string]string{"prompt": "hi"})
+	if err := c.Record(key, "hi", "first"); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+	if err := c.Record(key, "hi", "second"); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+
+	reopened, err := Open(path, "llm")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	for _, want := range []string{`"first"`, `"second"`, `"second"`} {
+		got, ok := reopened.Lookup(key)
+		if !ok || string(got) != want {
+			t.Fatalf("Lookup = %s %v, want %s", got, ok, want)
+		}
+	}
+	if _, ok := reopened.Lookup("missing"); ok {
+		t.Fatalf("expected miss for unknown key")
+	}
+
+	// The first recording of a key in a new session replaces the old entries.
+	if err := reopened.Record(key, "hi", "third"); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("expected re-record to replace old entries, got %d", reopened.Len())
+	}
+
+	if _, err := Open(path, "vision"); err == nil {
+		t.Fatalf("expected kind mismatch error")
+	}
+}
+
+func TestCassette_Assets(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "vision.json"), "vision")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	name, err := c.WriteAsset([]byte("png-bytes"), "png")
+	if err != nil {
+		t.Fatalf("WriteAsset error: %v", err)
+	}
+	data, err := c.ReadAsset(name)
+	if err != nil || string(data) != "png-bytes" {
+		t.Fatalf("ReadAsset = %q err=%v", data, err)
+	}
+	if _, err := c.ReadAsset("../vision.json"); err == nil {
+		t.Fatalf("expected path traversal to be rejected")
+	}
+}
+
+func TestNormalizerAndMode(t *testing.T) {
+	n, err := NewNormalizer([]string{`id-[0-9a-f]+`})
+	if err != nil {
+		t.Fatalf("NewNormalizer error: %v", err)
+	}
+	if got := n.Text("  scene  id-3f2a\n\tstart "); got != "scene <ignored> start" {
+		t.Fatalf("Text = %q", got)
+	}
+	if _, err := NewNormalizer([]string{"("}); err == nil {
+		t.Fatalf("expected invalid pattern error")
+	}
+
+	if m, err := ParseMode(""); err != nil || m != ModeReplay {
+		t.Fatalf("ParseMode(\"\") = %v %v", m, err)
+	}
+	if m, err := ParseMode("STRICT"); err != nil || m != ModeStrict {
+		t.Fatalf("ParseMode(STRICT) = %v %v", m, err)
+	}
+	if _, err := ParseMode("live"); err == nil {
+		t.Fatalf("expected invalid mode error")
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	for name, want := range map[string]string{
+		"llm.json":                  filepath.Join("data", "cassettes", "llm.json"),
+		"runs/llm.json":             filepath.Join("data", "cassettes", "runs", "llm.json"),
+		"data/cassettes/llm.json":   filepath.Join("data", "cassettes", "llm.json"),
+		"./data/cassettes/old.json": filepath.Join("data", "cassettes", "old.json"),
+	} {
+		got, err := ResolvePath(filepath.Join("data", "cassettes"), name)
+		if err != nil || got != want {
+			t.Fatalf("ResolvePath(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "/etc/passwd.json", "../config.json", "runs/../../config.json", "."} {
+		if _, err := ResolvePath(filepath.Join("data", "cassettes"), name); !errors.Is(err, ErrInvalidPath) {
+			t.Fatalf("ResolvePath(%q) error = %v, want ErrInvalidPath", name, err)
+		}
+	}
+}
+
+func TestOpenRejectsNonCassetteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"llm_provider":"openai"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, "llm"); err == nil {
+		t.Fatalf("expected a JSON file without version/kind to be rejected")
+	}
+}
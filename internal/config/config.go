@@ -7,9 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
 	"github.com/Corphon/SceneIntruderMCP/internal/utils"
 	"github.com/joho/godotenv"
 )
@@ -34,10 +37,33 @@ type AppConfig struct {
 	LogDir       string `json:"log_dir"`
 	DebugMode    bool   `json:"debug_mode"`
 
+	// 存储后端（file / sqlite），仅由环境变量决定
+	StorageBackend    string `json:"-"`
+	StorageSQLitePath string `json:"-"`
+
+	// 漫画页面排版字体（TTF/OTF/TTC），仅由环境变量决定
+	ComicFontPath string `json:"-"`
+
+	// 管理员用户名或用户 ID（可修改价格表与用量配额），仅由环境变量决定
+	AdminUsers []string `json:"-"`
+
 	// LLM相关配置
 	LLMProvider string            `json:"llm_provider"`
 	LLMConfig   map[string]string `json:"llm_config"`
 
+	// 备用 LLM 提供商，按顺序在主提供商不可用时使用
+	LLMFallbacks []LLMFallbackInfo `json:"llm_fallbacks,omitempty"`
+
+	// 按任务类别（analysis / chat / story / comic / script）路由到指定的提供商与模型
+	LLMRoutes map[string]LLMRouteInfo `json:"llm_routes,omitempty"`
+
+	// 价格表（键为 "provider/model" 或 "provider/*"），用于计算 LLM 调用费用
+	LLMPrices map[string]LLMPriceInfo `json:"llm_prices,omitempty"`
+
+	// 每个用户的默认用量配额，以及按用户 ID 覆盖的配额
+	UsageQuota      UsageQuotaInfo            `json:"usage_quota,omitempty"`
+	UserUsageQuotas map[string]UsageQuotaInfo `json:"user_usage_quotas,omitempty"`
+
 	// Encrypted API key storage (stored as encrypted string)
 	EncryptedLLMConfig map[string]string `json:"encrypted_llm_config,omitempty"`
 
@@ -69,6 +95,49 @@ type VisionModelInfo struct {
 	SupportsReferenceImage bool   `json:"supports_reference_image"`
 }
 
+// LLMFallbackInfo describes one backup LLM backend. Backends are tried in order after the
+// primary provider fails with a retryable error. The api_key is kept in EncryptedAPIKey
+// unless config encryption is disabled.
+type LLMFallbackInfo struct {
+	Provider        string            `json:"provider"`
+	Model           string            `json:"model,omitempty"`
+	Config          map[string]string `json:"config,omitempty"`
+	EncryptedAPIKey string            `json:"encrypted_api_key,omitempty"`
+}
+
+// LLMRouteInfo maps one task class to a provider/model pair with its own sampling defaults.
+// Provider must be the primary LLM provider or one of the fallbacks, whose credentials are reused.
+// Empty Provider means the primary provider. Temperature / MaxTokens, when set, override the
+// values chosen by the call site; unset fields keep the call site's values.
+type LLMRouteInfo struct {
+	Provider    string   `json:"provider,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+}
+
+// LLMPriceInfo is the price of one provider/model in USD per million tokens.
+type LLMPriceInfo struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// UsageQuotaInfo caps a user's LLM usage per day and per calendar month. Zero means unlimited.
+type UsageQuotaInfo struct {
+	DailyTokens    int64   `json:"daily_tokens,omitempty"`
+	MonthlyTokens  int64   `json:"monthly_tokens,omitempty"`
+	DailyCostUSD   float64 `json:"daily_cost_usd,omitempty"`
+	MonthlyCostUSD float64 `json:"monthly_cost_usd,omitempty"`
+}
+
+// IsZero reports whether the quota sets no limit at all.
+func (q UsageQuotaInfo) IsZero() bool {
+	return q.DailyTokens <= 0 && q.MonthlyTokens <= 0 && q.DailyCostUSD <= 0 && q.MonthlyCostUSD <= 0
+}
+
+// MaxLLMFallbacks limits the length of the fallback chain.
+const MaxLLMFallbacks = 5
+
 type VideoModelInfo struct {
 	Key                      string `json:"key"`
 	Label                    string `json:"label"`
@@ -85,6 +154,13 @@ type Config struct {
 	TemplatesDir string
 	LogDir       string
 	DebugMode    bool
+
+	StorageBackend    string
+	StorageSQLitePath string
+
+	ComicFontPath string
+
+	AdminUsers []string
 }
 
 // generateEncryptionKey generates a secure encryption key
@@ -191,6 +267,13 @@ func Load() (*Config, error) {
 		TemplatesDir: getEnv("TEMPLATES_DIR", defaultTemplatesDir),
 		LogDir:       getEnvPath("LOG_DIR", "logs"),
 		DebugMode:    getEnvBool("DEBUG_MODE", true),
+
+		StorageBackend:    strings.ToLower(getEnv("STORAGE_BACKEND", "file")),
+		StorageSQLitePath: getEnv("STORAGE_SQLITE_PATH", ""),
+
+		ComicFontPath: getEnv("COMIC_FONT_PATH", ""),
+
+		AdminUsers: adminUsersFromEnv(),
 	}
 
 	// 验证OpenAI API密钥 (这是可选的，可以通过设置页面配置)
@@ -202,6 +285,18 @@ func Load() (*Config, error) {
 	return config, nil
 }
 
+// adminUsersFromEnv 读取 AUTH_ADMIN_USERS（逗号分隔），并包含引导管理员 AUTH_ADMIN_USERNAME
+func adminUsersFromEnv() []string {
+	var admins []string
+	for _, name := range strings.Split(getEnv("AUTH_ADMIN_USERS", "")+","+getEnv("AUTH_ADMIN_USERNAME", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.Contains(admins, name) {
+			admins = append(admins, name)
+		}
+	}
+	return admins
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -470,6 +565,29 @@ func (c *AppConfig) getLLMConfig() map[string]string {
 	return config
 }
 
+// getLLMFallbacks returns a copy of the fallback chain with decrypted api_key injected into each Config.
+func (c *AppConfig) getLLMFallbacks() []LLMFallbackInfo {
+	if len(c.LLMFallbacks) == 0 {
+		return nil
+	}
+	out := make([]LLMFallbackInfo, 0, len(c.LLMFallbacks))
+	for _, fb := range c.LLMFallbacks {
+		cfg := make(map[string]string, len(fb.Config)+1)
+		for k, v := range fb.Config {
+			cfg[k] = v
+		}
+		if fb.EncryptedAPIKey != "" {
+			if key, err := decryptAPIKey(fb.EncryptedAPIKey); err == nil {
+				cfg["api_key"] = key
+			} else {
+				utils.GetLogger().Warn("无法解密备用LLM提供商的API密钥", map[string]interface{}{"provider": fb.Provider})
+			}
+		}
+		out = append(out, LLMFallbackInfo{Provider: fb.Provider, Model: fb.Model, Config: cfg})
+	}
+	return out
+}
+
 // InitConfig 初始化配置管理器
 func InitConfig(dataDir string) error {
 	configFile = filepath.Join(dataDir, "config.json")
@@ -492,6 +610,10 @@ func InitConfig(dataDir string) error {
 		TemplatesDir:          baseConfig.TemplatesDir,
 		LogDir:                baseConfig.LogDir,
 		DebugMode:             baseConfig.DebugMode,
+		StorageBackend:        baseConfig.StorageBackend,
+		StorageSQLitePath:     baseConfig.StorageSQLitePath,
+		ComicFontPath:         baseConfig.ComicFontPath,
+		AdminUsers:            baseConfig.AdminUsers,
 		LLMProvider:           "",                      // No default provider
 		LLMConfig:             make(map[string]string), // Empty config initially
 		EncryptedLLMConfig:    make(map[string]string),
@@ -514,6 +636,7 @@ func InitConfig(dataDir string) error {
 			{Key: "glm-image", Label: "glm-image", Provider: "", SupportsReferenceImage: false},
 			{Key: "dalle3", Label: "dalle3", Provider: "", SupportsReferenceImage: false},
 			{Key: "sd", Label: "sd", Provider: "", SupportsReferenceImage: true},
+			{Key: "comfyui", Label: "comfyui (workflow)", Provider: "", SupportsReferenceImage: true},
 			{Key: "midjourney", Label: "midjourney", Provider: "", SupportsReferenceImage: false},
 			{Key: "placeholder", Label: "Placeholder", Provider: "placeholder", SupportsReferenceImage: false},
 		},
@@ -526,6 +649,7 @@ func InitConfig(dataDir string) error {
 			"veo-2":                   "google",
 			"veo-2-vertex":            "vertex",
 			"doubao-seedance-1-5-pro": "ark",
+			"ken-burns":               "local",
 		},
 		VideoModels: []VideoModelInfo{
 			{Key: "wan2.6-i2v-flash", Label: "wan2.6-i2v-flash", Provider: "dashscope", SupportsImageConditioned: true},
@@ -533,6 +657,7 @@ func InitConfig(dataDir string) error {
 			{Key: "veo-2", Label: "veo-2", Provider: "google", SupportsImageConditioned: true},
 			{Key: "veo-2-vertex", Label: "veo-2-vertex", Provider: "vertex", SupportsImageConditioned: true},
 			{Key: "doubao-seedance-1-5-pro", Label: "doubao-seedance-1-5-pro", Provider: "ark", SupportsImageConditioned: true},
+			{Key: "ken-burns", Label: "Ken Burns (local ffmpeg)", Provider: "local", SupportsImageConditioned: true},
 		},
 	}
 
@@ -580,6 +705,14 @@ func InitConfig(dataDir string) error {
 					hasMeaningfulValues = true
 				}
 
+				if len(savedConfig.LLMFallbacks) > 0 || len(savedConfig.LLMRoutes) > 0 || len(savedConfig.LLMPrices) > 0 {
+					hasMeaningfulValues = true
+				}
+
+				if !savedConfig.UsageQuota.IsZero() || len(savedConfig.UserUsageQuotas) > 0 {
+					hasMeaningfulValues = true
+				}
+
 				// Vision meaningful checks (Phase5): allow vision-only config to be loaded even when LLM is untouched.
 				if savedConfig.VisionProvider != "" && savedConfig.VisionProvider != "placeholder" {
 					hasMeaningfulValues = true
@@ -644,6 +777,10 @@ func InitConfig(dataDir string) error {
 					savedConfig.TemplatesDir = baseConfig.TemplatesDir
 					savedConfig.LogDir = baseConfig.LogDir
 					savedConfig.DebugMode = baseConfig.DebugMode
+					savedConfig.StorageBackend = baseConfig.StorageBackend
+					savedConfig.StorageSQLitePath = baseConfig.StorageSQLitePath
+					savedConfig.ComicFontPath = baseConfig.ComicFontPath
+					savedConfig.AdminUsers = baseConfig.AdminUsers
 
 					// Handle backward compatibility with unencrypted API keys in old configs
 					if savedConfig.LLMConfig != nil {
@@ -740,6 +877,23 @@ func InitConfig(dataDir string) error {
 						}
 					}
 
+					if isEncryptionEnabled() {
+						for i := range savedConfig.LLMFallbacks {
+							fb := &savedConfig.LLMFallbacks[i]
+							apiKey := fb.Config["api_key"]
+							if apiKey == "" {
+								continue
+							}
+							encrypted, err := encryptAPIKey(apiKey)
+							if err != nil {
+								utils.GetLogger().Warn("无法加密旧配置中的备用LLM API密钥", map[string]interface{}{"provider": fb.Provider, "err": err})
+								continue
+							}
+							fb.EncryptedAPIKey = encrypted
+							delete(fb.Config, "api_key")
+						}
+					}
+
 					// If config file doesn't provide an API key, fall back to environment variable key.
 					// This preserves the old behavior where empty keys are filled from OPENAI_API_KEY.
 					if baseConfig.OpenAIAPIKey != "" && savedConfig.getDecryptedAPIKey() == "" {
@@ -777,6 +931,10 @@ func GetCurrentConfig() *AppConfig {
 			TemplatesDir:          baseConfig.TemplatesDir,
 			LogDir:                baseConfig.LogDir,
 			DebugMode:             baseConfig.DebugMode,
+			StorageBackend:        baseConfig.StorageBackend,
+			StorageSQLitePath:     baseConfig.StorageSQLitePath,
+			ComicFontPath:         baseConfig.ComicFontPath,
+			AdminUsers:            baseConfig.AdminUsers,
 			LLMProvider:           "",
 			LLMConfig:             make(map[string]string),
 			EncryptedLLMConfig:    make(map[string]string),
@@ -799,6 +957,7 @@ func GetCurrentConfig() *AppConfig {
 				{Key: "glm-image", Label: "glm-image", Provider: "", SupportsReferenceImage: false},
 				{Key: "dalle3", Label: "dalle3", Provider: "", SupportsReferenceImage: false},
 				{Key: "sd", Label: "sd", Provider: "", SupportsReferenceImage: true},
+				{Key: "comfyui", Label: "comfyui (workflow)", Provider: "", SupportsReferenceImage: true},
 				{Key: "midjourney", Label: "midjourney", Provider: "", SupportsReferenceImage: false},
 				{Key: "placeholder", Label: "Placeholder", Provider: "placeholder", SupportsReferenceImage: false},
 			},
@@ -811,6 +970,7 @@ func GetCurrentConfig() *AppConfig {
 				"veo-2":                   "google",
 				"veo-2-vertex":            "vertex",
 				"doubao-seedance-1-5-pro": "ark",
+				"ken-burns":               "local",
 			},
 			VideoModels: []VideoModelInfo{
 				{Key: "wan2.6-i2v-flash", Label: "wan2.6-i2v-flash", Provider: "dashscope", SupportsImageConditioned: true},
@@ -818,6 +978,7 @@ func GetCurrentConfig() *AppConfig {
 				{Key: "veo-2", Label: "veo-2", Provider: "google", SupportsImageConditioned: true},
 				{Key: "veo-2-vertex", Label: "veo-2-vertex", Provider: "vertex", SupportsImageConditioned: true},
 				{Key: "doubao-seedance-1-5-pro", Label: "doubao-seedance-1-5-pro", Provider: "ark", SupportsImageConditioned: true},
+				{Key: "ken-burns", Label: "Ken Burns (local ffmpeg)", Provider: "local", SupportsImageConditioned: true},
 			},
 		}
 
@@ -836,6 +997,25 @@ func GetCurrentConfig() *AppConfig {
 	// Return a copy with decrypted Vision config.
 	configCopy.VisionConfig = currentConfig.getVisionConfig()
 	configCopy.VideoConfig = currentConfig.getVideoConfig()
+	configCopy.LLMFallbacks = currentConfig.getLLMFallbacks()
+	if currentConfig.LLMRoutes != nil {
+		configCopy.LLMRoutes = make(map[string]LLMRouteInfo, len(currentConfig.LLMRoutes))
+		for k, v := range currentConfig.LLMRoutes {
+			configCopy.LLMRoutes[k] = v
+		}
+	}
+	if currentConfig.LLMPrices != nil {
+		configCopy.LLMPrices = make(map[string]LLMPriceInfo, len(currentConfig.LLMPrices))
+		for k, v := range currentConfig.LLMPrices {
+			configCopy.LLMPrices[k] = v
+		}
+	}
+	if currentConfig.UserUsageQuotas != nil {
+		configCopy.UserUsageQuotas = make(map[string]UsageQuotaInfo, len(currentConfig.UserUsageQuotas))
+		for k, v := range currentConfig.UserUsageQuotas {
+			configCopy.UserUsageQuotas[k] = v
+		}
+	}
 	if currentConfig.VisionModelProviders != nil {
 		configCopy.VisionModelProviders = make(map[string]string, len(currentConfig.VisionModelProviders))
 		for k, v := range currentConfig.VisionModelProviders {
@@ -856,12 +1036,13 @@ func GetCurrentConfig() *AppConfig {
 		configCopy.VideoModels = make([]VideoModelInfo, len(currentConfig.VideoModels))
 		copy(configCopy.VideoModels, currentConfig.VideoModels)
 	}
+	configCopy.AdminUsers = slices.Clone(currentConfig.AdminUsers)
 	return &configCopy
 }
 
 // validateVisionProvider validates a supported vision provider.
 func validateVisionProvider(provider string) error {
-	supported := []string{"placeholder", "sdwebui", "dashscope", "gemini", "ark", "openai", "glm"}
+	supported := []string{"placeholder", "sdwebui", "comfyui", "dashscope", "gemini", "ark", "openai", "glm", "replay"}
 	if slices.Contains(supported, provider) {
 		return nil
 	}
@@ -884,6 +1065,26 @@ func validateVisionConfig(provider string, cfg map[string]string) error {
 			return fmt.Errorf("sdwebui 需要 endpoint")
 		}
 		return nil
+	case "comfyui":
+		endpoint := ""
+		workflow := ""
+		if cfg != nil {
+			endpoint = cfg["endpoint"]
+			if endpoint == "" {
+				endpoint = cfg["base_url"]
+			}
+			workflow = strings.TrimSpace(cfg["workflow"])
+		}
+		if endpoint == "" {
+			return fmt.Errorf("comfyui 需要 endpoint")
+		}
+		if workflow == "" || !json.Valid([]byte(workflow)) {
+			return fmt.Errorf("comfyui 需要 workflow（API 格式的工作流 JSON）")
+		}
+		if extra := strings.TrimSpace(cfg["workflow_img2img"]); extra != "" && !json.Valid([]byte(extra)) {
+			return fmt.Errorf("comfyui workflow_img2img 不是合法的 JSON")
+		}
+		return nil
 	case "dashscope", "gemini", "ark", "openai", "glm":
 		endpoint := ""
 		if cfg != nil {
@@ -897,13 +1098,43 @@ func validateVisionConfig(provider string, cfg map[string]string) error {
 		}
 		// api_key can be provided via env fallback.
 		return nil
+	case "replay":
+		mode, err := cassette.ParseMode(cfg["mode"])
+		if err != nil {
+			return err
+		}
+		if _, err := cassette.ParsePatterns(cfg["ignore_patterns"]); err != nil {
+			return err
+		}
+		if name := strings.TrimSpace(cfg["cassette"]); name != "" {
+			if err := cassette.CheckName(name); err != nil {
+				return err
+			}
+		}
+		upstream := strings.TrimSpace(cfg["upstream"])
+		if upstream == "replay" {
+			return fmt.Errorf("replay 的上游提供商不能是 replay")
+		}
+		if upstream == "" {
+			if mode == cassette.ModeRecord {
+				return fmt.Errorf("replay 的 record 模式需要 upstream")
+			}
+			return nil
+		}
+		if err := validateVisionProvider(upstream); err != nil {
+			return err
+		}
+		if mode == cassette.ModeStrict {
+			return nil
+		}
+		return validateVisionConfig(upstream, cfg)
 	default:
 		return fmt.Errorf("不支持的vision提供商: %s", provider)
 	}
 }
 
 func validateVideoProvider(provider string) error {
-	supported := []string{"mock", "dashscope", "kling", "google", "vertex", "ark"}
+	supported := []string{"mock", "local", "dashscope", "kling", "google", "vertex", "ark"}
 	if slices.Contains(supported, provider) {
 		return nil
 	}
@@ -912,7 +1143,7 @@ func validateVideoProvider(provider string) error {
 
 func validateVideoConfig(provider string, cfg map[string]string) error {
 	switch provider {
-	case "mock":
+	case "mock", "local":
 		return nil
 	case "dashscope", "kling", "google", "vertex", "ark":
 		endpoint := ""
@@ -976,6 +1207,8 @@ func UpdateVisionConfig(provider string, visionCfg map[string]string, defaultMod
 		// Sensible defaults.
 		if provider == "sdwebui" {
 			defaultModel = "sd"
+		} else if provider == "comfyui" {
+			defaultModel = "comfyui"
 		} else if provider == "dashscope" {
 			defaultModel = "qwen-image-2.0"
 		} else if provider == "gemini" {
@@ -986,6 +1219,8 @@ func UpdateVisionConfig(provider string, visionCfg map[string]string, defaultMod
 			defaultModel = "gpt-image-1.5"
 		} else if provider == "glm" {
 			defaultModel = "glm-image"
+		} else if provider == "replay" {
+			defaultModel = "replay"
 		} else {
 			defaultModel = "placeholder"
 		}
@@ -1070,6 +1305,8 @@ func UpdateVideoConfig(provider string, videoCfg map[string]string, defaultModel
 			defaultModel = "veo-2-vertex"
 		case "ark":
 			defaultModel = "doubao-seedance-1-5-pro"
+		case "local":
+			defaultModel = "ken-burns"
 		default:
 			defaultModel = "wan2.6-i2v-flash"
 		}
@@ -1143,6 +1380,11 @@ func UpdateLLMConfig(provider string, config map[string]string) error {
 
 	currentConfig.LLMProvider = provider
 
+	// 本地提供商未配置密钥时，清除上一个提供商遗留的加密密钥
+	if newConfig["api_key"] == "" {
+		delete(currentConfig.EncryptedLLMConfig, "api_key")
+	}
+
 	// Handle API key encryption/decryption based on useEncryption setting
 	currentConfig.LLMConfig = make(map[string]string)
 	for k, v := range newConfig {
@@ -1187,6 +1429,155 @@ func UpdateFullConfig(provider string, llmConfig map[string]string, encryptedLLM
 	return SaveConfig()
 }
 
+// UpdateLLMFallbacks 替换备用 LLM 提供商链。
+// 条目未提供 api_key 时复用同一提供商已保存的密钥（先查原备用链，再查主提供商）。
+func UpdateLLMFallbacks(fallbacks []LLMFallbackInfo) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	if currentConfig == nil {
+		return fmt.Errorf("配置系统未初始化")
+	}
+	if len(fallbacks) > MaxLLMFallbacks {
+		return fmt.Errorf("备用提供商最多 %d 个", MaxLLMFallbacks)
+	}
+
+	existing := currentConfig.getLLMFallbacks()
+	stored := make([]LLMFallbackInfo, 0, len(fallbacks))
+	for i, fb := range fallbacks {
+		provider := strings.TrimSpace(fb.Provider)
+		if err := validateLLMProvider(provider); err != nil {
+			return fmt.Errorf("备用提供商 #%d: %w", i+1, err)
+		}
+
+		cfg := make(map[string]string, len(fb.Config)+1)
+		for k, v := range fb.Config {
+			cfg[k] = v
+		}
+		if cfg["api_key"] == "" {
+			for _, old := range existing {
+				if old.Provider == provider && old.Config["api_key"] != "" {
+					cfg["api_key"] = old.Config["api_key"]
+					break
+				}
+			}
+		}
+		if cfg["api_key"] == "" && currentConfig.LLMProvider == provider {
+			cfg["api_key"] = currentConfig.getDecryptedAPIKey()
+		}
+		if err := validateLLMConfig(provider, cfg); err != nil {
+			return fmt.Errorf("备用提供商 #%d (%s): %w", i+1, provider, err)
+		}
+
+		entry := LLMFallbackInfo{Provider: provider, Model: strings.TrimSpace(fb.Model), Config: cfg}
+		if apiKey := cfg["api_key"]; apiKey != "" && isEncryptionEnabled() {
+			encrypted, err := encryptAPIKey(apiKey)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt fallback API key: %w", err)
+			}
+			delete(cfg, "api_key")
+			entry.EncryptedAPIKey = encrypted
+		}
+		stored = append(stored, entry)
+	}
+
+	currentConfig.LLMFallbacks = stored
+	return SaveConfig()
+}
+
+// UpdateLLMRoutes 替换按任务类别的模型路由表；任务类别名由调用方校验
+func UpdateLLMRoutes(routes map[string]LLMRouteInfo) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	if currentConfig == nil {
+		return fmt.Errorf("配置系统未初始化")
+	}
+
+	stored := make(map[string]LLMRouteInfo, len(routes))
+	for task, route := range routes {
+		route.Provider = strings.TrimSpace(route.Provider)
+		route.Model = strings.TrimSpace(route.Model)
+		if route.Provider != "" {
+			if err := validateLLMProvider(route.Provider); err != nil {
+				return fmt.Errorf("路由 %s: %w", task, err)
+			}
+		}
+		if route.Temperature != nil && (*route.Temperature < 0 || *route.Temperature > 2) {
+			return fmt.Errorf("路由 %s: temperature 必须在 0..2 之间", task)
+		}
+		if route.MaxTokens < 0 {
+			return fmt.Errorf("路由 %s: max_tokens 不能为负数", task)
+		}
+		stored[task] = route
+	}
+
+	currentConfig.LLMRoutes = stored
+	return SaveConfig()
+}
+
+// UpdateLLMPrices 替换价格表。键为 "provider/model" 或 "provider/*"，价格为每百万 token 的美元价
+func UpdateLLMPrices(prices map[string]LLMPriceInfo) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	if currentConfig == nil {
+		return fmt.Errorf("配置系统未初始化")
+	}
+
+	stored := make(map[string]LLMPriceInfo, len(prices))
+	for key, price := range prices {
+		key = strings.TrimSpace(key)
+		provider, model, ok := strings.Cut(key, "/")
+		if !ok || strings.TrimSpace(provider) == "" || strings.TrimSpace(model) == "" {
+			return fmt.Errorf("价格键 %q 必须为 provider/model 或 provider/*", key)
+		}
+		if price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+			return fmt.Errorf("价格 %s 不能为负数", key)
+		}
+		stored[key] = price
+	}
+
+	currentConfig.LLMPrices = stored
+	return SaveConfig()
+}
+
+// UpdateUsageQuotas 替换默认配额与按用户覆盖的配额；各项为 0 表示不限制
+func UpdateUsageQuotas(defaultQuota UsageQuotaInfo, userQuotas map[string]UsageQuotaInfo) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	if currentConfig == nil {
+		return fmt.Errorf("配置系统未初始化")
+	}
+
+	if err := validateUsageQuota(defaultQuota); err != nil {
+		return fmt.Errorf("默认配额: %w", err)
+	}
+	stored := make(map[string]UsageQuotaInfo, len(userQuotas))
+	for userID, quota := range userQuotas {
+		userID = strings.TrimSpace(userID)
+		if userID == "" {
+			return fmt.Errorf("用户配额缺少用户 ID")
+		}
+		if err := validateUsageQuota(quota); err != nil {
+			return fmt.Errorf("用户 %s 的配额: %w", userID, err)
+		}
+		stored[userID] = quota
+	}
+
+	currentConfig.UsageQuota = defaultQuota
+	currentConfig.UserUsageQuotas = stored
+	return SaveConfig()
+}
+
+func validateUsageQuota(q UsageQuotaInfo) error {
+	if q.DailyTokens < 0 || q.MonthlyTokens < 0 || q.DailyCostUSD < 0 || q.MonthlyCostUSD < 0 {
+		return fmt.Errorf("配额不能为负数")
+	}
+	return nil
+}
+
 // validateLLMProvider 验证 LLM 提供商是否受支持
 func validateLLMProvider(provider string) error {
 	supportedProviders := []string{
@@ -1194,7 +1585,8 @@ func validateLLMProvider(provider string) error {
 		"mistral", "qwen", "glm", "deepseek", "openrouter", "nvidia",
 	}
 
-	if slices.Contains(supportedProviders, provider) {
+	// 无需 api_key 的提供商（本地推理、录制回放）以 llm.RegisterKeyless 注册为准
+	if slices.Contains(supportedProviders, provider) || !llm.RequiresAPIKey(provider) {
 		return nil
 	}
 
@@ -1203,6 +1595,17 @@ func validateLLMProvider(provider string) error {
 
 // validateLLMConfig 验证 LLM 配置
 func validateLLMConfig(provider string, config map[string]string) error {
+	if provider == "replay" {
+		return validateReplayLLMConfig(config)
+	}
+	if provider == "local" && strings.TrimSpace(config["default_model"]) == "" && strings.TrimSpace(config["model"]) == "" {
+		// 任意 OpenAI 兼容服务没有通用的默认模型，必须显式指定
+		return fmt.Errorf("local 提供商需要配置 default_model")
+	}
+	if !llm.RequiresAPIKey(provider) {
+		return nil // base_url 缺省时使用提供商默认的本机地址
+	}
+
 	// 验证必需的配置项
 	apiKey, exists := config["api_key"]
 	if !exists {
@@ -1227,6 +1630,41 @@ func validateLLMConfig(provider string, config map[string]string) error {
 	return nil
 }
 
+// validateReplayLLMConfig 验证录制回放配置；非 strict 模式下同时验证上游提供商的配置
+func validateReplayLLMConfig(config map[string]string) error {
+	mode, err := cassette.ParseMode(config["mode"])
+	if err != nil {
+		return err
+	}
+	if _, err := cassette.ParsePatterns(config["ignore_patterns"]); err != nil {
+		return err
+	}
+	// 录制会整体重写 cassette 文件，只允许 $DATA_DIR/cassettes 下的相对路径
+	if name := strings.TrimSpace(config["cassette"]); name != "" {
+		if err := cassette.CheckName(name); err != nil {
+			return err
+		}
+	}
+
+	upstream := strings.TrimSpace(config["upstream"])
+	if upstream == "replay" {
+		return fmt.Errorf("replay 的上游提供商不能是 replay")
+	}
+	if upstream == "" {
+		if mode == cassette.ModeRecord {
+			return fmt.Errorf("replay 的 record 模式需要 upstream")
+		}
+		return nil
+	}
+	if err := validateLLMProvider(upstream); err != nil {
+		return err
+	}
+	if mode == cassette.ModeStrict {
+		return nil
+	}
+	return validateLLMConfig(upstream, config)
+}
+
 // SaveConfig 保存当前配置到文件
 func SaveConfig() error {
 	if currentConfig == nil {
@@ -1260,6 +1698,20 @@ func SaveConfig() error {
 	if isEncryptionEnabled() && configToSave.VideoConfig != nil {
 		delete(configToSave.VideoConfig, "api_key")
 	}
+	if isEncryptionEnabled() && len(configToSave.LLMFallbacks) > 0 {
+		fallbacks := make([]LLMFallbackInfo, len(configToSave.LLMFallbacks))
+		for i, fb := range configToSave.LLMFallbacks {
+			cfg := make(map[string]string, len(fb.Config))
+			for k, v := range fb.Config {
+				if k != "api_key" {
+					cfg[k] = v
+				}
+			}
+			fb.Config = cfg
+			fallbacks[i] = fb
+		}
+		configToSave.LLMFallbacks = fallbacks
+	}
 
 	// 序列化并保存
 	data, err := json.MarshalIndent(configToSave, "", "  ")
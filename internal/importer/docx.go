@@ -0,0 +1,188 @@
+// internal/importer/docx.go
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+type docxStyles struct {
+	Styles []struct {
+		ID   string `xml:"styleId,attr"`
+		Name struct {
+			Val string `xml:"val,attr"`
+		} `xml:"name"`
+		PPr struct {
+			OutlineLvl *struct {
+				Val string `xml:"val,attr"`
+			} `xml:"outlineLvl"`
+		} `xml:"pPr"`
+	} `xml:"style"`
+}
+
+type docxCore struct {
+	Title string `xml:"title"`
+}
+
+// extractDOCX 读取 word/document.xml，以「标题」样式（Title/Heading N 或大纲级别）的段落分章
+func extractDOCX(r io.ReaderAt, size int64, emit ChapterFunc) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("无效的DOCX文件: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	levels := docxStyleLevels(files)
+	title := ""
+	var core docxCore
+	if err := readZipXML(files, "docProps/core.xml", &core); err == nil {
+		title = core.Title
+	}
+
+	f, ok := files["word/document.xml"]
+	if !ok {
+		return "", fmt.Errorf("无效的DOCX文件: 缺少 word/document.xml")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	blocks, err := docxBlocks(io.LimitReader(rc, maxZipEntryBytes), levels)
+	if err != nil {
+		return "", fmt.Errorf("解析DOCX正文失败: %w", err)
+	}
+	bookTitle, err := groupBlocks(blocks, "\n\n", emit)
+	if title == "" {
+		title = bookTitle
+	}
+	return title, err
+}
+
+// docxStyleLevels 从 styles.xml 中找出标题样式及其层级（Title 视为 1 级，Heading N 为 N+1 级）
+func docxStyleLevels(files map[string]*zip.File) map[string]int {
+	levels := map[string]int{}
+	var styles docxStyles
+	if err := readZipXML(files, "word/styles.xml", &styles); err != nil {
+		return levels
+	}
+	for _, s := range styles.Styles {
+		if level := docxHeadingLevel(s.ID, s.Name.Val); level > 0 {
+			levels[s.ID] = level
+			continue
+		}
+		if s.PPr.OutlineLvl != nil {
+			if n, err := strconv.Atoi(s.PPr.OutlineLvl.Val); err == nil && n < 9 {
+				levels[s.ID] = n + 2
+			}
+		}
+	}
+	return levels
+}
+
+// docxHeadingLevel 按样式 ID 或名称识别标题样式（styles.xml 缺失时也能识别内置样式 ID）
+func docxHeadingLevel(id, name string) int {
+	for _, candidate := range []string{strings.ToLower(name), strings.ToLower(id)} {
+		candidate = strings.ReplaceAll(candidate, " ", "")
+		if candidate == "title" {
+			return 1
+		}
+		if strings.HasPrefix(candidate, "heading") {
+			if n, err := strconv.Atoi(strings.TrimPrefix(candidate, "heading")); err == nil && n > 0 {
+				return n + 1
+			}
+		}
+	}
+	return 0
+}
+
+// docxBlocks 流式解析 document.xml：每个 <w:p> 一个块，<w:br/>/<w:tab/> 转为空白
+func docxBlocks(r io.Reader, levels map[string]int) ([]block, error) {
+	decoder := xml.NewDecoder(r)
+	var blocks []block
+	var para strings.Builder
+	level := 0
+	inText := false
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				level = 0
+			case "pStyle":
+				id := wordAttr(t, "val")
+				if l, ok := levels[id]; ok {
+					level = l
+				} else {
+					level = docxHeadingLevel(id, "")
+				}
+			case "outlineLvl":
+				if n, err := strconv.Atoi(wordAttr(t, "val")); err == nil && n < 9 && level == 0 {
+					level = n + 2
+				}
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString(" ")
+			case "br", "cr":
+				para.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := para.String()
+				if level > 0 {
+					text = collapseSpaces(text)
+				} else {
+					text = strings.TrimSpace(strings.ReplaceAll(text, "\n", " "))
+				}
+				if text != "" {
+					blocks = append(blocks, block{level: level, text: text})
+				}
+				para.Reset()
+				level = 0
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+func wordAttr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
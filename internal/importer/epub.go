@@ -0,0 +1,183 @@
+// internal/importer/epub.go
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxZipEntryBytes 单个 ZIP 条目解压后的读取上限，防止压缩炸弹
+const maxZipEntryBytes = 64 << 20
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Titles   []string `xml:"metadata>title"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type ncxNavPoint struct {
+	Label    string        `xml:"navLabel>text"`
+	Content  ncxContent    `xml:"content"`
+	Children []ncxNavPoint `xml:"navPoint"`
+}
+
+type ncxContent struct {
+	Src string `xml:"src,attr"`
+}
+
+type ncxDocument struct {
+	NavPoints []ncxNavPoint `xml:"navMap>navPoint"`
+}
+
+// extractEPUB 按 spine 顺序读取各个 XHTML 文件，每个文件一章；
+// 章节标题优先取目录（toc.ncx）中的名称，其次取文件内的第一个标题。
+func extractEPUB(r io.ReaderAt, size int64, emit ChapterFunc) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("无效的EPUB文件: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := readZipXML(files, "META-INF/container.xml", &container); err != nil {
+		return "", fmt.Errorf("无效的EPUB文件: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return "", fmt.Errorf("无效的EPUB文件: 缺少 rootfile")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := readZipXML(files, opfPath, &pkg); err != nil {
+		return "", fmt.Errorf("解析EPUB包信息失败: %w", err)
+	}
+	baseDir := path.Dir(opfPath)
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	navItems := map[string]bool{}
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = resolveHref(baseDir, item.Href)
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			navItems[item.ID] = true
+		}
+	}
+
+	labels := map[string]string{}
+	if tocPath, ok := hrefs[pkg.Spine.Toc]; ok {
+		var ncx ncxDocument
+		if err := readZipXML(files, tocPath, &ncx); err == nil {
+			collectNCXLabels(path.Dir(tocPath), ncx.NavPoints, labels)
+		}
+	}
+
+	title := ""
+	if len(pkg.Titles) > 0 {
+		title = pkg.Titles[0]
+	}
+
+	for _, ref := range pkg.Spine.ItemRefs {
+		// 非线性内容（注释、附图）与 EPUB3 目录页不作为章节
+		if ref.Linear == "no" || navItems[ref.IDRef] {
+			continue
+		}
+		name, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return title, err
+		}
+		_, blocks, err := htmlBlocks(io.LimitReader(rc, maxZipEntryBytes))
+		rc.Close()
+		if err != nil {
+			return title, fmt.Errorf("解析章节 %s 失败: %w", name, err)
+		}
+
+		chapterTitle := labels[name]
+		// 文件开头的标题通常就是章名：目录中没有名称时用它作为章名，有名称时去掉以免重复
+		if len(blocks) > 0 && blocks[0].level > 0 {
+			if chapterTitle == "" {
+				chapterTitle = blocks[0].text
+			}
+			blocks = blocks[1:]
+		}
+		var body strings.Builder
+		for i, b := range blocks {
+			if i > 0 {
+				body.WriteString("\n\n")
+			}
+			body.WriteString(b.text)
+		}
+		if err := emitChapter(Chapter{Title: chapterTitle, Text: body.String()}, emit); err != nil {
+			return title, err
+		}
+	}
+	return title, nil
+}
+
+func readZipXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("缺少 %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxZipEntryBytes))
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	return decoder.Decode(v)
+}
+
+// resolveHref 将 OPF/NCX 中的相对链接解析为 ZIP 内的路径（去掉锚点并解码 %xx）
+func resolveHref(baseDir, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return strings.TrimPrefix(path.Join(baseDir, href), "./")
+}
+
+func collectNCXLabels(baseDir string, points []ncxNavPoint, labels map[string]string) {
+	for _, p := range points {
+		name := resolveHref(baseDir, p.Content.Src)
+		// 同一文件内的多个锚点只取第一个名称
+		if _, exists := labels[name]; !exists && strings.TrimSpace(p.Label) != "" {
+			labels[name] = p.Label
+		}
+		collectNCXLabels(baseDir, p.Children, labels)
+	}
+}
@@ -0,0 +1,124 @@
+// internal/importer/html.go
+package importer
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 会产生段落边界的块级元素
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Li: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Tr: true, atom.Section: true, atom.Article: true, atom.Hr: true, atom.Table: true,
+	atom.Ul: true, atom.Ol: true, atom.Dd: true, atom.Dt: true, atom.Figure: true,
+	atom.Figcaption: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Main: true,
+	atom.Body: true,
+}
+
+// 不包含正文的元素
+var htmlSkippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Math: true, atom.Nav: true,
+}
+
+var htmlHeadingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// htmlBlocks 把 HTML/XHTML 文档拆为标题与段落块，同时返回 <title>
+func htmlBlocks(r io.Reader) (string, []block, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var blocks []block
+	var para strings.Builder
+	title := ""
+	flush := func() {
+		if text := collapseSpaces(para.String()); text != "" {
+			blocks = append(blocks, block{text: text})
+		}
+		para.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			para.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if n.DataAtom == atom.Title && title == "" {
+				title = collapseSpaces(nodeText(n))
+				return
+			}
+			if n.DataAtom == atom.Head {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.ElementNode && c.DataAtom == atom.Title {
+						walk(c)
+					}
+				}
+				return
+			}
+			if htmlSkippedElements[n.DataAtom] {
+				return
+			}
+			if level, ok := htmlHeadingLevels[n.DataAtom]; ok {
+				flush()
+				if text := collapseSpaces(nodeText(n)); text != "" {
+					blocks = append(blocks, block{level: level, text: text})
+				}
+				return
+			}
+			if n.DataAtom == atom.Br {
+				flush()
+				return
+			}
+			if htmlBlockElements[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	flush()
+	return title, blocks, nil
+}
+
+// nodeText 拼接节点下的全部文本
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func extractHTML(r io.ReaderAt, size int64, emit ChapterFunc) (string, error) {
+	title, blocks, err := htmlBlocks(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return "", err
+	}
+	bookTitle, err := groupBlocks(blocks, "\n\n", emit)
+	if bookTitle != "" {
+		title = bookTitle
+	}
+	return title, err
+}
@@ -0,0 +1,219 @@
+// internal/importer/importer.go
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+)
+
+// 支持的导入格式
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatEPUB     = "epub"
+	FormatDOCX     = "docx"
+	FormatPDF      = "pdf"
+)
+
+// ErrUnsupportedFormat 文件扩展名不在支持列表中
+var ErrUnsupportedFormat = errors.New("不支持的文件格式")
+
+// ErrNoText 文件中没有可提取的文本（例如扫描版 PDF）
+var ErrNoText = errors.New("文件中没有可提取的文本")
+
+// ErrStopImport 由 ChapterFunc 返回时提前结束提取，不视为错误
+var ErrStopImport = errors.New("停止导入")
+
+// Chapter 提取出的一个章节；Text 中的段落以空行分隔
+type Chapter struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// Document 导入结果
+type Document struct {
+	Title    string    `json:"title"`
+	Format   string    `json:"format"`
+	Chapters []Chapter `json:"chapters"`
+}
+
+// ChapterFunc 流式提取时对每个章节的回调；返回 ErrStopImport 可提前结束
+type ChapterFunc func(ch Chapter) error
+
+// extractor 各格式的提取实现：通过 emit 逐章输出，返回文档标题（可为空）
+type extractor func(r io.ReaderAt, size int64, emit ChapterFunc) (string, error)
+
+var extractors = map[string]struct {
+	format string
+	fn     extractor
+}{
+	".txt":   {FormatText, extractText},
+	".md":    {FormatMarkdown, extractMarkdown},
+	".html":  {FormatHTML, extractHTML},
+	".htm":   {FormatHTML, extractHTML},
+	".xhtml": {FormatHTML, extractHTML},
+	".epub":  {FormatEPUB, extractEPUB},
+	".docx":  {FormatDOCX, extractDOCX},
+	".pdf":   {FormatPDF, extractPDF},
+}
+
+// SupportedExtensions 返回支持的文件扩展名（小写，带点）
+func SupportedExtensions() []string {
+	return []string{".txt", ".md", ".html", ".htm", ".xhtml", ".epub", ".docx", ".pdf"}
+}
+
+// IsSupported 判断文件名的扩展名是否可导入
+func IsSupported(filename string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// FormatOf 返回文件名对应的导入格式；不支持时返回空串
+func FormatOf(filename string) string {
+	return extractors[strings.ToLower(filepath.Ext(filename))].format
+}
+
+// Stream 逐章提取 r 中的文本，每得到一个非空章节就调用 emit。
+// 章节在提取过程中依次输出，调用方可以边读边处理，也可以通过 ErrStopImport 提前结束；
+// 超长章节会被拆分，因此整本书也能以章节为单位处理而不是被整体拒绝。
+func Stream(filename string, r io.ReaderAt, size int64, emit ChapterFunc) (string, error) {
+	ext, ok := extractors[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(filename))
+	}
+
+	count := 0
+	title, err := ext.fn(r, size, func(ch Chapter) error {
+		count++
+		ch.Title = collapseSpaces(ch.Title)
+		if ch.Title == "" {
+			ch.Title = fmt.Sprintf("Section %d", count)
+		}
+		return emit(ch)
+	})
+	if err != nil && !errors.Is(err, ErrStopImport) {
+		return "", err
+	}
+	if count == 0 {
+		return "", ErrNoText
+	}
+	return collapseSpaces(title), nil
+}
+
+// Extract 提取整个文档。maxRunes > 0 时累计正文达到该长度后不再读取后续章节，并返回 truncated=true。
+func Extract(filename string, r io.ReaderAt, size int64, maxRunes int) (*Document, bool, error) {
+	doc := &Document{Format: FormatOf(filename)}
+	total := 0
+	truncated := false
+	title, err := Stream(filename, r, size, func(ch Chapter) error {
+		if maxRunes > 0 && total >= maxRunes {
+			truncated = true
+			return ErrStopImport
+		}
+		total += utf8.RuneCountInString(ch.Text)
+		doc.Chapters = append(doc.Chapters, ch)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	doc.Title = title
+	if doc.Title == "" {
+		doc.Title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return doc, truncated, nil
+}
+
+// ExtractFile 从磁盘文件提取文档
+func ExtractFile(path string, maxRunes int) (*Document, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	return Extract(path, f, info.Size(), maxRunes)
+}
+
+// Text 返回全文，章节之间以空行分隔并保留章节标题
+func (d *Document) Text() string {
+	var b strings.Builder
+	for i, ch := range d.Chapters {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		if ch.Title != "" {
+			b.WriteString(ch.Title)
+			b.WriteString("\n\n")
+		}
+		b.WriteString(ch.Text)
+	}
+	return b.String()
+}
+
+// Segments 将章节转换为原文片段，每章一个片段；段落序号在全文范围内连续编号
+func (d *Document) Segments() []models.OriginalSegment {
+	segments := make([]models.OriginalSegment, 0, len(d.Chapters))
+	paragraph := 0
+	for i, ch := range d.Chapters {
+		count := len(strings.Split(ch.Text, "\n\n"))
+		segments = append(segments, models.OriginalSegment{
+			Index:          i,
+			Title:          ch.Title,
+			Summary:        summarize(ch.Text, 160),
+			StartParagraph: paragraph,
+			EndParagraph:   paragraph + count - 1,
+			OriginalText:   ch.Text,
+		})
+		paragraph += count
+	}
+	return segments
+}
+
+// normalizeParagraphs 统一换行并整理段落，段落之间以单个空行分隔。
+// 文本中存在空行时以空行分段（段内的硬换行合并）；否则每一行视为一段（常见于中文小说）。
+func normalizeParagraphs(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var blocks []string
+	if strings.Contains(text, "\n\n") {
+		blocks = strings.Split(text, "\n\n")
+	} else {
+		blocks = strings.Split(text, "\n")
+	}
+	paragraphs := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if para := collapseSpaces(block); para != "" {
+			paragraphs = append(paragraphs, para)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// collapseSpaces 将连续空白（含全角空格、BOM）压缩为单个空格并去掉首尾空白
+func collapseSpaces(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\ufeff'
+	}), " ")
+}
+
+func summarize(text string, limit int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= limit {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:limit])) + "..."
+}
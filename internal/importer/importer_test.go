@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func extractBytes(t *testing.T, filename string, data []byte) *Document {
+	t.Helper()
+	doc, _, err := Extract(filename, bytes.NewReader(data), int64(len(data)), 0)
+	if err != nil {
+		t.Fatalf("Extract(%s): %v", filename, err)
+	}
+	return doc
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func chapterTitles(doc *Document) []string {
+	titles := make([]string, len(doc.Chapters))
+	for i, ch := range doc.Chapters {
+		titles[i] = ch.Title
+	}
+	return titles
+}
+
+func TestExtractTextChapters(t *testing.T) {
+	text := "楔子\n很久以前。\n第一章 出发\n　　他走出家门。\n天很蓝。\n第二章 归来\n他回来了。\n"
+	doc := extractBytes(t, "novel.txt", []byte(text))
+
+	if got := strings.Join(chapterTitles(doc), "|"); got != "楔子|第一章 出发|第二章 归来" {
+		t.Fatalf("titles = %s", got)
+	}
+	if doc.Chapters[1].Text != "他走出家门。\n\n天很蓝。" {
+		t.Fatalf("chapter text = %q", doc.Chapters[1].Text)
+	}
+	if doc.Title != "novel" {
+		t.Fatalf("title = %q", doc.Title)
+	}
+
+	segments := doc.Segments()
+	if len(segments) != 3 || segments[1].StartParagraph != 1 || segments[1].EndParagraph != 2 || segments[2].StartParagraph != 3 {
+		t.Fatalf("segments = %+v", segments)
+	}
+}
+
+func TestExtractMarkdownUsesBookTitle(t *testing.T) {
+	md := "# The Voyage\n\n## Chapter 1\n\nThe ship left\nat dawn.\n\nGulls followed.\n\n## Chapter 2\n\nStorms came.\n"
+	doc := extractBytes(t, "voyage.md", []byte(md))
+	if doc.Title != "The Voyage" {
+		t.Fatalf("title = %q", doc.Title)
+	}
+	if got := strings.Join(chapterTitles(doc), "|"); got != "Chapter 1|Chapter 2" {
+		t.Fatalf("titles = %s", got)
+	}
+	if doc.Chapters[0].Text != "The ship left at dawn.\n\nGulls followed." {
+		t.Fatalf("chapter text = %q", doc.Chapters[0].Text)
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	page := `<html><head><title>Tale</title><style>p{}</style></head><body>
+		<h1>Part One</h1><p>First <b>para</b>.</p><script>alert(1)</script><p>Second para.</p>
+		<h1>Part Two</h1><div>Third para.</div></body></html>`
+	doc := extractBytes(t, "tale.html", []byte(page))
+	if doc.Title != "Tale" || len(doc.Chapters) != 2 {
+		t.Fatalf("doc = %+v", doc)
+	}
+	if doc.Chapters[0].Text != "First para.\n\nSecond para." {
+		t.Fatalf("chapter text = %q", doc.Chapters[0].Text)
+	}
+}
+
+func TestExtractEPUB(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/">
+			<metadata><dc:title>Book</dc:title></metadata>
+			<manifest>
+				<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+				<item id="c1" href="text/ch%201.xhtml" media-type="application/xhtml+xml"/>
+				<item id="c2" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+			</manifest>
+			<spine toc="ncx"><itemref idref="c2"/><itemref idref="c1"/></spine>
+		</package>`,
+		"OEBPS/toc.ncx":         `<ncx><navMap><navPoint><navLabel><text>Opening</text></navLabel><content src="text/ch2.xhtml#start"/></navPoint></navMap></ncx>`,
+		"OEBPS/text/ch 1.xhtml": `<html><body><h2>Second</h2><p>Later text.</p></body></html>`,
+		"OEBPS/text/ch2.xhtml":  `<html><body><h1>Ignored heading</h1><p>Early text.</p></body></html>`,
+	})
+	doc := extractBytes(t, "book.epub", data)
+	if doc.Title != "Book" {
+		t.Fatalf("title = %q", doc.Title)
+	}
+	if got := strings.Join(chapterTitles(doc), "|"); got != "Opening|Second" {
+		t.Fatalf("titles = %s", got)
+	}
+	if doc.Chapters[0].Text != "Early text." {
+		t.Fatalf("chapter text = %q", doc.Chapters[0].Text)
+	}
+}
+
+func TestExtractDOCX(t *testing.T) {
+	const w = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	data := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + w + `><w:body>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Chapter A</w:t></w:r></w:p>
+			<w:p><w:r><w:t xml:space="preserve">Hello </w:t></w:r><w:r><w:t>world.</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Chapter B</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Bye.</w:t></w:r></w:p>
+		</w:body></w:document>`,
+	})
+	doc := extractBytes(t, "story.docx", data)
+	if got := strings.Join(chapterTitles(doc), "|"); got != "Chapter A|Chapter B" {
+		t.Fatalf("titles = %s", got)
+	}
+	if doc.Chapters[0].Text != "Hello world." {
+		t.Fatalf("chapter text = %q", doc.Chapters[0].Text)
+	}
+}
+
+func TestLongChaptersAreSplitAndTruncated(t *testing.T) {
+	para := strings.Repeat("字", 1000)
+	text := strings.Repeat(para+"\n", 100)
+	doc := extractBytes(t, "long.txt", []byte(text))
+	if len(doc.Chapters) != 4 {
+		t.Fatalf("expected 4 parts, got %d", len(doc.Chapters))
+	}
+
+	partial, truncated, err := Extract("long.txt", strings.NewReader(text), int64(len(text)), 40000)
+	if err != nil || !truncated || len(partial.Chapters) != 2 {
+		t.Fatalf("truncated extract = %d chapters, %v, %v", len(partial.Chapters), truncated, err)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	if _, _, err := Extract("a.exe", strings.NewReader("x"), 1, 0); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, _, err := Extract("empty.txt", strings.NewReader("  \n\n"), 4, 0); !errors.Is(err, ErrNoText) {
+		t.Fatalf("expected ErrNoText, got %v", err)
+	}
+	if _, _, err := Extract("bad.epub", strings.NewReader("not a zip"), 9, 0); err == nil {
+		t.Fatal("expected error for invalid epub")
+	}
+}
+
+func TestJoinPDFLines(t *testing.T) {
+	lines := []string{"Chapter 1", "The rain fell on", "the roof.", "第二章 夜", "雨一直", "下。"}
+	got := normalizeParagraphs(joinPDFLines(lines))
+	want := "Chapter 1\n\nThe rain fell on the roof.\n\n第二章 夜\n\n雨一直下。"
+	if got != want {
+		t.Fatalf("joinPDFLines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+// internal/importer/pdf.go
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// extractPDF 逐页提取带文本层的 PDF；扫描版（无文本层）会得到 ErrNoText。
+// 章节按页内的章节标题行（第X章 / Chapter N）切分，没有标题时按长度拆分。
+func extractPDF(r io.ReaderAt, size int64, emit ChapterFunc) (title string, err error) {
+	// 第三方解析器遇到损坏的文件可能 panic，这里统一转为错误
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("无效的PDF文件: %v", p)
+		}
+	}()
+
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("无效的PDF文件: %w", err)
+	}
+	title = reader.Trailer().Key("Info").Key("Title").Text()
+
+	// 按行提取（行内文本按 x 坐标排序），再把排版折行合并回段落
+	var lines []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return title, fmt.Errorf("读取PDF第%d页失败: %w", i, err)
+		}
+		for _, row := range rows {
+			var line strings.Builder
+			for _, word := range row.Content {
+				line.WriteString(word.S)
+			}
+			lines = append(lines, line.String())
+		}
+	}
+
+	blocks, err := textBlocks(strings.NewReader(joinPDFLines(lines)), false)
+	if err != nil {
+		return title, err
+	}
+	bookTitle, err := groupBlocks(blocks, "\n", emit)
+	if title == "" {
+		title = bookTitle
+	}
+	return title, err
+}
+
+// joinPDFLines 合并 PDF 的排版折行：以句末标点结尾的行结束一个段落，章节标题行单独成段
+func joinPDFLines(lines []string) string {
+	var b strings.Builder
+	inPara := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if level, _ := headingLevel(line); level > 0 {
+			b.WriteString("\n\n")
+			b.WriteString(line)
+			b.WriteString("\n\n")
+			inPara = false
+			continue
+		}
+		if inPara {
+			last, _ := utf8.DecodeLastRuneInString(b.String())
+			first, _ := utf8.DecodeRuneInString(line)
+			// 中文折行直接拼接，西文以空格连接（行尾连字符除外）
+			switch {
+			case last == '-':
+			case last < 0x2E80 || first < 0x2E80:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(line)
+		inPara = true
+		last, _ := utf8.DecodeLastRuneInString(line)
+		if strings.ContainsRune("。！？…”」』.!?\"", last) {
+			b.WriteString("\n\n")
+			inPara = false
+		}
+	}
+	return b.String()
+}
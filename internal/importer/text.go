@@ -0,0 +1,214 @@
+// internal/importer/text.go
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxChapterRunes 单个章节的最大长度；超长章节（或没有章节标记的整本书）按段落边界拆成多个部分
+const maxChapterRunes = 30000
+
+var (
+	// 中文章节标记：第一章、第12回、第三卷 ……
+	cnChapterPattern = regexp.MustCompile(`^第[0-9０-９零〇一二三四五六七八九十百千万两]+[章回节卷集部篇幕]`)
+	// 英文章节标记：Chapter 1 / CHAPTER XII / Chapter One / Part 2
+	enChapterPattern = regexp.MustCompile(`(?i)^(chapter|part|book)\s+([0-9]+|[ivxlcdm]+|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|[a-z]+teen|twenty[a-z-]*|thirty[a-z-]*)\b`)
+	// 独立成行的序章/尾声等
+	specialHeadingPattern = regexp.MustCompile(`(?i)^(序章|序言|楔子|引子|尾声|后记|番外.*|prologue|epilogue|preface|introduction|afterword)$`)
+	markdownHeading       = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*$`)
+)
+
+// block 提取出的文本块：level 为 0 表示正文，1-6 表示标题层级
+type block struct {
+	level int
+	text  string
+}
+
+// headingLevel 识别纯文本中的章节标题行，返回层级（0 表示不是标题）
+func headingLevel(line string) (int, string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || utf8.RuneCountInString(trimmed) > 50 {
+		return 0, ""
+	}
+	if m := markdownHeading.FindStringSubmatch(trimmed); m != nil {
+		return len(m[1]), m[2]
+	}
+	if cnChapterPattern.MatchString(trimmed) || enChapterPattern.MatchString(trimmed) || specialHeadingPattern.MatchString(trimmed) {
+		return 1, trimmed
+	}
+	return 0, ""
+}
+
+// textBlocks 逐行扫描纯文本，识别章节标题；正文行原样保留换行，交给 normalizeParagraphs 分段
+func textBlocks(r io.Reader, markdown bool) ([]block, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+
+	var blocks []block
+	var body strings.Builder
+	flush := func() {
+		if strings.TrimSpace(body.String()) != "" {
+			blocks = append(blocks, block{text: strings.TrimRight(body.String(), "\n")})
+		}
+		body.Reset()
+	}
+
+	inFence := false
+	for scanner.Scan() {
+		line := strings.ToValidUTF8(scanner.Text(), "")
+		if markdown && strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		level, title := 0, ""
+		if !inFence {
+			level, title = headingLevel(line)
+			// 纯文本中以 # 开头的行不一定是标题，只在 Markdown 中识别
+			if !markdown && strings.HasPrefix(strings.TrimSpace(line), "#") {
+				level = 0
+			}
+		}
+		if level > 0 {
+			flush()
+			blocks = append(blocks, block{level: level, text: title})
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return blocks, nil
+}
+
+func extractText(r io.ReaderAt, size int64, emit ChapterFunc) (string, error) {
+	blocks, err := textBlocks(io.NewSectionReader(r, 0, size), false)
+	if err != nil {
+		return "", err
+	}
+	return groupBlocks(blocks, "\n", emit)
+}
+
+func extractMarkdown(r io.ReaderAt, size int64, emit ChapterFunc) (string, error) {
+	blocks, err := textBlocks(io.NewSectionReader(r, 0, size), true)
+	if err != nil {
+		return "", err
+	}
+	return groupBlocks(blocks, "\n", emit)
+}
+
+// groupBlocks 以最高层级的标题为界把文本块归并为章节。
+// 若最高层级只有一个标题、出现在正文之前且下面还有更低层级的标题，视为书名，改用下一层级分章。
+// sep 为正文块之间的连接符：纯文本使用 "\n" 以保留原有的分段方式，结构化格式使用 "\n\n"。
+func groupBlocks(blocks []block, sep string, emit ChapterFunc) (string, error) {
+	docTitle := ""
+	splitLevel := topHeadingLevel(blocks)
+	if splitLevel > 0 {
+		count, first := 0, -1
+		for i, b := range blocks {
+			if b.level == splitLevel {
+				count++
+				if first < 0 {
+					first = i
+				}
+			}
+		}
+		if count == 1 && !hasBodyBefore(blocks, first) {
+			rest := append(blocks[:first:first], blocks[first+1:]...)
+			if next := topHeadingLevel(rest); next > 0 {
+				docTitle = blocks[first].text
+				blocks, splitLevel = rest, next
+			}
+		}
+	}
+
+	title := ""
+	var body strings.Builder
+	flush := func() error {
+		err := emitChapter(Chapter{Title: title, Text: body.String()}, emit)
+		body.Reset()
+		return err
+	}
+	for _, b := range blocks {
+		if splitLevel > 0 && b.level == splitLevel {
+			if err := flush(); err != nil {
+				return docTitle, err
+			}
+			title = b.text
+			continue
+		}
+		if body.Len() > 0 {
+			body.WriteString(sep)
+		}
+		body.WriteString(b.text)
+	}
+	return docTitle, flush()
+}
+
+func topHeadingLevel(blocks []block) int {
+	top := 0
+	for _, b := range blocks {
+		if b.level > 0 && (top == 0 || b.level < top) {
+			top = b.level
+		}
+	}
+	return top
+}
+
+func hasBodyBefore(blocks []block, idx int) bool {
+	for _, b := range blocks[:idx] {
+		if b.level == 0 && strings.TrimSpace(b.text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// emitChapter 输出章节；超过 maxChapterRunes 的章节按段落拆成「标题 (2)」「标题 (3)」……
+func emitChapter(ch Chapter, emit ChapterFunc) error {
+	text := normalizeParagraphs(ch.Text)
+	if text == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(text) <= maxChapterRunes {
+		return emit(Chapter{Title: ch.Title, Text: text})
+	}
+
+	part := 1
+	var b strings.Builder
+	n := 0
+	flush := func() error {
+		if b.Len() == 0 {
+			return nil
+		}
+		title := ch.Title
+		if part > 1 && title != "" {
+			title = fmt.Sprintf("%s (%d)", title, part)
+		}
+		part++
+		err := emit(Chapter{Title: title, Text: b.String()})
+		b.Reset()
+		n = 0
+		return err
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		pn := utf8.RuneCountInString(para)
+		if n > 0 && n+pn > maxChapterRunes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(para)
+		n += pn + 2
+	}
+	return flush()
+}
@@ -4,11 +4,37 @@ package llm
 import (
 	"context"
 	"errors"
+	"fmt"
 )
 
 // 错误定义
 var ErrUnknownProvider = errors.New("未知的AI提供者")
 
+// HTTPError 提供者 API 返回非 200 状态码时的错误。
+// StatusCode 供调用方判断是否可重试（errors.As），Error() 保持提供者原有的错误信息。
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// NewHTTPError 按 fmt.Sprintf 格式化错误信息并附带 HTTP 状态码
+func NewHTTPError(statusCode int, format string, args ...interface{}) error {
+	return &HTTPError{StatusCode: statusCode, Message: fmt.Sprintf(format, args...)}
+}
+
+// HTTPStatusCode 返回错误链中 HTTPError 的状态码；不是 HTTP 错误时返回 0
+func HTTPStatusCode(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
+
 // 请求参数标准化
 type CompletionRequest struct {
 	Prompt       string                 `json:"prompt"`
@@ -20,6 +46,8 @@ type CompletionRequest struct {
 	StopWords    []string               `json:"stop_words,omitempty"`
 	Stream       bool                   `json:"stream,omitempty"`
 	ExtraParams  map[string]interface{} `json:"extra_params,omitempty"`
+	// 要求结构化 JSON 输出；支持原生 JSON 模式的提供者据此设置请求，其余提供者忽略
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 // 响应结构标准化
@@ -39,6 +67,8 @@ type StreamResponse struct {
 	FinishReason string `json:"finish_reason,omitempty"`
 	ModelName    string `json:"model_name,omitempty"`
 	Done         bool   `json:"done"`
+	// Error FinishReason 为 "error" 时的原因（读取失败、服务端错误信息等）
+	Error string `json:"error,omitempty"`
 }
 
 // Provider 定义所有LLM提供者必须实现的接口
@@ -118,6 +148,20 @@ func Register(name string, factory ProviderFactory) {
 	providers[name] = factory
 }
 
+// 无需API密钥即可使用的提供者（本地推理服务等）
+var keylessProviders = make(map[string]bool)
+
+// RegisterKeyless 注册提供者并声明其不需要 api_key
+func RegisterKeyless(name string, factory ProviderFactory) {
+	Register(name, factory)
+	keylessProviders[name] = true
+}
+
+// RequiresAPIKey 返回指定提供者是否必须配置 api_key
+func RequiresAPIKey(name string) bool {
+	return !keylessProviders[name]
+}
+
 // GetProvider 创建指定名称的提供者实例
 func GetProvider(name string, config map[string]string) (Provider, error) {
 	factory, exists := providers[name]
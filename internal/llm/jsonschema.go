@@ -0,0 +1,330 @@
+// internal/llm/jsonschema.go
+package llm
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSchemaViolations 单次校验最多报告的问题数，避免修复提示过长
+const maxSchemaViolations = 20
+
+// SchemaViolation 结构化输出不符合 JSON Schema 的一处问题
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// Missing 为 true 表示缺少必填字段；这类问题不影响 json.Unmarshal，调用方可以只记录不重试
+	Missing bool `json:"missing,omitempty"`
+}
+
+func (v SchemaViolation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+var (
+	schemaCache sync.Map // reflect.Type -> map[string]interface{}
+
+	timeType        = reflect.TypeOf(time.Time{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SchemaFor 根据 Go 类型（按 encoding/json 的规则）生成 JSON Schema。
+// 没有 omitempty 的非指针字段视为必填；自定义 UnmarshalJSON 的类型与递归类型不做约束。
+// 返回的 map 为缓存副本的共享引用，调用方不要修改。
+func SchemaFor(v interface{}) map[string]interface{} {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if cached, ok := schemaCache.Load(t); ok {
+		return cached.(map[string]interface{})
+	}
+	schema := schemaForType(t, map[reflect.Type]bool{})
+	schemaCache.Store(t, schema)
+	return schema
+}
+
+// SchemaName 返回适合作为 response_format 名称的类型名（仅字母、数字、_、-）
+func SchemaName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	name := ""
+	if t != nil {
+		name = t.Name()
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" {
+		return "structured_output"
+	}
+	return name
+}
+
+// SchemaRootType 返回 schema 根节点的 type（object / array / ...），未声明时为空
+func SchemaRootType(schema map[string]interface{}) string {
+	t, _ := schema["type"].(string)
+	return t
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	case t.Implements(jsonUnmarshaler) || reflect.PointerTo(t).Implements(jsonUnmarshaler):
+		return map[string]interface{}{}
+	case t.Kind() != reflect.String && (t.Implements(textUnmarshaler) || reflect.PointerTo(t).Implements(textUnmarshaler)):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte 按 base64 字符串编码
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return map[string]interface{}{"type": "object"}
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]interface{}{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		var required []string
+		collectStructFields(t, visiting, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// interface{} 等：任意值
+		return map[string]interface{}{}
+	}
+}
+
+// collectStructFields 按 encoding/json 的字段规则收集属性；匿名嵌入的结构体字段提升到外层
+func collectStructFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			et := ft
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				collectStructFields(et, visiting, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, exists := properties[name]; exists {
+			continue
+		}
+
+		prop := schemaForType(ft, visiting)
+		if strings.Contains(opts, "string") {
+			// `json:",string"` 的数值/布尔字段以字符串编码
+			prop = map[string]interface{}{"type": "string"}
+		}
+		properties[name] = prop
+		if !strings.Contains(opts, "omitempty") && ft.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// DecodeJSONValue 把 JSON 文本解码为通用值（数字保留为 json.Number），供 ValidateJSON 使用
+func DecodeJSONValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected content after the top-level JSON value")
+	}
+	return value, nil
+}
+
+// ValidateJSON 按 SchemaFor 生成的 schema 校验 DecodeJSONValue 的结果。
+// null 与 json.Unmarshal 一致视为“未提供”，只在根节点报错。
+func ValidateJSON(schema map[string]interface{}, value interface{}) []SchemaViolation {
+	var out []SchemaViolation
+	if value == nil {
+		if rootType := SchemaRootType(schema); rootType != "" {
+			return []SchemaViolation{{Path: "$", Message: "expected " + rootType + ", got null"}}
+		}
+		return nil
+	}
+	validateValue(schema, value, "$", &out)
+	return out
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, out *[]SchemaViolation) {
+	if len(*out) >= maxSchemaViolations || value == nil {
+		return
+	}
+	expected, _ := schema["type"].(string)
+	if expected == "" {
+		return
+	}
+
+	if got := jsonTypeOf(value); !typeMatches(expected, value) {
+		*out = append(*out, SchemaViolation{Path: path, Message: "expected " + expected + ", got " + got})
+		return
+	}
+
+	switch expected {
+	case "object":
+		obj := value.(map[string]interface{})
+		properties, _ := schema["properties"].(map[string]interface{})
+		if len(properties) > 0 && len(obj) > 0 && !hasAnyProperty(obj, properties) {
+			// 常见于模型把结果包了一层（如 {"characters": [...]}），按结构错误处理
+			*out = append(*out, SchemaViolation{Path: path, Message: "object has none of the expected properties (" + strings.Join(sortedKeys(properties), ", ") + ")"})
+			return
+		}
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, exists := obj[name]; !exists && len(*out) < maxSchemaViolations {
+					*out = append(*out, SchemaViolation{Path: path, Message: fmt.Sprintf("missing required property %q", name), Missing: true})
+				}
+			}
+		}
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for _, k := range sortedKeys(obj) {
+			if prop, ok := properties[k].(map[string]interface{}); ok {
+				validateValue(prop, obj[k], path+"."+k, out)
+			} else if additional != nil {
+				validateValue(additional, obj[k], path+"."+k, out)
+			}
+		}
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		if items == nil {
+			return
+		}
+		for i, item := range value.([]interface{}) {
+			validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), out)
+		}
+	}
+}
+
+func hasAnyProperty(obj map[string]interface{}, properties map[string]interface{}) bool {
+	for k := range obj {
+		if _, ok := properties[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeMatches(expected string, value interface{}) bool {
+	switch expected {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	}
+	return true
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+type schemaTestItem struct {
+	Name  string            `json:"name"`
+	Count int               `json:"count"`
+	Tags  []string          `json:"tags,omitempty"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+	Note  *string           `json:"note"`
+	Skip  string            `json:"-"`
+}
+
+type schemaTestOutput struct {
+	Title string           `json:"title"`
+	Items []schemaTestItem `json:"items"`
+}
+
+func TestSchemaFor_StructFields(t *testing.T) {
+	schema := SchemaFor(&schemaTestOutput{})
+	if SchemaRootType(schema) != "object" {
+		t.Fatalf("root type = %v", schema["type"])
+	}
+	items := schema["properties"].(map[string]interface{})["items"].(map[string]interface{})
+	item := items["items"].(map[string]interface{})
+	props := item["properties"].(map[string]interface{})
+	if _, ok := props["Skip"]; ok {
+		t.Fatalf("json:\"-\" field should be skipped")
+	}
+	if got := props["count"].(map[string]interface{})["type"]; got != "integer" {
+		t.Fatalf("count type = %v", got)
+	}
+	required := strings.Join(item["required"].([]string), ",")
+	if required != "count,name" {
+		t.Fatalf("required = %q, want omitempty and pointer fields to be optional", required)
+	}
+	if SchemaName([]schemaTestItem{}) != "schemaTestItem" {
+		t.Fatalf("schema name = %q", SchemaName([]schemaTestItem{}))
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	schema := SchemaFor(&schemaTestOutput{})
+
+	cases := []struct {
+		name        string
+		body        string
+		wantHard    int
+		wantMissing int
+	}{
+		{"valid", `{"title":"a","items":[{"name":"x","count":2,"tags":["t"]}]}`, 0, 0},
+		{"missing fields are soft", `{"title":"a","items":[{"name":"x"}]}`, 0, 1},
+		{"wrong types", `{"title":1,"items":[{"name":"x","count":1.5,"tags":"t"}]}`, 3, 0},
+		{"null is allowed", `{"title":null,"items":null}`, 0, 0},
+		{"wrapped result", `{"result":{"title":"a"}}`, 1, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := DecodeJSONValue([]byte(tc.body))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			hard, missing := 0, 0
+			for _, v := range ValidateJSON(schema, value) {
+				if v.Missing {
+					missing++
+				} else {
+					hard++
+				}
+			}
+			if hard != tc.wantHard || missing != tc.wantMissing {
+				t.Fatalf("hard=%d missing=%d, want %d/%d: %v", hard, missing, tc.wantHard, tc.wantMissing, ValidateJSON(schema, value))
+			}
+		})
+	}
+
+	if v := ValidateJSON(schema, nil); len(v) != 1 {
+		t.Fatalf("root null should be reported, got %v", v)
+	}
+	if _, err := DecodeJSONValue([]byte(`{"a":1} trailing`)); err == nil {
+		t.Fatalf("expected error for trailing content")
+	}
+}
+
+func TestApplyResponseFormat(t *testing.T) {
+	format := &ResponseFormat{Name: "out", Schema: SchemaFor(&schemaTestOutput{})}
+
+	body := map[string]interface{}{}
+	ApplyResponseFormat("openai", body, format)
+	rf, _ := body["response_format"].(map[string]interface{})
+	if rf["type"] != "json_schema" {
+		t.Fatalf("openai response_format = %v", body["response_format"])
+	}
+
+	body = map[string]interface{}{}
+	ApplyResponseFormat("deepseek", body, &ResponseFormat{Schema: SchemaFor([]schemaTestItem{})})
+	if _, ok := body["response_format"]; ok {
+		t.Fatalf("array-root schema should not enable json_object mode")
+	}
+
+	body = map[string]interface{}{"generationConfig": map[string]interface{}{"temperature": 0.3}}
+	ApplyResponseFormat("google", body, format)
+	if body["generationConfig"].(map[string]interface{})["responseMimeType"] != "application/json" {
+		t.Fatalf("google generationConfig = %v", body["generationConfig"])
+	}
+
+	body = map[string]interface{}{}
+	ApplyResponseFormat("anthropic", body, format)
+	if len(body) != 0 {
+		t.Fatalf("unsupported provider should be left untouched: %v", body)
+	}
+}
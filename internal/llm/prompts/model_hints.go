@@ -23,7 +23,7 @@ func GetModelHints(modelKey string) ModelHints {
 	}
 
 	switch k {
-	case "sd", "sdwebui":
+	case "sd", "sdwebui", "comfyui":
 		return ModelHints{
 			PromptRules:        "- Prefer Stable Diffusion-style keywords and composition tokens; avoid Midjourney flag syntax (no --stylize/--chaos).\n- If a reference image will be used (img2img), keep identity-consistent descriptors for the main subject.",
 			NegativePromptHint: "low quality, blurry, watermark, text, logo, extra fingers, bad anatomy",
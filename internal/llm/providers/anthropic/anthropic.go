@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -109,7 +108,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 	// 检查响应
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -204,7 +203,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 	// 检查错误
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("anthropic api错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "anthropic api错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -319,7 +318,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("anthropic api错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "anthropic api错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
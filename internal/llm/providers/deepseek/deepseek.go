@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -110,7 +109,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 	// 检查响应
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -188,6 +187,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 		requestBody[k] = v
 	}
 	llm.ApplyReasoningDefaults("deepseek", requestBody, model, reasoningEnabled)
+	llm.ApplyResponseFormat("deepseek", requestBody, req.ResponseFormat)
 
 	// 序列化JSON
 	jsonData, err := json.Marshal(requestBody)
@@ -222,7 +222,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 	// 检查错误
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("DeepSeek API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "DeepSeek API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -309,6 +309,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 		requestBody[k] = v
 	}
 	llm.ApplyReasoningDefaults("deepseek", requestBody, model, reasoningEnabled)
+	llm.ApplyResponseFormat("deepseek", requestBody, req.ResponseFormat)
 
 	// 序列化JSON
 	jsonData, err := json.Marshal(requestBody)
@@ -344,7 +345,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("DeepSeek API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "DeepSeek API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
@@ -371,6 +372,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 							FinishReason: "error",
 							ModelName:    model,
 							Done:         true,
+							Error:        err.Error(),
 						}
 					}
 					return
@@ -152,7 +152,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -264,7 +264,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("GitHub Models API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "GitHub Models API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -389,7 +389,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
 		httpResp.Body.Close()
-		return nil, fmt.Errorf("GitHub Models API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "GitHub Models API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
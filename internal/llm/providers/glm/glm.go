@@ -200,7 +200,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 	// 检查错误
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("智谱GLM API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "智谱GLM API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -326,7 +326,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("智谱GLM API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "智谱GLM API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
@@ -353,6 +353,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 							FinishReason: "error",
 							ModelName:    model,
 							Done:         true,
+							Error:        err.Error(),
 						}
 					}
 					return
@@ -134,6 +134,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 		}
 	}
 	llm.ApplyReasoningDefaults("google", requestBody, model, reasoningEnabled)
+	llm.ApplyResponseFormat("google", requestBody, req.ResponseFormat)
 
 	// 序列化JSON
 	jsonData, err := json.Marshal(requestBody)
@@ -170,11 +171,11 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 		body, _ := io.ReadAll(httpResp.Body)
 		if err := json.Unmarshal(body, &errorResp); err == nil {
 			if errorObj, ok := errorResp["error"].(map[string]interface{}); ok {
-				return nil, fmt.Errorf("google gemini API错误(%d): %v",
+				return nil, llm.NewHTTPError(httpResp.StatusCode, "google gemini API错误(%d): %v",
 					httpResp.StatusCode, errorObj["message"])
 			}
 		}
-		return nil, fmt.Errorf("google gemini API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "google gemini API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -268,6 +269,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 		}
 	}
 	llm.ApplyReasoningDefaults("google", requestBody, model, reasoningEnabled)
+	llm.ApplyResponseFormat("google", requestBody, req.ResponseFormat)
 
 	// 序列化JSON
 	jsonData, err := json.Marshal(requestBody)
@@ -301,7 +303,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("google gemini API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "google gemini API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
@@ -408,7 +410,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 	// 检查响应
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -105,7 +104,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 	// 检查响应
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应 - 注意：这里的响应结构可能需要根据实际grok api调整
@@ -210,11 +209,11 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 		body, _ := io.ReadAll(httpResp.Body)
 		if err := json.Unmarshal(body, &errorResp); err == nil {
 			if errorObj, ok := errorResp["error"].(map[string]interface{}); ok {
-				return nil, fmt.Errorf("grok api错误(%d): %v",
+				return nil, llm.NewHTTPError(httpResp.StatusCode, "grok api错误(%d): %v",
 					httpResp.StatusCode, errorObj["message"])
 			}
 		}
-		return nil, fmt.Errorf("grok api错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "grok api错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应 - 这里的结构可能需要根据实际grok api调整
@@ -331,7 +330,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("grok api错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "grok api错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
@@ -6,7 +6,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -96,7 +95,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	var response struct {
@@ -179,7 +178,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("NVIDIA API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "NVIDIA API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	var response struct {
@@ -276,7 +275,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		defer httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("NVIDIA API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "NVIDIA API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	respChan := make(chan llm.StreamResponse)
@@ -297,7 +296,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 				line, err := reader.ReadString('\n')
 				if err != nil {
 					if err != io.EOF {
-						respChan <- llm.StreamResponse{Done: true, FinishReason: "error", ModelName: modelName}
+						respChan <- llm.StreamResponse{Done: true, FinishReason: "error", ModelName: modelName, Error: err.Error()}
 					}
 					return
 				}
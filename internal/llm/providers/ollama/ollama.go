@@ -0,0 +1,481 @@
+// internal/llm/providers/ollama/ollama.go
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+)
+
+// 接口格式
+const (
+	FormatOllama = "ollama" // Ollama 原生 /api/chat
+	FormatOpenAI = "openai" // OpenAI 兼容 /v1/chat/completions（llama.cpp server、vLLM、LM Studio 等）
+)
+
+func init() {
+	// ollama：默认连接本机 Ollama 的原生接口
+	llm.RegisterKeyless("ollama", func() llm.Provider {
+		return &Provider{
+			name:    "Ollama",
+			baseURL: "http://localhost:11434",
+			format:  FormatOllama,
+			recommendedModels: []string{
+				"llama3.1",
+				"qwen2.5",
+			},
+		}
+	})
+	// local：任意 OpenAI 兼容的本地服务
+	llm.RegisterKeyless("local", func() llm.Provider {
+		return &Provider{
+			name:    "Local",
+			baseURL: "http://localhost:11434/v1",
+			format:  FormatOpenAI,
+		}
+	})
+}
+
+// Provider 连接本地推理服务，api_key 可选（部分 llama.cpp / vLLM 部署会要求 Bearer 令牌）
+type Provider struct {
+	name              string
+	apiKey            string
+	baseURL           string
+	format            string
+	client            *http.Client
+	defaultModel      string
+	recommendedModels []string
+	availableModels   []string
+}
+
+func (p *Provider) Initialize(config map[string]string) error {
+	p.apiKey = strings.TrimSpace(config["api_key"])
+	p.client = &http.Client{}
+
+	if baseURL := strings.TrimSpace(config["base_url"]); baseURL != "" {
+		p.baseURL = baseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	if p.baseURL == "" {
+		return errors.New("本地模型服务地址(base_url)未提供")
+	}
+
+	switch format := strings.ToLower(strings.TrimSpace(config["api_format"])); format {
+	case "":
+		// 未指定时按地址推断：以 /v1 结尾视为 OpenAI 兼容接口
+		if strings.HasSuffix(p.baseURL, "/v1") {
+			p.format = FormatOpenAI
+		}
+	case FormatOllama, FormatOpenAI:
+		p.format = format
+	default:
+		return fmt.Errorf("不支持的接口格式: %s", format)
+	}
+
+	if model := strings.TrimSpace(config["default_model"]); model != "" {
+		p.defaultModel = model
+	} else if model := strings.TrimSpace(config["model"]); model != "" {
+		p.defaultModel = model
+	} else if len(p.recommendedModels) > 0 {
+		p.defaultModel = p.recommendedModels[0]
+	} else {
+		// local 没有推荐模型，未指定时调用方会退回到其他提供商的默认模型名
+		return fmt.Errorf("%s 提供者需要配置 default_model", p.name)
+	}
+
+	// 如果配置中包含自定义模型列表
+	if customModels, exists := config["custom_models"]; exists && customModels != "" {
+		var models []string
+		if err := json.Unmarshal([]byte(customModels), &models); err == nil && len(models) > 0 {
+			p.availableModels = models
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) GetName() string {
+	return p.name
+}
+
+func (p *Provider) GetSupportedModels() []string {
+	if len(p.availableModels) > 0 {
+		return p.availableModels
+	}
+	if len(p.recommendedModels) > 0 {
+		return p.recommendedModels
+	}
+	if p.defaultModel != "" {
+		return []string{p.defaultModel}
+	}
+	return []string{}
+}
+
+// FetchAvailableModels 从本地服务获取已安装/已加载的模型
+func (p *Provider) FetchAvailableModels(ctx context.Context) error {
+	path := "/models"
+	if p.format == FormatOllama {
+		path = "/api/tags"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	p.setAuth(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+
+	models := make([]string, 0, len(response.Models)+len(response.Data))
+	for _, m := range response.Models {
+		if m.Name != "" {
+			models = append(models, m.Name)
+		}
+	}
+	for _, m := range response.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	if len(models) > 0 {
+		p.availableModels = models
+	}
+
+	return nil
+}
+
+// SetCustomModels 设置自定义模型列表
+func (p *Provider) SetCustomModels(models []string) {
+	if len(models) > 0 {
+		p.availableModels = models
+	}
+}
+
+func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	model, httpReq, err := p.newChatRequest(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "%s API错误(%d): %s", p.name, httpResp.StatusCode, string(body))
+	}
+
+	if p.format == FormatOllama {
+		var response ollamaChatChunk
+		if err := json.NewDecoder(httpResp.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("%s API错误: %s", p.name, response.Error)
+		}
+		if response.Model == "" {
+			response.Model = model
+		}
+		return &llm.CompletionResponse{
+			Text:         response.Message.Content,
+			FinishReason: response.DoneReason,
+			TokensUsed:   response.PromptEvalCount + response.EvalCount,
+			PromptTokens: response.PromptEvalCount,
+			OutputTokens: response.EvalCount,
+			ModelName:    response.Model,
+			ProviderName: p.GetName(),
+		}, nil
+	}
+
+	var response struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("%s未返回任何结果", p.name)
+	}
+	if response.Model == "" {
+		response.Model = model
+	}
+
+	return &llm.CompletionResponse{
+		Text:         response.Choices[0].Message.Content,
+		FinishReason: response.Choices[0].FinishReason,
+		TokensUsed:   response.Usage.TotalTokens,
+		PromptTokens: response.Usage.PromptTokens,
+		OutputTokens: response.Usage.CompletionTokens,
+		ModelName:    response.Model,
+		ProviderName: p.GetName(),
+	}, nil
+}
+
+// StreamCompletion 实现流式响应：Ollama 原生接口为逐行 JSON，OpenAI 兼容接口为 SSE
+func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionRequest) (<-chan llm.StreamResponse, error) {
+	model, httpReq, err := p.newChatRequest(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "%s API错误(%d): %s", p.name, httpResp.StatusCode, string(body))
+	}
+
+	respChan := make(chan llm.StreamResponse)
+
+	go func() {
+		defer httpResp.Body.Close()
+		defer close(respChan)
+
+		reader := bufio.NewReader(httpResp.Body)
+		var contentBuffer strings.Builder
+
+		send := func(resp llm.StreamResponse) bool {
+			select {
+			case respChan <- resp:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		finish := func(reason string) {
+			send(llm.StreamResponse{
+				Text:         contentBuffer.String(),
+				FinishReason: reason,
+				ModelName:    model,
+				Done:         true,
+			})
+		}
+		fail := func(cause string) {
+			send(llm.StreamResponse{
+				Text:         contentBuffer.String(),
+				FinishReason: "error",
+				ModelName:    model,
+				Done:         true,
+				Error:        cause,
+			})
+		}
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				if err == io.EOF {
+					finish("stop")
+				} else {
+					fail(err.Error())
+				}
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, ":") {
+				continue
+			}
+
+			var content string
+			var doneReason *string
+
+			if p.format == FormatOllama {
+				var chunk ollamaChatChunk
+				if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+					continue
+				}
+				if chunk.Error != "" {
+					fail(chunk.Error)
+					return
+				}
+				content = chunk.Message.Content
+				if chunk.Done {
+					reason := chunk.DoneReason
+					if reason == "" {
+						reason = "stop"
+					}
+					doneReason = &reason
+				}
+			} else {
+				line = strings.TrimPrefix(line, "data:")
+				line = strings.TrimSpace(line)
+				if line == "[DONE]" {
+					finish("stop")
+					return
+				}
+				var chunk struct {
+					Choices []struct {
+						Delta struct {
+							Content string `json:"content"`
+						} `json:"delta"`
+						FinishReason *string `json:"finish_reason"`
+					} `json:"choices"`
+				}
+				if err := json.Unmarshal([]byte(line), &chunk); err != nil || len(chunk.Choices) == 0 {
+					continue
+				}
+				content = chunk.Choices[0].Delta.Content
+				doneReason = chunk.Choices[0].FinishReason
+			}
+
+			if content != "" {
+				contentBuffer.WriteString(content)
+				if !send(llm.StreamResponse{Text: content, ModelName: model}) {
+					return
+				}
+			}
+			if doneReason != nil {
+				finish(*doneReason)
+				return
+			}
+		}
+	}()
+
+	return respChan, nil
+}
+
+// ollamaChatChunk 对应 /api/chat 的响应（非流式为单个对象，流式为逐行对象）
+type ollamaChatChunk struct {
+	Model   string `json:"model"`
+	Message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// newChatRequest 按接口格式构建聊天请求
+func (p *Provider) newChatRequest(ctx context.Context, req llm.CompletionRequest, stream bool) (string, *http.Request, error) {
+	model := req.Model
+	if model == "" {
+		model = p.defaultModel
+	}
+	if model == "" {
+		return "", nil, fmt.Errorf("%s 未配置模型", p.name)
+	}
+	model, extraParams, _ := llm.NormalizeReasoningRequest("ollama", model, req.ExtraParams)
+
+	messages := []map[string]string{
+		{"role": "user", "content": req.Prompt},
+	}
+	if req.SystemPrompt != "" {
+		messages = append([]map[string]string{
+			{"role": "system", "content": req.SystemPrompt},
+		}, messages...)
+	}
+
+	requestBody := map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+		"stream":   stream,
+	}
+
+	path := "/chat/completions"
+	if p.format == FormatOllama {
+		path = "/api/chat"
+		// 原生接口的采样参数放在 options 中
+		options := map[string]interface{}{
+			"temperature": req.Temperature,
+		}
+		if req.MaxTokens > 0 {
+			options["num_predict"] = req.MaxTokens
+		}
+		if req.TopP > 0 {
+			options["top_p"] = req.TopP
+		}
+		if len(req.StopWords) > 0 {
+			options["stop"] = req.StopWords
+		}
+		requestBody["options"] = options
+	} else {
+		requestBody["temperature"] = req.Temperature
+		if req.MaxTokens > 0 {
+			requestBody["max_tokens"] = req.MaxTokens
+		}
+		if req.TopP > 0 {
+			requestBody["top_p"] = req.TopP
+		}
+		if len(req.StopWords) > 0 {
+			requestBody["stop"] = req.StopWords
+		}
+	}
+
+	// 添加任何额外参数
+	for k, v := range extraParams {
+		requestBody[k] = v
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream && p.format == FormatOpenAI {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	p.setAuth(httpReq)
+
+	return model, httpReq, nil
+}
+
+func (p *Provider) setAuth(req *http.Request) {
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+}
@@ -0,0 +1,136 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+)
+
+func TestOllamaProvider_NativeChatAndModels(t *testing.T) {
+	var gotBody map[string]interface{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"llama3.1:8b"},{"name":"qwen2.5:7b"}]}`))
+		case "/api/chat":
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &gotBody)
+			if gotBody["stream"] == true {
+				_, _ = w.Write([]byte(`{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hel"},"done":false}` + "\n"))
+				_, _ = w.Write([]byte(`{"model":"llama3.1:8b","message":{"role":"assistant","content":"lo"},"done":false}` + "\n"))
+				_, _ = w.Write([]byte(`{"model":"llama3.1:8b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}` + "\n"))
+				return
+			}
+			_, _ = w.Write([]byte(`{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hello"},"done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":2}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if llm.RequiresAPIKey("ollama") {
+		t.Fatalf("expected ollama to be keyless")
+	}
+
+	p, err := llm.GetProvider("ollama", map[string]string{"base_url": srv.URL, "default_model": "llama3.1:8b"})
+	if err != nil {
+		t.Fatalf("GetProvider error: %v", err)
+	}
+
+	if err := p.FetchAvailableModels(context.Background()); err != nil {
+		t.Fatalf("FetchAvailableModels error: %v", err)
+	}
+	if models := p.GetSupportedModels(); len(models) != 2 || models[0] != "llama3.1:8b" {
+		t.Fatalf("unexpected models: %v", models)
+	}
+
+	resp, err := p.CompleteText(context.Background(), llm.CompletionRequest{Prompt: "hi", SystemPrompt: "sys", MaxTokens: 16, Temperature: 0.2})
+	if err != nil {
+		t.Fatalf("CompleteText error: %v", err)
+	}
+	if resp.Text != "Hello" || resp.TokensUsed != 7 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	options, _ := gotBody["options"].(map[string]interface{})
+	if options["num_predict"] != float64(16) {
+		t.Fatalf("expected num_predict in options, got=%v", gotBody["options"])
+	}
+	if msgs, _ := gotBody["messages"].([]interface{}); len(msgs) != 2 {
+		t.Fatalf("expected system+user messages, got=%v", gotBody["messages"])
+	}
+
+	stream, err := p.StreamCompletion(context.Background(), llm.CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("StreamCompletion error: %v", err)
+	}
+	var deltas []string
+	var final llm.StreamResponse
+	for chunk := range stream {
+		if chunk.Done {
+			final = chunk
+			continue
+		}
+		deltas = append(deltas, chunk.Text)
+	}
+	if strings.Join(deltas, "") != "Hello" || final.Text != "Hello" || final.FinishReason != "stop" {
+		t.Fatalf("unexpected stream: deltas=%v final=%+v", deltas, final)
+	}
+}
+
+func TestLocalProvider_OpenAICompatibleStream(t *testing.T) {
+	var gotAuth, gotPath string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		if r.URL.Path == "/v1/models" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"id":"local-gguf"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"你\"}}]}\n\n"))
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"好\"},\"finish_reason\":\"stop\"}]}\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	p, err := llm.GetProvider("local", map[string]string{"base_url": srv.URL + "/v1", "api_key": "secret", "default_model": "local-gguf"})
+	if err != nil {
+		t.Fatalf("GetProvider error: %v", err)
+	}
+	if err := p.FetchAvailableModels(context.Background()); err != nil {
+		t.Fatalf("FetchAvailableModels error: %v", err)
+	}
+	if models := p.GetSupportedModels(); len(models) != 1 || models[0] != "local-gguf" {
+		t.Fatalf("unexpected models: %v", models)
+	}
+
+	stream, err := p.StreamCompletion(context.Background(), llm.CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("StreamCompletion error: %v", err)
+	}
+	var final llm.StreamResponse
+	for chunk := range stream {
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	if final.Text != "你好" || final.FinishReason != "stop" {
+		t.Fatalf("unexpected final chunk: %+v", final)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Fatalf("expected /v1/chat/completions, got=%q", gotPath)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Fatalf("expected bearer auth, got=%q", gotAuth)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -105,7 +104,7 @@ func (p *Provider) FetchAvailableModels(ctx context.Context) error {
 	// 检查响应
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("获取模型列表失败(%d): %s", resp.StatusCode, string(body))
+		return llm.NewHTTPError(resp.StatusCode, "获取模型列表失败(%d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -184,6 +183,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 		requestBody[k] = v
 	}
 	llm.ApplyReasoningDefaults("openai", requestBody, model, reasoningEnabled)
+	llm.ApplyResponseFormat("openai", requestBody, req.ResponseFormat)
 
 	// 序列化JSON
 	jsonData, err := json.Marshal(requestBody)
@@ -218,11 +218,11 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 		body, _ := io.ReadAll(httpResp.Body)
 		if err := json.Unmarshal(body, &errorResp); err == nil {
 			if errorObj, ok := errorResp["error"].(map[string]interface{}); ok {
-				return nil, fmt.Errorf("OpenAI API错误(%d): %v",
+				return nil, llm.NewHTTPError(httpResp.StatusCode, "OpenAI API错误(%d): %v",
 					httpResp.StatusCode, errorObj["message"])
 			}
 		}
-		return nil, fmt.Errorf("OpenAI API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "OpenAI API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析响应
@@ -307,6 +307,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 		requestBody[k] = v
 	}
 	llm.ApplyReasoningDefaults("openai", requestBody, model, reasoningEnabled)
+	llm.ApplyResponseFormat("openai", requestBody, req.ResponseFormat)
 
 	// 序列化JSON
 	jsonData, err := json.Marshal(requestBody)
@@ -340,9 +341,9 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 		body, readErr := io.ReadAll(httpResp.Body)
 		httpResp.Body.Close() // Close the body after reading
 		if readErr != nil {
-			return nil, fmt.Errorf("OpenAI API错误(%d), 读取响应失败: %v", httpResp.StatusCode, readErr)
+			return nil, llm.NewHTTPError(httpResp.StatusCode, "OpenAI API错误(%d), 读取响应失败: %v", httpResp.StatusCode, readErr)
 		}
-		return nil, fmt.Errorf("OpenAI API错误(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "OpenAI API错误(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
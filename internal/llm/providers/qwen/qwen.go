@@ -176,7 +176,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 	if httpResp.StatusCode != http.StatusOK {
 		httpResp.Body.Close()
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("qwen API Error(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "qwen API Error(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 创建响应通道
@@ -203,6 +203,7 @@ func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionReque
 							FinishReason: "error",
 							ModelName:    model,
 							Done:         true,
+							Error:        err.Error(),
 						}
 					}
 					return
@@ -373,7 +374,7 @@ func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest)
 	// 检查错误
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("qwen API Error(%d): %s", httpResp.StatusCode, string(body))
+		return nil, llm.NewHTTPError(httpResp.StatusCode, "qwen API Error(%d): %s", httpResp.StatusCode, string(body))
 	}
 
 	// 解析OpenAI兼容的响应
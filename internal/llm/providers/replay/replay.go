@@ -0,0 +1,349 @@
+// internal/llm/providers/replay/replay.go
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+)
+
+// 回放流式响应时每个分片的字符数
+const streamChunkRunes = 16
+
+func init() {
+	// replay：录制 / 回放 LLM 请求，用于离线测试与演示；可选的上游提供者负责真实调用
+	llm.RegisterKeyless("replay", func() llm.Provider {
+		return &Provider{}
+	})
+}
+
+// Provider 按规范化请求哈希从录制文件(cassette)中提供响应
+//
+// 配置项：
+//   - cassette：录制文件路径，默认 $DATA_DIR/cassettes/llm.json
+//   - mode：replay（默认，未命中时转发上游并录制）/ record（总是调用上游并重新录制）/ strict（未命中即报错）
+//   - upstream：真实提供者名称；其余配置项原样传给上游
+//   - ignore_patterns：计算哈希前从提示词中屏蔽的正则（JSON 数组），如时间戳、随机 ID
+type Provider struct {
+	cassette     *cassette.Cassette
+	mode         cassette.Mode
+	upstream     llm.Provider
+	upstreamName string
+	normalizer   *cassette.Normalizer
+	defaultModel string
+	customModels []string
+}
+
+// recordedRequest 参与哈希的规范化请求
+type recordedRequest struct {
+	Model          string                 `json:"model"`
+	SystemPrompt   string                 `json:"system_prompt,omitempty"`
+	Prompt         string                 `json:"prompt"`
+	MaxTokens      int                    `json:"max_tokens,omitempty"`
+	Temperature    float32                `json:"temperature,omitempty"`
+	TopP           float32                `json:"top_p,omitempty"`
+	StopWords      []string               `json:"stop_words,omitempty"`
+	ExtraParams    map[string]interface{} `json:"extra_params,omitempty"`
+	ResponseFormat string                 `json:"response_format,omitempty"`
+}
+
+func (p *Provider) Initialize(config map[string]string) error {
+	mode, err := cassette.ParseMode(config["mode"])
+	if err != nil {
+		return err
+	}
+	p.mode = mode
+
+	patterns, err := cassette.ParsePatterns(config["ignore_patterns"])
+	if err != nil {
+		return err
+	}
+	if p.normalizer, err = cassette.NewNormalizer(patterns); err != nil {
+		return err
+	}
+
+	path := DefaultCassettePath()
+	if name := strings.TrimSpace(config["cassette"]); name != "" {
+		// 录制会整体重写该文件，只允许 $DATA_DIR/cassettes 下的相对路径
+		if path, err = cassette.ResolvePath(CassetteDir(), name); err != nil {
+			return err
+		}
+	}
+	if p.cassette, err = cassette.Open(path, "llm"); err != nil {
+		return err
+	}
+	if p.mode == cassette.ModeStrict && !p.cassette.Exists() {
+		return fmt.Errorf("strict 模式下录制文件不存在: %s", path)
+	}
+
+	if model := strings.TrimSpace(config["default_model"]); model != "" {
+		p.defaultModel = model
+	} else if model := strings.TrimSpace(config["model"]); model != "" {
+		p.defaultModel = model
+	}
+
+	p.upstreamName = strings.TrimSpace(config["upstream"])
+	if p.upstreamName == "replay" {
+		return errors.New("replay 的上游提供者不能是 replay")
+	}
+	if p.upstreamName == "" {
+		if p.mode == cassette.ModeRecord {
+			return errors.New("record 模式需要配置上游提供者(upstream)")
+		}
+		return nil
+	}
+	if p.mode == cassette.ModeStrict {
+		// strict 模式从不调用上游，无需初始化（也无需上游密钥）
+		return nil
+	}
+
+	upstreamConfig := make(map[string]string, len(config))
+	for k, v := range config {
+		switch k {
+		case "cassette", "mode", "upstream", "ignore_patterns":
+			continue
+		}
+		upstreamConfig[k] = v
+	}
+	upstream, err := llm.GetProvider(p.upstreamName, upstreamConfig)
+	if err != nil {
+		return fmt.Errorf("初始化上游提供者 %s 失败: %w", p.upstreamName, err)
+	}
+	p.upstream = upstream
+	return nil
+}
+
+// CassetteDir 录制文件所在目录 $DATA_DIR/cassettes
+func CassetteDir() string {
+	dataDir := strings.TrimSpace(os.Getenv("DATA_DIR"))
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	return filepath.Join(dataDir, "cassettes")
+}
+
+// DefaultCassettePath 默认录制文件位置，跟随 DATA_DIR
+func DefaultCassettePath() string {
+	return filepath.Join(CassetteDir(), "llm.json")
+}
+
+func (p *Provider) GetName() string {
+	if p.upstreamName != "" {
+		return "Replay(" + p.upstreamName + ")"
+	}
+	return "Replay"
+}
+
+func (p *Provider) GetSupportedModels() []string {
+	if len(p.customModels) > 0 {
+		return p.customModels
+	}
+	if p.upstream != nil {
+		if models := p.upstream.GetSupportedModels(); len(models) > 0 {
+			return models
+		}
+	}
+
+	// 没有上游时返回录制文件中出现过的模型
+	seen := make(map[string]bool)
+	models := []string{}
+	if p.defaultModel != "" {
+		seen[p.defaultModel] = true
+		models = append(models, p.defaultModel)
+	}
+	var recorded []string
+	for _, it := range p.cassette.Interactions() {
+		var req recordedRequest
+		if err := json.Unmarshal(it.Request, &req); err == nil && req.Model != "" && !seen[req.Model] {
+			seen[req.Model] = true
+			recorded = append(recorded, req.Model)
+		}
+	}
+	sort.Strings(recorded)
+	return append(models, recorded...)
+}
+
+func (p *Provider) FetchAvailableModels(ctx context.Context) error {
+	if p.upstream != nil {
+		return p.upstream.FetchAvailableModels(ctx)
+	}
+	return nil
+}
+
+func (p *Provider) SetCustomModels(models []string) {
+	p.customModels = models
+	if p.upstream != nil {
+		p.upstream.SetCustomModels(models)
+	}
+}
+
+func (p *Provider) CompleteText(ctx context.Context, req llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	key, normalized, err := p.requestKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.mode != cassette.ModeRecord {
+		if resp, ok, err := p.lookup(key); err != nil || ok {
+			return resp, err
+		}
+		if p.mode == cassette.ModeStrict || p.upstream == nil {
+			return nil, p.missError(normalized)
+		}
+	}
+
+	resp, err := p.upstream.CompleteText(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.cassette.Record(key, normalized, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (p *Provider) StreamCompletion(ctx context.Context, req llm.CompletionRequest) (<-chan llm.StreamResponse, error) {
+	key, normalized, err := p.requestKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.mode != cassette.ModeRecord {
+		resp, ok, err := p.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return replayStream(ctx, resp), nil
+		}
+		if p.mode == cassette.ModeStrict || p.upstream == nil {
+			return nil, p.missError(normalized)
+		}
+	}
+
+	upstream, err := p.upstream.StreamCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return p.recordStream(ctx, key, normalized, upstream), nil
+}
+
+func (p *Provider) requestKey(req llm.CompletionRequest) (string, recordedRequest, error) {
+	model := req.Model
+	if model == "" {
+		model = p.defaultModel
+	}
+	normalized := recordedRequest{
+		Model:        model,
+		SystemPrompt: p.normalizer.Text(req.SystemPrompt),
+		Prompt:       p.normalizer.Text(req.Prompt),
+		MaxTokens:    req.MaxTokens,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		StopWords:    req.StopWords,
+		ExtraParams:  req.ExtraParams,
+	}
+	if req.ResponseFormat != nil {
+		normalized.ResponseFormat = req.ResponseFormat.Name
+	}
+	key, err := cassette.Key(normalized)
+	return key, normalized, err
+}
+
+func (p *Provider) lookup(key string) (*llm.CompletionResponse, bool, error) {
+	data, ok := p.cassette.Lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	var resp llm.CompletionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false, fmt.Errorf("录制文件 %s 中的响应无法解析: %w", p.cassette.Path(), err)
+	}
+	return &resp, true, nil
+}
+
+func (p *Provider) missError(req recordedRequest) error {
+	return fmt.Errorf("%w (cassette=%s, model=%s, prompt=%q)", cassette.ErrNoMatch,
+		p.cassette.Path(), req.Model, cassette.Describe(req.Prompt, 80))
+}
+
+// replayStream 将录制的完整文本切片后按流式协议发送，最后一条消息携带完整文本
+func replayStream(ctx context.Context, resp *llm.CompletionResponse) <-chan llm.StreamResponse {
+	respChan := make(chan llm.StreamResponse)
+
+	go func() {
+		defer close(respChan)
+
+		send := func(msg llm.StreamResponse) bool {
+			select {
+			case respChan <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		runes := []rune(resp.Text)
+		for start := 0; start < len(runes); start += streamChunkRunes {
+			end := min(start+streamChunkRunes, len(runes))
+			if !send(llm.StreamResponse{Text: string(runes[start:end]), ModelName: resp.ModelName}) {
+				return
+			}
+		}
+
+		reason := resp.FinishReason
+		if reason == "" {
+			reason = "stop"
+		}
+		send(llm.StreamResponse{Text: resp.Text, FinishReason: reason, ModelName: resp.ModelName, Done: true})
+	}()
+
+	return respChan
+}
+
+// recordStream 透传上游流式响应，正常结束后录制完整文本；中断或出错的流不录制
+func (p *Provider) recordStream(ctx context.Context, key string, req recordedRequest, upstream <-chan llm.StreamResponse) <-chan llm.StreamResponse {
+	respChan := make(chan llm.StreamResponse)
+
+	go func() {
+		defer close(respChan)
+
+		var text strings.Builder
+		for msg := range upstream {
+			if msg.Done {
+				full := msg.Text
+				if full == "" {
+					full = text.String()
+				}
+				if msg.FinishReason != "error" {
+					resp := llm.CompletionResponse{Text: full, FinishReason: msg.FinishReason, ModelName: msg.ModelName}
+					if err := p.cassette.Record(key, req, resp); err != nil {
+						msg.FinishReason = "error"
+						msg.Error = "录制失败: " + err.Error()
+					}
+				}
+			} else {
+				text.WriteString(msg.Text)
+			}
+
+			select {
+			case respChan <- msg:
+			case <-ctx.Done():
+				// 排空上游，避免其发送协程阻塞
+				for range upstream {
+				}
+				return
+			}
+		}
+	}()
+
+	return respChan
+}
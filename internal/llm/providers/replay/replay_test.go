@@ -0,0 +1,170 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+)
+
+type fakeUpstream struct {
+	calls int
+}
+
+func (f *fakeUpstream) Initialize(config map[string]string) error { return nil }
+func (f *fakeUpstream) GetName() string                           { return "Fake" }
+func (f *fakeUpstream) GetSupportedModels() []string              { return []string{"fake-1"} }
+func (f *fakeUpstream) FetchAvailableModels(ctx context.Context) error {
+	return nil
+}
+func (f *fakeUpstream) SetCustomModels(models []string) {}
+
+func (f *fakeUpstream) CompleteText(ctx context.Context, req llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	f.calls++
+	return &llm.CompletionResponse{Text: "echo: " + req.Prompt, FinishReason: "stop", TokensUsed: 7, ModelName: "fake-1"}, nil
+}
+
+func (f *fakeUpstream) StreamCompletion(ctx context.Context, req llm.CompletionRequest) (<-chan llm.StreamResponse, error) {
+	f.calls++
+	ch := make(chan llm.StreamResponse, 3)
+	ch <- llm.StreamResponse{Text: "streamed "}
+	ch <- llm.StreamResponse{Text: "reply"}
+	ch <- llm.StreamResponse{Text: "streamed reply", FinishReason: "stop", ModelName: "fake-1", Done: true}
+	close(ch)
+	return ch, nil
+}
+
+var fake = &fakeUpstream{}
+
+func init() {
+	llm.Register("replay-test-fake", func() llm.Provider { return fake })
+}
+
+func collect(t *testing.T, ch <-chan llm.StreamResponse) (string, llm.StreamResponse) {
+	t.Helper()
+	var deltas strings.Builder
+	var final llm.StreamResponse
+	for msg := range ch {
+		if msg.Done {
+			final = msg
+			continue
+		}
+		deltas.WriteString(msg.Text)
+	}
+	return deltas.String(), final
+}
+
+func TestReplayProvider_RecordThenStrictReplay(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	path := "llm.json"
+	ctx := context.Background()
+
+	if llm.RequiresAPIKey("replay") {
+		t.Fatalf("expected replay to be keyless")
+	}
+
+	rec, err := llm.GetProvider("replay", map[string]string{
+		"cassette":        path,
+		"mode":            "record",
+		"upstream":        "replay-test-fake",
+		"default_model":   "fake-1",
+		"ignore_patterns": `["\\d{4}-\\d{2}-\\d{2}"]`,
+	})
+	if err != nil {
+		t.Fatalf("GetProvider(record) error: %v", err)
+	}
+
+	resp, err := rec.CompleteText(ctx, llm.CompletionRequest{Prompt: "hello   on 2024-01-02", MaxTokens: 32})
+	if err != nil || resp.Text != "echo: hello   on 2024-01-02" {
+		t.Fatalf("unexpected record response: %+v err=%v", resp, err)
+	}
+	stream, err := rec.StreamCompletion(ctx, llm.CompletionRequest{Prompt: "tell a story"})
+	if err != nil {
+		t.Fatalf("StreamCompletion(record) error: %v", err)
+	}
+	if text, final := collect(t, stream); text != "streamed reply" || !final.Done {
+		t.Fatalf("unexpected recorded stream: %q %+v", text, final)
+	}
+	if fake.calls != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", fake.calls)
+	}
+
+	strict, err := llm.GetProvider("replay", map[string]string{
+		"cassette":        path,
+		"mode":            "strict",
+		"default_model":   "fake-1",
+		"ignore_patterns": `["\\d{4}-\\d{2}-\\d{2}"]`,
+	})
+	if err != nil {
+		t.Fatalf("GetProvider(strict) error: %v", err)
+	}
+
+	// 空白与被忽略的日期不影响匹配
+	resp, err = strict.CompleteText(ctx, llm.CompletionRequest{Prompt: "hello on 2025-12-31", MaxTokens: 32})
+	if err != nil || resp.Text != "echo: hello   on 2024-01-02" || resp.TokensUsed != 7 {
+		t.Fatalf("unexpected replayed response: %+v err=%v", resp, err)
+	}
+
+	stream, err = strict.StreamCompletion(ctx, llm.CompletionRequest{Prompt: "tell a story"})
+	if err != nil {
+		t.Fatalf("StreamCompletion(strict) error: %v", err)
+	}
+	if text, final := collect(t, stream); text != "streamed reply" || final.Text != "streamed reply" || final.FinishReason != "stop" {
+		t.Fatalf("unexpected replayed stream: %q %+v", text, final)
+	}
+
+	if _, err := strict.CompleteText(ctx, llm.CompletionRequest{Prompt: "hello on 2025-12-31", MaxTokens: 64}); !errors.Is(err, cassette.ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch for changed max_tokens, got %v", err)
+	}
+	if fake.calls != 2 {
+		t.Fatalf("strict mode must not call upstream, calls=%d", fake.calls)
+	}
+	if models := strict.GetSupportedModels(); len(models) != 1 || models[0] != "fake-1" {
+		t.Fatalf("unexpected models from cassette: %v", models)
+	}
+}
+
+func TestReplayProvider_ConfigErrors(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("DATA_DIR", dataDir)
+	if err := os.WriteFile(filepath.Join(dataDir, "config.json"), []byte(`{"llm_provider":"openai"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(CassetteDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(CassetteDir(), "plain.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []map[string]string{
+		{"cassette": "a.json", "mode": "bogus"},
+		{"cassette": "b.json", "mode": "record"},
+		{"cassette": "c.json", "mode": "strict"},
+		{"cassette": "d.json", "upstream": "replay"},
+		{"cassette": "e.json", "ignore_patterns": `["("]`},
+		// 只允许 $DATA_DIR/cassettes 下的相对路径，且不接管非录制文件
+		{"cassette": filepath.Join(dataDir, "config.json")},
+		{"cassette": "../config.json"},
+		{"cassette": "plain.json"},
+	}
+	for _, cfg := range cases {
+		if _, err := llm.GetProvider("replay", cfg); err == nil {
+			t.Fatalf("expected error for config %v", cfg)
+		}
+	}
+
+	// replay 模式无上游：未命中直接报错
+	p, err := llm.GetProvider("replay", map[string]string{"cassette": "f.json"})
+	if err != nil {
+		t.Fatalf("GetProvider error: %v", err)
+	}
+	if _, err := p.CompleteText(context.Background(), llm.CompletionRequest{Prompt: "x"}); !errors.Is(err, cassette.ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+// internal/llm/structured_output.go
+package llm
+
+import "strings"
+
+// ResponseFormat 描述期望的 JSON 输出：Name 为 schema 名称，Schema 由 SchemaFor 生成
+type ResponseFormat struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema,omitempty"`
+}
+
+// ApplyResponseFormat 为支持原生 JSON 模式的提供者写入请求参数；额外参数中已显式设置的不覆盖。
+//   - openai / mistral：response_format=json_schema（非 strict），仅限根节点为对象的 schema
+//   - deepseek：response_format=json_object，仅限根节点为对象的 schema
+//   - google：generationConfig.responseMimeType=application/json
+//
+// 根节点为数组时 OpenAI 兼容接口的 JSON 模式会强制输出对象，因此只依赖提示词。
+func ApplyResponseFormat(provider string, requestBody map[string]interface{}, format *ResponseFormat) {
+	if format == nil || requestBody == nil {
+		return
+	}
+	objectRoot := SchemaRootType(format.Schema) == "object"
+
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "openai", "mistral":
+		if _, exists := requestBody["response_format"]; exists || !objectRoot {
+			return
+		}
+		name := strings.TrimSpace(format.Name)
+		if name == "" {
+			name = "structured_output"
+		}
+		requestBody["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   name,
+				"schema": format.Schema,
+				"strict": false,
+			},
+		}
+	case "deepseek":
+		if _, exists := requestBody["response_format"]; exists || !objectRoot {
+			return
+		}
+		requestBody["response_format"] = map[string]interface{}{"type": "json_object"}
+	case "google":
+		generationConfig, _ := requestBody["generationConfig"].(map[string]interface{})
+		if generationConfig == nil {
+			generationConfig = map[string]interface{}{}
+			requestBody["generationConfig"] = generationConfig
+		}
+		if _, exists := generationConfig["responseMimeType"]; !exists {
+			generationConfig["responseMimeType"] = "application/json"
+		}
+	}
+}
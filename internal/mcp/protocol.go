@@ -0,0 +1,128 @@
+// internal/mcp/protocol.go
+package mcp
+
+import (
+	"encoding/json"
+)
+
+// ProtocolVersion 是本服务实现的 MCP 协议版本
+const ProtocolVersion = "2025-03-26"
+
+const jsonRPCVersion = "2.0"
+
+// JSON-RPC 2.0 标准错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Request 表示一条 JSON-RPC 请求或通知（ID 为空即为通知）
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification 判断请求是否为无需响应的通知
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0 || string(r.ID) == "null"
+}
+
+// Response 表示一条 JSON-RPC 响应
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError 表示 JSON-RPC 错误对象
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// Implementation 描述客户端或服务端的名称与版本
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeParams 是 initialize 请求的参数
+type InitializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities,omitempty"`
+	ClientInfo      Implementation         `json:"clientInfo"`
+}
+
+// InitializeResult 是 initialize 请求的返回
+type InitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      Implementation         `json:"serverInfo"`
+	Instructions    string                 `json:"instructions,omitempty"`
+}
+
+// Tool 描述一个可供客户端调用的工具
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// CallToolParams 是 tools/call 请求的参数
+type CallToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Content 是工具结果中的一段内容
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// CallToolResult 是 tools/call 请求的返回；工具执行错误通过 IsError 表达
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Resource 描述一个可读取的资源
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate 描述一类可按 URI 模板读取的资源
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents 是 resources/read 返回的单个资源内容
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+// ReadResourceParams 是 resources/read 请求的参数
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
@@ -0,0 +1,453 @@
+// internal/mcp/scene_tools.go
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/di"
+	"github.com/Corphon/SceneIntruderMCP/internal/services"
+)
+
+const (
+	// ServerName 是对外公布的 MCP 服务名
+	ServerName = "scene-intruder-mcp"
+	// ServerVersion 是对外公布的 MCP 服务版本
+	ServerVersion = "1.0.0"
+
+	// defaultUserID 与 REST API 的游客身份保持一致
+	defaultUserID = "console_user"
+
+	sceneURIScheme = "scene://"
+)
+
+// NewSceneServer 创建注册了场景/角色/故事/漫画工具与资源的 MCP 服务端。
+// 服务实例在每次调用时从 DI 容器获取，确保 LLM 重新配置后仍使用最新实例。
+func NewSceneServer() *Server {
+	server := NewServer(ServerName, ServerVersion)
+	server.SetInstructions("Drive SceneIntruder scenes directly: create scenes from text, chat with characters, " +
+		"make story choices, explore locations and generate comics. Read scene://{scene_id} and " +
+		"scene://{scene_id}/story resources for the current state.")
+
+	registerSceneTools(server)
+	server.SetResourceProvider(&sceneResourceProvider{})
+	return server
+}
+
+// ---------------------------------------------------------
+// 服务获取
+
+func sceneService() (*services.SceneService, error) {
+	svc, ok := di.GetContainer().Get("scene").(*services.SceneService)
+	if !ok || svc == nil {
+		return nil, errors.New("scene service not initialized")
+	}
+	return svc, nil
+}
+
+func characterService() (*services.CharacterService, error) {
+	svc, ok := di.GetContainer().Get("character").(*services.CharacterService)
+	if !ok || svc == nil {
+		return nil, errors.New("character service not initialized")
+	}
+	return svc, nil
+}
+
+func storyService() (*services.StoryService, error) {
+	svc, ok := di.GetContainer().Get("story").(*services.StoryService)
+	if !ok || svc == nil {
+		return nil, errors.New("story service not initialized")
+	}
+	return svc, nil
+}
+
+func comicService() (*services.ComicService, error) {
+	svc, ok := di.GetContainer().Get("comic").(*services.ComicService)
+	if !ok || svc == nil {
+		return nil, errors.New("comic service not initialized")
+	}
+	return svc, nil
+}
+
+func progressService() (*services.ProgressService, error) {
+	svc, ok := di.GetContainer().Get("progress").(*services.ProgressService)
+	if !ok || svc == nil {
+		return nil, errors.New("progress service not initialized")
+	}
+	return svc, nil
+}
+
+// checkUsageQuota 在调用 LLM 的工具执行前检查调用方的用量配额；调用方未知时按场景所有者检查
+func checkUsageQuota(userID, sceneID string) error {
+	usage, ok := di.GetContainer().Get("usage").(*services.UsageService)
+	if !ok || usage == nil {
+		return nil
+	}
+	return usage.CheckQuota(usage.ResolveUser(userID, sceneID))
+}
+
+// ---------------------------------------------------------
+// 工具定义
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func decodeArgs(raw json.RawMessage, out interface{}, required map[string]*string) error {
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	for name, value := range required {
+		*value = strings.TrimSpace(*value)
+		if *value == "" {
+			return fmt.Errorf("missing required argument: %s", name)
+		}
+	}
+	return nil
+}
+
+func registerSceneTools(server *Server) {
+	server.RegisterTool(Tool{
+		Name:        "list_scenes",
+		Description: "List all scenes with their IDs, titles and character counts.",
+		InputSchema: objectSchema(map[string]interface{}{}),
+	}, handleListScenes)
+
+	server.RegisterTool(Tool{
+		Name:        "create_scene_from_text",
+		Description: "Analyze a piece of novel text with the LLM and create a new scene with characters, items and locations.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"title": stringProp("Scene title"),
+			"text":  stringProp("Source text to analyze"),
+		}, "title", "text"),
+	}, handleCreateSceneFromText)
+
+	server.RegisterTool(Tool{
+		Name:        "chat_with_character",
+		Description: "Send a message to a character in a scene and get an in-character reply with emotion data. The exchange is stored in the scene conversation history.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"scene_id":     stringProp("Scene ID"),
+			"character_id": stringProp("Character ID (see scene://{scene_id})"),
+			"message":      stringProp("Message to the character"),
+		}, "scene_id", "character_id", "message"),
+	}, handleChatWithCharacter)
+
+	server.RegisterTool(Tool{
+		Name:        "make_story_choice",
+		Description: "Select a choice on a story node and advance the story. Returns the next story node.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"scene_id":  stringProp("Scene ID"),
+			"node_id":   stringProp("Story node ID that owns the choice"),
+			"choice_id": stringProp("Choice ID to select"),
+		}, "scene_id", "node_id", "choice_id"),
+	}, handleMakeStoryChoice)
+
+	server.RegisterTool(Tool{
+		Name:        "explore_location",
+		Description: "Explore an unlocked story location; may discover items, clues or new story nodes.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"scene_id":    stringProp("Scene ID"),
+			"location_id": stringProp("Location ID from the scene story data"),
+		}, "scene_id", "location_id"),
+	}, handleExploreLocation)
+
+	server.RegisterTool(Tool{
+		Name:        "generate_comic",
+		Description: "Start asynchronous comic image generation for a scene. Requires comic analysis and prompts to exist. Returns a task ID for get_task_progress.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"scene_id": stringProp("Scene ID"),
+			"resume": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Skip frames whose images are already up to date",
+			},
+		}, "scene_id"),
+	}, handleGenerateComic)
+
+	server.RegisterTool(Tool{
+		Name:        "get_task_progress",
+		Description: "Get the latest progress snapshot of an asynchronous task such as comic generation.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"task_id": stringProp("Task ID returned by an asynchronous tool"),
+		}, "task_id"),
+	}, handleGetTaskProgress)
+}
+
+func handleListScenes(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+	svc, err := sceneService()
+	if err != nil {
+		return nil, err
+	}
+	scenes, err := svc.GetAllScenes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0, len(scenes))
+	for _, scene := range scenes {
+		result = append(result, map[string]interface{}{
+			"id":              scene.ID,
+			"title":           scene.Title,
+			"description":     scene.Description,
+			"character_count": scene.CharacterCount,
+			"last_updated":    scene.LastUpdated,
+		})
+	}
+	return map[string]interface{}{"scenes": result}, nil
+}
+
+func handleCreateSceneFromText(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+	if err := decodeArgs(raw, &args, map[string]*string{"title": &args.Title, "text": &args.Text}); err != nil {
+		return nil, err
+	}
+
+	userID := UserIDFromContext(ctx, defaultUserID)
+	if err := checkUsageQuota(userID, ""); err != nil {
+		return nil, err
+	}
+	svc, err := sceneService()
+	if err != nil {
+		return nil, err
+	}
+	scene, err := svc.CreateSceneFromText(userID, args.Text, args.Title)
+	if err != nil {
+		return nil, err
+	}
+	return scene, nil
+}
+
+func handleChatWithCharacter(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		SceneID     string `json:"scene_id"`
+		CharacterID string `json:"character_id"`
+		Message     string `json:"message"`
+	}
+	if err := decodeArgs(raw, &args, map[string]*string{
+		"scene_id":     &args.SceneID,
+		"character_id": &args.CharacterID,
+		"message":      &args.Message,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := checkUsageQuota(UserIDFromContext(ctx, ""), args.SceneID); err != nil {
+		return nil, err
+	}
+	svc, err := characterService()
+	if err != nil {
+		return nil, err
+	}
+	return svc.GenerateResponseWithEmotion(args.SceneID, args.CharacterID, args.Message)
+}
+
+func handleMakeStoryChoice(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		SceneID  string `json:"scene_id"`
+		NodeID   string `json:"node_id"`
+		ChoiceID string `json:"choice_id"`
+	}
+	if err := decodeArgs(raw, &args, map[string]*string{
+		"scene_id":  &args.SceneID,
+		"node_id":   &args.NodeID,
+		"choice_id": &args.ChoiceID,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := checkUsageQuota(UserIDFromContext(ctx, ""), args.SceneID); err != nil {
+		return nil, err
+	}
+	svc, err := storyService()
+	if err != nil {
+		return nil, err
+	}
+	return svc.MakeChoice(args.SceneID, args.NodeID, args.ChoiceID, nil)
+}
+
+func handleExploreLocation(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		SceneID    string `json:"scene_id"`
+		LocationID string `json:"location_id"`
+	}
+	if err := decodeArgs(raw, &args, map[string]*string{
+		"scene_id":    &args.SceneID,
+		"location_id": &args.LocationID,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := checkUsageQuota(UserIDFromContext(ctx, ""), args.SceneID); err != nil {
+		return nil, err
+	}
+	svc, err := storyService()
+	if err != nil {
+		return nil, err
+	}
+	return svc.ExploreLocation(args.SceneID, args.LocationID, nil)
+}
+
+func handleGenerateComic(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		SceneID string `json:"scene_id"`
+		Resume  bool   `json:"resume"`
+	}
+	if err := decodeArgs(raw, &args, map[string]*string{"scene_id": &args.SceneID}); err != nil {
+		return nil, err
+	}
+
+	svc, err := comicService()
+	if err != nil {
+		return nil, err
+	}
+	// 任务在请求返回后继续运行，不能继承调用方的取消信号
+	taskID, err := svc.GenerateComicAsyncWithOptions(context.WithoutCancel(ctx), args.SceneID, services.ComicGenerateOptions{Resume: args.Resume})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"task_id": taskID, "scene_id": args.SceneID}, nil
+}
+
+func handleGetTaskProgress(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := decodeArgs(raw, &args, map[string]*string{"task_id": &args.TaskID}); err != nil {
+		return nil, err
+	}
+
+	svc, err := progressService()
+	if err != nil {
+		return nil, err
+	}
+	tracker, exists := svc.GetTracker(args.TaskID)
+	if !exists {
+		return nil, fmt.Errorf("task not found: %s", args.TaskID)
+	}
+	return tracker.Snapshot(), nil
+}
+
+// ---------------------------------------------------------
+// 资源：scene://{scene_id}、scene://{scene_id}/story、scene://{scene_id}/story/nodes/{node_id}
+
+type sceneResourceProvider struct{}
+
+func (p *sceneResourceProvider) ListResources(ctx context.Context) ([]Resource, error) {
+	svc, err := sceneService()
+	if err != nil {
+		return nil, err
+	}
+	scenes, err := svc.GetAllScenes()
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, 0, len(scenes)*2)
+	for _, scene := range scenes {
+		resources = append(resources,
+			Resource{
+				URI:         sceneURIScheme + url.PathEscape(scene.ID),
+				Name:        scene.Title,
+				Description: "Scene, characters, items and settings",
+				MimeType:    "application/json",
+			},
+			Resource{
+				URI:         sceneURIScheme + url.PathEscape(scene.ID) + "/story",
+				Name:        scene.Title + " - story",
+				Description: "Story nodes, tasks and locations",
+				MimeType:    "application/json",
+			},
+		)
+	}
+	return resources, nil
+}
+
+func (p *sceneResourceProvider) ListResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{
+		{URITemplate: "scene://{scene_id}", Name: "Scene", Description: "Scene data with characters and items", MimeType: "application/json"},
+		{URITemplate: "scene://{scene_id}/story", Name: "Story", Description: "Story data of a scene", MimeType: "application/json"},
+		{URITemplate: "scene://{scene_id}/story/nodes/{node_id}", Name: "Story node", Description: "A single story node", MimeType: "application/json"},
+	}
+}
+
+func (p *sceneResourceProvider) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	if !strings.HasPrefix(uri, sceneURIScheme) {
+		return nil, ErrResourceNotFound
+	}
+
+	parts := strings.Split(strings.TrimPrefix(uri, sceneURIScheme), "/")
+	for i := range parts {
+		unescaped, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, ErrResourceNotFound
+		}
+		parts[i] = unescaped
+	}
+	sceneID := parts[0]
+	if sceneID == "" {
+		return nil, ErrResourceNotFound
+	}
+
+	var payload interface{}
+	switch {
+	case len(parts) == 1:
+		svc, err := sceneService()
+		if err != nil {
+			return nil, err
+		}
+		sceneData, err := svc.LoadScene(sceneID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrResourceNotFound, err)
+		}
+		// 原文体积较大且已按节点拆分在故事数据中，资源中不重复返回
+		data := *sceneData
+		data.OriginalText = ""
+		data.OriginalSegments = nil
+		payload = data
+	case len(parts) == 2 && parts[1] == "story":
+		svc, err := storyService()
+		if err != nil {
+			return nil, err
+		}
+		storyData, err := svc.GetStoryForScene(sceneID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrResourceNotFound, err)
+		}
+		payload = storyData
+	case len(parts) == 4 && parts[1] == "story" && parts[2] == "nodes" && parts[3] != "":
+		svc, err := storyService()
+		if err != nil {
+			return nil, err
+		}
+		node, err := svc.GetStoryNode(sceneID, parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrResourceNotFound, err)
+		}
+		payload = node
+	default:
+		return nil, ErrResourceNotFound
+	}
+
+	text, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ResourceContents{{URI: uri, MimeType: "application/json", Text: string(text)}}, nil
+}
@@ -0,0 +1,322 @@
+// internal/mcp/server.go
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+// ToolHandler 执行一次工具调用，返回值会被序列化为 JSON 文本内容
+type ToolHandler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+
+// ResourceProvider 为服务器提供资源的列举与读取能力
+type ResourceProvider interface {
+	ListResources(ctx context.Context) ([]Resource, error)
+	ListResourceTemplates() []ResourceTemplate
+	ReadResource(ctx context.Context, uri string) ([]ResourceContents, error)
+}
+
+// ErrResourceNotFound 表示请求的资源不存在
+var ErrResourceNotFound = errors.New("resource not found")
+
+type registeredTool struct {
+	tool    Tool
+	handler ToolHandler
+}
+
+// Server 是与传输层无关的 MCP 服务端，负责 JSON-RPC 分发
+type Server struct {
+	info         Implementation
+	instructions string
+
+	mu        sync.RWMutex
+	tools     map[string]registeredTool
+	toolOrder []string
+	resources ResourceProvider
+}
+
+// NewServer 创建 MCP 服务端
+func NewServer(name, version string) *Server {
+	return &Server{
+		info:  Implementation{Name: name, Version: version},
+		tools: make(map[string]registeredTool),
+	}
+}
+
+// SetInstructions 设置 initialize 时返回给客户端的使用说明
+func (s *Server) SetInstructions(instructions string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instructions = instructions
+}
+
+// RegisterTool 注册工具；同名工具会被覆盖
+func (s *Server) RegisterTool(tool Tool, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tools[tool.Name]; !exists {
+		s.toolOrder = append(s.toolOrder, tool.Name)
+	}
+	s.tools[tool.Name] = registeredTool{tool: tool, handler: handler}
+}
+
+// SetResourceProvider 设置资源提供者
+func (s *Server) SetResourceProvider(provider ResourceProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources = provider
+}
+
+// HandleMessage 处理一条原始 JSON-RPC 消息（单条或批量）。
+// 当消息全部为通知时返回 nil。
+func (s *Server) HandleMessage(ctx context.Context, raw []byte) []byte {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil
+	}
+
+	if trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return marshalResponse(errorResponse(nil, CodeParseError, "parse error", err.Error()))
+		}
+		if len(batch) == 0 {
+			return marshalResponse(errorResponse(nil, CodeInvalidRequest, "empty batch", nil))
+		}
+
+		responses := make([]*Response, 0, len(batch))
+		for _, item := range batch {
+			if resp := s.handleSingle(ctx, item); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(responses)
+		if err != nil {
+			return marshalResponse(errorResponse(nil, CodeInternalError, "marshal batch failed", err.Error()))
+		}
+		return data
+	}
+
+	resp := s.handleSingle(ctx, trimmed)
+	if resp == nil {
+		return nil
+	}
+	return marshalResponse(resp)
+}
+
+func (s *Server) handleSingle(ctx context.Context, raw json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, CodeParseError, "parse error", err.Error())
+	}
+	if req.JSONRPC != jsonRPCVersion || req.Method == "" {
+		// 客户端回传的响应（例如对服务端请求的应答）没有 method，直接忽略
+		if req.Method == "" && !req.IsNotification() {
+			return nil
+		}
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request", nil)
+	}
+
+	result, rpcErr := s.dispatch(ctx, &req)
+	if req.IsNotification() {
+		return nil
+	}
+	if rpcErr != nil {
+		return &Response{JSONRPC: jsonRPCVersion, ID: req.ID, Error: rpcErr}
+	}
+	return &Response{JSONRPC: jsonRPCVersion, ID: req.ID, Result: result}
+}
+
+func (s *Server) dispatch(ctx context.Context, req *Request) (interface{}, *RPCError) {
+	switch req.Method {
+	case "initialize":
+		return s.handleInitialize(req.Params)
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.listTools()}, nil
+	case "tools/call":
+		return s.handleCallTool(ctx, req.Params)
+	case "resources/list":
+		return s.handleListResources(ctx)
+	case "resources/templates/list":
+		return s.handleListResourceTemplates()
+	case "resources/read":
+		return s.handleReadResource(ctx, req.Params)
+	default:
+		return nil, &RPCError{Code: CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+}
+
+func (s *Server) handleInitialize(params json.RawMessage) (interface{}, *RPCError) {
+	var p InitializeParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "invalid initialize params", Data: err.Error()}
+		}
+	}
+
+	s.mu.RLock()
+	hasResources := s.resources != nil
+	instructions := s.instructions
+	s.mu.RUnlock()
+
+	capabilities := map[string]interface{}{
+		"tools": map[string]interface{}{"listChanged": false},
+	}
+	if hasResources {
+		capabilities["resources"] = map[string]interface{}{"subscribe": false, "listChanged": false}
+	}
+
+	utils.GetLogger().Info("mcp client initialized", map[string]interface{}{
+		"client":           p.ClientInfo.Name,
+		"client_version":   p.ClientInfo.Version,
+		"protocol_version": p.ProtocolVersion,
+	})
+
+	return &InitializeResult{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    capabilities,
+		ServerInfo:      s.info,
+		Instructions:    instructions,
+	}, nil
+}
+
+func (s *Server) listTools() []Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(s.toolOrder))
+	for _, name := range s.toolOrder {
+		tools = append(tools, s.tools[name].tool)
+	}
+	return tools
+}
+
+func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	var p CallToolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "invalid tools/call params", Data: err.Error()}
+	}
+
+	s.mu.RLock()
+	registered, exists := s.tools[p.Name]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", p.Name)}
+	}
+
+	args := p.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	result, err := registered.handler(ctx, args)
+	if err != nil {
+		utils.GetLogger().Warn("mcp tool call failed", map[string]interface{}{"tool": p.Name, "err": err.Error()})
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: err.Error()}},
+			IsError: true,
+		}, nil
+	}
+
+	text, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, &RPCError{Code: CodeInternalError, Message: "marshal tool result failed", Data: err.Error()}
+	}
+	return &CallToolResult{Content: []Content{{Type: "text", Text: string(text)}}}, nil
+}
+
+func (s *Server) resourceProvider() (ResourceProvider, *RPCError) {
+	s.mu.RLock()
+	provider := s.resources
+	s.mu.RUnlock()
+	if provider == nil {
+		return nil, &RPCError{Code: CodeMethodNotFound, Message: "resources not supported"}
+	}
+	return provider, nil
+}
+
+func (s *Server) handleListResources(ctx context.Context) (interface{}, *RPCError) {
+	provider, rpcErr := s.resourceProvider()
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	resources, err := provider.ListResources(ctx)
+	if err != nil {
+		return nil, &RPCError{Code: CodeInternalError, Message: "list resources failed", Data: err.Error()}
+	}
+	if resources == nil {
+		resources = []Resource{}
+	}
+	return map[string]interface{}{"resources": resources}, nil
+}
+
+func (s *Server) handleListResourceTemplates() (interface{}, *RPCError) {
+	provider, rpcErr := s.resourceProvider()
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	templates := provider.ListResourceTemplates()
+	if templates == nil {
+		templates = []ResourceTemplate{}
+	}
+	return map[string]interface{}{"resourceTemplates": templates}, nil
+}
+
+func (s *Server) handleReadResource(ctx context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	provider, rpcErr := s.resourceProvider()
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	var p ReadResourceParams
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "invalid resources/read params"}
+	}
+
+	contents, err := provider.ReadResource(ctx, p.URI)
+	if err != nil {
+		if errors.Is(err, ErrResourceNotFound) {
+			// -32002 是 MCP 约定的资源不存在错误码
+			return nil, &RPCError{Code: -32002, Message: "resource not found", Data: map[string]string{"uri": p.URI}}
+		}
+		return nil, &RPCError{Code: CodeInternalError, Message: "read resource failed", Data: err.Error()}
+	}
+	return map[string]interface{}{"contents": contents}, nil
+}
+
+func errorResponse(id json.RawMessage, code int, message string, data interface{}) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{
+		JSONRPC: jsonRPCVersion,
+		ID:      id,
+		Error:   &RPCError{Code: code, Message: message, Data: data},
+	}
+}
+
+func marshalResponse(resp *Response) []byte {
+	if len(resp.ID) == 0 {
+		resp.ID = json.RawMessage("null")
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		fallback, _ := json.Marshal(errorResponse(resp.ID, CodeInternalError, "marshal response failed", err.Error()))
+		return fallback
+	}
+	return data
+}
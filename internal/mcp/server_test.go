@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func callTool(t *testing.T, s *Server, name, args string) CallToolResult {
+	t.Helper()
+	raw := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"`+name+`","arguments":`+args+`}}`))
+	var resp struct {
+		Result CallToolResult `json:"result"`
+		Error  *RPCError      `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		t.Fatalf("unmarshal response: %v (%s)", err, raw)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected rpc error: %+v", resp.Error)
+	}
+	return resp.Result
+}
+
+func TestServer_ToolCall(t *testing.T) {
+	s := NewServer("test", "0")
+	s.RegisterTool(Tool{Name: "echo"}, func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		var p struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(args, &p); err != nil {
+			return nil, err
+		}
+		if p.Text == "" {
+			return nil, errors.New("text required")
+		}
+		return map[string]string{"echo": p.Text, "user": UserIDFromContext(ctx, "guest")}, nil
+	})
+
+	result := callTool(t, s, "echo", `{"text":"hi"}`)
+	if result.IsError || len(result.Content) != 1 || !strings.Contains(result.Content[0].Text, `"echo": "hi"`) {
+		t.Fatalf("unexpected tool result: %+v", result)
+	}
+	if !strings.Contains(result.Content[0].Text, `"user": "guest"`) {
+		t.Fatalf("expected fallback user in result: %s", result.Content[0].Text)
+	}
+
+	result = callTool(t, s, "echo", `{}`)
+	if !result.IsError || result.Content[0].Text != "text required" {
+		t.Fatalf("expected tool error result, got %+v", result)
+	}
+}
+
+func TestServer_HandleMessageErrors(t *testing.T) {
+	s := NewServer("test", "0")
+
+	cases := []struct {
+		name string
+		msg  string
+		code int
+	}{
+		{"parse error", `{not json`, CodeParseError},
+		{"empty batch", `[]`, CodeInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"nope"}`, CodeMethodNotFound},
+		{"unknown tool", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"missing"}}`, CodeInvalidParams},
+		{"resources unsupported", `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`, CodeMethodNotFound},
+	}
+	for _, tc := range cases {
+		var resp Response
+		if err := json.Unmarshal(s.HandleMessage(context.Background(), []byte(tc.msg)), &resp); err != nil {
+			t.Fatalf("%s: unmarshal response: %v", tc.name, err)
+		}
+		if resp.Error == nil || resp.Error.Code != tc.code {
+			t.Fatalf("%s: error = %+v, want code %d", tc.name, resp.Error, tc.code)
+		}
+	}
+
+	if resp := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); resp != nil {
+		t.Fatalf("expected no response for notification, got %s", resp)
+	}
+
+	batch := s.HandleMessage(context.Background(), []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`))
+	var responses []Response
+	if err := json.Unmarshal(batch, &responses); err != nil || len(responses) != 1 {
+		t.Fatalf("batch responses = %s (%v), want one response", batch, err)
+	}
+}
@@ -0,0 +1,284 @@
+// internal/mcp/transport.go
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHTTPBodySize 限制单次 HTTP 请求体大小（场景原文可能较长）
+const maxHTTPBodySize = 16 << 20
+
+// SessionHeader 是 Streamable HTTP 传输中携带会话ID的请求头
+const SessionHeader = "Mcp-Session-Id"
+
+const (
+	// DefaultSessionTTL 会话空闲超过该时长后失效，客户端需重新 initialize
+	DefaultSessionTTL = 30 * time.Minute
+	// DefaultMaxSessions 同时保留的会话上限，超出时淘汰最久未使用的会话
+	DefaultMaxSessions = 1024
+)
+
+type contextKey string
+
+const userIDContextKey contextKey = "mcp_user_id"
+
+// WithUserID 将调用方用户ID写入上下文，供工具处理函数使用
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userID)
+}
+
+// UserIDFromContext 读取调用方用户ID，未设置时返回 fallback
+func UserIDFromContext(ctx context.Context, fallback string) string {
+	if userID, ok := ctx.Value(userIDContextKey).(string); ok && strings.TrimSpace(userID) != "" {
+		return userID
+	}
+	return fallback
+}
+
+// ServeStdio 以换行分隔的 JSON-RPC 消息在 r/w 上提供服务，直到输入结束或 ctx 取消。
+// 请求并发处理，以免长时间运行的工具阻塞 ping 等轻量请求。
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	write := func(data []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		w.Write(data)
+		w.Write([]byte("\n"))
+	}
+
+	type readResult struct {
+		line []byte
+		err  error
+	}
+	lines := make(chan readResult)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadBytes('\n')
+			select {
+			case lines <- readResult{line: line, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case res := <-lines:
+			if len(bytes.TrimSpace(res.line)) > 0 {
+				msg := res.line
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if resp := s.HandleMessage(ctx, msg); resp != nil {
+						write(resp)
+					}
+				}()
+			}
+			if res.err != nil {
+				if errors.Is(res.err, io.EOF) {
+					return nil
+				}
+				return res.err
+			}
+		}
+	}
+}
+
+// httpSession 记录会话所属用户与最近一次使用时间
+type httpSession struct {
+	userID   string
+	lastSeen time.Time
+}
+
+// HTTPHandler 实现 MCP Streamable HTTP 传输（仅 JSON 响应，不开启服务端推送流）。
+// 会话绑定创建它的用户；空闲超过 TTL 的会话在创建新会话时被清理。
+type HTTPHandler struct {
+	server *Server
+
+	sessionTTL  time.Duration
+	maxSessions int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// NewHTTPHandler 创建 Streamable HTTP 处理器
+func NewHTTPHandler(server *Server) *HTTPHandler {
+	return &HTTPHandler{
+		server:      server,
+		sessionTTL:  DefaultSessionTTL,
+		maxSessions: DefaultMaxSessions,
+		now:         time.Now,
+		sessions:    make(map[string]*httpSession),
+	}
+}
+
+// SetSessionLimits 调整会话空闲 TTL 与会话数上限；非正值保持原设置
+func (h *HTTPHandler) SetSessionLimits(ttl time.Duration, maxSessions int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ttl > 0 {
+		h.sessionTTL = ttl
+	}
+	if maxSessions > 0 {
+		h.maxSessions = maxSessions
+	}
+}
+
+// ServeHTTP 处理 POST（JSON-RPC 消息）、GET（不支持 SSE 流，返回 405）与 DELETE（结束会话）
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodDelete:
+		sessionID := r.Header.Get(SessionHeader)
+		if sessionID == "" {
+			http.Error(w, "missing session id", http.StatusBadRequest)
+			return
+		}
+		if !h.touchSession(sessionID, UserIDFromContext(r.Context(), "")) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		h.mu.Lock()
+		delete(h.sessions, sessionID)
+		h.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *HTTPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPBodySize+1))
+	if err != nil {
+		http.Error(w, "read body failed", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxHTTPBodySize {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	userID := UserIDFromContext(r.Context(), "")
+	isInit := isInitializeMessage(body)
+	sessionID := r.Header.Get(SessionHeader)
+	// 会话不存在、已过期或属于其他用户时一律按未知会话处理，不泄露会话是否存在
+	if !isInit && sessionID != "" && !h.touchSession(sessionID, userID) {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	resp := h.server.HandleMessage(r.Context(), body)
+
+	if isInit {
+		sessionID = h.createSession(userID)
+	}
+	if sessionID != "" {
+		w.Header().Set(SessionHeader, sessionID)
+	}
+
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// touchSession 校验会话存在、未过期且属于 userID，并刷新最近使用时间
+func (h *HTTPHandler) touchSession(sessionID, userID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sess, ok := h.sessions[sessionID]
+	if !ok {
+		return false
+	}
+	now := h.now()
+	if now.Sub(sess.lastSeen) > h.sessionTTL {
+		delete(h.sessions, sessionID)
+		return false
+	}
+	if sess.userID != userID {
+		return false
+	}
+	sess.lastSeen = now
+	return true
+}
+
+// createSession 为 userID 创建新会话；先清理过期会话，仍达到上限时淘汰最久未使用的会话
+func (h *HTTPHandler) createSession(userID string) string {
+	sessionID := newSessionID()
+	if sessionID == "" {
+		return ""
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.sweepLocked(now)
+	for len(h.sessions) >= h.maxSessions {
+		oldestID := ""
+		var oldest time.Time
+		for id, sess := range h.sessions {
+			if oldestID == "" || sess.lastSeen.Before(oldest) {
+				oldestID, oldest = id, sess.lastSeen
+			}
+		}
+		delete(h.sessions, oldestID)
+	}
+	h.sessions[sessionID] = &httpSession{userID: userID, lastSeen: now}
+	return sessionID
+}
+
+// sweepLocked 删除空闲超过 TTL 的会话（调用方需持有 h.mu）
+func (h *HTTPHandler) sweepLocked(now time.Time) {
+	for id, sess := range h.sessions {
+		if now.Sub(sess.lastSeen) > h.sessionTTL {
+			delete(h.sessions, id)
+		}
+	}
+}
+
+func isInitializeMessage(body []byte) bool {
+	var req Request
+	if err := json.Unmarshal(bytes.TrimSpace(body), &req); err != nil {
+		return false
+	}
+	return req.Method == "initialize"
+}
+
+func newSessionID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	initializeBody = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"test","version":"0"}}}`
+	pingBody       = `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+)
+
+func newTestHTTPHandler() (*HTTPHandler, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHTTPHandler(NewServer("test", "0"))
+	h.now = func() time.Time { return now }
+	return h, &now
+}
+
+func doMCPRequest(h *HTTPHandler, method, userID, sessionID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/mcp", strings.NewReader(body))
+	if userID != "" {
+		req = req.WithContext(WithUserID(context.Background(), userID))
+	}
+	if sessionID != "" {
+		req.Header.Set(SessionHeader, sessionID)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func initSession(t *testing.T, h *HTTPHandler, userID string) string {
+	t.Helper()
+	rec := doMCPRequest(h, http.MethodPost, userID, "", initializeBody)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("initialize status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	sessionID := rec.Header().Get(SessionHeader)
+	if sessionID == "" {
+		t.Fatalf("initialize did not return a session id")
+	}
+	return sessionID
+}
+
+func TestHTTPHandler_SessionBoundToUser(t *testing.T) {
+	h, _ := newTestHTTPHandler()
+	sessionID := initSession(t, h, "alice")
+
+	if rec := doMCPRequest(h, http.MethodPost, "alice", sessionID, pingBody); rec.Code != http.StatusOK {
+		t.Fatalf("owner ping status = %d", rec.Code)
+	}
+	if rec := doMCPRequest(h, http.MethodPost, "bob", sessionID, pingBody); rec.Code != http.StatusNotFound {
+		t.Fatalf("other user ping status = %d, want 404", rec.Code)
+	}
+	if rec := doMCPRequest(h, http.MethodDelete, "bob", sessionID, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("other user delete status = %d, want 404", rec.Code)
+	}
+	if rec := doMCPRequest(h, http.MethodDelete, "alice", sessionID, ""); rec.Code != http.StatusOK {
+		t.Fatalf("owner delete status = %d", rec.Code)
+	}
+	if rec := doMCPRequest(h, http.MethodPost, "alice", sessionID, pingBody); rec.Code != http.StatusNotFound {
+		t.Fatalf("ping after delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHTTPHandler_SessionExpiresAfterTTL(t *testing.T) {
+	h, now := newTestHTTPHandler()
+	h.SetSessionLimits(time.Minute, 0)
+	sessionID := initSession(t, h, "alice")
+
+	// Each request refreshes the idle timer.
+	*now = now.Add(50 * time.Second)
+	if rec := doMCPRequest(h, http.MethodPost, "alice", sessionID, pingBody); rec.Code != http.StatusOK {
+		t.Fatalf("ping within ttl status = %d", rec.Code)
+	}
+	*now = now.Add(50 * time.Second)
+	if rec := doMCPRequest(h, http.MethodPost, "alice", sessionID, pingBody); rec.Code != http.StatusOK {
+		t.Fatalf("ping after refresh status = %d", rec.Code)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if rec := doMCPRequest(h, http.MethodPost, "alice", sessionID, pingBody); rec.Code != http.StatusNotFound {
+		t.Fatalf("ping after ttl status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHTTPHandler_SweepAndMaxSessions(t *testing.T) {
+	h, now := newTestHTTPHandler()
+	h.SetSessionLimits(time.Minute, 2)
+
+	stale := initSession(t, h, "alice")
+	*now = now.Add(2 * time.Minute)
+	first := initSession(t, h, "alice")
+	if len(h.sessions) != 1 {
+		t.Fatalf("expected expired session to be swept, have %d sessions", len(h.sessions))
+	}
+	if _, ok := h.sessions[stale]; ok {
+		t.Fatalf("expected stale session to be removed")
+	}
+
+	*now = now.Add(time.Second)
+	second := initSession(t, h, "bob")
+	*now = now.Add(time.Second)
+	third := initSession(t, h, "carol")
+
+	if len(h.sessions) != 2 {
+		t.Fatalf("session count = %d, want 2", len(h.sessions))
+	}
+	if rec := doMCPRequest(h, http.MethodPost, "alice", first, pingBody); rec.Code != http.StatusNotFound {
+		t.Fatalf("least recently used session should be evicted, status = %d", rec.Code)
+	}
+	for user, id := range map[string]string{"bob": second, "carol": third} {
+		if rec := doMCPRequest(h, http.MethodPost, user, id, pingBody); rec.Code != http.StatusOK {
+			t.Fatalf("%s ping status = %d", user, rec.Code)
+		}
+	}
+}
+
+func TestHTTPHandler_Methods(t *testing.T) {
+	h, _ := newTestHTTPHandler()
+
+	if rec := doMCPRequest(h, http.MethodGet, "alice", "", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want 405", rec.Code)
+	}
+	if rec := doMCPRequest(h, http.MethodDelete, "alice", "", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("DELETE without session status = %d, want 400", rec.Code)
+	}
+	notification := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	if rec := doMCPRequest(h, http.MethodPost, "alice", "", notification); rec.Code != http.StatusAccepted {
+		t.Fatalf("notification status = %d, want 202", rec.Code)
+	}
+}
@@ -8,6 +8,7 @@ type Character struct {
 	ID            string            `json:"id"`
 	SceneID       string            `json:"scene_id"`
 	Name          string            `json:"name"`
+	Aliases       []string          `json:"aliases,omitempty"` // 别名、称号（长篇分块分析时用于合并同一角色）
 	Role          string            `json:"role"`
 	Description   string            `json:"description"`
 	Novel         string            `json:"novel"`
@@ -106,12 +106,27 @@ type ComicReferenceIndex struct {
 // ComicFrameImageSignature records the effective render inputs used to generate a frame image.
 // It is persisted under images/<frame_id>.meta.json and used by resume generation to avoid
 // skipping stale images created with old prompt/style/model settings.
+//
+// The effective negative prompt, model params, size and seed are recorded as well, so the
+// image can be reproduced with the exact same inputs.
 type ComicFrameImageSignature struct {
-	FrameID      string    `json:"frame_id"`
-	Signature    string    `json:"signature"`
-	Prompt       string    `json:"prompt,omitempty"`
-	Style        string    `json:"style,omitempty"`
-	Model        string    `json:"model,omitempty"`
-	GeneratedAt  time.Time `json:"generated_at"`
-	TemplateHint string    `json:"template_hint,omitempty"`
+	FrameID        string                 `json:"frame_id"`
+	Signature      string                 `json:"signature"`
+	Prompt         string                 `json:"prompt,omitempty"`
+	NegativePrompt string                 `json:"negative_prompt,omitempty"`
+	Style          string                 `json:"style,omitempty"`
+	Model          string                 `json:"model,omitempty"`
+	ModelParams    map[string]interface{} `json:"model_params,omitempty"`
+	Width          int                    `json:"width,omitempty"`
+	Height         int                    `json:"height,omitempty"`
+	Seed           int64                  `json:"seed,omitempty"`
+	SeedStrategy   string                 `json:"seed_strategy,omitempty"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+	TemplateHint   string                 `json:"template_hint,omitempty"`
+
+	// Reference images (SHA-256 of the bytes) and the effective denoising strength of the
+	// render, so a reproduce can feed back exactly the same inputs.
+	ReferenceImage    string   `json:"reference_image,omitempty"`
+	ReferenceImages   []string `json:"reference_images,omitempty"`
+	DenoisingStrength float64  `json:"denoising_strength,omitempty"`
 }
@@ -0,0 +1,67 @@
+// internal/models/comic_campaign.go
+package models
+
+import "time"
+
+// Comic pipeline steps a campaign can run, in order.
+const (
+	ComicCampaignStepAnalysis    = "analysis"
+	ComicCampaignStepPrompts     = "prompts"
+	ComicCampaignStepKeyElements = "key_elements"
+	ComicCampaignStepGenerate    = "generate"
+)
+
+// ComicCampaignSteps lists all campaign steps in pipeline order.
+var ComicCampaignSteps = []string{
+	ComicCampaignStepAnalysis,
+	ComicCampaignStepPrompts,
+	ComicCampaignStepKeyElements,
+	ComicCampaignStepGenerate,
+}
+
+// Campaign and per-scene statuses.
+const (
+	ComicCampaignStatusQueued    = "queued"
+	ComicCampaignStatusRunning   = "running"
+	ComicCampaignStatusCompleted = "completed"
+	// ComicCampaignStatusPartial means the campaign finished but some scenes failed.
+	ComicCampaignStatusPartial   = "partial"
+	ComicCampaignStatusFailed    = "failed"
+	ComicCampaignStatusCancelled = "cancelled"
+)
+
+// ComicCampaignScene is the progress of one scene inside a campaign.
+type ComicCampaignScene struct {
+	SceneID        string     `json:"scene_id"`
+	Status         string     `json:"status"`
+	Step           string     `json:"step,omitempty"`
+	TaskID         string     `json:"task_id,omitempty"`
+	Provider       string     `json:"provider,omitempty"`
+	CompletedSteps []string   `json:"completed_steps,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// ComicCampaign runs a range of comic pipeline steps over many scenes.
+// Its ID is also the progress task id of the aggregated progress stream.
+// The record is persisted so an unfinished campaign resumes after a restart.
+// CreatedBy is the user who started it; only that user and admins can see or cancel it.
+type ComicCampaign struct {
+	ID                       string               `json:"id"`
+	CreatedBy                string               `json:"created_by,omitempty"`
+	Status                   string               `json:"status"`
+	Steps                    []string             `json:"steps"`
+	Scenes                   []ComicCampaignScene `json:"scenes"`
+	MaxParallelScenes        int                  `json:"max_parallel_scenes"`
+	VisionConcurrency        map[string]int       `json:"vision_concurrency,omitempty"`
+	DefaultVisionConcurrency int                  `json:"default_vision_concurrency"`
+	TargetFrames             int                  `json:"target_frames,omitempty"`
+	Style                    string               `json:"style,omitempty"`
+	Resume                   bool                 `json:"resume,omitempty"`
+	Progress                 int                  `json:"progress"`
+	Error                    string               `json:"error,omitempty"`
+	CreatedAt                time.Time            `json:"created_at"`
+	UpdatedAt                time.Time            `json:"updated_at"`
+	FinishedAt               *time.Time           `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,48 @@
+// internal/models/comic_character_sheet.go
+package models
+
+import "time"
+
+// Standard model-sheet views. Custom view names (e.g. "expression_angry") are also allowed.
+const (
+	ComicSheetViewFront        = "front"
+	ComicSheetViewThreeQuarter = "three_quarter"
+	ComicSheetViewProfile      = "profile"
+	ComicSheetViewBack         = "back"
+	ComicSheetViewExpressions  = "expressions"
+)
+
+// Sources of a character sheet view.
+const (
+	ComicSheetSourceUploaded  = "uploaded"
+	ComicSheetSourceGenerated = "generated"
+)
+
+// ComicCharacterSheetView is one reference view of a character.
+type ComicCharacterSheetView struct {
+	View        string    `json:"view"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type,omitempty"`
+	SizeBytes   int64     `json:"size_bytes,omitempty"`
+	Source      string    `json:"source"`
+	Prompt      string    `json:"prompt,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ComicCharacterSheet is the model sheet of a single character (key element).
+// Name and Aliases are matched against frame descriptions and prompts to pick references per frame.
+type ComicCharacterSheet struct {
+	ElementID   string                    `json:"element_id"`
+	Name        string                    `json:"name"`
+	Aliases     []string                  `json:"aliases,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Views       []ComicCharacterSheetView `json:"views"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+}
+
+// ComicCharacterSheetIndex is persisted to data/comics/scene_<id>/references/sheets/index.json.
+type ComicCharacterSheetIndex struct {
+	SceneID   string                         `json:"scene_id"`
+	Sheets    map[string]ComicCharacterSheet `json:"sheets"`
+	UpdatedAt time.Time                      `json:"updated_at"`
+}
@@ -0,0 +1,78 @@
+// internal/models/comic_frame_version.go
+package models
+
+import "time"
+
+// Sources of a frame image version.
+const (
+	// ComicFrameVersionSourceOriginal is an image that was on disk before it was tracked.
+	ComicFrameVersionSourceOriginal   = "original"
+	ComicFrameVersionSourceGenerate   = "generate"
+	ComicFrameVersionSourceRegenerate = "regenerate"
+	ComicFrameVersionSourceInpaint    = "inpaint"
+	ComicFrameVersionSourceReproduce  = "reproduce"
+)
+
+// ComicFrameImageVersion is one stored revision of a frame image together with the
+// inputs that produced it.
+type ComicFrameImageVersion struct {
+	Version        int                       `json:"version"`
+	FileName       string                    `json:"file_name"`
+	Source         string                    `json:"source"`
+	Prompt         string                    `json:"prompt,omitempty"`
+	NegativePrompt string                    `json:"negative_prompt,omitempty"`
+	Model          string                    `json:"model,omitempty"`
+	Seed           int64                     `json:"seed,omitempty"`
+	Width          int                       `json:"width,omitempty"`
+	Height         int                       `json:"height,omitempty"`
+	Signature      *ComicFrameImageSignature `json:"signature,omitempty"`
+	// Pinned versions are never removed by garbage collection.
+	Pinned    bool      `json:"pinned,omitempty"`
+	SizeBytes int64     `json:"size_bytes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ComicFrameImageVersions is persisted to data/comics/scene_<id>/images/versions/<frame_id>/index.json.
+// Current is the version copied to images/<frame_id>.png.
+type ComicFrameImageVersions struct {
+	SceneID   string                   `json:"scene_id"`
+	FrameID   string                   `json:"frame_id"`
+	Current   int                      `json:"current"`
+	Versions  []ComicFrameImageVersion `json:"versions"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+// ComicFrameVersionPixelDiff summarises how two version images differ.
+// Metrics are only filled when both images have the same size.
+type ComicFrameVersionPixelDiff struct {
+	SameSize bool `json:"same_size"`
+	// MeanAbsDiff is the mean absolute RGB difference, 0 (identical) .. 1.
+	MeanAbsDiff float64 `json:"mean_abs_diff"`
+	// ChangedRatio is the share of pixels whose largest channel difference exceeds 16/255.
+	ChangedRatio float64 `json:"changed_ratio"`
+}
+
+// ComicFrameVersionComparison is the result of comparing two versions of one frame.
+type ComicFrameVersionComparison struct {
+	SceneID       string                      `json:"scene_id"`
+	FrameID       string                      `json:"frame_id"`
+	A             ComicFrameImageVersion      `json:"a"`
+	B             ComicFrameImageVersion      `json:"b"`
+	ChangedFields []string                    `json:"changed_fields"`
+	Pixels        *ComicFrameVersionPixelDiff `json:"pixels,omitempty"`
+}
+
+// ComicFrameVersionGCResult lists the versions removed (or, for a dry run, that would be removed).
+type ComicFrameVersionGCResult struct {
+	SceneID    string                     `json:"scene_id"`
+	DryRun     bool                       `json:"dry_run"`
+	Deleted    []ComicFrameVersionGCEntry `json:"deleted"`
+	FreedBytes int64                      `json:"freed_bytes"`
+}
+
+// ComicFrameVersionGCEntry identifies one collected version.
+type ComicFrameVersionGCEntry struct {
+	FrameID   string `json:"frame_id"`
+	Version   int    `json:"version"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+}
@@ -0,0 +1,83 @@
+// internal/models/comic_page.go
+package models
+
+import "time"
+
+// Balloon kinds supported by the page composer.
+const (
+	ComicBalloonSpeech  = "speech"
+	ComicBalloonThought = "thought"
+	ComicBalloonCaption = "caption"
+)
+
+// ComicRect is an axis-aligned rectangle in pixels.
+// Panel rects are page coordinates; balloon rects are relative to their panel.
+type ComicRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// ComicPoint is a pixel position relative to the owning panel.
+type ComicPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// ComicBalloon is a speech/thought balloon or a narration caption placed on a panel.
+type ComicBalloon struct {
+	ID       string      `json:"id"`
+	Kind     string      `json:"kind"` // speech / thought / caption
+	Speaker  string      `json:"speaker,omitempty"`
+	Text     string      `json:"text"`
+	Rect     ComicRect   `json:"rect"`
+	Tail     *ComicPoint `json:"tail,omitempty"`      // speech/thought only; where the tail points to
+	FontSize int         `json:"font_size,omitempty"` // 0 = layout default; text shrinks to fit the rect
+	// Provenance of the text, mirrors ComicPromptSources.
+	SourceNodeID         string `json:"source_node_id,omitempty"`
+	SourceConversationID string `json:"source_conversation_id,omitempty"`
+}
+
+// ComicPanel places one generated frame image on a page.
+type ComicPanel struct {
+	FrameID  string         `json:"frame_id"`
+	Rect     ComicRect      `json:"rect"`
+	Fit      string         `json:"fit,omitempty"` // cover (default) / contain
+	Balloons []ComicBalloon `json:"balloons,omitempty"`
+}
+
+// ComicPage is a single composed page.
+type ComicPage struct {
+	ID         string       `json:"id"`
+	Index      int          `json:"index"`
+	Template   string       `json:"template"`
+	Panels     []ComicPanel `json:"panels"`
+	ImagePath  string       `json:"image_path,omitempty"` // relative to data/comics
+	RenderedAt time.Time    `json:"rendered_at,omitempty"`
+}
+
+// ComicPageLayout is the editable page composition of a scene.
+// It is persisted to data/comics/scene_<id>/pages/layout.json; rendered PNGs live next to it.
+type ComicPageLayout struct {
+	SceneID     string      `json:"scene_id"`
+	PageWidth   int         `json:"page_width"`
+	PageHeight  int         `json:"page_height"`
+	Margin      int         `json:"margin"`
+	Gutter      int         `json:"gutter"`
+	BorderWidth int         `json:"border_width"`
+	FontSize    int         `json:"font_size"`
+	Background  string      `json:"background,omitempty"` // #RRGGBB
+	Pages       []ComicPage `json:"pages"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	// Warnings collected during the last render (missing frame images, missing glyphs, ...).
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ComicPageTemplateInfo describes a built-in panel grid.
+type ComicPageTemplateInfo struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Panels int    `json:"panels"`
+}
@@ -0,0 +1,25 @@
+// internal/models/comic_seed.go
+package models
+
+import "time"
+
+// Seed strategies for comic frame rendering.
+const (
+	// ComicSeedStrategyRandom picks a new random seed for every render and records it.
+	ComicSeedStrategyRandom = "random"
+	// ComicSeedStrategyFixed uses BaseSeed for every frame.
+	ComicSeedStrategyFixed = "fixed"
+	// ComicSeedStrategyPerFrame derives a stable seed from BaseSeed and the frame id.
+	ComicSeedStrategyPerFrame = "per_frame"
+	// ComicSeedStrategyExplicit is only recorded in signatures, for seeds set in model_params.
+	ComicSeedStrategyExplicit = "explicit"
+)
+
+// ComicSeedSettings is persisted to data/comics/scene_<id>/seed.json.
+// A seed in a frame's model_params always takes precedence over the strategy.
+type ComicSeedSettings struct {
+	SceneID   string    `json:"scene_id"`
+	Strategy  string    `json:"strategy"`
+	BaseSeed  int64     `json:"base_seed,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
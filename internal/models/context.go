@@ -28,10 +28,13 @@ type Conversation struct {
 
 // Memory 表示角色记忆的关键信息
 type Memory struct {
-	CharacterID string    `json:"character_id"`
-	Key         string    `json:"key"`        // 记忆的关键点
-	Details     string    `json:"details"`    // 详细内容
-	Importance  int       `json:"importance"` // 重要性 1-10
-	CreatedAt   time.Time `json:"created_at"`
-	References  []string  `json:"references"` // 引用的对话ID
+	ID           string    `json:"id"`
+	SceneID      string    `json:"scene_id,omitempty"`
+	CharacterID  string    `json:"character_id"`
+	Key          string    `json:"key"`        // 记忆的关键点
+	Details      string    `json:"details"`    // 详细内容
+	Importance   int       `json:"importance"` // 重要性 1-10
+	CreatedAt    time.Time `json:"created_at"`
+	LastAccessed time.Time `json:"last_accessed"` // 最近一次被检索或强化的时间
+	References   []string  `json:"references"`    // 引用的对话ID
 }
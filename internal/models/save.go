@@ -0,0 +1,88 @@
+// internal/models/save.go
+package models
+
+import "time"
+
+// AutoSaveSlotID 读档前自动备份当前进度使用的存档ID
+const AutoSaveSlotID = "autosave"
+
+// SaveSlot 场景存档：某一时刻故事进度、对话、物品与角色状态的完整快照
+type SaveSlot struct {
+	ID          string          `json:"id"`
+	SceneID     string          `json:"scene_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Auto        bool            `json:"auto,omitempty"` // 读档前自动生成的备份
+	Summary     SaveSlotSummary `json:"summary"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	// Snapshot 列表接口不返回快照内容
+	Snapshot *SceneSnapshot `json:"snapshot,omitempty"`
+}
+
+// SaveSlotSummary 存档概要，便于在列表中区分不同存档
+type SaveSlotSummary struct {
+	Progress      int    `json:"progress"`
+	CurrentState  string `json:"current_state,omitempty"`
+	CurrentNodeID string `json:"current_node_id,omitempty"` // 最后一个已揭示的节点
+	RevealedNodes int    `json:"revealed_nodes"`
+	TotalNodes    int    `json:"total_nodes"`
+	Conversations int    `json:"conversations"`
+	Items         int    `json:"items"`
+	Characters    int    `json:"characters"`
+}
+
+// SceneSnapshot 场景可变状态的快照
+type SceneSnapshot struct {
+	Story      *StoryData          `json:"story,omitempty"`
+	Context    SceneContext        `json:"context"`
+	Items      []*Item             `json:"items"`
+	Characters []*Character        `json:"characters"`
+	Memories   map[string][]Memory `json:"memories,omitempty"` // characterID -> 长期记忆
+}
+
+// SaveSlotDiff 两个存档（或存档与当前进度）之间的差异，From 为基准
+type SaveSlotDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	ProgressFrom int `json:"progress_from"`
+	ProgressTo   int `json:"progress_to"`
+	// 仅在一侧已揭示的节点ID
+	NodesOnlyInFrom []string `json:"nodes_only_in_from"`
+	NodesOnlyInTo   []string `json:"nodes_only_in_to"`
+	// 仅在一侧被选中的选项（节点ID:选项ID）
+	ChoicesOnlyInFrom []string `json:"choices_only_in_from"`
+	ChoicesOnlyInTo   []string `json:"choices_only_in_to"`
+
+	ConversationsFrom int `json:"conversations_from"`
+	ConversationsTo   int `json:"conversations_to"`
+	// 按对话ID比较
+	ConversationsOnlyInFrom int `json:"conversations_only_in_from"`
+	ConversationsOnlyInTo   int `json:"conversations_only_in_to"`
+
+	ItemsOnlyInFrom []string     `json:"items_only_in_from"` // 物品名称
+	ItemsOnlyInTo   []string     `json:"items_only_in_to"`
+	ItemsChanged    []ItemChange `json:"items_changed"`
+
+	RelationshipChanges []RelationshipChange `json:"relationship_changes"`
+
+	MemoriesOnlyInFrom int `json:"memories_only_in_from"`
+	MemoriesOnlyInTo   int `json:"memories_only_in_to"`
+}
+
+// ItemChange 同一物品在两个快照间变化的字段
+type ItemChange struct {
+	ItemID string   `json:"item_id"`
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"` // location / is_owned / description / properties
+}
+
+// RelationshipChange 角色对某个对象的关系描述变化；From 或 To 为空表示新增或移除
+type RelationshipChange struct {
+	CharacterID   string `json:"character_id"`
+	CharacterName string `json:"character_name"`
+	Target        string `json:"target"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+}
@@ -0,0 +1,34 @@
+// internal/models/user_auth.go
+package models
+
+import "time"
+
+// UserCredential 用户登录凭据（密码只保存 bcrypt 哈希）
+type UserCredential struct {
+	UserID            string    `json:"user_id"`
+	Username          string    `json:"username"`
+	PasswordHash      string    `json:"password_hash"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	// TokensValidAfter 之前签发的会话令牌全部失效（修改密码时更新）
+	TokensValidAfter time.Time `json:"tokens_valid_after"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// APIToken 个人 API 令牌（供脚本与 CI 使用的长期令牌，明文仅在创建时返回一次）
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 明文前若干位，便于用户辨认
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RevokedToken 服务端吊销的会话令牌（保留到原过期时间为止）
+type RevokedToken struct {
+	TokenID   string    `json:"token_id"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
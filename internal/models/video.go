@@ -27,6 +27,11 @@ type VideoConfig struct {
 	AudioURL          string             `json:"audio_url,omitempty"`
 	ImageURL          string             `json:"img_url,omitempty"`
 	PromptOptions     VideoPromptOptions `json:"prompt_options,omitempty"`
+	// NarrationEnabled mixes per-clip TTS voice-over of Narration into the merged MP4.
+	NarrationEnabled bool   `json:"narration_enabled,omitempty"`
+	NarrationVoice   string `json:"narration_voice,omitempty"`
+	// BurnSubtitles renders the narration subtitles into the merged MP4 frames.
+	BurnSubtitles bool `json:"burn_subtitles,omitempty"`
 }
 
 // VideoPromptOptions captures prompt-assembly controls used to enrich image-to-video prompts.
@@ -66,6 +71,10 @@ type VideoTimelineClipPatch struct {
 	Prompt            *string                  `json:"prompt,omitempty"`
 	NegativePrompt    *string                  `json:"negative_prompt,omitempty"`
 	ReferenceImageURL *string                  `json:"reference_image_url,omitempty"`
+	Narration         *string                  `json:"narration,omitempty"`
+	CameraMotion      *string                  `json:"camera_motion,omitempty"`
+	Transition        *string                  `json:"transition,omitempty"`
+	DurationSec       *float64                 `json:"duration_sec,omitempty"`
 	PromptOptions     *VideoPromptOptionsPatch `json:"prompt_options,omitempty"`
 }
 
@@ -106,6 +115,9 @@ type VideoTimeline struct {
 	PromptExtend      bool                `json:"prompt_extend,omitempty"`
 	AudioURL          string              `json:"audio_url,omitempty"`
 	PromptOptions     VideoPromptOptions  `json:"prompt_options,omitempty"`
+	NarrationEnabled  bool                `json:"narration_enabled,omitempty"`
+	NarrationVoice    string              `json:"narration_voice,omitempty"`
+	BurnSubtitles     bool                `json:"burn_subtitles,omitempty"`
 	Clips             []VideoTimelineClip `json:"clips"`
 	GeneratedAt       time.Time           `json:"generated_at"`
 	UpdatedAt         time.Time           `json:"updated_at"`
@@ -133,6 +145,7 @@ type VideoClipRequest struct {
 	ContinuityMode     string  `json:"continuity_mode,omitempty"`
 	PreviousFramePath  string  `json:"previous_frame_path,omitempty"`
 	NextFramePath      string  `json:"next_frame_path,omitempty"`
+	NextCameraMotion   string  `json:"next_camera_motion,omitempty"`
 }
 
 // VideoReferenceUploadRequest captures a local frame image that should be uploaded to a provider-accessible store.
@@ -169,13 +182,33 @@ type VideoClipResult struct {
 	ProviderPayload map[string]interface{} `json:"provider_payload,omitempty"`
 }
 
+// VideoNarrationRequest is the normalized request sent to a TTS provider for one clip's narration.
+type VideoNarrationRequest struct {
+	SceneID      string `json:"scene_id"`
+	VideoVersion string `json:"video_version"`
+	FrameID      string `json:"frame_id"`
+	Text         string `json:"text"`
+	Voice        string `json:"voice,omitempty"`
+	Language     string `json:"language,omitempty"`
+}
+
+// VideoNarrationResult is the synthesized voice-over audio for one clip.
+type VideoNarrationResult struct {
+	Audio       []byte  `json:"-"`
+	Format      string  `json:"format"` // wav / mp3 ...
+	DurationSec float64 `json:"duration_sec,omitempty"`
+	Provider    string  `json:"provider,omitempty"`
+}
+
 // VideoProviderTask is the provider-facing async task shape used by DashScope-like providers.
+// Providers that render on this host set LocalPath (relative to the video repository) instead of ResultURL.
 type VideoProviderTask struct {
 	TaskID         string                 `json:"task_id"`
 	Status         string                 `json:"status,omitempty"`
 	ResultURL      string                 `json:"result_url,omitempty"`
 	ErrorMessage   string                 `json:"error_message,omitempty"`
 	ProviderStatus string                 `json:"provider_status,omitempty"`
+	LocalPath      string                 `json:"local_path,omitempty"`
 	Raw            map[string]interface{} `json:"raw,omitempty"`
 }
 
@@ -198,6 +231,10 @@ type VideoMeta struct {
 	RenderArtifactPath  string    `json:"render_artifact_path,omitempty"`
 	RenderArtifactType  string    `json:"render_artifact_type,omitempty"`
 	Degraded            bool      `json:"degraded,omitempty"`
+	RenderDowngrades    []string  `json:"render_downgrades,omitempty"`
+	SubtitleSRTPath     string    `json:"subtitle_srt_path,omitempty"`
+	SubtitleVTTPath     string    `json:"subtitle_vtt_path,omitempty"`
+	NarrationStatus     string    `json:"narration_status,omitempty"`
 	LastError           string    `json:"last_error,omitempty"`
 	CreatedAt           time.Time `json:"created_at"`
 	UpdatedAt           time.Time `json:"updated_at"`
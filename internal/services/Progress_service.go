@@ -23,6 +23,7 @@ type ProgressUpdate struct {
 	StageTotal     int    `json:"stage_total,omitempty"`      // 可选：总阶段数
 	ProviderTaskID string `json:"provider_task_id,omitempty"` // 可选：上游 provider 任务 ID
 	ProviderStatus string `json:"provider_status,omitempty"`  // 可选：上游 provider 状态
+	SceneID        string `json:"scene_id,omitempty"`         // 可选：事件关联场景 ID（批量任务）
 }
 
 type ProgressEventMeta struct {
@@ -32,6 +33,7 @@ type ProgressEventMeta struct {
 	StageTotal     int
 	ProviderTaskID string
 	ProviderStatus string
+	SceneID        string
 }
 
 type ProgressSnapshot struct {
@@ -158,6 +160,7 @@ func (t *ProgressTracker) EmitProgressEventWithMeta(progress int, message string
 		update.StageTotal = meta.StageTotal
 		update.ProviderTaskID = strings.TrimSpace(meta.ProviderTaskID)
 		update.ProviderStatus = strings.TrimSpace(meta.ProviderStatus)
+		update.SceneID = strings.TrimSpace(meta.SceneID)
 	}
 
 	t.notifySubscribers(update, false)
@@ -275,6 +278,29 @@ func (t *ProgressTracker) Fail(errorMsg string) {
 	}
 }
 
+// Reopen 将已结束的跟踪器恢复为运行状态（任务队列重试时使用）
+func (t *ProgressTracker) Reopen(message string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.Message = message
+	t.Status = "running"
+	t.UpdateTime = time.Now()
+
+	select {
+	case <-t.Done:
+		t.Done = make(chan struct{})
+	default:
+	}
+
+	t.notifySubscribers(ProgressUpdate{
+		Progress:  t.Progress,
+		Message:   t.Message,
+		Status:    "running",
+		EventType: normalizeProgressEventType(""),
+	}, false)
+}
+
 // Subscribe 订阅进度更新
 func (t *ProgressTracker) Subscribe() chan ProgressUpdate {
 	t.mutex.Lock()
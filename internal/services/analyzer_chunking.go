@@ -0,0 +1,719 @@
+// internal/services/analyzer_chunking.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+// 长文本分块分析（map-reduce）参数
+const (
+	// analysisChunkRunes 单个分析块的最大长度，与单次提取提示词使用的文本窗口一致
+	analysisChunkRunes = 5000
+	// analysisChunkConcurrency 同时进行的分块分析请求数
+	analysisChunkConcurrency = 3
+	// analysisChunkTimeout 单个分块的 LLM 调用超时
+	analysisChunkTimeout = 90 * time.Second
+	// maxMergedKnowledge 合并后每个角色保留的知识条目上限
+	maxMergedKnowledge = 20
+)
+
+// analysisChunk 待分析的文本块；Title 为章节名或「片段 N」
+type analysisChunk struct {
+	Index int
+	Title string
+	Text  string
+}
+
+// chunkAnalysis 单个分块的分析结果（map 阶段输出）
+type chunkAnalysis struct {
+	Summary    string `json:"summary"`
+	Characters []struct {
+		Name          string            `json:"name"`
+		Aliases       []string          `json:"aliases"`
+		Role          string            `json:"role"`
+		Description   string            `json:"description"`
+		Personality   string            `json:"personality"`
+		Background    string            `json:"background"`
+		SpeechStyle   string            `json:"speech_style"`
+		Relationships map[string]string `json:"relationships"`
+		Knowledge     []string          `json:"knowledge"`
+	} `json:"characters"`
+	Locations []struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"locations"`
+	Items []struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Location    string `json:"location"`
+	} `json:"items"`
+	Scenes []struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Atmosphere  string   `json:"atmosphere"`
+		Era         string   `json:"era"`
+		Themes      []string `json:"themes"`
+		Items       []string `json:"items"`
+	} `json:"scenes"`
+}
+
+// needsChunkedAnalysis 文本超过单个分析窗口时需要分块分析，否则超出部分会被截断丢弃
+func needsChunkedAnalysis(text string) bool {
+	return utf8.RuneCountInString(strings.TrimSpace(text)) > analysisChunkRunes
+}
+
+// AnalysisTimeout 根据文本长度估算完整分析所需的超时时间
+func (s *AnalyzerService) AnalysisTimeout(text string) time.Duration {
+	base := 5 * time.Minute
+	if !needsChunkedAnalysis(text) {
+		return base
+	}
+	chunks := len(buildAnalysisChunks(text, nil, isEnglishText(text)))
+	rounds := (chunks + analysisChunkConcurrency - 1) / analysisChunkConcurrency
+	return base + time.Duration(rounds)*analysisChunkTimeout
+}
+
+// buildAnalysisChunks 切分分析块：提供章节片段时按章节边界切分（短章节合并、长章节再拆分），
+// 否则按段落边界切分全文
+func buildAnalysisChunks(text string, segments []models.OriginalSegment, isEnglish bool) []analysisChunk {
+	var chunks []analysisChunk
+	add := func(title, body string) {
+		for i, part := range splitTextIntoSegments(body, analysisChunkRunes) {
+			partTitle := title
+			if i > 0 {
+				partTitle = fmt.Sprintf("%s (%d)", title, i+1)
+			}
+			chunks = append(chunks, analysisChunk{Index: len(chunks), Title: partTitle, Text: part})
+		}
+	}
+
+	if len(segments) == 0 {
+		for i, part := range splitTextIntoSegments(text, analysisChunkRunes) {
+			title := fmt.Sprintf(pickLocale(isEnglish, "Part %d", "片段 %d"), i+1)
+			chunks = append(chunks, analysisChunk{Index: i, Title: title, Text: part})
+		}
+		return chunks
+	}
+
+	var titles []string
+	var body strings.Builder
+	flush := func() {
+		if strings.TrimSpace(body.String()) != "" {
+			add(strings.Join(titles, " / "), body.String())
+		}
+		titles = nil
+		body.Reset()
+	}
+	for _, seg := range segments {
+		segText := strings.TrimSpace(seg.OriginalText)
+		if segText == "" {
+			continue
+		}
+		if body.Len() > 0 && utf8.RuneCountInString(body.String())+utf8.RuneCountInString(segText) > analysisChunkRunes {
+			flush()
+		}
+		titles = append(titles, seg.Title)
+		if body.Len() > 0 {
+			body.WriteString("\n\n")
+		}
+		body.WriteString(segText)
+	}
+	flush()
+	return chunks
+}
+
+// AnalyzeTextInChunks 对长文本执行 map-reduce 分析：逐块（章节）独立提取角色、地点、物品与场景，
+// 再按别名合并角色、去重地点与物品并整合关系。segments 非空时按章节边界分块；tracker 可为 nil。
+func (s *AnalyzerService) AnalyzeTextInChunks(ctx context.Context, text, title string, segments []models.OriginalSegment, tracker *ProgressTracker) (*models.AnalysisResult, error) {
+	if s.LLMService == nil || !s.LLMService.IsReady() {
+		return nil, fmt.Errorf("%w: %s", ErrLLMNotReady, "LLM服务未配置或未就绪，请先在设置页面配置API密钥")
+	}
+
+	s.semaphore <- struct{}{}
+	defer func() { <-s.semaphore }()
+
+	return s.analyzeChunks(ctx, text, title, segments, tracker, 10, 80)
+}
+
+// analyzeChunks 分块分析主流程（调用方已持有并发许可）。
+// map 阶段的进度映射到 [progressFrom, progressTo]，其后依次为关系整合与摘要合并。
+func (s *AnalyzerService) analyzeChunks(ctx context.Context, text, title string, segments []models.OriginalSegment, tracker *ProgressTracker, progressFrom, progressTo int) (*models.AnalysisResult, error) {
+	isEnglish := isEnglishText(title + " " + truncateText(text, 2000))
+	chunks := buildAnalysisChunks(text, segments, isEnglish)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("文本为空，无法分析")
+	}
+
+	reportProgress(tracker, progressFrom, fmt.Sprintf(pickLocale(isEnglish, "Analyzing %d chunks...", "共 %d 个分块，开始逐块分析..."), len(chunks)))
+
+	// map：逐块分析，失败的分块记录后跳过；用量配额用完时停止其余分块
+	mapCtx, cancelMap := context.WithCancel(ctx)
+	defer cancelMap()
+	results := make([]*chunkAnalysis, len(chunks))
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		done      int
+		failed    int
+		lastError error
+		quotaErr  error
+	)
+	limiter := make(chan struct{}, analysisChunkConcurrency)
+	for i := range chunks {
+		wg.Add(1)
+		go func(chunk analysisChunk) {
+			defer wg.Done()
+			select {
+			case limiter <- struct{}{}:
+			case <-mapCtx.Done():
+				return
+			}
+			defer func() { <-limiter }()
+
+			analysis, err := s.analyzeChunk(mapCtx, chunk, title, isEnglish)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				failed++
+				lastError = err
+				if errors.Is(err, ErrUsageQuotaExceeded) && quotaErr == nil {
+					quotaErr = err
+					cancelMap()
+				}
+				utils.GetLogger().Warn("分块分析失败", map[string]interface{}{"chunk": chunk.Index, "title": chunk.Title, "err": err})
+			} else {
+				results[chunk.Index] = analysis
+			}
+			progress := progressFrom + (progressTo-progressFrom)*done/len(chunks)
+			reportProgress(tracker, progress, fmt.Sprintf(pickLocale(isEnglish, "Analyzed %d/%d: %s", "已分析 %d/%d：%s"), done, len(chunks), chunk.Title))
+		}(chunks[i])
+	}
+	wg.Wait()
+
+	if quotaErr != nil {
+		return nil, quotaErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if failed == len(chunks) {
+		if errors.Is(lastError, ErrLLMNotReady) {
+			return nil, lastError
+		}
+		return nil, fmt.Errorf("所有分块分析均失败: %w", lastError)
+	}
+
+	// reduce：合并各分块结果
+	result := mergeChunkAnalyses(results, isEnglish)
+	result.Title = title
+	result.TextLength = utf8.RuneCountInString(text)
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["is_english"] = isEnglish
+	result.Metadata["text_length"] = len(text)
+	result.Metadata["analysis_chunks"] = len(chunks)
+	result.Metadata["failed_chunks"] = failed
+
+	reportProgress(tracker, progressTo+5, pickLocale(isEnglish, "Reconciling character relationships...", "整合角色关系..."))
+	if len(result.Characters) > 1 && len(result.Characters) <= 40 {
+		relCtx, cancel := context.WithTimeout(ctx, analysisChunkTimeout)
+		if err := s.buildCharacterRelationships(relCtx, result.Characters); err != nil {
+			// 合并阶段已得到基本关系，LLM 补全失败不影响结果
+			utils.GetLogger().Warn("补全角色关系失败", map[string]interface{}{"err": err})
+		}
+		cancel()
+	}
+
+	reportProgress(tracker, progressTo+10, pickLocale(isEnglish, "Merging chapter summaries...", "合并章节摘要..."))
+	result.Summary = s.reduceSummaries(ctx, title, chunks, results, isEnglish)
+
+	return result, nil
+}
+
+// analyzeChunk 对单个分块进行一次结构化提取
+func (s *AnalyzerService) analyzeChunk(ctx context.Context, chunk analysisChunk, title string, isEnglish bool) (*chunkAnalysis, error) {
+	var prompt, systemPrompt string
+	if isEnglish {
+		systemPrompt = `You are a structured data extraction specialist for long novels. You must output only a single valid JSON object matching the requested schema. No explanations, no markdown, no extra keys.`
+		prompt = fmt.Sprintf(`This is section "%s" of the book titled "%s". Extract everything that appears in THIS section only, as a JSON object:
+{
+  "summary": string (2-3 sentences),
+  "characters": [{"name": string, "aliases": [string], "role": string, "description": string, "personality": string, "background": string, "speech_style": string, "relationships": {"other character name": "relationship"}, "knowledge": [string]}],
+  "locations": [{"name": string, "description": string}],
+  "items": [{"name": string, "description": string, "location": string}],
+  "scenes": [{"name": string, "description": string, "atmosphere": string, "era": string, "themes": [string], "items": [string]}]
+}
+
+Requirements:
+- Use each character's full canonical name as "name"; list nicknames, titles and other forms of address in "aliases".
+- Use empty strings or arrays for unknown fields.
+- Return ONLY the JSON object.
+
+Text:
+%s`, chunk.Title, title, chunk.Text)
+	} else {
+		systemPrompt = `你是长篇小说的结构化数据抽取专家。必须只输出一个符合指定结构的 JSON 对象，禁止添加说明或 Markdown。`
+		prompt = fmt.Sprintf(`以下是《%s》的「%s」部分。只提取本部分中出现的信息，按以下 JSON 对象返回：
+{
+  "summary": "本部分的情节摘要（2-3句）",
+  "characters": [{"name": "角色全名", "aliases": ["别名、称号、昵称"], "role": "", "description": "", "personality": "", "background": "", "speech_style": "", "relationships": {"其他角色名": "关系"}, "knowledge": [""]}],
+  "locations": [{"name": "", "description": ""}],
+  "items": [{"name": "", "description": "", "location": ""}],
+  "scenes": [{"name": "", "description": "", "atmosphere": "", "era": "", "themes": [""], "items": [""]}]
+}
+
+要求：
+- "name" 使用角色最完整的正式名字，其它称呼（字、号、昵称、头衔）放入 "aliases"。
+- 缺失信息用空字符串或空数组表示。
+- 只能输出上述 JSON 对象。
+
+文本：
+%s`, title, chunk.Title, chunk.Text)
+	}
+
+	chunkCtx, cancel := context.WithTimeout(ctx, analysisChunkTimeout)
+	defer cancel()
+
+	var analysis chunkAnalysis
+	if err := s.LLMService.CreateStructuredCompletion(WithLLMTask(chunkCtx, LLMTaskAnalysis), prompt, systemPrompt, &analysis); err != nil {
+		return nil, fmt.Errorf("分析「%s」失败: %w", chunk.Title, err)
+	}
+	return &analysis, nil
+}
+
+// reduceSummaries 将各分块摘要合并为全书摘要；只有一个分块或合并失败时直接拼接
+func (s *AnalyzerService) reduceSummaries(ctx context.Context, title string, chunks []analysisChunk, results []*chunkAnalysis, isEnglish bool) string {
+	// 标题与摘要分开保存：章节标题本身可能含有冒号，拼接后无法可靠拆回
+	type sectionSummary struct {
+		title   string
+		summary string
+	}
+	var sections []sectionSummary
+	for i, r := range results {
+		if r == nil || strings.TrimSpace(r.Summary) == "" {
+			continue
+		}
+		sections = append(sections, sectionSummary{title: chunks[i].Title, summary: truncateText(strings.TrimSpace(r.Summary), 300)})
+	}
+	if len(sections) == 0 {
+		return ""
+	}
+	if len(sections) == 1 {
+		return sections[0].summary
+	}
+
+	lines := make([]string, 0, len(sections))
+	for _, sec := range sections {
+		lines = append(lines, fmt.Sprintf("%s: %s", sec.title, sec.summary))
+	}
+
+	outline := truncateText(strings.Join(lines, "\n"), 12000)
+	var prompt, systemPrompt string
+	if isEnglish {
+		systemPrompt = `You are a professional literary summary expert, skilled at creating concise yet comprehensive summaries for stories.`
+		prompt = fmt.Sprintf(`Below are section-by-section summaries of the book titled "%s". Write one concise summary of the whole book covering the main plot, characters and themes. Return JSON {"summary": string}.
+
+%s`, title, outline)
+	} else {
+		systemPrompt = `你是一个专业的文学摘要专家，擅长为故事创建简明而全面的摘要。`
+		prompt = fmt.Sprintf(`以下是《%s》各部分的摘要。请写一段覆盖全书主要情节、角色与主题的简明摘要，以 JSON {"summary": "..."} 返回。
+
+%s`, title, outline)
+	}
+
+	var response struct {
+		Summary string `json:"summary"`
+	}
+	summaryCtx, cancel := context.WithTimeout(ctx, analysisChunkTimeout)
+	defer cancel()
+	if err := s.LLMService.CreateStructuredCompletion(WithLLMTask(summaryCtx, LLMTaskAnalysis), prompt, systemPrompt, &response); err != nil || strings.TrimSpace(response.Summary) == "" {
+		utils.GetLogger().Warn("合并摘要失败，使用分段摘要", map[string]interface{}{"err": err})
+		return truncateText(strings.Join(lines, "\n"), 2000)
+	}
+	return strings.TrimSpace(response.Summary)
+}
+
+func reportProgress(tracker *ProgressTracker, progress int, message string) {
+	if tracker != nil {
+		tracker.UpdateProgress(progress, message)
+	}
+}
+
+// ---------------------------------------------------
+// reduce：合并分块结果
+
+// mergeChunkAnalyses 合并各分块结果：角色按名字/别名去重，地点、物品、场景按名称去重
+func mergeChunkAnalyses(results []*chunkAnalysis, isEnglish bool) *models.AnalysisResult {
+	result := &models.AnalysisResult{
+		Characters: mergeCharacters(results, isEnglish),
+		Locations:  []models.Location{},
+		Items:      []models.Item{},
+		Scenes:     []models.Scene{},
+	}
+
+	locationIndex := map[string]int{}
+	itemIndex := map[string]int{}
+	sceneIndex := map[string]int{}
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		for _, loc := range r.Locations {
+			key := entityKey(loc.Name)
+			if key == "" {
+				continue
+			}
+			if idx, ok := locationIndex[key]; ok {
+				result.Locations[idx].Description = longer(result.Locations[idx].Description, loc.Description)
+				continue
+			}
+			locationIndex[key] = len(result.Locations)
+			result.Locations = append(result.Locations, models.Location{
+				Name:        strings.TrimSpace(loc.Name),
+				Description: strings.TrimSpace(loc.Description),
+			})
+		}
+
+		for _, item := range r.Items {
+			key := entityKey(item.Name)
+			if key == "" {
+				continue
+			}
+			if idx, ok := itemIndex[key]; ok {
+				existing := &result.Items[idx]
+				existing.Description = longer(existing.Description, item.Description)
+				if existing.Location == "" {
+					existing.Location = strings.TrimSpace(item.Location)
+				}
+				continue
+			}
+			itemIndex[key] = len(result.Items)
+			result.Items = append(result.Items, models.Item{
+				Name:        strings.TrimSpace(item.Name),
+				Description: strings.TrimSpace(item.Description),
+				Location:    strings.TrimSpace(item.Location),
+			})
+		}
+
+		for _, sc := range r.Scenes {
+			key := entityKey(sc.Name)
+			if key == "" {
+				continue
+			}
+			idx, ok := sceneIndex[key]
+			if !ok {
+				idx = len(result.Scenes)
+				sceneIndex[key] = idx
+				result.Scenes = append(result.Scenes, models.Scene{Name: strings.TrimSpace(sc.Name)})
+			}
+			scene := &result.Scenes[idx]
+			scene.Description = longer(scene.Description, sc.Description)
+			if scene.Atmosphere == "" {
+				scene.Atmosphere = strings.TrimSpace(sc.Atmosphere)
+			}
+			if scene.Era == "" {
+				scene.Era = strings.TrimSpace(sc.Era)
+			}
+			scene.Themes = appendUnique(scene.Themes, sc.Themes...)
+			for _, name := range sc.Items {
+				if strings.TrimSpace(name) == "" || containsItemNamed(scene.Items, name) {
+					continue
+				}
+				scene.Items = append(scene.Items, models.Item{
+					Name:        strings.TrimSpace(name),
+					Description: pickLocale(isEnglish, "An item in the scene", "场景中的物品"),
+				})
+			}
+		}
+	}
+	return result
+}
+
+// mergeCharacters 以名字与别名为键做并查集，把不同分块中的同一角色合并；
+// 出现分块越多的角色越靠前，关系中的别名统一替换为规范名字
+func mergeCharacters(results []*chunkAnalysis, isEnglish bool) []models.Character {
+	type mention struct {
+		chunk   int
+		name    string
+		aliases []string
+		char    *models.Character
+	}
+
+	var mentions []mention
+	for ci, r := range results {
+		if r == nil {
+			continue
+		}
+		for _, c := range r.Characters {
+			name := strings.TrimSpace(c.Name)
+			if entityKey(name) == "" {
+				continue
+			}
+			mentions = append(mentions, mention{
+				chunk:   ci,
+				name:    name,
+				aliases: c.Aliases,
+				char: &models.Character{
+					Name:          name,
+					Role:          strings.TrimSpace(c.Role),
+					Description:   strings.TrimSpace(c.Description),
+					Personality:   strings.TrimSpace(c.Personality),
+					Background:    strings.TrimSpace(c.Background),
+					SpeechStyle:   strings.TrimSpace(c.SpeechStyle),
+					Relationships: c.Relationships,
+					Knowledge:     c.Knowledge,
+				},
+			})
+		}
+	}
+	if len(mentions) == 0 {
+		return []models.Character{}
+	}
+
+	// 并查集：共享名字或别名的提及属于同一角色
+	parent := make([]int, len(mentions))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	owner := map[string]int{}
+	for i, m := range mentions {
+		for _, key := range mentionKeys(m.name, m.aliases) {
+			if j, ok := owner[key]; ok {
+				if a, b := find(i), find(j); a != b {
+					if a < b {
+						parent[b] = a
+					} else {
+						parent[a] = b
+					}
+				}
+			} else {
+				owner[key] = i
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range mentions {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	type merged struct {
+		char    models.Character
+		members []int
+		chunks  int
+	}
+	all := make([]merged, 0, len(roots))
+	canonical := map[string]string{} // 名字/别名键 -> 规范名字
+	for _, root := range roots {
+		members := groups[root]
+
+		// 规范名字：作为主名字出现次数最多者，平局取最早出现的
+		nameCount := map[string]int{}
+		var names []string
+		for _, idx := range members {
+			name := mentions[idx].name
+			if nameCount[name] == 0 {
+				names = append(names, name)
+			}
+			nameCount[name]++
+		}
+		best := names[0]
+		for _, name := range names[1:] {
+			if nameCount[name] > nameCount[best] {
+				best = name
+			}
+		}
+
+		char := models.Character{Name: best, Relationships: map[string]string{}}
+		chunkSet := map[int]bool{}
+		roleCount := map[string]int{}
+		for _, idx := range members {
+			m := mentions[idx]
+			chunkSet[m.chunk] = true
+			if m.name != best {
+				char.Aliases = appendUnique(char.Aliases, m.name)
+			}
+			for _, alias := range m.aliases {
+				if usableAlias(alias) && strings.TrimSpace(alias) != best {
+					char.Aliases = appendUnique(char.Aliases, alias)
+				}
+			}
+			if m.char.Role != "" {
+				roleCount[m.char.Role]++
+				if roleCount[m.char.Role] > roleCount[char.Role] {
+					char.Role = m.char.Role
+				}
+			}
+			char.Description = longer(char.Description, m.char.Description)
+			char.Personality = longer(char.Personality, m.char.Personality)
+			char.Background = longer(char.Background, m.char.Background)
+			char.SpeechStyle = longer(char.SpeechStyle, m.char.SpeechStyle)
+			if len(char.Knowledge) < maxMergedKnowledge {
+				char.Knowledge = appendUnique(char.Knowledge, m.char.Knowledge...)
+				if len(char.Knowledge) > maxMergedKnowledge {
+					char.Knowledge = char.Knowledge[:maxMergedKnowledge]
+				}
+			}
+		}
+		for _, key := range mentionKeys(best, char.Aliases) {
+			canonical[key] = best
+		}
+		all = append(all, merged{char: char, members: members, chunks: len(chunkSet)})
+	}
+
+	// 关系整合：目标名字映射到规范名字，去掉自指，同一对象的不同描述按出现顺序合并（体现关系演变）
+	sep := pickLocale(isEnglish, "; ", "；")
+	for i := range all {
+		char := &all[i].char
+		for _, idx := range all[i].members {
+			for target, relation := range mentions[idx].char.Relationships {
+				relation = strings.TrimSpace(relation)
+				target = strings.TrimSpace(target)
+				if target == "" || relation == "" {
+					continue
+				}
+				if name, ok := canonical[entityKey(target)]; ok {
+					target = name
+				}
+				if target == char.Name {
+					continue
+				}
+				existing := char.Relationships[target]
+				if existing == "" {
+					char.Relationships[target] = relation
+				} else if !strings.Contains(existing, relation) {
+					char.Relationships[target] = existing + sep + relation
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].chunks != all[j].chunks {
+			return all[i].chunks > all[j].chunks
+		}
+		return all[i].members[0] < all[j].members[0]
+	})
+
+	characters := make([]models.Character, len(all))
+	for i := range all {
+		characters[i] = all[i].char
+	}
+	return characters
+}
+
+// mentionKeys 名字与可用别名的归一化键
+func mentionKeys(name string, aliases []string) []string {
+	keys := []string{}
+	if key := entityKey(name); key != "" {
+		keys = append(keys, key)
+	}
+	for _, alias := range aliases {
+		if !usableAlias(alias) {
+			continue
+		}
+		if key := entityKey(alias); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// usableAlias 过滤过短的别名（单字称呼、代词等）以免误合并不同角色
+func usableAlias(alias string) bool {
+	key := entityKey(alias)
+	n := utf8.RuneCountInString(key)
+	if n < 2 || pronounAliases[key] {
+		return false
+	}
+	// 西文别名至少 3 个字母
+	if r, _ := utf8.DecodeRuneInString(key); r < 0x2E80 && n < 3 {
+		return false
+	}
+	return true
+}
+
+// pronounAliases 模型偶尔会把代词当作别名返回，这类别名不能用于合并人物
+var pronounAliases = map[string]bool{
+	"he": true, "she": true, "him": true, "her": true, "they": true, "them": true, "you": true,
+	"他们": true, "她们": true, "我们": true, "你们": true, "自己": true,
+}
+
+// entityKey 归一化实体名称：小写，去掉空白与标点，去掉英文冠词
+func entityKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, prefix := range []string{"the ", "a ", "an "} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func longer(current, candidate string) string {
+	candidate = strings.TrimSpace(candidate)
+	if utf8.RuneCountInString(candidate) > utf8.RuneCountInString(current) {
+		return candidate
+	}
+	return current
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		exists := false
+		for _, existing := range list {
+			if strings.EqualFold(existing, v) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func containsItemNamed(items []models.Item, name string) bool {
+	key := entityKey(name)
+	for _, item := range items {
+		if entityKey(item.Name) == key {
+			return true
+		}
+	}
+	return false
+}
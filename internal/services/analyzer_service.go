@@ -113,6 +113,16 @@ func NewAnalyzerServiceWithLLMService(llmService *LLMService) *AnalyzerService {
 
 // AnalyzeText 分析文本，提取场景、角色、物品等信息
 func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResult, error) {
+	return s.AnalyzeTextWithSegments(text, title, nil)
+}
+
+// AnalyzeTextWithSegments 分析文本；超过单个分析窗口的长文本按 segments（章节）或段落分块做 map-reduce 分析
+func (s *AnalyzerService) AnalyzeTextWithSegments(text, title string, segments []models.OriginalSegment) (*models.AnalysisResult, error) {
+	return s.analyzeTextWithSegments(context.Background(), text, title, segments)
+}
+
+// analyzeTextWithSegments 同 AnalyzeTextWithSegments；ctx 只用于传递用量归属等上下文值，各步骤仍使用各自的超时
+func (s *AnalyzerService) analyzeTextWithSegments(ctx context.Context, text, title string, segments []models.OriginalSegment) (*models.AnalysisResult, error) {
 	// 获取并发许可
 	s.semaphore <- struct{}{}
 	defer func() { <-s.semaphore }()
@@ -128,6 +138,22 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 		return cachedResult, nil
 	}
 
+	// 长文本：逐块分析后合并，避免超出窗口的内容被截断
+	if needsChunkedAnalysis(text) {
+		chunkCtx, cancel := context.WithTimeout(ctx, s.AnalysisTimeout(text))
+		defer cancel()
+
+		result, err := s.analyzeChunks(chunkCtx, text, title, segments, nil, 0, 80)
+		if err != nil {
+			return nil, err
+		}
+		if built := s.buildOriginalSegmentsFromText(text, title); len(built) > 0 {
+			result.OriginalSegments = built
+		}
+		s.addToAnalysisCache(cacheKey, result)
+		return result, nil
+	}
+
 	// 一次性预处理
 	isEnglish := isEnglishText(text + " " + title)
 
@@ -151,7 +177,7 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		s, err := s.extractScenes(text, title)
+		s, err := s.extractScenes(ctx, text, title)
 		if err != nil {
 			sceneErr = err
 			return
@@ -163,7 +189,7 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		c, err := s.extractCharacters(text, title)
+		c, err := s.extractCharacters(ctx, text, title)
 		if err != nil {
 			charErr = err
 			return
@@ -175,7 +201,7 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		i, err := s.extractItems(text, title)
+		i, err := s.extractItems(ctx, text, title)
 		if err != nil {
 			itemErr = err
 			return
@@ -187,7 +213,7 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		locs, err := s.extractLocations(text, title)
+		locs, err := s.extractLocations(ctx, text, title)
 		if err != nil {
 			locErr = err
 			return
@@ -199,7 +225,7 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		sum, err := s.generateSummary(text, title)
+		sum, err := s.generateSummary(ctx, text, title)
 		if err != nil {
 			summaryErr = err
 			return
@@ -245,13 +271,13 @@ func (s *AnalyzerService) AnalyzeText(text, title string) (*models.AnalysisResul
 }
 
 // 提取场景信息
-func (s *AnalyzerService) extractScenes(text, title string) ([]models.Scene, error) {
+func (s *AnalyzerService) extractScenes(parent context.Context, text, title string) ([]models.Scene, error) {
 	// Create a context with timeout for the LLM call
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 90*time.Second)
 	defer cancel()
 
 	// 使用LLMService的结构化输出功能
-	sceneInfos, err := s.LLMService.ExtractScenes(ctx, text, title)
+	sceneInfos, err := s.LLMService.ExtractScenes(WithLLMTask(ctx, LLMTaskAnalysis), text, title)
 	if err != nil {
 		return nil, err
 	}
@@ -289,13 +315,13 @@ func (s *AnalyzerService) extractScenes(text, title string) ([]models.Scene, err
 }
 
 // 提取角色信息
-func (s *AnalyzerService) extractCharacters(text, title string) ([]models.Character, error) {
+func (s *AnalyzerService) extractCharacters(parent context.Context, text, title string) ([]models.Character, error) {
 	// Create a context with timeout for the LLM call
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 90*time.Second)
 	defer cancel()
 
 	// 使用LLMService的结构化输出功能
-	characterInfos, err := s.LLMService.ExtractCharacters(ctx, text, title)
+	characterInfos, err := s.LLMService.ExtractCharacters(WithLLMTask(ctx, LLMTaskAnalysis), text, title)
 	if err != nil {
 		return nil, err
 	}
@@ -327,7 +353,7 @@ func (s *AnalyzerService) extractCharacters(text, title string) ([]models.Charac
 }
 
 // 提取物品信息
-func (s *AnalyzerService) extractItems(text, title string) ([]models.Item, error) {
+func (s *AnalyzerService) extractItems(parent context.Context, text, title string) ([]models.Item, error) {
 	type ItemInfo struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
@@ -384,11 +410,11 @@ Text to analyze:
 %s`, title, truncateText(text, 5000))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 90*time.Second)
 	defer cancel()
 
 	var itemInfos []ItemInfo
-	err := s.LLMService.CreateStructuredCompletion(ctx, prompt, systemPrompt, &itemInfos)
+	err := s.LLMService.CreateStructuredCompletion(WithLLMTask(ctx, LLMTaskAnalysis), prompt, systemPrompt, &itemInfos)
 	if err != nil {
 		return nil, fmt.Errorf("解析物品列表失败: %w", err)
 	}
@@ -406,7 +432,7 @@ Text to analyze:
 }
 
 // 提取地点信息
-func (s *AnalyzerService) extractLocations(text, title string) ([]models.Location, error) {
+func (s *AnalyzerService) extractLocations(parent context.Context, text, title string) ([]models.Location, error) {
 	type LocationInfo struct {
 		Name         string `json:"name"`
 		Description  string `json:"description"`
@@ -447,11 +473,11 @@ Text:
 %s`, title, truncateText(text, 5000))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 90*time.Second)
 	defer cancel()
 
 	var locInfos []LocationInfo
-	if err := s.LLMService.CreateStructuredCompletion(ctx, prompt, systemPrompt, &locInfos); err != nil {
+	if err := s.LLMService.CreateStructuredCompletion(WithLLMTask(ctx, LLMTaskAnalysis), prompt, systemPrompt, &locInfos); err != nil {
 		return nil, fmt.Errorf("解析地点列表失败: %w", err)
 	}
 
@@ -487,7 +513,7 @@ Text:
 }
 
 // 生成故事摘要
-func (s *AnalyzerService) generateSummary(text, title string) (string, error) {
+func (s *AnalyzerService) generateSummary(parent context.Context, text, title string) (string, error) {
 	type SummaryResponse struct {
 		Summary string `json:"summary"`
 	}
@@ -520,10 +546,10 @@ The summary should be brief and capture the main plot, characters, and themes of
 	}
 
 	// Create a context with timeout for the LLM call
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 90*time.Second)
 	defer cancel()
 
-	err := s.LLMService.CreateStructuredCompletion(ctx, prompt, systemPrompt, &response)
+	err := s.LLMService.CreateStructuredCompletion(WithLLMTask(ctx, LLMTaskAnalysis), prompt, systemPrompt, &response)
 	if err != nil {
 		return "", err
 	}
@@ -599,8 +625,12 @@ func (s *AnalyzerService) AnalyzeTextWithProgress(ctx context.Context, text stri
 		return nil, ctx.Err()
 	}
 
-	// 使用子context和timeout
-	analyzeCtx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+	// 使用子context和timeout（长文本按分块数量放宽）
+	timeout := 3 * time.Minute
+	if needsChunkedAnalysis(text) {
+		timeout = s.AnalysisTimeout(text)
+	}
+	analyzeCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	result := &models.AnalysisResult{
@@ -621,6 +651,27 @@ func (s *AnalyzerService) AnalyzeTextWithProgress(ctx context.Context, text stri
 		return nil, analyzeCtx.Err()
 	}
 
+	// 长文本：步骤2-4 改为逐块分析（15%-80%）后合并
+	if needsChunkedAnalysis(text) {
+		chunked, err := s.analyzeChunks(analyzeCtx, text, result.Title, nil, tracker, 15, 80)
+		if err != nil {
+			return nil, fmt.Errorf("分块分析失败: %w", err)
+		}
+		chunked.TextType = result.TextType
+		chunked.Themes = result.Themes
+		for key, value := range result.Metadata {
+			if _, exists := chunked.Metadata[key]; !exists {
+				chunked.Metadata[key] = value
+			}
+		}
+		if segments := s.buildOriginalSegmentsFromText(text, chunked.Title); len(segments) > 0 {
+			chunked.OriginalSegments = segments
+		}
+
+		tracker.Complete("分析成功完成")
+		return chunked, nil
+	}
+
 	// 步骤2: 提取场景信息 (30%)
 	tracker.UpdateProgress(30, "提取场景信息...")
 	if err := s.extractSceneInfo(analyzeCtx, text, result); err != nil {
@@ -759,7 +810,7 @@ func (s *AnalyzerService) preliminaryAnalysis(ctx context.Context, text string,
 	}
 
 	err := s.LLMService.CreateStructuredCompletion(
-		typeCtx,
+		WithLLMTask(typeCtx, LLMTaskAnalysis),
 		textTypePrompt,
 		systemPrompt,
 		&typeInfo,
@@ -822,7 +873,7 @@ Identify the primary mood, any secondary moods, and overall emotional tone.`, tr
 
 		// 使用短超时进行情感分析
 		if err := s.LLMService.CreateStructuredCompletion(
-			moodCtx,
+			WithLLMTask(moodCtx, LLMTaskAnalysis),
 			moodPrompt,
 			systemPrompt,
 			&moodInfo,
@@ -864,7 +915,7 @@ List main character names, locations, and any time frames or periods mentioned.`
 
 		// 实体提取
 		if err := s.LLMService.CreateStructuredCompletion(
-			namesCtx,
+			WithLLMTask(namesCtx, LLMTaskAnalysis),
 			entitiesPrompt,
 			systemPrompt,
 			&entities,
@@ -890,7 +941,7 @@ func (s *AnalyzerService) extractSceneInfo(ctx context.Context, text string, res
 	}
 
 	// 使用现有的场景提取功能，但传入上下文
-	sceneInfos, err := s.LLMService.ExtractScenes(ctx, text, result.Title)
+	sceneInfos, err := s.LLMService.ExtractScenes(WithLLMTask(ctx, LLMTaskAnalysis), text, result.Title)
 	if err != nil {
 		return err
 	}
@@ -945,7 +996,7 @@ func (s *AnalyzerService) extractCharacterInfo(ctx context.Context, text string,
 	}
 
 	// 使用LLMService的结构化输出功能，但传入上下文
-	characterInfos, err := s.LLMService.ExtractCharacters(ctx, text, result.Title)
+	characterInfos, err := s.LLMService.ExtractCharacters(WithLLMTask(ctx, LLMTaskAnalysis), text, result.Title)
 	if err != nil {
 		return err
 	}
@@ -1068,7 +1119,7 @@ func (s *AnalyzerService) buildCharacterRelationships(ctx context.Context, chara
 		systemPrompt = `你是文学中角色关系分析和社交网络映射的专家。你的分析应该创建一个全面的、心理学上现实的关系矩阵，增强故事理解和角色发展潜力。`
 	}
 	var output RelationshipOutput
-	if err := s.LLMService.CreateStructuredCompletion(ctx, prompt, systemPrompt, &output); err != nil {
+	if err := s.LLMService.CreateStructuredCompletion(WithLLMTask(ctx, LLMTaskAnalysis), prompt, systemPrompt, &output); err != nil {
 		return fmt.Errorf("分析角色关系失败: %w", err)
 	}
 
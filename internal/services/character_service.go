@@ -19,6 +19,7 @@ type CharacterService struct {
 	// 依赖服务
 	LLMService     *LLMService
 	ContextService *ContextService
+	MemoryService  *MemoryService
 
 	// 并发控制
 	sceneLocks  sync.Map // sceneID -> *sync.RWMutex
@@ -83,9 +84,18 @@ func NewCharacterService() *CharacterService {
 		}
 	}
 
+	// 角色长期记忆（可选）
+	var memoryService *MemoryService
+	if memObj := container.Get("memory"); memObj != nil {
+		if ms, ok := memObj.(*MemoryService); ok {
+			memoryService = ms
+		}
+	}
+
 	service := &CharacterService{
 		LLMService:     llmService,
 		ContextService: contextService,
+		MemoryService:  memoryService,
 		sceneCache:     make(map[string]*CachedSceneData),
 		cacheExpiry:    5 * time.Minute, // 5分钟缓存过期
 		stopCleanup:    make(chan struct{}),
@@ -124,7 +134,7 @@ func (s *CharacterService) GenerateResponse(sceneID, characterID, userMessage st
 	}
 
 	// 获取角色记忆
-	memory, err := s.ContextService.BuildCharacterMemory(sceneID, characterID)
+	memory, err := s.ContextService.BuildCharacterMemory(sceneID, characterID, userMessage)
 	if err != nil {
 		// 记忆构建失败不是致命错误，可以继续
 		memory = "我没有之前的记忆。"
@@ -144,7 +154,7 @@ func (s *CharacterService) GenerateResponse(sceneID, characterID, userMessage st
 	if s.LLMService != nil {
 		// 创建聊天请求
 		resp, err := s.LLMService.CreateChatCompletion(
-			context.Background(),
+			WithLLMScene(WithLLMTask(context.Background(), LLMTaskChat), sceneID),
 			ChatCompletionRequest{
 				Model: s.LLMService.GetDefaultModel(), // 使用配置或服务默认模型
 				Messages: []ChatCompletionMessage{
@@ -176,16 +186,17 @@ func (s *CharacterService) GenerateResponse(sceneID, characterID, userMessage st
 	}
 
 	// 添加到对话记录
-	err = s.ContextService.AddConversation(sceneID, "user", userMessage, nil, "")
+	userConvID, err := s.ContextService.AddConversationWithID(sceneID, "user", userMessage, nil, "")
 	if err != nil {
 		// 记录失败不影响响应
 		utils.GetLogger().Warn("记录用户对话失败", map[string]interface{}{"scene_id": sceneID, "speaker": "user", "err": err})
 	}
 
-	err = s.ContextService.AddConversation(sceneID, characterID, characterResponse, nil, "")
+	replyConvID, err := s.ContextService.AddConversationWithID(sceneID, characterID, characterResponse, nil, "")
 	if err != nil {
 		utils.GetLogger().Warn("记录角色回应失败", map[string]interface{}{"scene_id": sceneID, "speaker": characterID, "err": err})
 	}
+	s.rememberExchange(sceneID, character, userMessage, characterResponse, userConvID, replyConvID)
 
 	// 返回角色回应
 	return &models.ChatResponse{
@@ -267,6 +278,42 @@ func (s *CharacterService) GenerateResponseWithEmotion(sceneID, characterID, mes
 		return nil, err
 	}
 
+	memory := s.MemoryService.Recall(sceneID, characterID, message, isEnglishText(character.Name+" "+character.Description))
+	systemPrompt, userPrompt, isEnglish := buildEmotionalResponsePrompts(character, message, memory)
+
+	// Create a context with timeout for the LLM call
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	// 调用LLM服务
+	var emotionalData models.EmotionalResponse
+	err = s.LLMService.CreateStructuredCompletion(
+		WithLLMScene(WithLLMTask(ctx, LLMTaskChat), sceneID),
+		userPrompt,
+		systemPrompt,
+		&emotionalData,
+	)
+	if err != nil {
+		if isEnglish {
+			return nil, fmt.Errorf("emotion analysis failed: %w", err)
+		} else {
+			return nil, fmt.Errorf("情感分析失败: %w", err)
+		}
+	}
+
+	// 设置角色信息
+	emotionalData.CharacterID = characterID
+	emotionalData.CharacterName = character.Name
+	emotionalData.Timestamp = time.Now()
+
+	s.recordEmotionalExchange(sceneID, character, message, &emotionalData)
+
+	return &emotionalData, nil
+}
+
+// buildEmotionalResponsePrompts 构建带情绪分析的角色回复提示词，返回系统提示、用户提示及是否英文。
+// memory 为检索到的长期记忆片段，可为空。
+func buildEmotionalResponsePrompts(character *models.Character, message, memory string) (string, string, bool) {
 	// 检测语言
 	isEnglish := isEnglishText(character.Name + " " + character.Description + " " + message)
 
@@ -284,6 +331,9 @@ func (s *CharacterService) GenerateResponseWithEmotion(sceneID, characterID, mes
 			character.SpeechStyle,
 			character.Personality,
 		)
+		if memory != "" {
+			systemPrompt += "Things the character remembers:\n" + memory + "\n"
+		}
 
 		userPrompt = fmt.Sprintf(
 			"User message: %s\n\n"+
@@ -310,6 +360,9 @@ func (s *CharacterService) GenerateResponseWithEmotion(sceneID, characterID, mes
 			character.SpeechStyle,
 			character.Personality,
 		)
+		if memory != "" {
+			systemPrompt += "角色记得的事：\n" + memory + "\n"
+		}
 
 		userPrompt = fmt.Sprintf(
 			"用户消息：%s\n\n"+
@@ -326,43 +379,24 @@ func (s *CharacterService) GenerateResponseWithEmotion(sceneID, characterID, mes
 		)
 	}
 
-	// Create a context with timeout for the LLM call
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
-	defer cancel()
-
-	// 调用LLM服务
-	var emotionalData models.EmotionalResponse
-	err = s.LLMService.CreateStructuredCompletion(
-		ctx,
-		userPrompt,
-		systemPrompt,
-		&emotionalData,
-	)
-	if err != nil {
-		if isEnglish {
-			return nil, fmt.Errorf("emotion analysis failed: %w", err)
-		} else {
-			return nil, fmt.Errorf("情感分析失败: %w", err)
-		}
-	}
-
-	// 设置角色信息
-	emotionalData.CharacterID = characterID
-	emotionalData.CharacterName = character.Name
-	emotionalData.Timestamp = time.Now()
+	return systemPrompt, userPrompt, isEnglish
+}
 
+// recordEmotionalExchange 将用户消息与角色的情绪化回应写入对话历史
+func (s *CharacterService) recordEmotionalExchange(sceneID string, character *models.Character, message string, data *models.EmotionalResponse) {
+	characterID := character.ID
 	// 存储对话历史
 	metadata := map[string]interface{}{
-		"emotion":            emotionalData.Emotion,
-		"intensity":          emotionalData.Intensity,
-		"body_language":      emotionalData.BodyLanguage,
-		"facial_expression":  emotionalData.FacialExpression,
-		"voice_tone":         emotionalData.VoiceTone,
-		"secondary_emotions": emotionalData.SecondaryEmotions,
+		"emotion":            data.Emotion,
+		"intensity":          data.Intensity,
+		"body_language":      data.BodyLanguage,
+		"facial_expression":  data.FacialExpression,
+		"voice_tone":         data.VoiceTone,
+		"secondary_emotions": data.SecondaryEmotions,
 	}
 
 	// 先添加用户消息
-	err = s.ContextService.AddConversation(
+	userConvID, err := s.ContextService.AddConversationWithID(
 		sceneID,
 		"user",  // 用户作为发言者
 		message, // 用户消息内容
@@ -374,18 +408,32 @@ func (s *CharacterService) GenerateResponseWithEmotion(sceneID, characterID, mes
 	}
 
 	// 添加角色回应
-	err = s.ContextService.AddConversation(
+	replyConvID, err := s.ContextService.AddConversationWithID(
 		sceneID,
-		characterID,            // 角色作为发言者
-		emotionalData.Response, // 角色回应内容
-		metadata,               // 情感相关元数据
+		characterID,   // 角色作为发言者
+		data.Response, // 角色回应内容
+		metadata,      // 情感相关元数据
 		"",
 	)
 	if err != nil {
 		utils.GetLogger().Warn("记录角色回应失败", map[string]interface{}{"scene_id": sceneID, "speaker": characterID, "err": err})
 	}
 
-	return &emotionalData, nil
+	s.rememberExchange(sceneID, character, message, data.Response, userConvID, replyConvID)
+}
+
+// rememberExchange 在后台从本轮对话中提炼角色的长期记忆
+func (s *CharacterService) rememberExchange(sceneID string, character *models.Character, message, reply string, conversationIDs ...string) {
+	if s.MemoryService == nil {
+		return
+	}
+	refs := make([]string, 0, len(conversationIDs))
+	for _, id := range conversationIDs {
+		if id != "" {
+			refs = append(refs, id)
+		}
+	}
+	s.MemoryService.ObserveTurn(sceneID, character, message, reply, refs)
 }
 
 // GetCharacter 根据ID获取指定场景中的角色
@@ -548,7 +596,7 @@ func (s *CharacterService) GenerateResponseWithEmotionFormat(sceneID, characterI
 	}
 
 	// 获取角色记忆
-	memory, err := s.ContextService.BuildCharacterMemory(sceneID, characterID)
+	memory, err := s.ContextService.BuildCharacterMemory(sceneID, characterID, userMessage)
 	if err != nil {
 		memory = "我没有之前的记忆。"
 	}
@@ -568,7 +616,7 @@ func (s *CharacterService) GenerateResponseWithEmotionFormat(sceneID, characterI
 		// 从配置或提供商获取默认模型
 		modelName := s.LLMService.GetDefaultModel()
 		resp, err := s.LLMService.CreateChatCompletion(
-			context.Background(),
+			WithLLMScene(WithLLMTask(context.Background(), LLMTaskChat), sceneID),
 			ChatCompletionRequest{
 				Model: modelName,
 				Messages: []ChatCompletionMessage{
@@ -607,10 +655,11 @@ func (s *CharacterService) GenerateResponseWithEmotionFormat(sceneID, characterI
 	}
 
 	// 先添加用户消息
-	s.ContextService.AddConversation(sceneID, "user", userMessage, nil, "")
+	userConvID, _ := s.ContextService.AddConversationWithID(sceneID, "user", userMessage, nil, "")
 
 	// 添加角色回应
-	s.ContextService.AddConversation(sceneID, characterID, emotionData.Text, metadata, "")
+	replyConvID, _ := s.ContextService.AddConversationWithID(sceneID, characterID, emotionData.Text, metadata, "")
+	s.rememberExchange(sceneID, character, userMessage, emotionData.Text, userConvID, replyConvID)
 
 	// 返回带情绪的回应
 	return &ChatResponseWithEmotion{
@@ -775,7 +824,7 @@ func (s *CharacterService) GenerateCharacterInteraction(
 	// 使用结构化输出
 	var dialogues []models.InteractionDialogue
 	err = s.LLMService.CreateStructuredCompletion(
-		ctx,
+		WithLLMScene(WithLLMTask(ctx, LLMTaskChat), sceneID),
 		prompt,     // 系统提示词
 		topic,      // 用户消息
 		&dialogues, // 输出结构
@@ -964,7 +1013,7 @@ func (s *CharacterService) SimulateCharactersConversation(
 	// 使用结构化输出
 	var dialogues []models.InteractionDialogue
 	err = s.LLMService.CreateStructuredCompletion(
-		ctx,
+		WithLLMScene(WithLLMTask(ctx, LLMTaskChat), sceneID),
 		prompt.String(),  // 系统提示词
 		initialSituation, // 用户消息
 		&dialogues,       // 输出结构
@@ -0,0 +1,239 @@
+// internal/services/chat_stream.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+)
+
+// ChatDeltaHandler 接收角色回复的增量文本
+type ChatDeltaHandler func(delta string)
+
+// ErrChatStreamInterrupted 流式回复在完成前中断
+var ErrChatStreamInterrupted = errors.New("流式响应中断")
+
+// ChatStreamError 描述流式回复中断的原因与中断前已推送的回复文本
+type ChatStreamError struct {
+	Cause   string // 提供商给出的原因；为空表示连接在没有错误信息的情况下断开
+	Partial string // 中断前已推送给客户端的回复文本
+}
+
+func (e *ChatStreamError) Error() string {
+	if e.Cause == "" {
+		return ErrChatStreamInterrupted.Error() + ": 连接已断开"
+	}
+	return ErrChatStreamInterrupted.Error() + ": " + e.Cause
+}
+
+func (e *ChatStreamError) Unwrap() error { return ErrChatStreamInterrupted }
+
+// StreamResponseWithEmotion 以流式方式生成带情绪的角色回应。
+// 模型输出的 JSON 中 response 字段一边生成一边通过 onDelta 推送；
+// 流结束后解析完整的 EmotionalResponse，并与非流式接口一样写入对话历史。
+func (s *CharacterService) StreamResponseWithEmotion(ctx context.Context, sceneID, characterID, message string, onDelta ChatDeltaHandler) (*models.EmotionalResponse, error) {
+	if s.ContextService == nil {
+		return nil, fmt.Errorf("上下文服务未初始化")
+	}
+
+	character, err := s.GetCharacter(sceneID, characterID)
+	if err != nil {
+		return nil, err
+	}
+
+	memory := s.MemoryService.Recall(sceneID, characterID, message, isEnglishText(character.Name+" "+character.Description))
+	systemPrompt, userPrompt, isEnglish := buildEmotionalResponsePrompts(character, message, memory)
+	// response 字段放在最前面，前端才能尽早看到文字
+	systemPrompt += "\n\nReturn your response in valid JSON format without explanations or preambles. " +
+		"The \"response\" field must be the first field of the JSON object."
+
+	stream, err := s.LLMService.CreateStreamingCompletion(WithLLMScene(WithLLMTask(ctx, LLMTaskChat), sceneID), llm.CompletionRequest{
+		Prompt:       userPrompt,
+		SystemPrompt: systemPrompt,
+		Temperature:  0.3,
+	})
+	if err != nil {
+		if isEnglish {
+			return nil, fmt.Errorf("emotion analysis failed: %w", err)
+		}
+		return nil, fmt.Errorf("情感分析失败: %w", err)
+	}
+
+	var raw strings.Builder
+	field := newJSONStringFieldStreamer("response")
+	finalText := ""
+
+	for chunk := range stream {
+		if chunk.Done {
+			if chunk.FinishReason == "error" {
+				return nil, &ChatStreamError{Cause: chunk.Error, Partial: field.Value()}
+			}
+			finalText = chunk.Text
+			break
+		}
+		raw.WriteString(chunk.Text)
+		if delta := field.Feed(chunk.Text); delta != "" && onDelta != nil {
+			onDelta(delta)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 多数Provider在结束消息中给出完整文本，没有时使用累积的增量
+	fullText := raw.String()
+	if strings.TrimSpace(finalText) != "" {
+		fullText = finalText
+	}
+
+	var emotionalData models.EmotionalResponse
+	if err := json.Unmarshal([]byte(cleanJSONString(fullText)), &emotionalData); err != nil || strings.TrimSpace(emotionalData.Response) == "" {
+		// 模型没有按JSON返回时，保留已推送的文本，避免前端看到的内容与历史不一致
+		emotionalData = models.EmotionalResponse{
+			Response: field.Value(),
+			Emotion:  "neutral",
+		}
+		if strings.TrimSpace(emotionalData.Response) == "" {
+			emotionalData.Response = strings.TrimSpace(fullText)
+		}
+	}
+	if strings.TrimSpace(emotionalData.Response) == "" {
+		if isEnglish {
+			return nil, fmt.Errorf("emotion analysis failed: empty response")
+		}
+		return nil, fmt.Errorf("情感分析失败: 回复为空")
+	}
+
+	emotionalData.CharacterID = characterID
+	emotionalData.CharacterName = character.Name
+	emotionalData.Timestamp = time.Now()
+
+	s.recordEmotionalExchange(sceneID, character, message, &emotionalData)
+
+	return &emotionalData, nil
+}
+
+// jsonStringFieldStreamer 从不断增长的JSON文本中增量解码指定字符串字段的值
+type jsonStringFieldStreamer struct {
+	keyPattern *regexp.Regexp
+	buf        strings.Builder
+	pos        int  // 下一个待解码字节在 buf 中的位置，-1 表示尚未找到字段
+	done       bool // 已读到字段结束引号
+	value      strings.Builder
+}
+
+func newJSONStringFieldStreamer(field string) *jsonStringFieldStreamer {
+	return &jsonStringFieldStreamer{
+		keyPattern: regexp.MustCompile(`"` + regexp.QuoteMeta(field) + `"\s*:\s*"`),
+		pos:        -1,
+	}
+}
+
+// Feed 追加一段原始输出，返回本次新解码出的字段文本
+func (f *jsonStringFieldStreamer) Feed(chunk string) string {
+	if f.done {
+		return ""
+	}
+	f.buf.WriteString(chunk)
+	text := f.buf.String()
+
+	if f.pos < 0 {
+		loc := f.keyPattern.FindStringIndex(text)
+		if loc == nil {
+			return ""
+		}
+		f.pos = loc[1]
+	}
+
+	var delta strings.Builder
+	i := f.pos
+	for i < len(text) {
+		c := text[i]
+		if c == '"' {
+			f.done = true
+			i++
+			break
+		}
+		if c == '\\' {
+			r, size, ok := decodeJSONEscape(text[i:])
+			if !ok {
+				break // 转义序列尚不完整，等待后续数据
+			}
+			delta.WriteRune(r)
+			i += size
+			continue
+		}
+		if c >= utf8.RuneSelf && !utf8.FullRuneInString(text[i:]) {
+			break // 多字节字符被截断，等待后续数据
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		delta.WriteString(text[i : i+size])
+		i += size
+	}
+	f.pos = i
+
+	f.value.WriteString(delta.String())
+	return delta.String()
+}
+
+// Value 返回目前已解码的字段内容
+func (f *jsonStringFieldStreamer) Value() string {
+	return f.value.String()
+}
+
+// decodeJSONEscape 解码以反斜杠开头的JSON转义序列；数据不完整时 ok=false
+func decodeJSONEscape(s string) (r rune, size int, ok bool) {
+	if len(s) < 2 {
+		return 0, 0, false
+	}
+	switch s[1] {
+	case '"', '\\', '/':
+		return rune(s[1]), 2, true
+	case 'b':
+		return '\b', 2, true
+	case 'f':
+		return '\f', 2, true
+	case 'n':
+		return '\n', 2, true
+	case 'r':
+		return '\r', 2, true
+	case 't':
+		return '\t', 2, true
+	case 'u':
+		if len(s) < 6 {
+			return 0, 0, false
+		}
+		code, err := strconv.ParseUint(s[2:6], 16, 16)
+		if err != nil {
+			return utf8.RuneError, 6, true
+		}
+		r = rune(code)
+		if utf16.IsSurrogate(r) {
+			// 代理对需要等到低位部分到达
+			if len(s) < 12 {
+				return 0, 0, false
+			}
+			if s[6] == '\\' && s[7] == 'u' {
+				if low, err := strconv.ParseUint(s[8:12], 16, 16); err == nil {
+					if combined := utf16.DecodeRune(r, rune(low)); combined != utf8.RuneError {
+						return combined, 12, true
+					}
+				}
+			}
+			return utf8.RuneError, 6, true
+		}
+		return r, 6, true
+	default:
+		return rune(s[1]), 2, true
+	}
+}
@@ -0,0 +1,746 @@
+// internal/services/comic_campaign.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+var (
+	// ErrInvalidComicCampaign is returned for an empty scene list, an unknown step or bad limits.
+	ErrInvalidComicCampaign = errors.New("invalid comic campaign")
+	// ErrComicCampaignNotFound is returned for an unknown campaign id.
+	ErrComicCampaignNotFound = errors.New("comic campaign not found")
+)
+
+const (
+	comicCampaignMaxScenes          = 500
+	comicCampaignMaxParallelScenes  = 16
+	comicCampaignDefaultParallel    = 2
+	comicCampaignProgressInterval   = 3 * time.Second
+	comicCampaignFinishedRetainSize = 50
+)
+
+// ComicCampaignOptions 批量漫画任务参数。
+// FromStep/ToStep 为空时分别取 analysis / generate；VisionConcurrency 为每个 vision provider
+// 同时执行 generate 的场景数上限（计入所有批量任务正在执行的场景），
+// 未列出的 provider 使用 DefaultVisionConcurrency（默认 1）。
+type ComicCampaignOptions struct {
+	SceneIDs                 []string       `json:"scene_ids"`
+	FromStep                 string         `json:"from_step,omitempty"`
+	ToStep                   string         `json:"to_step,omitempty"`
+	TargetFrames             int            `json:"target_frames,omitempty"`
+	Style                    string         `json:"style,omitempty"`
+	Resume                   bool           `json:"resume,omitempty"`
+	MaxParallelScenes        int            `json:"max_parallel_scenes,omitempty"`
+	VisionConcurrency        map[string]int `json:"vision_concurrency,omitempty"`
+	DefaultVisionConcurrency int            `json:"default_vision_concurrency,omitempty"`
+}
+
+// comicCampaignRun 是运行中的批量任务；campaign 字段由 mu 保护，每次更新后写入 repo
+type comicCampaignRun struct {
+	mu       sync.Mutex
+	saveMu   sync.Mutex
+	campaign models.ComicCampaign
+	opts     ComicCampaignOptions
+	cancel   context.CancelFunc
+	tracker  *ProgressTracker
+	repo     *ComicRepository
+}
+
+// comicProviderLimiter 在所有批量任务之间共享的 vision provider 并发计数。
+// 每个批量任务带着自己的上限申请名额：只有该 provider 上正在执行的 generate 少于上限时才能开始。
+type comicProviderLimiter struct {
+	mu      sync.Mutex
+	inUse   map[string]int
+	changed chan struct{} // 每次释放名额时关闭并替换，用于唤醒等待者
+}
+
+// StartComicCampaign 对多个场景依次执行 analysis → prompts → key_elements → generate 中的指定区间。
+// 每个场景的每一步都作为普通任务提交到 JobQueue；generate 步骤按 vision provider 限制并发。
+// 返回的 campaign ID 同时是聚合进度流的 taskID（GET /api/progress/:taskID）。
+// userID 记录为批量任务的创建者，各步骤任务也以其名义提交。
+func (s *ComicService) StartComicCampaign(userID string, opts ComicCampaignOptions) (*models.ComicCampaign, error) {
+	steps, err := resolveComicCampaignSteps(opts.FromStep, opts.ToStep)
+	if err != nil {
+		return nil, err
+	}
+	sceneIDs, err := normalizeComicCampaignScenes(opts.SceneIDs)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxParallelScenes < 0 || opts.MaxParallelScenes > comicCampaignMaxParallelScenes {
+		return nil, fmt.Errorf("%w: max_parallel_scenes must be within 1..%d", ErrInvalidComicCampaign, comicCampaignMaxParallelScenes)
+	}
+	if opts.MaxParallelScenes == 0 {
+		opts.MaxParallelScenes = comicCampaignDefaultParallel
+	}
+	if opts.DefaultVisionConcurrency < 0 {
+		return nil, fmt.Errorf("%w: default_vision_concurrency must not be negative", ErrInvalidComicCampaign)
+	}
+	if opts.DefaultVisionConcurrency == 0 {
+		opts.DefaultVisionConcurrency = 1
+	}
+	concurrency := make(map[string]int, len(opts.VisionConcurrency))
+	for provider, n := range opts.VisionConcurrency {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if provider == "" || n <= 0 {
+			return nil, fmt.Errorf("%w: vision_concurrency needs a provider name and a positive limit", ErrInvalidComicCampaign)
+		}
+		concurrency[provider] = n
+	}
+	opts.VisionConcurrency = concurrency
+	opts.SceneIDs = sceneIDs
+
+	for _, step := range steps {
+		if step == models.ComicCampaignStepGenerate {
+			if err := s.ensureVisionReady(); err != nil {
+				return nil, err
+			}
+		} else if err := s.ensureLLMReady(); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("comic_campaign_%d", now.UnixNano())
+	campaign := models.ComicCampaign{
+		ID:                       id,
+		CreatedBy:                strings.TrimSpace(userID),
+		Status:                   models.ComicCampaignStatusQueued,
+		Steps:                    steps,
+		Scenes:                   make([]models.ComicCampaignScene, 0, len(sceneIDs)),
+		MaxParallelScenes:        opts.MaxParallelScenes,
+		VisionConcurrency:        concurrency,
+		DefaultVisionConcurrency: opts.DefaultVisionConcurrency,
+		TargetFrames:             opts.TargetFrames,
+		Style:                    opts.Style,
+		Resume:                   opts.Resume,
+		CreatedAt:                now,
+		UpdatedAt:                now,
+	}
+	for _, sceneID := range sceneIDs {
+		campaign.Scenes = append(campaign.Scenes, models.ComicCampaignScene{SceneID: sceneID, Status: models.ComicCampaignStatusQueued})
+	}
+
+	ctx, cancel := context.WithCancel(WithJobUser(context.Background(), campaign.CreatedBy))
+	run := &comicCampaignRun{
+		campaign: campaign,
+		opts:     opts,
+		cancel:   cancel,
+		tracker:  s.Progress.CreateTracker(id),
+		repo:     s.Repo,
+	}
+	run.tracker.UpdateProgress(0, fmt.Sprintf("批量任务已创建：%d 个场景，步骤 %s", len(sceneIDs), strings.Join(steps, " → ")))
+	run.persist()
+
+	s.addCampaignRun(run)
+	go s.runComicCampaign(ctx, run)
+	return run.snapshot(), nil
+}
+
+// RestoreComicCampaigns 读取落盘的批量任务：已结束的只恢复记录供查询，未结束的重新启动协调器。
+// 已完成的步骤会跳过；重启前正在执行、且已被任务队列恢复的步骤任务继续等待而不重复提交。
+// 应在 JobQueue.RestorePending 之后调用，返回重新启动的批量任务数量。
+func (s *ComicService) RestoreComicCampaigns() int {
+	if s.Repo == nil || s.JobQueue == nil || s.Progress == nil {
+		return 0
+	}
+	records, err := s.Repo.LoadCampaigns()
+	if err != nil {
+		utils.GetLogger().Warn("load comic campaigns failed", map[string]interface{}{"err": err})
+		return 0
+	}
+
+	restored := 0
+	for _, campaign := range records {
+		ctx, cancel := context.WithCancel(WithJobUser(context.Background(), campaign.CreatedBy))
+		run := &comicCampaignRun{
+			campaign: campaign,
+			opts:     comicCampaignOptionsFromRecord(campaign),
+			cancel:   cancel,
+			repo:     s.Repo,
+		}
+		if campaign.FinishedAt != nil {
+			cancel()
+			s.addCampaignRun(run)
+			continue
+		}
+		run.tracker = s.Progress.CreateTracker(campaign.ID)
+		run.tracker.UpdateProgress(0, "服务重启，批量任务已恢复...")
+		s.addCampaignRun(run)
+		go s.runComicCampaign(ctx, run)
+		restored++
+	}
+
+	if restored > 0 {
+		utils.GetLogger().Info("comic campaigns restored", map[string]interface{}{"count": restored})
+	}
+	return restored
+}
+
+// comicCampaignOptionsFromRecord 由落盘的批量任务记录还原运行参数
+func comicCampaignOptionsFromRecord(c models.ComicCampaign) ComicCampaignOptions {
+	opts := ComicCampaignOptions{
+		SceneIDs:                 make([]string, 0, len(c.Scenes)),
+		TargetFrames:             c.TargetFrames,
+		Style:                    c.Style,
+		Resume:                   c.Resume,
+		MaxParallelScenes:        c.MaxParallelScenes,
+		VisionConcurrency:        c.VisionConcurrency,
+		DefaultVisionConcurrency: c.DefaultVisionConcurrency,
+	}
+	for _, sc := range c.Scenes {
+		opts.SceneIDs = append(opts.SceneIDs, sc.SceneID)
+	}
+	if opts.MaxParallelScenes <= 0 {
+		opts.MaxParallelScenes = comicCampaignDefaultParallel
+	}
+	if opts.DefaultVisionConcurrency <= 0 {
+		opts.DefaultVisionConcurrency = 1
+	}
+	return opts
+}
+
+func (s *ComicService) addCampaignRun(run *comicCampaignRun) {
+	s.campaignsMu.Lock()
+	defer s.campaignsMu.Unlock()
+	if s.campaigns == nil {
+		s.campaigns = make(map[string]*comicCampaignRun)
+	}
+	s.campaigns[run.campaign.ID] = run
+	s.pruneCampaignsLocked()
+}
+
+// GetComicCampaign 返回批量任务的当前状态
+func (s *ComicService) GetComicCampaign(id string) (*models.ComicCampaign, error) {
+	s.campaignsMu.Lock()
+	run := s.campaigns[strings.TrimSpace(id)]
+	s.campaignsMu.Unlock()
+	if run == nil {
+		return nil, ErrComicCampaignNotFound
+	}
+	return run.snapshot(), nil
+}
+
+// ListComicCampaigns 返回所有批量任务（含最近结束的），按创建时间倒序
+func (s *ComicService) ListComicCampaigns() []models.ComicCampaign {
+	s.campaignsMu.Lock()
+	runs := make([]*comicCampaignRun, 0, len(s.campaigns))
+	for _, run := range s.campaigns {
+		runs = append(runs, run)
+	}
+	s.campaignsMu.Unlock()
+
+	out := make([]models.ComicCampaign, 0, len(runs))
+	for _, run := range runs {
+		out = append(out, *run.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// CancelComicCampaign 取消批量任务：未开始的场景不再执行，正在执行的步骤任务会被取消
+func (s *ComicService) CancelComicCampaign(id string) (*models.ComicCampaign, error) {
+	s.campaignsMu.Lock()
+	run := s.campaigns[strings.TrimSpace(id)]
+	s.campaignsMu.Unlock()
+	if run == nil {
+		return nil, ErrComicCampaignNotFound
+	}
+	run.cancel()
+	return run.snapshot(), nil
+}
+
+// pruneCampaignsLocked 只保留最近结束的若干批量任务；调用方需持有 campaignsMu
+func (s *ComicService) pruneCampaignsLocked() {
+	finished := make([]*comicCampaignRun, 0)
+	for _, run := range s.campaigns {
+		run.mu.Lock()
+		done := run.campaign.FinishedAt != nil
+		run.mu.Unlock()
+		if done {
+			finished = append(finished, run)
+		}
+	}
+	if len(finished) <= comicCampaignFinishedRetainSize {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].campaign.CreatedAt.Before(finished[j].campaign.CreatedAt)
+	})
+	for _, run := range finished[:len(finished)-comicCampaignFinishedRetainSize] {
+		delete(s.campaigns, run.campaign.ID)
+		if s.Repo != nil {
+			if err := s.Repo.DeleteCampaign(run.campaign.ID); err != nil {
+				utils.GetLogger().Warn("delete comic campaign record failed", map[string]interface{}{"campaign_id": run.campaign.ID, "err": err})
+			}
+		}
+	}
+}
+
+func (s *ComicService) runComicCampaign(ctx context.Context, run *comicCampaignRun) {
+	defer run.cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			utils.GetLogger().Error("comic campaign panic", map[string]interface{}{"campaign_id": run.campaign.ID, "panic": fmt.Sprintf("%v", r)})
+			run.tracker.Fail("批量任务异常崩溃")
+		}
+	}()
+
+	run.update(func(c *models.ComicCampaign) { c.Status = models.ComicCampaignStatusRunning })
+
+	tickerDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(comicCampaignProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.emitCampaignProgress(run, "", "", nil)
+			case <-tickerDone:
+				return
+			}
+		}
+	}()
+
+	slots := make(chan struct{}, run.opts.MaxParallelScenes)
+	var wg sync.WaitGroup
+	initial := run.snapshot()
+	for i := range run.opts.SceneIDs {
+		// 恢复的批量任务中已结束的场景不再执行
+		if isComicCampaignSceneFinished(initial.Scenes[i].Status) {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			run.updateScene(i, func(sc *models.ComicCampaignScene) { sc.Status = models.ComicCampaignStatusCancelled })
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			s.runComicCampaignScene(ctx, run, i)
+		}(i)
+	}
+	wg.Wait()
+	close(tickerDone)
+
+	var completed, failed, cancelled int
+	run.update(func(c *models.ComicCampaign) {
+		for _, sc := range c.Scenes {
+			switch sc.Status {
+			case models.ComicCampaignStatusCompleted:
+				completed++
+			case models.ComicCampaignStatusFailed:
+				failed++
+			default:
+				cancelled++
+			}
+		}
+		switch {
+		case cancelled > 0:
+			c.Status = models.ComicCampaignStatusCancelled
+		case failed == 0:
+			c.Status = models.ComicCampaignStatusCompleted
+		case completed == 0:
+			c.Status = models.ComicCampaignStatusFailed
+		default:
+			c.Status = models.ComicCampaignStatusPartial
+		}
+		now := time.Now()
+		c.FinishedAt = &now
+	})
+
+	summary := fmt.Sprintf("批量任务结束：完成 %d，失败 %d，取消 %d", completed, failed, cancelled)
+	if reason := run.snapshot().Error; reason != "" {
+		summary += "；" + reason
+	}
+	switch {
+	case cancelled > 0:
+		run.tracker.Fail("批量任务已取消；" + summary)
+	case completed == 0 && failed > 0:
+		run.tracker.Fail(summary)
+	default:
+		run.tracker.Complete(summary)
+	}
+}
+
+// runComicCampaignScene 依次执行单个场景的各个步骤；某一步失败时该场景停止，其他场景不受影响。
+// 恢复的场景跳过已完成的步骤，重启前提交的步骤任务若仍在任务队列中则直接等待。
+func (s *ComicService) runComicCampaignScene(ctx context.Context, run *comicCampaignRun, i int) {
+	sceneID := run.opts.SceneIDs[i]
+	prior := run.snapshot().Scenes[i]
+	completed := make(map[string]bool, len(prior.CompletedSteps))
+	for _, step := range prior.CompletedSteps {
+		completed[step] = true
+	}
+	started := time.Now()
+	run.updateScene(i, func(sc *models.ComicCampaignScene) {
+		sc.Status = models.ComicCampaignStatusRunning
+		if sc.StartedAt == nil {
+			sc.StartedAt = &started
+		}
+	})
+
+	for stepIndex, step := range run.campaign.Steps {
+		if completed[step] {
+			continue
+		}
+		if ctx.Err() != nil {
+			s.finishCampaignScene(run, i, models.ComicCampaignStatusCancelled, "")
+			return
+		}
+		interrupted := prior.Step == step && prior.TaskID != ""
+
+		release := func() {}
+		if step == models.ComicCampaignStepGenerate {
+			provider := s.resolveCampaignSceneProvider(sceneID)
+			run.updateScene(i, func(sc *models.ComicCampaignScene) { sc.Step = step; sc.TaskID = ""; sc.Provider = provider })
+			s.emitCampaignProgress(run, fmt.Sprintf("%s：等待 %s 并发配额", sceneID, provider), "scene_step_waiting", &ProgressEventMeta{Phase: step, SceneID: sceneID})
+			var ok bool
+			if release, ok = s.campaignLimiter.acquire(ctx, provider, run.visionLimit(provider)); !ok {
+				s.finishCampaignScene(run, i, models.ComicCampaignStatusCancelled, "")
+				return
+			}
+		}
+
+		taskID := ""
+		if interrupted {
+			if _, ok := s.JobQueue.Get(prior.TaskID); ok {
+				taskID = prior.TaskID
+			}
+		}
+		if taskID == "" {
+			if step != models.ComicCampaignStepGenerate {
+				if err := s.campaignQuotaError(sceneID); err != nil {
+					release()
+					s.stopCampaignOnQuota(run, i, step, err)
+					return
+				}
+			}
+
+			var err error
+			taskID, err = s.submitCampaignStep(ctx, run, sceneID, step, interrupted)
+			if err != nil {
+				release()
+				s.finishCampaignScene(run, i, models.ComicCampaignStatusFailed, fmt.Sprintf("%s: %v", step, err))
+				return
+			}
+		}
+		run.updateScene(i, func(sc *models.ComicCampaignScene) { sc.Step = step; sc.TaskID = taskID })
+		s.emitCampaignProgress(run, fmt.Sprintf("%s：开始 %s", sceneID, step), "scene_step_started", &ProgressEventMeta{Phase: step, SceneID: sceneID, StageIndex: stepIndex + 1, StageTotal: len(run.campaign.Steps)})
+
+		status, errMsg := s.waitCampaignStep(ctx, taskID)
+		release()
+		switch status {
+		case JobStatusCompleted:
+			run.updateScene(i, func(sc *models.ComicCampaignScene) { sc.CompletedSteps = append(sc.CompletedSteps, step) })
+			s.emitCampaignProgress(run, fmt.Sprintf("%s：%s 完成", sceneID, step), "scene_step_completed", &ProgressEventMeta{Phase: step, SceneID: sceneID, StageIndex: stepIndex + 1, StageTotal: len(run.campaign.Steps)})
+		case JobStatusCancelled:
+			s.finishCampaignScene(run, i, models.ComicCampaignStatusCancelled, "")
+			return
+		default:
+			if err := s.campaignQuotaError(sceneID); err != nil {
+				s.stopCampaignOnQuota(run, i, step, err)
+				return
+			}
+			s.finishCampaignScene(run, i, models.ComicCampaignStatusFailed, fmt.Sprintf("%s: %s", step, errMsg))
+			return
+		}
+	}
+	s.finishCampaignScene(run, i, models.ComicCampaignStatusCompleted, "")
+}
+
+// campaignQuotaError 检查场景归属用户的 LLM 用量配额；步骤中的 LLM 调用按场景所有者计费
+func (s *ComicService) campaignQuotaError(sceneID string) error {
+	if s.LLM == nil {
+		return nil
+	}
+	return s.LLM.checkUsageQuota(WithLLMScene(context.Background(), sceneID))
+}
+
+// stopCampaignOnQuota 配额用完后停止整个批量任务：当前场景记为失败，其余场景不再提交步骤
+func (s *ComicService) stopCampaignOnQuota(run *comicCampaignRun, i int, step string, err error) {
+	s.finishCampaignScene(run, i, models.ComicCampaignStatusFailed, fmt.Sprintf("%s: %v", step, err))
+	run.update(func(c *models.ComicCampaign) {
+		if c.Error == "" {
+			c.Error = err.Error()
+		}
+	})
+	run.cancel()
+}
+
+func (s *ComicService) finishCampaignScene(run *comicCampaignRun, i int, status string, errMsg string) {
+	now := time.Now()
+	sceneID := ""
+	run.updateScene(i, func(sc *models.ComicCampaignScene) {
+		sc.Status = status
+		sc.Error = errMsg
+		sc.FinishedAt = &now
+		sceneID = sc.SceneID
+	})
+	switch status {
+	case models.ComicCampaignStatusFailed:
+		utils.GetLogger().Warn("comic campaign scene failed", map[string]interface{}{"campaign_id": run.campaign.ID, "scene_id": sceneID, "err": errMsg})
+		s.emitCampaignProgress(run, fmt.Sprintf("%s：失败（%s）", sceneID, errMsg), "scene_failed", &ProgressEventMeta{SceneID: sceneID})
+	case models.ComicCampaignStatusCompleted:
+		s.emitCampaignProgress(run, fmt.Sprintf("%s：全部步骤完成", sceneID), "scene_completed", &ProgressEventMeta{SceneID: sceneID})
+	}
+}
+
+// submitCampaignStep 通过现有的单场景接口提交一步任务；interrupted 表示该步骤在服务重启前已开始，
+// generate 会跳过已生成的帧
+func (s *ComicService) submitCampaignStep(ctx context.Context, run *comicCampaignRun, sceneID string, step string, interrupted bool) (string, error) {
+	switch step {
+	case models.ComicCampaignStepAnalysis:
+		if run.opts.TargetFrames > 0 {
+			return s.AnalyzeStoryAsyncWithConfig(ctx, sceneID, run.opts.TargetFrames)
+		}
+		return s.AnalyzeStoryAsync(ctx, sceneID)
+	case models.ComicCampaignStepPrompts:
+		return s.BuildPromptsAsyncWithOptions(ctx, sceneID, ComicPromptsBuildOptions{Style: run.opts.Style})
+	case models.ComicCampaignStepKeyElements:
+		return s.ExtractKeyElementsAsync(ctx, sceneID)
+	case models.ComicCampaignStepGenerate:
+		return s.GenerateComicAsyncWithOptions(ctx, sceneID, ComicGenerateOptions{Resume: run.opts.Resume || interrupted})
+	default:
+		return "", fmt.Errorf("%w: unknown step %q", ErrInvalidComicCampaign, step)
+	}
+}
+
+// waitCampaignStep 等待步骤任务结束并返回其终态；批量任务被取消时同时取消该任务
+func (s *ComicService) waitCampaignStep(ctx context.Context, taskID string) (JobStatus, string) {
+	if err := s.JobQueue.Wait(ctx, taskID); err != nil {
+		s.JobQueue.Cancel(taskID)
+		return JobStatusCancelled, err.Error()
+	}
+	if rec, ok := s.JobQueue.Get(taskID); ok {
+		return rec.Status, rec.LastError
+	}
+	// 任务记录已被清理时以进度跟踪器为准
+	if tracker, ok := s.Progress.GetTracker(taskID); ok {
+		snap := tracker.Snapshot()
+		if snap.Status == "completed" {
+			return JobStatusCompleted, ""
+		}
+		return JobStatusFailed, snap.Message
+	}
+	return JobStatusFailed, "task record not found"
+}
+
+// resolveCampaignSceneProvider 根据首帧提示词的模型确定 generate 使用的 vision provider
+func (s *ComicService) resolveCampaignSceneProvider(sceneID string) string {
+	model := ""
+	if breakdown, err := s.Repo.LoadAnalysis(sceneID); err == nil && breakdown != nil {
+		for _, frame := range breakdown.Frames {
+			if fp, err := s.Repo.LoadPrompt(sceneID, frame.ID); err == nil && fp != nil && strings.TrimSpace(fp.Model) != "" {
+				model = fp.Model
+				break
+			}
+		}
+	}
+	provider := strings.ToLower(s.resolveVisionProviderForModel(model))
+	if provider == "" {
+		provider = "default"
+	}
+	return provider
+}
+
+// emitCampaignProgress 汇总各场景的步骤进度并推送到批量任务的进度流
+func (s *ComicService) emitCampaignProgress(run *comicCampaignRun, message string, eventType string, meta *ProgressEventMeta) {
+	run.mu.Lock()
+	total := len(run.campaign.Scenes) * len(run.campaign.Steps)
+	units := 0.0
+	for _, sc := range run.campaign.Scenes {
+		switch sc.Status {
+		case models.ComicCampaignStatusCompleted, models.ComicCampaignStatusFailed, models.ComicCampaignStatusCancelled:
+			// 结束的场景按全部步骤计入，保证总进度能到 100
+			units += float64(len(run.campaign.Steps))
+			continue
+		}
+		units += float64(len(sc.CompletedSteps))
+		if sc.TaskID != "" {
+			if tracker, ok := s.Progress.GetTracker(sc.TaskID); ok {
+				if snap := tracker.Snapshot(); snap.Status == "running" {
+					units += float64(snap.Progress) / 100.0
+				}
+			}
+		}
+	}
+	progress := 0
+	if total > 0 {
+		progress = int(units / float64(total) * 100)
+	}
+	if progress > 99 {
+		progress = 99
+	}
+	run.campaign.Progress = progress
+	run.campaign.UpdatedAt = time.Now()
+	run.mu.Unlock()
+
+	run.tracker.EmitProgressEventWithMeta(progress, message, eventType, "", meta)
+}
+
+func resolveComicCampaignSteps(from string, to string) ([]string, error) {
+	indexOf := func(step string, fallback int) (int, error) {
+		step = strings.ToLower(strings.TrimSpace(step))
+		if step == "" {
+			return fallback, nil
+		}
+		for i, s := range models.ComicCampaignSteps {
+			if s == step {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: unknown step %q (analysis / prompts / key_elements / generate)", ErrInvalidComicCampaign, step)
+	}
+	start, err := indexOf(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := indexOf(to, len(models.ComicCampaignSteps)-1)
+	if err != nil {
+		return nil, err
+	}
+	if start > end {
+		return nil, fmt.Errorf("%w: from_step must not come after to_step", ErrInvalidComicCampaign)
+	}
+	out := make([]string, 0, end-start+1)
+	out = append(out, models.ComicCampaignSteps[start:end+1]...)
+	return out, nil
+}
+
+func normalizeComicCampaignScenes(sceneIDs []string) ([]string, error) {
+	out := make([]string, 0, len(sceneIDs))
+	seen := make(map[string]struct{}, len(sceneIDs))
+	for _, id := range sceneIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if err := validatePathSegment(id); err != nil {
+			return nil, fmt.Errorf("%w: invalid scene id %q", ErrInvalidComicCampaign, id)
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: scene_ids is required", ErrInvalidComicCampaign)
+	}
+	if len(out) > comicCampaignMaxScenes {
+		return nil, fmt.Errorf("%w: at most %d scenes per campaign", ErrInvalidComicCampaign, comicCampaignMaxScenes)
+	}
+	return out, nil
+}
+
+func isComicCampaignSceneFinished(status string) bool {
+	switch status {
+	case models.ComicCampaignStatusCompleted, models.ComicCampaignStatusFailed, models.ComicCampaignStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// acquire 等待 provider 上正在执行的 generate 少于 limit 后占用一个名额；ctx 结束时返回 false
+func (l *comicProviderLimiter) acquire(ctx context.Context, provider string, limit int) (func(), bool) {
+	for {
+		l.mu.Lock()
+		if l.inUse == nil {
+			l.inUse = make(map[string]int)
+			l.changed = make(chan struct{})
+		}
+		if l.inUse[provider] < limit {
+			l.inUse[provider]++
+			l.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(func() { l.release(provider) }) }, true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (l *comicProviderLimiter) release(provider string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse[provider]--
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// visionLimit 返回该批量任务对 provider 的 generate 并发上限
+func (r *comicCampaignRun) visionLimit(provider string) int {
+	if limit := r.opts.VisionConcurrency[provider]; limit > 0 {
+		return limit
+	}
+	return r.opts.DefaultVisionConcurrency
+}
+
+func (r *comicCampaignRun) update(fn func(c *models.ComicCampaign)) {
+	r.mu.Lock()
+	fn(&r.campaign)
+	r.campaign.UpdatedAt = time.Now()
+	r.mu.Unlock()
+	r.persist()
+}
+
+// persist 写入批量任务记录；saveMu 保证较旧的快照不会覆盖较新的
+func (r *comicCampaignRun) persist() {
+	if r.repo == nil {
+		return
+	}
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	if err := r.repo.SaveCampaign(r.snapshot()); err != nil {
+		utils.GetLogger().Warn("save comic campaign failed", map[string]interface{}{"campaign_id": r.campaign.ID, "err": err})
+	}
+}
+
+func (r *comicCampaignRun) updateScene(i int, fn func(sc *models.ComicCampaignScene)) {
+	r.update(func(c *models.ComicCampaign) { fn(&c.Scenes[i]) })
+}
+
+// snapshot 返回 campaign 的深拷贝，供 API 返回
+func (r *comicCampaignRun) snapshot() *models.ComicCampaign {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.campaign
+	out.Steps = append([]string(nil), r.campaign.Steps...)
+	out.Scenes = make([]models.ComicCampaignScene, len(r.campaign.Scenes))
+	for i, sc := range r.campaign.Scenes {
+		sc.CompletedSteps = append([]string(nil), sc.CompletedSteps...)
+		out.Scenes[i] = sc
+	}
+	if r.campaign.VisionConcurrency != nil {
+		out.VisionConcurrency = make(map[string]int, len(r.campaign.VisionConcurrency))
+		for k, v := range r.campaign.VisionConcurrency {
+			out.VisionConcurrency[k] = v
+		}
+	}
+	if out.FinishedAt != nil {
+		out.Progress = 100
+	}
+	return &out
+}
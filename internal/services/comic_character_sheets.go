@@ -0,0 +1,717 @@
+// internal/services/comic_character_sheets.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+var (
+	// ErrInvalidCharacterSheet is returned for malformed sheet views, images or options.
+	ErrInvalidCharacterSheet = errors.New("invalid character sheet request")
+	// ErrCharacterSheetNotFound is returned when a character or sheet view does not exist.
+	ErrCharacterSheetNotFound = errors.New("character sheet not found")
+)
+
+// ComicJobTypeCharacterSheet 角色设定图生成任务
+const ComicJobTypeCharacterSheet = "comic_character_sheet"
+
+const (
+	// 每帧最多附带的角色设定图数量（按角色轮流取视角）
+	maxFrameCharacterReferences = 6
+	maxCharacterSheetViews      = 12
+	// 参与匹配的角色名/别名最少字符数；单字名（如“王”）几乎出现在任何文本中
+	minCharacterNameRunes = 2
+)
+
+// DefaultComicCharacterSheetViews 未指定视角时生成的设定图：正面、四分之三侧、侧面与表情集
+var DefaultComicCharacterSheetViews = []string{
+	models.ComicSheetViewFront,
+	models.ComicSheetViewThreeQuarter,
+	models.ComicSheetViewProfile,
+	models.ComicSheetViewExpressions,
+}
+
+// ComicCharacterSheetGenerateOptions 控制设定图生成
+type ComicCharacterSheetGenerateOptions struct {
+	Views     []string `json:"views,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	Style     string   `json:"style,omitempty"`
+	Overwrite bool     `json:"overwrite,omitempty"`
+}
+
+// ComicCharacterSheetPatch 修改设定图的名称、别名与外观描述（用于分镜匹配与生成提示词）
+type ComicCharacterSheetPatch struct {
+	Name        *string   `json:"name,omitempty"`
+	Aliases     *[]string `json:"aliases,omitempty"`
+	Description *string   `json:"description,omitempty"`
+}
+
+// NormalizeComicSheetView 统一视角名：小写、空格与连字符转下划线，仅允许 [a-z0-9_]
+func NormalizeComicSheetView(view string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(view))
+	v = strings.NewReplacer(" ", "_", "-", "_").Replace(v)
+	switch v {
+	case "side":
+		v = models.ComicSheetViewProfile
+	case "3/4", "three_quarters", "34":
+		v = models.ComicSheetViewThreeQuarter
+	case "expression", "faces":
+		v = models.ComicSheetViewExpressions
+	}
+	if v == "" || len(v) > 32 {
+		return "", fmt.Errorf("%w: view must be 1-32 characters", ErrInvalidCharacterSheet)
+	}
+	for _, r := range v {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return "", fmt.Errorf("%w: view %q may only contain a-z, 0-9 and _", ErrInvalidCharacterSheet, view)
+		}
+	}
+	return v, nil
+}
+
+// comicSheetViewRank 决定同一角色多个视角的取用顺序：正面最能锁定五官
+func comicSheetViewRank(view string) int {
+	switch view {
+	case models.ComicSheetViewFront:
+		return 0
+	case models.ComicSheetViewThreeQuarter:
+		return 1
+	case models.ComicSheetViewProfile:
+		return 2
+	case models.ComicSheetViewExpressions:
+		return 3
+	case models.ComicSheetViewBack:
+		return 5
+	default:
+		return 4
+	}
+}
+
+// LoadCharacterSheets 读取设定图索引；尚未创建时返回空索引
+func (s *ComicService) LoadCharacterSheets(sceneID string) (*models.ComicCharacterSheetIndex, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	idx, err := s.Repo.LoadCharacterSheets(sceneID)
+	if err != nil {
+		if !isLikelyNotExist(err) {
+			return nil, err
+		}
+		idx = nil
+	}
+	if idx == nil {
+		idx = &models.ComicCharacterSheetIndex{SceneID: sceneID}
+	}
+	if idx.Sheets == nil {
+		idx.Sheets = make(map[string]models.ComicCharacterSheet)
+	}
+	return idx, nil
+}
+
+// SaveCharacterSheetView 保存（上传或生成的）一张设定图视角；同一视角会被替换
+func (s *ComicService) SaveCharacterSheetView(sceneID, elementID, view string, data []byte, source, prompt string) (*models.ComicCharacterSheet, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	elementID = strings.TrimSpace(elementID)
+	if err := validatePathSegment(elementID); err != nil {
+		return nil, fmt.Errorf("%w: invalid element id", ErrInvalidCharacterSheet)
+	}
+	view, err := NormalizeComicSheetView(view)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: image is empty", ErrInvalidCharacterSheet)
+	}
+	sniff := data
+	if len(sniff) > 512 {
+		sniff = sniff[:512]
+	}
+	contentType := http.DetectContentType(sniff)
+	ext := ""
+	switch contentType {
+	case "image/png":
+		ext = ".png"
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/webp":
+		ext = ".webp"
+	default:
+		return nil, fmt.Errorf("%w: only PNG/JPEG/WEBP images are supported", ErrInvalidCharacterSheet)
+	}
+
+	s.sheetsMu.Lock()
+	defer s.sheetsMu.Unlock()
+
+	idx, err := s.LoadCharacterSheets(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	sheet, ok := idx.Sheets[elementID]
+	if !ok {
+		sheet = s.newCharacterSheet(sceneID, elementID)
+	}
+	pos := -1
+	for i := range sheet.Views {
+		if sheet.Views[i].View == view {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 && len(sheet.Views) >= maxCharacterSheetViews {
+		return nil, fmt.Errorf("%w: at most %d views per character", ErrInvalidCharacterSheet, maxCharacterSheetViews)
+	}
+
+	fileName, err := s.Repo.SaveCharacterSheetImage(sceneID, elementID, view, ext, data)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	entry := models.ComicCharacterSheetView{
+		View:        view,
+		FileName:    fileName,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		Source:      source,
+		Prompt:      strings.TrimSpace(prompt),
+		UpdatedAt:   now,
+	}
+	if pos >= 0 {
+		// 扩展名变化时清理旧文件
+		if old := sheet.Views[pos].FileName; old != "" && old != fileName {
+			if err := s.Repo.DeleteCharacterSheetImage(sceneID, old); err != nil {
+				utils.GetLogger().Warn("delete replaced character sheet image failed", map[string]interface{}{"scene_id": sceneID, "file_name": old, "err": err})
+			}
+		}
+		sheet.Views[pos] = entry
+	} else {
+		sheet.Views = append(sheet.Views, entry)
+	}
+	sort.SliceStable(sheet.Views, func(i, j int) bool {
+		return comicSheetViewRank(sheet.Views[i].View) < comicSheetViewRank(sheet.Views[j].View)
+	})
+	sheet.UpdatedAt = now
+	idx.SceneID = sceneID
+	idx.Sheets[elementID] = sheet
+	idx.UpdatedAt = now
+	if err := s.Repo.SaveCharacterSheets(sceneID, idx); err != nil {
+		return nil, err
+	}
+	return &sheet, nil
+}
+
+// UpdateCharacterSheet 修改设定图的名称、别名或外观描述
+func (s *ComicService) UpdateCharacterSheet(sceneID, elementID string, patch ComicCharacterSheetPatch) (*models.ComicCharacterSheet, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	elementID = strings.TrimSpace(elementID)
+	if err := validatePathSegment(elementID); err != nil {
+		return nil, fmt.Errorf("%w: invalid element id", ErrInvalidCharacterSheet)
+	}
+
+	s.sheetsMu.Lock()
+	defer s.sheetsMu.Unlock()
+
+	idx, err := s.LoadCharacterSheets(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	sheet, ok := idx.Sheets[elementID]
+	if !ok {
+		sheet = s.newCharacterSheet(sceneID, elementID)
+	}
+	if patch.Name != nil {
+		if name := strings.TrimSpace(*patch.Name); name != "" {
+			sheet.Name = name
+		}
+	}
+	if patch.Aliases != nil {
+		sheet.Aliases = normalizeCharacterSheetAliases(*patch.Aliases, sheet.Name)
+	}
+	if patch.Description != nil {
+		sheet.Description = strings.TrimSpace(*patch.Description)
+	}
+	now := time.Now()
+	sheet.UpdatedAt = now
+	idx.SceneID = sceneID
+	idx.Sheets[elementID] = sheet
+	idx.UpdatedAt = now
+	if err := s.Repo.SaveCharacterSheets(sceneID, idx); err != nil {
+		return nil, err
+	}
+	return &sheet, nil
+}
+
+// DeleteCharacterSheetView 删除单个视角；view 为空时删除整个角色设定
+func (s *ComicService) DeleteCharacterSheetView(sceneID, elementID, view string) (*models.ComicCharacterSheetIndex, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	elementID = strings.TrimSpace(elementID)
+	if strings.TrimSpace(view) != "" {
+		normalized, err := NormalizeComicSheetView(view)
+		if err != nil {
+			return nil, err
+		}
+		view = normalized
+	}
+
+	s.sheetsMu.Lock()
+	defer s.sheetsMu.Unlock()
+
+	idx, err := s.LoadCharacterSheets(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	sheet, ok := idx.Sheets[elementID]
+	if !ok {
+		return nil, ErrCharacterSheetNotFound
+	}
+	kept := make([]models.ComicCharacterSheetView, 0, len(sheet.Views))
+	removed := 0
+	for _, v := range sheet.Views {
+		if view != "" && v.View != view {
+			kept = append(kept, v)
+			continue
+		}
+		if err := s.Repo.DeleteCharacterSheetImage(sceneID, v.FileName); err != nil {
+			return nil, err
+		}
+		removed++
+	}
+	if view != "" && removed == 0 {
+		return nil, ErrCharacterSheetNotFound
+	}
+	now := time.Now()
+	if view == "" {
+		delete(idx.Sheets, elementID)
+	} else {
+		sheet.Views = kept
+		sheet.UpdatedAt = now
+		idx.Sheets[elementID] = sheet
+	}
+	idx.UpdatedAt = now
+	if err := s.Repo.SaveCharacterSheets(sceneID, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// LoadCharacterSheetViewImage 读取某个视角的图片
+func (s *ComicService) LoadCharacterSheetViewImage(sceneID, elementID, view string) ([]byte, string, error) {
+	if s == nil || s.Repo == nil {
+		return nil, "", ErrComicRepositoryNotReady
+	}
+	view, err := NormalizeComicSheetView(view)
+	if err != nil {
+		return nil, "", err
+	}
+	idx, err := s.LoadCharacterSheets(sceneID)
+	if err != nil {
+		return nil, "", err
+	}
+	sheet, ok := idx.Sheets[strings.TrimSpace(elementID)]
+	if !ok {
+		return nil, "", ErrCharacterSheetNotFound
+	}
+	for _, v := range sheet.Views {
+		if v.View != view {
+			continue
+		}
+		data, err := s.Repo.LoadCharacterSheetImage(sceneID, v.FileName)
+		if err != nil {
+			if isLikelyNotExist(err) {
+				return nil, "", ErrCharacterSheetNotFound
+			}
+			return nil, "", err
+		}
+		return data, v.ContentType, nil
+	}
+	return nil, "", ErrCharacterSheetNotFound
+}
+
+// GenerateCharacterSheetAsync 用 Vision 生成角色设定图。正面先生成，
+// 之后的视角把正面作为参考图传给支持多参考图的 provider，以保持五官一致。
+func (s *ComicService) GenerateCharacterSheetAsync(ctx context.Context, sceneID string, elementID string, opts ComicCharacterSheetGenerateOptions) (taskID string, err error) {
+	if err := s.ensureVisionReady(); err != nil {
+		return "", err
+	}
+	elementID = strings.TrimSpace(elementID)
+	if err := validatePathSegment(elementID); err != nil {
+		return "", fmt.Errorf("%w: invalid element id", ErrInvalidCharacterSheet)
+	}
+	views, err := resolveCharacterSheetViews(opts.Views)
+	if err != nil {
+		return "", err
+	}
+	name, description := s.resolveCharacterForSheet(sceneID, elementID)
+	if name == "" {
+		return "", fmt.Errorf("%w: character %s is not in key elements", ErrCharacterSheetNotFound, elementID)
+	}
+	style := strings.TrimSpace(opts.Style)
+	if style == "" {
+		if ke, err := s.Repo.LoadKeyElements(sceneID); err == nil && ke != nil {
+			style = strings.Join(ke.StyleTags, ", ")
+		}
+	}
+
+	taskID = fmt.Sprintf("comic_sheet_%s_%s_%d", sceneID, elementID, time.Now().UnixNano())
+	tracker := s.Progress.CreateTracker(taskID)
+	tracker.UpdateProgress(1, "准备生成角色设定图...")
+
+	err = s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeCharacterSheet, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		defer func() {
+			if r := recover(); r != nil {
+				utils.GetLogger().Error("comic character sheet panic", map[string]interface{}{"scene_id": sceneID, "element_id": elementID, "task_id": taskID, "panic": fmt.Sprintf("%v", r)})
+				tracker.Fail("任务异常崩溃")
+			}
+		}()
+
+		existing := map[string]bool{}
+		var anchor []byte
+		if idx, err := s.LoadCharacterSheets(sceneID); err == nil {
+			if sheet, ok := idx.Sheets[elementID]; ok {
+				for _, v := range sheet.Views {
+					existing[v.View] = true
+				}
+			}
+		}
+		if !opts.Overwrite && existing[models.ComicSheetViewFront] {
+			anchor, _, _ = s.LoadCharacterSheetViewImage(sceneID, elementID, models.ComicSheetViewFront)
+		}
+
+		for i, view := range views {
+			if err := jobCtx.Err(); err != nil {
+				tracker.Fail("任务已取消")
+				return err
+			}
+			progress := 5 + int(float64(i)/float64(len(views))*90.0)
+			if existing[view] && !opts.Overwrite {
+				tracker.UpdateProgress(progress, fmt.Sprintf("跳过已存在的设定图：%s", view))
+				continue
+			}
+			tracker.UpdateProgress(progress, fmt.Sprintf("生成设定图：%s", view))
+
+			prompt := applyStyleToPrompt(buildCharacterSheetPrompt(name, description, view), style)
+			genOpts := VisionGenerateOptions{Width: 768, Height: 1024, Model: strings.TrimSpace(opts.Model)}
+			if view == models.ComicSheetViewExpressions {
+				genOpts.Width, genOpts.Height = 1024, 1024
+			}
+			if len(anchor) > 0 {
+				genOpts.ReferenceImages = [][]byte{anchor}
+			}
+			img, err := s.Vision.GenerateImage(jobCtx, "", prompt, genOpts)
+			if err == nil && (img == nil || len(img.Data) == 0) {
+				err = errors.New("empty image")
+			}
+			if err != nil {
+				failMsg := s.buildFrameVisionFailureMessage(elementID+"/"+view, genOpts, err)
+				tracker.EmitProgressEvent(progress, failMsg, "sheet_failed", elementID)
+				tracker.Fail(failMsg)
+				return err
+			}
+			if _, err := s.SaveCharacterSheetView(sceneID, elementID, view, img.Data, models.ComicSheetSourceGenerated, prompt); err != nil {
+				tracker.Fail("保存设定图失败")
+				return err
+			}
+			if view == models.ComicSheetViewFront {
+				anchor = img.Data
+			}
+			tracker.EmitProgressEvent(progress, fmt.Sprintf("设定图已写入：%s", view), "sheet_written", elementID)
+		}
+		tracker.Complete("角色设定图生成完成")
+		return nil
+	})
+	if err != nil {
+		tracker.Fail("任务提交失败")
+		return "", err
+	}
+	return taskID, nil
+}
+
+// resolveCharacterSheetViews 去重并把正面排到最前，保证后续视角能以正面为参考
+func resolveCharacterSheetViews(views []string) ([]string, error) {
+	if len(views) == 0 {
+		views = DefaultComicCharacterSheetViews
+	}
+	out := make([]string, 0, len(views))
+	seen := map[string]bool{}
+	for _, v := range views {
+		normalized, err := NormalizeComicSheetView(v)
+		if err != nil {
+			return nil, err
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		out = append(out, normalized)
+	}
+	if len(out) > maxCharacterSheetViews {
+		return nil, fmt.Errorf("%w: at most %d views per character", ErrInvalidCharacterSheet, maxCharacterSheetViews)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i] == models.ComicSheetViewFront && out[j] != models.ComicSheetViewFront
+	})
+	return out, nil
+}
+
+// resolveCharacterForSheet 从已有设定或关键元素中取角色名称与外观描述
+func (s *ComicService) resolveCharacterForSheet(sceneID, elementID string) (name string, description string) {
+	if idx, err := s.LoadCharacterSheets(sceneID); err == nil {
+		if sheet, ok := idx.Sheets[elementID]; ok {
+			name, description = strings.TrimSpace(sheet.Name), strings.TrimSpace(sheet.Description)
+		}
+	}
+	if ke, err := s.Repo.LoadKeyElements(sceneID); err == nil && ke != nil {
+		for _, c := range ke.Characters {
+			if strings.TrimSpace(c.ID) != elementID {
+				continue
+			}
+			if name == "" {
+				name = strings.TrimSpace(c.Name)
+			}
+			if description == "" {
+				description = strings.TrimSpace(c.Description)
+			}
+			break
+		}
+	}
+	return name, description
+}
+
+func (s *ComicService) newCharacterSheet(sceneID, elementID string) models.ComicCharacterSheet {
+	name, description := s.resolveCharacterForSheet(sceneID, elementID)
+	if name == "" {
+		name = elementID
+	}
+	return models.ComicCharacterSheet{ElementID: elementID, Name: name, Description: description}
+}
+
+func normalizeCharacterSheetAliases(aliases []string, name string) []string {
+	out := make([]string, 0, len(aliases))
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(name)): true}
+	for _, a := range aliases {
+		a = strings.TrimSpace(a)
+		key := strings.ToLower(a)
+		if a == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+func buildCharacterSheetPrompt(name, description, view string) string {
+	subject := name
+	if description != "" {
+		subject += ", " + description
+	}
+	var shot string
+	switch view {
+	case models.ComicSheetViewFront:
+		shot = "full-body front view, facing the viewer, neutral pose, neutral expression"
+	case models.ComicSheetViewThreeQuarter:
+		shot = "three-quarter view, upper body, looking slightly off camera"
+	case models.ComicSheetViewProfile:
+		shot = "side profile view, head and shoulders, facing left"
+	case models.ComicSheetViewBack:
+		shot = "full-body back view, showing hair and costume from behind"
+	case models.ComicSheetViewExpressions:
+		shot = "expression sheet, 2x2 grid of head shots: neutral, happy, angry, sad"
+	default:
+		shot = strings.ReplaceAll(view, "_", " ") + " view"
+	}
+	return fmt.Sprintf("character model sheet of %s, %s, consistent face, hairstyle and costume, plain light background, clean line art, no text", subject, shot)
+}
+
+// indexWholeName 返回 name 在 text 中首次完整出现的位置，找不到返回 -1。
+// 少于 minCharacterNameRunes 个字符的名字不参与匹配；以字母或数字开头/结尾的名字
+// 要求两侧不是字母或数字，避免 “Ann” 命中 “Annual”。中日韩文字没有词边界，按整名匹配。
+func indexWholeName(text string, name string) int {
+	if utf8.RuneCountInString(name) < minCharacterNameRunes {
+		return -1
+	}
+	first, _ := utf8.DecodeRuneInString(name)
+	last, _ := utf8.DecodeLastRuneInString(name)
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], name)
+		if i < 0 {
+			return -1
+		}
+		start := offset + i
+		end := start + len(name)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !(isLatinWordRune(first) && start > 0 && isLatinWordRune(before)) &&
+			!(isLatinWordRune(last) && end < len(text) && isLatinWordRune(after)) {
+			return start
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+	return -1
+}
+
+// isLatinWordRune 判断字符是否属于有词边界的文字（字母或数字，不含中日韩文字）
+func isLatinWordRune(r rune) bool {
+	if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// selectCharacterSheetReferences 根据分镜描述与提示词中出现的角色挑选设定图：
+// 按角色名或别名首次完整出现的位置排序（见 indexWholeName），
+// 先取每个角色的正面，再轮流补充其他视角。
+// 没有设定图的角色回退到 references/ 中上传的元素参考图。
+func (s *ComicService) selectCharacterSheetReferences(sceneID string, frame *models.ComicFramePlan, promptText string, keyElements *models.ComicKeyElements, sheets *models.ComicCharacterSheetIndex, refIndex *models.ComicReferenceIndex) [][]byte {
+	if s == nil || s.Repo == nil {
+		return nil
+	}
+	searchText := strings.TrimSpace(promptText)
+	if frame != nil && strings.TrimSpace(frame.Description) != "" {
+		searchText = strings.TrimSpace(searchText + "\n" + frame.Description)
+	}
+	searchText = strings.ToLower(searchText)
+	if searchText == "" {
+		return nil
+	}
+
+	type candidate struct {
+		elementID string
+		pos       int
+		files     []string // sheet views in rank order
+		fallback  string   // uploaded element reference
+	}
+	firstIndex := func(names ...string) int {
+		best := -1
+		for _, n := range names {
+			n = strings.ToLower(strings.TrimSpace(n))
+			if n == "" {
+				continue
+			}
+			if i := indexWholeName(searchText, n); i >= 0 && (best < 0 || i < best) {
+				best = i
+			}
+		}
+		return best
+	}
+	keyNames := map[string]string{}
+	if keyElements != nil {
+		for _, c := range keyElements.Characters {
+			keyNames[strings.TrimSpace(c.ID)] = c.Name
+		}
+	}
+
+	matched := map[string]*candidate{}
+	if sheets != nil {
+		for id, sheet := range sheets.Sheets {
+			if len(sheet.Views) == 0 {
+				continue
+			}
+			names := append([]string{sheet.Name, keyNames[id], id}, sheet.Aliases...)
+			pos := firstIndex(names...)
+			if pos < 0 {
+				continue
+			}
+			c := &candidate{elementID: id, pos: pos}
+			for _, v := range sheet.Views {
+				c.files = append(c.files, v.FileName)
+			}
+			matched[id] = c
+		}
+	}
+	if keyElements != nil && refIndex != nil {
+		for _, ch := range keyElements.Characters {
+			id := strings.TrimSpace(ch.ID)
+			if _, ok := matched[id]; ok || id == "" {
+				continue
+			}
+			meta, ok := refIndex.References[id]
+			if !ok || strings.TrimSpace(meta.FileName) == "" {
+				continue
+			}
+			if pos := firstIndex(ch.Name, id); pos >= 0 {
+				matched[id] = &candidate{elementID: id, pos: pos, fallback: meta.FileName}
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	ordered := make([]*candidate, 0, len(matched))
+	for _, c := range matched {
+		ordered = append(ordered, c)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].pos != ordered[j].pos {
+			return ordered[i].pos < ordered[j].pos
+		}
+		return ordered[i].elementID < ordered[j].elementID
+	})
+
+	out := make([][]byte, 0, maxFrameCharacterReferences)
+	for round := 0; len(out) < maxFrameCharacterReferences; round++ {
+		added := false
+		for _, c := range ordered {
+			if len(out) >= maxFrameCharacterReferences {
+				break
+			}
+			var data []byte
+			var err error
+			switch {
+			case round < len(c.files):
+				data, err = s.Repo.LoadCharacterSheetImage(sceneID, c.files[round])
+			case round == 0 && c.fallback != "":
+				data, err = s.Repo.LoadReference(sceneID, c.fallback)
+			default:
+				continue
+			}
+			added = true
+			if err != nil {
+				if !isLikelyStorageNotFound(err) {
+					utils.GetLogger().Warn("load character reference failed", map[string]interface{}{"scene_id": sceneID, "element_id": c.elementID, "err": err})
+				}
+				continue
+			}
+			if len(data) > 0 {
+				out = append(out, data)
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return out
+}
+
+// bestEffortLoadCharacterSheets 为生成任务读取设定图索引；读取失败只记录日志
+func (s *ComicService) bestEffortLoadCharacterSheets(sceneID string) *models.ComicCharacterSheetIndex {
+	if s == nil || s.Repo == nil {
+		return nil
+	}
+	idx, err := s.Repo.LoadCharacterSheets(sceneID)
+	if err != nil {
+		if !isLikelyStorageNotFound(err) {
+			utils.GetLogger().Warn("load character sheets failed", map[string]interface{}{"scene_id": sceneID, "err": err})
+		}
+		return nil
+	}
+	return idx
+}
@@ -0,0 +1,300 @@
+// internal/services/comic_export.go
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+)
+
+var (
+	// ErrInvalidComicExportOptions is returned when export query options are out of range.
+	ErrInvalidComicExportOptions = errors.New("invalid comic export options")
+	// ErrComicExportEmpty is returned when a scene has no rendered pages or frame images to export.
+	ErrComicExportEmpty = errors.New("no comic images to export")
+)
+
+// 导出图片来源
+const (
+	ComicExportSourcePages  = "pages"
+	ComicExportSourceFrames = "frames"
+)
+
+// ComicExportImage 是一张按阅读顺序排列的导出图片（PNG/JPEG 原始字节）
+type ComicExportImage struct {
+	Name   string // page_01 / frame_1 ...
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// LoadComicExportImages 按阅读顺序收集导出图片。
+// source 为空时：存在 pages/layout.json 则使用排版后的页面，否则使用分镜原图。
+func (s *ComicService) LoadComicExportImages(sceneID string, source string) ([]ComicExportImage, string, error) {
+	if s == nil || s.Repo == nil {
+		return nil, "", ErrComicRepositoryNotReady
+	}
+	source = strings.ToLower(strings.TrimSpace(source))
+	switch source {
+	case "":
+		layout, err := s.Repo.LoadPageLayout(sceneID)
+		if err == nil && layout != nil && len(layout.Pages) > 0 {
+			source = ComicExportSourcePages
+		} else {
+			source = ComicExportSourceFrames
+		}
+	case ComicExportSourcePages, ComicExportSourceFrames:
+	default:
+		return nil, "", fmt.Errorf("%w: source must be pages or frames", ErrInvalidComicExportOptions)
+	}
+
+	var out []ComicExportImage
+	if source == ComicExportSourcePages {
+		layout, err := s.Repo.LoadPageLayout(sceneID)
+		if err != nil {
+			if isLikelyNotExist(err) {
+				return nil, source, ErrComicExportEmpty
+			}
+			return nil, source, err
+		}
+		for _, page := range layout.Pages {
+			data, err := s.Repo.LoadPageImage(sceneID, page.ID)
+			if err != nil {
+				if isLikelyNotExist(err) {
+					continue
+				}
+				return nil, source, err
+			}
+			if img, ok := decodeComicExportImage(page.ID, data); ok {
+				out = append(out, img)
+			}
+		}
+	} else {
+		breakdown, err := s.Repo.LoadAnalysis(sceneID)
+		if err != nil {
+			if isLikelyNotExist(err) {
+				return nil, source, ErrComicExportEmpty
+			}
+			return nil, source, err
+		}
+		frames := append([]models.ComicFramePlan(nil), breakdown.Frames...)
+		sort.SliceStable(frames, func(i, j int) bool { return frames[i].Order < frames[j].Order })
+		for _, frame := range frames {
+			data, err := s.Repo.LoadFrameImage(sceneID, frame.ID)
+			if err != nil {
+				if isLikelyNotExist(err) {
+					continue
+				}
+				return nil, source, err
+			}
+			if img, ok := decodeComicExportImage(frame.ID, data); ok {
+				out = append(out, img)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, source, ErrComicExportEmpty
+	}
+	return out, source, nil
+}
+
+func decodeComicExportImage(name string, data []byte) (ComicExportImage, bool) {
+	if len(data) == 0 {
+		return ComicExportImage{}, false
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return ComicExportImage{}, false
+	}
+	return ComicExportImage{Name: name, Data: data, Width: cfg.Width, Height: cfg.Height}, true
+}
+
+// comicInfo 对应 ComicRack 的 ComicInfo.xml（v2.0 schema 的常用子集）
+type comicInfo struct {
+	XMLName     xml.Name       `xml:"ComicInfo"`
+	XSI         string         `xml:"xmlns:xsi,attr"`
+	XSD         string         `xml:"xmlns:xsd,attr"`
+	Title       string         `xml:"Title,omitempty"`
+	Series      string         `xml:"Series,omitempty"`
+	Summary     string         `xml:"Summary,omitempty"`
+	Notes       string         `xml:"Notes,omitempty"`
+	Year        int            `xml:"Year,omitempty"`
+	Month       int            `xml:"Month,omitempty"`
+	Day         int            `xml:"Day,omitempty"`
+	Genre       string         `xml:"Genre,omitempty"`
+	PageCount   int            `xml:"PageCount"`
+	LanguageISO string         `xml:"LanguageISO,omitempty"`
+	Characters  string         `xml:"Characters,omitempty"`
+	Locations   string         `xml:"Locations,omitempty"`
+	Pages       comicInfoPages `xml:"Pages"`
+}
+
+type comicInfoPages struct {
+	Page []comicInfoPage `xml:"Page"`
+}
+
+type comicInfoPage struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+	ImageSize   int    `xml:"ImageSize,attr,omitempty"`
+}
+
+// ComicExportTitle 返回场景标题，缺省时回退到场景 ID
+func (s *ComicService) ComicExportTitle(sceneID string) string {
+	if s != nil && s.Scene != nil {
+		if data, err := s.Scene.LoadScene(sceneID); err == nil && data != nil {
+			if t := strings.TrimSpace(data.Scene.Title); t != "" {
+				return t
+			}
+			if t := strings.TrimSpace(data.Scene.Name); t != "" {
+				return t
+			}
+		}
+	}
+	return "Comic " + sceneID
+}
+
+// BuildComicInfoXML 根据场景标题、简介、角色与时代生成 ComicInfo.xml
+func (s *ComicService) BuildComicInfoXML(sceneID string, images []ComicExportImage) ([]byte, error) {
+	now := time.Now()
+	info := comicInfo{
+		XSI:       "http://www.w3.org/2001/XMLSchema-instance",
+		XSD:       "http://www.w3.org/2001/XMLSchema",
+		Title:     s.ComicExportTitle(sceneID),
+		Year:      now.Year(),
+		Month:     int(now.Month()),
+		Day:       now.Day(),
+		PageCount: len(images),
+	}
+	if s != nil && s.Scene != nil {
+		if data, err := s.Scene.LoadScene(sceneID); err == nil && data != nil {
+			scene := data.Scene
+			info.Series = strings.TrimSpace(scene.Name)
+			if info.Series == info.Title {
+				info.Series = ""
+			}
+			info.Summary = strings.TrimSpace(scene.Summary)
+			if info.Summary == "" {
+				info.Summary = strings.TrimSpace(scene.Description)
+			}
+			info.Genre = joinNonEmpty(scene.Themes, ", ")
+			names := make([]string, 0, len(data.Characters))
+			for _, ch := range data.Characters {
+				if ch != nil {
+					names = append(names, ch.Name)
+				}
+			}
+			info.Characters = joinNonEmpty(names, ", ")
+			places := make([]string, 0, len(scene.Locations))
+			for _, loc := range scene.Locations {
+				places = append(places, loc.Name)
+			}
+			info.Locations = joinNonEmpty(places, ", ")
+
+			notes := make([]string, 0, 2)
+			if era := strings.TrimSpace(scene.Era); era != "" {
+				notes = append(notes, "Era: "+era)
+			}
+			if atm := strings.TrimSpace(scene.Atmosphere); atm != "" {
+				notes = append(notes, "Atmosphere: "+atm)
+			}
+			info.Notes = strings.Join(notes, "\n")
+			info.LanguageISO = guessComicLanguageISO(info.Title + info.Summary)
+		}
+	}
+
+	info.Pages.Page = make([]comicInfoPage, 0, len(images))
+	for i, img := range images {
+		p := comicInfoPage{Image: i, ImageWidth: img.Width, ImageHeight: img.Height, ImageSize: len(img.Data)}
+		if i == 0 {
+			p.Type = "FrontCover"
+		}
+		info.Pages.Page = append(info.Pages.Page, p)
+	}
+
+	body, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// WriteComicCBZ 写出 CBZ（按阅读顺序编号的图片 + ComicInfo.xml）
+func WriteComicCBZ(w io.Writer, images []ComicExportImage, comicInfoXML []byte) error {
+	if len(images) == 0 {
+		return ErrComicExportEmpty
+	}
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for i, img := range images {
+		ext := ".png"
+		if bytes.HasPrefix(img.Data, []byte{0xFF, 0xD8}) {
+			ext = ".jpg"
+		}
+		// 图片已压缩，直接存储即可
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("%03d%s", i+1, ext), Method: zip.Store, Modified: now})
+		if err != nil {
+			_ = zw.Close()
+			return err
+		}
+		if _, err := fw.Write(img.Data); err != nil {
+			_ = zw.Close()
+			return err
+		}
+	}
+	if len(comicInfoXML) > 0 {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "ComicInfo.xml", Method: zip.Deflate, Modified: now})
+		if err != nil {
+			_ = zw.Close()
+			return err
+		}
+		if _, err := fw.Write(comicInfoXML); err != nil {
+			_ = zw.Close()
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func joinNonEmpty(values []string, sep string) string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+// guessComicLanguageISO 粗略判断文本语言：含汉字为 zh，含假名为 ja，否则 en
+func guessComicLanguageISO(text string) string {
+	hasHan := false
+	for _, r := range text {
+		switch {
+		case r >= 0x3040 && r <= 0x30FF:
+			return "ja"
+		case r >= 0x4E00 && r <= 0x9FFF:
+			hasHan = true
+		}
+	}
+	if hasHan {
+		return "zh"
+	}
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	return "en"
+}
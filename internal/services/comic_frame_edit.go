@@ -0,0 +1,208 @@
+// internal/services/comic_frame_edit.go
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+var (
+	// ErrInvalidInpaintRequest is returned for a missing prompt or an unusable mask.
+	ErrInvalidInpaintRequest = errors.New("invalid inpaint request")
+	// ErrInpaintingUnsupported is returned when the vision provider for the model cannot inpaint.
+	ErrInpaintingUnsupported = errors.New("vision provider does not support inpainting")
+	// ErrNoFrameVersionToRestore is returned by undo when there is no earlier version.
+	ErrNoFrameVersionToRestore = errors.New("no earlier frame version to restore")
+)
+
+// ComicJobTypeInpaintFrame 单帧局部重绘任务
+const ComicJobTypeInpaintFrame = "comic_inpaint_frame"
+
+// ComicInpaintOptions 局部重绘参数。Mask 与当前帧图片同尺寸，白色区域重绘、黑色区域保留。
+type ComicInpaintOptions struct {
+	Prompt            string  `json:"prompt"`
+	NegativePrompt    string  `json:"negative_prompt,omitempty"`
+	Model             string  `json:"model,omitempty"`
+	DenoisingStrength float64 `json:"denoising_strength,omitempty"`
+	Mask              []byte  `json:"-"`
+}
+
+// InpaintFrameAsync 按遮罩与编辑提示词重绘某帧的局部区域。
+// 结果保存为新的帧版本，之前的图片保留在版本记录中，可通过 UndoFrameEdit 恢复。
+func (s *ComicService) InpaintFrameAsync(ctx context.Context, sceneID string, frameID string, opts ComicInpaintOptions) (taskID string, err error) {
+	if err := s.ensureVisionReady(); err != nil {
+		return "", err
+	}
+	frameID = strings.TrimSpace(frameID)
+	if err := validatePathSegment(frameID); err != nil {
+		return "", fmt.Errorf("%w: invalid frame id", ErrInvalidInpaintRequest)
+	}
+	opts.Prompt = strings.TrimSpace(opts.Prompt)
+	if opts.Prompt == "" {
+		return "", fmt.Errorf("%w: prompt is required", ErrInvalidInpaintRequest)
+	}
+	if opts.DenoisingStrength < 0 || opts.DenoisingStrength > 1 {
+		return "", fmt.Errorf("%w: denoising_strength must be within 0..1", ErrInvalidInpaintRequest)
+	}
+
+	source, err := s.Repo.LoadFrameImage(sceneID, frameID)
+	if err != nil {
+		return "", err
+	}
+	width, height, err := validateInpaintMask(source, opts.Mask)
+	if err != nil {
+		return "", err
+	}
+
+	var fp *models.ComicFramePrompt
+	if loaded, err := s.Repo.LoadPrompt(sceneID, frameID); err == nil {
+		fp = loaded
+	}
+	model := strings.TrimSpace(opts.Model)
+	if model == "" && fp != nil {
+		model = strings.TrimSpace(fp.Model)
+	}
+	if !s.Vision.SupportsInpainting(model) {
+		return "", fmt.Errorf("%w: %s", ErrInpaintingUnsupported, s.resolveVisionProviderForModel(model))
+	}
+
+	genOpts := VisionGenerateOptions{
+		Model:             model,
+		Width:             width,
+		Height:            height,
+		NegativePrompt:    strings.TrimSpace(opts.NegativePrompt),
+		ReferenceImage:    source,
+		Mask:              opts.Mask,
+		DenoisingStrength: opts.DenoisingStrength,
+	}
+	promptText := opts.Prompt
+	if fp != nil {
+		promptText = applyStyleToPrompt(promptText, fp.Style)
+		if genOpts.NegativePrompt == "" {
+			genOpts.NegativePrompt = fp.NegativePrompt
+		}
+		genOpts.NegativePrompt = applyStyleGuardToNegativePrompt(fp.Style, genOpts.NegativePrompt)
+	}
+
+	taskID = fmt.Sprintf("comic_inpaint_%s_%s_%d", sceneID, frameID, time.Now().UnixNano())
+	tracker := s.Progress.CreateTracker(taskID)
+	tracker.UpdateProgress(1, "准备局部重绘...")
+
+	err = s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeInpaintFrame, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		defer func() {
+			if r := recover(); r != nil {
+				utils.GetLogger().Error("comic inpaint panic", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "task_id": taskID, "panic": fmt.Sprintf("%v", r)})
+				tracker.Fail("任务异常崩溃")
+			}
+		}()
+
+		tracker.UpdateProgress(30, "局部重绘中...")
+		img, err := s.Vision.GenerateImage(jobCtx, "", promptText, genOpts)
+		if err == nil && (img == nil || len(img.Data) == 0) {
+			err = errors.New("empty image")
+		}
+		if err != nil {
+			failMsg := s.buildFrameVisionFailureMessage(frameID, genOpts, err)
+			tracker.EmitProgressEvent(30, failMsg, "frame_failed", frameID)
+			tracker.Fail(failMsg)
+			return err
+		}
+		if err := jobCtx.Err(); err != nil {
+			tracker.Fail("任务已取消")
+			return err
+		}
+
+		entry := models.ComicFrameImageVersion{
+			Source:         models.ComicFrameVersionSourceInpaint,
+			Prompt:         opts.Prompt,
+			NegativePrompt: genOpts.NegativePrompt,
+			Model:          model,
+			Width:          width,
+			Height:         height,
+		}
+		versions, err := s.recordFrameVersion(sceneID, frameID, img.Data, entry, true)
+		if err != nil {
+			tracker.Fail("保存重绘结果失败")
+			return err
+		}
+		tracker.EmitProgressEvent(95, fmt.Sprintf("图片已写入：%s（版本 %d）", frameID, versions.Current), "image_written", frameID)
+		tracker.Complete("局部重绘完成")
+		return nil
+	})
+	if err != nil {
+		tracker.Fail("任务提交失败")
+		return "", err
+	}
+	return taskID, nil
+}
+
+// UndoFrameEdit 把帧图片恢复到当前版本之前的一个版本
+func (s *ComicService) UndoFrameEdit(sceneID string, frameID string) (*models.ComicFrameImageVersions, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	frameID = strings.TrimSpace(frameID)
+
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	idx, err := s.loadFrameVersionsSynced(sceneID, frameID)
+	if err != nil {
+		return nil, err
+	}
+	var prev *models.ComicFrameImageVersion
+	for i := range idx.Versions {
+		v := &idx.Versions[i]
+		if v.Version < idx.Current && (prev == nil || v.Version > prev.Version) {
+			prev = v
+		}
+	}
+	if prev == nil {
+		return nil, ErrNoFrameVersionToRestore
+	}
+	if err := s.activateFrameVersion(idx, prev); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SaveFrameImageVersions(sceneID, frameID, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// validateInpaintMask 检查遮罩可解码、与原图同尺寸且至少有一处需要重绘；返回原图尺寸
+func validateInpaintMask(source []byte, mask []byte) (int, int, error) {
+	if len(mask) == 0 {
+		return 0, 0, fmt.Errorf("%w: mask is required", ErrInvalidInpaintRequest)
+	}
+	srcCfg, _, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil {
+		return 0, 0, fmt.Errorf("decode frame image: %w", err)
+	}
+	maskImg, _, err := image.Decode(bytes.NewReader(mask))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: mask must be a PNG/JPEG/WEBP image", ErrInvalidInpaintRequest)
+	}
+	b := maskImg.Bounds()
+	if b.Dx() != srcCfg.Width || b.Dy() != srcCfg.Height {
+		return 0, 0, fmt.Errorf("%w: mask is %dx%d but the frame is %dx%d", ErrInvalidInpaintRequest, b.Dx(), b.Dy(), srcCfg.Width, srcCfg.Height)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.GrayModel.Convert(maskImg.At(x, y)).(color.Gray).Y >= 128 {
+				return srcCfg.Width, srcCfg.Height, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: mask has no white (edit) area", ErrInvalidInpaintRequest)
+}
@@ -0,0 +1,527 @@
+// internal/services/comic_frame_versions.go
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+var (
+	// ErrFrameVersionNotFound is returned when a frame has no version with the requested number.
+	ErrFrameVersionNotFound = errors.New("frame image version not found")
+	// ErrInvalidFrameVersionGCPolicy is returned for negative keep_last / older_than_days values.
+	ErrInvalidFrameVersionGCPolicy = errors.New("invalid frame version gc policy")
+)
+
+const (
+	// defaultFrameVersionKeepLast 未指定任何 GC 条件时，每帧保留的最新版本数
+	defaultFrameVersionKeepLast = 5
+	// frameVersionPixelThreshold 像素对比时单通道差值超过该值（0..255）才计为变化
+	frameVersionPixelThreshold = 16
+)
+
+// ComicFrameVersionGCPolicy 帧版本回收策略。
+// 当前版本与固定（pinned）版本永远不会被回收；KeepLast 为每帧额外保留的最新版本数，
+// OlderThanDays > 0 时只回收早于该天数的版本。两者都为 0 时按 KeepLast=5 处理。
+type ComicFrameVersionGCPolicy struct {
+	KeepLast      int      `json:"keep_last,omitempty"`
+	OlderThanDays int      `json:"older_than_days,omitempty"`
+	FrameIDs      []string `json:"frame_ids,omitempty"`
+	DryRun        bool     `json:"dry_run,omitempty"`
+}
+
+// ListFrameVersions 返回某帧的全部图片版本（按版本号升序）。
+// 尚未记录的当前图片会先补记为一个版本。
+func (s *ComicService) ListFrameVersions(sceneID string, frameID string) (*models.ComicFrameImageVersions, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	frameID = strings.TrimSpace(frameID)
+
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	idx, err := s.loadFrameVersionsSynced(sceneID, frameID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(idx.Versions, func(i, j int) bool { return idx.Versions[i].Version < idx.Versions[j].Version })
+	return idx, nil
+}
+
+// LoadFrameVersionImage 读取某个版本的图片数据
+func (s *ComicService) LoadFrameVersionImage(sceneID string, frameID string, version int) ([]byte, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	frameID = strings.TrimSpace(frameID)
+
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	idx, err := s.Repo.LoadFrameImageVersions(sceneID, frameID)
+	if err != nil {
+		if isLikelyStorageNotFound(err) {
+			return nil, ErrFrameVersionNotFound
+		}
+		return nil, err
+	}
+	v := findFrameVersion(idx, version)
+	if v == nil {
+		return nil, ErrFrameVersionNotFound
+	}
+	return s.Repo.LoadFrameImageVersion(sceneID, frameID, v.FileName)
+}
+
+// PinFrameVersion 把指定版本设为当前图片并标记为固定；固定版本不会被 GC 回收。
+// pinned=false 时只取消固定标记，不改变当前图片。
+func (s *ComicService) PinFrameVersion(sceneID string, frameID string, version int, pinned bool) (*models.ComicFrameImageVersions, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	frameID = strings.TrimSpace(frameID)
+
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	idx, err := s.loadFrameVersionsSynced(sceneID, frameID)
+	if err != nil {
+		return nil, err
+	}
+	v := findFrameVersion(idx, version)
+	if v == nil {
+		return nil, ErrFrameVersionNotFound
+	}
+	if pinned {
+		if err := s.activateFrameVersion(idx, v); err != nil {
+			return nil, err
+		}
+	}
+	v.Pinned = pinned
+	idx.UpdatedAt = time.Now()
+	if err := s.Repo.SaveFrameImageVersions(sceneID, frameID, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// CompareFrameVersions 对比同一帧的两个版本：列出生成参数的差异，并在尺寸一致时计算像素差异。
+func (s *ComicService) CompareFrameVersions(sceneID string, frameID string, a int, b int) (*models.ComicFrameVersionComparison, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	frameID = strings.TrimSpace(frameID)
+
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	idx, err := s.Repo.LoadFrameImageVersions(sceneID, frameID)
+	if err != nil {
+		if isLikelyStorageNotFound(err) {
+			return nil, ErrFrameVersionNotFound
+		}
+		return nil, err
+	}
+	va := findFrameVersion(idx, a)
+	vb := findFrameVersion(idx, b)
+	if va == nil || vb == nil {
+		return nil, ErrFrameVersionNotFound
+	}
+
+	out := &models.ComicFrameVersionComparison{
+		SceneID:       sceneID,
+		FrameID:       frameID,
+		A:             *va,
+		B:             *vb,
+		ChangedFields: diffFrameVersionFields(va, vb),
+	}
+
+	dataA, err := s.Repo.LoadFrameImageVersion(sceneID, frameID, va.FileName)
+	if err != nil {
+		return nil, err
+	}
+	dataB, err := s.Repo.LoadFrameImageVersion(sceneID, frameID, vb.FileName)
+	if err != nil {
+		return nil, err
+	}
+	pixels, err := diffFrameVersionPixels(dataA, dataB)
+	if err != nil {
+		utils.GetLogger().Warn("compare frame version pixels failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "a": a, "b": b, "err": err})
+	} else {
+		out.Pixels = pixels
+	}
+	return out, nil
+}
+
+// GCFrameVersions 按策略回收旧的帧版本文件。DryRun 时只返回将被删除的版本。
+func (s *ComicService) GCFrameVersions(sceneID string, policy ComicFrameVersionGCPolicy) (*models.ComicFrameVersionGCResult, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	if policy.KeepLast < 0 || policy.OlderThanDays < 0 {
+		return nil, fmt.Errorf("%w: keep_last and older_than_days must not be negative", ErrInvalidFrameVersionGCPolicy)
+	}
+	if policy.KeepLast == 0 && policy.OlderThanDays == 0 {
+		policy.KeepLast = defaultFrameVersionKeepLast
+	}
+
+	frameIDs := make([]string, 0, len(policy.FrameIDs))
+	for _, id := range policy.FrameIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if err := validatePathSegment(id); err != nil {
+			return nil, fmt.Errorf("%w: invalid frame id %q", ErrInvalidFrameVersionGCPolicy, id)
+		}
+		frameIDs = append(frameIDs, id)
+	}
+	if len(frameIDs) == 0 {
+		ids, err := s.Repo.ListFrameVersionFrameIDs(sceneID)
+		if err != nil && !isLikelyStorageNotFound(err) {
+			return nil, err
+		}
+		frameIDs = ids
+	}
+
+	var cutoff time.Time
+	if policy.OlderThanDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -policy.OlderThanDays)
+	}
+	result := &models.ComicFrameVersionGCResult{SceneID: sceneID, DryRun: policy.DryRun, Deleted: []models.ComicFrameVersionGCEntry{}}
+
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	for _, frameID := range frameIDs {
+		idx, err := s.Repo.LoadFrameImageVersions(sceneID, frameID)
+		if err != nil {
+			if isLikelyStorageNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		collect := selectFrameVersionsForGC(idx, policy.KeepLast, cutoff)
+		if len(collect) == 0 {
+			continue
+		}
+
+		kept := make([]models.ComicFrameImageVersion, 0, len(idx.Versions)-len(collect))
+		for _, v := range idx.Versions {
+			if _, ok := collect[v.Version]; !ok {
+				kept = append(kept, v)
+				continue
+			}
+			if !policy.DryRun {
+				if err := s.Repo.DeleteFrameImageVersion(sceneID, frameID, v.FileName); err != nil && !isLikelyNotExist(err) {
+					utils.GetLogger().Warn("delete frame version failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "version": v.Version, "err": err})
+					kept = append(kept, v)
+					continue
+				}
+			}
+			result.Deleted = append(result.Deleted, models.ComicFrameVersionGCEntry{FrameID: frameID, Version: v.Version, SizeBytes: v.SizeBytes})
+			result.FreedBytes += v.SizeBytes
+		}
+		if policy.DryRun {
+			continue
+		}
+		idx.Versions = kept
+		idx.UpdatedAt = time.Now()
+		if err := s.Repo.SaveFrameImageVersions(sceneID, frameID, idx); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// selectFrameVersionsForGC 返回可回收的版本号集合：跳过当前版本、固定版本与最新的 keepLast 个版本，
+// cutoff 非零时只回收创建时间早于 cutoff 的版本。
+func selectFrameVersionsForGC(idx *models.ComicFrameImageVersions, keepLast int, cutoff time.Time) map[int]struct{} {
+	ordered := make([]models.ComicFrameImageVersion, len(idx.Versions))
+	copy(ordered, idx.Versions)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Version > ordered[j].Version })
+
+	out := make(map[int]struct{})
+	for i, v := range ordered {
+		if i < keepLast || v.Version == idx.Current || v.Pinned {
+			continue
+		}
+		if !cutoff.IsZero() && !v.CreatedAt.Before(cutoff) {
+			continue
+		}
+		out[v.Version] = struct{}{}
+	}
+	return out
+}
+
+// snapshotFrameVersion 在整帧重绘覆盖 images/<frame_id>.png 之前，把尚未记录的当前图片补记为版本
+func (s *ComicService) snapshotFrameVersion(sceneID string, frameID string) {
+	if s == nil || s.Repo == nil {
+		return
+	}
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	if _, err := s.loadFrameVersionsSynced(sceneID, frameID); err != nil && !isLikelyStorageNotFound(err) {
+		utils.GetLogger().Warn("snapshot frame version failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "err": err})
+	}
+}
+
+// recordGeneratedFrameVersion 把刚由 GenerateAndSaveFrame 写入的图片记为新的当前版本
+func (s *ComicService) recordGeneratedFrameVersion(sceneID string, frameID string, img *VisionImage, source string, promptText string, opts VisionGenerateOptions, sig *models.ComicFrameImageSignature) {
+	if img == nil || len(img.Data) == 0 {
+		return
+	}
+	entry := models.ComicFrameImageVersion{
+		Source:         source,
+		Prompt:         promptText,
+		NegativePrompt: opts.NegativePrompt,
+		Model:          strings.TrimSpace(opts.Model),
+		Seed:           opts.Seed,
+		Width:          opts.Width,
+		Height:         opts.Height,
+		Signature:      sig,
+	}
+	if entry.Model == "" && sig != nil {
+		entry.Model = sig.Model
+	}
+	if _, err := s.recordFrameVersion(sceneID, frameID, img.Data, entry, false); err != nil {
+		utils.GetLogger().Warn("record frame version failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "err": err})
+	}
+}
+
+// recordFrameVersion 保存新版本并设为当前版本。
+// writeImage=true 时同时写入 images/<frame_id>.png，并先把之前未记录的图片补记为版本；
+// writeImage=false 用于图片已由生成流程写入的情况。
+func (s *ComicService) recordFrameVersion(sceneID string, frameID string, data []byte, entry models.ComicFrameImageVersion, writeImage bool) (*models.ComicFrameImageVersions, error) {
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+
+	var idx *models.ComicFrameImageVersions
+	var err error
+	if writeImage {
+		idx, err = s.loadFrameVersionsSynced(sceneID, frameID)
+	} else {
+		idx, err = s.Repo.LoadFrameImageVersions(sceneID, frameID)
+	}
+	if err != nil && !isLikelyStorageNotFound(err) {
+		return nil, err
+	}
+	if idx == nil {
+		idx = &models.ComicFrameImageVersions{SceneID: sceneID, FrameID: frameID}
+	}
+	version, err := s.appendFrameVersion(idx, data, entry)
+	if err != nil {
+		return nil, err
+	}
+	if writeImage {
+		if _, err := s.Repo.SaveFrameImage(sceneID, frameID, data); err != nil {
+			return nil, err
+		}
+	}
+	idx.Current = version
+	idx.UpdatedAt = time.Now()
+	if err := s.Repo.SaveFrameImageVersions(sceneID, frameID, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// activateFrameVersion 把版本图片复制为 images/<frame_id>.png，并恢复该版本的渲染签名。
+// 调用方需持有 versionsMu 并负责保存版本列表。
+func (s *ComicService) activateFrameVersion(idx *models.ComicFrameImageVersions, v *models.ComicFrameImageVersion) error {
+	data, err := s.Repo.LoadFrameImageVersion(idx.SceneID, idx.FrameID, v.FileName)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Repo.SaveFrameImage(idx.SceneID, idx.FrameID, data); err != nil {
+		return err
+	}
+	if v.Signature != nil {
+		if err := s.Repo.SaveFrameImageSignature(idx.SceneID, idx.FrameID, v.Signature); err != nil {
+			utils.GetLogger().Warn("restore frame image signature failed", map[string]interface{}{"scene_id": idx.SceneID, "frame_id": idx.FrameID, "version": v.Version, "err": err})
+		}
+	}
+	idx.Current = v.Version
+	idx.UpdatedAt = time.Now()
+	return nil
+}
+
+// loadFrameVersionsSynced 读取版本列表，并把版本记录之外的当前图片（首次编辑前的原图，
+// 或版本功能之前生成的图片）补记为一个版本，保证撤销时不会丢失。
+// 调用方需持有 versionsMu。
+func (s *ComicService) loadFrameVersionsSynced(sceneID string, frameID string) (*models.ComicFrameImageVersions, error) {
+	idx, err := s.Repo.LoadFrameImageVersions(sceneID, frameID)
+	if err != nil && !isLikelyStorageNotFound(err) {
+		return nil, err
+	}
+	current, curErr := s.Repo.LoadFrameImage(sceneID, frameID)
+	if curErr != nil && !isLikelyStorageNotFound(curErr) {
+		return nil, curErr
+	}
+	if idx == nil {
+		if len(current) == 0 {
+			if curErr != nil {
+				return nil, curErr
+			}
+			return nil, err
+		}
+		idx = &models.ComicFrameImageVersions{SceneID: sceneID, FrameID: frameID}
+	}
+	if len(current) == 0 {
+		return idx, nil
+	}
+	if v := findFrameVersion(idx, idx.Current); v != nil {
+		if stored, err := s.Repo.LoadFrameImageVersion(sceneID, frameID, v.FileName); err == nil && bytes.Equal(stored, current) {
+			return idx, nil
+		}
+	}
+	entry := models.ComicFrameImageVersion{Source: models.ComicFrameVersionSourceOriginal}
+	if sig, err := s.Repo.LoadFrameImageSignature(sceneID, frameID); err == nil && sig != nil {
+		entry.Prompt = sig.Prompt
+		entry.Model = sig.Model
+		entry.Signature = sig
+	}
+	version, err := s.appendFrameVersion(idx, current, entry)
+	if err != nil {
+		return nil, err
+	}
+	idx.Current = version
+	idx.UpdatedAt = time.Now()
+	if err := s.Repo.SaveFrameImageVersions(sceneID, frameID, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// appendFrameVersion 以下一个版本号保存图片文件，并把 entry 追加到版本列表
+func (s *ComicService) appendFrameVersion(idx *models.ComicFrameImageVersions, data []byte, entry models.ComicFrameImageVersion) (int, error) {
+	next := 1
+	for _, v := range idx.Versions {
+		if v.Version >= next {
+			next = v.Version + 1
+		}
+	}
+	fileName, err := s.Repo.SaveFrameImageVersion(idx.SceneID, idx.FrameID, next, data)
+	if err != nil {
+		return 0, err
+	}
+	entry.Version = next
+	entry.FileName = fileName
+	entry.Pinned = false
+	entry.SizeBytes = int64(len(data))
+	entry.CreatedAt = time.Now()
+	if entry.Width == 0 || entry.Height == 0 {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			entry.Width, entry.Height = cfg.Width, cfg.Height
+		}
+	}
+	idx.Versions = append(idx.Versions, entry)
+	return next, nil
+}
+
+func findFrameVersion(idx *models.ComicFrameImageVersions, version int) *models.ComicFrameImageVersion {
+	if idx == nil {
+		return nil
+	}
+	for i := range idx.Versions {
+		if idx.Versions[i].Version == version {
+			return &idx.Versions[i]
+		}
+	}
+	return nil
+}
+
+// diffFrameVersionFields 列出两个版本之间不同的生成参数
+func diffFrameVersionFields(a *models.ComicFrameImageVersion, b *models.ComicFrameImageVersion) []string {
+	changed := make([]string, 0, 8)
+	if a.Source != b.Source {
+		changed = append(changed, "source")
+	}
+	if a.Prompt != b.Prompt {
+		changed = append(changed, "prompt")
+	}
+	if a.NegativePrompt != b.NegativePrompt {
+		changed = append(changed, "negative_prompt")
+	}
+	if a.Model != b.Model {
+		changed = append(changed, "model")
+	}
+	if a.Seed != b.Seed {
+		changed = append(changed, "seed")
+	}
+	if a.Width != b.Width || a.Height != b.Height {
+		changed = append(changed, "size")
+	}
+	sigA, sigB := "", ""
+	styleA, styleB := "", ""
+	if a.Signature != nil {
+		sigA, styleA = a.Signature.Signature, a.Signature.Style
+	}
+	if b.Signature != nil {
+		sigB, styleB = b.Signature.Signature, b.Signature.Style
+	}
+	if styleA != styleB {
+		changed = append(changed, "style")
+	}
+	if sigA != sigB {
+		changed = append(changed, "signature")
+	}
+	return changed
+}
+
+// diffFrameVersionPixels 计算两张图片的平均绝对差与变化像素占比；尺寸不同时只返回 SameSize=false
+func diffFrameVersionPixels(a []byte, b []byte) (*models.ComicFrameVersionPixelDiff, error) {
+	imgA, _, err := image.Decode(bytes.NewReader(a))
+	if err != nil {
+		return nil, fmt.Errorf("decode version image: %w", err)
+	}
+	imgB, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decode version image: %w", err)
+	}
+	ba, bb := imgA.Bounds(), imgB.Bounds()
+	if ba.Dx() != bb.Dx() || ba.Dy() != bb.Dy() {
+		return &models.ComicFrameVersionPixelDiff{SameSize: false}, nil
+	}
+	total := ba.Dx() * ba.Dy()
+	if total == 0 {
+		return &models.ComicFrameVersionPixelDiff{SameSize: true}, nil
+	}
+
+	var sum float64
+	changed := 0
+	for y := 0; y < ba.Dy(); y++ {
+		for x := 0; x < ba.Dx(); x++ {
+			r1, g1, b1, _ := imgA.At(ba.Min.X+x, ba.Min.Y+y).RGBA()
+			r2, g2, b2, _ := imgB.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			dr, dg, db := absDiff8(r1, r2), absDiff8(g1, g2), absDiff8(b1, b2)
+			sum += float64(dr + dg + db)
+			if dr > frameVersionPixelThreshold || dg > frameVersionPixelThreshold || db > frameVersionPixelThreshold {
+				changed++
+			}
+		}
+	}
+	return &models.ComicFrameVersionPixelDiff{
+		SameSize:     true,
+		MeanAbsDiff:  sum / float64(total*3*255),
+		ChangedRatio: float64(changed) / float64(total),
+	}, nil
+}
+
+// absDiff8 比较两个 16 位颜色分量并返回 8 位差值
+func absDiff8(a uint32, b uint32) int {
+	x, y := int(a>>8), int(b>>8)
+	if x > y {
+		return x - y
+	}
+	return y - x
+}
@@ -0,0 +1,514 @@
+// internal/services/comic_page_render.go
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+// comicCaptionPadding 文字与气泡/旁白框边缘之间的留白（像素）
+const comicCaptionPadding = 10
+
+// comicSystemFontPaths 未配置 COMIC_FONT_PATH 时依次尝试的常见中日韩字体
+var comicSystemFontPaths = []string{
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/wenquanyi/wqy-microhei/wqy-microhei.ttc",
+	"/System/Library/Fonts/PingFang.ttc",
+	"/System/Library/Fonts/STHeiti Medium.ttc",
+	`C:\Windows\Fonts\msyh.ttc`,
+	`C:\Windows\Fonts\simhei.ttf`,
+}
+
+var (
+	comicFontCacheMu sync.Mutex
+	comicFontCache   = make(map[string]*opentype.Font)
+	comicGoRegular   *opentype.Font
+)
+
+// comicFontSet is the primary font (configured or system CJK font) plus the bundled Go
+// font as fallback for glyphs the primary font lacks.
+type comicFontSet struct {
+	primary      *opentype.Font
+	fallback     *opentype.Font
+	fallbackOnly bool
+}
+
+// loadComicFonts resolves the page font. Parsed fonts are cached per path.
+func loadComicFonts(configured string) (*comicFontSet, error) {
+	comicFontCacheMu.Lock()
+	defer comicFontCacheMu.Unlock()
+
+	if comicGoRegular == nil {
+		f, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			return nil, fmt.Errorf("parse bundled font: %w", err)
+		}
+		comicGoRegular = f
+	}
+	set := &comicFontSet{fallback: comicGoRegular}
+
+	candidates := comicSystemFontPaths
+	if strings.TrimSpace(configured) != "" {
+		candidates = []string{strings.TrimSpace(configured)}
+	}
+	for _, path := range candidates {
+		if f, ok := comicFontCache[path]; ok {
+			if f != nil {
+				set.primary = f
+				return set, nil
+			}
+			continue
+		}
+		f, err := parseComicFontFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				utils.GetLogger().Warn("load comic font failed", map[string]interface{}{"path": path, "err": err})
+			}
+			comicFontCache[path] = nil
+			continue
+		}
+		comicFontCache[path] = f
+		set.primary = f
+		return set, nil
+	}
+	set.primary = comicGoRegular
+	set.fallbackOnly = true
+	return set, nil
+}
+
+func parseComicFontFile(path string) (*opentype.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f, err := opentype.Parse(data); err == nil {
+		return f, nil
+	}
+	coll, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, err
+	}
+	if coll.NumFonts() == 0 {
+		return nil, fmt.Errorf("font collection %s is empty", path)
+	}
+	return coll.Font(0)
+}
+
+// comicFace measures and draws text with per-rune fallback between two faces.
+// Faces are not safe for concurrent use; create one per render.
+type comicFace struct {
+	primary  font.Face
+	fallback font.Face
+	em       fixed.Int26_6
+	missing  bool // a rune had no glyph in either face
+}
+
+func (s *comicFontSet) face(size float64) *comicFace {
+	opts := &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull}
+	primary, err := opentype.NewFace(s.primary, opts)
+	if err != nil {
+		primary, _ = opentype.NewFace(s.fallback, opts)
+	}
+	fallback, _ := opentype.NewFace(s.fallback, opts)
+	return &comicFace{primary: primary, fallback: fallback, em: fixed.Int26_6(size * 64)}
+}
+
+func (f *comicFace) pick(r rune) (font.Face, fixed.Int26_6) {
+	if adv, ok := f.primary.GlyphAdvance(r); ok {
+		return f.primary, adv
+	}
+	if adv, ok := f.fallback.GlyphAdvance(r); ok {
+		return f.fallback, adv
+	}
+	if unicode.IsSpace(r) {
+		adv, _ := f.primary.GlyphAdvance(r)
+		return f.primary, adv
+	}
+	// Reserve a full em so the layout stays usable once a font covering the text is configured.
+	f.missing = true
+	return f.primary, f.em
+}
+
+func (f *comicFace) lineHeight() int {
+	m := f.primary.Metrics()
+	h := (m.Ascent + m.Descent).Ceil()
+	return h + h/5
+}
+
+func (f *comicFace) measure(text string) int {
+	var w fixed.Int26_6
+	for _, r := range text {
+		_, adv := f.pick(r)
+		w += adv
+	}
+	return w.Ceil()
+}
+
+func (f *comicFace) maxLineWidth(lines []string) int {
+	w := 0
+	for _, l := range lines {
+		w = max(w, f.measure(l))
+	}
+	return w
+}
+
+// wrap breaks text into lines no wider than maxWidth. Latin words stay whole where
+// possible; CJK text may break between any two characters.
+func (f *comicFace) wrap(text string, maxWidth int) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n") {
+		var line strings.Builder
+		lineW := 0
+		flush := func() {
+			if s := strings.TrimSpace(line.String()); s != "" {
+				lines = append(lines, s)
+			}
+			line.Reset()
+			lineW = 0
+		}
+		for _, tok := range comicWrapTokens(para) {
+			isSpace := tok == " "
+			if isSpace && lineW == 0 {
+				continue
+			}
+			tokW := f.measure(tok)
+			switch {
+			case lineW+tokW <= maxWidth:
+				line.WriteString(tok)
+				lineW += tokW
+			case isSpace:
+				flush()
+			case comicNoLineStart(tok):
+				// Keep closing punctuation on the previous line even if it overhangs slightly.
+				line.WriteString(tok)
+				lineW += tokW
+			case tokW > maxWidth:
+				// A single over-long word: break it by rune.
+				for _, r := range tok {
+					rw := f.measure(string(r))
+					if lineW+rw > maxWidth && lineW > 0 {
+						flush()
+					}
+					line.WriteRune(r)
+					lineW += rw
+				}
+			default:
+				flush()
+				line.WriteString(tok)
+				lineW = tokW
+			}
+		}
+		flush()
+	}
+	return lines
+}
+
+// comicWrapTokens splits into breakable units: runs of Latin letters/digits, single spaces,
+// and single CJK characters or punctuation.
+func comicWrapTokens(text string) []string {
+	var tokens []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+			tokens = append(tokens, " ")
+		case r < 0x2E80 && !unicode.IsSpace(r):
+			word = append(word, r)
+		default:
+			flush()
+			tokens = append(tokens, string(r))
+		}
+	}
+	flush()
+	return tokens
+}
+
+func comicNoLineStart(tok string) bool {
+	return len([]rune(tok)) == 1 && strings.ContainsAny(tok, "，。、！？；：”’）》」』…,.!?;:")
+}
+
+// drawLine draws a single line with its baseline at y.
+func (f *comicFace) drawLine(dst draw.Image, text string, x, y int, col color.Color) {
+	src := image.NewUniform(col)
+	dot := fixed.P(x, y)
+	for _, r := range text {
+		face, adv := f.pick(r)
+		dr, mask, maskp, _, ok := face.Glyph(dot, r)
+		if ok {
+			draw.DrawMask(dst, dr, src, image.Point{}, mask, maskp, draw.Over)
+		}
+		dot.X += adv
+	}
+}
+
+// fitComicText shrinks the font until the wrapped text fits into w×h; lines that still
+// overflow at the minimum size are cut with an ellipsis.
+func fitComicText(fonts *comicFontSet, text string, size, w, h int) (*comicFace, []string) {
+	for ; size > minComicFontSize; size-- {
+		face := fonts.face(float64(size))
+		lines := face.wrap(text, w)
+		if len(lines)*face.lineHeight() <= h {
+			return face, lines
+		}
+	}
+	face := fonts.face(float64(minComicFontSize))
+	lines := face.wrap(text, w)
+	maxLines := max(1, h/face.lineHeight())
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		if len(last) > 1 {
+			last = last[:len(last)-1]
+		}
+		lines[maxLines-1] = string(last) + "…"
+	}
+	return face, lines
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+var (
+	comicInk          = color.RGBA{0x11, 0x11, 0x11, 0xff}
+	comicBalloonFill  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	comicCaptionFill  = color.RGBA{0xff, 0xf4, 0xc8, 0xff}
+	comicMissingPanel = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+)
+
+// renderComicPage draws one page: panels with their frame images, borders, then
+// captions and balloons on top. It returns the PNG bytes and human-readable warnings.
+func renderComicPage(layout *models.ComicPageLayout, page *models.ComicPage, fonts *comicFontSet, loadFrame func(frameID string) ([]byte, error)) ([]byte, []string, error) {
+	bg, err := parseHexColor(layout.Background)
+	if err != nil {
+		return nil, nil, err
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, layout.PageWidth, layout.PageHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	var warnings []string
+	labelFace := fonts.face(float64(layout.FontSize))
+	for _, panel := range page.Panels {
+		r := image.Rect(panel.Rect.X, panel.Rect.Y, panel.Rect.X+panel.Rect.W, panel.Rect.Y+panel.Rect.H)
+		data, err := loadFrame(panel.FrameID)
+		var src image.Image
+		if err == nil {
+			src, _, err = image.Decode(bytes.NewReader(data))
+		}
+		if err != nil || src == nil {
+			warnings = append(warnings, fmt.Sprintf("%s: frame %s has no image yet", page.ID, panel.FrameID))
+			draw.Draw(canvas, r, image.NewUniform(comicMissingPanel), image.Point{}, draw.Src)
+			w := labelFace.measure(panel.FrameID)
+			labelFace.drawLine(canvas, panel.FrameID, r.Min.X+(r.Dx()-w)/2, r.Min.Y+r.Dy()/2, comicInk)
+		} else {
+			drawComicPanelImage(canvas, r, src, panel.Fit, bg)
+		}
+		strokeComicRect(canvas, r, layout.BorderWidth, comicInk)
+	}
+
+	for _, panel := range page.Panels {
+		clip := canvas.SubImage(image.Rect(panel.Rect.X, panel.Rect.Y, panel.Rect.X+panel.Rect.W, panel.Rect.Y+panel.Rect.H)).(*image.RGBA)
+		for _, b := range panel.Balloons {
+			if strings.TrimSpace(b.Text) == "" || b.Rect.W <= 0 || b.Rect.H <= 0 {
+				continue
+			}
+			size := b.FontSize
+			if size == 0 {
+				size = layout.FontSize
+			}
+			if missing := drawComicBalloon(clip, panel.Rect, b, fonts, size); missing {
+				warnings = append(warnings, fmt.Sprintf("%s: some characters in %s are not covered by the page font; set COMIC_FONT_PATH to a CJK font", page.ID, b.ID))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), warnings, nil
+}
+
+// drawComicPanelImage scales src into r: cover crops to fill, contain letterboxes.
+func drawComicPanelImage(dst *image.RGBA, r image.Rectangle, src image.Image, fit string, bg color.Color) {
+	sb := src.Bounds()
+	if sb.Dx() == 0 || sb.Dy() == 0 {
+		return
+	}
+	if fit == "contain" {
+		draw.Draw(dst, r, image.NewUniform(bg), image.Point{}, draw.Src)
+		scale := math.Min(float64(r.Dx())/float64(sb.Dx()), float64(r.Dy())/float64(sb.Dy()))
+		w := int(float64(sb.Dx()) * scale)
+		h := int(float64(sb.Dy()) * scale)
+		target := image.Rect(0, 0, w, h).Add(r.Min).Add(image.Pt((r.Dx()-w)/2, (r.Dy()-h)/2))
+		draw.CatmullRom.Scale(dst, target, src, sb, draw.Src, nil)
+		return
+	}
+	// cover: crop the source to the panel aspect ratio around its centre
+	panelAspect := float64(r.Dx()) / float64(r.Dy())
+	srcAspect := float64(sb.Dx()) / float64(sb.Dy())
+	crop := sb
+	if srcAspect > panelAspect {
+		w := int(float64(sb.Dy()) * panelAspect)
+		crop.Min.X = sb.Min.X + (sb.Dx()-w)/2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := int(float64(sb.Dx()) / panelAspect)
+		crop.Min.Y = sb.Min.Y + (sb.Dy()-h)/2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	draw.CatmullRom.Scale(dst, r, src, crop, draw.Src, nil)
+}
+
+func strokeComicRect(dst *image.RGBA, r image.Rectangle, width int, col color.Color) {
+	if width <= 0 {
+		return
+	}
+	u := image.NewUniform(col)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), u, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+}
+
+// drawComicBalloon renders a caption box or a speech/thought balloon inside a panel.
+// dst is the panel sub-image; balloon geometry is panel-relative. It reports whether
+// any glyph was missing from the fonts.
+func drawComicBalloon(dst *image.RGBA, panel models.ComicRect, b models.ComicBalloon, fonts *comicFontSet, size int) bool {
+	ox, oy := float32(panel.X), float32(panel.Y)
+	x, y := ox+float32(b.Rect.X), oy+float32(b.Rect.Y)
+	w, h := float32(b.Rect.W), float32(b.Rect.H)
+	const stroke = 3
+
+	var textBox image.Rectangle
+	if b.Kind == models.ComicBalloonCaption {
+		outer := image.Rect(int(x), int(y), int(x+w), int(y+h))
+		draw.Draw(dst, outer, image.NewUniform(comicInk), image.Point{}, draw.Src)
+		draw.Draw(dst, outer.Inset(stroke), image.NewUniform(comicCaptionFill), image.Point{}, draw.Src)
+		textBox = outer.Inset(comicCaptionPadding)
+	} else {
+		cx, cy := x+w/2, y+h/2
+		if b.Tail != nil {
+			tx, ty := ox+float32(b.Tail.X), oy+float32(b.Tail.Y)
+			if b.Kind == models.ComicBalloonThought {
+				drawComicThoughtTrail(dst, cx, cy, w, h, tx, ty, stroke)
+			} else {
+				fillComicTail(dst, cx, cy, w, h, tx, ty, 0, comicInk)
+			}
+		}
+		fillComicEllipse(dst, cx, cy, w/2, h/2, comicInk)
+		if b.Tail != nil && b.Kind != models.ComicBalloonThought {
+			fillComicTail(dst, cx, cy, w, h, ox+float32(b.Tail.X), oy+float32(b.Tail.Y), stroke, comicBalloonFill)
+		}
+		fillComicEllipse(dst, cx, cy, w/2-stroke, h/2-stroke, comicBalloonFill)
+		// Largest axis-aligned box inside the ellipse is w/√2 × h/√2.
+		iw, ih := int(w/math.Sqrt2), int(h/math.Sqrt2)
+		textBox = image.Rect(int(cx)-iw/2, int(cy)-ih/2, int(cx)+iw/2, int(cy)+ih/2)
+	}
+
+	face, lines := fitComicText(fonts, b.Text, size, textBox.Dx(), textBox.Dy())
+	lh := face.lineHeight()
+	ascent := face.primary.Metrics().Ascent.Ceil()
+	top := textBox.Min.Y + (textBox.Dy()-len(lines)*lh)/2
+	for i, line := range lines {
+		lx := textBox.Min.X
+		if b.Kind != models.ComicBalloonCaption {
+			lx += (textBox.Dx() - face.measure(line)) / 2
+		}
+		face.drawLine(dst, line, lx, top+i*lh+ascent, comicInk)
+	}
+	return face.missing
+}
+
+// fillComicEllipse fills an anti-aliased ellipse using four cubic Bézier arcs.
+func fillComicEllipse(dst *image.RGBA, cx, cy, rx, ry float32, col color.Color) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	const k = 0.5522848
+	b := dst.Bounds()
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	cx -= float32(b.Min.X)
+	cy -= float32(b.Min.Y)
+	z.MoveTo(cx+rx, cy)
+	z.CubeTo(cx+rx, cy+k*ry, cx+k*rx, cy+ry, cx, cy+ry)
+	z.CubeTo(cx-k*rx, cy+ry, cx-rx, cy+k*ry, cx-rx, cy)
+	z.CubeTo(cx-rx, cy-k*ry, cx-k*rx, cy-ry, cx, cy-ry)
+	z.CubeTo(cx+k*rx, cy-ry, cx+rx, cy-k*ry, cx+rx, cy)
+	z.ClosePath()
+	z.Draw(dst, b, image.NewUniform(col), b.Min)
+}
+
+// fillComicTail fills the wedge from the balloon centre to the tail tip; inset shrinks
+// it so the inner (white) wedge leaves an outline.
+func fillComicTail(dst *image.RGBA, cx, cy, w, h, tx, ty, inset float32, col color.Color) {
+	dx, dy := tx-cx, ty-cy
+	dist := float32(math.Hypot(float64(dx), float64(dy)))
+	if dist < 1 {
+		return
+	}
+	ux, uy := dx/dist, dy/dist
+	half := float32(math.Min(float64(w), float64(h))) * 0.14
+	half -= inset * 1.5
+	if half <= 0 {
+		return
+	}
+	tipX, tipY := tx-ux*inset*2.5, ty-uy*inset*2.5
+	b := dst.Bounds()
+	ox, oy := float32(b.Min.X), float32(b.Min.Y)
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	z.MoveTo(cx-uy*half-ox, cy+ux*half-oy)
+	z.LineTo(tipX-ox, tipY-oy)
+	z.LineTo(cx+uy*half-ox, cy-ux*half-oy)
+	z.ClosePath()
+	z.Draw(dst, b, image.NewUniform(col), b.Min)
+}
+
+// drawComicThoughtTrail draws shrinking bubbles from the balloon edge toward the tail point.
+func drawComicThoughtTrail(dst *image.RGBA, cx, cy, w, h, tx, ty float32, stroke float32) {
+	base := float32(math.Min(float64(w), float64(h))) * 0.08
+	for i, t := range []float32{0.55, 0.75, 0.92} {
+		px, py := cx+(tx-cx)*t, cy+(ty-cy)*t
+		r := base * (1 - float32(i)*0.28)
+		fillComicEllipse(dst, px, py, r, r, comicInk)
+		fillComicEllipse(dst, px, py, r-stroke*0.7, r-stroke*0.7, comicBalloonFill)
+	}
+}
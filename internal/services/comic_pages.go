@@ -0,0 +1,728 @@
+// internal/services/comic_pages.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+// ErrInvalidComicPageLayout is returned when compose options or an edited layout cannot be rendered.
+var ErrInvalidComicPageLayout = errors.New("invalid comic page layout")
+
+// 页面排版默认值与取值范围（像素）
+const (
+	defaultComicPageWidth    = 1200
+	defaultComicPageHeight   = 1800
+	defaultComicPageMargin   = 60
+	defaultComicPageGutter   = 24
+	defaultComicBorderWidth  = 4
+	defaultComicFontSize     = 24
+	defaultComicPageTemplate = "grid_2x2"
+
+	minComicPageSide = 400
+	maxComicPageSide = 4000
+	minComicFontSize = 10
+	maxComicFontSize = 96
+
+	maxComicCaptionRunes     = 90
+	maxComicSpeechRunes      = 60
+	maxComicBalloonsPerPanel = 2
+)
+
+// ComicPageComposeOptions controls how frames are arranged into pages.
+// Templates is applied page by page; the last entry repeats for the remaining pages.
+type ComicPageComposeOptions struct {
+	Template   string   `json:"template,omitempty"`
+	Templates  []string `json:"templates,omitempty"`
+	PageWidth  int      `json:"page_width,omitempty"`
+	PageHeight int      `json:"page_height,omitempty"`
+	Margin     *int     `json:"margin,omitempty"`
+	Gutter     *int     `json:"gutter,omitempty"`
+	FontSize   int      `json:"font_size,omitempty"`
+	Background string   `json:"background,omitempty"`
+	// NoBalloons composes panels only (no captions or speech balloons).
+	NoBalloons bool `json:"no_balloons,omitempty"`
+}
+
+// comicTemplateRow is one tier of a page template; Columns are relative panel widths.
+type comicTemplateRow struct {
+	Weight  float64
+	Columns []float64
+}
+
+type comicPageTemplate struct {
+	ID   string
+	Name string
+	Rows []comicTemplateRow
+}
+
+func (t comicPageTemplate) panelCount() int {
+	n := 0
+	for _, row := range t.Rows {
+		n += len(row.Columns)
+	}
+	return n
+}
+
+// comicPageTemplates 内置的分格模板，按展示顺序排列
+var comicPageTemplates = []comicPageTemplate{
+	{ID: "splash", Name: "Splash page", Rows: []comicTemplateRow{{1, []float64{1}}}},
+	{ID: "two_tier", Name: "Two tiers", Rows: []comicTemplateRow{{1, []float64{1}}, {1, []float64{1}}}},
+	{ID: "three_tier", Name: "Three tiers", Rows: []comicTemplateRow{{1, []float64{1}}, {1, []float64{1}}, {1, []float64{1}}}},
+	{ID: "grid_2x2", Name: "2x2 grid", Rows: []comicTemplateRow{{1, []float64{1, 1}}, {1, []float64{1, 1}}}},
+	{ID: "grid_2x3", Name: "2x3 grid", Rows: []comicTemplateRow{{1, []float64{1, 1}}, {1, []float64{1, 1}}, {1, []float64{1, 1}}}},
+	{ID: "feature_top", Name: "Wide top, two below", Rows: []comicTemplateRow{{1.2, []float64{1}}, {1, []float64{1, 1}}}},
+	{ID: "feature_bottom", Name: "Two on top, wide below", Rows: []comicTemplateRow{{1, []float64{1, 1}}, {1.2, []float64{1}}}},
+	{ID: "staggered", Name: "Staggered tiers", Rows: []comicTemplateRow{{1, []float64{2, 1}}, {1, []float64{1}}, {1, []float64{1, 2}}}},
+}
+
+func findComicPageTemplate(id string) (comicPageTemplate, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	for _, t := range comicPageTemplates {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return comicPageTemplate{}, false
+}
+
+// ListComicPageTemplates returns the built-in panel grids.
+func ListComicPageTemplates() []models.ComicPageTemplateInfo {
+	out := make([]models.ComicPageTemplateInfo, 0, len(comicPageTemplates))
+	for _, t := range comicPageTemplates {
+		out = append(out, models.ComicPageTemplateInfo{ID: t.ID, Name: t.Name, Panels: t.panelCount()})
+	}
+	return out
+}
+
+// templatePanelRects splits the page content box into panel rects following the template.
+func templatePanelRects(t comicPageTemplate, width, height, margin, gutter int) []models.ComicRect {
+	contentW := width - 2*margin
+	contentH := height - 2*margin
+	rects := make([]models.ComicRect, 0, t.panelCount())
+
+	totalWeight := 0.0
+	for _, row := range t.Rows {
+		totalWeight += row.Weight
+	}
+	availH := contentH - gutter*(len(t.Rows)-1)
+	y := margin
+	for ri, row := range t.Rows {
+		h := int(float64(availH) * row.Weight / totalWeight)
+		if ri == len(t.Rows)-1 {
+			h = margin + contentH - y
+		}
+		colTotal := 0.0
+		for _, c := range row.Columns {
+			colTotal += c
+		}
+		availW := contentW - gutter*(len(row.Columns)-1)
+		x := margin
+		for ci, c := range row.Columns {
+			w := int(float64(availW) * c / colTotal)
+			if ci == len(row.Columns)-1 {
+				w = margin + contentW - x
+			}
+			rects = append(rects, models.ComicRect{X: x, Y: y, W: w, H: h})
+			x += w + gutter
+		}
+		y += h + gutter
+	}
+	return rects
+}
+
+// newComicPageLayout fills layout-level settings from compose options.
+func newComicPageLayout(sceneID string, opts ComicPageComposeOptions) (*models.ComicPageLayout, error) {
+	layout := &models.ComicPageLayout{
+		SceneID:     sceneID,
+		PageWidth:   opts.PageWidth,
+		PageHeight:  opts.PageHeight,
+		Margin:      -1,
+		Gutter:      -1,
+		BorderWidth: defaultComicBorderWidth,
+		FontSize:    opts.FontSize,
+		Background:  opts.Background,
+	}
+	if opts.Margin != nil {
+		layout.Margin = *opts.Margin
+	}
+	if opts.Gutter != nil {
+		layout.Gutter = *opts.Gutter
+	}
+	if err := normalizeComicPageSettings(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// normalizeComicPageSettings applies defaults and validates page-level settings.
+// Negative margin/gutter mean "use default".
+func normalizeComicPageSettings(layout *models.ComicPageLayout) error {
+	if layout.PageWidth == 0 {
+		layout.PageWidth = defaultComicPageWidth
+	}
+	if layout.PageHeight == 0 {
+		layout.PageHeight = defaultComicPageHeight
+	}
+	if layout.PageWidth < minComicPageSide || layout.PageWidth > maxComicPageSide ||
+		layout.PageHeight < minComicPageSide || layout.PageHeight > maxComicPageSide {
+		return fmt.Errorf("%w: page size must be between %d and %d pixels", ErrInvalidComicPageLayout, minComicPageSide, maxComicPageSide)
+	}
+	if layout.Margin < 0 {
+		layout.Margin = defaultComicPageMargin
+	}
+	if layout.Gutter < 0 {
+		layout.Gutter = defaultComicPageGutter
+	}
+	shortSide := layout.PageWidth
+	if layout.PageHeight < shortSide {
+		shortSide = layout.PageHeight
+	}
+	if layout.Margin*2+layout.Gutter*2 >= shortSide/2 {
+		return fmt.Errorf("%w: margin/gutter too large for the page", ErrInvalidComicPageLayout)
+	}
+	if layout.BorderWidth < 0 || layout.BorderWidth > 40 {
+		layout.BorderWidth = defaultComicBorderWidth
+	}
+	if layout.FontSize == 0 {
+		layout.FontSize = defaultComicFontSize
+	}
+	if layout.FontSize < minComicFontSize || layout.FontSize > maxComicFontSize {
+		return fmt.Errorf("%w: font_size must be between %d and %d", ErrInvalidComicPageLayout, minComicFontSize, maxComicFontSize)
+	}
+	if strings.TrimSpace(layout.Background) == "" {
+		layout.Background = "#FFFFFF"
+	}
+	if _, err := parseHexColor(layout.Background); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidComicPageLayout, err)
+	}
+	return nil
+}
+
+// sanitizeComicPageLayout validates an edited layout and clamps geometry into the page/panel bounds.
+// Page IDs are renumbered by position so files stay predictable.
+func sanitizeComicPageLayout(sceneID string, layout *models.ComicPageLayout) error {
+	if layout == nil {
+		return fmt.Errorf("%w: layout required", ErrInvalidComicPageLayout)
+	}
+	layout.SceneID = sceneID
+	if err := normalizeComicPageSettings(layout); err != nil {
+		return err
+	}
+	if len(layout.Pages) == 0 {
+		return fmt.Errorf("%w: at least one page required", ErrInvalidComicPageLayout)
+	}
+	pageRect := models.ComicRect{W: layout.PageWidth, H: layout.PageHeight}
+	for pi := range layout.Pages {
+		page := &layout.Pages[pi]
+		page.Index = pi + 1
+		page.ID = fmt.Sprintf("page_%02d", pi+1)
+		if strings.TrimSpace(page.Template) == "" {
+			page.Template = "custom"
+		}
+		for qi := range page.Panels {
+			panel := &page.Panels[qi]
+			if err := validatePathSegment(panel.FrameID); err != nil {
+				return fmt.Errorf("%w: page %d panel %d: invalid frame_id", ErrInvalidComicPageLayout, pi+1, qi+1)
+			}
+			panel.Rect = clampComicRect(panel.Rect, pageRect)
+			if panel.Rect.W < 16 || panel.Rect.H < 16 {
+				return fmt.Errorf("%w: page %d panel %s is too small", ErrInvalidComicPageLayout, pi+1, panel.FrameID)
+			}
+			switch panel.Fit {
+			case "", "cover", "contain":
+			default:
+				return fmt.Errorf("%w: unknown fit %q", ErrInvalidComicPageLayout, panel.Fit)
+			}
+			panelBox := models.ComicRect{W: panel.Rect.W, H: panel.Rect.H}
+			for bi := range panel.Balloons {
+				b := &panel.Balloons[bi]
+				b.Kind = strings.ToLower(strings.TrimSpace(b.Kind))
+				switch b.Kind {
+				case models.ComicBalloonSpeech, models.ComicBalloonThought, models.ComicBalloonCaption:
+				default:
+					return fmt.Errorf("%w: unknown balloon kind %q", ErrInvalidComicPageLayout, b.Kind)
+				}
+				if strings.TrimSpace(b.ID) == "" {
+					b.ID = fmt.Sprintf("%s_b%d", panel.FrameID, bi+1)
+				}
+				b.Rect = clampComicRect(b.Rect, panelBox)
+				if b.FontSize != 0 && (b.FontSize < minComicFontSize || b.FontSize > maxComicFontSize) {
+					b.FontSize = 0
+				}
+				if b.Kind == models.ComicBalloonCaption {
+					b.Tail = nil
+				} else if b.Tail != nil {
+					b.Tail.X = clampInt(b.Tail.X, 0, panelBox.W)
+					b.Tail.Y = clampInt(b.Tail.Y, 0, panelBox.H)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func clampComicRect(r models.ComicRect, bounds models.ComicRect) models.ComicRect {
+	r.X = clampInt(r.X, bounds.X, bounds.X+bounds.W)
+	r.Y = clampInt(r.Y, bounds.Y, bounds.Y+bounds.H)
+	r.W = clampInt(r.W, 0, bounds.X+bounds.W-r.X)
+	r.H = clampInt(r.H, 0, bounds.Y+bounds.H-r.Y)
+	return r
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// resolvePageTemplates picks the template for each page and returns frames grouped per page.
+func resolvePageTemplates(frames []models.ComicFramePlan, opts ComicPageComposeOptions) ([]comicPageTemplate, [][]models.ComicFramePlan, error) {
+	names := make([]string, 0, len(opts.Templates))
+	for _, n := range opts.Templates {
+		if strings.TrimSpace(n) != "" {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		name := opts.Template
+		if strings.TrimSpace(name) == "" {
+			name = defaultComicPageTemplate
+		}
+		names = []string{name}
+	}
+	resolved := make([]comicPageTemplate, 0, len(names))
+	for _, n := range names {
+		t, ok := findComicPageTemplate(n)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unknown template %q", ErrInvalidComicPageLayout, n)
+		}
+		resolved = append(resolved, t)
+	}
+
+	var templates []comicPageTemplate
+	var groups [][]models.ComicFramePlan
+	for i := 0; i < len(frames); {
+		t := resolved[len(resolved)-1]
+		if len(groups) < len(resolved) {
+			t = resolved[len(groups)]
+		}
+		end := i + t.panelCount()
+		if end > len(frames) {
+			end = len(frames)
+		}
+		templates = append(templates, t)
+		groups = append(groups, frames[i:end])
+		i = end
+	}
+	return templates, groups, nil
+}
+
+// ComposePages arranges the analysed frames into pages, fills captions/balloons from the
+// story text referenced by each frame's prompt sources, then renders and saves the pages.
+func (s *ComicService) ComposePages(sceneID string, opts ComicPageComposeOptions) (*models.ComicPageLayout, error) {
+	if s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	breakdown, err := s.Repo.LoadAnalysis(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	if breakdown == nil || len(breakdown.Frames) == 0 {
+		return nil, fmt.Errorf("%w: analysis has no frames", ErrInvalidComicPageLayout)
+	}
+	frames := append([]models.ComicFramePlan(nil), breakdown.Frames...)
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].Order < frames[j].Order })
+
+	layout, err := newComicPageLayout(sceneID, opts)
+	if err != nil {
+		return nil, err
+	}
+	templates, groups, err := resolvePageTemplates(frames, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := s.loadComicPageTextSources(sceneID)
+	fonts, err := loadComicFonts(s.FontPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for pi, group := range groups {
+		rects := templatePanelRects(templates[pi], layout.PageWidth, layout.PageHeight, layout.Margin, layout.Gutter)
+		page := models.ComicPage{
+			ID:       fmt.Sprintf("page_%02d", pi+1),
+			Index:    pi + 1,
+			Template: templates[pi].ID,
+			Panels:   make([]models.ComicPanel, 0, len(group)),
+		}
+		for fi, frame := range group {
+			panel := models.ComicPanel{FrameID: frame.ID, Rect: rects[fi], Fit: "cover"}
+			if !opts.NoBalloons {
+				lines := texts.frameLines(s.Repo, sceneID, frame)
+				panel.Balloons = placeComicBalloons(fonts, layout.FontSize, panel, lines)
+			}
+			page.Panels = append(page.Panels, panel)
+		}
+		layout.Pages = append(layout.Pages, page)
+	}
+	layout.GeneratedAt = time.Now()
+
+	return s.RenderPages(sceneID, layout)
+}
+
+func (s *ComicService) pageLock(sceneID string) *sync.Mutex {
+	value, _ := s.pageLocks.LoadOrStore(sceneID, &sync.Mutex{})
+	return value.(*sync.Mutex)
+}
+
+// RenderPages validates a (possibly edited) layout, renders every page to PNG and saves
+// the layout alongside. Page images that no longer belong to the layout are removed.
+func (s *ComicService) RenderPages(sceneID string, layout *models.ComicPageLayout) (*models.ComicPageLayout, error) {
+	if s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	if err := sanitizeComicPageLayout(sceneID, layout); err != nil {
+		return nil, err
+	}
+
+	lock := s.pageLock(sceneID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	fonts, err := loadComicFonts(s.FontPath)
+	if err != nil {
+		return nil, err
+	}
+	warnings := make([]string, 0)
+	seen := make(map[string]bool)
+	addWarning := func(msg string) {
+		if !seen[msg] {
+			seen[msg] = true
+			warnings = append(warnings, msg)
+		}
+	}
+	if fonts.fallbackOnly && s.FontPath != "" {
+		addWarning(fmt.Sprintf("font %s could not be loaded; using the built-in Latin font", s.FontPath))
+	}
+
+	keep := make(map[string]bool, len(layout.Pages))
+	now := time.Now()
+	for i := range layout.Pages {
+		page := &layout.Pages[i]
+		pngBytes, pageWarnings, err := renderComicPage(layout, page, fonts, func(frameID string) ([]byte, error) {
+			return s.Repo.LoadFrameImage(sceneID, frameID)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("render %s: %w", page.ID, err)
+		}
+		for _, w := range pageWarnings {
+			addWarning(w)
+		}
+		rel, err := s.Repo.SavePageImage(sceneID, page.ID, pngBytes)
+		if err != nil {
+			return nil, err
+		}
+		page.ImagePath = rel
+		page.RenderedAt = now
+		keep[page.ID] = true
+	}
+	if err := s.Repo.PrunePageImages(sceneID, keep); err != nil {
+		utils.GetLogger().Warn("prune comic page images failed", map[string]interface{}{"scene_id": sceneID, "err": err})
+	}
+
+	if layout.GeneratedAt.IsZero() {
+		layout.GeneratedAt = now
+	}
+	layout.UpdatedAt = now
+	layout.Warnings = warnings
+	if err := s.Repo.SavePageLayout(sceneID, layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// RerenderPages renders the stored layout again, e.g. after frames were regenerated.
+func (s *ComicService) RerenderPages(sceneID string) (*models.ComicPageLayout, error) {
+	if s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	layout, err := s.Repo.LoadPageLayout(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	return s.RenderPages(sceneID, layout)
+}
+
+// comicTextLine is one piece of text destined for a caption or balloon.
+type comicTextLine struct {
+	Kind           string
+	Speaker        string
+	Text           string
+	NodeID         string
+	ConversationID string
+}
+
+// comicPageTextSources holds the scene text used to fill balloons.
+type comicPageTextSources struct {
+	conversations map[string]models.Conversation
+	ordered       []models.Conversation
+	nodes         map[string]*models.StoryNode
+	characters    map[string]string // characterID -> name
+}
+
+func (s *ComicService) loadComicPageTextSources(sceneID string) *comicPageTextSources {
+	src := &comicPageTextSources{
+		conversations: make(map[string]models.Conversation),
+		nodes:         make(map[string]*models.StoryNode),
+		characters:    make(map[string]string),
+	}
+	if s.Scene != nil {
+		if sceneData, err := s.Scene.LoadScene(sceneID); err == nil && sceneData != nil {
+			src.ordered = sceneData.Context.Conversations
+			for _, conv := range sceneData.Context.Conversations {
+				if id := strings.TrimSpace(conv.ID); id != "" {
+					src.conversations[id] = conv
+				}
+			}
+			for _, ch := range sceneData.Characters {
+				if ch != nil {
+					src.characters[ch.ID] = ch.Name
+				}
+			}
+		} else if err != nil {
+			utils.GetLogger().Warn("load scene for comic pages failed", map[string]interface{}{"scene_id": sceneID, "err": err})
+		}
+	}
+	if s.Story != nil {
+		if story, err := s.Story.LoadStorySnapshot(sceneID); err == nil && story != nil {
+			for i := range story.Nodes {
+				src.nodes[story.Nodes[i].ID] = &story.Nodes[i]
+			}
+		}
+	}
+	return src
+}
+
+// frameLines collects caption and dialogue text for a frame. Sources follow the frame's
+// ComicPromptSources (conversation IDs, then node IDs) and fall back to the frame plan.
+func (src *comicPageTextSources) frameLines(repo *ComicRepository, sceneID string, frame models.ComicFramePlan) []comicTextLine {
+	nodeIDs := frame.StoryNodeIDs
+	var convIDs []string
+	if fp, err := repo.LoadPrompt(sceneID, frame.ID); err == nil && fp != nil && fp.PromptSources != nil {
+		if len(fp.PromptSources.NodeIDs) > 0 {
+			nodeIDs = fp.PromptSources.NodeIDs
+		}
+		convIDs = fp.PromptSources.ConversationIDs
+	}
+
+	var narration []comicTextLine
+	for _, id := range convIDs {
+		conv, ok := src.conversations[id]
+		if !ok {
+			continue
+		}
+		if text := strings.TrimSpace(conv.Content); text != "" {
+			narration = append(narration, comicTextLine{Text: text, NodeID: resolveConversationNodeIDForComic(conv), ConversationID: conv.ID})
+		}
+	}
+	if len(narration) == 0 {
+		for _, id := range nodeIDs {
+			node, ok := src.nodes[id]
+			if !ok {
+				continue
+			}
+			text := strings.TrimSpace(node.Content)
+			if text == "" {
+				text = strings.TrimSpace(node.OriginalContent)
+			}
+			if text != "" {
+				narration = append(narration, comicTextLine{Text: text, NodeID: id})
+			}
+		}
+	}
+
+	var caption *comicTextLine
+	var speech []comicTextLine
+	for _, n := range narration {
+		quotes, rest := splitComicQuotes(n.Text)
+		if caption == nil {
+			if c := firstComicSentences(rest, maxComicCaptionRunes); c != "" {
+				caption = &comicTextLine{Kind: models.ComicBalloonCaption, Text: c, NodeID: n.NodeID, ConversationID: n.ConversationID}
+			}
+		}
+		for _, q := range quotes {
+			speech = append(speech, comicTextLine{Kind: models.ComicBalloonSpeech, Text: q, NodeID: n.NodeID, ConversationID: n.ConversationID})
+		}
+	}
+
+	// Character lines recorded against the same story nodes take precedence over anonymous quotes.
+	nodeSet := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		nodeSet[id] = true
+	}
+	var spoken []comicTextLine
+	for _, conv := range src.ordered {
+		name, isCharacter := src.characters[conv.SpeakerID]
+		if !isCharacter || isComicStoryConversation(conv) {
+			continue
+		}
+		nodeID := resolveConversationNodeIDForComic(conv)
+		if nodeID == "" || !nodeSet[nodeID] {
+			continue
+		}
+		text := strings.TrimSpace(conv.Content)
+		if text == "" {
+			text = strings.TrimSpace(conv.Message)
+		}
+		if text != "" {
+			spoken = append(spoken, comicTextLine{Kind: models.ComicBalloonSpeech, Speaker: name, Text: text, NodeID: nodeID, ConversationID: conv.ID})
+		}
+	}
+	speech = append(spoken, speech...)
+
+	lines := make([]comicTextLine, 0, 1+maxComicBalloonsPerPanel)
+	if caption != nil {
+		lines = append(lines, *caption)
+	}
+	for i := 0; i < len(speech) && i < maxComicBalloonsPerPanel; i++ {
+		speech[i].Text = truncateComicText(speech[i].Text, maxComicSpeechRunes)
+		lines = append(lines, speech[i])
+	}
+	if len(lines) == 0 && strings.TrimSpace(frame.Description) != "" {
+		lines = append(lines, comicTextLine{Kind: models.ComicBalloonCaption, Text: firstComicSentences(frame.Description, maxComicCaptionRunes)})
+	}
+	return lines
+}
+
+var comicQuotePattern = regexp.MustCompile(`“([^”]+)”|"([^"]+)"|「([^」]+)」|『([^』]+)』`)
+
+// splitComicQuotes separates quoted dialogue from the surrounding narration.
+func splitComicQuotes(text string) (quotes []string, narration string) {
+	for _, m := range comicQuotePattern.FindAllStringSubmatch(text, -1) {
+		for _, g := range m[1:] {
+			if q := strings.TrimSpace(g); q != "" {
+				quotes = append(quotes, q)
+				break
+			}
+		}
+	}
+	narration = comicQuotePattern.ReplaceAllString(text, "")
+	return quotes, strings.Join(strings.Fields(narration), " ")
+}
+
+// firstComicSentences returns whole leading sentences up to maxRunes; a single long
+// sentence is truncated instead.
+func firstComicSentences(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return ""
+	}
+	var out []rune
+	var sentence []rune
+	for _, r := range text {
+		sentence = append(sentence, r)
+		if strings.ContainsRune("。！？!?.…", r) {
+			if len(out)+len(sentence) > maxRunes {
+				break
+			}
+			out = append(out, sentence...)
+			sentence = sentence[:0]
+		}
+	}
+	if len(out) == 0 {
+		return truncateComicText(text, maxRunes)
+	}
+	if len(sentence) > 0 && len(out)+len(sentence) <= maxRunes {
+		out = append(out, sentence...)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func truncateComicText(text string, maxRunes int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= maxRunes {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:maxRunes-1])) + "…"
+}
+
+// placeComicBalloons lays out the caption along the top edge and speech balloons
+// alternating left/right below it, with tails pointing into the panel.
+func placeComicBalloons(fonts *comicFontSet, fontSize int, panel models.ComicPanel, lines []comicTextLine) []models.ComicBalloon {
+	if len(lines) == 0 {
+		return nil
+	}
+	face := fonts.face(float64(fontSize))
+	pad := panel.Rect.W / 40
+	if pad < 6 {
+		pad = 6
+	}
+	lineHeight := face.lineHeight()
+
+	balloons := make([]models.ComicBalloon, 0, len(lines))
+	y := pad
+	speechIndex := 0
+	for i, line := range lines {
+		b := models.ComicBalloon{
+			ID:                   fmt.Sprintf("%s_b%d", panel.FrameID, i+1),
+			Kind:                 line.Kind,
+			Speaker:              line.Speaker,
+			Text:                 line.Text,
+			SourceNodeID:         line.NodeID,
+			SourceConversationID: line.ConversationID,
+		}
+		if line.Kind == models.ComicBalloonCaption {
+			maxW := panel.Rect.W - 2*pad
+			wrapped := face.wrap(line.Text, maxW-2*comicCaptionPadding)
+			b.Rect = models.ComicRect{
+				X: pad,
+				Y: y,
+				W: min(maxW, face.maxLineWidth(wrapped)+2*comicCaptionPadding),
+				H: len(wrapped)*lineHeight + 2*comicCaptionPadding,
+			}
+		} else {
+			// Aim for a roughly 3:1 text block so the balloon stays round rather than a flat strip.
+			singleW := face.measure(line.Text)
+			maxTextW := int(math.Sqrt(float64(singleW*lineHeight) * 3))
+			maxTextW = max(min(maxTextW, panel.Rect.W*2/5), min(lineHeight*4, panel.Rect.W*2/5))
+			wrapped := face.wrap(line.Text, maxTextW)
+			textW := face.maxLineWidth(wrapped)
+			textH := len(wrapped) * lineHeight
+			// The text box is inscribed in the ellipse: scale by ~sqrt(2) plus a little air.
+			w := min(panel.Rect.W-2*pad, textW*3/2+2*comicCaptionPadding)
+			h := textH*3/2 + 2*comicCaptionPadding
+			x := pad
+			if speechIndex%2 == 1 {
+				x = panel.Rect.W - pad - w
+			}
+			b.Rect = models.ComicRect{X: x, Y: y, W: w, H: h}
+			tailX := x + w/2 + w/6
+			if speechIndex%2 == 1 {
+				tailX = x + w/2 - w/6
+			}
+			b.Tail = &models.ComicPoint{X: tailX, Y: min(y+h+panel.Rect.H/8, panel.Rect.H-pad)}
+			speechIndex++
+		}
+		if b.Rect.Y+b.Rect.H > panel.Rect.H-pad {
+			break
+		}
+		y = b.Rect.Y + b.Rect.H + pad/2
+		balloons = append(balloons, b)
+	}
+	return balloons
+}
@@ -0,0 +1,344 @@
+// internal/services/comic_pdf.go
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/image/draw"
+)
+
+// 印刷 PDF 默认值与取值范围
+const (
+	defaultComicPDFPageSize = "a4"
+	defaultComicPDFBleedMM  = 3.0
+	defaultComicPDFDPI      = 300
+
+	minComicPDFDPI     = 72
+	maxComicPDFDPI     = 600
+	maxComicPDFBleedMM = 10.0
+	minComicPDFSideMM  = 50.0
+	maxComicPDFSideMM  = 600.0
+	// 单页栅格上限，避免超大尺寸 + 高 DPI 耗尽内存
+	maxComicPDFPagePixels = 60_000_000
+
+	comicPDFPointsPerMM = 72.0 / 25.4
+)
+
+// comicPDFPageSizes 预置成品尺寸（毫米，宽 x 高）
+var comicPDFPageSizes = map[string][2]float64{
+	"a4":       {210, 297},
+	"a5":       {148, 210},
+	"b5":       {176, 250},
+	"letter":   {215.9, 279.4},
+	"comic":    {168.3, 260.4}, // 美漫单行本 6.625" x 10.25"
+	"tankobon": {128, 182},     // 日漫单行本（B6 变体）
+}
+
+// ComicPDFPageSizes 返回可用的预置纸张名称
+func ComicPDFPageSizes() []string {
+	out := make([]string, 0, len(comicPDFPageSizes))
+	for k := range comicPDFPageSizes {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ComicPDFOptions 控制印刷 PDF 的成品尺寸、出血与分辨率。
+// WidthMM/HeightMM 同时给出时覆盖 PageSize；BleedMM 为 nil 时使用默认出血。
+type ComicPDFOptions struct {
+	PageSize string
+	WidthMM  float64
+	HeightMM float64
+	BleedMM  *float64
+	DPI      int
+	Fit      string // contain（默认，留白）/ cover（裁切铺满到出血线）
+	Title    string
+}
+
+type comicPDFLayout struct {
+	trimW, trimH float64 // 成品尺寸（pt）
+	bleed        float64 // 出血（pt）
+	dpi          int
+	fit          string
+}
+
+func normalizeComicPDFOptions(opts ComicPDFOptions) (comicPDFLayout, error) {
+	var out comicPDFLayout
+	widthMM, heightMM := opts.WidthMM, opts.HeightMM
+	// NaN 与任何数比较都为 false，会绕过下面的范围检查，需单独拒绝
+	if math.IsNaN(widthMM) || math.IsNaN(heightMM) || math.IsInf(widthMM, 0) || math.IsInf(heightMM, 0) {
+		return out, fmt.Errorf("%w: width_mm/height_mm must be finite numbers", ErrInvalidComicExportOptions)
+	}
+	if widthMM > 0 || heightMM > 0 {
+		if widthMM < minComicPDFSideMM || widthMM > maxComicPDFSideMM || heightMM < minComicPDFSideMM || heightMM > maxComicPDFSideMM {
+			return out, fmt.Errorf("%w: width_mm/height_mm must both be within %.0f..%.0f", ErrInvalidComicExportOptions, minComicPDFSideMM, maxComicPDFSideMM)
+		}
+	} else {
+		name := strings.ToLower(strings.TrimSpace(opts.PageSize))
+		if name == "" {
+			name = defaultComicPDFPageSize
+		}
+		size, ok := comicPDFPageSizes[name]
+		if !ok {
+			return out, fmt.Errorf("%w: unknown page_size %q (supported: %s)", ErrInvalidComicExportOptions, opts.PageSize, strings.Join(ComicPDFPageSizes(), "/"))
+		}
+		widthMM, heightMM = size[0], size[1]
+	}
+
+	bleedMM := defaultComicPDFBleedMM
+	if opts.BleedMM != nil {
+		bleedMM = *opts.BleedMM
+	}
+	if bleedMM < 0 || bleedMM > maxComicPDFBleedMM || math.IsNaN(bleedMM) {
+		return out, fmt.Errorf("%w: bleed_mm must be within 0..%.0f", ErrInvalidComicExportOptions, maxComicPDFBleedMM)
+	}
+
+	dpi := opts.DPI
+	if dpi == 0 {
+		dpi = defaultComicPDFDPI
+	}
+	if dpi < minComicPDFDPI || dpi > maxComicPDFDPI {
+		return out, fmt.Errorf("%w: dpi must be within %d..%d", ErrInvalidComicExportOptions, minComicPDFDPI, maxComicPDFDPI)
+	}
+
+	fit := strings.ToLower(strings.TrimSpace(opts.Fit))
+	switch fit {
+	case "":
+		fit = "contain"
+	case "contain", "cover":
+	default:
+		return out, fmt.Errorf("%w: fit must be contain or cover", ErrInvalidComicExportOptions)
+	}
+
+	out = comicPDFLayout{
+		trimW: widthMM * comicPDFPointsPerMM,
+		trimH: heightMM * comicPDFPointsPerMM,
+		bleed: bleedMM * comicPDFPointsPerMM,
+		dpi:   dpi,
+		fit:   fit,
+	}
+	mediaPxW := (out.trimW + 2*out.bleed) / 72 * float64(dpi)
+	mediaPxH := (out.trimH + 2*out.bleed) / 72 * float64(dpi)
+	if mediaPxW*mediaPxH > maxComicPDFPagePixels {
+		return out, fmt.Errorf("%w: page too large at %d dpi", ErrInvalidComicExportOptions, dpi)
+	}
+	return out, nil
+}
+
+// ValidateComicPDFOptions 在读取图片前校验导出参数
+func ValidateComicPDFOptions(opts ComicPDFOptions) error {
+	_, err := normalizeComicPDFOptions(opts)
+	return err
+}
+
+// WriteComicPDF 写出每张图片一页的印刷 PDF（纯 Go 实现，无外部依赖）。
+// MediaBox/BleedBox 为成品尺寸加四边出血，TrimBox 为成品尺寸；
+// 图片按目标 DPI 重采样后以 FlateDecode 的 DeviceRGB 嵌入。
+func WriteComicPDF(w io.Writer, images []ComicExportImage, opts ComicPDFOptions) error {
+	if len(images) == 0 {
+		return ErrComicExportEmpty
+	}
+	layout, err := normalizeComicPDFOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	pw := &comicPDFWriter{w: bufio.NewWriter(w)}
+	// 对象编号：1 Catalog，2 Pages，3 Info，之后每页 Page/Contents/Image 各占一个
+	const firstPageObj = 4
+	n := len(images)
+	pw.offsets = make([]int64, firstPageObj+3*n)
+
+	pw.printf("%%PDF-1.4\n%%\xE2\xE3\xCF\xD3\n")
+
+	pw.beginObj(1)
+	pw.printf("<< /Type /Catalog /Pages 2 0 R >>\n")
+	pw.endObj()
+
+	kids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPageObj+3*i))
+	}
+	pw.beginObj(2)
+	pw.printf("<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), n)
+	pw.endObj()
+
+	title := strings.TrimSpace(opts.Title)
+	if title == "" {
+		title = "Comic"
+	}
+	pw.beginObj(3)
+	pw.printf("<< /Title %s /Producer %s /CreationDate (D:%s) >>\n",
+		comicPDFTextString(title), comicPDFTextString("SceneIntruderMCP"), time.Now().UTC().Format("20060102150405Z"))
+	pw.endObj()
+
+	mediaW := layout.trimW + 2*layout.bleed
+	mediaH := layout.trimH + 2*layout.bleed
+	for i, img := range images {
+		pageObj := firstPageObj + 3*i
+		contentObj, imageObj := pageObj+1, pageObj+2
+
+		src, _, err := image.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", img.Name, err)
+		}
+		placed, crop := comicPDFPlacement(src.Bounds(), layout)
+		pxW := max(1, int(math.Round(placed.w/72*float64(layout.dpi))))
+		pxH := max(1, int(math.Round(placed.h/72*float64(layout.dpi))))
+		dst := image.NewRGBA(image.Rect(0, 0, pxW, pxH))
+		// 透明区域按白纸处理
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+
+		pw.beginObj(pageObj)
+		pw.printf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /BleedBox [0 0 %s %s] /TrimBox [%s %s %s %s] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\n",
+			pdfNum(mediaW), pdfNum(mediaH), pdfNum(mediaW), pdfNum(mediaH),
+			pdfNum(layout.bleed), pdfNum(layout.bleed), pdfNum(mediaW-layout.bleed), pdfNum(mediaH-layout.bleed),
+			imageObj, contentObj)
+		pw.endObj()
+
+		content := fmt.Sprintf("q %s 0 0 %s %s %s cm /Im0 Do Q", pdfNum(placed.w), pdfNum(placed.h), pdfNum(placed.x), pdfNum(placed.y))
+		pw.beginObj(contentObj)
+		pw.printf("<< /Length %d >>\nstream\n%s\nendstream\n", len(content), content)
+		pw.endObj()
+
+		stream, err := comicPDFImageStream(dst)
+		if err != nil {
+			return err
+		}
+		pw.beginObj(imageObj)
+		pw.printf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n", pxW, pxH, len(stream))
+		pw.write(stream)
+		pw.printf("\nendstream\n")
+		pw.endObj()
+	}
+
+	xrefAt := pw.pos
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets))
+	for obj := 1; obj < len(pw.offsets); obj++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[obj])
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets), xrefAt)
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
+
+type comicPDFRect struct{ x, y, w, h float64 }
+
+// comicPDFPlacement 计算图片在页面上的位置（pt）与源图裁切区域。
+// contain 居中缩放到成品区域（TrimBox）内；cover 裁切源图铺满含出血的整页。
+func comicPDFPlacement(src image.Rectangle, layout comicPDFLayout) (comicPDFRect, image.Rectangle) {
+	sw, sh := float64(src.Dx()), float64(src.Dy())
+	if layout.fit == "cover" {
+		mediaW, mediaH := layout.trimW+2*layout.bleed, layout.trimH+2*layout.bleed
+		crop := src
+		if sw/sh > mediaW/mediaH {
+			cw := int(math.Round(sh * mediaW / mediaH))
+			off := (src.Dx() - cw) / 2
+			crop = image.Rect(src.Min.X+off, src.Min.Y, src.Min.X+off+cw, src.Max.Y)
+		} else {
+			ch := int(math.Round(sw * mediaH / mediaW))
+			off := (src.Dy() - ch) / 2
+			crop = image.Rect(src.Min.X, src.Min.Y+off, src.Max.X, src.Min.Y+off+ch)
+		}
+		return comicPDFRect{0, 0, mediaW, mediaH}, crop
+	}
+	scale := math.Min(layout.trimW/sw, layout.trimH/sh)
+	w, h := sw*scale, sh*scale
+	return comicPDFRect{layout.bleed + (layout.trimW-w)/2, layout.bleed + (layout.trimH-h)/2, w, h}, src
+}
+
+// comicPDFImageStream 把 RGBA 像素压缩为 FlateDecode 的 RGB 数据流
+func comicPDFImageStream(img *image.RGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	b := img.Bounds()
+	row := make([]byte, 3*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		off := img.PixOffset(b.Min.X, y)
+		for x := 0; x < b.Dx(); x++ {
+			copy(row[3*x:3*x+3], img.Pix[off+4*x:off+4*x+3])
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// comicPDFWriter 记录每个对象的字节偏移，用于生成 xref 表
+type comicPDFWriter struct {
+	w       *bufio.Writer
+	pos     int64
+	offsets []int64
+	err     error
+}
+
+func (p *comicPDFWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.pos += int64(n)
+	p.err = err
+}
+
+func (p *comicPDFWriter) printf(format string, args ...interface{}) {
+	p.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (p *comicPDFWriter) beginObj(num int) {
+	p.offsets[num] = p.pos
+	p.printf("%d 0 obj\n", num)
+}
+
+func (p *comicPDFWriter) endObj() {
+	p.printf("endobj\n")
+}
+
+func pdfNum(v float64) string {
+	s := fmt.Sprintf("%.3f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// comicPDFTextString 编码 PDF 文本字符串；非 ASCII 使用带 BOM 的 UTF-16BE 十六进制串
+func comicPDFTextString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r > 0x7E || r < 0x20 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
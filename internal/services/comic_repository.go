@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -80,7 +81,7 @@ func validatePathSegment(segment string) error {
 	return nil
 }
 
-// EnsureSceneLayout 确保 comics/scene_<id>/ 下的基础目录存在：prompts/images/references。
+// EnsureSceneLayout 确保 comics/scene_<id>/ 下的基础目录存在：prompts/images/references/pages。
 func (r *ComicRepository) EnsureSceneLayout(sceneID string) (string, error) {
 	sceneDir, err := r.sceneDir(sceneID)
 	if err != nil {
@@ -94,6 +95,7 @@ func (r *ComicRepository) EnsureSceneLayout(sceneID string) (string, error) {
 		filepath.Join(absSceneDir, "prompts"),
 		filepath.Join(absSceneDir, "images"),
 		filepath.Join(absSceneDir, "references"),
+		filepath.Join(absSceneDir, "pages"),
 	} {
 		if err := os.MkdirAll(p, 0755); err != nil {
 			return "", fmt.Errorf("create comics dir failed: %w", err)
@@ -376,6 +378,87 @@ func (r *ComicRepository) DeleteReferenceForElement(sceneID, elementID string) (
 	return idx, nil
 }
 
+// LoadCharacterSheets loads references/sheets/index.json. If it does not exist, it returns (nil, err).
+func (r *ComicRepository) LoadCharacterSheets(sceneID string) (*models.ComicCharacterSheetIndex, error) {
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	var out models.ComicCharacterSheetIndex
+	if err := r.FileStorage.LoadJSONFile(filepath.Join(sceneDir, "references", "sheets"), "index.json", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SaveCharacterSheets saves references/sheets/index.json.
+func (r *ComicRepository) SaveCharacterSheets(sceneID string, idx *models.ComicCharacterSheetIndex) error {
+	if idx == nil {
+		return errors.New("character sheet index required")
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return err
+	}
+	return r.FileStorage.SaveJSONFile(filepath.Join(sceneDir, "references", "sheets"), "index.json", idx)
+}
+
+// SaveCharacterSheetImage stores one model-sheet view as references/sheets/<elementID>_<view><ext>.
+func (r *ComicRepository) SaveCharacterSheetImage(sceneID, elementID, view, ext string, content []byte) (fileName string, err error) {
+	if err := validatePathSegment(elementID); err != nil {
+		return "", fmt.Errorf("invalid element id: %w", err)
+	}
+	if err := validatePathSegment(view); err != nil {
+		return "", fmt.Errorf("invalid sheet view: %w", err)
+	}
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext == "" || !strings.HasPrefix(ext, ".") {
+		return "", errors.New("invalid extension")
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return "", err
+	}
+	fileName = elementID + "_" + view + ext
+	if err := r.FileStorage.SaveTextFile(filepath.Join(sceneDir, "references", "sheets"), fileName, content); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// LoadCharacterSheetImage loads a model-sheet view from references/sheets/.
+func (r *ComicRepository) LoadCharacterSheetImage(sceneID, fileName string) ([]byte, error) {
+	if err := validatePathSegment(fileName); err != nil {
+		return nil, fmt.Errorf("invalid sheet filename: %w", err)
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileStorage.LoadTextFile(filepath.Join(sceneDir, "references", "sheets"), fileName)
+}
+
+// DeleteCharacterSheetImage deletes a model-sheet view file; a missing file is not an error.
+func (r *ComicRepository) DeleteCharacterSheetImage(sceneID, fileName string) error {
+	if err := validatePathSegment(fileName); err != nil {
+		return fmt.Errorf("invalid sheet filename: %w", err)
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return err
+	}
+	if err := r.FileStorage.DeleteFile(filepath.Join(sceneDir, "references", "sheets"), fileName); err != nil && !isLikelyNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // SaveFrameImage saves raw PNG bytes to comics/scene_<id>/images/<frameID>.png.
 func (r *ComicRepository) SaveFrameImage(sceneID string, frameID string, pngBytes []byte) (relativePath string, err error) {
 	if err := validatePathSegment(frameID); err != nil {
@@ -442,6 +525,131 @@ func (r *ComicRepository) LoadFrameImageSignature(sceneID string, frameID string
 	return &out, nil
 }
 
+// frameVersionsDir returns comics/scene_<id>/images/versions/<frameID>.
+func (r *ComicRepository) frameVersionsDir(sceneID string, frameID string) (string, error) {
+	if err := validatePathSegment(frameID); err != nil {
+		return "", fmt.Errorf("invalid frame id: %w", err)
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(sceneDir, "images", "versions", frameID), nil
+}
+
+// LoadFrameImageVersions loads images/versions/<frameID>/index.json. If it does not exist, it returns (nil, err).
+func (r *ComicRepository) LoadFrameImageVersions(sceneID string, frameID string) (*models.ComicFrameImageVersions, error) {
+	dir, err := r.frameVersionsDir(sceneID, frameID)
+	if err != nil {
+		return nil, err
+	}
+	var out models.ComicFrameImageVersions
+	if err := r.FileStorage.LoadJSONFile(dir, "index.json", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SaveFrameImageVersions saves images/versions/<frameID>/index.json.
+func (r *ComicRepository) SaveFrameImageVersions(sceneID string, frameID string, idx *models.ComicFrameImageVersions) error {
+	if idx == nil {
+		return errors.New("frame image versions required")
+	}
+	dir, err := r.frameVersionsDir(sceneID, frameID)
+	if err != nil {
+		return err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return err
+	}
+	return r.FileStorage.SaveJSONFile(dir, "index.json", idx)
+}
+
+// SaveFrameImageVersion stores a frame image revision as images/versions/<frameID>/v<NNNN>.png.
+func (r *ComicRepository) SaveFrameImageVersion(sceneID string, frameID string, version int, content []byte) (fileName string, err error) {
+	if version <= 0 {
+		return "", errors.New("invalid frame image version")
+	}
+	dir, err := r.frameVersionsDir(sceneID, frameID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return "", err
+	}
+	fileName = fmt.Sprintf("v%04d.png", version)
+	if err := r.FileStorage.SaveTextFile(dir, fileName, content); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// LoadFrameImageVersion loads a stored frame image revision.
+func (r *ComicRepository) LoadFrameImageVersion(sceneID string, frameID string, fileName string) ([]byte, error) {
+	if err := validatePathSegment(fileName); err != nil {
+		return nil, fmt.Errorf("invalid version filename: %w", err)
+	}
+	dir, err := r.frameVersionsDir(sceneID, frameID)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileStorage.LoadTextFile(dir, fileName)
+}
+
+// DeleteFrameImageVersion removes a stored frame image revision file.
+func (r *ComicRepository) DeleteFrameImageVersion(sceneID string, frameID string, fileName string) error {
+	if err := validatePathSegment(fileName); err != nil {
+		return fmt.Errorf("invalid version filename: %w", err)
+	}
+	dir, err := r.frameVersionsDir(sceneID, frameID)
+	if err != nil {
+		return err
+	}
+	return r.FileStorage.DeleteFile(dir, fileName)
+}
+
+// ListFrameVersionFrameIDs lists frame ids that have a version history under images/versions.
+func (r *ComicRepository) ListFrameVersionFrameIDs(sceneID string) ([]string, error) {
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := r.FileStorage.ListDirs(filepath.Join(sceneDir, "images", "versions"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// SaveSeedSettings saves the scene seed strategy to comics/scene_<id>/seed.json.
+func (r *ComicRepository) SaveSeedSettings(sceneID string, settings *models.ComicSeedSettings) error {
+	if settings == nil {
+		return errors.New("seed settings required")
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return err
+	}
+	return r.FileStorage.SaveJSONFile(sceneDir, "seed.json", settings)
+}
+
+// LoadSeedSettings loads comics/scene_<id>/seed.json. If it does not exist, it returns (nil, err).
+func (r *ComicRepository) LoadSeedSettings(sceneID string) (*models.ComicSeedSettings, error) {
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	var out models.ComicSeedSettings
+	if err := r.FileStorage.LoadJSONFile(sceneDir, "seed.json", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 func (r *ComicRepository) SaveMetrics(sceneID string, metrics *models.ComicMetrics) error {
 	if metrics == nil {
 		return errors.New("metrics required")
@@ -467,3 +675,139 @@ func (r *ComicRepository) LoadMetrics(sceneID string) (*models.ComicMetrics, err
 	}
 	return &out, nil
 }
+
+// SavePageLayout saves the editable page composition to comics/scene_<id>/pages/layout.json.
+func (r *ComicRepository) SavePageLayout(sceneID string, layout *models.ComicPageLayout) error {
+	if layout == nil {
+		return errors.New("page layout required")
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return err
+	}
+	return r.FileStorage.SaveJSONFile(filepath.Join(sceneDir, "pages"), "layout.json", layout)
+}
+
+// LoadPageLayout loads comics/scene_<id>/pages/layout.json.
+func (r *ComicRepository) LoadPageLayout(sceneID string) (*models.ComicPageLayout, error) {
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	var out models.ComicPageLayout
+	if err := r.FileStorage.LoadJSONFile(filepath.Join(sceneDir, "pages"), "layout.json", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SavePageImage saves a rendered page to comics/scene_<id>/pages/<pageID>.png.
+func (r *ComicRepository) SavePageImage(sceneID string, pageID string, pngBytes []byte) (relativePath string, err error) {
+	if err := validatePathSegment(pageID); err != nil {
+		return "", fmt.Errorf("invalid page id: %w", err)
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.EnsureSceneLayout(sceneID); err != nil {
+		return "", err
+	}
+	filename := pageID + ".png"
+	if err := r.FileStorage.SaveTextFile(filepath.Join(sceneDir, "pages"), filename, pngBytes); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(filepath.Join(sceneDir, "pages", filename)), nil
+}
+
+// LoadPageImage loads a rendered page from comics/scene_<id>/pages/<pageID>.png.
+func (r *ComicRepository) LoadPageImage(sceneID string, pageID string) ([]byte, error) {
+	if err := validatePathSegment(pageID); err != nil {
+		return nil, fmt.Errorf("invalid page id: %w", err)
+	}
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileStorage.LoadTextFile(filepath.Join(sceneDir, "pages"), pageID+".png")
+}
+
+// PrunePageImages deletes rendered page PNGs that are not in keep (e.g. after a layout shrinks).
+func (r *ComicRepository) PrunePageImages(sceneID string, keep map[string]bool) error {
+	sceneDir, err := r.sceneDir(sceneID)
+	if err != nil {
+		return err
+	}
+	pagesDir := filepath.Join(sceneDir, "pages")
+	files, err := r.FileStorage.ListFiles(pagesDir)
+	if err != nil {
+		if isLikelyNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, name := range files {
+		if !strings.HasSuffix(strings.ToLower(name), ".png") {
+			continue
+		}
+		if keep[strings.TrimSuffix(name, filepath.Ext(name))] {
+			continue
+		}
+		if err := r.FileStorage.DeleteFile(pagesDir, name); err != nil && !isLikelyNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// comicCampaignsDir holds campaign records (comics/campaigns/<id>.json), next to the scene dirs.
+const comicCampaignsDir = "campaigns"
+
+// SaveCampaign saves comics/campaigns/<id>.json.
+func (r *ComicRepository) SaveCampaign(campaign *models.ComicCampaign) error {
+	if campaign == nil {
+		return errors.New("campaign required")
+	}
+	if err := validatePathSegment(campaign.ID); err != nil {
+		return fmt.Errorf("invalid campaign id: %w", err)
+	}
+	return r.FileStorage.SaveJSONFile(comicCampaignsDir, campaign.ID+".json", campaign)
+}
+
+// LoadCampaigns loads every campaign record; unreadable files are skipped.
+// A missing campaigns directory yields an empty list.
+func (r *ComicRepository) LoadCampaigns() ([]models.ComicCampaign, error) {
+	files, err := r.FileStorage.ListFiles(comicCampaignsDir)
+	if err != nil {
+		if isLikelyNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out := make([]models.ComicCampaign, 0, len(files))
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		var campaign models.ComicCampaign
+		if err := r.FileStorage.LoadJSONFile(comicCampaignsDir, name, &campaign); err != nil || campaign.ID == "" {
+			continue
+		}
+		out = append(out, campaign)
+	}
+	return out, nil
+}
+
+// DeleteCampaign removes comics/campaigns/<id>.json. Missing files are ignored.
+func (r *ComicRepository) DeleteCampaign(id string) error {
+	if err := validatePathSegment(id); err != nil {
+		return fmt.Errorf("invalid campaign id: %w", err)
+	}
+	if err := r.FileStorage.DeleteFile(comicCampaignsDir, id+".json"); err != nil && !isLikelyNotExist(err) {
+		return err
+	}
+	return nil
+}
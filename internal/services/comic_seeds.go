@@ -0,0 +1,345 @@
+// internal/services/comic_seeds.go
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+var (
+	// ErrInvalidSeedSettings is returned for an unknown strategy or an out-of-range base seed.
+	ErrInvalidSeedSettings = errors.New("invalid seed settings")
+	// ErrFrameNotReproducible is returned when a frame (version) has no recorded seed/prompt
+	// or one of its recorded reference images can no longer be found.
+	ErrFrameNotReproducible = errors.New("frame has no recorded render inputs to reproduce")
+)
+
+// ComicJobTypeReproduceFrame 按记录的参数复现单帧的任务
+const ComicJobTypeReproduceFrame = "comic_reproduce_frame"
+
+// frameSignatureTemplateHint 渲染签名的版本标记；v2 起签名记录了参考图哈希与去噪强度，可精确复现
+const frameSignatureTemplateHint = "resume_signature_v2"
+
+// maxComicSeed 种子取值上限；多数 SD 类后端只接受 32 位种子
+const maxComicSeed = math.MaxInt32
+
+// ComicReproduceOptions 复现参数。Version=0 时使用当前图片的签名；
+// Prompt 非空时只替换提示词，其余参数（模型、尺寸、种子等）保持不变，便于 A/B 对比。
+type ComicReproduceOptions struct {
+	Version int    `json:"version,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+}
+
+// GetSeedSettings 返回场景的种子策略；未设置时为 random
+func (s *ComicService) GetSeedSettings(sceneID string) (*models.ComicSeedSettings, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	settings, err := s.Repo.LoadSeedSettings(sceneID)
+	if err != nil {
+		if isLikelyStorageNotFound(err) {
+			return &models.ComicSeedSettings{SceneID: sceneID, Strategy: models.ComicSeedStrategyRandom}, nil
+		}
+		return nil, err
+	}
+	if strings.TrimSpace(settings.Strategy) == "" {
+		settings.Strategy = models.ComicSeedStrategyRandom
+	}
+	return settings, nil
+}
+
+// UpdateSeedSettings 保存场景的种子策略。fixed / per_frame 未指定 base_seed 时随机生成一个并记录下来。
+func (s *ComicService) UpdateSeedSettings(sceneID string, settings models.ComicSeedSettings) (*models.ComicSeedSettings, error) {
+	if s == nil || s.Repo == nil {
+		return nil, ErrComicRepositoryNotReady
+	}
+	strategy := strings.ToLower(strings.TrimSpace(settings.Strategy))
+	switch strategy {
+	case "":
+		strategy = models.ComicSeedStrategyRandom
+	case models.ComicSeedStrategyRandom, models.ComicSeedStrategyFixed, models.ComicSeedStrategyPerFrame:
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q (random / fixed / per_frame)", ErrInvalidSeedSettings, settings.Strategy)
+	}
+	if settings.BaseSeed < 0 || settings.BaseSeed > maxComicSeed {
+		return nil, fmt.Errorf("%w: base_seed must be within 0..%d", ErrInvalidSeedSettings, maxComicSeed)
+	}
+
+	out := &models.ComicSeedSettings{
+		SceneID:   sceneID,
+		Strategy:  strategy,
+		BaseSeed:  settings.BaseSeed,
+		UpdatedAt: time.Now(),
+	}
+	if strategy == models.ComicSeedStrategyRandom {
+		out.BaseSeed = 0
+	} else if out.BaseSeed == 0 {
+		out.BaseSeed = randomComicSeed()
+	}
+	if err := s.Repo.SaveSeedSettings(sceneID, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// loadSeedSettingsBestEffort 读取种子策略，失败时退回 random
+func (s *ComicService) loadSeedSettingsBestEffort(sceneID string) *models.ComicSeedSettings {
+	settings, err := s.GetSeedSettings(sceneID)
+	if err != nil {
+		utils.GetLogger().Warn("load seed settings failed", map[string]interface{}{"scene_id": sceneID, "err": err})
+		return &models.ComicSeedSettings{SceneID: sceneID, Strategy: models.ComicSeedStrategyRandom}
+	}
+	return settings
+}
+
+// resolveFrameSeed 按策略计算帧种子；model_params 中显式指定的正数种子优先。
+// random 为 true 表示种子是本次随机选取的，不能用于匹配录制的请求。
+func resolveFrameSeed(settings *models.ComicSeedSettings, frameID string, explicit int64) (seed int64, random bool) {
+	if explicit > 0 {
+		return explicit, false
+	}
+	if settings == nil || settings.BaseSeed <= 0 {
+		return randomComicSeed(), true
+	}
+	switch settings.Strategy {
+	case models.ComicSeedStrategyFixed:
+		return settings.BaseSeed, false
+	case models.ComicSeedStrategyPerFrame:
+		return derivePerFrameSeed(settings.BaseSeed, frameID), false
+	default:
+		return randomComicSeed(), true
+	}
+}
+
+// seedStrategyFor 返回写入签名的策略名；model_params 中的显式种子记为 explicit
+func seedStrategyFor(settings *models.ComicSeedSettings, explicit int64) string {
+	if explicit > 0 {
+		return models.ComicSeedStrategyExplicit
+	}
+	if settings == nil || strings.TrimSpace(settings.Strategy) == "" {
+		return models.ComicSeedStrategyRandom
+	}
+	return settings.Strategy
+}
+
+// derivePerFrameSeed 由基础种子与帧 ID 派生稳定的种子，与帧的顺序无关
+func derivePerFrameSeed(base int64, frameID string) int64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%d:%s", base, strings.TrimSpace(frameID))
+	return int64(h.Sum64()%uint64(maxComicSeed)) + 1
+}
+
+func randomComicSeed() int64 {
+	return rand.Int63n(maxComicSeed) + 1
+}
+
+// ReproduceFrameAsync 用记录的提示词、模型、尺寸与种子重新渲染某帧；结果保存为新版本。
+func (s *ComicService) ReproduceFrameAsync(ctx context.Context, sceneID string, frameID string, opts ComicReproduceOptions) (taskID string, err error) {
+	if err := s.ensureVisionReady(); err != nil {
+		return "", err
+	}
+	frameID = strings.TrimSpace(frameID)
+	if err := validatePathSegment(frameID); err != nil {
+		return "", fmt.Errorf("invalid frame id: %w", err)
+	}
+	if opts.Version < 0 {
+		return "", ErrFrameVersionNotFound
+	}
+
+	sig, err := s.loadReproducibleSignature(sceneID, frameID, opts.Version)
+	if err != nil {
+		return "", err
+	}
+
+	genOpts := VisionGenerateOptions{Width: sig.Width, Height: sig.Height, Model: sig.Model}
+	if genOpts.Width <= 0 || genOpts.Height <= 0 {
+		genOpts.Width, genOpts.Height = 512, 512
+	}
+	applyModelParams(&genOpts, sig.ModelParams)
+	genOpts.NegativePrompt = sig.NegativePrompt
+	genOpts.Seed = sig.Seed
+	// 参考图按记录的哈希找回，而不是按当前状态重新挑选
+	genOpts.ReferenceImage, genOpts.ReferenceImages, err = s.resolveRecordedReferences(sceneID, frameID, sig)
+	if err != nil {
+		return "", err
+	}
+	genOpts.DenoisingStrength = sig.DenoisingStrength
+
+	promptText := sig.Prompt
+	replayed := *sig
+	if p := strings.TrimSpace(opts.Prompt); p != "" && p != sig.Prompt {
+		promptText = p
+		replayed.Prompt = p
+		// 提示词已变化：清空签名哈希，续跑生成时不会把该图片视为与提示词文件一致
+		replayed.Signature = ""
+	}
+
+	taskID = fmt.Sprintf("comic_reproduce_%s_%s_%d", sceneID, frameID, time.Now().UnixNano())
+	tracker := s.Progress.CreateTracker(taskID)
+	tracker.UpdateProgress(1, "准备复现图片...")
+
+	err = s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeReproduceFrame, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		defer func() {
+			if r := recover(); r != nil {
+				utils.GetLogger().Error("comic reproduce panic", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "task_id": taskID, "panic": fmt.Sprintf("%v", r)})
+				tracker.Fail("任务异常崩溃")
+			}
+		}()
+
+		tracker.UpdateProgress(30, fmt.Sprintf("复现图片（seed=%d）...", genOpts.Seed))
+		s.snapshotFrameVersion(sceneID, frameID)
+		_, img, err := s.Vision.GenerateAndSaveFrame(jobCtx, sceneID, frameID, promptText, genOpts)
+		if err != nil {
+			failMsg := s.buildFrameVisionFailureMessage(frameID, genOpts, err)
+			tracker.EmitProgressEvent(30, failMsg, "frame_failed", frameID)
+			tracker.Fail(failMsg)
+			return err
+		}
+
+		replayed.GeneratedAt = time.Now()
+		if err := s.Repo.SaveFrameImageSignature(sceneID, frameID, &replayed); err != nil {
+			utils.GetLogger().Warn("save frame image signature failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "err": err})
+		}
+		s.recordGeneratedFrameVersion(sceneID, frameID, img, models.ComicFrameVersionSourceReproduce, promptText, genOpts, &replayed)
+		tracker.EmitProgressEvent(95, fmt.Sprintf("图片已写入：%s", frameID), "image_written", frameID)
+		tracker.Complete("复现完成")
+		return nil
+	})
+	if err != nil {
+		tracker.Fail("任务提交失败")
+		return "", err
+	}
+	return taskID, nil
+}
+
+// loadReproducibleSignature 读取当前图片（version=0）或指定版本的渲染签名，并检查其可复现
+func (s *ComicService) loadReproducibleSignature(sceneID string, frameID string, version int) (*models.ComicFrameImageSignature, error) {
+	var sig *models.ComicFrameImageSignature
+	if version > 0 {
+		s.versionsMu.Lock()
+		idx, err := s.Repo.LoadFrameImageVersions(sceneID, frameID)
+		s.versionsMu.Unlock()
+		if err != nil {
+			if isLikelyStorageNotFound(err) {
+				return nil, ErrFrameVersionNotFound
+			}
+			return nil, err
+		}
+		v := findFrameVersion(idx, version)
+		if v == nil {
+			return nil, ErrFrameVersionNotFound
+		}
+		sig = v.Signature
+	} else {
+		loaded, err := s.Repo.LoadFrameImageSignature(sceneID, frameID)
+		if err != nil && !isLikelyStorageNotFound(err) {
+			return nil, err
+		}
+		sig = loaded
+	}
+	// 早于 v2 的签名没有记录参考图，无法保证输入一致
+	if sig == nil || sig.Seed <= 0 || strings.TrimSpace(sig.Prompt) == "" || sig.TemplateHint != frameSignatureTemplateHint {
+		return nil, ErrFrameNotReproducible
+	}
+	out := *sig
+	return &out, nil
+}
+
+// frameReferenceHash 参考图内容的 SHA-256（十六进制），空数据返回空串
+func frameReferenceHash(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveRecordedReferences 按签名记录的哈希找回渲染时使用的参考图。
+// 候选来源：上一帧的当前图片及其历史版本、元素参考图、角色设定图；
+// 任一哈希找不到对应图片（例如已被替换或回收）时返回 ErrFrameNotReproducible。
+func (s *ComicService) resolveRecordedReferences(sceneID string, frameID string, sig *models.ComicFrameImageSignature) ([]byte, [][]byte, error) {
+	found := make(map[string][]byte)
+	pending := 0
+	want := func(hash string) {
+		if _, ok := found[hash]; hash != "" && !ok {
+			found[hash] = nil
+			pending++
+		}
+	}
+	want(sig.ReferenceImage)
+	for _, hash := range sig.ReferenceImages {
+		want(hash)
+	}
+	// offer 核对一张候选图片，全部找到后返回 true
+	offer := func(data []byte) bool {
+		if hash := frameReferenceHash(data); hash != "" {
+			if got, ok := found[hash]; ok && got == nil {
+				found[hash] = data
+				pending--
+			}
+		}
+		return pending == 0
+	}
+	if pending == 0 {
+		return nil, nil, nil
+	}
+
+	prevFrameID := s.findPreviousFrameID(sceneID, frameID)
+	if prevFrameID != "" && offer(s.bestEffortLoadGeneratedFrameImage(sceneID, prevFrameID)) {
+		return collectRecordedReferences(sig, found)
+	}
+	if idx, err := s.Repo.LoadReferenceIndex(sceneID); err == nil && idx != nil {
+		for _, meta := range idx.References {
+			if strings.TrimSpace(meta.FileName) == "" {
+				continue
+			}
+			if data, err := s.Repo.LoadReference(sceneID, meta.FileName); err == nil && offer(data) {
+				return collectRecordedReferences(sig, found)
+			}
+		}
+	}
+	if sheets := s.bestEffortLoadCharacterSheets(sceneID); sheets != nil {
+		for _, sheet := range sheets.Sheets {
+			for _, view := range sheet.Views {
+				if data, err := s.Repo.LoadCharacterSheetImage(sceneID, view.FileName); err == nil && offer(data) {
+					return collectRecordedReferences(sig, found)
+				}
+			}
+		}
+	}
+	if prevFrameID != "" {
+		s.versionsMu.Lock()
+		versions, err := s.Repo.LoadFrameImageVersions(sceneID, prevFrameID)
+		s.versionsMu.Unlock()
+		if err == nil && versions != nil {
+			for _, v := range versions.Versions {
+				if data, err := s.Repo.LoadFrameImageVersion(sceneID, prevFrameID, v.FileName); err == nil && offer(data) {
+					return collectRecordedReferences(sig, found)
+				}
+			}
+		}
+	}
+	return nil, nil, ErrFrameNotReproducible
+}
+
+// collectRecordedReferences 按签名中的顺序组装已找回的参考图
+func collectRecordedReferences(sig *models.ComicFrameImageSignature, found map[string][]byte) ([]byte, [][]byte, error) {
+	var refs [][]byte
+	for _, hash := range sig.ReferenceImages {
+		if hash != "" {
+			refs = append(refs, found[hash])
+		}
+	}
+	return found[sig.ReferenceImage], refs, nil
+}
@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -42,7 +43,23 @@ type ComicPromptsBuildOptions struct {
 }
 
 type ComicGenerateOptions struct {
-	Resume bool
+	Resume bool `json:"resume,omitempty"`
+}
+
+// 漫画任务类型（JobQueue 按类型决定优先级与重试策略）
+const (
+	ComicJobTypeAnalyze         = "comic_analyze"
+	ComicJobTypePrompts         = "comic_prompts"
+	ComicJobTypeKeyElements     = "comic_key_elements"
+	ComicJobTypeGenerate        = "comic_generate"
+	ComicJobTypeRegenerateFrame = "comic_regenerate_frame"
+)
+
+// comicGenerateJobPayload 是图片生成任务写入任务日志的参数
+type comicGenerateJobPayload struct {
+	SceneID string               `json:"scene_id"`
+	FrameID string               `json:"frame_id,omitempty"`
+	Options ComicGenerateOptions `json:"options"`
 }
 
 // ComicService 是 v2 comics 领域服务的入口。
@@ -55,6 +72,18 @@ type ComicService struct {
 	Vision   *VisionService
 	Scene    *SceneService
 	Story    *StoryService
+
+	// FontPath 漫画页面排版使用的字体文件（TTF/OTF/TTC）；为空时尝试常见的系统中文字体
+	FontPath   string
+	pageLocks  sync.Map // sceneID -> *sync.Mutex，同一场景的页面渲染串行执行
+	sheetsMu   sync.Mutex
+	versionsMu sync.Mutex
+
+	// 批量漫画任务（campaign）：记录落盘于 comics/campaigns/，重启后由 RestoreComicCampaigns 恢复
+	campaignsMu sync.Mutex
+	campaigns   map[string]*comicCampaignRun
+	// campaignLimiter 所有批量任务共享的 vision provider 并发计数
+	campaignLimiter comicProviderLimiter
 }
 
 func NewComicService(
@@ -66,7 +95,7 @@ func NewComicService(
 	scene *SceneService,
 	story *StoryService,
 ) *ComicService {
-	return &ComicService{
+	s := &ComicService{
 		Repo:     repo,
 		JobQueue: jobQueue,
 		Progress: progress,
@@ -75,6 +104,50 @@ func NewComicService(
 		Scene:    scene,
 		Story:    story,
 	}
+	s.registerJobTypes()
+	return s
+}
+
+// registerJobTypes 登记漫画任务的优先级与重试策略；图片生成任务可在服务重启后恢复。
+func (s *ComicService) registerJobTypes() {
+	if s.JobQueue == nil {
+		return
+	}
+	llmRetry := RetryPolicy{MaxAttempts: 2, InitialBackoff: 5 * time.Second, MaxBackoff: 30 * time.Second}
+	visionRetry := RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Second, MaxBackoff: 2 * time.Minute}
+
+	s.JobQueue.RegisterJobType(ComicJobTypeAnalyze, JobTypePolicy{Priority: JobPriorityNormal, Retry: llmRetry}, nil)
+	s.JobQueue.RegisterJobType(ComicJobTypePrompts, JobTypePolicy{Priority: JobPriorityNormal, Retry: llmRetry}, nil)
+	s.JobQueue.RegisterJobType(ComicJobTypeKeyElements, JobTypePolicy{Priority: JobPriorityNormal, Retry: llmRetry}, nil)
+	s.JobQueue.RegisterJobType(ComicJobTypeGenerate, JobTypePolicy{Priority: JobPriorityBatch, Retry: visionRetry}, s.restoreGenerateJob)
+	s.JobQueue.RegisterJobType(ComicJobTypeRegenerateFrame, JobTypePolicy{Priority: JobPriorityInteractive, Retry: visionRetry}, s.restoreGenerateJob)
+	s.JobQueue.RegisterJobType(ComicJobTypeCharacterSheet, JobTypePolicy{Priority: JobPriorityInteractive, Retry: visionRetry}, nil)
+	s.JobQueue.RegisterJobType(ComicJobTypeInpaintFrame, JobTypePolicy{Priority: JobPriorityInteractive, Retry: visionRetry}, nil)
+	s.JobQueue.RegisterJobType(ComicJobTypeReproduceFrame, JobTypePolicy{Priority: JobPriorityInteractive, Retry: visionRetry}, nil)
+}
+
+// restoreGenerateJob 根据任务日志重新提交图片生成/单帧重绘任务
+func (s *ComicService) restoreGenerateJob(rec JobRecord) error {
+	if err := s.ensureVisionReady(); err != nil {
+		return err
+	}
+	var payload comicGenerateJobPayload
+	if err := json.Unmarshal(rec.Payload, &payload); err != nil {
+		return err
+	}
+	if strings.TrimSpace(payload.SceneID) == "" {
+		return errors.New("sceneID required")
+	}
+
+	tracker := s.Progress.CreateTracker(rec.ID)
+	tracker.UpdateProgress(1, "服务重启，任务已恢复排队...")
+
+	if rec.Type == ComicJobTypeRegenerateFrame {
+		return s.submitRegenerateFrameJob(context.Background(), rec.ID, tracker, payload.SceneID, payload.FrameID)
+	}
+	// 恢复的生成任务总是跳过已完成的帧
+	payload.Options.Resume = true
+	return s.submitGenerateJob(context.Background(), rec.ID, tracker, payload.SceneID, payload.Options)
 }
 
 // EnsureSceneLayout 提前创建 `data/comics/scene_<id>/...` 目录结构。
@@ -381,26 +454,40 @@ func (s *ComicService) shouldSkipExistingFrameOnResume(sceneID string, frameID s
 	return strings.TrimSpace(saved.Signature) == currentSig
 }
 
-func (s *ComicService) saveFrameImageSignature(sceneID string, frameID string, fp *models.ComicFramePrompt, promptText string) {
+// saveFrameImageSignature 写入 images/<frame_id>.meta.json（含实际使用的尺寸与种子，供复现），
+// 并返回写入的签名供版本记录使用
+func (s *ComicService) saveFrameImageSignature(sceneID string, frameID string, fp *models.ComicFramePrompt, promptText string, opts VisionGenerateOptions, seedStrategy string) *models.ComicFrameImageSignature {
 	if s == nil || s.Repo == nil || fp == nil {
-		return
+		return nil
 	}
 	sig := buildFrameImageSignature(fp, promptText)
 	if sig == "" {
-		return
+		return nil
 	}
 	meta := &models.ComicFrameImageSignature{
-		FrameID:      strings.TrimSpace(frameID),
-		Signature:    sig,
-		Prompt:       strings.TrimSpace(promptText),
-		Style:        strings.TrimSpace(fp.Style),
-		Model:        strings.TrimSpace(fp.Model),
-		GeneratedAt:  time.Now(),
-		TemplateHint: "resume_signature_v1",
-	}
+		FrameID:        strings.TrimSpace(frameID),
+		Signature:      sig,
+		Prompt:         strings.TrimSpace(promptText),
+		NegativePrompt: opts.NegativePrompt,
+		Style:          strings.TrimSpace(fp.Style),
+		Model:          strings.TrimSpace(fp.Model),
+		ModelParams:    fp.ModelParams,
+		Width:          opts.Width,
+		Height:         opts.Height,
+		Seed:           opts.Seed,
+		SeedStrategy:   seedStrategy,
+		GeneratedAt:    time.Now(),
+		TemplateHint:   frameSignatureTemplateHint,
+	}
+	meta.ReferenceImage = frameReferenceHash(opts.ReferenceImage)
+	for _, ref := range opts.ReferenceImages {
+		meta.ReferenceImages = append(meta.ReferenceImages, frameReferenceHash(ref))
+	}
+	meta.DenoisingStrength = opts.DenoisingStrength
 	if err := s.Repo.SaveFrameImageSignature(sceneID, frameID, meta); err != nil {
 		utils.GetLogger().Warn("save frame image signature failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "err": err})
 	}
+	return meta
 }
 
 func buildNodeContentFromConversations(convs []models.Conversation, nodeIDs []string, maxRunes int) nodeContentBuildResult {
@@ -804,6 +891,25 @@ func (s *ComicService) findPreviousFrameID(sceneID string, frameID string) strin
 	return ""
 }
 
+// findFramePlan 返回分镜中的某一帧（用于单帧重绘时匹配角色设定图）
+func (s *ComicService) findFramePlan(sceneID string, frameID string) *models.ComicFramePlan {
+	if s == nil || s.Repo == nil {
+		return nil
+	}
+	frameID = strings.TrimSpace(frameID)
+	analysis, err := s.Repo.LoadAnalysis(sceneID)
+	if frameID == "" || err != nil || analysis == nil {
+		return nil
+	}
+	for i := range analysis.Frames {
+		if strings.TrimSpace(analysis.Frames[i].ID) == frameID {
+			frame := analysis.Frames[i]
+			return &frame
+		}
+	}
+	return nil
+}
+
 func buildContinuityAnchorFromKeyElements(keyElements *models.ComicKeyElements) string {
 	if keyElements == nil {
 		return ""
@@ -1134,8 +1240,8 @@ func (s *ComicService) AnalyzeStoryAsyncWithConfigAndSourceText(ctx context.Cont
 	tracker := s.Progress.CreateTracker(taskID)
 	tracker.UpdateProgress(1, "准备分析故事...")
 
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
-		if ctx != nil {
+	if err := s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeAnalyze, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		if ctx != nil && JobAttempt(jobCtx) <= 1 {
 			select {
 			case <-jobCtx.Done():
 				return jobCtx.Err()
@@ -1176,7 +1282,7 @@ func (s *ComicService) AnalyzeStoryAsyncWithConfigAndSourceText(ctx context.Cont
 		user := prompts.BuildStoryAnalysisPromptFromNodeContent(sceneData.Scene, "source_text", sourceText, cfg)
 
 		var breakdown models.ComicBreakdown
-		resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(jobCtx, user, sys, &breakdown)
+		resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(WithLLMScene(WithLLMTask(jobCtx, LLMTaskComic), sceneID), user, sys, &breakdown)
 		if err != nil {
 			tracker.Fail("LLM 分镜生成失败")
 			return err
@@ -1233,9 +1339,9 @@ func (s *ComicService) AnalyzeStoryAsyncWithConfigAndNode(ctx context.Context, s
 	tracker := s.Progress.CreateTracker(taskID)
 	tracker.UpdateProgress(1, "准备分析故事...")
 
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
+	if err := s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeAnalyze, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
 		// prefer job ctx; fallback to caller ctx if needed
-		if ctx != nil {
+		if ctx != nil && JobAttempt(jobCtx) <= 1 {
 			select {
 			case <-jobCtx.Done():
 				return jobCtx.Err()
@@ -1289,7 +1395,7 @@ func (s *ComicService) AnalyzeStoryAsyncWithConfigAndNode(ctx context.Context, s
 		}
 
 		var breakdown models.ComicBreakdown
-		resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(jobCtx, user, sys, &breakdown)
+		resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(WithLLMScene(WithLLMTask(jobCtx, LLMTaskComic), sceneID), user, sys, &breakdown)
 		if err != nil {
 			tracker.Fail("LLM 分镜生成失败")
 			return err
@@ -1351,8 +1457,8 @@ func (s *ComicService) BuildPromptsAsyncWithOptions(ctx context.Context, sceneID
 	tracker := s.Progress.CreateTracker(taskID)
 	tracker.UpdateProgress(1, "准备生成提示词...")
 
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
-		if ctx != nil {
+	if err := s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypePrompts, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		if ctx != nil && JobAttempt(jobCtx) <= 1 {
 			select {
 			case <-jobCtx.Done():
 				return jobCtx.Err()
@@ -1438,7 +1544,7 @@ func (s *ComicService) BuildPromptsAsyncWithOptions(ctx context.Context, sceneID
 			}
 			user := prompts.BuildFramePrompt(sceneData.Scene, frame, cfg, build.UsedNodeIDs, build.Content, prevFramePrompt)
 			var out models.ComicFramePrompt
-			resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(jobCtx, user, sys, &out)
+			resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(WithLLMScene(WithLLMTask(jobCtx, LLMTaskComic), sceneID), user, sys, &out)
 			if err != nil {
 				tracker.Fail("LLM 提示词生成失败")
 				return err
@@ -1512,8 +1618,8 @@ func (s *ComicService) ExtractKeyElementsAsync(ctx context.Context, sceneID stri
 	tracker := s.Progress.CreateTracker(taskID)
 	tracker.UpdateProgress(1, "准备提取关键元素...")
 
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
-		if ctx != nil {
+	if err := s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeKeyElements, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		if ctx != nil && JobAttempt(jobCtx) <= 1 {
 			select {
 			case <-jobCtx.Done():
 				return jobCtx.Err()
@@ -1568,7 +1674,7 @@ func (s *ComicService) ExtractKeyElementsAsync(ctx context.Context, sceneID stri
 		user := prompts.BuildKeyElementsPrompt(sceneData.Scene, *breakdown, framePrompts, cfg)
 
 		var out models.ComicKeyElements
-		resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(jobCtx, user, sys, &out)
+		resp, dur, cached, err := s.LLM.CreateStructuredCompletionWithMetrics(WithLLMScene(WithLLMTask(jobCtx, LLMTaskComic), sceneID), user, sys, &out)
 		if err != nil {
 			tracker.Fail("LLM 关键元素提取失败")
 			return err
@@ -1629,8 +1735,19 @@ func (s *ComicService) GenerateComicAsyncWithOptions(ctx context.Context, sceneI
 	tracker := s.Progress.CreateTracker(taskID)
 	tracker.UpdateProgress(1, "准备生成图片...")
 
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
-		if ctx != nil {
+	if err := s.submitGenerateJob(ctx, taskID, tracker, sceneID, options); err != nil {
+		tracker.Fail("任务提交失败")
+		return "", err
+	}
+
+	return taskID, nil
+}
+
+// submitGenerateJob 提交图片生成任务；任务参数写入任务日志，服务重启后以相同 taskID 恢复。
+func (s *ComicService) submitGenerateJob(ctx context.Context, taskID string, tracker *ProgressTracker, sceneID string, options ComicGenerateOptions) error {
+	payload := comicGenerateJobPayload{SceneID: sceneID, Options: options}
+	return s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeGenerate, Payload: payload, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		if ctx != nil && JobAttempt(jobCtx) <= 1 {
 			select {
 			case <-jobCtx.Done():
 				return jobCtx.Err()
@@ -1685,11 +1802,12 @@ func (s *ComicService) GenerateComicAsyncWithOptions(ctx context.Context, sceneI
 				keyElements = ke
 			}
 		}
+		sheets := s.bestEffortLoadCharacterSheets(sceneID)
 
-		baseSeed := time.Now().UnixNano() % 10000
-		if baseSeed == 0 {
-			baseSeed = 1
-		}
+		// 重试或重启恢复时跳过已生成且提示词未变的帧
+		resume := options.Resume || JobAttempt(jobCtx) > 1
+
+		seedSettings := s.loadSeedSettingsBestEffort(sceneID)
 
 		for i, frame := range breakdown.Frames {
 			if err := jobCtx.Err(); err != nil {
@@ -1710,7 +1828,7 @@ func (s *ComicService) GenerateComicAsyncWithOptions(ctx context.Context, sceneI
 				return errors.New("prompt is empty")
 			}
 			promptText := applyStyleToPrompt(fp.Prompt, fp.Style)
-			if options.Resume && s.shouldSkipExistingFrameOnResume(sceneID, frame.ID, fp, promptText) {
+			if resume && s.shouldSkipExistingFrameOnResume(sceneID, frame.ID, fp, promptText) {
 				tracker.UpdateProgress(progress, fmt.Sprintf("跳过已存在且未变更图片：%s", frame.ID))
 				continue
 			}
@@ -1718,9 +1836,8 @@ func (s *ComicService) GenerateComicAsyncWithOptions(ctx context.Context, sceneI
 			frameOpts := VisionGenerateOptions{Width: 512, Height: 512, Model: fp.Model, NegativePrompt: fp.NegativePrompt}
 			applyModelParams(&frameOpts, fp.ModelParams)
 			frameOpts.NegativePrompt = applyStyleGuardToNegativePrompt(fp.Style, frameOpts.NegativePrompt)
-			if frameOpts.Seed == 0 {
-				frameOpts.Seed = baseSeed + int64(i*100)
-			}
+			explicitSeed := frameOpts.Seed
+			frameOpts.Seed, frameOpts.RandomSeed = resolveFrameSeed(seedSettings, frame.ID, explicitSeed)
 			if promptText != strings.TrimSpace(fp.Prompt) {
 				fp.Prompt = promptText
 				if err := s.Repo.SavePrompt(sceneID, frame.ID, fp); err != nil {
@@ -1748,24 +1865,23 @@ func (s *ComicService) GenerateComicAsyncWithOptions(ctx context.Context, sceneI
 					frameOpts.ReferenceImage = refImage
 				}
 			}
-			if _, _, err := s.Vision.GenerateAndSaveFrame(jobCtx, sceneID, frame.ID, promptText, frameOpts); err != nil {
+			frameOpts.ReferenceImages = s.selectCharacterSheetReferences(sceneID, &frame, promptText, keyElements, sheets, refIndex)
+			s.snapshotFrameVersion(sceneID, frame.ID)
+			_, img, err := s.Vision.GenerateAndSaveFrame(jobCtx, sceneID, frame.ID, promptText, frameOpts)
+			if err != nil {
 				failMsg := s.buildFrameVisionFailureMessage(frame.ID, frameOpts, err)
 				tracker.EmitProgressEvent(progress, failMsg, "frame_failed", frame.ID)
 				tracker.Fail(failMsg)
 				return err
 			}
-			s.saveFrameImageSignature(sceneID, frame.ID, fp, promptText)
+			sig := s.saveFrameImageSignature(sceneID, frame.ID, fp, promptText, frameOpts, seedStrategyFor(seedSettings, explicitSeed))
+			s.recordGeneratedFrameVersion(sceneID, frame.ID, img, models.ComicFrameVersionSourceGenerate, promptText, frameOpts, sig)
 			tracker.EmitProgressEvent(progress, fmt.Sprintf("图片已写入：%s", frame.ID), "image_written", frame.ID)
 		}
 
 		tracker.Complete("图片生成完成")
 		return nil
-	}); err != nil {
-		tracker.Fail("任务提交失败")
-		return "", err
-	}
-
-	return taskID, nil
+	})
 }
 
 // RegenerateFrameAsync regenerates a single frame image based on its saved prompt.
@@ -1784,8 +1900,19 @@ func (s *ComicService) RegenerateFrameAsync(ctx context.Context, sceneID string,
 	tracker := s.Progress.CreateTracker(taskID)
 	tracker.UpdateProgress(1, "准备重绘图片...")
 
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
-		if ctx != nil {
+	if err := s.submitRegenerateFrameJob(ctx, taskID, tracker, sceneID, frameID); err != nil {
+		tracker.Fail("任务提交失败")
+		return "", err
+	}
+
+	return taskID, nil
+}
+
+// submitRegenerateFrameJob 提交单帧重绘任务（同样会写入任务日志）。
+func (s *ComicService) submitRegenerateFrameJob(ctx context.Context, taskID string, tracker *ProgressTracker, sceneID string, frameID string) error {
+	payload := comicGenerateJobPayload{SceneID: sceneID, FrameID: frameID}
+	return s.JobQueue.SubmitJob(taskID, JobOptions{Type: ComicJobTypeRegenerateFrame, Payload: payload, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
+		if ctx != nil && JobAttempt(jobCtx) <= 1 {
 			select {
 			case <-jobCtx.Done():
 				return jobCtx.Err()
@@ -1817,6 +1944,9 @@ func (s *ComicService) RegenerateFrameAsync(ctx context.Context, sceneID string,
 		opts := VisionGenerateOptions{Width: 512, Height: 512, Model: fp.Model, NegativePrompt: fp.NegativePrompt}
 		applyModelParams(&opts, fp.ModelParams)
 		opts.NegativePrompt = applyStyleGuardToNegativePrompt(fp.Style, opts.NegativePrompt)
+		seedSettings := s.loadSeedSettingsBestEffort(sceneID)
+		explicitSeed := opts.Seed
+		opts.Seed, opts.RandomSeed = resolveFrameSeed(seedSettings, frameID, explicitSeed)
 		promptText := applyStyleToPrompt(fp.Prompt, fp.Style)
 		if promptText != strings.TrimSpace(fp.Prompt) {
 			fp.Prompt = promptText
@@ -1824,62 +1954,68 @@ func (s *ComicService) RegenerateFrameAsync(ctx context.Context, sceneID string,
 				utils.GetLogger().Warn("save normalized prompt failed", map[string]interface{}{"scene_id": sceneID, "frame_id": frameID, "err": err})
 			}
 		}
-		usePrevRef, prevRefDenoising := parsePrevFrameReferenceOptions(fp.ModelParams)
-		if usePrevRef {
-			prevFrameID := s.findPreviousFrameID(sceneID, frameID)
-			prevFrameImage := s.bestEffortLoadGeneratedFrameImage(sceneID, prevFrameID)
-			if len(prevFrameImage) > 0 {
-				opts.ReferenceImage = prevFrameImage
-				if opts.DenoisingStrength <= 0 {
-					if prevRefDenoising > 0 {
-						opts.DenoisingStrength = prevRefDenoising
-					} else {
-						opts.DenoisingStrength = 0.35
-					}
-				}
-			}
-		}
-		if opts.DenoisingStrength > 0 && len(opts.ReferenceImage) == 0 {
-			var refIndex *models.ComicReferenceIndex
-			if s.Repo != nil {
-				idx, err := s.Repo.LoadReferenceIndex(sceneID)
-				if err != nil && !isLikelyStorageNotFound(err) {
-					utils.GetLogger().Warn("load reference index failed", map[string]interface{}{"scene_id": sceneID, "err": err})
-				} else {
-					refIndex = idx
-				}
-			}
-			var keyElements *models.ComicKeyElements
-			if s.Repo != nil {
-				ke, err := s.Repo.LoadKeyElements(sceneID)
-				if err != nil && !isLikelyStorageNotFound(err) {
-					utils.GetLogger().Warn("load key elements failed", map[string]interface{}{"scene_id": sceneID, "err": err})
-				} else {
-					keyElements = ke
-				}
-			}
-			refImage := s.selectReferenceImageForFrame(sceneID, nil, promptText, keyElements, refIndex)
-			if len(refImage) > 0 {
-				opts.ReferenceImage = refImage
-			}
-		}
-		if _, _, err := s.Vision.GenerateAndSaveFrame(jobCtx, sceneID, frameID, promptText, opts); err != nil {
+		s.applyFrameReferenceImages(sceneID, frameID, fp.ModelParams, promptText, &opts)
+		s.snapshotFrameVersion(sceneID, frameID)
+		_, img, err := s.Vision.GenerateAndSaveFrame(jobCtx, sceneID, frameID, promptText, opts)
+		if err != nil {
 			failMsg := s.buildFrameVisionFailureMessage(frameID, opts, err)
 			tracker.EmitProgressEvent(50, failMsg, "frame_failed", frameID)
 			tracker.Fail(failMsg)
 			return err
 		}
-		s.saveFrameImageSignature(sceneID, frameID, fp, promptText)
+		sig := s.saveFrameImageSignature(sceneID, frameID, fp, promptText, opts, seedStrategyFor(seedSettings, explicitSeed))
+		s.recordGeneratedFrameVersion(sceneID, frameID, img, models.ComicFrameVersionSourceRegenerate, promptText, opts, sig)
 		tracker.EmitProgressEvent(95, fmt.Sprintf("图片已写入：%s", frameID), "image_written", frameID)
 
 		tracker.Complete("重绘完成")
 		return nil
-	}); err != nil {
-		tracker.Fail("任务提交失败")
-		return "", err
-	}
+	})
+}
 
-	return taskID, nil
+// applyFrameReferenceImages 按单帧重绘的规则为 opts 选择参考图：上一帧图片（model_params 开启时）、
+// 元素参考图（img2img 时）与角色设定图。
+func (s *ComicService) applyFrameReferenceImages(sceneID string, frameID string, modelParams map[string]interface{}, promptText string, opts *VisionGenerateOptions) {
+	usePrevRef, prevRefDenoising := parsePrevFrameReferenceOptions(modelParams)
+	if usePrevRef {
+		prevFrameID := s.findPreviousFrameID(sceneID, frameID)
+		prevFrameImage := s.bestEffortLoadGeneratedFrameImage(sceneID, prevFrameID)
+		if len(prevFrameImage) > 0 {
+			opts.ReferenceImage = prevFrameImage
+			if opts.DenoisingStrength <= 0 {
+				if prevRefDenoising > 0 {
+					opts.DenoisingStrength = prevRefDenoising
+				} else {
+					opts.DenoisingStrength = 0.35
+				}
+			}
+		}
+	}
+	var refIndex *models.ComicReferenceIndex
+	if s.Repo != nil {
+		idx, err := s.Repo.LoadReferenceIndex(sceneID)
+		if err != nil && !isLikelyStorageNotFound(err) {
+			utils.GetLogger().Warn("load reference index failed", map[string]interface{}{"scene_id": sceneID, "err": err})
+		} else {
+			refIndex = idx
+		}
+	}
+	var keyElements *models.ComicKeyElements
+	if s.Repo != nil {
+		ke, err := s.Repo.LoadKeyElements(sceneID)
+		if err != nil && !isLikelyStorageNotFound(err) {
+			utils.GetLogger().Warn("load key elements failed", map[string]interface{}{"scene_id": sceneID, "err": err})
+		} else {
+			keyElements = ke
+		}
+	}
+	if opts.DenoisingStrength > 0 && len(opts.ReferenceImage) == 0 {
+		refImage := s.selectReferenceImageForFrame(sceneID, nil, promptText, keyElements, refIndex)
+		if len(refImage) > 0 {
+			opts.ReferenceImage = refImage
+		}
+	}
+	sheets := s.bestEffortLoadCharacterSheets(sceneID)
+	opts.ReferenceImages = s.selectCharacterSheetReferences(sceneID, s.findFramePlan(sceneID, frameID), promptText, keyElements, sheets, refIndex)
 }
 
 // GenerateFramesAsync starts async jobs to generate multiple frames in parallel.
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Corphon/SceneIntruderMCP/internal/config"
 	"github.com/Corphon/SceneIntruderMCP/internal/di"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
 	"github.com/Corphon/SceneIntruderMCP/internal/utils"
 )
 
@@ -306,6 +308,225 @@ func (s *ConfigService) UpdateLLMConfig(provider string, configMap map[string]st
 	return nil
 }
 
+// UpdateLLMFallbacks 替换备用 LLM 提供商链，并同步到运行中的 LLM 服务
+func (s *ConfigService) UpdateLLMFallbacks(fallbacks []config.LLMFallbackInfo, changedBy string) error {
+	fallbacksCopy := make([]config.LLMFallbackInfo, len(fallbacks))
+	for i, fb := range fallbacks {
+		cfg := make(map[string]string, len(fb.Config))
+		maps.Copy(cfg, fb.Config)
+		fallbacksCopy[i] = config.LLMFallbackInfo{Provider: fb.Provider, Model: fb.Model, Config: cfg}
+	}
+
+	var oldConfig *config.AppConfig
+	var subscribers []ConfigChangeSubscriber
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		oldConfig = s.getCurrentConfigUnsafe()
+		subscribers = make([]ConfigChangeSubscriber, len(s.subscribers))
+		copy(subscribers, s.subscribers)
+
+		s.recordAuditUnsafe("write", "LLM备用提供商", changedBy)
+		s.configVersion++
+	}()
+
+	if err := config.UpdateLLMFallbacks(fallbacksCopy); err != nil {
+		s.mu.Lock()
+		s.configVersion--
+		s.mu.Unlock()
+		return fmt.Errorf("更新备用提供商失败: %w", err)
+	}
+
+	var newConfig *config.AppConfig
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.cachedConfig = config.GetCurrentConfig()
+		s.lastUpdated = time.Now()
+		newConfig = s.cachedConfig
+
+		// 变更记录只保留提供商与模型，不记录密钥
+		s.recordChangeUnsafe("LLM备用提供商", describeLLMFallbacks(oldConfig.LLMFallbacks), describeLLMFallbacks(newConfig.LLMFallbacks), changedBy)
+	}()
+
+	s.notifySubscribersAsyncSafe(oldConfig, newConfig, subscribers)
+
+	go func() {
+		container := di.GetContainer()
+		if llmService, ok := container.Get("llm").(*LLMService); ok && llmService != nil {
+			llmService.UpdateFallbacks(newConfig.LLMFallbacks)
+		}
+	}()
+
+	return nil
+}
+
+// UpdateLLMRoutes 替换按任务类别的模型路由表，并同步到运行中的 LLM 服务
+func (s *ConfigService) UpdateLLMRoutes(routes map[string]config.LLMRouteInfo, changedBy string) error {
+	routesCopy := make(map[string]config.LLMRouteInfo, len(routes))
+	maps.Copy(routesCopy, routes)
+	if err := ValidateLLMRoutes(routesCopy, config.GetCurrentConfig()); err != nil {
+		return err
+	}
+
+	var oldConfig *config.AppConfig
+	var subscribers []ConfigChangeSubscriber
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		oldConfig = s.getCurrentConfigUnsafe()
+		subscribers = make([]ConfigChangeSubscriber, len(s.subscribers))
+		copy(subscribers, s.subscribers)
+
+		s.recordAuditUnsafe("write", "LLM任务路由", changedBy)
+		s.configVersion++
+	}()
+
+	if err := config.UpdateLLMRoutes(routesCopy); err != nil {
+		s.mu.Lock()
+		s.configVersion--
+		s.mu.Unlock()
+		return fmt.Errorf("更新任务路由失败: %w", err)
+	}
+
+	var newConfig *config.AppConfig
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.cachedConfig = config.GetCurrentConfig()
+		s.lastUpdated = time.Now()
+		newConfig = s.cachedConfig
+
+		s.recordChangeUnsafe("LLM任务路由", oldConfig.LLMRoutes, newConfig.LLMRoutes, changedBy)
+	}()
+
+	s.notifySubscribersAsyncSafe(oldConfig, newConfig, subscribers)
+
+	go func() {
+		container := di.GetContainer()
+		if llmService, ok := container.Get("llm").(*LLMService); ok && llmService != nil {
+			llmService.UpdateRoutes(newConfig.LLMRoutes)
+		}
+	}()
+
+	return nil
+}
+
+// UpdateLLMPrices 替换 LLM 价格表，并同步到用量服务
+func (s *ConfigService) UpdateLLMPrices(prices map[string]config.LLMPriceInfo, changedBy string) error {
+	pricesCopy := make(map[string]config.LLMPriceInfo, len(prices))
+	maps.Copy(pricesCopy, prices)
+
+	var oldConfig *config.AppConfig
+	var subscribers []ConfigChangeSubscriber
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		oldConfig = s.getCurrentConfigUnsafe()
+		subscribers = make([]ConfigChangeSubscriber, len(s.subscribers))
+		copy(subscribers, s.subscribers)
+
+		s.recordAuditUnsafe("write", "LLM价格表", changedBy)
+		s.configVersion++
+	}()
+
+	if err := config.UpdateLLMPrices(pricesCopy); err != nil {
+		s.mu.Lock()
+		s.configVersion--
+		s.mu.Unlock()
+		return fmt.Errorf("更新价格表失败: %w", err)
+	}
+
+	var newConfig *config.AppConfig
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.cachedConfig = config.GetCurrentConfig()
+		s.lastUpdated = time.Now()
+		newConfig = s.cachedConfig
+
+		s.recordChangeUnsafe("LLM价格表", oldConfig.LLMPrices, newConfig.LLMPrices, changedBy)
+	}()
+
+	s.notifySubscribersAsyncSafe(oldConfig, newConfig, subscribers)
+
+	go func() {
+		container := di.GetContainer()
+		if usageService, ok := container.Get("usage").(*UsageService); ok && usageService != nil {
+			usageService.UpdatePrices(newConfig.LLMPrices)
+		}
+	}()
+
+	return nil
+}
+
+// UpdateUsageQuotas 替换默认配额与按用户的配额，并同步到用量服务
+func (s *ConfigService) UpdateUsageQuotas(defaultQuota config.UsageQuotaInfo, userQuotas map[string]config.UsageQuotaInfo, changedBy string) error {
+	quotasCopy := make(map[string]config.UsageQuotaInfo, len(userQuotas))
+	maps.Copy(quotasCopy, userQuotas)
+
+	var oldConfig *config.AppConfig
+	var subscribers []ConfigChangeSubscriber
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		oldConfig = s.getCurrentConfigUnsafe()
+		subscribers = make([]ConfigChangeSubscriber, len(s.subscribers))
+		copy(subscribers, s.subscribers)
+
+		s.recordAuditUnsafe("write", "用量配额", changedBy)
+		s.configVersion++
+	}()
+
+	if err := config.UpdateUsageQuotas(defaultQuota, quotasCopy); err != nil {
+		s.mu.Lock()
+		s.configVersion--
+		s.mu.Unlock()
+		return fmt.Errorf("更新用量配额失败: %w", err)
+	}
+
+	var newConfig *config.AppConfig
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.cachedConfig = config.GetCurrentConfig()
+		s.lastUpdated = time.Now()
+		newConfig = s.cachedConfig
+
+		s.recordChangeUnsafe("用量配额",
+			map[string]interface{}{"default": oldConfig.UsageQuota, "users": oldConfig.UserUsageQuotas},
+			map[string]interface{}{"default": newConfig.UsageQuota, "users": newConfig.UserUsageQuotas},
+			changedBy)
+	}()
+
+	s.notifySubscribersAsyncSafe(oldConfig, newConfig, subscribers)
+
+	go func() {
+		container := di.GetContainer()
+		if usageService, ok := container.Get("usage").(*UsageService); ok && usageService != nil {
+			usageService.UpdateQuotas(newConfig.UsageQuota, newConfig.UserUsageQuotas)
+		}
+	}()
+
+	return nil
+}
+
+func describeLLMFallbacks(fallbacks []config.LLMFallbackInfo) []string {
+	out := make([]string, 0, len(fallbacks))
+	for _, fb := range fallbacks {
+		out = append(out, strings.TrimSuffix(fb.Provider+"/"+fb.Model, "/"))
+	}
+	return out
+}
+
 // UpdateVisionConfig updates vision provider configuration and applies it to the live VisionService.
 // This is intentionally minimal for Phase5.
 func (s *ConfigService) UpdateVisionConfig(provider string, visionCfg map[string]string, defaultModel string, modelProviders map[string]string, models []config.VisionModelInfo, changedBy string) error {
@@ -487,6 +708,7 @@ func (s *ConfigService) validateLLMConfig(provider string, configMap map[string]
 	supportedProviders := []string{
 		"openai", "anthropic", "google", "githubmodels", "grok",
 		"mistral", "qwen", "glm", "deepseek", "openrouter", "nvidia",
+		"ollama", "local", "replay",
 	}
 
 	found := false
@@ -501,13 +723,15 @@ func (s *ConfigService) validateLLMConfig(provider string, configMap map[string]
 		return fmt.Errorf("不支持的提供商: %s", provider)
 	}
 
-	// 验证必需的配置项
-	if _, ok := configMap["api_key"]; !ok {
-		return fmt.Errorf("缺少 api_key 配置")
-	}
+	// 验证必需的配置项（本地提供商无需密钥）
+	if llm.RequiresAPIKey(provider) {
+		if _, ok := configMap["api_key"]; !ok {
+			return fmt.Errorf("缺少 api_key 配置")
+		}
 
-	if configMap["api_key"] == "" {
-		return fmt.Errorf("api_key 不能为空")
+		if configMap["api_key"] == "" {
+			return fmt.Errorf("api_key 不能为空")
+		}
 	}
 	// 验证特定提供商的配置
 	switch provider {
@@ -554,6 +778,8 @@ func (s *ConfigService) getDefaultModelForProvider(provider string) string {
 		return "x-ai/grok-4.1-fast:free"
 	case "nvidia":
 		return "moonshotai/kimi-k2.5"
+	case "ollama":
+		return "llama3.1"
 	default:
 		return "gpt-4.1" // 默认回退
 	}
@@ -815,7 +1041,7 @@ func (c *ConfigHealthCheck) CheckHealth() map[string]interface{} {
 				"provider": c.service.cachedConfig.LLMProvider,
 			}
 		}
-		if c.service.cachedConfig.LLMConfig == nil || c.service.cachedConfig.LLMConfig["api_key"] == "" {
+		if !LLMConfigComplete(c.service.cachedConfig.LLMProvider, c.service.cachedConfig.LLMConfig) {
 			checks["llm_api_key"] = map[string]interface{}{
 				"status": "warning",
 				"error":  "LLM API密钥未配置",
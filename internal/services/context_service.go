@@ -13,6 +13,8 @@ import (
 // ContextService 管理场景上下文和交互历史
 type ContextService struct {
 	SceneService SceneServiceInterface
+	// MemoryService 角色长期记忆（可选）；未设置时角色只依赖近期对话
+	MemoryService *MemoryService
 
 	// 并发控制
 	sceneLocks  sync.Map // sceneID -> *sync.RWMutex
@@ -313,8 +315,8 @@ func (s *ContextService) HasUserStoryCommands(sceneID, nodeID string) bool {
 	return false
 }
 
-// BuildCharacterMemory 构建角色记忆
-func (s *ContextService) BuildCharacterMemory(sceneID, characterID string) (string, error) {
+// BuildCharacterMemory 构建角色记忆：基础身份描述，加上与 query 相关的长期记忆
+func (s *ContextService) BuildCharacterMemory(sceneID, characterID, query string) (string, error) {
 	// 使用缓存加载场景数据
 	sceneData, err := s.loadSceneDataSafe(sceneID)
 	if err != nil {
@@ -338,11 +340,26 @@ func (s *ContextService) BuildCharacterMemory(sceneID, characterID string) (stri
 	memory := fmt.Sprintf("我是%s，我在%s场景中。我是%s。",
 		character.Name, sceneData.Scene.Title, character.Description)
 
+	isEnglish := isEnglishText(character.Name + " " + character.Description)
+	if recalled := s.MemoryService.Recall(sceneID, characterID, query, isEnglish); recalled != "" {
+		if isEnglish {
+			memory += "\nThings I remember:\n" + recalled
+		} else {
+			memory += "\n我记得：\n" + recalled
+		}
+	}
+
 	return memory, nil
 }
 
 // AddConversation 添加对话到场景上下文，支持角色间对话记录
 func (s *ContextService) AddConversation(sceneID, speakerID, content string, metadata map[string]interface{}, nodeID string) error {
+	_, err := s.AddConversationWithID(sceneID, speakerID, content, metadata, nodeID)
+	return err
+}
+
+// AddConversationWithID 与 AddConversation 相同，并返回新对话的ID（供记忆引用）
+func (s *ContextService) AddConversationWithID(sceneID, speakerID, content string, metadata map[string]interface{}, nodeID string) (string, error) {
 	lock := s.getSceneLock(sceneID)
 	lock.Lock()
 	defer lock.Unlock()
@@ -350,7 +367,7 @@ func (s *ContextService) AddConversation(sceneID, speakerID, content string, met
 	// 在锁内强制加载最新场景数据，避免使用缓存导致旧上下文覆盖新写入
 	sceneData, err := s.SceneService.LoadSceneNoCache(sceneID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	var metaCopy map[string]interface{}
@@ -400,7 +417,7 @@ func (s *ContextService) AddConversation(sceneID, speakerID, content string, met
 	// 更新场景上下文
 	err = s.SceneService.UpdateContext(sceneID, &sceneData.Context)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// 🔧 更新缓存
@@ -417,7 +434,7 @@ func (s *ContextService) AddConversation(sceneID, speakerID, content string, met
 	// 清除缓存以强制重新加载 when context changes
 	s.InvalidateSceneCache(sceneID)
 
-	return nil
+	return conversation.ID, nil
 }
 
 // GetCharacterInteractions 获取场景中的角色间互动历史
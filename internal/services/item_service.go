@@ -12,12 +12,14 @@ import (
 	"time"
 
 	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/storage"
 	"github.com/Corphon/SceneIntruderMCP/internal/utils"
 )
 
 // ItemService 处理物品相关的业务逻辑
 type ItemService struct {
 	ScenesPath string
+	Storage    storage.Store
 
 	// 并发控制
 	sceneLocks  sync.Map // sceneID -> *sync.RWMutex
@@ -45,8 +47,14 @@ func NewItemService(scenesPath string) *ItemService {
 		utils.GetLogger().Warn("创建场景数据目录失败", map[string]interface{}{"scenes_path": scenesPath, "err": err})
 	}
 
+	store, err := storage.Open(scenesPath)
+	if err != nil {
+		utils.GetLogger().Warn("创建物品存储失败", map[string]interface{}{"scenes_path": scenesPath, "err": err})
+	}
+
 	service := &ItemService{
 		ScenesPath:  scenesPath,
+		Storage:     store,
 		itemCache:   make(map[string]*CachedItemData),
 		cacheExpiry: 3 * time.Minute, // 3分钟缓存
 	}
@@ -57,6 +65,21 @@ func NewItemService(scenesPath string) *ItemService {
 	return service
 }
 
+// itemStore 返回物品存储（兼容直接构造的 ItemService）
+func (s *ItemService) itemStore() (storage.Store, error) {
+	s.fileMutex.Lock()
+	defer s.fileMutex.Unlock()
+
+	if s.Storage == nil {
+		store, err := storage.Open(s.ScenesPath)
+		if err != nil {
+			return nil, fmt.Errorf("初始化物品存储失败: %w", err)
+		}
+		s.Storage = store
+	}
+	return s.Storage, nil
+}
+
 // 获取场景锁
 func (s *ItemService) getSceneLock(sceneID string) *sync.RWMutex {
 	value, _ := s.sceneLocks.LoadOrStore(sceneID, &sync.RWMutex{})
@@ -100,34 +123,35 @@ func (s *ItemService) loadSceneItemsSafe(sceneID string) (*CachedItemData, error
 	s.cacheMutex.RUnlock()
 
 	// 加载数据
-	scenePath := filepath.Join(s.ScenesPath, sceneID, "items")
+	store, err := s.itemStore()
+	if err != nil {
+		return nil, err
+	}
+	itemsDir := filepath.Join(sceneID, "items")
 	cached := &CachedItemData{
 		Items:     make(map[string]*models.Item),
 		Timestamp: time.Now(),
 	}
 
-	// 检查目录是否存在
-	if _, err := os.Stat(scenePath); os.IsNotExist(err) {
-		// 目录不存在，返回空缓存
-		s.cacheMutex.Lock()
-		s.itemCache[sceneID] = cached
-		s.cacheMutex.Unlock()
-		return cached, nil
-	}
-
 	// 批量读取所有物品文件
-	files, err := os.ReadDir(scenePath)
+	files, err := store.ListFiles(itemsDir)
 	if err != nil {
+		if storage.IsNotExist(err) {
+			// 目录不存在，返回空缓存
+			s.cacheMutex.Lock()
+			s.itemCache[sceneID] = cached
+			s.cacheMutex.Unlock()
+			return cached, nil
+		}
 		return nil, fmt.Errorf("读取场景物品目录失败: %w", err)
 	}
 
 	for _, file := range files {
-		if filepath.Ext(file.Name()) != ".json" {
+		if filepath.Ext(file) != ".json" {
 			continue
 		}
 
-		itemPath := filepath.Join(scenePath, file.Name())
-		itemDataBytes, err := os.ReadFile(itemPath)
+		itemDataBytes, err := store.LoadTextFile(itemsDir, file)
 		if err != nil {
 			continue // 跳过无法读取的文件
 		}
@@ -161,14 +185,10 @@ func (s *ItemService) AddItem(sceneID string, item *models.Item) error {
 	lock.Lock()
 	defer lock.Unlock()
 
-	// 🔧 线程安全的目录创建
-	s.fileMutex.Lock()
-	scenePath := filepath.Join(s.ScenesPath, sceneID, "items")
-	if err := os.MkdirAll(scenePath, 0755); err != nil {
-		s.fileMutex.Unlock()
-		return fmt.Errorf("创建场景物品目录失败: %w", err)
+	store, err := s.itemStore()
+	if err != nil {
+		return err
 	}
-	s.fileMutex.Unlock()
 
 	// 🔧 线程安全的ID生成
 	if item.ID == "" {
@@ -181,22 +201,8 @@ func (s *ItemService) AddItem(sceneID string, item *models.Item) error {
 	item.SceneID = sceneID
 	item.LastUpdated = time.Now()
 
-	// 🔧 原子性文件写入
-	itemPath := filepath.Join(scenePath, item.ID+".json")
-	tempPath := itemPath + ".tmp"
-
-	itemDataJSON, err := json.MarshalIndent(item, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化物品数据失败: %w", err)
-	}
-
-	// 先写入临时文件，再原子性重命名
-	if err := os.WriteFile(tempPath, itemDataJSON, 0644); err != nil {
-		return fmt.Errorf("保存物品数据失败: %w", err)
-	}
-
-	if err := os.Rename(tempPath, itemPath); err != nil {
-		os.Remove(tempPath) // 清理临时文件
+	// 🔧 原子性写入（由存储后端保证）
+	if err := store.SaveJSONFile(filepath.Join(sceneID, "items"), item.ID+".json", item); err != nil {
 		return fmt.Errorf("保存物品数据失败: %w", err)
 	}
 
@@ -210,10 +216,9 @@ func (s *ItemService) AddItem(sceneID string, item *models.Item) error {
 func (s *ItemService) generateUniqueItemID(sceneID string) string {
 	for {
 		id := fmt.Sprintf("item_%d_%d", time.Now().UnixNano(), rand.Intn(1000))
-		itemPath := filepath.Join(s.ScenesPath, sceneID, "items", id+".json")
 
 		// 检查文件是否已存在
-		if _, err := os.Stat(itemPath); os.IsNotExist(err) {
+		if store, err := s.itemStore(); err != nil || !store.FileExists(filepath.Join(sceneID, "items"), id+".json") {
 			return id
 		}
 
@@ -307,6 +312,51 @@ func (s *ItemService) startCacheCleanup() {
 	}()
 }
 
+// ReplaceItems 用给定物品整体替换场景物品（读档），不在列表中的物品会被删除
+func (s *ItemService) ReplaceItems(sceneID string, items []*models.Item) error {
+	lock := s.getSceneLock(sceneID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	store, err := s.itemStore()
+	if err != nil {
+		return err
+	}
+
+	itemsDir := filepath.Join(sceneID, "items")
+	keep := make(map[string]bool, len(items))
+	for _, item := range items {
+		keep[item.ID+".json"] = true
+	}
+	existing, err := store.ListFiles(itemsDir)
+	if err != nil && !storage.IsNotExist(err) {
+		return fmt.Errorf("读取物品目录失败: %w", err)
+	}
+
+	err = store.WithTx(func(tx storage.Tx) error {
+		for _, name := range existing {
+			if strings.HasSuffix(name, ".json") && !keep[name] {
+				if err := tx.DeleteFile(itemsDir, name); err != nil {
+					return err
+				}
+			}
+		}
+		for _, item := range items {
+			item.SceneID = sceneID
+			if err := tx.SaveJSONFile(itemsDir, item.ID+".json", item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("替换物品失败: %w", err)
+	}
+
+	s.invalidateSceneCache(sceneID)
+	return nil
+}
+
 // DeleteItem 删除物品
 func (s *ItemService) DeleteItem(sceneID, itemID string) error {
 	// 获取场景写锁
@@ -315,8 +365,11 @@ func (s *ItemService) DeleteItem(sceneID, itemID string) error {
 	defer lock.Unlock()
 
 	// 删除物品文件
-	itemPath := filepath.Join(s.ScenesPath, sceneID, "items", itemID+".json")
-	if err := os.Remove(itemPath); err != nil {
+	store, err := s.itemStore()
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteFile(filepath.Join(sceneID, "items"), itemID+".json"); err != nil {
 		return fmt.Errorf("删除物品失败: %w", err)
 	}
 
@@ -3,42 +3,183 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
 )
 
 var (
 	ErrJobQueueStopped   = errors.New("job queue stopped")
 	ErrTaskAlreadyExists = errors.New("task already exists")
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobStateConflict  = errors.New("job state does not allow this operation")
 )
 
 type JobFunc func(ctx context.Context) error
 
-type queuedJob struct {
-	taskID string
-	ctx    context.Context
+// JobPriority 优先级通道：数值越小越先执行；0 表示沿用任务类型的默认优先级
+type JobPriority int
+
+const (
+	JobPriorityInteractive JobPriority = iota + 1 // 交互请求（对话、单帧重绘等）
+	JobPriorityNormal                             // 分析、提示词构建等
+	JobPriorityBatch                              // 批量图片/视频生成
+
+	jobPriorityLanes = 3
+)
+
+// JobStatus 任务状态
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusRetrying  JobStatus = "retrying" // 等待退避后重试
+	JobStatusPaused    JobStatus = "paused"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+func (s JobStatus) terminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// RetryPolicy 失败重试策略：指数退避，MaxAttempts 含首次执行
+type RetryPolicy struct {
+	MaxAttempts    int           `json:"max_attempts"`
+	InitialBackoff time.Duration `json:"initial_backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff"`
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	if d <= 0 {
+		d = time.Second
+	}
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// JobTypePolicy 每种任务类型的默认优先级与重试策略
+type JobTypePolicy struct {
+	Priority JobPriority
+	Retry    RetryPolicy
+}
+
+// JobRestoreFunc 在服务重启后根据日志记录重新提交任务（需以 rec.ID 调用 SubmitJob）
+type JobRestoreFunc func(rec JobRecord) error
+
+// JobOptions 提交任务时的附加信息；Type+Payload 齐备且类型注册了恢复函数时任务会写入日志
+type JobOptions struct {
+	Type     string
+	Priority JobPriority
+	Payload  interface{}
+	UserID   string // 提交任务的用户；任务列表与暂停/恢复/取消按此归属校验
+}
+
+// JobRecord 任务的可序列化状态（同时用于 API 展示与 data/jobs 日志）
+type JobRecord struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type,omitempty"`
+	UserID      string          `json:"user_id,omitempty"`
+	Priority    JobPriority     `json:"priority"`
+	Status      JobStatus       `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Persistent  bool            `json:"persistent"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	NextRunAt   *time.Time      `json:"next_run_at,omitempty"`
+}
+
+type jobEntry struct {
+	rec    JobRecord
 	fn     JobFunc
+	cancel context.CancelFunc // 当前执行尝试的取消函数
+	done   chan struct{}      // 进入终态时关闭
+	timer  *time.Timer        // 等待重试的定时器
+
+	cancelRequested bool
+	pauseRequested  bool
 }
 
-type taskState struct {
-	cancel context.CancelFunc
-	done   chan struct{}
+type jobAttemptKey struct{}
+
+type jobUserKey struct{}
+
+// WithJobUser 在上下文中记录提交任务的用户，服务层提交任务时据此填写 JobOptions.UserID
+func WithJobUser(ctx context.Context, userID string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, jobUserKey{}, strings.TrimSpace(userID))
+}
+
+// JobUserFromContext 返回上下文中记录的任务提交者；未记录时沿用 LLM 用量归属的用户
+func JobUserFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if userID, _ := ctx.Value(jobUserKey{}).(string); userID != "" {
+		return userID
+	}
+	userID, _ := LLMUsageScopeFromContext(ctx)
+	return userID
+}
+
+// JobAttempt 返回当前执行是第几次尝试（从1开始），不在队列中执行时返回0
+func JobAttempt(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	n, _ := ctx.Value(jobAttemptKey{}).(int)
+	return n
 }
 
-// JobQueue 是一个最小 worker pool：支持 Submit/Cancel/Wait，用 context 贯穿取消。
-// 设计目标：Phase1 先保证可用闭环；重试/持久化/优先级等后续再加。
+// JobQueue 是带优先级通道的 worker pool：支持 Submit/Cancel/Pause/Resume/Wait，用 context 贯穿取消。
+// 注册了恢复函数的任务类型会以 JSON 形式记录在日志目录中，服务重启后由 RestorePending 重新入队；
+// 失败的任务按类型的 RetryPolicy 退避重试。
 type JobQueue struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	jobs chan queuedJob
-	wg   sync.WaitGroup
+	wg sync.WaitGroup
 
-	mu      sync.RWMutex
-	tasks   map[string]*taskState
+	mu      sync.Mutex
+	cond    *sync.Cond
+	entries map[string]*jobEntry
+	lanes   [jobPriorityLanes][]string
 	stopped bool
+
+	policies   map[string]JobTypePolicy
+	restorers  map[string]JobRestoreFunc
+	journalDir string
+	recovered  map[string]JobRecord // 启动时从日志读取、尚未恢复的任务
+
+	// Progress 可选：重试时重新打开同名进度跟踪器
+	Progress *ProgressService
 }
 
+// 终态任务在内存中保留的数量，供列表接口查看
+const jobHistoryLimit = 200
+
+// NewJobQueue 创建队列；queueSize 为各优先级通道的初始容量（通道本身不设上限）
 func NewJobQueue(workerCount int, queueSize int) *JobQueue {
 	if workerCount <= 0 {
 		workerCount = 1
@@ -49,10 +190,16 @@ func NewJobQueue(workerCount int, queueSize int) *JobQueue {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	q := &JobQueue{
-		ctx:    ctx,
-		cancel: cancel,
-		jobs:   make(chan queuedJob, queueSize),
-		tasks:  make(map[string]*taskState),
+		ctx:       ctx,
+		cancel:    cancel,
+		entries:   make(map[string]*jobEntry),
+		policies:  make(map[string]JobTypePolicy),
+		restorers: make(map[string]JobRestoreFunc),
+		recovered: make(map[string]JobRecord),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	for i := range q.lanes {
+		q.lanes[i] = make([]string, 0, queueSize)
 	}
 
 	for i := 0; i < workerCount; i++ {
@@ -63,118 +210,305 @@ func NewJobQueue(workerCount int, queueSize int) *JobQueue {
 	return q
 }
 
-func (q *JobQueue) worker() {
-	defer q.wg.Done()
+// RegisterJobType 登记任务类型的默认策略；restore 非空时该类型的任务会被持久化并可在重启后恢复
+func (q *JobQueue) RegisterJobType(jobType string, policy JobTypePolicy, restore JobRestoreFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policies[jobType] = policy
+	if restore != nil {
+		q.restorers[jobType] = restore
+	}
+}
 
-	for job := range q.jobs {
-		func() {
-			defer func() {
-				q.mu.Lock()
-				st := q.tasks[job.taskID]
-				if st != nil {
-					select {
-					case <-st.done:
-					default:
-						close(st.done)
-					}
-				}
-				delete(q.tasks, job.taskID)
-				q.mu.Unlock()
-			}()
-
-			if job.fn != nil {
-				_ = job.fn(job.ctx)
-			}
-		}()
+// EnableJournal 启用任务日志目录，并读取上次运行遗留的未完成任务
+func (q *JobQueue) EnableJournal(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建任务日志目录失败: %w", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.journalDir = dir
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		var rec JobRecord
+		if err := json.Unmarshal(data, &rec); err != nil || rec.ID == "" {
+			utils.GetLogger().Warn("skip unreadable job journal", map[string]interface{}{"file": f.Name(), "err": err})
+			continue
+		}
+		if rec.Status.terminal() {
+			_ = os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		q.recovered[rec.ID] = rec
 	}
+
+	return nil
 }
 
+// RestorePending 调用各类型的恢复函数重新提交日志中的未完成任务，返回恢复成功的数量。
+// 应在所有服务完成 RegisterJobType 之后调用。
+func (q *JobQueue) RestorePending() int {
+	q.mu.Lock()
+	pending := make([]JobRecord, 0, len(q.recovered))
+	for _, rec := range q.recovered {
+		pending = append(pending, rec)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	restored := 0
+	for _, rec := range pending {
+		q.mu.Lock()
+		restore := q.restorers[rec.Type]
+		q.mu.Unlock()
+
+		var err error
+		if restore == nil {
+			err = fmt.Errorf("no restorer for job type %q", rec.Type)
+		} else {
+			err = restore(rec)
+		}
+
+		q.mu.Lock()
+		delete(q.recovered, rec.ID)
+		q.mu.Unlock()
+
+		if err != nil {
+			utils.GetLogger().Warn("restore job failed", map[string]interface{}{"task_id": rec.ID, "type": rec.Type, "err": err})
+			q.removeJournal(rec.ID)
+			continue
+		}
+		restored++
+	}
+
+	if restored > 0 {
+		utils.GetLogger().Info("jobs restored from journal", map[string]interface{}{"count": restored})
+	}
+	return restored
+}
+
+// Submit 提交一个普通任务（不持久化、不重试、普通优先级）
 func (q *JobQueue) Submit(taskID string, fn JobFunc) error {
+	return q.SubmitJob(taskID, JobOptions{}, fn)
+}
+
+// SubmitJob 按任务类型策略提交任务
+func (q *JobQueue) SubmitJob(taskID string, opts JobOptions, fn JobFunc) error {
 	if taskID == "" {
 		return errors.New("taskID required")
 	}
 
+	var payload json.RawMessage
+	if opts.Payload != nil {
+		b, err := json.Marshal(opts.Payload)
+		if err != nil {
+			return fmt.Errorf("序列化任务参数失败: %w", err)
+		}
+		payload = b
+	}
+
 	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	if q.stopped {
-		q.mu.Unlock()
 		return ErrJobQueueStopped
 	}
-	if _, exists := q.tasks[taskID]; exists {
-		q.mu.Unlock()
+	if existing, exists := q.entries[taskID]; exists && !existing.rec.Status.terminal() {
 		return ErrTaskAlreadyExists
 	}
 
-	taskCtx, taskCancel := context.WithCancel(q.ctx)
-	q.tasks[taskID] = &taskState{cancel: taskCancel, done: make(chan struct{})}
-	jobs := q.jobs
-	q.mu.Unlock()
+	policy := q.policies[opts.Type]
+	priority := opts.Priority
+	if priority == 0 {
+		priority = policy.Priority
+	}
+	if priority < JobPriorityInteractive || priority > JobPriorityBatch {
+		priority = JobPriorityNormal
+	}
+	maxAttempts := policy.Retry.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
 
-	// Enqueue outside the lock to avoid deadlocks when the queue is full.
-	// If the queue is concurrently stopped/closed, roll back the task state.
-	defer func() {
-		if r := recover(); r != nil {
-			q.mu.Lock()
-			st := q.tasks[taskID]
-			if st != nil {
-				select {
-				case <-st.done:
-				default:
-					close(st.done)
-				}
-				delete(q.tasks, taskID)
-				st.cancel()
-			}
-			q.mu.Unlock()
-		}
-	}()
+	now := time.Now()
+	e := &jobEntry{
+		rec: JobRecord{
+			ID:          taskID,
+			Type:        opts.Type,
+			UserID:      strings.TrimSpace(opts.UserID),
+			Priority:    priority,
+			Status:      JobStatusQueued,
+			MaxAttempts: maxAttempts,
+			Payload:     payload,
+			Persistent:  payload != nil && q.restorers[opts.Type] != nil,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		fn:   fn,
+		done: make(chan struct{}),
+	}
 
-	select {
-	case jobs <- queuedJob{taskID: taskID, ctx: taskCtx, fn: fn}:
-		return nil
-	case <-q.ctx.Done():
-		q.mu.Lock()
-		st := q.tasks[taskID]
-		if st != nil {
-			select {
-			case <-st.done:
-			default:
-				close(st.done)
-			}
-			delete(q.tasks, taskID)
-			st.cancel()
+	// 从日志恢复的任务沿用原有的提交者、尝试次数与暂停状态
+	if prev, ok := q.recovered[taskID]; ok {
+		if e.rec.UserID == "" {
+			e.rec.UserID = prev.UserID
+		}
+		e.rec.Attempts = prev.Attempts
+		e.rec.CreatedAt = prev.CreatedAt
+		e.rec.LastError = prev.LastError
+		if prev.Status == JobStatusPaused {
+			e.rec.Status = JobStatusPaused
 		}
-		q.mu.Unlock()
-		return ErrJobQueueStopped
 	}
+
+	q.entries[taskID] = e
+	if e.rec.Status == JobStatusQueued {
+		q.enqueueLocked(e)
+	}
+	q.persistLocked(e)
+	q.pruneHistoryLocked()
+	return nil
 }
 
+// Cancel 取消任务：排队/暂停/等待重试的任务直接结束，运行中的任务取消其 context
 func (q *JobQueue) Cancel(taskID string) bool {
-	q.mu.RLock()
-	st := q.tasks[taskID]
-	q.mu.RUnlock()
-	if st == nil {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.entries[taskID]
+	if e == nil || e.rec.Status.terminal() {
 		return false
 	}
-	st.cancel()
+
+	if e.rec.Status == JobStatusRunning {
+		e.cancelRequested = true
+		e.pauseRequested = false
+		if e.cancel != nil {
+			e.cancel()
+		}
+		return true
+	}
+
+	q.finishLocked(e, JobStatusCancelled, "cancelled")
+	// 未开始执行的任务不会自行结束进度跟踪器
+	q.updateTracker(taskID, func(t *ProgressTracker) { t.Fail("任务已取消") })
 	return true
 }
 
+// Pause 暂停任务：排队中的任务移出队列；运行中的任务取消当前尝试，恢复后从头执行
+func (q *JobQueue) Pause(taskID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.entries[taskID]
+	if e == nil {
+		return ErrJobNotFound
+	}
+
+	switch e.rec.Status {
+	case JobStatusQueued, JobStatusRetrying:
+		if e.timer != nil {
+			e.timer.Stop()
+			e.timer = nil
+		}
+		e.rec.NextRunAt = nil
+		q.setStatusLocked(e, JobStatusPaused)
+		return nil
+	case JobStatusRunning:
+		e.pauseRequested = true
+		if e.cancel != nil {
+			e.cancel()
+		}
+		return nil
+	case JobStatusPaused:
+		return nil
+	default:
+		return ErrJobStateConflict
+	}
+}
+
+// Resume 恢复已暂停的任务
+func (q *JobQueue) Resume(taskID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.entries[taskID]
+	if e == nil {
+		return ErrJobNotFound
+	}
+	if e.rec.Status != JobStatusPaused {
+		return ErrJobStateConflict
+	}
+	if q.stopped {
+		return ErrJobQueueStopped
+	}
+
+	q.setStatusLocked(e, JobStatusQueued)
+	q.enqueueLocked(e)
+	q.updateTracker(taskID, func(t *ProgressTracker) { t.Reopen("任务已恢复，等待执行...") })
+	return nil
+}
+
+// Get 返回任务当前状态
+func (q *JobQueue) Get(taskID string) (JobRecord, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.entries[taskID]
+	if e == nil {
+		return JobRecord{}, false
+	}
+	return e.rec, true
+}
+
+// List 返回所有任务（含最近结束的任务），按创建时间倒序
+func (q *JobQueue) List() []JobRecord {
+	q.mu.Lock()
+	out := make([]JobRecord, 0, len(q.entries))
+	for _, e := range q.entries {
+		out = append(out, e.rec)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out
+}
+
 func (q *JobQueue) Wait(ctx context.Context, taskID string) error {
-	q.mu.RLock()
-	st := q.tasks[taskID]
-	q.mu.RUnlock()
-	if st == nil {
+	q.mu.Lock()
+	e := q.entries[taskID]
+	q.mu.Unlock()
+	if e == nil {
 		return nil
 	}
 
 	select {
-	case <-st.done:
+	case <-e.done:
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
 
+// Stop 停止队列；运行中的持久化任务保持未完成状态，下次启动时恢复
 func (q *JobQueue) Stop() {
 	q.mu.Lock()
 	if q.stopped {
@@ -182,9 +516,227 @@ func (q *JobQueue) Stop() {
 		return
 	}
 	q.stopped = true
+	for _, e := range q.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+			e.timer = nil
+		}
+	}
+	q.cond.Broadcast()
 	q.mu.Unlock()
 
 	q.cancel()
-	close(q.jobs)
 	q.wg.Wait()
 }
+
+func (q *JobQueue) worker() {
+	defer q.wg.Done()
+
+	for {
+		q.mu.Lock()
+		e := q.nextLocked()
+		for e == nil && !q.stopped {
+			q.cond.Wait()
+			e = q.nextLocked()
+		}
+		if q.stopped {
+			q.mu.Unlock()
+			return
+		}
+
+		attemptCtx, attemptCancel := context.WithCancel(q.ctx)
+		e.cancel = attemptCancel
+		e.rec.Attempts++
+		e.rec.NextRunAt = nil
+		q.setStatusLocked(e, JobStatusRunning)
+		attemptCtx = context.WithValue(attemptCtx, jobAttemptKey{}, e.rec.Attempts)
+		fn := e.fn
+		q.mu.Unlock()
+
+		err := runJobFunc(attemptCtx, fn)
+		attemptCancel()
+
+		q.mu.Lock()
+		q.afterRunLocked(e, err)
+		q.mu.Unlock()
+	}
+}
+
+func runJobFunc(ctx context.Context, fn JobFunc) (err error) {
+	if fn == nil {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// afterRunLocked 根据执行结果决定任务去向：完成、暂停、取消、重试或失败
+func (q *JobQueue) afterRunLocked(e *jobEntry, err error) {
+	e.cancel = nil
+
+	switch {
+	case e.cancelRequested:
+		q.finishLocked(e, JobStatusCancelled, "cancelled")
+	case e.pauseRequested:
+		e.pauseRequested = false
+		q.setStatusLocked(e, JobStatusPaused)
+	case q.stopped:
+		// 服务关闭导致的中断：保留为排队状态，重启后恢复
+		e.rec.Status = JobStatusQueued
+		e.rec.UpdatedAt = time.Now()
+		q.persistLocked(e)
+	case err == nil:
+		q.finishLocked(e, JobStatusCompleted, "")
+	case errors.Is(err, context.Canceled) || e.rec.Attempts >= e.rec.MaxAttempts:
+		q.finishLocked(e, JobStatusFailed, err.Error())
+	default:
+		q.scheduleRetryLocked(e, err)
+	}
+}
+
+func (q *JobQueue) scheduleRetryLocked(e *jobEntry, err error) {
+	policy := q.policies[e.rec.Type]
+	delay := policy.Retry.backoff(e.rec.Attempts)
+	next := time.Now().Add(delay)
+
+	e.rec.LastError = err.Error()
+	e.rec.NextRunAt = &next
+	q.setStatusLocked(e, JobStatusRetrying)
+
+	taskID := e.rec.ID
+	e.timer = time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		cur := q.entries[taskID]
+		if cur != e || cur.rec.Status != JobStatusRetrying || q.stopped {
+			return
+		}
+		cur.timer = nil
+		q.setStatusLocked(cur, JobStatusQueued)
+		q.enqueueLocked(cur)
+	})
+
+	q.updateTracker(taskID, func(t *ProgressTracker) {
+		t.Reopen(fmt.Sprintf("第 %d 次尝试失败，%s 后重试: %v", e.rec.Attempts, delay.Round(time.Second), err))
+	})
+
+	utils.GetLogger().Warn("job failed, retry scheduled", map[string]interface{}{
+		"task_id":  taskID,
+		"type":     e.rec.Type,
+		"attempt":  e.rec.Attempts,
+		"delay_ms": delay.Milliseconds(),
+		"err":      err.Error(),
+	})
+}
+
+func (q *JobQueue) finishLocked(e *jobEntry, status JobStatus, lastError string) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	if lastError != "" {
+		e.rec.LastError = lastError
+	}
+	e.rec.NextRunAt = nil
+	e.rec.Status = status
+	e.rec.UpdatedAt = time.Now()
+	e.fn = nil
+
+	select {
+	case <-e.done:
+	default:
+		close(e.done)
+	}
+
+	q.removeJournal(e.rec.ID)
+	q.pruneHistoryLocked()
+}
+
+func (q *JobQueue) updateTracker(taskID string, fn func(t *ProgressTracker)) {
+	if q.Progress == nil {
+		return
+	}
+	if tracker, ok := q.Progress.GetTracker(taskID); ok {
+		fn(tracker)
+	}
+}
+
+func (q *JobQueue) setStatusLocked(e *jobEntry, status JobStatus) {
+	e.rec.Status = status
+	e.rec.UpdatedAt = time.Now()
+	q.persistLocked(e)
+}
+
+func (q *JobQueue) enqueueLocked(e *jobEntry) {
+	lane := int(e.rec.Priority) - 1
+	q.lanes[lane] = append(q.lanes[lane], e.rec.ID)
+	q.cond.Signal()
+}
+
+// nextLocked 按优先级取出下一个待执行任务，跳过已被取消/暂停的条目
+func (q *JobQueue) nextLocked() *jobEntry {
+	for lane := range q.lanes {
+		for len(q.lanes[lane]) > 0 {
+			id := q.lanes[lane][0]
+			q.lanes[lane] = q.lanes[lane][1:]
+			if e := q.entries[id]; e != nil && e.rec.Status == JobStatusQueued {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// pruneHistoryLocked 限制内存中保留的终态任务数量
+func (q *JobQueue) pruneHistoryLocked() {
+	var finished []*jobEntry
+	for _, e := range q.entries {
+		if e.rec.Status.terminal() {
+			finished = append(finished, e)
+		}
+	}
+	if len(finished) <= jobHistoryLimit {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].rec.UpdatedAt.Before(finished[j].rec.UpdatedAt)
+	})
+	for _, e := range finished[:len(finished)-jobHistoryLimit] {
+		delete(q.entries, e.rec.ID)
+	}
+}
+
+func (q *JobQueue) persistLocked(e *jobEntry) {
+	if q.journalDir == "" || !e.rec.Persistent {
+		return
+	}
+	data, err := json.MarshalIndent(e.rec, "", "  ")
+	if err != nil {
+		return
+	}
+	path := q.journalPath(e.rec.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		utils.GetLogger().Warn("write job journal failed", map[string]interface{}{"task_id": e.rec.ID, "err": err})
+	}
+}
+
+func (q *JobQueue) removeJournal(taskID string) {
+	if q.journalDir == "" {
+		return
+	}
+	if err := os.Remove(q.journalPath(taskID)); err != nil && !os.IsNotExist(err) {
+		utils.GetLogger().Warn("remove job journal failed", map[string]interface{}{"task_id": taskID, "err": err})
+	}
+}
+
+func (q *JobQueue) journalPath(taskID string) string {
+	return filepath.Join(q.journalDir, filepath.Base(taskID)+".json")
+}
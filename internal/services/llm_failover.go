@@ -0,0 +1,429 @@
+// internal/services/llm_failover.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
+	"github.com/Corphon/SceneIntruderMCP/internal/config"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+const (
+	// llmBreakerFailureThreshold 连续可重试失败达到该次数后熔断
+	llmBreakerFailureThreshold = 3
+	// llmBreakerCooldown 熔断后经过该时间允许一次探测请求
+	llmBreakerCooldown = 30 * time.Second
+)
+
+// 熔断器状态
+const (
+	LLMBreakerClosed   = "closed"
+	LLMBreakerOpen     = "open"
+	LLMBreakerHalfOpen = "half_open"
+)
+
+// llmBackend provider 链中的一个后端：主 provider 或按配置顺序排列的备用 provider
+type llmBackend struct {
+	position int // 0 为主 provider
+	name     string
+	provider llm.Provider
+	model    string // 备用后端固定使用的模型；主 provider 为空，沿用请求中的模型
+	breaker  *llmCircuitBreaker
+}
+
+// llmCircuitBreaker 单个后端的健康记录与熔断状态。方法允许 nil 接收者（视为始终可用）。
+type llmCircuitBreaker struct {
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openedAt            time.Time
+	probing             bool
+	requests            int64
+	failures            int64
+	lastError           string
+	lastFailureAt       time.Time
+	lastSuccessAt       time.Time
+}
+
+// LLMBackendHealth 描述 provider 链中一个后端的健康状态
+type LLMBackendHealth struct {
+	Position            int        `json:"position"`
+	Provider            string     `json:"provider"`
+	Model               string     `json:"model,omitempty"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+func newLLMCircuitBreaker() *llmCircuitBreaker {
+	return &llmCircuitBreaker{}
+}
+
+// allow 判断是否可以向该后端发送请求；冷却结束后只放行一个探测请求
+func (b *llmCircuitBreaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if now.Sub(b.openedAt) < llmBreakerCooldown || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *llmCircuitBreaker) recordSuccess(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.consecutiveFailures = 0
+	b.openedAt = time.Time{}
+	b.probing = false
+	b.lastSuccessAt = now
+}
+
+func (b *llmCircuitBreaker) recordFailure(now time.Time, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.failures++
+	b.consecutiveFailures++
+	b.lastFailureAt = now
+	if err != nil {
+		b.lastError = truncateText(err.Error(), 200)
+	}
+	// 探测失败立即重新熔断；否则达到阈值后熔断
+	if b.probing || b.consecutiveFailures >= llmBreakerFailureThreshold {
+		b.openedAt = now
+	}
+	b.probing = false
+}
+
+// release 请求没有给出健康结论（如调用方取消）时释放探测名额
+func (b *llmCircuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *llmCircuitBreaker) openedSince() time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.openedAt
+}
+
+func (b *llmCircuitBreaker) snapshot(now time.Time) LLMBackendHealth {
+	out := LLMBackendHealth{State: LLMBreakerClosed}
+	if b == nil {
+		return out
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out.ConsecutiveFailures = b.consecutiveFailures
+	out.Requests = b.requests
+	out.Failures = b.failures
+	out.LastError = b.lastError
+	if !b.lastFailureAt.IsZero() {
+		t := b.lastFailureAt
+		out.LastFailureAt = &t
+	}
+	if !b.lastSuccessAt.IsZero() {
+		t := b.lastSuccessAt
+		out.LastSuccessAt = &t
+	}
+	if !b.openedAt.IsZero() {
+		retryAt := b.openedAt.Add(llmBreakerCooldown)
+		out.RetryAt = &retryAt
+		out.State = LLMBreakerOpen
+		if !now.Before(retryAt) {
+			out.State = LLMBreakerHalfOpen
+		}
+	}
+	return out
+}
+
+// buildLLMFallbackBackends 按配置创建备用后端；单个备用初始化失败只记录日志并跳过
+func buildLLMFallbackBackends(fallbacks []config.LLMFallbackInfo) []*llmBackend {
+	backends := make([]*llmBackend, 0, len(fallbacks))
+	for _, fb := range fallbacks {
+		name := strings.TrimSpace(fb.Provider)
+		if !LLMConfigComplete(name, fb.Config) {
+			utils.GetLogger().Warn("skip llm fallback without api key", map[string]interface{}{"provider": name})
+			continue
+		}
+		provider, err := llm.GetProvider(name, fb.Config)
+		if err != nil {
+			utils.GetLogger().Warn("init llm fallback failed", map[string]interface{}{"provider": name, "err": err.Error()})
+			continue
+		}
+		model := strings.TrimSpace(fb.Model)
+		if model == "" {
+			model = extractDefaultModel(fb.Config)
+		}
+		if model == "" {
+			model = strings.TrimSpace(providerDefaultModels[name])
+		}
+		if model == "" {
+			if supported := provider.GetSupportedModels(); len(supported) > 0 {
+				model = strings.TrimSpace(supported[0])
+			}
+		}
+		backends = append(backends, &llmBackend{
+			position: len(backends) + 1,
+			name:     name,
+			provider: provider,
+			model:    model,
+			breaker:  newLLMCircuitBreaker(),
+		})
+	}
+	return backends
+}
+
+// UpdateFallbacks 替换备用 provider 链，备用后端的健康记录随之重置
+func (s *LLMService) UpdateFallbacks(fallbacks []config.LLMFallbackInfo) {
+	backends := buildLLMFallbackBackends(fallbacks)
+	s.providerMutex.Lock()
+	s.fallbacks = backends
+	s.providerMutex.Unlock()
+}
+
+// GetBackendHealth 返回主 provider 与各备用 provider 的健康状态
+func (s *LLMService) GetBackendHealth() []LLMBackendHealth {
+	now := time.Now()
+	chain := s.backendChain()
+	out := make([]LLMBackendHealth, 0, len(chain))
+	for _, b := range chain {
+		h := b.breaker.snapshot(now)
+		h.Position = b.position
+		h.Provider = b.name
+		h.Model = b.model
+		if b.position == 0 {
+			h.Model = s.resolveModel("")
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// backendChain 返回当前的 provider 链：主 provider（若就绪）在前，备用 provider 按配置顺序在后
+func (s *LLMService) backendChain() []*llmBackend {
+	s.providerMutex.RLock()
+	defer s.providerMutex.RUnlock()
+
+	chain := make([]*llmBackend, 0, 1+len(s.fallbacks))
+	if s.isReady && s.provider != nil {
+		chain = append(chain, &llmBackend{
+			name:     s.providerName,
+			provider: s.provider,
+			breaker:  s.primaryBreaker,
+		})
+	}
+	return append(chain, s.fallbacks...)
+}
+
+func (s *LLMService) notReadyError() error {
+	s.providerMutex.RLock()
+	defer s.providerMutex.RUnlock()
+	return fmt.Errorf("LLM service not ready: %s", s.readyState)
+}
+
+// completeText 沿 provider 链调用文本生成：可重试的错误切换到下一个后端，熔断中的后端被跳过。
+// 返回的 ProviderName / ModelName 为实际提供结果的后端。
+func (s *LLMService) completeText(ctx context.Context, req llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if err := s.checkUsageQuota(ctx); err != nil {
+		return nil, err
+	}
+	var resp *llm.CompletionResponse
+	err := s.callWithFailover(ctx, req, "complete", func(b *llmBackend, attemptReq llm.CompletionRequest) error {
+		r, err := b.provider.CompleteText(ctx, attemptReq)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return errors.New("empty completion response")
+		}
+		r.ProviderName = b.name
+		if strings.TrimSpace(r.ModelName) == "" {
+			r.ModelName = attemptReq.Model
+		}
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordCompletionUsage(ctx, req, resp)
+	return resp, nil
+}
+
+// streamCompletion 沿 provider 链建立流式调用；只在流开始前的错误上切换后端
+func (s *LLMService) streamCompletion(ctx context.Context, req llm.CompletionRequest) (<-chan llm.StreamResponse, error) {
+	var (
+		stream   <-chan llm.StreamResponse
+		provider string
+		model    string
+	)
+	if err := s.checkUsageQuota(ctx); err != nil {
+		return nil, err
+	}
+	err := s.callWithFailover(ctx, req, "stream", func(b *llmBackend, attemptReq llm.CompletionRequest) error {
+		ch, err := b.provider.StreamCompletion(ctx, attemptReq)
+		if err != nil {
+			return err
+		}
+		stream, provider, model = ch, b.name, attemptReq.Model
+		return nil
+	})
+	if err != nil {
+		return stream, err
+	}
+	return s.meterStream(ctx, req, provider, model, stream), nil
+}
+
+// callWithFailover 依次尝试 provider 链上的后端。上下文带有已配置路由的任务类别时，路由的后端排在链首。
+// 所有后端都处于熔断中时，仍探测最早熔断的一个，避免在冷却期内让请求直接失败。
+func (s *LLMService) callWithFailover(ctx context.Context, req llm.CompletionRequest, op string, call func(b *llmBackend, attemptReq llm.CompletionRequest) error) error {
+	chain := s.backendChain()
+	if route := s.resolveTaskRoute(ctx, req.Model); route != nil {
+		route.applyTo(&req)
+		routed := make([]*llmBackend, 0, len(chain)+1)
+		routed = append(routed, route.backend)
+		for _, b := range chain {
+			// 路由到主 provider 时不再以默认模型重试同一 provider
+			if b.provider == route.backend.provider && (b.position == 0 || b.model == route.model) {
+				continue
+			}
+			routed = append(routed, b)
+		}
+		chain = routed
+	}
+	if len(chain) == 0 {
+		return s.notReadyError()
+	}
+
+	var (
+		lastErr   error
+		attempted bool
+		skipped   []*llmBackend
+	)
+	// try 返回 true 表示得到了最终结果（成功，或不应再切换后端的错误）
+	try := func(b *llmBackend, failover bool) bool {
+		attempted = true
+		attemptReq := req
+		if b.model != "" {
+			attemptReq.Model = b.model
+		}
+		err := call(b, attemptReq)
+		now := time.Now()
+		s.recordLLMBackendStats(b, attemptReq.Model, failover, err)
+		if err == nil {
+			lastErr = nil
+			b.breaker.recordSuccess(now)
+			if failover {
+				utils.GetLogger().Info("llm request served by fallback", map[string]interface{}{"op": op, "provider": b.name, "model": attemptReq.Model, "position": b.position})
+			}
+			return true
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			b.breaker.release()
+			return true
+		}
+		if !isRetryableLLMError(err) {
+			// 后端给出了明确的非暂时性错误（如 400），说明服务可达，不计入熔断
+			b.breaker.recordSuccess(now)
+			return true
+		}
+		b.breaker.recordFailure(now, err)
+		utils.GetLogger().Warn("llm backend failed", map[string]interface{}{"op": op, "provider": b.name, "model": attemptReq.Model, "position": b.position, "err": err.Error()})
+		return false
+	}
+
+	done := false
+	for i, b := range chain {
+		if !b.breaker.allow(time.Now()) {
+			skipped = append(skipped, b)
+			continue
+		}
+		if done = try(b, i > 0); done {
+			break
+		}
+	}
+	if !done && !attempted && len(skipped) > 0 {
+		probe := skipped[0]
+		for _, b := range skipped[1:] {
+			if b.breaker.openedSince().Before(probe.breaker.openedSince()) {
+				probe = b
+			}
+		}
+		done = try(probe, probe != chain[0])
+	}
+
+	if done || lastErr == nil || len(chain) == 1 {
+		return lastErr
+	}
+	return fmt.Errorf("all LLM providers failed: %w", lastErr)
+}
+
+// recordLLMBackendStats 记录实际处理请求的后端；链首之后的后端成功提供结果时计为一次切换
+func (s *LLMService) recordLLMBackendStats(b *llmBackend, model string, failover bool, err error) {
+	if s.Stats == nil {
+		return
+	}
+	if statErr := s.Stats.RecordLLMRequest(b.name, model, failover, err); statErr != nil {
+		utils.GetLogger().Warn("record llm stats failed", map[string]interface{}{"err": statErr.Error()})
+	}
+}
+
+// isRetryableLLMError 判断错误是否值得换一个后端重试：只有网络/传输错误与带类型的
+// HTTP 408/425/429/5xx 可重试；其余错误默认不重试（其他 4xx、解析失败、调用方取消、用量超额、
+// 录制文件未命中等）。strict 回放未命中若切换到实时后端，离线测试就会悄悄变成真实调用。
+func isRetryableLLMError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrUsageQuotaExceeded) || errors.Is(err, cassette.ErrNoMatch) {
+		return false
+	}
+	if code := llm.HTTPStatusCode(err); code != 0 {
+		return code == 408 || code == 425 || code == 429 || code >= 500
+	}
+	// 传输层错误：连接失败、超时、连接被重置或响应体读取中断
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
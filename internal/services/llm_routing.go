@@ -0,0 +1,202 @@
+// internal/services/llm_routing.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/config"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+)
+
+// LLM 任务类别。服务在调用 LLM 前用 WithLLMTask 标记上下文，LLMService 据此查路由表；
+// 未标记或未配置路由的调用使用主提供商的默认模型。
+const (
+	LLMTaskAnalysis = "analysis" // 文本分析、场景/角色/物品抽取
+	LLMTaskChat     = "chat"     // 角色对话、情绪回复、记忆提炼
+	LLMTaskStory    = "story"    // 故事续写、选项生成与场景指令
+	LLMTaskComic    = "comic"    // 漫画分镜、提示词与关键元素
+	LLMTaskScript   = "script"   // 剧本大纲与正文起草
+)
+
+// LLMTaskClasses 所有可路由的任务类别
+var LLMTaskClasses = []string{LLMTaskAnalysis, LLMTaskChat, LLMTaskStory, LLMTaskComic, LLMTaskScript}
+
+// ErrInvalidLLMRoute 表示路由使用了未知的任务类别，或指向不在主提供商/备用链中的提供商
+var ErrInvalidLLMRoute = errors.New("invalid llm route")
+
+type llmTaskKey struct{}
+
+// WithLLMTask 在上下文中标记本次 LLM 调用的任务类别
+func WithLLMTask(ctx context.Context, task string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, llmTaskKey{}, task)
+}
+
+// LLMTaskFromContext 返回上下文中标记的任务类别，未标记时为空
+func LLMTaskFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	task, _ := ctx.Value(llmTaskKey{}).(string)
+	return task
+}
+
+// LLMRouteStatus 描述一条路由及其当前是否可用
+type LLMRouteStatus struct {
+	Task        string   `json:"task"`
+	Provider    string   `json:"provider"`
+	Model       string   `json:"model"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Active      bool     `json:"active"`
+}
+
+// llmResolvedRoute 一次调用实际使用的路由：链首后端、模型与采样参数
+type llmResolvedRoute struct {
+	backend     *llmBackend
+	model       string
+	temperature *float64
+	maxTokens   int
+}
+
+// ValidateLLMRoutes 检查任务类别名，以及路由的提供商是否为主提供商或备用提供商之一
+func ValidateLLMRoutes(routes map[string]config.LLMRouteInfo, cfg *config.AppConfig) error {
+	for task, route := range routes {
+		if !slices.Contains(LLMTaskClasses, task) {
+			return fmt.Errorf("%w: unknown task class %q (%s)", ErrInvalidLLMRoute, task, strings.Join(LLMTaskClasses, " / "))
+		}
+		provider := strings.TrimSpace(route.Provider)
+		if provider == "" || cfg == nil || provider == cfg.LLMProvider {
+			continue
+		}
+		found := false
+		for _, fb := range cfg.LLMFallbacks {
+			if fb.Provider == provider {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s routes to %q, which is neither the primary provider nor a fallback", ErrInvalidLLMRoute, task, provider)
+		}
+	}
+	return nil
+}
+
+// UpdateRoutes 替换按任务类别的路由表
+func (s *LLMService) UpdateRoutes(routes map[string]config.LLMRouteInfo) {
+	copied := make(map[string]config.LLMRouteInfo, len(routes))
+	for k, v := range routes {
+		copied[k] = v
+	}
+	s.providerMutex.Lock()
+	s.routes = copied
+	s.providerMutex.Unlock()
+}
+
+// GetRoutes 返回路由表；Active=false 表示路由的提供商当前不可用，调用回到默认模型
+func (s *LLMService) GetRoutes() []LLMRouteStatus {
+	s.providerMutex.RLock()
+	tasks := make([]string, 0, len(s.routes))
+	for task := range s.routes {
+		tasks = append(tasks, task)
+	}
+	s.providerMutex.RUnlock()
+	slices.Sort(tasks)
+
+	out := make([]LLMRouteStatus, 0, len(tasks))
+	for _, task := range tasks {
+		s.providerMutex.RLock()
+		info := s.routes[task]
+		s.providerMutex.RUnlock()
+
+		status := LLMRouteStatus{Task: task, Provider: info.Provider, Model: info.Model, Temperature: info.Temperature, MaxTokens: info.MaxTokens}
+		if route := s.resolveTaskRoute(WithLLMTask(context.Background(), task), ""); route != nil {
+			status.Provider = route.backend.name
+			status.Model = route.model
+			status.Active = true
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// modelForTask 返回本次调用将使用的模型（用于缓存键），没有路由时原样返回
+func (s *LLMService) modelForTask(ctx context.Context, model string) string {
+	if route := s.resolveTaskRoute(ctx, model); route != nil {
+		return route.model
+	}
+	return model
+}
+
+// resolveTaskRoute 按上下文中的任务类别查找路由。
+// 调用方显式指定了非默认模型（如用户偏好的模型）时不走路由；路由的提供商不可用时返回 nil。
+func (s *LLMService) resolveTaskRoute(ctx context.Context, requestedModel string) *llmResolvedRoute {
+	task := LLMTaskFromContext(ctx)
+	if task == "" {
+		return nil
+	}
+	defaultModel := s.resolveModel("")
+	requested := strings.TrimSpace(requestedModel)
+	if requested != "" && requested != defaultModel {
+		return nil
+	}
+
+	s.providerMutex.RLock()
+	defer s.providerMutex.RUnlock()
+
+	info, ok := s.routes[task]
+	if !ok {
+		return nil
+	}
+	route := &llmResolvedRoute{temperature: info.Temperature, maxTokens: info.MaxTokens}
+	provider := strings.TrimSpace(info.Provider)
+	if provider == "" || provider == s.providerName {
+		if !s.isReady || s.provider == nil {
+			return nil
+		}
+		route.backend = &llmBackend{name: s.providerName, provider: s.provider, breaker: s.primaryBreaker}
+		route.model = strings.TrimSpace(info.Model)
+		if route.model == "" {
+			route.model = defaultModel
+		}
+	} else {
+		for _, fb := range s.fallbacks {
+			if fb.name != provider {
+				continue
+			}
+			route.backend = &llmBackend{name: fb.name, provider: fb.provider, breaker: fb.breaker}
+			route.model = strings.TrimSpace(info.Model)
+			if route.model == "" {
+				route.model = fb.model
+			}
+			break
+		}
+		if route.backend == nil {
+			return nil
+		}
+	}
+	if route.model == "" {
+		return nil
+	}
+	route.backend.model = route.model
+	return route
+}
+
+// applyTo 把路由的模型写入请求；路由显式设置的采样参数覆盖调用方的取值
+// （调用方多为硬编码的通用默认值，路由是管理员针对该任务类别的配置）
+func (r *llmResolvedRoute) applyTo(req *llm.CompletionRequest) {
+	req.Model = r.model
+	if r.temperature != nil {
+		req.Temperature = float32(*r.temperature)
+	}
+	if r.maxTokens > 0 {
+		req.MaxTokens = r.maxTokens
+	}
+}
@@ -37,6 +37,7 @@ var providerDefaultModels = map[string]string{
 	"githubmodels": "gpt-4.1-mini",
 	"grok":         "grok-4.1-fast",
 	"openrouter":   "x-ai/grok-4.1-fast:free",
+	"ollama":       "llama3.1",
 }
 
 // LLMService 提供统一的大语言模型调用接口
@@ -48,6 +49,16 @@ type LLMService struct {
 	isReady            bool
 	readyState         string
 	activeDefaultModel string
+
+	// 备用 provider 链与各后端的熔断状态，见 llm_failover.go
+	primaryBreaker *llmCircuitBreaker
+	fallbacks      []*llmBackend
+	// 按任务类别的模型路由，见 llm_routing.go
+	routes map[string]config.LLMRouteInfo
+
+	Stats *StatsService
+	// Usage 按用户/场景/任务记录 token 用量与费用，见 usage_service.go
+	Usage *UsageService
 }
 type LLMCache struct {
 	cache      map[string]*CacheEntry
@@ -155,7 +166,7 @@ func NewLLMService() (*LLMService, error) {
 		return service, nil
 	}
 
-	if cfg.LLMProvider == "" || (cfg.LLMConfig != nil && cfg.LLMConfig["api_key"] == "") {
+	if !LLMConfigComplete(cfg.LLMProvider, cfg.LLMConfig) {
 		service.readyState = "API key not configured"
 		return service, nil
 	}
@@ -171,6 +182,8 @@ func NewLLMService() (*LLMService, error) {
 	service.provider = provider
 	service.providerName = cfg.LLMProvider
 	service.activeDefaultModel = extractDefaultModel(cfg.LLMConfig)
+	service.fallbacks = buildLLMFallbackBackends(cfg.LLMFallbacks)
+	service.routes = cfg.LLMRoutes
 	service.isReady = true
 	service.readyState = "Ready"
 
@@ -193,6 +206,7 @@ func createBaseLLMService() *LLMService {
 		isReady:            false,
 		readyState:         "Uninitialized",
 		activeDefaultModel: "",
+		primaryBreaker:     newLLMCircuitBreaker(),
 		cache: &LLMCache{
 			cache:      make(map[string]*CacheEntry),
 			mutex:      sync.RWMutex{},
@@ -222,13 +236,9 @@ func (s *LLMService) IsReady() bool {
 		return false
 	}
 
-	// Check if API key is available in the current config
+	// Check if API key is available in the current config (local providers don't need one)
 	// The LLMConfig from GetCurrentConfig should already include decrypted API key
-	if cfg.LLMConfig == nil || cfg.LLMConfig["api_key"] == "" {
-		return false
-	}
-
-	return true
+	return LLMConfigComplete(cfg.LLMProvider, cfg.LLMConfig)
 }
 
 // GetReadyState 返回服务就绪状态描述
@@ -246,9 +256,9 @@ func (s *LLMService) GetReadyState() string {
 		return "LLM provider not configured"
 	}
 
-	// Check if API key is available in the current config
+	// Check if API key is available in the current config (local providers don't need one)
 	// The LLMConfig from GetCurrentConfig should already include decrypted API key
-	if cfg.LLMConfig == nil || cfg.LLMConfig["api_key"] == "" {
+	if !LLMConfigComplete(cfg.LLMProvider, cfg.LLMConfig) {
 		return "API key not configured"
 	}
 
@@ -289,6 +299,7 @@ func (s *LLMService) UpdateProvider(providerName string, config map[string]strin
 	s.provider = provider
 	s.providerName = providerName
 	s.activeDefaultModel = extractDefaultModel(config)
+	s.primaryBreaker = newLLMCircuitBreaker()
 	s.isReady = true
 	s.readyState = "Ready"
 
@@ -401,8 +412,8 @@ func (s *LLMService) CreateChatCompletion(ctx context.Context, request ChatCompl
 	// 解析需要使用的模型
 	resolvedModel := s.resolveModel(request.Model)
 
-	// 生成缓存键
-	cacheKey := s.generateCacheKey(userContent, systemContent, resolvedModel)
+	// 生成缓存键（按任务路由后的实际模型区分）
+	cacheKey := s.generateCacheKey(userContent, systemContent, s.modelForTask(ctx, resolvedModel))
 
 	// 检查缓存
 	if s.cache != nil {
@@ -424,15 +435,15 @@ func (s *LLMService) CreateChatCompletion(ctx context.Context, request ChatCompl
 	req.SystemPrompt = systemContent
 	req.Prompt = userContent
 
-	// 调用实际Provider
-	resp, err := s.provider.CompleteText(ctx, req)
+	// 调用实际Provider（主 provider 不可用时切换到备用 provider）
+	resp, err := s.completeText(ctx, req)
 	if err != nil {
 		return ChatCompletionResponse{}, err
 	}
 
 	// 转换为旧格式的响应
 	result := ChatCompletionResponse{
-		ID: resp.ModelName + "-" + s.providerName,
+		ID: resp.ModelName + "-" + resp.ProviderName,
 		Choices: []ChatCompletionChoice{
 			{
 				Message: ChatCompletionMessage{
@@ -468,12 +479,18 @@ func (s *LLMService) CreateStructuredCompletionWithMetrics(ctx context.Context,
 		s.providerMutex.RUnlock()
 		return nil, 0, false, fmt.Errorf("LLM service not ready: %s", s.readyState)
 	}
-	provider := s.provider
+	providerName := s.providerName
 	s.providerMutex.RUnlock()
 
-	model := s.resolveModel("")
+	// requestModel 为默认模型，路由在 completeText 中按任务类别替换；model 为实际使用的模型
+	requestModel := s.resolveModel("")
+	model := requestModel
+	if route := s.resolveTaskRoute(ctx, requestModel); route != nil {
+		model = route.model
+		providerName = route.backend.name
+	}
 
-	// 生成缓存键
+	// 生成缓存键（按任务路由后的实际模型区分）
 	cacheKey := s.generateCacheKey(prompt, systemPrompt, model)
 
 	// 检查缓存
@@ -484,7 +501,7 @@ func (s *LLMService) CreateStructuredCompletionWithMetrics(ctx context.Context,
 			PromptTokens: 0,
 			OutputTokens: 0,
 			ModelName:    model,
-			ProviderName: s.providerName,
+			ProviderName: providerName,
 		}, 0, true, nil
 	}
 
@@ -494,34 +511,28 @@ func (s *LLMService) CreateStructuredCompletionWithMetrics(ctx context.Context,
 		structuredSystemPrompt += "\n\n"
 	}
 	structuredSystemPrompt += "Return your response in valid JSON format, following the provided output schema, without adding explanations or preambles."
+	structuredSystemPrompt += structuredSchemaPrompt(outputSchema)
 
 	req := llm.CompletionRequest{
 		Prompt:       prompt,
 		SystemPrompt: structuredSystemPrompt,
 		Temperature:  0.3,
-		Model:        model,
+		Model:        requestModel,
 	}
 
-	// 调用实际Provider
+	// 调用实际Provider，按输出类型的 JSON Schema 校验，失败时带着问题列表重新提示
 	callStart := time.Now()
-	resp, err = provider.CompleteText(ctx, req)
+	resp, validated, err := s.completeStructured(ctx, req, outputSchema)
 	callDuration = time.Since(callStart)
 	if err != nil {
-		return nil, callDuration, false, err
+		return resp, callDuration, false, err
 	}
 
-	// 尝试解析结构化输出
-	text := cleanJSONString(resp.Text)
-
-	// 解析JSON到提供的结构中
-	err = json.Unmarshal([]byte(text), outputSchema)
-	if err != nil {
-		return resp, callDuration, false, fmt.Errorf("failed to parse AI response into structured data: %w\nAI return: %s", err, text)
+	// 只缓存通过校验的结果
+	if validated {
+		s.saveToCache(cacheKey, outputSchema)
 	}
 
-	// 保存到缓存
-	s.saveToCache(cacheKey, outputSchema)
-
 	return resp, callDuration, false, nil
 }
 
@@ -531,6 +542,23 @@ func (s *LLMService) CreateStructuredCompletion(ctx context.Context, prompt stri
 	return err
 }
 
+// CreateStreamingCompletion 以流式方式调用当前Provider；流开始前失败时按 provider 链切换。
+// 通道中 Done=false 的消息为增量文本，最后一条 Done=true 的消息携带结束原因（多数Provider同时给出完整文本）。
+// 流式结果不写入缓存。
+func (s *LLMService) CreateStreamingCompletion(ctx context.Context, req llm.CompletionRequest) (<-chan llm.StreamResponse, error) {
+	s.providerMutex.RLock()
+	if !s.isReady || s.provider == nil {
+		s.providerMutex.RUnlock()
+		return nil, fmt.Errorf("LLM service not ready: %s", s.readyState)
+	}
+	s.providerMutex.RUnlock()
+
+	req.Model = s.resolveModel(req.Model)
+	req.Stream = true
+
+	return s.streamCompletion(ctx, req)
+}
+
 // 清理JSON字符串，去除前后非JSON内容
 var jsonNoiseReplacer = strings.NewReplacer(
 	"```json", "",
@@ -1066,53 +1094,25 @@ Formatting requirements:
 		Temperature:  0.2,
 	}
 
-	cacheKey := s.GenerateCacheKey(request)
+	// 单个对象会被包装为数组；校验失败时由 completeStructured 重新提示
+	var characters []CharacterInfo
+	cacheKey := s.generateCacheKey(request.Prompt, request.SystemPrompt, s.modelForTask(ctx, request.Model))
 	if cachedResp := s.CheckCache(cacheKey); cachedResp != nil {
-		cleanedText := cleanJSONString(cachedResp.Text)
-		// 尝试解析为数组格式
-		var characters []CharacterInfo
-		err := json.Unmarshal([]byte(cleanedText), &characters)
-		if err == nil {
+		if _, decoded := decodeStructuredOutput(cleanJSONString(cachedResp.Text), llm.SchemaFor(&characters), &characters); decoded {
 			return characters, nil
 		}
-
-		// 如果解析数组失败，尝试解析为单个对象
-		var singleCharacter CharacterInfo
-		err = json.Unmarshal([]byte(cleanedText), &singleCharacter)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse cached AI response into structured data: %w\nAI return: %s",
-				err, truncateText(cachedResp.Text, 120))
-		}
-
-		// 将单个对象添加到数组中
-		return []CharacterInfo{singleCharacter}, nil
 	}
 
-	response, err := s.provider.CompleteText(ctx, request)
+	response, validated, err := s.completeStructured(ctx, request, &characters)
 	if err != nil {
 		return nil, err
 	}
-	// 添加到缓存
-	s.AddToCache(cacheKey, response)
-
-	cleanedText := cleanJSONString(response.Text)
-	// 尝试解析为数组格式
-	var characters []CharacterInfo
-	err = json.Unmarshal([]byte(cleanedText), &characters)
-	if err == nil {
-		return characters, nil
-	}
-
-	// 如果解析数组失败，尝试解析为单个对象
-	var singleCharacter CharacterInfo
-	err = json.Unmarshal([]byte(cleanedText), &singleCharacter)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response into structured data: %w\nAI return: %s",
-			err, truncateText(response.Text, 120))
+	// 只缓存通过校验的 JSON
+	if validated {
+		s.AddToCache(cacheKey, response)
 	}
 
-	// 将单个对象添加到数组中
-	return []CharacterInfo{singleCharacter}, nil
+	return characters, nil
 }
 
 // 用于提取场景信息
@@ -1190,53 +1190,25 @@ Formatting requirements:
 		Temperature:  0.2,
 	}
 
-	cacheKey := s.GenerateCacheKey(request)
+	// 单个对象会被包装为数组；校验失败时由 completeStructured 重新提示
+	var scenes []SceneInfo
+	cacheKey := s.generateCacheKey(request.Prompt, request.SystemPrompt, s.modelForTask(ctx, request.Model))
 	if cachedResp := s.CheckCache(cacheKey); cachedResp != nil {
-		cleanedText := cleanJSONString(cachedResp.Text)
-		// 尝试解析为数组格式
-		var scenes []SceneInfo
-		err := json.Unmarshal([]byte(cleanedText), &scenes)
-		if err == nil {
+		if _, decoded := decodeStructuredOutput(cleanJSONString(cachedResp.Text), llm.SchemaFor(&scenes), &scenes); decoded {
 			return scenes, nil
 		}
-
-		// 如果解析数组失败，尝试解析为单个对象
-		var singleScene SceneInfo
-		err = json.Unmarshal([]byte(cleanedText), &singleScene)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse cached AI response into structured data: %w\nAI return: %s",
-				err, truncateText(cachedResp.Text, 120))
-		}
-
-		// 将单个对象添加到数组中
-		return []SceneInfo{singleScene}, nil
 	}
 
-	response, err := s.provider.CompleteText(ctx, request)
+	response, validated, err := s.completeStructured(ctx, request, &scenes)
 	if err != nil {
 		return nil, err
 	}
-	// 添加到缓存
-	s.AddToCache(cacheKey, response)
-
-	cleanedText := cleanJSONString(response.Text)
-	// 尝试解析为数组格式
-	var scenes []SceneInfo
-	err = json.Unmarshal([]byte(cleanedText), &scenes)
-	if err == nil {
-		return scenes, nil
-	}
-
-	// 如果解析数组失败，尝试解析为单个对象
-	var singleScene SceneInfo
-	err = json.Unmarshal([]byte(cleanedText), &singleScene)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response into structured data: %w\nAI return: %s",
-			err, truncateText(response.Text, 120))
+	// 只缓存通过校验的 JSON
+	if validated {
+		s.AddToCache(cacheKey, response)
 	}
 
-	// 将单个对象添加到数组中
-	return []SceneInfo{singleScene}, nil
+	return scenes, nil
 }
 
 // GenerateCacheKey 为请求生成缓存键
@@ -1425,6 +1397,17 @@ func (s *LLMService) resolveModel(requestedModel string) string {
 	return "gpt-4.1"
 }
 
+// LLMConfigComplete 判断提供商配置是否可用：需要密钥的提供商必须配置 api_key
+func LLMConfigComplete(provider string, cfg map[string]string) bool {
+	if strings.TrimSpace(provider) == "" {
+		return false
+	}
+	if !llm.RequiresAPIKey(provider) {
+		return true
+	}
+	return cfg != nil && cfg["api_key"] != ""
+}
+
 func extractDefaultModel(cfg map[string]string) string {
 	if cfg == nil {
 		return ""
@@ -0,0 +1,208 @@
+// internal/services/llm_structured.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+const (
+	// maxStructuredRepairAttempts 结构化输出校验失败后最多重新提示的次数
+	maxStructuredRepairAttempts = 2
+	// structuredRepairEchoLimit 修复提示中回显上一次输出的最大字符数
+	structuredRepairEchoLimit = 4000
+	// structuredRepairListLimit 修复提示中列出的问题条数上限
+	structuredRepairListLimit = 10
+)
+
+// ErrStructuredOutputInvalid is returned when the model output still fails schema validation after all repair attempts.
+var ErrStructuredOutputInvalid = errors.New("structured output does not match the expected schema")
+
+// completeStructured 调用 LLM 并按 out 的类型生成的 JSON Schema 校验结果：
+// 请求携带 ResponseFormat，支持原生 JSON 模式的提供者会直接约束输出；
+// JSON 无法解析或字段类型不符时，把问题列表和上一次输出附在提示后重新请求，最多 maxStructuredRepairAttempts 次。
+// 只缺少部分字段的结果直接采用（模型常省略空字段，为此重试不划算）。
+// 返回的响应累计了所有尝试的 token 用量，Text 为最终采用的 JSON。
+// validated=false 表示重试用尽后宽松解码采用的结果：可以返回给调用方，但不应写入缓存。
+func (s *LLMService) completeStructured(ctx context.Context, req llm.CompletionRequest, out interface{}) (resp *llm.CompletionResponse, validated bool, err error) {
+	if rv := reflect.ValueOf(out); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, false, fmt.Errorf("structured output target must be a non-nil pointer, got %T", out)
+	}
+
+	schema := llm.SchemaFor(out)
+	req.ResponseFormat = &llm.ResponseFormat{Name: llm.SchemaName(out), Schema: schema}
+	basePrompt := req.Prompt
+
+	total := &llm.CompletionResponse{}
+	var lastText string
+	var lastViolations []llm.SchemaViolation
+	for attempt := 0; attempt <= maxStructuredRepairAttempts; attempt++ {
+		if attempt > 0 {
+			req.Prompt = buildStructuredRepairPrompt(basePrompt, lastText, lastViolations)
+		}
+
+		resp, err := s.completeStructuredAttempt(ctx, &req)
+		if err != nil {
+			if attempt == 0 {
+				return nil, false, err
+			}
+			return total, false, err
+		}
+		total.TokensUsed += resp.TokensUsed
+		total.PromptTokens += resp.PromptTokens
+		total.OutputTokens += resp.OutputTokens
+		total.FinishReason = resp.FinishReason
+		total.ModelName = resp.ModelName
+		total.ProviderName = resp.ProviderName
+
+		lastText = cleanJSONString(resp.Text)
+		violations, decoded := decodeStructuredOutput(lastText, schema, out)
+		if decoded {
+			total.Text = lastText
+			return total, true, nil
+		}
+		lastViolations = violations
+
+		utils.GetLogger().Warn("structured output failed schema validation", map[string]interface{}{
+			"attempt":    attempt + 1,
+			"provider":   resp.ProviderName,
+			"model":      resp.ModelName,
+			"violations": len(violations),
+			"first":      violations[0].String(),
+		})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// 重试用尽：旧逻辑能解码的结果（例如嵌套对象的键全部对不上）仍然采用，只记录警告；
+	// 这类结果标记为未通过校验，调用方不得缓存，以免后续命中缓存时一直拿到同一份坏结果
+	if len(lastViolations) > 0 && decodeStructuredOutputLenient(lastText, schema, out) {
+		utils.GetLogger().Warn("accepting structured output that failed schema validation", map[string]interface{}{
+			"violations": describeSchemaViolations(lastViolations, 3),
+		})
+		total.Text = lastText
+		return total, false, nil
+	}
+	return total, false, fmt.Errorf("failed to parse AI response into structured data: %w: %s\nAI return: %s",
+		ErrStructuredOutputInvalid, describeSchemaViolations(lastViolations, 3), truncateText(lastText, 200))
+}
+
+// completeStructuredAttempt 发起一次调用；提供者以 400/422 拒绝原生 JSON 模式时，去掉 ResponseFormat 后重试一次
+func (s *LLMService) completeStructuredAttempt(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	resp, err := s.completeText(ctx, *req)
+	if err == nil || req.ResponseFormat == nil || !isResponseFormatRejected(err) {
+		return resp, err
+	}
+	utils.GetLogger().Warn("native JSON mode rejected, retrying with prompt-only JSON", map[string]interface{}{"err": err.Error()})
+	req.ResponseFormat = nil
+	return s.completeText(ctx, *req)
+}
+
+func isResponseFormatRejected(err error) bool {
+	code := llm.HTTPStatusCode(err)
+	return code == 400 || code == 422
+}
+
+// decodeStructuredOutput 校验并解码已清洗的 JSON 文本。
+// 根节点应为数组而模型只返回单个对象时自动包装为数组。
+// decoded=true 表示 out 已被替换为新结果，此时 violations 中只有缺少字段的提示。
+func decodeStructuredOutput(text string, schema map[string]interface{}, out interface{}) (violations []llm.SchemaViolation, decoded bool) {
+	value, err := llm.DecodeJSONValue([]byte(text))
+	if err != nil {
+		return []llm.SchemaViolation{{Path: "$", Message: "invalid JSON: " + err.Error()}}, false
+	}
+	if obj, ok := value.(map[string]interface{}); ok && llm.SchemaRootType(schema) == "array" {
+		value = []interface{}{obj}
+	}
+
+	violations = llm.ValidateJSON(schema, value)
+	// 结构性问题排在缺少字段的提示之前，修复提示与日志优先展示它们
+	sort.SliceStable(violations, func(i, j int) bool { return !violations[i].Missing && violations[j].Missing })
+	if len(violations) > 0 && !violations[0].Missing {
+		return violations, false
+	}
+
+	if err := replaceStructuredOutput(value, out); err != nil {
+		return append(violations, llm.SchemaViolation{Path: "$", Message: err.Error()}), false
+	}
+	return violations, true
+}
+
+// decodeStructuredOutputLenient 不做 schema 校验，只要 json.Unmarshal 成功即写入 out
+func decodeStructuredOutputLenient(text string, schema map[string]interface{}, out interface{}) bool {
+	value, err := llm.DecodeJSONValue([]byte(text))
+	if err != nil || value == nil {
+		return false
+	}
+	if obj, ok := value.(map[string]interface{}); ok && llm.SchemaRootType(schema) == "array" {
+		value = []interface{}{obj}
+	}
+	return replaceStructuredOutput(value, out) == nil
+}
+
+// replaceStructuredOutput 解码到新值再整体替换 out，避免重试之间残留上一次的字段
+func replaceStructuredOutput(value interface{}, out interface{}) error {
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	target := reflect.New(reflect.TypeOf(out).Elem())
+	if err := json.Unmarshal(normalized, target.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(out).Elem().Set(target.Elem())
+	return nil
+}
+
+// buildStructuredRepairPrompt 在原始提示后附上校验问题与上一次输出，要求模型返回修正后的完整 JSON
+func buildStructuredRepairPrompt(basePrompt, previous string, violations []llm.SchemaViolation) string {
+	var b strings.Builder
+	b.WriteString(basePrompt)
+	b.WriteString("\n\n---\nYour previous response could not be used because it does not match the required JSON schema:\n")
+	for i, v := range violations {
+		if i >= structuredRepairListLimit {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(violations)-i)
+			break
+		}
+		b.WriteString("- ")
+		b.WriteString(v.String())
+		b.WriteString("\n")
+	}
+	if previous = strings.TrimSpace(previous); previous != "" {
+		b.WriteString("\nPrevious response:\n")
+		b.WriteString(truncateText(previous, structuredRepairEchoLimit))
+		b.WriteString("\n")
+	}
+	b.WriteString("\nReturn the complete corrected JSON only, without explanations or Markdown fences.")
+	return b.String()
+}
+
+func describeSchemaViolations(violations []llm.SchemaViolation, limit int) string {
+	parts := make([]string, 0, limit)
+	for i, v := range violations {
+		if i >= limit {
+			parts = append(parts, fmt.Sprintf("... (%d more)", len(violations)-i))
+			break
+		}
+		parts = append(parts, v.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// structuredSchemaPrompt 返回附加在系统提示后的 JSON Schema 说明，供不支持原生 JSON 模式的提供者使用
+func structuredSchemaPrompt(out interface{}) string {
+	data, err := json.Marshal(llm.SchemaFor(out))
+	if err != nil || string(data) == "{}" {
+		return ""
+	}
+	return "\n\nJSON Schema of the expected output:\n" + string(data)
+}
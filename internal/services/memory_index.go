@@ -0,0 +1,156 @@
+// internal/services/memory_index.go
+package services
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 参数：k1 控制词频饱和速度，b 控制文档长度归一化强度
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// memoryStopwords 检索时忽略的常见英文虚词（中文按双字切分，单字虚词不会单独成词）
+var memoryStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "were": true, "you": true,
+	"your": true, "that": true, "this": true, "with": true, "have": true, "has": true, "had": true,
+	"not": true, "but": true, "what": true, "who": true, "how": true, "can": true, "will": true,
+	"about": true, "from": true, "they": true, "them": true, "his": true, "her": true, "she": true,
+	"him": true, "its": true, "our": true, "did": true, "does": true, "been": true, "into": true,
+}
+
+// memoryTokens 把文本切分为检索词：西文按单词（小写、去停用词），
+// 连续的中日韩字符按双字切分，孤立的单个汉字保留为单字
+func memoryTokens(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) >= 2 {
+			w := string(word)
+			if !memoryStopwords[w] {
+				tokens = append(tokens, w)
+			}
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// bm25Index 一组记忆文档上的 BM25 词法索引；记忆数量有上限，检索时现建即可
+type bm25Index struct {
+	docs   []map[string]int
+	lens   []int
+	df     map[string]int
+	avgLen float64
+}
+
+func newBM25Index(texts []string) *bm25Index {
+	idx := &bm25Index{
+		docs: make([]map[string]int, len(texts)),
+		lens: make([]int, len(texts)),
+		df:   make(map[string]int),
+	}
+	total := 0
+	for i, text := range texts {
+		tf := make(map[string]int)
+		tokens := memoryTokens(text)
+		for _, t := range tokens {
+			tf[t]++
+		}
+		for t := range tf {
+			idx.df[t]++
+		}
+		idx.docs[i] = tf
+		idx.lens[i] = len(tokens)
+		total += len(tokens)
+	}
+	if len(texts) > 0 {
+		idx.avgLen = float64(total) / float64(len(texts))
+	}
+	return idx
+}
+
+// Scores 返回查询对每个文档的 BM25 得分（与 texts 顺序一致）
+func (idx *bm25Index) Scores(query string) []float64 {
+	scores := make([]float64, len(idx.docs))
+	if len(idx.docs) == 0 || idx.avgLen == 0 {
+		return scores
+	}
+
+	seen := make(map[string]bool)
+	n := float64(len(idx.docs))
+	for _, term := range memoryTokens(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		df := idx.df[term]
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+		for i, tf := range idx.docs {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(idx.lens[i])/idx.avgLen
+			scores[i] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+	return scores
+}
+
+// tokenSimilarity 两段文本检索词集合的 Jaccard 相似度，用于判断是否为重复记忆
+func tokenSimilarity(a, b string) float64 {
+	setA := make(map[string]bool)
+	for _, t := range memoryTokens(a) {
+		setA[t] = true
+	}
+	setB := make(map[string]bool)
+	for _, t := range memoryTokens(b) {
+		setB[t] = true
+	}
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+	inter := 0
+	for t := range setA {
+		if setB[t] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(setA)+len(setB)-inter)
+}
@@ -0,0 +1,629 @@
+// internal/services/memory_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/storage"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+const (
+	// 每个角色最多保留的记忆条数，超出时淘汰重要性低且久未访问的记忆
+	maxMemoriesPerCharacter = 200
+	// 默认注入提示词的记忆条数
+	defaultMemoryTopK = 5
+	// 时间衰减半衰期：三天未被提起的记忆权重减半
+	memoryHalfLife = 72 * time.Hour
+	// 访问时间先记在内存中，按该间隔批量写回，避免每轮对话都重写记忆文件
+	memoryAccessFlushInterval = 5 * time.Minute
+	// 检索词集合相似度超过该值视为同一条记忆
+	duplicateMemorySimilarity = 0.6
+	// 每轮对话最多提炼的记忆条数
+	maxDistilledPerTurn  = 3
+	memoryDistillTimeout = 60 * time.Second
+	// 同时进行的后台提炼任务上限，超出时跳过本轮提炼
+	maxConcurrentDistills = 4
+)
+
+var ErrMemoryNotFound = errors.New("记忆不存在")
+
+// MemoryService 管理角色长期记忆：每轮对话后由 LLM 提炼值得记住的事实，
+// 检索时综合 BM25 相关度、重要性与时间衰减选出最相关的若干条。
+// 记忆按角色存放在 <scene>/memories/<character>.json，随场景一起删除。
+type MemoryService struct {
+	ScenesPath string
+	Storage    storage.Store
+	LLMService *LLMService
+
+	storeMu    sync.Mutex
+	locks      sync.Map // sceneID/characterID -> *sync.Mutex
+	distillSem chan struct{}
+
+	// pendingAccess 尚未写回文件的访问时间：sceneID/characterID -> memoryID -> 时间
+	accessMu      sync.Mutex
+	pendingAccess map[string]map[string]time.Time
+
+	// 关闭控制：Close 关闭 stopFlush，写回协程退出后关闭 flushDone
+	stopFlush chan struct{}
+	flushDone chan struct{}
+	closeOnce sync.Once
+}
+
+// ScoredMemory 检索结果：记忆及其综合得分
+type ScoredMemory struct {
+	models.Memory
+	Score     float64 `json:"score"`
+	Relevance float64 `json:"relevance"` // 归一化后的 BM25 相关度 0-1
+}
+
+// distilledMemories LLM 提炼结果
+type distilledMemories struct {
+	Memories []struct {
+		Key        string `json:"key"`
+		Details    string `json:"details"`
+		Importance int    `json:"importance"`
+	} `json:"memories"`
+}
+
+// NewMemoryService 创建角色记忆服务
+func NewMemoryService(scenesPath string, llmService *LLMService) *MemoryService {
+	if scenesPath == "" {
+		scenesPath = filepath.Join("data", "scenes")
+	}
+
+	if err := os.MkdirAll(scenesPath, 0755); err != nil {
+		utils.GetLogger().Warn("创建场景数据目录失败", map[string]interface{}{"scenes_path": scenesPath, "err": err})
+	}
+
+	store, err := storage.Open(scenesPath)
+	if err != nil {
+		utils.GetLogger().Warn("创建记忆存储失败", map[string]interface{}{"scenes_path": scenesPath, "err": err})
+	}
+
+	service := &MemoryService{
+		ScenesPath: scenesPath,
+		Storage:    store,
+		LLMService: llmService,
+		distillSem: make(chan struct{}, maxConcurrentDistills),
+		stopFlush:  make(chan struct{}),
+		flushDone:  make(chan struct{}),
+	}
+	service.startAccessFlush()
+	return service
+}
+
+func (s *MemoryService) characterLock(sceneID, characterID string) *sync.Mutex {
+	value, _ := s.locks.LoadOrStore(sceneID+"/"+characterID, &sync.Mutex{})
+	return value.(*sync.Mutex)
+}
+
+// memoryStore 返回记忆存储；构造时打开失败（或直接构造的 MemoryService）会在此重试
+func (s *MemoryService) memoryStore() (storage.Store, error) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	if s.Storage == nil {
+		store, err := storage.Open(s.ScenesPath)
+		if err != nil {
+			return nil, fmt.Errorf("初始化记忆存储失败: %w", err)
+		}
+		s.Storage = store
+	}
+	return s.Storage, nil
+}
+
+func memoriesDir(sceneID string) string {
+	return filepath.Join(sceneID, "memories")
+}
+
+// load 读取角色的全部记忆（调用方需持有角色锁）
+func (s *MemoryService) load(sceneID, characterID string) ([]models.Memory, error) {
+	store, err := s.memoryStore()
+	if err != nil {
+		return nil, err
+	}
+	if !store.FileExists(memoriesDir(sceneID), characterID+".json") {
+		return []models.Memory{}, nil
+	}
+	var memories []models.Memory
+	if err := store.LoadJSONFile(memoriesDir(sceneID), characterID+".json", &memories); err != nil {
+		return nil, fmt.Errorf("读取角色记忆失败: %w", err)
+	}
+	s.applyPendingAccess(sceneID, characterID, memories)
+	return memories, nil
+}
+
+// save 写回角色的全部记忆（调用方需持有角色锁）
+func (s *MemoryService) save(sceneID, characterID string, memories []models.Memory) error {
+	store, err := s.memoryStore()
+	if err != nil {
+		return err
+	}
+	if err := store.SaveJSONFile(memoriesDir(sceneID), characterID+".json", memories); err != nil {
+		return fmt.Errorf("保存角色记忆失败: %w", err)
+	}
+	s.clearPendingAccess(sceneID, characterID, memories)
+	return nil
+}
+
+// ListMemories 按重要性、创建时间倒序列出角色的全部记忆
+func (s *MemoryService) ListMemories(sceneID, characterID string) ([]models.Memory, error) {
+	lock := s.characterLock(sceneID, characterID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	memories, err := s.load(sceneID, characterID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		if memories[i].Importance != memories[j].Importance {
+			return memories[i].Importance > memories[j].Importance
+		}
+		return memories[i].CreatedAt.After(memories[j].CreatedAt)
+	})
+	return memories, nil
+}
+
+// AddMemories 写入新记忆：与已有记忆重复时合并（保留更详细的描述与更高的重要性），
+// 超出上限时淘汰保留价值最低的记忆。返回实际写入或合并后的记忆。
+func (s *MemoryService) AddMemories(sceneID, characterID string, incoming []models.Memory) ([]models.Memory, error) {
+	lock := s.characterLock(sceneID, characterID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	memories, err := s.load(sceneID, characterID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stored := make([]models.Memory, 0, len(incoming))
+	for i, m := range incoming {
+		m.Key = strings.TrimSpace(m.Key)
+		m.Details = strings.TrimSpace(m.Details)
+		if m.Key == "" && m.Details == "" {
+			continue
+		}
+		if m.Key == "" {
+			m.Key = truncateRunes(m.Details, 30)
+		}
+		m.Importance = clampImportance(m.Importance)
+
+		if j := findDuplicateMemory(memories, m); j >= 0 {
+			existing := &memories[j]
+			existing.Details = longer(existing.Details, m.Details)
+			if m.Importance > existing.Importance {
+				existing.Importance = m.Importance
+			}
+			existing.References = appendUnique(existing.References, m.References...)
+			existing.LastAccessed = now
+			stored = append(stored, *existing)
+			continue
+		}
+
+		if m.ID == "" {
+			m.ID = fmt.Sprintf("mem_%d_%d", now.UnixNano(), i)
+		}
+		m.SceneID = sceneID
+		m.CharacterID = characterID
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		m.LastAccessed = now
+		if m.References == nil {
+			m.References = []string{}
+		}
+		memories = append(memories, m)
+		stored = append(stored, m)
+	}
+	if len(stored) == 0 {
+		return stored, nil
+	}
+
+	if len(memories) > maxMemoriesPerCharacter {
+		sort.SliceStable(memories, func(i, j int) bool {
+			return memoryRetention(memories[i], now) > memoryRetention(memories[j], now)
+		})
+		memories = memories[:maxMemoriesPerCharacter]
+	}
+
+	if err := s.save(sceneID, characterID, memories); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// DeleteMemory 删除指定记忆
+func (s *MemoryService) DeleteMemory(sceneID, characterID, memoryID string) error {
+	lock := s.characterLock(sceneID, characterID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	memories, err := s.load(sceneID, characterID)
+	if err != nil {
+		return err
+	}
+	for i, m := range memories {
+		if m.ID == memoryID {
+			memories = append(memories[:i], memories[i+1:]...)
+			return s.save(sceneID, characterID, memories)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrMemoryNotFound, memoryID)
+}
+
+// ReplaceMemories 用给定记忆整体替换场景内各角色的记忆（读档），不在 memories 中的角色记忆会被清空
+func (s *MemoryService) ReplaceMemories(sceneID string, memories map[string][]models.Memory) error {
+	store, err := s.memoryStore()
+	if err != nil {
+		return err
+	}
+	existing, err := store.ListFiles(memoriesDir(sceneID))
+	if err != nil && !storage.IsNotExist(err) {
+		return fmt.Errorf("读取记忆目录失败: %w", err)
+	}
+	for _, name := range existing {
+		characterID := strings.TrimSuffix(name, ".json")
+		if characterID == name {
+			continue
+		}
+		if _, ok := memories[characterID]; ok {
+			continue
+		}
+		lock := s.characterLock(sceneID, characterID)
+		lock.Lock()
+		s.dropPendingAccess(sceneID, characterID)
+		err := store.DeleteFile(memoriesDir(sceneID), name)
+		lock.Unlock()
+		if err != nil {
+			return fmt.Errorf("清空角色记忆失败: %w", err)
+		}
+	}
+	for characterID, list := range memories {
+		lock := s.characterLock(sceneID, characterID)
+		lock.Lock()
+		s.dropPendingAccess(sceneID, characterID)
+		err := s.save(sceneID, characterID, list)
+		lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retrieve 检索与 query 最相关的 k 条记忆。
+// 综合得分 = 0.6×相关度 + 0.25×重要性 + 0.15×时间衰减；query 为空时只看重要性与时间。
+func (s *MemoryService) Retrieve(sceneID, characterID, query string, k int) ([]ScoredMemory, error) {
+	if k <= 0 {
+		k = defaultMemoryTopK
+	}
+
+	lock := s.characterLock(sceneID, characterID)
+	lock.Lock()
+	memories, err := s.load(sceneID, characterID)
+	lock.Unlock()
+	if err != nil || len(memories) == 0 {
+		return []ScoredMemory{}, err
+	}
+
+	texts := make([]string, len(memories))
+	for i, m := range memories {
+		texts[i] = m.Key + " " + m.Details
+	}
+	relevance := newBM25Index(texts).Scores(query)
+	maxScore := 0.0
+	for _, r := range relevance {
+		maxScore = math.Max(maxScore, r)
+	}
+
+	now := time.Now()
+	results := make([]ScoredMemory, len(memories))
+	for i, m := range memories {
+		rel := 0.0
+		if maxScore > 0 {
+			rel = relevance[i] / maxScore
+		}
+		importance := float64(m.Importance) / 10
+		recency := memoryRecency(m, now)
+
+		score := 0.6*importance + 0.4*recency
+		if maxScore > 0 {
+			score = 0.6*rel + 0.25*importance + 0.15*recency
+		}
+		results[i] = ScoredMemory{Memory: m, Score: score, Relevance: rel}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	return results, nil
+}
+
+// touch 刷新被注入提示词的记忆的访问时间，经常被提起的记忆因此不容易被淡忘。
+// 访问时间只记在内存中，随下一次写入或定时批量写回文件。
+func (s *MemoryService) touch(sceneID, characterID string, results []ScoredMemory) {
+	now := time.Now()
+	key := sceneID + "/" + characterID
+
+	s.accessMu.Lock()
+	defer s.accessMu.Unlock()
+	if s.pendingAccess == nil {
+		s.pendingAccess = make(map[string]map[string]time.Time)
+	}
+	pending := s.pendingAccess[key]
+	if pending == nil {
+		pending = make(map[string]time.Time, len(results))
+		s.pendingAccess[key] = pending
+	}
+	for _, r := range results {
+		pending[r.ID] = now
+	}
+}
+
+// applyPendingAccess 把尚未写回的访问时间覆盖到刚读取的记忆上
+func (s *MemoryService) applyPendingAccess(sceneID, characterID string, memories []models.Memory) {
+	s.accessMu.Lock()
+	defer s.accessMu.Unlock()
+	pending := s.pendingAccess[sceneID+"/"+characterID]
+	if len(pending) == 0 {
+		return
+	}
+	for i := range memories {
+		if t, ok := pending[memories[i].ID]; ok && t.After(memories[i].LastAccessed) {
+			memories[i].LastAccessed = t
+		}
+	}
+}
+
+// clearPendingAccess 移除已随 memories 写回的访问时间；写入期间新记录的访问保留到下一次写回
+func (s *MemoryService) clearPendingAccess(sceneID, characterID string, memories []models.Memory) {
+	key := sceneID + "/" + characterID
+
+	s.accessMu.Lock()
+	defer s.accessMu.Unlock()
+	pending := s.pendingAccess[key]
+	if len(pending) == 0 {
+		return
+	}
+	for _, m := range memories {
+		if t, ok := pending[m.ID]; ok && !t.After(m.LastAccessed) {
+			delete(pending, m.ID)
+		}
+	}
+	if len(pending) == 0 {
+		delete(s.pendingAccess, key)
+	}
+}
+
+// dropPendingAccess 丢弃角色尚未写回的访问时间（记忆被整体替换或清空时）
+func (s *MemoryService) dropPendingAccess(sceneID, characterID string) {
+	s.accessMu.Lock()
+	delete(s.pendingAccess, sceneID+"/"+characterID)
+	s.accessMu.Unlock()
+}
+
+// FlushAccessTimes 把内存中的访问时间写回记忆文件
+func (s *MemoryService) FlushAccessTimes() {
+	s.accessMu.Lock()
+	keys := make([]string, 0, len(s.pendingAccess))
+	for key := range s.pendingAccess {
+		keys = append(keys, key)
+	}
+	s.accessMu.Unlock()
+
+	for _, key := range keys {
+		sceneID, characterID, _ := strings.Cut(key, "/")
+		s.flushCharacterAccess(sceneID, characterID)
+	}
+}
+
+func (s *MemoryService) flushCharacterAccess(sceneID, characterID string) {
+	lock := s.characterLock(sceneID, characterID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	memories, err := s.load(sceneID, characterID)
+	if err != nil || len(memories) == 0 {
+		// 记忆已被删除（如场景被删除）时不重新创建文件
+		s.dropPendingAccess(sceneID, characterID)
+		return
+	}
+	// 失败只影响时间衰减，访问时间保留到下一次写回
+	if err := s.save(sceneID, characterID, memories); err != nil {
+		utils.GetLogger().Warn("写回记忆访问时间失败", map[string]interface{}{"scene_id": sceneID, "character_id": characterID, "err": err})
+	}
+}
+
+// 定时写回访问时间，直到 Close 被调用
+func (s *MemoryService) startAccessFlush() {
+	go func() {
+		defer close(s.flushDone)
+		ticker := time.NewTicker(memoryAccessFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.FlushAccessTimes()
+			case <-s.stopFlush:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时写回协程，并把尚未保存的访问时间写回文件；可重复调用
+func (s *MemoryService) Close() {
+	s.closeOnce.Do(func() {
+		if s.stopFlush != nil {
+			close(s.stopFlush)
+			<-s.flushDone
+		}
+	})
+	s.FlushAccessTimes()
+}
+
+// Recall 检索相关记忆并格式化为提示词片段；没有记忆或检索失败时返回空串
+func (s *MemoryService) Recall(sceneID, characterID, query string, isEnglish bool) string {
+	if s == nil {
+		return ""
+	}
+	results, err := s.Retrieve(sceneID, characterID, query, defaultMemoryTopK)
+	if err != nil {
+		utils.GetLogger().Warn("检索角色记忆失败", map[string]interface{}{"scene_id": sceneID, "character_id": characterID, "err": err})
+		return ""
+	}
+	if len(results) == 0 {
+		return ""
+	}
+	s.touch(sceneID, characterID, results)
+
+	var b strings.Builder
+	for _, r := range results {
+		b.WriteString("- ")
+		b.WriteString(r.Key)
+		if r.Details != "" && r.Details != r.Key {
+			if isEnglish {
+				b.WriteString(": ")
+			} else {
+				b.WriteString("：")
+			}
+			b.WriteString(r.Details)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ObserveTurn 在后台从一轮对话中提炼长期记忆，不阻塞对话响应。
+// 提炼任务过多或 LLM 未就绪时直接跳过本轮。
+func (s *MemoryService) ObserveTurn(sceneID string, character *models.Character, userMessage, reply string, references []string) {
+	if s == nil || character == nil || s.LLMService == nil || !s.LLMService.IsReady() {
+		return
+	}
+	select {
+	case s.distillSem <- struct{}{}:
+	default:
+		utils.GetLogger().Warn("记忆提炼任务繁忙，跳过本轮", map[string]interface{}{"scene_id": sceneID, "character_id": character.ID})
+		return
+	}
+
+	go func() {
+		defer func() { <-s.distillSem }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), memoryDistillTimeout)
+		defer cancel()
+		if _, err := s.DistillTurn(ctx, sceneID, character, userMessage, reply, references); err != nil {
+			utils.GetLogger().Warn("提炼角色记忆失败", map[string]interface{}{"scene_id": sceneID, "character_id": character.ID, "err": err})
+		}
+	}()
+}
+
+// DistillTurn 调用 LLM 从一轮对话中提炼值得长期记住的事实并写入记忆库
+func (s *MemoryService) DistillTurn(ctx context.Context, sceneID string, character *models.Character, userMessage, reply string, references []string) ([]models.Memory, error) {
+	if s.LLMService == nil {
+		return nil, fmt.Errorf("LLM服务未初始化")
+	}
+	if strings.TrimSpace(userMessage) == "" && strings.TrimSpace(reply) == "" {
+		return nil, nil
+	}
+
+	isEnglish := isEnglishText(character.Name + " " + character.Description + " " + userMessage)
+	var systemPrompt, prompt string
+	if isEnglish {
+		systemPrompt = fmt.Sprintf("You maintain the long-term memory of the character \"%s\". "+
+			"Extract only facts worth remembering in later conversations: names, promises, secrets, plans, "+
+			"changes in relationships or feelings, and important events. Ignore small talk.", character.Name)
+		prompt = fmt.Sprintf("User: %s\n%s: %s\n\n"+
+			"Return JSON {\"memories\": [{\"key\": \"short label\", \"details\": \"one sentence from %s's point of view\", \"importance\": 1-10}]} "+
+			"with at most %d entries. Return {\"memories\": []} if nothing is worth remembering.",
+			userMessage, character.Name, reply, character.Name, maxDistilledPerTurn)
+	} else {
+		systemPrompt = fmt.Sprintf("你负责维护角色「%s」的长期记忆。"+
+			"只提炼在以后的对话中值得记住的事实：称呼与身份、承诺、秘密、计划、关系或情感的变化、重要事件。忽略寒暄闲聊。", character.Name)
+		prompt = fmt.Sprintf("用户：%s\n%s：%s\n\n"+
+			"返回JSON {\"memories\": [{\"key\": \"简短标签\", \"details\": \"以%s的视角写的一句话\", \"importance\": 1-10}]}，"+
+			"最多%d条。没有值得记住的内容时返回 {\"memories\": []}。",
+			userMessage, character.Name, reply, character.Name, maxDistilledPerTurn)
+	}
+
+	var out distilledMemories
+	if err := s.LLMService.CreateStructuredCompletion(WithLLMScene(WithLLMTask(ctx, LLMTaskChat), sceneID), prompt, systemPrompt, &out); err != nil {
+		return nil, err
+	}
+
+	incoming := make([]models.Memory, 0, len(out.Memories))
+	for _, m := range out.Memories {
+		if len(incoming) >= maxDistilledPerTurn {
+			break
+		}
+		incoming = append(incoming, models.Memory{
+			Key:        m.Key,
+			Details:    m.Details,
+			Importance: m.Importance,
+			References: append([]string(nil), references...),
+		})
+	}
+	if len(incoming) == 0 {
+		return nil, nil
+	}
+	return s.AddMemories(sceneID, character.ID, incoming)
+}
+
+// findDuplicateMemory 查找与 m 重复的已有记忆：关键点相同，或内容高度重合
+func findDuplicateMemory(memories []models.Memory, m models.Memory) int {
+	key := entityKey(m.Key)
+	for i, existing := range memories {
+		if key != "" && entityKey(existing.Key) == key {
+			return i
+		}
+		if tokenSimilarity(existing.Key+" "+existing.Details, m.Key+" "+m.Details) >= duplicateMemorySimilarity {
+			return i
+		}
+	}
+	return -1
+}
+
+// memoryRecency 按最近访问时间计算的指数衰减权重 0-1
+func memoryRecency(m models.Memory, now time.Time) float64 {
+	last := m.LastAccessed
+	if last.IsZero() {
+		last = m.CreatedAt
+	}
+	age := now.Sub(last)
+	if age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(memoryHalfLife))
+}
+
+// memoryRetention 淘汰时使用的保留价值：以重要性为主，兼顾最近是否被提起
+func memoryRetention(m models.Memory, now time.Time) float64 {
+	return 0.7*float64(m.Importance)/10 + 0.3*memoryRecency(m, now)
+}
+
+func clampImportance(v int) int {
+	switch {
+	case v <= 0:
+		return 5
+	case v > 10:
+		return 10
+	}
+	return v
+}
@@ -0,0 +1,587 @@
+// internal/services/save_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/storage"
+)
+
+const (
+	// 每个场景最多保留的手动存档数（不含自动备份）
+	maxSaveSlotsPerScene = 50
+	// CurrentSaveState 在差异比较中表示「当前进度」
+	CurrentSaveState = "current"
+)
+
+var (
+	ErrSaveSlotNotFound = errors.New("存档不存在")
+	ErrSaveSlotLimit    = errors.New("存档数量已达上限")
+	ErrSaveSlotReserved = errors.New("autosave 为读档前的自动备份保留，不能手动覆盖")
+
+	saveSlotIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// SaveService 场景存档：把故事进度、对话、物品、角色与角色记忆整体快照为命名存档，
+// 读档时整体替换当前状态。读档前会自动把当前进度备份到 autosave，
+// 玩家可以先存档、尝试另一条分支，再读档回到原来的进度。
+// 存档保存在 <scene>/saves/<slot>.json，随场景一起删除。
+type SaveService struct {
+	SceneService     *SceneService
+	StoryService     *StoryService
+	ItemService      *ItemService
+	ContextService   *ContextService
+	CharacterService *CharacterService
+	MemoryService    *MemoryService
+
+	sceneLocks sync.Map // sceneID -> *sync.Mutex
+}
+
+// NewSaveService 创建存档服务
+func NewSaveService(sceneService *SceneService, storyService *StoryService, itemService *ItemService,
+	contextService *ContextService, characterService *CharacterService, memoryService *MemoryService) *SaveService {
+	return &SaveService{
+		SceneService:     sceneService,
+		StoryService:     storyService,
+		ItemService:      itemService,
+		ContextService:   contextService,
+		CharacterService: characterService,
+		MemoryService:    memoryService,
+	}
+}
+
+func (s *SaveService) getSceneLock(sceneID string) *sync.Mutex {
+	value, _ := s.sceneLocks.LoadOrStore(sceneID, &sync.Mutex{})
+	return value.(*sync.Mutex)
+}
+
+func (s *SaveService) store() (storage.Store, error) {
+	if s.SceneService == nil || s.SceneService.FileCache == nil {
+		return nil, fmt.Errorf("场景存储未初始化")
+	}
+	return s.SceneService.FileCache, nil
+}
+
+func savesDir(sceneID string) string {
+	return filepath.Join(sceneID, "saves")
+}
+
+// ListSlots 列出场景的全部存档（不含快照内容），最近更新的在前
+func (s *SaveService) ListSlots(sceneID string) ([]*models.SaveSlot, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	files, err := store.ListFiles(savesDir(sceneID))
+	if err != nil {
+		if storage.IsNotExist(err) {
+			return []*models.SaveSlot{}, nil
+		}
+		return nil, fmt.Errorf("读取存档列表失败: %w", err)
+	}
+
+	slots := make([]*models.SaveSlot, 0, len(files))
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		var slot models.SaveSlot
+		if err := store.LoadJSONFile(savesDir(sceneID), name, &slot); err != nil {
+			continue
+		}
+		slot.Snapshot = nil
+		slots = append(slots, &slot)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].UpdatedAt.After(slots[j].UpdatedAt)
+	})
+	return slots, nil
+}
+
+// GetSlot 读取完整存档（含快照）
+func (s *SaveService) GetSlot(sceneID, slotID string) (*models.SaveSlot, error) {
+	if !saveSlotIDPattern.MatchString(slotID) {
+		return nil, ErrSaveSlotNotFound
+	}
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	if !store.FileExists(savesDir(sceneID), slotID+".json") {
+		return nil, ErrSaveSlotNotFound
+	}
+	var slot models.SaveSlot
+	if err := store.LoadJSONFile(savesDir(sceneID), slotID+".json", &slot); err != nil {
+		return nil, fmt.Errorf("读取存档失败: %w", err)
+	}
+	if slot.Snapshot == nil {
+		return nil, fmt.Errorf("存档内容损坏: %s", slotID)
+	}
+	return &slot, nil
+}
+
+// CreateSlot 把当前进度保存为存档；slotID 非空时覆盖该存档（保留创建时间）
+func (s *SaveService) CreateSlot(sceneID, slotID, name, description string) (*models.SaveSlot, error) {
+	lock := s.getSceneLock(sceneID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if slotID == models.AutoSaveSlotID {
+		return nil, ErrSaveSlotReserved
+	}
+
+	now := time.Now()
+	slot := &models.SaveSlot{SceneID: sceneID, CreatedAt: now}
+	if slotID != "" {
+		existing, err := s.GetSlot(sceneID, slotID)
+		if err != nil {
+			return nil, err
+		}
+		slot.ID = existing.ID
+		slot.CreatedAt = existing.CreatedAt
+		if name == "" {
+			name = existing.Name
+		}
+	} else {
+		slots, err := s.ListSlots(sceneID)
+		if err != nil {
+			return nil, err
+		}
+		manual := 0
+		for _, existing := range slots {
+			if !existing.Auto {
+				manual++
+			}
+		}
+		if manual >= maxSaveSlotsPerScene {
+			return nil, ErrSaveSlotLimit
+		}
+		slot.ID = fmt.Sprintf("save_%d", now.UnixNano())
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = now.Format("2006-01-02 15:04:05")
+	}
+	slot.Name = name
+	slot.Description = strings.TrimSpace(description)
+
+	if err := s.writeSlot(sceneID, slot, now); err != nil {
+		return nil, err
+	}
+	slot.Snapshot = nil
+	return slot, nil
+}
+
+// writeSlot 采集当前快照并写入存档文件（调用方需持有场景锁）
+func (s *SaveService) writeSlot(sceneID string, slot *models.SaveSlot, now time.Time) error {
+	snapshot, err := s.captureSnapshot(sceneID)
+	if err != nil {
+		return err
+	}
+	slot.Snapshot = snapshot
+	slot.Summary = summarizeSnapshot(snapshot)
+	slot.UpdatedAt = now
+
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	if err := store.SaveJSONFile(savesDir(sceneID), slot.ID+".json", slot); err != nil {
+		return fmt.Errorf("保存存档失败: %w", err)
+	}
+	return nil
+}
+
+// LoadSlot 读档：先把当前进度备份到 autosave，再用存档整体替换当前状态。
+// 读取 autosave 本身时相当于撤销上一次读档。返回读取的存档与新的自动备份。
+func (s *SaveService) LoadSlot(sceneID, slotID string) (*models.SaveSlot, *models.SaveSlot, error) {
+	lock := s.getSceneLock(sceneID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	slot, err := s.GetSlot(sceneID, slotID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	backup := &models.SaveSlot{
+		ID:        models.AutoSaveSlotID,
+		SceneID:   sceneID,
+		Name:      "读档前自动备份",
+		Auto:      true,
+		CreatedAt: now,
+	}
+	if err := s.writeSlot(sceneID, backup, now); err != nil {
+		return nil, nil, fmt.Errorf("备份当前进度失败: %w", err)
+	}
+
+	// 恢复分多步写入：任何一步失败都用刚写入的自动备份回滚，避免留下一半旧、一半新的场景
+	if err := s.restoreSnapshot(sceneID, slot.Snapshot); err != nil {
+		if rollbackErr := s.restoreSnapshot(sceneID, backup.Snapshot); rollbackErr != nil {
+			return nil, nil, fmt.Errorf("%w（回滚到读档前状态失败: %v）", err, rollbackErr)
+		}
+		return nil, nil, err
+	}
+
+	slot.Snapshot = nil
+	backup.Snapshot = nil
+	return slot, backup, nil
+}
+
+// DeleteSlot 删除存档
+func (s *SaveService) DeleteSlot(sceneID, slotID string) error {
+	lock := s.getSceneLock(sceneID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if !saveSlotIDPattern.MatchString(slotID) {
+		return ErrSaveSlotNotFound
+	}
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	if !store.FileExists(savesDir(sceneID), slotID+".json") {
+		return ErrSaveSlotNotFound
+	}
+	if err := store.DeleteFile(savesDir(sceneID), slotID+".json"); err != nil {
+		return fmt.Errorf("删除存档失败: %w", err)
+	}
+	return nil
+}
+
+// DiffSlots 比较两个存档；任一侧传 CurrentSaveState 表示当前进度
+func (s *SaveService) DiffSlots(sceneID, fromID, toID string) (*models.SaveSlotDiff, error) {
+	from, err := s.snapshotFor(sceneID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.snapshotFor(sceneID, toID)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffSnapshots(from, to)
+	diff.From = fromID
+	diff.To = toID
+	return diff, nil
+}
+
+func (s *SaveService) snapshotFor(sceneID, slotID string) (*models.SceneSnapshot, error) {
+	if slotID == CurrentSaveState {
+		return s.captureSnapshot(sceneID)
+	}
+	slot, err := s.GetSlot(sceneID, slotID)
+	if err != nil {
+		return nil, err
+	}
+	return slot.Snapshot, nil
+}
+
+// captureSnapshot 采集场景当前的可变状态
+func (s *SaveService) captureSnapshot(sceneID string) (*models.SceneSnapshot, error) {
+	if s.SceneService == nil {
+		return nil, fmt.Errorf("场景服务未初始化")
+	}
+	sceneData, err := s.SceneService.LoadSceneNoCache(sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &models.SceneSnapshot{
+		Context:    sceneData.Context,
+		Characters: sceneData.Characters,
+		Items:      []*models.Item{},
+	}
+	if snapshot.Context.Conversations == nil {
+		snapshot.Context.Conversations = []models.Conversation{}
+	}
+	if snapshot.Characters == nil {
+		snapshot.Characters = []*models.Character{}
+	}
+
+	if s.StoryService != nil {
+		story, err := s.StoryService.LoadStorySnapshot(sceneID)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Story = story
+	}
+
+	if s.ItemService != nil {
+		items, err := s.ItemService.GetAllItems(sceneID)
+		if err != nil {
+			return nil, fmt.Errorf("读取物品失败: %w", err)
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+		snapshot.Items = items
+	}
+
+	if s.MemoryService != nil {
+		for _, character := range snapshot.Characters {
+			memories, err := s.MemoryService.ListMemories(sceneID, character.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(memories) == 0 {
+				continue
+			}
+			if snapshot.Memories == nil {
+				snapshot.Memories = make(map[string][]models.Memory)
+			}
+			snapshot.Memories[character.ID] = memories
+		}
+	}
+
+	return snapshot, nil
+}
+
+// restoreSnapshot 用快照替换场景状态：对话与角色在同一事务中写入，物品、角色记忆与故事数据
+// 分别交给各自的服务写入（各服务的存储实例有独立的读缓存）。
+// 存档之后新建的角色会保留，存档之后新增的物品、记忆与故事（快照中没有故事时）会被移除。
+func (s *SaveService) restoreSnapshot(sceneID string, snapshot *models.SceneSnapshot) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+
+	sceneLock := s.SceneService.getSceneLock(sceneID)
+	sceneLock.Lock()
+	err = store.WithTx(func(tx storage.Tx) error {
+		context := snapshot.Context
+		context.SceneID = sceneID
+		context.LastUpdated = time.Now()
+		if err := tx.SaveJSONFile(sceneID, "context.json", context); err != nil {
+			return fmt.Errorf("恢复对话失败: %w", err)
+		}
+		for _, character := range snapshot.Characters {
+			if err := tx.SaveJSONFile(filepath.Join(sceneID, "characters"), character.ID+".json", character); err != nil {
+				return fmt.Errorf("恢复角色失败: %w", err)
+			}
+		}
+		return nil
+	})
+	sceneLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if s.ItemService != nil {
+		if err := s.ItemService.ReplaceItems(sceneID, snapshot.Items); err != nil {
+			return fmt.Errorf("恢复物品失败: %w", err)
+		}
+	}
+	if s.MemoryService != nil {
+		if err := s.MemoryService.ReplaceMemories(sceneID, snapshot.Memories); err != nil {
+			return fmt.Errorf("恢复角色记忆失败: %w", err)
+		}
+	}
+	if s.StoryService != nil {
+		if err := s.StoryService.RestoreStorySnapshot(sceneID, snapshot.Story); err != nil {
+			return fmt.Errorf("恢复故事进度失败: %w", err)
+		}
+	}
+
+	// 各服务的缓存都可能持有旧状态
+	s.SceneService.invalidateSceneCache(sceneID)
+	if s.ContextService != nil {
+		s.ContextService.InvalidateSceneCache(sceneID)
+	}
+	if s.CharacterService != nil {
+		s.CharacterService.InvalidateSceneCache(sceneID)
+	}
+	return nil
+}
+
+func summarizeSnapshot(snapshot *models.SceneSnapshot) models.SaveSlotSummary {
+	summary := models.SaveSlotSummary{
+		Conversations: len(snapshot.Context.Conversations),
+		Items:         len(snapshot.Items),
+		Characters:    len(snapshot.Characters),
+	}
+	if story := snapshot.Story; story != nil {
+		summary.Progress = story.Progress
+		summary.CurrentState = story.CurrentState
+		summary.TotalNodes = len(story.Nodes)
+		for _, node := range story.Nodes {
+			if node.IsRevealed {
+				summary.RevealedNodes++
+				summary.CurrentNodeID = node.ID
+			}
+		}
+	}
+	return summary
+}
+
+// diffSnapshots 比较两个快照，from 为基准
+func diffSnapshots(from, to *models.SceneSnapshot) *models.SaveSlotDiff {
+	diff := &models.SaveSlotDiff{
+		ConversationsFrom: len(from.Context.Conversations),
+		ConversationsTo:   len(to.Context.Conversations),
+	}
+
+	// 故事：进度、已揭示节点、已选选项
+	fromNodes, fromChoices := storyMarks(from.Story)
+	toNodes, toChoices := storyMarks(to.Story)
+	if from.Story != nil {
+		diff.ProgressFrom = from.Story.Progress
+	}
+	if to.Story != nil {
+		diff.ProgressTo = to.Story.Progress
+	}
+	diff.NodesOnlyInFrom, diff.NodesOnlyInTo = orderedSetDiff(fromNodes, toNodes)
+	diff.ChoicesOnlyInFrom, diff.ChoicesOnlyInTo = orderedSetDiff(fromChoices, toChoices)
+
+	// 对话：按ID比较
+	fromConvs := make([]string, 0, len(from.Context.Conversations))
+	for _, conv := range from.Context.Conversations {
+		fromConvs = append(fromConvs, conv.ID)
+	}
+	toConvs := make([]string, 0, len(to.Context.Conversations))
+	for _, conv := range to.Context.Conversations {
+		toConvs = append(toConvs, conv.ID)
+	}
+	onlyFrom, onlyTo := orderedSetDiff(fromConvs, toConvs)
+	diff.ConversationsOnlyInFrom, diff.ConversationsOnlyInTo = len(onlyFrom), len(onlyTo)
+
+	// 物品
+	fromItems := make(map[string]*models.Item, len(from.Items))
+	for _, item := range from.Items {
+		fromItems[item.ID] = item
+	}
+	toItems := make(map[string]*models.Item, len(to.Items))
+	for _, item := range to.Items {
+		toItems[item.ID] = item
+	}
+	diff.ItemsOnlyInFrom, diff.ItemsOnlyInTo, diff.ItemsChanged = []string{}, []string{}, []models.ItemChange{}
+	for _, item := range from.Items {
+		other, ok := toItems[item.ID]
+		if !ok {
+			diff.ItemsOnlyInFrom = append(diff.ItemsOnlyInFrom, item.Name)
+			continue
+		}
+		var fields []string
+		if item.Location != other.Location {
+			fields = append(fields, "location")
+		}
+		if item.IsOwned != other.IsOwned {
+			fields = append(fields, "is_owned")
+		}
+		if item.Description != other.Description {
+			fields = append(fields, "description")
+		}
+		if !reflect.DeepEqual(item.Properties, other.Properties) {
+			fields = append(fields, "properties")
+		}
+		if len(fields) > 0 {
+			diff.ItemsChanged = append(diff.ItemsChanged, models.ItemChange{ItemID: item.ID, Name: item.Name, Fields: fields})
+		}
+	}
+	for _, item := range to.Items {
+		if _, ok := fromItems[item.ID]; !ok {
+			diff.ItemsOnlyInTo = append(diff.ItemsOnlyInTo, item.Name)
+		}
+	}
+
+	// 角色关系
+	diff.RelationshipChanges = []models.RelationshipChange{}
+	fromChars := make(map[string]*models.Character, len(from.Characters))
+	for _, c := range from.Characters {
+		fromChars[c.ID] = c
+	}
+	for _, c := range to.Characters {
+		before := map[string]string{}
+		if prev, ok := fromChars[c.ID]; ok && prev.Relationships != nil {
+			before = prev.Relationships
+		}
+		targets := make([]string, 0, len(before)+len(c.Relationships))
+		for target := range before {
+			targets = append(targets, target)
+		}
+		for target := range c.Relationships {
+			if _, ok := before[target]; !ok {
+				targets = append(targets, target)
+			}
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			if before[target] != c.Relationships[target] {
+				diff.RelationshipChanges = append(diff.RelationshipChanges, models.RelationshipChange{
+					CharacterID:   c.ID,
+					CharacterName: c.Name,
+					Target:        target,
+					From:          before[target],
+					To:            c.Relationships[target],
+				})
+			}
+		}
+	}
+
+	// 角色记忆：按记忆ID比较
+	var fromMems, toMems []string
+	for _, memories := range from.Memories {
+		for _, m := range memories {
+			fromMems = append(fromMems, m.ID)
+		}
+	}
+	for _, memories := range to.Memories {
+		for _, m := range memories {
+			toMems = append(toMems, m.ID)
+		}
+	}
+	onlyFrom, onlyTo = orderedSetDiff(fromMems, toMems)
+	diff.MemoriesOnlyInFrom, diff.MemoriesOnlyInTo = len(onlyFrom), len(onlyTo)
+
+	return diff
+}
+
+// storyMarks 返回已揭示节点ID与已选选项（节点ID:选项ID），保持节点顺序
+func storyMarks(story *models.StoryData) (nodes, choices []string) {
+	if story == nil {
+		return nil, nil
+	}
+	for _, node := range story.Nodes {
+		if node.IsRevealed {
+			nodes = append(nodes, node.ID)
+		}
+		for _, choice := range node.Choices {
+			if choice.Selected {
+				choices = append(choices, node.ID+":"+choice.ID)
+			}
+		}
+	}
+	return nodes, choices
+}
+
+// orderedSetDiff 返回仅在 a 中、仅在 b 中的元素，保持原有顺序
+func orderedSetDiff(a, b []string) (onlyA, onlyB []string) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+	}
+	onlyA, onlyB = []string{}, []string{}
+	for _, v := range a {
+		if !inB[v] {
+			onlyA = append(onlyA, v)
+		}
+	}
+	for _, v := range b {
+		if !inA[v] {
+			onlyB = append(onlyB, v)
+		}
+	}
+	return onlyA, onlyB
+}
@@ -2,6 +2,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,7 +34,7 @@ type SceneData struct {
 // SceneService 处理场景相关的业务逻辑
 type SceneService struct {
 	BasePath    string
-	FileCache   *storage.FileStorage
+	FileCache   storage.Store
 	ItemService *ItemService
 
 	// 并发控制
@@ -71,8 +72,8 @@ func NewSceneService(basePath string) *SceneService {
 		logger.Warn("创建场景目录失败", map[string]interface{}{"base_path": basePath, "err": err})
 	}
 
-	// 初始化 FileStorage
-	fileStorage, err := storage.NewFileStorage(basePath)
+	// 初始化存储（文件或 SQLite，取决于全局存储配置）
+	fileStorage, err := storage.Open(basePath)
 	if err != nil {
 		logger.Warn("创建文件存储失败", map[string]interface{}{"base_path": basePath, "err": err})
 		fileStorage = nil
@@ -277,13 +278,21 @@ func (s *SceneService) enforceMaxCacheSize() {
 	}
 }
 
+// sceneExists 检查场景是否存在（兼容文件与 SQLite 存储后端）
+func (s *SceneService) sceneExists(sceneID string) bool {
+	if s.FileCache != nil {
+		return s.FileCache.DirExists(sceneID)
+	}
+	_, err := os.Stat(filepath.Join(s.BasePath, sceneID))
+	return err == nil
+}
+
 // 生成唯一场景ID
 func (s *SceneService) generateUniqueSceneID() string {
 	for {
 		id := fmt.Sprintf("scene_%d", time.Now().UnixNano())
-		scenePath := filepath.Join(s.BasePath, id)
 
-		if _, err := os.Stat(scenePath); os.IsNotExist(err) {
+		if !s.sceneExists(id) {
 			return id
 		}
 
@@ -471,25 +480,22 @@ func (s *SceneService) loadCharactersCached(sceneID string) ([]*models.Character
 		return nil, fmt.Errorf("文件存储服务未初始化")
 	}
 
-	charactersDir := filepath.Join(s.BasePath, sceneID, "characters")
-
-	if _, err := os.Stat(charactersDir); os.IsNotExist(err) {
-		return []*models.Character{}, nil
-	}
-
-	files, err := os.ReadDir(charactersDir)
+	files, err := s.FileCache.ListFiles(filepath.Join(sceneID, "characters"))
 	if err != nil {
+		if storage.IsNotExist(err) {
+			return []*models.Character{}, nil
+		}
 		return nil, fmt.Errorf("读取角色目录失败: %w", err)
 	}
 
 	characters := make([]*models.Character, 0, len(files))
 
 	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
+		if filepath.Ext(file) == ".json" {
 			var character models.Character
 
 			// 🔧 关键修复：使用相对路径而不是绝对路径
-			characterPath := filepath.Join("characters", file.Name())
+			characterPath := filepath.Join("characters", file)
 			if err := s.FileCache.LoadJSONFile(sceneID, characterPath, &character); err != nil {
 				utils.GetLogger().Warn("读取角色数据失败", map[string]interface{}{"scene_id": sceneID, "character_path": characterPath, "err": err})
 				continue
@@ -528,8 +534,7 @@ func (s *SceneService) AddCharacter(sceneID string, character *models.Character)
 	defer lock.Unlock()
 
 	// 检查场景是否存在
-	sceneDir := filepath.Join(s.BasePath, sceneID)
-	if _, err := os.Stat(sceneDir); os.IsNotExist(err) {
+	if !s.sceneExists(sceneID) {
 		return fmt.Errorf("场景不存在: %s", sceneID)
 	}
 
@@ -564,13 +569,16 @@ func (s *SceneService) AddCharacter(sceneID string, character *models.Character)
 
 // generateUniqueCharacterID 生成唯一角色ID
 func (s *SceneService) generateUniqueCharacterID(sceneID string) string {
-	charactersDir := filepath.Join(s.BasePath, sceneID, "characters")
+	charactersDir := filepath.Join(sceneID, "characters")
 
 	for {
 		id := fmt.Sprintf("char_%d", time.Now().UnixNano())
-		characterPath := filepath.Join(charactersDir, id+".json")
 
-		if _, err := os.Stat(characterPath); os.IsNotExist(err) {
+		if s.FileCache == nil {
+			if _, err := os.Stat(filepath.Join(s.BasePath, charactersDir, id+".json")); os.IsNotExist(err) {
+				return id
+			}
+		} else if !s.FileCache.FileExists(charactersDir, id+".json") {
 			return id
 		}
 
@@ -661,14 +669,20 @@ func (s *SceneService) UpdateContext(sceneID string, context *models.SceneContex
 func (s *SceneService) UpdateSettings(sceneID string, settings *models.SceneSettings) error {
 	settings.LastUpdated = time.Now()
 
-	settingsDataJSON, err := json.MarshalIndent(settings, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化设置数据失败: %w", err)
-	}
+	if s.FileCache != nil {
+		if err := s.FileCache.SaveJSONFile(sceneID, "settings.json", settings); err != nil {
+			return fmt.Errorf("保存设置数据失败: %w", err)
+		}
+	} else {
+		settingsDataJSON, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化设置数据失败: %w", err)
+		}
 
-	settingsPath := filepath.Join(s.BasePath, sceneID, "settings.json")
-	if err := os.WriteFile(settingsPath, settingsDataJSON, 0644); err != nil {
-		return fmt.Errorf("保存设置数据失败: %w", err)
+		settingsPath := filepath.Join(s.BasePath, sceneID, "settings.json")
+		if err := os.WriteFile(settingsPath, settingsDataJSON, 0644); err != nil {
+			return fmt.Errorf("保存设置数据失败: %w", err)
+		}
 	}
 
 	// 缓存清除
@@ -747,27 +761,21 @@ func (s *SceneService) GetAllScenes() ([]models.Scene, error) {
 	s.cacheMutex.RUnlock()
 
 	// 加载场景列表
-	entries, err := os.ReadDir(s.BasePath)
+	sceneIDs, err := s.listSceneIDs()
 	if err != nil {
 		return nil, fmt.Errorf("读取场景目录失败: %w", err)
 	}
 
-	scenes := make([]models.Scene, 0, len(entries))
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
+	scenes := make([]models.Scene, 0, len(sceneIDs))
 
-		sceneID := entry.Name()
+	for _, sceneID := range sceneIDs {
 		scenePath := filepath.Join(s.BasePath, sceneID, "scene.json")
 
-		if _, err := os.Stat(scenePath); os.IsNotExist(err) {
-			continue
-		}
-
 		var scene models.Scene
 		if s.FileCache != nil {
+			if !s.FileCache.FileExists(sceneID, "scene.json") {
+				continue
+			}
 			if err := s.FileCache.LoadJSONFile(sceneID, "scene.json", &scene); err != nil {
 				utils.GetLogger().Warn("无法读取场景", map[string]interface{}{"scene_id": sceneID, "err": err})
 				continue
@@ -803,39 +811,81 @@ func (s *SceneService) GetAllScenes() ([]models.Scene, error) {
 	return scenes, nil
 }
 
-// enrichSceneSummary 补充场景的角色和物品数量等元数据
-func (s *SceneService) enrichSceneSummary(sceneID string, scene *models.Scene) {
-	if scene == nil {
-		return
+// listSceneIDs 列出所有场景目录
+func (s *SceneService) listSceneIDs() ([]string, error) {
+	if s.FileCache != nil {
+		return s.FileCache.ListDirs("")
 	}
 
-	characterDir := filepath.Join(s.BasePath, sceneID, "characters")
-	if entries, err := os.ReadDir(characterDir); err == nil {
-		count := 0
-		for _, entry := range entries {
-			if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
-				continue
-			}
-			count++
+	entries, err := os.ReadDir(s.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
 		}
-		scene.CharacterCount = count
 	}
+	return ids, nil
+}
 
-	itemsDir := filepath.Join(s.BasePath, sceneID, "items")
-	if entries, err := os.ReadDir(itemsDir); err == nil {
-		count := 0
+// countJSONFiles 统计场景子目录下的 JSON 文件数量；目录不存在时 ok 为 false
+func (s *SceneService) countJSONFiles(sceneID, subDir string) (int, bool) {
+	var names []string
+	if s.FileCache != nil {
+		files, err := s.FileCache.ListFiles(filepath.Join(sceneID, subDir))
+		if err != nil {
+			return 0, false
+		}
+		names = files
+	} else {
+		entries, err := os.ReadDir(filepath.Join(s.BasePath, sceneID, subDir))
+		if err != nil {
+			return 0, false
+		}
 		for _, entry := range entries {
-			if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
-				continue
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
 			}
+		}
+	}
+
+	count := 0
+	for _, name := range names {
+		if filepath.Ext(name) == ".json" {
 			count++
 		}
+	}
+	return count, true
+}
+
+// enrichSceneSummary 补充场景的角色和物品数量等元数据
+func (s *SceneService) enrichSceneSummary(sceneID string, scene *models.Scene) {
+	if scene == nil {
+		return
+	}
+
+	if count, ok := s.countJSONFiles(sceneID, "characters"); ok {
+		scene.CharacterCount = count
+	}
+
+	if count, ok := s.countJSONFiles(sceneID, "items"); ok {
 		scene.ItemCount = count
 	}
 }
 
+// SceneJobTypeImport 是书籍导入（整本书逐章分析并创建场景）在 JobQueue 中的任务类型
+const SceneJobTypeImport = "scene_import"
+
 // CreateSceneFromText 从文本创建新场景
 func (s *SceneService) CreateSceneFromText(userID, text, title string) (*models.Scene, error) {
+	return s.CreateSceneFromTextWithProgress(context.Background(), userID, text, title, nil, nil)
+}
+
+// CreateSceneFromTextWithProgress 从文本创建新场景，并使用调用方提供的原文片段（如导入书籍的章节）
+// 代替分析器自动切分的片段。提供片段的长文本按章节逐块分析全文，每完成一个分块通过 tracker 报告进度；tracker 可为 nil。
+func (s *SceneService) CreateSceneFromTextWithProgress(ctx context.Context, userID, text, title string, segments []models.OriginalSegment, tracker *ProgressTracker) (*models.Scene, error) {
 	// 检查参数有效性
 	if text == "" || title == "" {
 		return nil, fmt.Errorf("文本和标题不能为空")
@@ -848,13 +898,28 @@ func (s *SceneService) CreateSceneFromText(userID, text, title string) (*models.
 		return nil, fmt.Errorf("分析服务未初始化，无法分析文本")
 	}
 
-	analysisResult, err := analyzerService.AnalyzeText(text, title)
+	ctx = WithLLMUsageScope(ctx, userID, "")
+	var analysisResult *models.AnalysisResult
+	var err error
+	if len(segments) > 0 && needsChunkedAnalysis(text) {
+		chunkCtx, cancel := context.WithTimeout(ctx, analyzerService.AnalysisTimeout(text))
+		analysisResult, err = analyzerService.AnalyzeTextInChunks(chunkCtx, text, title, segments, tracker)
+		cancel()
+	} else {
+		reportProgress(tracker, 10, "分析文本...")
+		analysisResult, err = analyzerService.analyzeTextWithSegments(ctx, text, title, segments)
+	}
 	if err != nil {
 		if errors.Is(err, ErrLLMNotReady) {
 			return nil, ErrLLMNotReady
 		}
 		return nil, fmt.Errorf("分析文本失败: %w", err)
 	}
+	reportProgress(tracker, 95, "保存场景...")
+	originalSegments := analysisResult.OriginalSegments
+	if len(segments) > 0 {
+		originalSegments = segments
+	}
 
 	// 生成场景ID
 	sceneID := fmt.Sprintf("scene_%d", time.Now().UnixNano())
@@ -913,118 +978,99 @@ func (s *SceneService) CreateSceneFromText(userID, text, title string) (*models.
 		LastUpdated: time.Now(),
 	}
 
-	// 创建场景目录
-	sceneDir := filepath.Join(s.BasePath, sceneID)
-	if err := os.MkdirAll(sceneDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建场景目录失败: %w", err)
-	}
-
-	// 保存场景数据
-	sceneDataJSON, err := json.MarshalIndent(scene, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("序列化场景数据失败: %w", err)
+	if s.FileCache == nil {
+		return nil, fmt.Errorf("文件存储服务未初始化")
 	}
 
-	scenePath := filepath.Join(sceneDir, "scene.json")
-	if err := os.WriteFile(scenePath, sceneDataJSON, 0644); err != nil {
-		return nil, fmt.Errorf("保存场景数据失败: %w", err)
+	// 初始化上下文
+	context := models.SceneContext{
+		SceneID:       sceneID,
+		Conversations: []models.Conversation{},
+		LastUpdated:   time.Now(),
 	}
 
-	if err := s.saveOriginalText(sceneDir, text); err != nil {
-		return nil, err
-	}
+	// 场景、原文、角色、物品与上下文在同一个事务中写入，避免留下半成品场景
+	err = s.FileCache.WithTx(func(tx storage.Tx) error {
+		if err := tx.SaveJSONFile(sceneID, "scene.json", scene); err != nil {
+			return fmt.Errorf("保存场景数据失败: %w", err)
+		}
 
-	if len(analysisResult.OriginalSegments) > 0 {
-		if err := s.saveOriginalSegments(sceneDir, analysisResult.OriginalSegments); err != nil {
-			utils.GetLogger().Warn("保存原文片段失败", map[string]interface{}{"scene_id": sceneID, "err": err})
+		if strings.TrimSpace(text) != "" {
+			if err := tx.SaveTextFile(sceneID, "original.txt", []byte(text)); err != nil {
+				return fmt.Errorf("保存原始文本失败: %w", err)
+			}
 		}
-	}
 
-	// 保存角色数据
-	if len(analysisResult.Characters) > 0 {
-		charactersDir := filepath.Join(s.BasePath, sceneID, "characters")
-		if err := os.MkdirAll(charactersDir, 0755); err != nil {
-			utils.GetLogger().Warn("创建角色目录失败", map[string]interface{}{"scene_id": sceneID, "dir": charactersDir, "err": err})
-		} else {
-			for i, character := range analysisResult.Characters {
-				// 创建角色ID
-				charID := fmt.Sprintf("char_%d_%d", time.Now().UnixNano(), i)
-				character.ID = charID
-				character.SceneID = sceneID
-
-				charDataJSON, err := json.MarshalIndent(character, "", "  ")
-				if err != nil {
-					utils.GetLogger().Warn("无法序列化角色数据", map[string]interface{}{"scene_id": sceneID, "character_id": charID, "err": err})
-					continue
-				}
-
-				charPath := filepath.Join(charactersDir, charID+".json")
-				if err := os.WriteFile(charPath, charDataJSON, 0644); err != nil {
-					utils.GetLogger().Warn("保存角色数据失败", map[string]interface{}{"scene_id": sceneID, "character_id": charID, "path": charPath, "err": err})
-				}
+		if len(originalSegments) > 0 {
+			if err := tx.SaveJSONFile(sceneID, "original_segments.json", originalSegments); err != nil {
+				utils.GetLogger().Warn("保存原文片段失败", map[string]interface{}{"scene_id": sceneID, "err": err})
 			}
 		}
-	}
 
-	// 保存物品数据
-	if len(analysisResult.Items) > 0 {
-		itemsDir := filepath.Join(s.BasePath, sceneID, "items")
-		if err := os.MkdirAll(itemsDir, 0755); err != nil {
-			utils.GetLogger().Warn("创建物品目录失败", map[string]interface{}{"scene_id": sceneID, "dir": itemsDir, "err": err})
-		} else {
-			for i, item := range analysisResult.Items {
-				// 创建物品ID
-				itemID := fmt.Sprintf("item_%d_%d", time.Now().UnixNano(), i)
-				item.ID = itemID
-				item.SceneID = sceneID
-
-				itemDataJSON, err := json.MarshalIndent(item, "", "  ")
-				if err != nil {
-					utils.GetLogger().Warn("无法序列化物品数据", map[string]interface{}{"scene_id": sceneID, "item_id": itemID, "err": err})
-					continue
-				}
-
-				itemPath := filepath.Join(itemsDir, itemID+".json")
-				if err := os.WriteFile(itemPath, itemDataJSON, 0644); err != nil {
-					utils.GetLogger().Warn("保存物品数据失败", map[string]interface{}{"scene_id": sceneID, "item_id": itemID, "path": itemPath, "err": err})
-				}
+		// 保存角色数据
+		charactersDir := filepath.Join(sceneID, "characters")
+		for i, character := range analysisResult.Characters {
+			// 创建角色ID
+			charID := fmt.Sprintf("char_%d_%d", time.Now().UnixNano(), i)
+			character.ID = charID
+			character.SceneID = sceneID
+
+			if err := tx.SaveJSONFile(charactersDir, charID+".json", character); err != nil {
+				utils.GetLogger().Warn("保存角色数据失败", map[string]interface{}{"scene_id": sceneID, "character_id": charID, "err": err})
 			}
 		}
-	}
 
-	// 初始化上下文
-	context := models.SceneContext{
-		SceneID:       sceneID,
-		Conversations: []models.Conversation{},
-		LastUpdated:   time.Now(),
-	}
+		// 保存物品数据
+		itemsDir := filepath.Join(sceneID, "items")
+		for i, item := range analysisResult.Items {
+			// 创建物品ID
+			itemID := fmt.Sprintf("item_%d_%d", time.Now().UnixNano(), i)
+			item.ID = itemID
+			item.SceneID = sceneID
 
-	if err := s.UpdateContext(sceneID, &context); err != nil {
-		return nil, fmt.Errorf("初始化场景上下文失败: %w", err)
+			if err := tx.SaveJSONFile(itemsDir, itemID+".json", item); err != nil {
+				utils.GetLogger().Warn("保存物品数据失败", map[string]interface{}{"scene_id": sceneID, "item_id": itemID, "err": err})
+			}
+		}
+
+		if err := tx.SaveJSONFile(sceneID, "context.json", &context); err != nil {
+			return fmt.Errorf("初始化场景上下文失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 缓存清除
-	s.invalidateListCache()
+	s.invalidateSceneCache(sceneID)
 
 	return scene, nil
 }
 
-func (s *SceneService) saveOriginalText(sceneDir, text string) error {
-	trimmed := strings.TrimSpace(text)
-	if trimmed == "" {
-		return nil
+// readSceneFile 读取场景目录下的文件（兼容 FileCache 未初始化时的直接文件读取）
+func (s *SceneService) readSceneFile(sceneID, filename string) ([]byte, error) {
+	if s.FileCache != nil {
+		return s.FileCache.LoadTextFile(sceneID, filename)
 	}
-	path := filepath.Join(sceneDir, "original.txt")
-	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
-		return fmt.Errorf("保存原始文本失败: %w", err)
+	return os.ReadFile(filepath.Join(s.BasePath, sceneID, filename))
+}
+
+// writeSceneFile 写入场景目录下的文件（兼容 FileCache 未初始化时的直接文件写入）
+func (s *SceneService) writeSceneFile(sceneID, filename string, data []byte) error {
+	if s.FileCache != nil {
+		return s.FileCache.SaveTextFile(sceneID, filename, data)
 	}
-	return nil
+	sceneDir := filepath.Join(s.BasePath, sceneID)
+	if err := os.MkdirAll(sceneDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(sceneDir, filename), data, 0644)
 }
 
 func (s *SceneService) loadOriginalText(sceneID string, scene *models.Scene) string {
 	if sceneID != "" {
-		primary := filepath.Join(s.BasePath, sceneID, "original.txt")
-		if data, err := os.ReadFile(primary); err == nil {
+		if data, err := s.readSceneFile(sceneID, "original.txt"); err == nil {
 			return string(data)
 		}
 	}
@@ -1048,7 +1094,7 @@ func (s *SceneService) loadOriginalText(sceneID string, scene *models.Scene) str
 	return ""
 }
 
-func (s *SceneService) saveOriginalSegments(sceneDir string, segments []models.OriginalSegment) error {
+func (s *SceneService) saveOriginalSegments(sceneID string, segments []models.OriginalSegment) error {
 	if len(segments) == 0 {
 		return nil
 	}
@@ -1056,8 +1102,7 @@ func (s *SceneService) saveOriginalSegments(sceneDir string, segments []models.O
 	if err != nil {
 		return fmt.Errorf("序列化原文片段失败: %w", err)
 	}
-	path := filepath.Join(sceneDir, "original_segments.json")
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := s.writeSceneFile(sceneID, "original_segments.json", data); err != nil {
 		return fmt.Errorf("保存原文片段失败: %w", err)
 	}
 	return nil
@@ -1067,14 +1112,13 @@ func (s *SceneService) loadOriginalSegments(sceneID string) []models.OriginalSeg
 	if sceneID == "" {
 		return nil
 	}
-	path := filepath.Join(s.BasePath, sceneID, "original_segments.json")
-	data, err := os.ReadFile(path)
+	data, err := s.readSceneFile(sceneID, "original_segments.json")
 	if err != nil {
 		return nil
 	}
 	var segments []models.OriginalSegment
 	if err := json.Unmarshal(data, &segments); err != nil {
-		utils.GetLogger().Warn("解析原文片段失败", map[string]interface{}{"scene_id": sceneID, "path": path, "err": err})
+		utils.GetLogger().Warn("解析原文片段失败", map[string]interface{}{"scene_id": sceneID, "err": err})
 		return nil
 	}
 	for i := range segments {
@@ -1102,13 +1146,8 @@ func (s *SceneService) ensureOriginalSegments(sceneID string, sceneData *SceneDa
 	if len(segments) == 0 {
 		return
 	}
-	sceneDir := filepath.Join(s.BasePath, sceneID)
-	if err := os.MkdirAll(sceneDir, 0755); err != nil {
-		utils.GetLogger().Warn("自动创建场景目录失败", map[string]interface{}{"scene_id": sceneID, "dir": sceneDir, "err": err})
-		return
-	}
-	if err := s.saveOriginalSegments(sceneDir, segments); err != nil {
-		utils.GetLogger().Warn("自动保存原文片段失败", map[string]interface{}{"scene_id": sceneID, "dir": sceneDir, "err": err})
+	if err := s.saveOriginalSegments(sceneID, segments); err != nil {
+		utils.GetLogger().Warn("自动保存原文片段失败", map[string]interface{}{"scene_id": sceneID, "err": err})
 		return
 	}
 	sceneData.OriginalSegments = segments
@@ -1273,8 +1312,7 @@ func (s *SceneService) CreateSceneWithCharacters(scene *models.Scene, characters
 // GetCharactersByScene 获取指定场景的所有角色
 func (s *SceneService) GetCharactersByScene(sceneID string) ([]*models.Character, error) {
 	// 检查场景是否存在
-	sceneDir := filepath.Join(s.BasePath, sceneID)
-	if _, err := os.Stat(sceneDir); os.IsNotExist(err) {
+	if !s.sceneExists(sceneID) {
 		return nil, fmt.Errorf("场景不存在: %s", sceneID)
 	}
 
@@ -1300,13 +1338,16 @@ func (s *SceneService) DeleteScene(sceneID string) error {
 	defer lock.Unlock()
 
 	// 检查场景是否存在
-	sceneDir := filepath.Join(s.BasePath, sceneID)
-	if _, err := os.Stat(sceneDir); os.IsNotExist(err) {
+	if !s.sceneExists(sceneID) {
 		return fmt.Errorf("场景不存在: %s", sceneID)
 	}
 
 	// 删除场景目录及其所有内容
-	if err := os.RemoveAll(sceneDir); err != nil {
+	if s.FileCache != nil {
+		if err := s.FileCache.DeleteDir(sceneID); err != nil {
+			return fmt.Errorf("删除场景目录失败: %w", err)
+		}
+	} else if err := os.RemoveAll(filepath.Join(s.BasePath, sceneID)); err != nil {
 		return fmt.Errorf("删除场景目录失败: %w", err)
 	}
 
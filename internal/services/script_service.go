@@ -25,7 +25,7 @@ var (
 
 type ScriptService struct {
 	BasePath    string
-	FileStorage *storage.FileStorage
+	FileStorage storage.Store
 	LLM         *LLMService
 }
 
@@ -584,7 +584,7 @@ func readOptionalChapterCount(framework map[string]interface{}) int {
 }
 
 func NewScriptService(basePath string, llm *LLMService) (*ScriptService, error) {
-	fs, err := storage.NewFileStorage(basePath)
+	fs, err := storage.Open(basePath)
 	if err != nil {
 		return nil, err
 	}
@@ -1000,17 +1000,13 @@ func (s *ScriptService) GetProject(ctx context.Context, id string) (*models.Scri
 }
 
 func (s *ScriptService) ListProjects(ctx context.Context) ([]models.ScriptProject, error) {
-	entries, err := os.ReadDir(s.BasePath)
+	dirNames, err := s.FileStorage.ListDirs("")
 	if err != nil {
 		return nil, err
 	}
 
 	projects := make([]models.ScriptProject, 0)
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-		dirName := e.Name()
+	for _, dirName := range dirNames {
 		if !strings.HasPrefix(dirName, "script_") {
 			continue
 		}
@@ -1037,8 +1033,7 @@ func (s *ScriptService) ListDraftMetas(ctx context.Context, scriptID string) ([]
 		return nil, err
 	}
 
-	draftsDir := filepath.Join(s.BasePath, scriptID, "drafts")
-	entries, err := os.ReadDir(draftsDir)
+	names, err := s.FileStorage.ListFiles(filepath.Join(scriptID, "drafts"))
 	if err != nil {
 		if os.IsNotExist(unwrapPathError(err)) || strings.Contains(strings.ToLower(err.Error()), "no such file") {
 			return []models.ScriptDraftMeta{}, nil
@@ -1047,11 +1042,7 @@ func (s *ScriptService) ListDraftMetas(ctx context.Context, scriptID string) ([]
 	}
 
 	metas := make([]models.ScriptDraftMeta, 0)
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		name := e.Name()
+	for _, name := range names {
 		if !strings.HasPrefix(name, "draft_") || !strings.HasSuffix(name, ".json") {
 			continue
 		}
@@ -1166,7 +1157,7 @@ func (s *ScriptService) Command(ctx context.Context, id string, req models.Scrip
 	if isExpandScene {
 		maxTokens = 2400
 	}
-	resp, err := s.LLM.CreateChatCompletion(ctx, ChatCompletionRequest{
+	resp, err := s.LLM.CreateChatCompletion(WithLLMTask(ctx, LLMTaskScript), ChatCompletionRequest{
 		Model: model,
 		Messages: []ChatCompletionMessage{
 			{Role: RoleSystem, Content: systemPrompt},
@@ -1829,7 +1820,7 @@ func (s *ScriptService) GenerateInitial(ctx context.Context, id string, tracker
 	outPrompt := buildGenerateInitialOutlinePromptForRange(string(frameworkJSON), start, end, totalChapters)
 	var part *scriptOutline
 	for attempt := 0; attempt < 3; attempt++ {
-		outlineResp, err := s.LLM.CreateChatCompletion(ctx, ChatCompletionRequest{
+		outlineResp, err := s.LLM.CreateChatCompletion(WithLLMTask(ctx, LLMTaskScript), ChatCompletionRequest{
 			Model: model,
 			Messages: []ChatCompletionMessage{
 				{Role: RoleSystem, Content: systemPrompt},
@@ -1906,7 +1897,7 @@ func (s *ScriptService) GenerateInitial(ctx context.Context, id string, tracker
 	ch1 := outline.Chapters[0]
 	bodyPrompt := buildGenerateInitialSceneKeyBeatsPrompt(string(frameworkJSON), ch1)
 
-	bodyResp, err := s.LLM.CreateChatCompletion(ctx, ChatCompletionRequest{
+	bodyResp, err := s.LLM.CreateChatCompletion(WithLLMTask(ctx, LLMTaskScript), ChatCompletionRequest{
 		Model: model,
 		Messages: []ChatCompletionMessage{
 			{Role: RoleSystem, Content: systemPrompt},
@@ -2046,7 +2037,7 @@ func (s *ScriptService) fillOutlineNextRange(ctx context.Context, id string, pro
 	model := s.LLM.GetDefaultModel()
 	var part *scriptOutline
 	for attempt := 0; attempt < 3; attempt++ {
-		outlineResp, err := s.LLM.CreateChatCompletion(ctx, ChatCompletionRequest{
+		outlineResp, err := s.LLM.CreateChatCompletion(WithLLMTask(ctx, LLMTaskScript), ChatCompletionRequest{
 			Model: model,
 			Messages: []ChatCompletionMessage{
 				{Role: RoleSystem, Content: systemPrompt},
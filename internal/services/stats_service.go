@@ -36,6 +36,12 @@ type UsageStats struct {
 	TodayVisionLatencyMinMs int64 `json:"today_vision_latency_min_ms,omitempty"`
 	TodayVisionLatencyMaxMs int64 `json:"today_vision_latency_max_ms,omitempty"`
 
+	// LLM backend stats for today, keyed by "provider/model" of the backend that handled the call.
+	// Failovers counts calls served by a fallback provider instead of the primary one.
+	TodayLLMFailovers  int            `json:"today_llm_failovers,omitempty"`
+	LLMBackendRequests map[string]int `json:"llm_backend_requests,omitempty"`
+	LLMBackendFailures map[string]int `json:"llm_backend_failures,omitempty"`
+
 	LastUpdated time.Time `json:"last_updated"`
 }
 
@@ -109,6 +115,8 @@ func (s *StatsService) initStatsUnlocked() {
 		TodayVisionLatencySumMs: 0,
 		TodayVisionLatencyMinMs: 0,
 		TodayVisionLatencyMaxMs: 0,
+		LLMBackendRequests:      make(map[string]int),
+		LLMBackendFailures:      make(map[string]int),
 		LastUpdated:             time.Now(),
 	}
 
@@ -149,6 +157,9 @@ func (s *StatsService) updateStatsForNewPeriod(stats *UsageStats) {
 		stats.TodayVisionLatencySumMs = 0
 		stats.TodayVisionLatencyMinMs = 0
 		stats.TodayVisionLatencyMaxMs = 0
+		stats.TodayLLMFailovers = 0
+		stats.LLMBackendRequests = make(map[string]int)
+		stats.LLMBackendFailures = make(map[string]int)
 		updated = true
 	}
 
@@ -288,6 +299,8 @@ func (s *StatsService) createStatsCopy() *UsageStats {
 			VisionDailyFailures:   make(map[string]int),
 			VisionMonthlyRequests: make(map[string]int),
 			VisionMonthlyFailures: make(map[string]int),
+			LLMBackendRequests:    make(map[string]int),
+			LLMBackendFailures:    make(map[string]int),
 			LastUpdated:           time.Now(),
 		}
 	}
@@ -307,6 +320,9 @@ func (s *StatsService) createStatsCopy() *UsageStats {
 		TodayVisionLatencySumMs: s.cachedStats.TodayVisionLatencySumMs,
 		TodayVisionLatencyMinMs: s.cachedStats.TodayVisionLatencyMinMs,
 		TodayVisionLatencyMaxMs: s.cachedStats.TodayVisionLatencyMaxMs,
+		TodayLLMFailovers:       s.cachedStats.TodayLLMFailovers,
+		LLMBackendRequests:      copyIntMap(s.cachedStats.LLMBackendRequests),
+		LLMBackendFailures:      copyIntMap(s.cachedStats.LLMBackendFailures),
 		LastUpdated:             s.cachedStats.LastUpdated,
 	}
 }
@@ -376,6 +392,45 @@ func (s *StatsService) RecordVisionRequest(provider string, modelKey string, dur
 	return nil
 }
 
+// RecordLLMRequest 记录一次 LLM 后端调用：按 provider/model 计数与失败数；
+// failover=true 且成功时表示本次由备用 provider 提供结果。token 用量仍由 RecordAPIRequest 统计。
+func (s *StatsService) RecordLLMRequest(provider string, model string, failover bool, err error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cachedStats == nil {
+		s.initStatsUnlocked()
+	}
+	if s.needsPeriodUpdate() {
+		s.updateStatsForCurrentPeriod()
+	}
+	if s.cachedStats.LLMBackendRequests == nil {
+		s.cachedStats.LLMBackendRequests = make(map[string]int)
+	}
+	if s.cachedStats.LLMBackendFailures == nil {
+		s.cachedStats.LLMBackendFailures = make(map[string]int)
+	}
+
+	key := provider
+	if model != "" {
+		key = provider + "/" + model
+	}
+	s.cachedStats.LLMBackendRequests[key]++
+	if err != nil {
+		s.cachedStats.LLMBackendFailures[key]++
+	} else if failover {
+		s.cachedStats.TodayLLMFailovers++
+	}
+
+	now := time.Now()
+	s.cachedStats.LastUpdated = now
+	s.isDirty = true
+	if now.Sub(s.lastSaveTime) > s.saveInterval {
+		return s.saveStatsImmediate()
+	}
+	return nil
+}
+
 // 简化的映射复制
 func copyIntMap(original map[string]int) map[string]int {
 	if original == nil {
@@ -473,6 +528,8 @@ func (s *StatsService) ResetStats() error {
 		TodayVisionLatencySumMs: 0,
 		TodayVisionLatencyMinMs: 0,
 		TodayVisionLatencyMaxMs: 0,
+		LLMBackendRequests:      make(map[string]int),
+		LLMBackendFailures:      make(map[string]int),
 		LastUpdated:             time.Now(),
 	}
 
@@ -32,12 +32,15 @@ type StoryService struct {
 	SceneService     *SceneService
 	ContextService   *ContextService
 	LLMService       *LLMService
-	FileStorage      *storage.FileStorage
+	FileStorage      storage.Store
 	ItemService      *ItemService
 	CharacterService *CharacterService
 	BasePath         string
 	lockManager      *LockManager // 使用统一的锁管理器
 
+	storeMutex    sync.Mutex // 保护 FileStorage / storeBasePath 的按需创建与切换
+	storeBasePath string     // FileStorage 创建时对应的 BasePath，用于检测 BasePath 被修改
+
 	// 缓存机制
 	cacheMutex  sync.RWMutex
 	storyCache  map[string]*CachedStoryData
@@ -72,7 +75,7 @@ func NewStoryServiceWithBasePath(llmService *LLMService, basePath string) *Story
 	}
 
 	// 创建文件存储
-	fileStorage, err := storage.NewFileStorage(basePath)
+	fileStorage, err := storage.Open(basePath)
 	if err != nil {
 		utils.GetLogger().Error("failed to create story file storage", map[string]interface{}{
 			"base_path": basePath,
@@ -102,6 +105,7 @@ func NewStoryServiceWithBasePath(llmService *LLMService, basePath string) *Story
 		ContextService:   contextService,
 		LLMService:       llmService,
 		FileStorage:      fileStorage,
+		storeBasePath:    basePath,
 		ItemService:      itemService,
 		CharacterService: characterService,
 		BasePath:         basePath,
@@ -641,30 +645,13 @@ func (s *StoryService) loadStoryDataSafe(sceneID string) (*models.StoryData, err
 		s.cacheMutex.RUnlock()
 	}
 
-	basePath := strings.TrimSpace(s.BasePath)
-	if basePath == "" {
-		basePath = "data/stories"
-		s.BasePath = basePath
-	}
-	if s.FileStorage == nil {
-		fs, err := storage.NewFileStorage(basePath)
-		if err != nil {
-			return nil, fmt.Errorf("初始化故事存储失败: %w", err)
-		}
-		s.FileStorage = fs
-	}
-	// 兼容：历史代码/测试可能会在构造后直接修改 BasePath。
-	// 为避免绕过 FileStorage 后出现 BaseDir 与 BasePath 不一致，这里做一次轻量同步。
-	if filepath.Clean(s.FileStorage.BaseDir) != filepath.Clean(s.BasePath) {
-		fs, err := storage.NewFileStorage(s.BasePath)
-		if err != nil {
-			return nil, fmt.Errorf("初始化故事存储失败: %w", err)
-		}
-		s.FileStorage = fs
+	store, err := s.storyStore()
+	if err != nil {
+		return nil, err
 	}
 
 	// 缓存过期或不存在，需要重新加载
-	if !s.FileStorage.FileExists(sceneID, "story.json") {
+	if !store.FileExists(sceneID, "story.json") {
 		return nil, fmt.Errorf("故事数据不存在")
 	}
 
@@ -688,7 +675,7 @@ func (s *StoryService) loadStoryDataSafe(sceneID string) (*models.StoryData, err
 	// 使用 sync.Once 确保只加载一次
 	loading.Do(func() {
 		var storyData models.StoryData
-		if err := s.FileStorage.LoadJSONFile(sceneID, "story.json", &storyData); err != nil {
+		if err := store.LoadJSONFile(sceneID, "story.json", &storyData); err != nil {
 			loadErr = fmt.Errorf("读取故事数据失败: %w", err)
 			return
 		}
@@ -715,6 +702,46 @@ func (s *StoryService) loadStoryDataSafe(sceneID string) (*models.StoryData, err
 	return loadedData, nil
 }
 
+// storyStore 返回与 BasePath 对应的存储实例（按需创建）
+func (s *StoryService) storyStore() (storage.Store, error) {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+
+	basePath := strings.TrimSpace(s.BasePath)
+	if basePath == "" {
+		basePath = "data/stories"
+		s.BasePath = basePath
+	}
+	// 兼容：历史代码/测试可能会在构造后直接修改 BasePath。
+	// 为避免绕过存储后出现根目录与 BasePath 不一致，这里做一次轻量同步。
+	if s.FileStorage == nil || (s.storeBasePath != "" && filepath.Clean(s.storeBasePath) != filepath.Clean(basePath)) {
+		fs, err := storage.Open(basePath)
+		if err != nil {
+			return nil, fmt.Errorf("初始化故事存储失败: %w", err)
+		}
+		s.FileStorage = fs
+		s.storeBasePath = basePath
+	}
+	return s.FileStorage, nil
+}
+
+// readStoryFile 读取 story.json 的原始内容；exists 为 false 表示故事尚未初始化
+func (s *StoryService) readStoryFile(sceneID string) ([]byte, bool, error) {
+	store, err := s.storyStore()
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := store.LoadTextFile(sceneID, "story.json")
+	if storage.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("读取故事数据失败: %w", err)
+	}
+	return data, true, nil
+}
+
 // 缓存失效方法
 func (s *StoryService) invalidateStoryCache(sceneID string) {
 	s.cacheMutex.Lock()
@@ -886,7 +913,7 @@ Return in JSON format:
 	defer cancel()
 
 	resp, err := s.LLMService.CreateChatCompletion(
-		ctx,
+		WithLLMScene(WithLLMTask(ctx, LLMTaskStory), sceneData.Scene.ID),
 		ChatCompletionRequest{
 			Model: s.getLLMModel(preferences),
 			Messages: []ChatCompletionMessage{
@@ -1128,7 +1155,7 @@ Return in JSON format:
 	return storyData, nil
 }
 
-// getLLMModel 根据用户偏好和可用配置获取合适的LLM模型名称
+// getLLMModel 根据用户偏好和可用配置获取合适的LLM模型名称；偏好模型优先于 LLM 任务路由
 func (s *StoryService) getLLMModel(preferences *models.UserPreferences) string {
 	// 如果提供了用户偏好设置，并且用户有指定模型
 	if preferences != nil && preferences.PreferredModel != "" {
@@ -1154,23 +1181,13 @@ func (s *StoryService) saveStoryData(sceneID string, storyData *models.StoryData
 		return fmt.Errorf("序列化故事数据失败: %w", err)
 	}
 
-	// 确保目录存在
-	storyDir := filepath.Join(s.BasePath, sceneID)
-	if err := os.MkdirAll(storyDir, 0755); err != nil {
-		return fmt.Errorf("创建故事目录失败: %w", err)
-	}
-
-	storyPath := filepath.Join(storyDir, "story.json")
-
-	// 🔧 原子性文件写入
-	tempPath := storyPath + ".tmp"
-
-	if err := os.WriteFile(tempPath, storyDataJSON, 0644); err != nil {
-		return fmt.Errorf("保存故事数据失败: %w", err)
+	store, err := s.storyStore()
+	if err != nil {
+		return err
 	}
 
-	if err := os.Rename(tempPath, storyPath); err != nil {
-		os.Remove(tempPath) // 清理临时文件
+	// 存储层负责原子写入（文件后端为临时文件 + rename，SQLite 后端为单行 UPSERT）
+	if err := store.SaveTextFile(sceneID, "story.json", storyDataJSON); err != nil {
 		return fmt.Errorf("保存故事数据失败: %w", err)
 	}
 
@@ -1440,7 +1457,7 @@ Return JSON:
 }`, summary, consoleSection)
 	}
 
-	resp, err := s.LLMService.CreateChatCompletion(ctx, ChatCompletionRequest{
+	resp, err := s.LLMService.CreateChatCompletion(WithLLMScene(WithLLMTask(ctx, LLMTaskStory), sceneID), ChatCompletionRequest{
 		Model: s.getLLMModel(preferences),
 		Messages: []ChatCompletionMessage{
 			{Role: "system", Content: systemPrompt},
@@ -1653,15 +1670,7 @@ func (s *StoryService) persistOriginalSegmentsForScene(sceneData *SceneData, seg
 	if sceneID == "" {
 		return
 	}
-	sceneDir := filepath.Join(s.SceneService.BasePath, sceneID)
-	if err := os.MkdirAll(sceneDir, 0755); err != nil {
-		utils.GetLogger().Warn("failed to create scene directory for original segments", map[string]interface{}{
-			"scene_id": sceneID,
-			"err":      err.Error(),
-		})
-		return
-	}
-	if err := s.SceneService.saveOriginalSegments(sceneDir, segments); err != nil {
+	if err := s.SceneService.saveOriginalSegments(sceneID, segments); err != nil {
 		utils.GetLogger().Warn("failed to save original segments", map[string]interface{}{
 			"scene_id": sceneID,
 			"err":      err.Error(),
@@ -1841,7 +1850,7 @@ func (s *StoryService) enrichNodeFromSegment(node *models.StoryNode, storyContex
 	}
 
 	systemPrompt, userPrompt := buildSegmentPrompts(storyContext, node.OriginalContent, node.Type, isEnglish)
-	resp, err := s.LLMService.CreateChatCompletion(ctx, ChatCompletionRequest{
+	resp, err := s.LLMService.CreateChatCompletion(WithLLMScene(WithLLMTask(ctx, LLMTaskStory), node.SceneID), ChatCompletionRequest{
 		Model: s.getLLMModel(preferences),
 		Messages: []ChatCompletionMessage{
 			{Role: "system", Content: systemPrompt},
@@ -2255,7 +2264,7 @@ Task:
 `, summary, original)
 	}
 
-	resp, err := s.LLMService.CreateChatCompletion(ctx, ChatCompletionRequest{
+	resp, err := s.LLMService.CreateChatCompletion(WithLLMScene(WithLLMTask(ctx, LLMTaskStory), node.SceneID), ChatCompletionRequest{
 		Model: s.getLLMModel(preferences),
 		Messages: []ChatCompletionMessage{
 			{Role: "system", Content: systemPrompt},
@@ -2383,7 +2392,7 @@ user_new_message:
 `, original, processingSummary, userMessage)
 	}
 
-	resp, err := s.LLMService.CreateChatCompletion(ctx, ChatCompletionRequest{
+	resp, err := s.LLMService.CreateChatCompletion(WithLLMTask(ctx, LLMTaskStory), ChatCompletionRequest{
 		Model: s.getLLMModel(preferences),
 		Messages: []ChatCompletionMessage{
 			{Role: "system", Content: systemPrompt},
@@ -2456,10 +2465,12 @@ func (s *StoryService) GetStoryData(sceneID string, preferences *models.UserPref
 	var storyData *models.StoryData
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-
 		// 在锁内检查和读取
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
+		if err != nil {
+			return err
+		}
+		if !exists {
 			// 如果不存在，创建初始故事数据
 			data, err := s.InitializeStoryForScene(sceneID, preferences)
 			if err != nil {
@@ -2472,12 +2483,7 @@ func (s *StoryService) GetStoryData(sceneID string, preferences *models.UserPref
 			return nil
 		}
 
-		// 读取故事数据
-		storyDataBytes, err := os.ReadFile(storyPath)
-		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
-		}
-
+		// 解析故事数据
 		var tempData models.StoryData
 		if err := json.Unmarshal(storyDataBytes, &tempData); err != nil {
 			return fmt.Errorf("解析故事数据失败: %w", err)
@@ -2836,7 +2842,7 @@ Respond with a JSON object in the following format:
 	defer cancel()
 
 	resp, err := s.LLMService.CreateChatCompletion(
-		ctx,
+		WithLLMScene(WithLLMTask(ctx, LLMTaskStory), sceneID),
 		ChatCompletionRequest{
 			Model: s.getLLMModel(preferences),
 			Messages: []ChatCompletionMessage{
@@ -3136,14 +3142,12 @@ func (s *StoryService) ExploreLocation(sceneID, locationID string, preferences *
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 直接读取文件
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -3275,7 +3279,7 @@ Return in JSON format:
 		defer cancel()
 
 		resp, err := s.LLMService.CreateChatCompletion(
-			ctx,
+			WithLLMScene(WithLLMTask(ctx, LLMTaskStory), sceneID),
 			ChatCompletionRequest{
 				Model: s.getLLMModel(preferences),
 				Messages: []ChatCompletionMessage{
@@ -3765,14 +3769,12 @@ func (s *StoryService) GetAvailableChoices(sceneID string) ([]models.StoryChoice
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 🔧 在锁内直接读取文件
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -3824,14 +3826,12 @@ func (s *StoryService) UpdateNodeChoices(sceneID, nodeID string, choices []model
 	}
 
 	return s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -3902,14 +3902,12 @@ func (s *StoryService) AdvanceStory(sceneID string, preferences *models.UserPref
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 只读加载 story.json
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -3997,7 +3995,7 @@ func (s *StoryService) AdvanceStory(sceneID string, preferences *models.UserPref
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
 		defer cancel()
-		resp, err := s.LLMService.CreateChatCompletion(ctx, ChatCompletionRequest{
+		resp, err := s.LLMService.CreateChatCompletion(WithLLMScene(WithLLMTask(ctx, LLMTaskStory), sceneID), ChatCompletionRequest{
 			Model:    s.getLLMModel(preferences),
 			Messages: []ChatCompletionMessage{{Role: "system", Content: systemPrompt}, {Role: "user", Content: userPrompt}},
 		})
@@ -4046,14 +4044,12 @@ func (s *StoryService) CreateStoryBranch(sceneID string, triggerType string, tri
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 直接读取文件
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -4209,7 +4205,7 @@ Return in JSON format:
 		defer cancel()
 
 		resp, err := s.LLMService.CreateChatCompletion(
-			ctx,
+			WithLLMScene(WithLLMTask(ctx, LLMTaskStory), sceneID),
 			ChatCompletionRequest{
 				Model: s.getLLMModel(preferences),
 				Messages: []ChatCompletionMessage{
@@ -4317,14 +4313,12 @@ func (s *StoryService) EvaluateStoryProgress(sceneID string) (*models.StoryProgr
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 在锁内直接读取文件
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -4561,14 +4555,12 @@ func (s *StoryService) RewindToNode(sceneID, nodeID string) (*models.StoryData,
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 直接读取文件
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var tempStoryData models.StoryData
@@ -4647,6 +4639,52 @@ func (s *StoryService) RewindToNode(sceneID, nodeID string) (*models.StoryData,
 	return storyData, nil
 }
 
+// LoadStorySnapshot 读取 story.json 的原样内容（不初始化、不叠加上下文揭示），用于存档；
+// 故事尚未初始化时返回 nil
+func (s *StoryService) LoadStorySnapshot(sceneID string) (*models.StoryData, error) {
+	var storyData *models.StoryData
+
+	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
+		if err != nil || !exists {
+			return err
+		}
+		var tempStoryData models.StoryData
+		if err := json.Unmarshal(storyDataBytes, &tempStoryData); err != nil {
+			return fmt.Errorf("解析故事数据失败: %w", err)
+		}
+		storyData = &tempStoryData
+		return nil
+	})
+
+	return storyData, err
+}
+
+// RestoreStorySnapshot 用存档中的故事数据整体替换当前故事（读档）。
+// storyData 为 nil 表示存档时还没有故事，此时删除当前故事数据。
+func (s *StoryService) RestoreStorySnapshot(sceneID string, storyData *models.StoryData) error {
+	return s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
+		if storyData == nil {
+			store, err := s.storyStore()
+			if err != nil {
+				return err
+			}
+			if store.FileExists(sceneID, "story.json") {
+				if err := store.DeleteFile(sceneID, "story.json"); err != nil {
+					return fmt.Errorf("删除故事数据失败: %w", err)
+				}
+			}
+			s.invalidateStoryCache(sceneID)
+			return nil
+		}
+		if err := s.saveStoryData(sceneID, storyData); err != nil {
+			return err
+		}
+		s.invalidateStoryCache(sceneID)
+		return nil
+	})
+}
+
 // 计算基于指定节点的故事进度
 func calculateProgress(storyData *models.StoryData, referenceNode *models.StoryNode) int {
 	_ = referenceNode
@@ -4693,14 +4731,12 @@ func (s *StoryService) ProcessCharacterInteractionTriggers(sceneID string, nodeI
 
 	err := s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 在锁内读取所需的数据
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
@@ -4850,27 +4886,18 @@ func (s *StoryService) DeleteStoryData(sceneID string) error {
 	}
 
 	return s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
-		storyDir := filepath.Join(s.BasePath, sceneID)
-
-		// 优先使用文件存储接口，确保缓存一致
-		if s.FileStorage != nil {
-			if !s.FileStorage.DirExists(sceneID) {
-				s.invalidateStoryCache(sceneID)
-				return nil
-			}
-
-			if err := s.FileStorage.DeleteDir(sceneID); err != nil {
-				return fmt.Errorf("删除故事数据失败: %w", err)
-			}
-		} else {
-			if _, err := os.Stat(storyDir); os.IsNotExist(err) {
-				s.invalidateStoryCache(sceneID)
-				return nil
-			}
+		// 通过存储接口删除，确保缓存一致
+		store, err := s.storyStore()
+		if err != nil {
+			return err
+		}
+		if !store.DirExists(sceneID) {
+			s.invalidateStoryCache(sceneID)
+			return nil
+		}
 
-			if err := os.RemoveAll(storyDir); err != nil {
-				return fmt.Errorf("删除故事数据失败: %w", err)
-			}
+		if err := store.DeleteDir(sceneID); err != nil {
+			return fmt.Errorf("删除故事数据失败: %w", err)
 		}
 
 		s.invalidateStoryCache(sceneID)
@@ -4882,14 +4909,12 @@ func (s *StoryService) DeleteStoryData(sceneID string) error {
 func (s *StoryService) ExecuteBatchOperation(sceneID string, operation func(*models.StoryData) error) error {
 	return s.lockManager.ExecuteWithSceneLock(sceneID, func() error {
 		// 直接读取文件，避免死锁
-		storyPath := filepath.Join(s.BasePath, sceneID, "story.json")
-		if _, err := os.Stat(storyPath); os.IsNotExist(err) {
-			return fmt.Errorf("故事数据不存在")
-		}
-
-		storyDataBytes, err := os.ReadFile(storyPath)
+		storyDataBytes, exists, err := s.readStoryFile(sceneID)
 		if err != nil {
-			return fmt.Errorf("读取故事数据失败: %w", err)
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("故事数据不存在")
 		}
 
 		var storyData models.StoryData
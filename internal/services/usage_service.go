@@ -0,0 +1,652 @@
+// internal/services/usage_service.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/config"
+	"github.com/Corphon/SceneIntruderMCP/internal/llm"
+	"github.com/Corphon/SceneIntruderMCP/internal/storage"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+const (
+	// UsageUnattributedUser 无法确定用户的调用（没有用户上下文、场景也没有所有者）记在这个名下
+	UsageUnattributedUser = "unattributed"
+	// usageOtherTask 未标记任务类别的调用
+	usageOtherTask = "other"
+
+	usageSaveInterval = 30 * time.Second
+)
+
+var (
+	// ErrUsageQuotaExceeded is returned by CheckQuota when a daily or monthly limit is reached.
+	ErrUsageQuotaExceeded = errors.New("usage quota exceeded")
+	// ErrInvalidUsageMonth is returned for a month that is not in YYYY-MM form.
+	ErrInvalidUsageMonth = errors.New("invalid usage month")
+)
+
+// UsageTotals 一组调用的累计用量与费用
+type UsageTotals struct {
+	Requests     int     `json:"requests"`
+	PromptTokens int64   `json:"prompt_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	// EstimatedRequests 中的 token 为按文本长度估算（流式调用或提供商未返回用量）
+	EstimatedRequests int `json:"estimated_requests,omitempty"`
+	// UnpricedRequests 为价格表中没有对应 provider/model 的调用，费用按 0 计
+	UnpricedRequests int `json:"unpriced_requests,omitempty"`
+}
+
+func (t *UsageTotals) add(r *LLMUsageRecord, cost float64, priced bool) {
+	t.Requests++
+	t.PromptTokens += r.PromptTokens
+	t.OutputTokens += r.OutputTokens
+	t.TotalTokens += r.TotalTokens
+	t.CostUSD += cost
+	if r.Estimated {
+		t.EstimatedRequests++
+	}
+	if !priced {
+		t.UnpricedRequests++
+	}
+}
+
+// UserUsage 单个用户在一个月内的用量：合计、按天、按场景、按任务类别与按 provider/model
+type UserUsage struct {
+	Total  UsageTotals             `json:"total"`
+	Daily  map[string]*UsageTotals `json:"daily"`
+	Scenes map[string]*UsageTotals `json:"scenes,omitempty"`
+	Tasks  map[string]*UsageTotals `json:"tasks,omitempty"`
+	Models map[string]*UsageTotals `json:"models,omitempty"`
+}
+
+// UsageLedger 一个自然月的用量账本，每月一个文件
+type UsageLedger struct {
+	Month     string                `json:"month"`
+	Users     map[string]*UserUsage `json:"users"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// LLMUsageRecord 一次 LLM 调用的用量
+type LLMUsageRecord struct {
+	UserID       string
+	SceneID      string
+	Task         string
+	Provider     string
+	Model        string
+	PromptTokens int64
+	OutputTokens int64
+	TotalTokens  int64
+	Estimated    bool
+}
+
+// UsageQuotaStatus 用户当前的配额与剩余额度；Remaining* 为 nil 表示该项不限制
+type UsageQuotaStatus struct {
+	Quota                   config.UsageQuotaInfo `json:"quota"`
+	RemainingDailyTokens    *int64                `json:"remaining_daily_tokens,omitempty"`
+	RemainingMonthlyTokens  *int64                `json:"remaining_monthly_tokens,omitempty"`
+	RemainingDailyCostUSD   *float64              `json:"remaining_daily_cost_usd,omitempty"`
+	RemainingMonthlyCostUSD *float64              `json:"remaining_monthly_cost_usd,omitempty"`
+}
+
+// UsageReport 用户某月的用量报告
+type UsageReport struct {
+	UserID string            `json:"user_id"`
+	Month  string            `json:"month"`
+	Today  UsageTotals       `json:"today"`
+	Usage  *UserUsage        `json:"usage"`
+	Quota  *UsageQuotaStatus `json:"quota,omitempty"`
+}
+
+// UsageQuotaError 描述触发的配额，供中间件设置 Retry-After
+type UsageQuotaError struct {
+	Limit   string    // daily_tokens / monthly_tokens / daily_cost_usd / monthly_cost_usd
+	Used    float64   // 已用量
+	Max     float64   // 上限
+	ResetAt time.Time // 配额重置时间
+}
+
+func (e *UsageQuotaError) Error() string {
+	return fmt.Sprintf("%s: %s %.4g / %.4g, resets at %s", ErrUsageQuotaExceeded, e.Limit, e.Used, e.Max, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *UsageQuotaError) Unwrap() error { return ErrUsageQuotaExceeded }
+
+// UsageService 按用户、场景与任务类别记录 LLM 用量，按价格表计算费用，并检查每日/每月配额
+type UsageService struct {
+	BasePath string
+	Storage  storage.Store
+
+	// SceneOwner 根据场景 ID 查询所有者；上下文中没有用户时用它归属场景内的调用
+	SceneOwner func(sceneID string) string
+
+	mu           sync.Mutex
+	ledgers      map[string]*UsageLedger // month -> ledger
+	dirty        map[string]bool
+	sceneOwners  map[string]string
+	prices       map[string]config.LLMPriceInfo
+	defaultQuota config.UsageQuotaInfo
+	userQuotas   map[string]config.UsageQuotaInfo
+
+	now func() time.Time
+}
+
+// NewUsageService 创建用量服务，价格表与配额取自当前配置
+func NewUsageService(basePath string) *UsageService {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		utils.GetLogger().Warn("创建用量目录失败", map[string]interface{}{"base_path": basePath, "err": err})
+	}
+	store, err := storage.Open(basePath)
+	if err != nil {
+		utils.GetLogger().Warn("创建用量存储失败", map[string]interface{}{"base_path": basePath, "err": err})
+	}
+	s := &UsageService{
+		BasePath:    basePath,
+		Storage:     store,
+		ledgers:     make(map[string]*UsageLedger),
+		dirty:       make(map[string]bool),
+		sceneOwners: make(map[string]string),
+		now:         time.Now,
+	}
+	if cfg := config.GetCurrentConfig(); cfg != nil {
+		s.UpdatePrices(cfg.LLMPrices)
+		s.UpdateQuotas(cfg.UsageQuota, cfg.UserUsageQuotas)
+	}
+	s.startPeriodicSave()
+	return s
+}
+
+// UpdatePrices 替换价格表
+func (s *UsageService) UpdatePrices(prices map[string]config.LLMPriceInfo) {
+	copied := make(map[string]config.LLMPriceInfo, len(prices))
+	maps.Copy(copied, prices)
+	s.mu.Lock()
+	s.prices = copied
+	s.mu.Unlock()
+}
+
+// UpdateQuotas 替换默认配额与按用户覆盖的配额
+func (s *UsageService) UpdateQuotas(defaultQuota config.UsageQuotaInfo, userQuotas map[string]config.UsageQuotaInfo) {
+	copied := make(map[string]config.UsageQuotaInfo, len(userQuotas))
+	maps.Copy(copied, userQuotas)
+	s.mu.Lock()
+	s.defaultQuota = defaultQuota
+	s.userQuotas = copied
+	s.mu.Unlock()
+}
+
+// Record 记一次调用的用量；用户为空时按场景所有者归属
+func (s *UsageService) Record(r LLMUsageRecord) {
+	if s == nil {
+		return
+	}
+	r.UserID = s.ResolveUser(r.UserID, r.SceneID)
+	if r.Task == "" {
+		r.Task = usageOtherTask
+	}
+	if r.TotalTokens < r.PromptTokens+r.OutputTokens {
+		r.TotalTokens = r.PromptTokens + r.OutputTokens
+	}
+
+	now := s.now()
+	month := now.Format("2006-01")
+	day := now.Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cost, priced := s.costUnlocked(r.Provider, r.Model, r.PromptTokens, r.OutputTokens)
+	ledger := s.ledgerUnlocked(month)
+	user := ledger.Users[r.UserID]
+	if user == nil {
+		user = &UserUsage{}
+		ledger.Users[r.UserID] = user
+	}
+	user.Total.add(&r, cost, priced)
+	addUsageBucket(&user.Daily, day, &r, cost, priced)
+	if r.SceneID != "" {
+		addUsageBucket(&user.Scenes, r.SceneID, &r, cost, priced)
+	}
+	addUsageBucket(&user.Tasks, r.Task, &r, cost, priced)
+	addUsageBucket(&user.Models, usageModelKey(r.Provider, r.Model), &r, cost, priced)
+
+	ledger.UpdatedAt = now
+	s.dirty[month] = true
+}
+
+// ResolveUser 返回用量归属的用户：优先使用给定用户，其次是场景所有者，都没有时为 UsageUnattributedUser
+func (s *UsageService) ResolveUser(userID, sceneID string) string {
+	if userID = strings.TrimSpace(userID); userID != "" {
+		return userID
+	}
+	if sceneID != "" {
+		if owner := s.sceneOwner(sceneID); owner != "" {
+			return owner
+		}
+	}
+	return UsageUnattributedUser
+}
+
+func addUsageBucket(buckets *map[string]*UsageTotals, key string, r *LLMUsageRecord, cost float64, priced bool) {
+	if *buckets == nil {
+		*buckets = make(map[string]*UsageTotals)
+	}
+	t := (*buckets)[key]
+	if t == nil {
+		t = &UsageTotals{}
+		(*buckets)[key] = t
+	}
+	t.add(r, cost, priced)
+}
+
+func usageModelKey(provider, model string) string {
+	if model == "" {
+		return provider
+	}
+	return provider + "/" + model
+}
+
+// costUnlocked 按价格表计算费用：先查 provider/model，再查 provider/*
+func (s *UsageService) costUnlocked(provider, model string, promptTokens, outputTokens int64) (float64, bool) {
+	price, ok := s.prices[provider+"/"+model]
+	if !ok {
+		price, ok = s.prices[provider+"/*"]
+	}
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.InputPerMillion + float64(outputTokens)*price.OutputPerMillion) / 1e6, true
+}
+
+// sceneOwner 查询并缓存场景所有者
+func (s *UsageService) sceneOwner(sceneID string) string {
+	s.mu.Lock()
+	owner, ok := s.sceneOwners[sceneID]
+	resolver := s.SceneOwner
+	s.mu.Unlock()
+	if ok || resolver == nil {
+		return owner
+	}
+	owner = resolver(sceneID)
+	s.mu.Lock()
+	s.sceneOwners[sceneID] = owner
+	s.mu.Unlock()
+	return owner
+}
+
+// quotaForUnlocked 返回用户的配额：有单独配置时使用单独配置，否则使用默认配额
+func (s *UsageService) quotaForUnlocked(userID string) config.UsageQuotaInfo {
+	if q, ok := s.userQuotas[userID]; ok {
+		return q
+	}
+	return s.defaultQuota
+}
+
+// CheckQuota 检查用户是否已用完当天或当月的配额；超出时返回 *UsageQuotaError
+func (s *UsageService) CheckQuota(userID string) error {
+	if s == nil {
+		return nil
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quota := s.quotaForUnlocked(userID)
+	if quota.IsZero() {
+		return nil
+	}
+	today, month := s.totalsUnlocked(userID, now)
+
+	nextDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	switch {
+	case quota.DailyTokens > 0 && today.TotalTokens >= quota.DailyTokens:
+		return &UsageQuotaError{Limit: "daily_tokens", Used: float64(today.TotalTokens), Max: float64(quota.DailyTokens), ResetAt: nextDay}
+	case quota.DailyCostUSD > 0 && today.CostUSD >= quota.DailyCostUSD:
+		return &UsageQuotaError{Limit: "daily_cost_usd", Used: today.CostUSD, Max: quota.DailyCostUSD, ResetAt: nextDay}
+	case quota.MonthlyTokens > 0 && month.TotalTokens >= quota.MonthlyTokens:
+		return &UsageQuotaError{Limit: "monthly_tokens", Used: float64(month.TotalTokens), Max: float64(quota.MonthlyTokens), ResetAt: nextMonth}
+	case quota.MonthlyCostUSD > 0 && month.CostUSD >= quota.MonthlyCostUSD:
+		return &UsageQuotaError{Limit: "monthly_cost_usd", Used: month.CostUSD, Max: quota.MonthlyCostUSD, ResetAt: nextMonth}
+	}
+	return nil
+}
+
+// totalsUnlocked 返回用户今天与本月的合计
+func (s *UsageService) totalsUnlocked(userID string, now time.Time) (UsageTotals, UsageTotals) {
+	var today, month UsageTotals
+	user := s.ledgerUnlocked(now.Format("2006-01")).Users[userID]
+	if user == nil {
+		return today, month
+	}
+	if t := user.Daily[now.Format("2006-01-02")]; t != nil {
+		today = *t
+	}
+	return today, user.Total
+}
+
+// GetUserReport 返回用户某月（YYYY-MM，空为本月）的用量；本月报告附带配额与剩余额度
+func (s *UsageService) GetUserReport(userID string, month string) (*UsageReport, error) {
+	now := s.now()
+	currentMonth := now.Format("2006-01")
+	month = strings.TrimSpace(month)
+	if month == "" {
+		month = currentMonth
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return nil, fmt.Errorf("%w: %q (YYYY-MM)", ErrInvalidUsageMonth, month)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &UsageReport{UserID: userID, Month: month, Usage: &UserUsage{}}
+	if user := s.ledgerUnlocked(month).Users[userID]; user != nil {
+		report.Usage = copyUserUsage(user)
+	}
+	if month != currentMonth {
+		return report, nil
+	}
+
+	today, monthTotals := s.totalsUnlocked(userID, now)
+	report.Today = today
+	quota := s.quotaForUnlocked(userID)
+	status := &UsageQuotaStatus{Quota: quota}
+	if quota.DailyTokens > 0 {
+		v := max(quota.DailyTokens-today.TotalTokens, 0)
+		status.RemainingDailyTokens = &v
+	}
+	if quota.MonthlyTokens > 0 {
+		v := max(quota.MonthlyTokens-monthTotals.TotalTokens, 0)
+		status.RemainingMonthlyTokens = &v
+	}
+	if quota.DailyCostUSD > 0 {
+		v := max(quota.DailyCostUSD-today.CostUSD, 0)
+		status.RemainingDailyCostUSD = &v
+	}
+	if quota.MonthlyCostUSD > 0 {
+		v := max(quota.MonthlyCostUSD-monthTotals.CostUSD, 0)
+		status.RemainingMonthlyCostUSD = &v
+	}
+	report.Quota = status
+	return report, nil
+}
+
+func copyUserUsage(u *UserUsage) *UserUsage {
+	out := &UserUsage{Total: u.Total}
+	copyBuckets := func(src map[string]*UsageTotals) map[string]*UsageTotals {
+		if src == nil {
+			return nil
+		}
+		dst := make(map[string]*UsageTotals, len(src))
+		for k, v := range src {
+			t := *v
+			dst[k] = &t
+		}
+		return dst
+	}
+	out.Daily = copyBuckets(u.Daily)
+	out.Scenes = copyBuckets(u.Scenes)
+	out.Tasks = copyBuckets(u.Tasks)
+	out.Models = copyBuckets(u.Models)
+	return out
+}
+
+// ledgerUnlocked 返回某月账本，首次访问时从文件加载
+func (s *UsageService) ledgerUnlocked(month string) *UsageLedger {
+	if ledger, ok := s.ledgers[month]; ok {
+		return ledger
+	}
+	ledger := &UsageLedger{Month: month, Users: make(map[string]*UserUsage)}
+	data, err := s.loadLedger(month)
+	if err == nil {
+		var loaded UsageLedger
+		if jsonErr := json.Unmarshal(data, &loaded); jsonErr != nil {
+			utils.GetLogger().Warn("用量账本解析失败，将重新开始记录", map[string]interface{}{"month": month, "err": jsonErr})
+		} else {
+			ledger = &loaded
+			ledger.Month = month
+			if ledger.Users == nil {
+				ledger.Users = make(map[string]*UserUsage)
+			}
+		}
+	} else if !storage.IsNotExist(err) {
+		utils.GetLogger().Warn("读取用量账本失败", map[string]interface{}{"month": month, "err": err})
+	}
+	s.ledgers[month] = ledger
+	return ledger
+}
+
+// usageStore 返回账本存储（兼容直接构造的 UsageService）；调用方需持有 s.mu
+func (s *UsageService) usageStore() (storage.Store, error) {
+	if s.Storage == nil {
+		store, err := storage.Open(s.BasePath)
+		if err != nil {
+			return nil, fmt.Errorf("初始化用量存储失败: %w", err)
+		}
+		s.Storage = store
+	}
+	return s.Storage, nil
+}
+
+func ledgerFilename(month string) string {
+	return month + ".json"
+}
+
+func (s *UsageService) loadLedger(month string) ([]byte, error) {
+	store, err := s.usageStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.LoadTextFile("", ledgerFilename(month))
+}
+
+// saveUnlocked 写出有变更的账本；非本月且已保存的账本从内存中移除
+func (s *UsageService) saveUnlocked() error {
+	currentMonth := s.now().Format("2006-01")
+	var firstErr error
+	for month, ledger := range s.ledgers {
+		if s.dirty[month] {
+			if err := s.writeLedger(ledger); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			delete(s.dirty, month)
+		}
+		if month != currentMonth {
+			delete(s.ledgers, month)
+		}
+	}
+	return firstErr
+}
+
+func (s *UsageService) writeLedger(ledger *UsageLedger) error {
+	store, err := s.usageStore()
+	if err != nil {
+		return err
+	}
+	if err := store.SaveJSONFile("", ledgerFilename(ledger.Month), ledger); err != nil {
+		return fmt.Errorf("failed to save usage ledger: %w", err)
+	}
+	return nil
+}
+
+// 定时保存机制
+func (s *UsageService) startPeriodicSave() {
+	go func() {
+		ticker := time.NewTicker(usageSaveInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.mu.Lock()
+			if err := s.saveUnlocked(); err != nil {
+				utils.GetLogger().Warn("定时保存用量账本失败", map[string]interface{}{"base_path": s.BasePath, "err": err})
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// Close 保存未写出的用量
+func (s *UsageService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveUnlocked()
+}
+
+// ---- 调用归属 ----
+
+type llmUsageScopeKey struct{}
+
+type llmUsageScope struct {
+	userID  string
+	sceneID string
+}
+
+// WithLLMUsageScope 在上下文中记录本次调用归属的用户与场景；空值沿用外层上下文中的值
+func WithLLMUsageScope(ctx context.Context, userID, sceneID string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	scope, _ := ctx.Value(llmUsageScopeKey{}).(llmUsageScope)
+	if userID = strings.TrimSpace(userID); userID != "" {
+		scope.userID = userID
+	}
+	if sceneID = strings.TrimSpace(sceneID); sceneID != "" {
+		scope.sceneID = sceneID
+	}
+	return context.WithValue(ctx, llmUsageScopeKey{}, scope)
+}
+
+// WithLLMScene 标记调用所属的场景；用户未知时用量按场景所有者归属
+func WithLLMScene(ctx context.Context, sceneID string) context.Context {
+	return WithLLMUsageScope(ctx, "", sceneID)
+}
+
+// LLMUsageScopeFromContext 返回上下文中记录的用户与场景
+func LLMUsageScopeFromContext(ctx context.Context) (userID, sceneID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	scope, _ := ctx.Value(llmUsageScopeKey{}).(llmUsageScope)
+	return scope.userID, scope.sceneID
+}
+
+// estimateTokens 粗略估算 token 数：CJK 字符各算 1 个，其余字符每 4 个算 1 个
+func estimateTokens(text string) int64 {
+	var cjk, other int64
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// ---- LLMService 接入 ----
+
+// checkUsageQuota 在每次调用提供商之前检查归属用户的配额，后台任务（战役、分块分析）因此在中途达到上限时停止。
+// 上下文中既无用户也无场景的系统调用不受配额限制。
+func (s *LLMService) checkUsageQuota(ctx context.Context) error {
+	if s.Usage == nil {
+		return nil
+	}
+	userID, sceneID := LLMUsageScopeFromContext(ctx)
+	if userID == "" && sceneID == "" {
+		return nil
+	}
+	return s.Usage.CheckQuota(s.Usage.ResolveUser(userID, sceneID))
+}
+
+// recordCompletionUsage 记录一次成功调用的用量；提供商未返回 token 数时按文本长度估算
+func (s *LLMService) recordCompletionUsage(ctx context.Context, req llm.CompletionRequest, resp *llm.CompletionResponse) {
+	if s.Usage == nil || resp == nil {
+		return
+	}
+	userID, sceneID := LLMUsageScopeFromContext(ctx)
+	record := LLMUsageRecord{
+		UserID:       userID,
+		SceneID:      sceneID,
+		Task:         LLMTaskFromContext(ctx),
+		Provider:     resp.ProviderName,
+		Model:        resp.ModelName,
+		PromptTokens: int64(resp.PromptTokens),
+		OutputTokens: int64(resp.OutputTokens),
+		TotalTokens:  int64(resp.TokensUsed),
+	}
+	if record.PromptTokens == 0 && record.OutputTokens == 0 {
+		record.PromptTokens = estimateTokens(req.SystemPrompt) + estimateTokens(req.Prompt)
+		record.OutputTokens = estimateTokens(resp.Text)
+		if record.TotalTokens == 0 {
+			record.Estimated = true
+		} else if record.TotalTokens > record.OutputTokens {
+			// 只有总数时以总数为准，输入/输出按估算的输出拆分
+			record.PromptTokens = record.TotalTokens - record.OutputTokens
+		}
+	}
+	s.Usage.Record(record)
+}
+
+// meterStream 转发流式结果，流结束（或调用方取消）后按文本长度估算用量并记录
+func (s *LLMService) meterStream(ctx context.Context, req llm.CompletionRequest, provider, model string, stream <-chan llm.StreamResponse) <-chan llm.StreamResponse {
+	if s.Usage == nil || stream == nil {
+		return stream
+	}
+	userID, sceneID := LLMUsageScopeFromContext(ctx)
+	task := LLMTaskFromContext(ctx)
+
+	out := make(chan llm.StreamResponse)
+	go func() {
+		defer close(out)
+		var streamed strings.Builder
+		final := ""
+		defer func() {
+			text := final
+			if text == "" {
+				text = streamed.String()
+			}
+			s.Usage.Record(LLMUsageRecord{
+				UserID:       userID,
+				SceneID:      sceneID,
+				Task:         task,
+				Provider:     provider,
+				Model:        model,
+				PromptTokens: estimateTokens(req.SystemPrompt) + estimateTokens(req.Prompt),
+				OutputTokens: estimateTokens(text),
+				Estimated:    true,
+			})
+		}()
+
+		for msg := range stream {
+			if msg.Done {
+				final = msg.Text
+			} else {
+				streamed.WriteString(msg.Text)
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				// 调用方已不再读取：排空上游以便提供者退出，已生成的部分照常计费
+				for range stream {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
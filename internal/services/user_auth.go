@@ -0,0 +1,456 @@
+// internal/services/user_auth.go
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/auth"
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUsernameTaken      = errors.New("username already registered")
+	ErrInvalidUsername    = errors.New("username must be 3-32 characters of letters, digits, '.', '_' or '-'")
+	ErrAPITokenNotFound   = errors.New("api token not found")
+	ErrAPITokenInvalid    = errors.New("api token invalid, expired or revoked")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,32}$`)
+
+// 保留的用户名（控制台访客等内置身份）
+var reservedUsernames = map[string]bool{
+	"console_user": true,
+}
+
+// dummyPasswordHash 用于用户名不存在时的等时比较
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("dummy-password-for-timing")
+	return hash
+})
+
+// 最近使用时间的落盘间隔，避免每个请求都写文件
+const apiTokenTouchInterval = time.Minute
+
+// userAuthStore 是 data/users/auth/auth.json 的内容
+type userAuthStore struct {
+	// key: 小写用户名
+	Credentials map[string]*models.UserCredential `json:"credentials"`
+	// key: API 令牌 ID
+	APITokens map[string]*storedAPIToken `json:"api_tokens"`
+	// key: 会话令牌 ID
+	Revoked map[string]*models.RevokedToken `json:"revoked"`
+}
+
+type storedAPIToken struct {
+	models.APIToken
+	TokenHash string `json:"token_hash"`
+}
+
+func (s *UserService) authStorePath() string {
+	return filepath.Join(s.BasePath, "auth", "auth.json")
+}
+
+// loadAuthStoreLocked 读取凭据存储（调用方持有 authMutex）
+func (s *UserService) loadAuthStoreLocked() (*userAuthStore, error) {
+	if s.authStore != nil {
+		return s.authStore, nil
+	}
+
+	store := &userAuthStore{
+		Credentials: make(map[string]*models.UserCredential),
+		APITokens:   make(map[string]*storedAPIToken),
+		Revoked:     make(map[string]*models.RevokedToken),
+	}
+
+	data, err := os.ReadFile(s.authStorePath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取凭据数据失败: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, store); err != nil {
+			return nil, fmt.Errorf("解析凭据数据失败: %w", err)
+		}
+		if store.Credentials == nil {
+			store.Credentials = make(map[string]*models.UserCredential)
+		}
+		if store.APITokens == nil {
+			store.APITokens = make(map[string]*storedAPIToken)
+		}
+		if store.Revoked == nil {
+			store.Revoked = make(map[string]*models.RevokedToken)
+		}
+	}
+
+	s.authStore = store
+	return store, nil
+}
+
+// saveAuthStoreLocked 原子写入凭据存储，并顺带清理已过期的吊销记录
+func (s *UserService) saveAuthStoreLocked(store *userAuthStore) error {
+	now := time.Now()
+	for id, rt := range store.Revoked {
+		if now.After(rt.ExpiresAt) {
+			delete(store.Revoked, id)
+		}
+	}
+
+	path := s.authStorePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("创建凭据目录失败: %w", err)
+	}
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化凭据数据失败: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入凭据数据失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Register 注册新账户：创建用户档案并保存密码哈希
+func (s *UserService) Register(username string, password string, email string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) || reservedUsernames[strings.ToLower(username)] {
+		return nil, ErrInvalidUsername
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.ToLower(username)
+	if _, exists := store.Credentials[key]; exists {
+		return nil, ErrUsernameTaken
+	}
+
+	user, err := s.CreateUser(username, strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	store.Credentials[key] = &models.UserCredential{
+		UserID:            user.ID,
+		Username:          username,
+		PasswordHash:      hash,
+		PasswordChangedAt: now,
+		CreatedAt:         now,
+	}
+	if err := s.saveAuthStoreLocked(store); err != nil {
+		delete(store.Credentials, key)
+		// 凭据未落盘时档案无法登录，删除档案以免留下孤立用户
+		if delErr := s.deleteUserProfile(user.ID); delErr != nil {
+			utils.GetLogger().Warn("删除未完成注册的用户档案失败", map[string]interface{}{"user_id": user.ID, "err": delErr})
+		}
+		return nil, err
+	}
+
+	utils.GetLogger().Info("user registered", map[string]interface{}{"user_id": user.ID, "username": username})
+	return user, nil
+}
+
+// deleteUserProfile 删除用户档案文件并清除缓存
+func (s *UserService) deleteUserProfile(userID string) error {
+	lock := s.getUserLock(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	defer s.invalidateUserCache(userID)
+	if s.FileStorage != nil {
+		return s.FileStorage.DeleteFile("", userID+".json")
+	}
+	if err := os.Remove(filepath.Join(s.BasePath, userID+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Authenticate 校验用户名与密码，成功时更新最后登录时间
+func (s *UserService) Authenticate(username string, password string) (*models.User, error) {
+	s.authMutex.Lock()
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		s.authMutex.Unlock()
+		return nil, err
+	}
+	cred := store.Credentials[strings.ToLower(strings.TrimSpace(username))]
+	s.authMutex.Unlock()
+
+	if cred == nil {
+		// 仍然执行一次哈希比较，避免通过响应时间探测用户名是否存在
+		auth.CheckPassword(dummyPasswordHash(), password)
+		return nil, ErrInvalidCredentials
+	}
+	if !auth.CheckPassword(cred.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := s.GetUser(cred.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.LastLogin = time.Now()
+	if err := s.SaveUser(user); err != nil {
+		utils.GetLogger().Warn("update last login failed", map[string]interface{}{"user_id": user.ID, "err": err})
+	}
+	s.invalidateUserCache(user.ID)
+
+	return user, nil
+}
+
+// HasAccount 判断用户名是否已注册
+func (s *UserService) HasAccount(username string) bool {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return false
+	}
+	_, exists := store.Credentials[strings.ToLower(strings.TrimSpace(username))]
+	return exists
+}
+
+// LoginUsername 返回用户 ID 对应的登录用户名（取自凭据存储，不受资料修改影响）
+func (s *UserService) LoginUsername(userID string) (string, bool) {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return "", false
+	}
+	if cred := findCredentialByUserID(store, userID); cred != nil {
+		return cred.Username, true
+	}
+	return "", false
+}
+
+// ChangePassword 修改密码，并使该用户此前签发的所有会话令牌失效
+func (s *UserService) ChangePassword(userID string, oldPassword string, newPassword string) error {
+	hash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return err
+	}
+
+	cred := findCredentialByUserID(store, userID)
+	if cred == nil || !auth.CheckPassword(cred.PasswordHash, oldPassword) {
+		return ErrInvalidCredentials
+	}
+
+	prev := *cred
+	now := time.Now()
+	cred.PasswordHash = hash
+	cred.PasswordChangedAt = now
+	cred.TokensValidAfter = now
+	if err := s.saveAuthStoreLocked(store); err != nil {
+		*cred = prev
+		return err
+	}
+	return nil
+}
+
+func findCredentialByUserID(store *userAuthStore, userID string) *models.UserCredential {
+	for _, cred := range store.Credentials {
+		if cred.UserID == userID {
+			return cred
+		}
+	}
+	return nil
+}
+
+// RevokeSessionToken 将会话令牌加入吊销列表，直到其原本的过期时间
+func (s *UserService) RevokeSessionToken(userID string, tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return nil
+	}
+
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return err
+	}
+	store.Revoked[tokenID] = &models.RevokedToken{TokenID: tokenID, UserID: userID, ExpiresAt: expiresAt}
+	return s.saveAuthStoreLocked(store)
+}
+
+// IsSessionTokenRevoked 检查会话令牌是否已被吊销（单独吊销或修改密码后整体失效）
+func (s *UserService) IsSessionTokenRevoked(userID string, tokenID string, issuedAt time.Time) bool {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		// 读取失败时按已吊销处理
+		return true
+	}
+	if tokenID != "" {
+		if _, revoked := store.Revoked[tokenID]; revoked {
+			return true
+		}
+	}
+	if cred := findCredentialByUserID(store, userID); cred != nil {
+		if issuedAt.Before(cred.TokensValidAfter) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIToken 创建个人 API 令牌；ttl 为 0 表示永不过期。返回的明文只此一次可见。
+func (s *UserService) CreateAPIToken(userID string, name string, ttl time.Duration) (string, *models.APIToken, error) {
+	if strings.TrimSpace(userID) == "" {
+		return "", nil, fmt.Errorf("用户ID不能为空")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "api token"
+	}
+
+	plain, hash, err := auth.GenerateAPIToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	tok := &storedAPIToken{
+		APIToken: models.APIToken{
+			ID:        fmt.Sprintf("tok_%d", now.UnixNano()),
+			UserID:    userID,
+			Name:      name,
+			Prefix:    plain[:len(auth.APITokenPrefix)+6],
+			CreatedAt: now,
+		},
+		TokenHash: hash,
+	}
+	if ttl > 0 {
+		exp := now.Add(ttl)
+		tok.ExpiresAt = &exp
+	}
+
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return "", nil, err
+	}
+	store.APITokens[tok.ID] = tok
+	if err := s.saveAuthStoreLocked(store); err != nil {
+		delete(store.APITokens, tok.ID)
+		return "", nil, err
+	}
+
+	out := tok.APIToken
+	return plain, &out, nil
+}
+
+// ListAPITokens 列出用户的个人 API 令牌（不含明文与哈希）
+func (s *UserService) ListAPITokens(userID string) ([]models.APIToken, error) {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]models.APIToken, 0)
+	for _, tok := range store.APITokens {
+		if tok.UserID == userID {
+			tokens = append(tokens, tok.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// RevokeAPIToken 吊销个人 API 令牌
+func (s *UserService) RevokeAPIToken(userID string, tokenID string) error {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return err
+	}
+
+	tok := store.APITokens[tokenID]
+	if tok == nil || tok.UserID != userID {
+		return ErrAPITokenNotFound
+	}
+	if tok.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	tok.RevokedAt = &now
+	return s.saveAuthStoreLocked(store)
+}
+
+// ValidateAPIToken 校验个人 API 令牌并返回所属用户ID
+func (s *UserService) ValidateAPIToken(plain string) (string, error) {
+	hash := auth.HashAPIToken(plain)
+
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	store, err := s.loadAuthStoreLocked()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	for _, tok := range store.APITokens {
+		if tok.TokenHash != hash {
+			continue
+		}
+		if tok.RevokedAt != nil || (tok.ExpiresAt != nil && now.After(*tok.ExpiresAt)) {
+			return "", ErrAPITokenInvalid
+		}
+		if tok.LastUsedAt == nil || now.Sub(*tok.LastUsedAt) > apiTokenTouchInterval {
+			tok.LastUsedAt = &now
+			if err := s.saveAuthStoreLocked(store); err != nil {
+				utils.GetLogger().Warn("update api token last use failed", map[string]interface{}{"token_id": tok.ID, "err": err})
+			}
+		}
+		return tok.UserID, nil
+	}
+	return "", ErrAPITokenInvalid
+}
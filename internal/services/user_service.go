@@ -20,13 +20,17 @@ import (
 // UserService 处理用户相关的业务逻辑
 type UserService struct {
 	BasePath    string
-	FileStorage *storage.FileStorage
+	FileStorage storage.Store
 
 	// 并发控制
 	userLocks   sync.Map                   // 用户级别锁
 	cacheMutex  sync.RWMutex               // 缓存锁
 	userCache   map[string]*CachedUserData // 用户缓存
 	cacheExpiry time.Duration              // 缓存过期时间
+
+	// 凭据与令牌（见 user_auth.go）
+	authMutex sync.Mutex
+	authStore *userAuthStore
 }
 
 // CachedUserData 缓存的用户数据
@@ -46,8 +50,8 @@ func NewUserService() *UserService {
 		utils.GetLogger().Warn("创建用户数据目录失败", map[string]interface{}{"base_path": basePath, "err": err})
 	}
 
-	// 创建 FileStorage 实例
-	fileStorage, err := storage.NewFileStorage(basePath)
+	// 创建存储实例（文件或 SQLite，取决于全局存储配置）
+	fileStorage, err := storage.Open(basePath)
 	if err != nil {
 		utils.GetLogger().Warn("创建文件存储服务失败", map[string]interface{}{"base_path": basePath, "err": err})
 		fileStorage = nil
@@ -179,11 +183,17 @@ func (s *UserService) EnsureUserExists(userID string) error {
 	lock.Lock()
 	defer lock.Unlock()
 
-	userPath := filepath.Join(s.BasePath, userID+".json")
-	if _, err := os.Stat(userPath); err == nil {
-		return nil
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("检查用户数据失败: %w", err)
+	if s.FileStorage != nil {
+		if s.FileStorage.FileExists("", userID+".json") {
+			return nil
+		}
+	} else {
+		userPath := filepath.Join(s.BasePath, userID+".json")
+		if _, err := os.Stat(userPath); err == nil {
+			return nil
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("检查用户数据失败: %w", err)
+		}
 	}
 
 	now := time.Now()
@@ -306,16 +316,29 @@ func (s *UserService) AddUserItem(userID string, item models.UserItem) error {
 
 // 直接文件操作（在锁内使用）
 func (s *UserService) loadUserDirect(userID string) (*models.User, error) {
-	// 统一使用直接文件读取，确保路径一致性
-	userPath := filepath.Join(s.BasePath, userID+".json")
+	var userDataBytes []byte
+	if s.FileStorage != nil {
+		data, err := s.FileStorage.LoadTextFile("", userID+".json")
+		if storage.IsNotExist(err) {
+			return nil, fmt.Errorf("用户不存在: %s", userID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取用户数据失败: %w", err)
+		}
+		userDataBytes = data
+	} else {
+		// 存储未初始化时降级为直接文件读取
+		userPath := filepath.Join(s.BasePath, userID+".json")
 
-	if _, err := os.Stat(userPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("用户不存在: %s", userID)
-	}
+		if _, err := os.Stat(userPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("用户不存在: %s", userID)
+		}
 
-	userDataBytes, err := os.ReadFile(userPath)
-	if err != nil {
-		return nil, fmt.Errorf("读取用户数据失败: %w", err)
+		data, err := os.ReadFile(userPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取用户数据失败: %w", err)
+		}
+		userDataBytes = data
 	}
 
 	var userData models.User
@@ -333,16 +356,22 @@ func (s *UserService) saveUserDirect(user *models.User) error {
 		return fmt.Errorf("序列化用户数据失败: %w", err)
 	}
 
-	userPath := filepath.Join(s.BasePath, user.ID+".json")
-	tempPath := userPath + ".tmp"
+	if s.FileStorage != nil {
+		if err := s.FileStorage.SaveTextFile("", user.ID+".json", userDataJSON); err != nil {
+			return fmt.Errorf("保存用户数据失败: %w", err)
+		}
+	} else {
+		userPath := filepath.Join(s.BasePath, user.ID+".json")
+		tempPath := userPath + ".tmp"
 
-	if err := os.WriteFile(tempPath, userDataJSON, 0644); err != nil {
-		return fmt.Errorf("保存用户数据失败: %w", err)
-	}
+		if err := os.WriteFile(tempPath, userDataJSON, 0644); err != nil {
+			return fmt.Errorf("保存用户数据失败: %w", err)
+		}
 
-	if err := os.Rename(tempPath, userPath); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("保存用户数据失败: %w", err)
+		if err := os.Rename(tempPath, userPath); err != nil {
+			os.Remove(tempPath)
+			return fmt.Errorf("保存用户数据失败: %w", err)
+		}
 	}
 
 	// 保存成功后更新缓存而不是清除
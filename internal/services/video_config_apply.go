@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Corphon/SceneIntruderMCP/internal/config"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
 )
 
 func ApplyVideoConfig(svc *VideoService, cfg *config.AppConfig) error {
@@ -26,6 +27,9 @@ func ApplyVideoConfig(svc *VideoService, cfg *config.AppConfig) error {
 	defaultModel := strings.TrimSpace(cfg.VideoDefaultModel)
 	if defaultModel == "" {
 		defaultModel = "wan2.6-i2v-flash"
+		if provider == "local" {
+			defaultModel = "ken-burns"
+		}
 	}
 
 	svc.DefaultProvider = provider
@@ -64,10 +68,36 @@ func ApplyVideoConfig(svc *VideoService, cfg *config.AppConfig) error {
 			svc.FFmpegPath = v
 		}
 	}
+	svc.TTS = buildVideoTTSProvider(cfg.VideoConfig)
 
 	switch provider {
 	case "mock":
 		return nil
+	case "local":
+		providerClient := NewLocalVideoProvider(svc.Repo, svc.FFmpegPath)
+		if cfg.VideoConfig != nil {
+			if v := strings.TrimSpace(cfg.VideoConfig["local_fps"]); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 60 {
+					providerClient.FPS = n
+				}
+			}
+			if v := strings.ToLower(strings.TrimSpace(cfg.VideoConfig["local_fit"])); v == "cover" || v == "contain" {
+				providerClient.Fit = v
+			}
+			if v := strings.TrimSpace(cfg.VideoConfig["local_transition_sec"]); v != "" {
+				if sec, err := strconv.ParseFloat(v, 64); err == nil && sec > 0 && sec <= 5 {
+					providerClient.TransitionSec = sec
+				}
+			}
+			if v := strings.TrimSpace(cfg.VideoConfig["local_timeout_sec"]); v != "" {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 {
+					providerClient.Timeout = time.Duration(n) * time.Second
+				}
+			}
+		}
+		providerClient.CommandRunner = svc.CommandRunner
+		svc.Provider = providerClient
+		return nil
 	case "dashscope":
 		endpoint := ""
 		apiKey := ""
@@ -262,3 +292,38 @@ func ApplyVideoConfig(svc *VideoService, cfg *config.AppConfig) error {
 		return fmt.Errorf("unsupported video provider: %s", provider)
 	}
 }
+
+// buildVideoTTSProvider selects the narration voice-over backend from video_config:
+// tts_provider (none/mock/piper/espeak/command), tts_command, tts_args, tts_voice, tts_voices,
+// tts_voice_dir, tts_timeout_sec.
+// A misconfigured TTS only disables voice-over; it never blocks the video provider.
+func buildVideoTTSProvider(videoConfig map[string]string) TTSProvider {
+	if videoConfig == nil {
+		return nil
+	}
+	provider := strings.ToLower(strings.TrimSpace(videoConfig["tts_provider"]))
+	switch provider {
+	case "", "none", "off":
+		return nil
+	case "mock":
+		return NewMockTTSProvider()
+	}
+	args := strings.Fields(strings.TrimSpace(videoConfig["tts_args"]))
+	p, err := NewCommandTTSProvider(provider, videoConfig["tts_command"], args, videoConfig["tts_voice"])
+	if err != nil {
+		utils.GetLogger().Warn("invalid video tts config; narration voice-over disabled", map[string]interface{}{"err": err.Error()})
+		return nil
+	}
+	for _, v := range strings.Split(videoConfig["tts_voices"], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			p.Voices = append(p.Voices, v)
+		}
+	}
+	p.VoiceDir = strings.TrimSpace(videoConfig["tts_voice_dir"])
+	if v := strings.TrimSpace(videoConfig["tts_timeout_sec"]); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			p.Timeout = time.Duration(n) * time.Second
+		}
+	}
+	return p
+}
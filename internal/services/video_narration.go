@@ -0,0 +1,480 @@
+// internal/services/video_narration.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+	"github.com/Corphon/SceneIntruderMCP/internal/utils"
+)
+
+const (
+	// maxSubtitleCueRunes keeps a cue readable; longer narration is split by sentence.
+	maxSubtitleCueRunes = 40
+	// subtitle line widths before wrapping onto a second line
+	maxSubtitleLineRunesCJK   = 18
+	maxSubtitleLineRunesLatin = 42
+
+	narrationSampleRate = 48000
+)
+
+// Narration status values recorded on VideoMeta.
+const (
+	VideoNarrationDisabled    = "disabled"
+	VideoNarrationUnavailable = "unavailable"
+	VideoNarrationCompleted   = "completed"
+	VideoNarrationPartial     = "partial"
+	VideoNarrationFailed      = "failed"
+	// VideoNarrationDropped means voice-over was synthesized but the render had to leave it out.
+	VideoNarrationDropped = "dropped"
+)
+
+// Render downgrades recorded on VideoMeta.RenderDowngrades when the composer falls back.
+const (
+	VideoDowngradeSubtitlesNotBurned = "subtitles_not_burned"
+	VideoDowngradeVoiceOverDropped   = "voice_over_dropped"
+)
+
+var subtitleSentence = regexp.MustCompile(`[^。！？!?；;….]+(?:[。！？!?；;….]+[”"』」']?)?\s*`)
+
+// videoSubtitleCue is one subtitle entry on the merged video's timeline (seconds).
+type videoSubtitleCue struct {
+	FrameID string
+	Start   float64
+	End     float64
+	Text    string
+}
+
+// videoNarrationMix carries what the merged MP4 compose needs to add voice-over and subtitles.
+type videoNarrationMix struct {
+	// AudioFiles and Durations are indexed like timeline.Clips; AudioFiles[i] is "" for clips without voice-over.
+	AudioFiles []string
+	Durations  []float64
+	// SubtitleFile is a temporary SRT copy used for burn-in ("" when burn-in is off).
+	SubtitleFile string
+}
+
+func (m *videoNarrationMix) hasAudio() bool {
+	if m == nil {
+		return false
+	}
+	for _, f := range m.AudioFiles {
+		if f != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *videoNarrationMix) isEmpty() bool {
+	return m == nil || (!m.hasAudio() && m.SubtitleFile == "")
+}
+
+// downgrades lists the parts of the prepared narration a render left out.
+func (m *videoNarrationMix) downgrades(burned bool, voiced bool) []string {
+	if m == nil {
+		return nil
+	}
+	var out []string
+	if m.SubtitleFile != "" && !burned {
+		out = append(out, VideoDowngradeSubtitlesNotBurned)
+	}
+	if m.hasAudio() && !voiced {
+		out = append(out, VideoDowngradeVoiceOverDropped)
+	}
+	return out
+}
+
+// prepareNarration writes SRT/WebVTT subtitles for the timeline narration and, when
+// narration is enabled and a TTS provider is configured, synthesizes per-clip voice-over.
+// It never fails the render: problems are logged and reflected in meta.NarrationStatus.
+// The returned cleanup removes temporary files used for burn-in.
+func (s *VideoService) prepareNarration(sceneID string, timeline *models.VideoTimeline) (*videoNarrationMix, func()) {
+	cleanup := func() {}
+	if timeline == nil || s.Repo == nil {
+		return nil, cleanup
+	}
+	rate := s.resolveMergedPlaybackRate(timeline)
+	mix := &videoNarrationMix{
+		AudioFiles: make([]string, len(timeline.Clips)),
+		Durations:  make([]float64, len(timeline.Clips)),
+	}
+	for i, clip := range timeline.Clips {
+		mix.Durations[i] = effectiveClipDuration(clip, rate)
+	}
+
+	srtPath, vttPath := "", ""
+	cues := buildVideoSubtitleCues(timeline, rate)
+	if len(cues) > 0 {
+		srt := formatSRT(cues)
+		var err error
+		srtPath, err = s.Repo.SaveRenderArtifact(sceneID, timeline.VideoVersion, fmt.Sprintf("subtitles_%s.srt", timeline.VideoVersion), []byte(srt))
+		if err == nil {
+			vttPath, err = s.Repo.SaveRenderArtifact(sceneID, timeline.VideoVersion, fmt.Sprintf("subtitles_%s.vtt", timeline.VideoVersion), []byte(formatWebVTT(cues)))
+		}
+		if err != nil {
+			utils.GetLogger().Warn("failed to save video subtitles", map[string]interface{}{"scene_id": sceneID, "err": err.Error()})
+		}
+		if timeline.BurnSubtitles {
+			if file, err := os.CreateTemp("", "scene-intruder-video-subtitles-*.srt"); err == nil {
+				_, werr := file.WriteString(srt)
+				cerr := file.Close()
+				if werr == nil && cerr == nil {
+					mix.SubtitleFile = file.Name()
+					cleanup = func() { _ = os.Remove(file.Name()) }
+				} else {
+					_ = os.Remove(file.Name())
+				}
+			}
+		}
+	}
+
+	status := VideoNarrationDisabled
+	if timeline.NarrationEnabled {
+		status = s.synthesizeNarration(sceneID, timeline, mix)
+	}
+	s.updateMetaNarration(sceneID, srtPath, vttPath, status)
+	return mix, cleanup
+}
+
+// synthesizeNarration fills mix.AudioFiles, reusing cached voice-over keyed by provider, voice and text.
+func (s *VideoService) synthesizeNarration(sceneID string, timeline *models.VideoTimeline, mix *videoNarrationMix) string {
+	if s.TTS == nil {
+		return VideoNarrationUnavailable
+	}
+	wanted, done := 0, 0
+	for i, clip := range timeline.Clips {
+		text := strings.TrimSpace(clip.Narration)
+		if text == "" {
+			continue
+		}
+		wanted++
+		voice := strings.TrimSpace(timeline.NarrationVoice)
+		filename := fmt.Sprintf("%s_%s.wav", clip.FrameID, sha256Hex([]byte(fmt.Sprintf("%T|%s|%s", s.TTS, voice, text)))[:16])
+		if abs, ok := s.Repo.NarrationAudioPath(sceneID, filename); ok {
+			mix.AudioFiles[i] = abs
+			done++
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		result, err := s.TTS.SynthesizeNarration(ctx, models.VideoNarrationRequest{
+			SceneID:      sceneID,
+			VideoVersion: timeline.VideoVersion,
+			FrameID:      clip.FrameID,
+			Text:         text,
+			Voice:        voice,
+			Language:     timeline.PromptOptions.Language,
+		})
+		cancel()
+		if err == nil && (result == nil || len(result.Audio) == 0) {
+			err = fmt.Errorf("tts provider returned no audio")
+		}
+		if err == nil {
+			_, err = s.Repo.SaveNarrationAudio(sceneID, filename, result.Audio)
+		}
+		if err != nil {
+			utils.GetLogger().Warn("video narration synthesis failed", map[string]interface{}{
+				"scene_id": sceneID,
+				"frame_id": clip.FrameID,
+				"err":      err.Error(),
+			})
+			continue
+		}
+		if result.DurationSec > mix.Durations[i]+0.05 {
+			utils.GetLogger().Warn("video narration longer than clip; it will be cut", map[string]interface{}{
+				"scene_id":      sceneID,
+				"frame_id":      clip.FrameID,
+				"narration_s":   result.DurationSec,
+				"clip_s":        mix.Durations[i],
+				"video_version": timeline.VideoVersion,
+			})
+		}
+		if abs, ok := s.Repo.NarrationAudioPath(sceneID, filename); ok {
+			mix.AudioFiles[i] = abs
+			done++
+		}
+	}
+	switch {
+	case wanted == 0:
+		return VideoNarrationDisabled
+	case done == wanted:
+		return VideoNarrationCompleted
+	case done > 0:
+		return VideoNarrationPartial
+	default:
+		return VideoNarrationFailed
+	}
+}
+
+func (s *VideoService) updateMetaNarration(sceneID string, srtPath string, vttPath string, status string) {
+	meta, _ := s.Repo.LoadMeta(sceneID)
+	if meta == nil {
+		meta = &models.VideoMeta{SceneID: sceneID, CreatedAt: time.Now()}
+	}
+	meta.SubtitleSRTPath = srtPath
+	meta.SubtitleVTTPath = vttPath
+	meta.NarrationStatus = status
+	meta.UpdatedAt = time.Now()
+	_ = s.Repo.SaveMeta(sceneID, meta)
+}
+
+// updateMetaRenderDowngrades records what the last render dropped; a dropped voice-over
+// also overrides the narration status so clients do not report it as completed.
+func (s *VideoService) updateMetaRenderDowngrades(sceneID string, downgrades []string) {
+	if s.Repo == nil {
+		return
+	}
+	meta, _ := s.Repo.LoadMeta(sceneID)
+	if meta == nil {
+		meta = &models.VideoMeta{SceneID: sceneID, CreatedAt: time.Now()}
+	}
+	meta.RenderDowngrades = downgrades
+	for _, d := range downgrades {
+		if d == VideoDowngradeVoiceOverDropped {
+			meta.NarrationStatus = VideoNarrationDropped
+		}
+	}
+	meta.UpdatedAt = time.Now()
+	_ = s.Repo.SaveMeta(sceneID, meta)
+}
+
+func effectiveClipDuration(clip models.VideoTimelineClip, playbackRate float64) float64 {
+	d := clip.DurationSec
+	if d <= 0 {
+		return 0
+	}
+	if playbackRate > 0 {
+		d /= playbackRate
+	}
+	return d
+}
+
+// buildVideoSubtitleCues times each clip's narration from clip durations (after the merged
+// playback rate). Long narration is split into sentences sharing the clip time by length.
+func buildVideoSubtitleCues(timeline *models.VideoTimeline, playbackRate float64) []videoSubtitleCue {
+	if timeline == nil {
+		return nil
+	}
+	cues := make([]videoSubtitleCue, 0, len(timeline.Clips))
+	offset := 0.0
+	for _, clip := range timeline.Clips {
+		dur := effectiveClipDuration(clip, playbackRate)
+		text := strings.Join(strings.Fields(clip.Narration), " ")
+		if text != "" && dur > 0 {
+			chunks := splitSubtitleChunks(text)
+			total := 0
+			for _, c := range chunks {
+				total += utf8.RuneCountInString(c)
+			}
+			start := offset
+			for i, c := range chunks {
+				end := offset + dur
+				if i < len(chunks)-1 && total > 0 {
+					end = start + dur*float64(utf8.RuneCountInString(c))/float64(total)
+				}
+				cues = append(cues, videoSubtitleCue{FrameID: clip.FrameID, Start: start, End: end, Text: wrapSubtitleText(c)})
+				start = end
+			}
+		}
+		offset += dur
+	}
+	return cues
+}
+
+// splitSubtitleChunks splits narration into sentences and packs them into cues of at most maxSubtitleCueRunes.
+func splitSubtitleChunks(text string) []string {
+	if utf8.RuneCountInString(text) <= maxSubtitleCueRunes {
+		return []string{text}
+	}
+	sentences := subtitleSentence.FindAllString(text, -1)
+	chunks := make([]string, 0, len(sentences))
+	cur := ""
+	for _, sent := range sentences {
+		sent = strings.TrimSpace(sent)
+		if sent == "" {
+			continue
+		}
+		joined := sent
+		if cur != "" {
+			joined = joinSubtitleText(cur, sent)
+		}
+		if cur != "" && utf8.RuneCountInString(joined) > maxSubtitleCueRunes {
+			chunks = append(chunks, cur)
+			cur = sent
+			continue
+		}
+		cur = joined
+	}
+	if cur != "" {
+		chunks = append(chunks, cur)
+	}
+	if len(chunks) == 0 {
+		return []string{text}
+	}
+	return chunks
+}
+
+func joinSubtitleText(a, b string) string {
+	last, _ := utf8.DecodeLastRuneInString(a)
+	if last < utf8.RuneSelf || !isCJKRune(last) {
+		return a + " " + b
+	}
+	return a + b
+}
+
+// wrapSubtitleText breaks a cue onto two lines near the middle when it is too wide.
+func wrapSubtitleText(text string) string {
+	runes := []rune(text)
+	cjk := 0
+	for _, r := range runes {
+		if isCJKRune(r) {
+			cjk++
+		}
+	}
+	limit := maxSubtitleLineRunesLatin
+	if cjk*2 >= len(runes) {
+		limit = maxSubtitleLineRunesCJK
+	}
+	if len(runes) <= limit {
+		return text
+	}
+	mid := len(runes) / 2
+	if cjk*2 < len(runes) {
+		// prefer the space closest to the middle
+		best := -1
+		for i, r := range runes {
+			if r == ' ' && (best < 0 || math.Abs(float64(i-mid)) < math.Abs(float64(best-mid))) {
+				best = i
+			}
+		}
+		if best > 0 {
+			return string(runes[:best]) + "\n" + strings.TrimSpace(string(runes[best+1:]))
+		}
+	}
+	return string(runes[:mid]) + "\n" + string(runes[mid:])
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+func formatSRT(cues []videoSubtitleCue) string {
+	var b strings.Builder
+	for i, c := range cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatSubtitleTimestamp(c.Start, ","), formatSubtitleTimestamp(c.End, ","), c.Text)
+	}
+	return b.String()
+}
+
+func formatWebVTT(cues []videoSubtitleCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, c := range cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatSubtitleTimestamp(c.Start, "."), formatSubtitleTimestamp(c.End, "."), c.Text)
+	}
+	return b.String()
+}
+
+func formatSubtitleTimestamp(sec float64, msSep string) string {
+	if sec < 0 {
+		sec = 0
+	}
+	ms := int64(math.Round(sec * 1000))
+	h := ms / 3600000
+	ms -= h * 3600000
+	m := ms / 60000
+	ms -= m * 60000
+	s := ms / 1000
+	ms -= s * 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, msSep, ms)
+}
+
+// escapeFFmpegFilterPath escapes a file path for use as a filter option value inside -vf/-filter_complex.
+func escapeFFmpegFilterPath(path string) string {
+	path = strings.ReplaceAll(path, `\`, `/`)
+	r := strings.NewReplacer(`:`, `\\:`, `'`, `\\\'`, `,`, `\,`, `[`, `\[`, `]`, `\]`, `;`, `\;`)
+	return r.Replace(path)
+}
+
+// buildFFmpegNarrationMP4Args builds the merged MP4 command with voice-over mixed per clip
+// and/or burned-in subtitles. Voice-over is padded or cut to each clip's effective duration
+// so it stays aligned with the concatenated video.
+func (s *VideoService) buildFFmpegNarrationMP4Args(concatFile string, outputFile string, timeline *models.VideoTimeline, includeAudio bool, mix *videoNarrationMix, burnSubtitles bool) []string {
+	playbackRate := s.resolveMergedPlaybackRate(timeline)
+	videoFilter := fmt.Sprintf("setpts=PTS/%.6f", playbackRate)
+	if burnSubtitles && mix != nil && mix.SubtitleFile != "" {
+		videoFilter += ",subtitles=" + escapeFFmpegFilterPath(mix.SubtitleFile)
+	}
+	args := []string{"-y", "-f", "concat", "-safe", "0", "-i", concatFile}
+
+	if !mix.hasAudio() {
+		args = append(args,
+			"-map", "0:v:0",
+			"-vf", videoFilter,
+			"-r", fmt.Sprintf("%d", timeline.FPS),
+			"-pix_fmt", "yuv420p",
+			"-movflags", "+faststart",
+			"-c:v", "libx264",
+		)
+		if includeAudio {
+			args = append(args, "-map", "0:a:0?", "-af", buildFFmpegATempoFilter(playbackRate), "-c:a", "aac")
+		} else {
+			args = append(args, "-an")
+		}
+		return append(args, outputFile)
+	}
+
+	filters := []string{"[0:v:0]" + videoFilter + "[v]"}
+	labels := make([]string, 0, len(mix.AudioFiles))
+	input := 1
+	for i, file := range mix.AudioFiles {
+		dur := 0.0
+		if i < len(mix.Durations) {
+			dur = mix.Durations[i]
+		}
+		if dur <= 0 {
+			continue
+		}
+		label := fmt.Sprintf("[n%d]", i)
+		if file != "" {
+			args = append(args, "-i", file)
+			filters = append(filters, fmt.Sprintf("[%d:a]aresample=%d,aformat=sample_fmts=fltp:channel_layouts=stereo,apad,atrim=0:%.3f,asetpts=N/SR/TB%s", input, narrationSampleRate, dur, label))
+			input++
+		} else {
+			filters = append(filters, fmt.Sprintf("anullsrc=r=%d:cl=stereo,atrim=0:%.3f,aformat=sample_fmts=fltp%s", narrationSampleRate, dur, label))
+		}
+		labels = append(labels, label)
+	}
+	voice := "[narration]"
+	if includeAudio {
+		voice = "[voice]"
+	}
+	filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=0:a=1%s", strings.Join(labels, ""), len(labels), voice))
+	if includeAudio {
+		filters = append(filters,
+			"[0:a:0]"+buildFFmpegATempoFilter(playbackRate)+",aresample="+fmt.Sprintf("%d", narrationSampleRate)+",volume=0.35[bed]",
+			"[bed][voice]amix=inputs=2:duration=longest:dropout_transition=0[narration]",
+		)
+	}
+
+	args = append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[v]",
+		"-map", "[narration]",
+		"-r", fmt.Sprintf("%d", timeline.FPS),
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-c:v", "libx264",
+		"-c:a", "aac",
+		outputFile,
+	)
+	return args
+}
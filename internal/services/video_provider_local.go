@@ -0,0 +1,411 @@
+// internal/services/video_provider_local.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+)
+
+var (
+	ErrLocalVideoRepositoryRequired     = errors.New("local video provider requires a video repository")
+	ErrLocalVideoReferenceImageRequired = errors.New("local video provider requires a comic frame image")
+	ErrLocalVideoTaskNotFound           = errors.New("local video task not found")
+)
+
+// Clip transitions understood by the local provider. The transition of a clip describes
+// how it hands over to the next clip in the timeline.
+const (
+	VideoClipTransitionCut       = "cut"
+	VideoClipTransitionFade      = "fade"
+	VideoClipTransitionCrossfade = "crossfade"
+)
+
+const (
+	localVideoDefaultFPS           = 24
+	localVideoDefaultDurationSec   = 4.0
+	videoClipMaxDurationSec        = 30.0
+	localVideoDefaultTransitionSec = 0.6
+	// zoompan works on integer pixel offsets; rendering on a larger canvas keeps slow moves smooth.
+	localVideoSupersample = 3
+)
+
+// LocalVideoProvider animates each comic frame with ffmpeg ("Ken Burns" pan/zoom) on this
+// host, so an animatic can be produced without a cloud video API. Rendering happens inside
+// SubmitClipTask and the returned task is already terminal, so PollTask is never needed.
+type LocalVideoProvider struct {
+	Repo          *VideoRepository
+	FFmpegPath    string
+	FPS           int
+	Fit           string // cover (crop to fill, default) / contain (letterbox)
+	TransitionSec float64
+	Timeout       time.Duration
+	CommandRunner func(ctx context.Context, name string, args ...string) *exec.Cmd
+}
+
+func NewLocalVideoProvider(repo *VideoRepository, ffmpegPath string) *LocalVideoProvider {
+	return &LocalVideoProvider{
+		Repo:          repo,
+		FFmpegPath:    strings.TrimSpace(ffmpegPath),
+		FPS:           localVideoDefaultFPS,
+		Fit:           "cover",
+		TransitionSec: localVideoDefaultTransitionSec,
+		Timeout:       5 * time.Minute,
+	}
+}
+
+func (p *LocalVideoProvider) SubmitClipTask(ctx context.Context, req models.VideoClipRequest) (*models.VideoProviderTask, error) {
+	if p.Repo == nil {
+		return nil, ErrLocalVideoRepositoryRequired
+	}
+	imagePath, err := p.resolveFramePath(req.ReferenceImagePath)
+	if err != nil {
+		return nil, err
+	}
+	if imagePath == "" {
+		return nil, ErrLocalVideoReferenceImageRequired
+	}
+	nextImagePath := ""
+	if normalizeVideoClipTransition(req.TransitionHint) == VideoClipTransitionCrossfade {
+		// a missing neighbour image downgrades the crossfade to a fade out
+		if resolved, err := p.resolveFramePath(req.NextFramePath); err == nil {
+			nextImagePath = resolved
+		}
+	}
+	binary := p.FFmpegPath
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+	binary, err = exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("local video provider requires ffmpeg: %w", err)
+	}
+
+	workDir, err := os.MkdirTemp("", "scene-intruder-local-clip-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+	outputFile := filepath.Join(workDir, "clip.mp4")
+	args := p.buildArgs(req, imagePath, nextImagePath, outputFile)
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	runner := p.CommandRunner
+	if runner == nil {
+		runner = exec.CommandContext
+	}
+	if output, err := runner(runCtx, binary, args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("local video render failed: %w: %s", err, tailString(strings.TrimSpace(string(output)), 2048))
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("local video render produced no output: %w", err)
+	}
+	if len(content) == 0 {
+		return nil, errors.New("local video render produced empty output")
+	}
+	localPath, err := p.Repo.SaveClipAsset(req.SceneID, req.VideoVersion, req.FrameID+".mp4", content)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := localVideoDimensions(req.Resolution)
+	return &models.VideoProviderTask{
+		TaskID:         fmt.Sprintf("local_%s_%d", req.FrameID, time.Now().UnixNano()),
+		Status:         "completed",
+		ProviderStatus: "local_rendered",
+		LocalPath:      localPath,
+		Raw: map[string]interface{}{
+			"camera_motion": strings.TrimSpace(req.CameraMotion),
+			"transition":    normalizeVideoClipTransition(req.TransitionHint),
+			"duration_sec":  localVideoDuration(req.DurationSec),
+			"size":          fmt.Sprintf("%dx%d", width, height),
+		},
+	}, nil
+}
+
+// PollTask exists to satisfy VideoProvider; local tasks are terminal when submitted.
+func (p *LocalVideoProvider) PollTask(ctx context.Context, taskID string) (*models.VideoProviderTask, error) {
+	return nil, fmt.Errorf("%w: %s", ErrLocalVideoTaskNotFound, strings.TrimSpace(taskID))
+}
+
+// resolveFramePath maps a comics-relative image path (scene_<id>/images/<frame>.png) to an
+// absolute path inside the repository; an empty input yields an empty path.
+func (p *LocalVideoProvider) resolveFramePath(rel string) (string, error) {
+	rel = strings.TrimSpace(rel)
+	if rel == "" {
+		return "", nil
+	}
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: invalid image path %q", ErrLocalVideoReferenceImageRequired, rel)
+	}
+	abs := filepath.Join(p.Repo.BaseDir, clean)
+	if info, err := os.Stat(abs); err != nil || info.IsDir() {
+		return "", fmt.Errorf("%w: %s", ErrLocalVideoReferenceImageRequired, rel)
+	}
+	return abs, nil
+}
+
+func (p *LocalVideoProvider) buildArgs(req models.VideoClipRequest, imagePath string, nextImagePath string, outputFile string) []string {
+	width, height := localVideoDimensions(req.Resolution)
+	fps := p.FPS
+	if fps <= 0 {
+		fps = localVideoDefaultFPS
+	}
+	duration := localVideoDuration(req.DurationSec)
+	frames := int(duration*float64(fps) + 0.5)
+	if frames < 2 {
+		frames = 2
+	}
+	transition := normalizeVideoClipTransition(req.TransitionHint)
+	transitionSec := p.TransitionSec
+	if transitionSec <= 0 {
+		transitionSec = localVideoDefaultTransitionSec
+	}
+	if transitionSec > duration/3 {
+		transitionSec = duration / 3
+	}
+
+	args := []string{"-y", "-i", imagePath}
+	audioInput := 1
+	if nextImagePath != "" {
+		args = append(args, "-i", nextImagePath)
+		audioInput = 2
+	}
+	// silent stereo track so clips concatenate cleanly with provider clips and voice-over
+	args = append(args, "-f", "lavfi", "-t", formatFFmpegSeconds(duration), "-i", "anullsrc=r=44100:cl=stereo")
+
+	motion := resolveKenBurnsMotion(req.CameraMotion)
+	filters := make([]string, 0, 3)
+	if nextImagePath != "" {
+		tailFrames := int(transitionSec*float64(fps) + 0.5)
+		if tailFrames < 1 {
+			tailFrames = 1
+		}
+		// the tail shows the next frame exactly as its own clip opens, so the cut after the dissolve is seamless
+		nextOpening := resolveKenBurnsMotion(req.NextCameraMotion).opening()
+		filters = append(filters,
+			"[0:v]"+p.kenBurnsFilter(motion, width, height, fps, frames)+"[base]",
+			"[1:v]"+p.kenBurnsFilter(nextOpening, width, height, fps, tailFrames)+"[next]",
+			fmt.Sprintf("[base][next]xfade=transition=fade:duration=%s:offset=%s[v]",
+				formatFFmpegSeconds(float64(tailFrames)/float64(fps)),
+				formatFFmpegSeconds(float64(frames-tailFrames)/float64(fps))),
+		)
+	} else {
+		chain := p.kenBurnsFilter(motion, width, height, fps, frames)
+		switch transition {
+		case VideoClipTransitionFade:
+			chain += fmt.Sprintf(",fade=t=in:st=0:d=%s,fade=t=out:st=%s:d=%s",
+				formatFFmpegSeconds(transitionSec), formatFFmpegSeconds(duration-transitionSec), formatFFmpegSeconds(transitionSec))
+		case VideoClipTransitionCrossfade:
+			// last clip (or missing neighbour): dissolve to black instead
+			chain += fmt.Sprintf(",fade=t=out:st=%s:d=%s", formatFFmpegSeconds(duration-transitionSec), formatFFmpegSeconds(transitionSec))
+		}
+		filters = append(filters, "[0:v]"+chain+"[v]")
+	}
+
+	args = append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[v]",
+		"-map", fmt.Sprintf("%d:a", audioInput),
+		"-frames:v", strconv.Itoa(frames),
+		"-r", strconv.Itoa(fps),
+		"-pix_fmt", "yuv420p",
+		"-c:v", "libx264",
+		"-c:a", "aac",
+		"-shortest",
+		"-movflags", "+faststart",
+		outputFile,
+	)
+	return args
+}
+
+// kenBurnsFilter scales the frame onto a supersampled canvas and drives zoompan with eased
+// zoom/focus expressions over the given number of output frames.
+func (p *LocalVideoProvider) kenBurnsFilter(m kenBurnsMotion, width int, height int, fps int, frames int) string {
+	cw, ch := width*localVideoSupersample, height*localVideoSupersample
+	var prep string
+	if strings.EqualFold(strings.TrimSpace(p.Fit), "contain") {
+		prep = fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=black", cw, ch, cw, ch)
+	} else {
+		prep = fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d", cw, ch, cw, ch)
+	}
+
+	span := frames - 1
+	if span < 1 {
+		span = 1
+	}
+	t := fmt.Sprintf("(on/%d)", span)
+	ease := fmt.Sprintf("(%s*%s*(3-2*%s))", t, t, t)
+	lerp := func(a, b float64) string {
+		if a == b {
+			return ffmpegExprNum(a)
+		}
+		return fmt.Sprintf("(%s+(%s)*%s)", ffmpegExprNum(a), ffmpegExprNum(b-a), ease)
+	}
+	xFocus, yFocus := lerp(m.X0, m.X1), lerp(m.Y0, m.Y1)
+	if m.Shake {
+		xFocus = fmt.Sprintf("(%s+0.06*sin(on*0.37)+0.03*sin(on*1.13))", xFocus)
+		yFocus = fmt.Sprintf("(%s+0.05*cos(on*0.29)+0.03*sin(on*0.91))", yFocus)
+	}
+	zoompan := fmt.Sprintf("zoompan=z='%s':x='(iw-iw/zoom)*%s':y='(ih-ih/zoom)*%s':d=%d:s=%dx%d:fps=%d",
+		lerp(m.Z0, m.Z1), xFocus, yFocus, frames, width, height, fps)
+	return strings.Join([]string{prep, "setsar=1", zoompan, "format=yuv420p"}, ",")
+}
+
+// kenBurnsMotion describes a move as start/end zoom and focus point (0..1 of the spare
+// image area: 0 = left/top edge, 1 = right/bottom edge).
+type kenBurnsMotion struct {
+	Z0, Z1 float64
+	X0, X1 float64
+	Y0, Y1 float64
+	Shake  bool
+}
+
+// opening is the still pose at the first frame of the move.
+func (m kenBurnsMotion) opening() kenBurnsMotion {
+	return kenBurnsMotion{Z0: m.Z0, Z1: m.Z0, X0: m.X0, X1: m.X0, Y0: m.Y0, Y1: m.Y0, Shake: false}
+}
+
+// resolveKenBurnsMotion maps timeline camera_motion values (push_in, pull_out, pan_left,
+// pan_right, tilt_up, tilt_down, handheld, static, plus common free-form synonyms) to a move.
+// Keywords match whole words only, so "close" does not fire on "closet" and Chinese keywords
+// are full terms such as 左移 rather than single characters that appear in unrelated text.
+func resolveKenBurnsMotion(cameraMotion string) kenBurnsMotion {
+	m := strings.ToLower(strings.TrimSpace(cameraMotion))
+	words := "_" + strings.Join(strings.FieldsFunc(m, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "_") + "_"
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if isLatinKeyword(k) {
+				if strings.Contains(words, "_"+k+"_") {
+					return true
+				}
+			} else if strings.Contains(m, k) {
+				return true
+			}
+		}
+		return false
+	}
+	center := kenBurnsMotion{Z0: 1, Z1: 1, X0: 0.5, X1: 0.5, Y0: 0.5, Y1: 0.5}
+	switch {
+	case m == "" || has("static", "still", "locked", "fixed", "固定", "静止"):
+		return center
+	case has("push_in", "zoom_in", "dolly_in", "close", "close_up", "推进", "推近"):
+		center.Z0, center.Z1 = 1.0, 1.25
+	case has("pull_out", "pull_back", "zoom_out", "dolly_out", "拉远"):
+		center.Z0, center.Z1 = 1.25, 1.0
+	case has("pan_left", "truck_left", "左移", "左摇", "向左"):
+		center.Z0, center.Z1 = 1.2, 1.2
+		center.X0, center.X1 = 1, 0
+	case has("pan", "pan_right", "truck", "truck_right", "横移", "右移", "右摇", "向右"):
+		center.Z0, center.Z1 = 1.2, 1.2
+		center.X0, center.X1 = 0, 1
+	case has("tilt_up", "crane_up", "pedestal_up", "rise", "仰拍", "上摇", "上移", "向上"):
+		center.Z0, center.Z1 = 1.2, 1.2
+		center.Y0, center.Y1 = 1, 0
+	case has("tilt", "tilt_down", "crane_down", "pedestal_down", "俯拍", "下摇", "下移", "向下"):
+		center.Z0, center.Z1 = 1.2, 1.2
+		center.Y0, center.Y1 = 0, 1
+	case has("handheld", "shake", "follow", "tracking", "手持", "跟拍", "跟随"):
+		center.Z0, center.Z1 = 1.12, 1.12
+		center.Shake = true
+	default:
+		// unknown wording still gets a slow push so the frame never looks frozen
+		center.Z0, center.Z1 = 1.0, 1.1
+	}
+	return center
+}
+
+// isLatinKeyword reports whether a motion keyword is ASCII and so must match whole words.
+func isLatinKeyword(k string) bool {
+	for _, r := range k {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeVideoClipTransition folds timeline transition wording into cut/fade/crossfade.
+func normalizeVideoClipTransition(value string) string {
+	v := strings.ToLower(strings.TrimSpace(value))
+	v = strings.NewReplacer("-", "_", " ", "_").Replace(v)
+	switch v {
+	case "crossfade", "cross_fade", "dissolve", "cross_dissolve", "xfade", "mix", "叠化":
+		return VideoClipTransitionCrossfade
+	case "fade", "fade_black", "fade_to_black", "dip_to_black", "fade_in_out", "淡入淡出":
+		return VideoClipTransitionFade
+	default:
+		return VideoClipTransitionCut
+	}
+}
+
+// localVideoDimensions maps timeline resolutions (480P/720P/1080P or WxH / W*H) to even pixel sizes.
+func localVideoDimensions(resolution string) (int, int) {
+	r := strings.ToUpper(strings.TrimSpace(resolution))
+	switch r {
+	case "480P":
+		return 854, 480
+	case "1080P":
+		return 1920, 1080
+	case "", "720P":
+		return 1280, 720
+	}
+	sep := strings.IndexAny(r, "X*")
+	if sep > 0 {
+		w, errW := strconv.Atoi(strings.TrimSpace(r[:sep]))
+		h, errH := strconv.Atoi(strings.TrimSpace(r[sep+1:]))
+		if errW == nil && errH == nil && w >= 64 && h >= 64 && w <= 3840 && h <= 3840 {
+			return w &^ 1, h &^ 1
+		}
+	}
+	return 1280, 720
+}
+
+func localVideoDuration(sec float64) float64 {
+	if sec <= 0 {
+		return localVideoDefaultDurationSec
+	}
+	if sec < 0.5 {
+		return 0.5
+	}
+	if sec > videoClipMaxDurationSec {
+		return videoClipMaxDurationSec
+	}
+	return sec
+}
+
+func formatFFmpegSeconds(sec float64) string {
+	if sec < 0 {
+		sec = 0
+	}
+	return strconv.FormatFloat(sec, 'f', 3, 64)
+}
+
+func ffmpegExprNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func tailString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[len(s)-max:]
+}
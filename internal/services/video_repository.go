@@ -271,3 +271,39 @@ func (r *VideoRepository) SaveRenderArtifact(sceneID string, videoVersion string
 	}
 	return filepath.ToSlash(filepath.Join("scene_"+sceneID, "video", "renders", filename)), nil
 }
+
+// SaveNarrationAudio stores synthesized clip voice-over under video/audio/narration/.
+// Files are keyed by content hash, so they are shared across video versions.
+func (r *VideoRepository) SaveNarrationAudio(sceneID string, filename string, content []byte) (string, error) {
+	if err := validatePathSegment(strings.TrimSpace(filename)); err != nil {
+		return "", fmt.Errorf("invalid narration filename: %w", err)
+	}
+	if err := r.EnsureVideoLayout(sceneID); err != nil {
+		return "", err
+	}
+	videoDir, err := r.videoDir(sceneID)
+	if err != nil {
+		return "", err
+	}
+	if err := r.FileStorage.SaveTextFile(filepath.Join(videoDir, "audio", "narration"), filename, content); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(filepath.Join("scene_"+sceneID, "video", "audio", "narration", filename)), nil
+}
+
+// NarrationAudioPath returns the absolute path of a stored narration file if it exists.
+func (r *VideoRepository) NarrationAudioPath(sceneID string, filename string) (string, bool) {
+	if err := validatePathSegment(strings.TrimSpace(filename)); err != nil {
+		return "", false
+	}
+	videoDir, err := r.videoDir(sceneID)
+	if err != nil {
+		return "", false
+	}
+	abs := filepath.Join(r.BaseDir, videoDir, "audio", "narration", filename)
+	st, err := os.Stat(abs)
+	if err != nil || st.IsDir() || st.Size() == 0 {
+		return "", false
+	}
+	return abs, true
+}
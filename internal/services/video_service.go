@@ -33,6 +33,8 @@ var (
 	ErrVideoFrameNotFound             = errors.New("video frame not found")
 	ErrVideoReferenceImageURLRequired = errors.New("video reference image url is required")
 	ErrVideoPublicBaseURLInvalid      = errors.New("video public_base_url is invalid")
+	ErrVideoClipPatchInvalid          = errors.New("video clip patch is invalid")
+	ErrVideoNarrationVoiceInvalid     = errors.New("video narration voice is not allowed")
 )
 
 const (
@@ -43,6 +45,19 @@ const (
 	videoStageTotal           = 4
 )
 
+// Job queue types for video work submitted through JobQueue.
+const (
+	VideoJobTypeGenerate       = "video_generate"
+	VideoJobTypeRegenerateClip = "video_regenerate_clip"
+)
+
+// videoJobPayload is journaled with video jobs so they can be restored from the saved timeline.
+type videoJobPayload struct {
+	SceneID      string `json:"scene_id"`
+	VideoVersion string `json:"video_version"`
+	FrameID      string `json:"frame_id,omitempty"`
+}
+
 // VideoProvider is the future provider abstraction for DashScope and other video backends.
 type VideoProvider interface {
 	SubmitClipTask(ctx context.Context, req models.VideoClipRequest) (*models.VideoProviderTask, error)
@@ -60,6 +75,8 @@ type VideoService struct {
 	JobQueue  *JobQueue
 	Progress  *ProgressService
 	Provider  VideoProvider
+	// TTS synthesizes clip narration for the merged MP4; nil disables voice-over (subtitles are still written).
+	TTS TTSProvider
 
 	DefaultProvider        string
 	DefaultModel           string
@@ -75,7 +92,7 @@ type VideoService struct {
 }
 
 func NewVideoService(repo *VideoRepository, comicRepo *ComicRepository, jobQueue *JobQueue, progress *ProgressService, provider VideoProvider) *VideoService {
-	return &VideoService{
+	s := &VideoService{
 		Repo:                   repo,
 		ComicRepo:              comicRepo,
 		JobQueue:               jobQueue,
@@ -91,6 +108,46 @@ func NewVideoService(repo *VideoRepository, comicRepo *ComicRepository, jobQueue
 		DownloadTimeout:        60 * time.Second,
 		FFmpegPath:             "ffmpeg",
 	}
+	s.registerJobTypes()
+	return s
+}
+
+// registerJobTypes sets queue policies for video jobs. Clip-level retries are handled by
+// MaxClipRetries, so the queue only retries a whole job once.
+func (s *VideoService) registerJobTypes() {
+	if s.JobQueue == nil {
+		return
+	}
+	retry := RetryPolicy{MaxAttempts: 2, InitialBackoff: 30 * time.Second, MaxBackoff: 2 * time.Minute}
+	s.JobQueue.RegisterJobType(VideoJobTypeGenerate, JobTypePolicy{Priority: JobPriorityBatch, Retry: retry}, s.restoreJob)
+	s.JobQueue.RegisterJobType(VideoJobTypeRegenerateClip, JobTypePolicy{Priority: JobPriorityBatch, Retry: retry}, s.restoreJob)
+}
+
+// restoreJob re-submits a journaled video job against the timeline saved on disk.
+func (s *VideoService) restoreJob(rec JobRecord) error {
+	if err := s.ensureReady(); err != nil {
+		return err
+	}
+	var payload videoJobPayload
+	if err := json.Unmarshal(rec.Payload, &payload); err != nil {
+		return err
+	}
+	timeline, err := s.LoadTimeline(payload.SceneID)
+	if err != nil {
+		return err
+	}
+	if timeline.VideoVersion != payload.VideoVersion {
+		return fmt.Errorf("timeline changed since job was queued (have %s, want %s)", timeline.VideoVersion, payload.VideoVersion)
+	}
+	tracker := s.Progress.CreateTracker(rec.ID)
+	if rec.Type == VideoJobTypeRegenerateClip {
+		clip, err := findTimelineClip(timeline, payload.FrameID)
+		if err != nil {
+			return err
+		}
+		return s.submitRegenerateClipJob(context.Background(), rec.ID, tracker, payload.SceneID, payload.FrameID, timeline, clip)
+	}
+	return s.submitGenerateJob(context.Background(), rec.ID, tracker, payload.SceneID, timeline)
 }
 
 func (s *VideoService) ensureReady() error {
@@ -100,6 +157,24 @@ func (s *VideoService) ensureReady() error {
 	return nil
 }
 
+// validateNarrationVoice rejects a timeline voice the TTS backend would refuse, so the
+// request fails up front instead of every clip's voice-over failing inside the job.
+func (s *VideoService) validateNarrationVoice(voice string) error {
+	if voice == "" {
+		return nil
+	}
+	if resolver, ok := s.TTS.(TTSVoiceResolver); ok {
+		if _, err := resolver.ResolveVoice(voice); err != nil {
+			return fmt.Errorf("%w: %v", ErrVideoNarrationVoiceInvalid, err)
+		}
+		return nil
+	}
+	if !validTTSVoiceName(voice) {
+		return fmt.Errorf("%w: %q", ErrVideoNarrationVoiceInvalid, voice)
+	}
+	return nil
+}
+
 func sanitizeVideoConfig(cfg models.VideoConfig, defaultProvider string, defaultModel string) models.VideoConfig {
 	cfg.ComicSnapshotID = strings.TrimSpace(cfg.ComicSnapshotID)
 	if cfg.ComicSnapshotID == "" {
@@ -138,6 +213,7 @@ func sanitizeVideoConfig(cfg models.VideoConfig, defaultProvider string, default
 		cfg.AudioEnabled = true
 	}
 	cfg.ImageURL = strings.TrimSpace(cfg.ImageURL)
+	cfg.NarrationVoice = strings.TrimSpace(cfg.NarrationVoice)
 	cfg.PromptOptions = sanitizeVideoPromptOptions(cfg.PromptOptions)
 	return cfg
 }
@@ -193,6 +269,9 @@ func (s *VideoService) BuildTimeline(sceneID string, cfg models.VideoConfig) (*m
 		return nil, err
 	}
 	cfg = sanitizeVideoConfig(cfg, s.DefaultProvider, s.DefaultModel)
+	if err := s.validateNarrationVoice(cfg.NarrationVoice); err != nil {
+		return nil, err
+	}
 	if err := s.Repo.EnsureVideoLayout(sceneID); err != nil {
 		return nil, err
 	}
@@ -327,6 +406,9 @@ func (s *VideoService) BuildTimeline(sceneID string, cfg models.VideoConfig) (*m
 		PromptExtend:      cfg.PromptExtend,
 		AudioURL:          cfg.AudioURL,
 		PromptOptions:     cfg.PromptOptions,
+		NarrationEnabled:  cfg.NarrationEnabled,
+		NarrationVoice:    cfg.NarrationVoice,
+		BurnSubtitles:     cfg.BurnSubtitles,
 		Clips:             clips,
 		GeneratedAt:       now,
 		UpdatedAt:         now,
@@ -605,7 +687,16 @@ func (s *VideoService) GenerateVideoAsync(ctx context.Context, sceneID string, c
 	}
 	taskID := fmt.Sprintf("video_generate_%s_%d", sceneID, time.Now().UnixNano())
 	tracker := s.Progress.CreateTracker(taskID)
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
+	if err := s.submitGenerateJob(ctx, taskID, tracker, sceneID, timeline); err != nil {
+		return "", err
+	}
+	return taskID, nil
+}
+
+// submitGenerateJob queues the full video generation; the journal entry lets it resume after a restart.
+func (s *VideoService) submitGenerateJob(ctx context.Context, taskID string, tracker *ProgressTracker, sceneID string, timeline *models.VideoTimeline) error {
+	payload := videoJobPayload{SceneID: sceneID, VideoVersion: timeline.VideoVersion}
+	return s.JobQueue.SubmitJob(taskID, JobOptions{Type: VideoJobTypeGenerate, Payload: payload, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
 		tracker.EmitProgressEventWithMeta(5, "validating video snapshot", "video_validate", "", &ProgressEventMeta{
 			Phase:      "video_validate",
 			StageIndex: 1,
@@ -721,10 +812,7 @@ func (s *VideoService) GenerateVideoAsync(ctx context.Context, sceneID string, c
 		}
 		tracker.Complete("视频任务已完成（当前为占位编排）")
 		return nil
-	}); err != nil {
-		return "", err
-	}
-	return taskID, nil
+	})
 }
 
 func (s *VideoService) RegenerateClipAsync(ctx context.Context, sceneID string, frameID string, cfg models.VideoConfig) (string, error) {
@@ -753,7 +841,15 @@ func (s *VideoService) RegenerateClipAsync(ctx context.Context, sceneID string,
 	}
 	taskID := fmt.Sprintf("video_regenerate_%s_%s_%d", sceneID, frameID, time.Now().UnixNano())
 	tracker := s.Progress.CreateTracker(taskID)
-	if err := s.JobQueue.Submit(taskID, func(jobCtx context.Context) error {
+	if err := s.submitRegenerateClipJob(ctx, taskID, tracker, sceneID, frameID, timeline, clip); err != nil {
+		return "", err
+	}
+	return taskID, nil
+}
+
+func (s *VideoService) submitRegenerateClipJob(ctx context.Context, taskID string, tracker *ProgressTracker, sceneID string, frameID string, timeline *models.VideoTimeline, clip models.VideoTimelineClip) error {
+	payload := videoJobPayload{SceneID: sceneID, VideoVersion: timeline.VideoVersion, FrameID: frameID}
+	return s.JobQueue.SubmitJob(taskID, JobOptions{Type: VideoJobTypeRegenerateClip, Payload: payload, UserID: JobUserFromContext(ctx)}, func(jobCtx context.Context) error {
 		tracker.EmitProgressEventWithMeta(10, "validating clip regenerate request", "video_validate", frameID, &ProgressEventMeta{
 			Phase:        "video_validate",
 			VideoVersion: timeline.VideoVersion,
@@ -802,10 +898,7 @@ func (s *VideoService) RegenerateClipAsync(ctx context.Context, sceneID string,
 		}
 		tracker.Complete("视频分镜重生成已完成（当前为占位编排）")
 		return nil
-	}); err != nil {
-		return "", err
-	}
-	return taskID, nil
+	})
 }
 
 func (s *VideoService) updateMetaRunning(sceneID string, taskID string, timeline *models.VideoTimeline) error {
@@ -996,12 +1089,17 @@ func (s *VideoService) composeFallbackRender(sceneID string, timeline *models.Vi
 }
 
 func (s *VideoService) composeRenderArtifact(sceneID string, timeline *models.VideoTimeline, clipResults map[string]*models.VideoClipResult) (string, string, error) {
+	mix, cleanup := s.prepareNarration(sceneID, timeline)
+	defer cleanup()
 	if s.canComposeMergedMP4Render(timeline, clipResults) {
-		artifactPath, artifactType, err := s.composeMergedMP4Render(sceneID, timeline, clipResults)
+		artifactPath, artifactType, downgrades, err := s.composeMergedMP4Render(sceneID, timeline, clipResults, mix)
 		if err == nil {
+			s.updateMetaRenderDowngrades(sceneID, downgrades)
 			return artifactPath, artifactType, nil
 		}
 	}
+	// HTML previews neither burn in subtitles nor carry the voice-over.
+	s.updateMetaRenderDowngrades(sceneID, mix.downgrades(false, false))
 	if s.canComposeMergedRender(timeline, clipResults) {
 		return s.composeMergedRender(sceneID, timeline, clipResults)
 	}
@@ -1059,59 +1157,89 @@ func (s *VideoService) composeMergedRender(sceneID string, timeline *models.Vide
 	return artifactPath, "html_stitched_video", nil
 }
 
-func (s *VideoService) composeMergedMP4Render(sceneID string, timeline *models.VideoTimeline, clipResults map[string]*models.VideoClipResult) (string, string, error) {
+func (s *VideoService) composeMergedMP4Render(sceneID string, timeline *models.VideoTimeline, clipResults map[string]*models.VideoClipResult, mix *videoNarrationMix) (string, string, []string, error) {
 	if timeline == nil {
-		return "", "", ErrVideoTimelineNotFound
+		return "", "", nil, ErrVideoTimelineNotFound
 	}
 	if s.Repo == nil {
-		return "", "", ErrVideoRepositoryNotReady
+		return "", "", nil, ErrVideoRepositoryNotReady
 	}
 	ffmpegBinary, err := s.resolveFFmpegBinary()
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 	paths := make([]string, 0, len(timeline.Clips))
 	for _, clip := range timeline.Clips {
 		result := clipResults[clip.FrameID]
 		if result == nil || strings.TrimSpace(result.LocalPath) == "" {
-			return "", "", fmt.Errorf("missing local clip asset for %s", clip.FrameID)
+			return "", "", nil, fmt.Errorf("missing local clip asset for %s", clip.FrameID)
 		}
 		paths = append(paths, filepath.Join(s.Repo.BaseDir, filepath.FromSlash(result.LocalPath)))
 	}
 	concatFile, cleanup, err := s.createFFmpegConcatList(paths)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 	defer cleanup()
 	outputFile, outputCleanup, err := s.createFFmpegOutputFile(timeline.VideoVersion)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 	defer outputCleanup()
 	preferAudio := s.shouldAttemptMergedAudio(timeline)
-	args := s.buildFFmpegMergedMP4Args(concatFile, outputFile, timeline, preferAudio)
-	output, err := s.runFFmpegComposeCommand(ffmpegBinary, args)
-	if err != nil {
-		if preferAudio {
-			fallbackArgs := s.buildFFmpegMergedMP4Args(concatFile, outputFile, timeline, false)
-			fallbackOutput, fallbackErr := s.runFFmpegComposeCommand(ffmpegBinary, fallbackArgs)
-			if fallbackErr != nil {
-				return "", "", fmt.Errorf("ffmpeg compose failed: %w: %s", fallbackErr, strings.TrimSpace(fallbackOutput))
+	// Try the richest render first, then drop clip audio, burned-in subtitles and voice-over in turn.
+	// Each attempt remembers what it keeps so a fallback can be reported instead of passing silently.
+	type composeAttempt struct {
+		args   []string
+		burned bool
+		voiced bool
+	}
+	attempts := make([]composeAttempt, 0, 6)
+	seen := make(map[string]bool, 6)
+	addAttempt := func(args []string, burned bool, voiced bool) {
+		key := strings.Join(args, "\x00")
+		if !seen[key] {
+			seen[key] = true
+			attempts = append(attempts, composeAttempt{args: args, burned: burned, voiced: voiced})
+		}
+	}
+	if !mix.isEmpty() {
+		for _, burn := range []bool{true, false} {
+			addAttempt(s.buildFFmpegNarrationMP4Args(concatFile, outputFile, timeline, preferAudio, mix, burn), burn, true)
+			if preferAudio {
+				addAttempt(s.buildFFmpegNarrationMP4Args(concatFile, outputFile, timeline, false, mix, burn), burn, true)
 			}
-		} else {
-			return "", "", fmt.Errorf("ffmpeg compose failed: %w: %s", err, strings.TrimSpace(output))
 		}
 	}
+	addAttempt(s.buildFFmpegMergedMP4Args(concatFile, outputFile, timeline, preferAudio), false, false)
+	if preferAudio {
+		addAttempt(s.buildFFmpegMergedMP4Args(concatFile, outputFile, timeline, false), false, false)
+	}
+	var composeErr error
+	var used composeAttempt
+	for _, attempt := range attempts {
+		output, err := s.runFFmpegComposeCommand(ffmpegBinary, attempt.args)
+		if err == nil {
+			composeErr = nil
+			used = attempt
+			break
+		}
+		composeErr = fmt.Errorf("ffmpeg compose failed: %w: %s", err, strings.TrimSpace(output))
+	}
+	if composeErr != nil {
+		return "", "", nil, composeErr
+	}
+	downgrades := mix.downgrades(used.burned, used.voiced)
 	content, err := os.ReadFile(outputFile)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 	filename := fmt.Sprintf("preview_%s.mp4", timeline.VideoVersion)
 	artifactPath, err := s.Repo.SaveRenderArtifact(sceneID, timeline.VideoVersion, filename, content)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
-	return artifactPath, "mp4_stitched_video", nil
+	return artifactPath, "mp4_stitched_video", downgrades, nil
 }
 
 func (s *VideoService) shouldAttemptMergedAudio(timeline *models.VideoTimeline) bool {
@@ -1602,6 +1730,8 @@ func (s *VideoService) generateClipWithProviderAttempt(ctx context.Context, trac
 			return failed, err
 		}
 		result.LocalPath = localPath
+	} else if localPath := strings.TrimSpace(finalTask.LocalPath); localPath != "" {
+		result.LocalPath = localPath
 	}
 	return result, nil
 }
@@ -1658,6 +1788,7 @@ func buildVideoClipCacheKey(req models.VideoClipRequest) (string, error) {
 		ContinuityMode     string  `json:"continuity_mode,omitempty"`
 		PreviousFramePath  string  `json:"previous_frame_path,omitempty"`
 		NextFramePath      string  `json:"next_frame_path,omitempty"`
+		NextCameraMotion   string  `json:"next_camera_motion,omitempty"`
 	}
 	b, err := json.Marshal(cachePayload{
 		SceneID:            req.SceneID,
@@ -1679,6 +1810,7 @@ func buildVideoClipCacheKey(req models.VideoClipRequest) (string, error) {
 		ContinuityMode:     req.ContinuityMode,
 		PreviousFramePath:  req.PreviousFramePath,
 		NextFramePath:      req.NextFramePath,
+		NextCameraMotion:   req.NextCameraMotion,
 	})
 	if err != nil {
 		return "", err
@@ -1765,7 +1897,7 @@ func (s *VideoService) buildClipRequest(timeline *models.VideoTimeline, clip mod
 	if strings.TrimSpace(referenceImageURL) == "" && providerRequiresReferenceImage(timeline.Provider) {
 		return models.VideoClipRequest{}, fmt.Errorf("%w: 请显式传入 image_url/img_url，或在 settings/config 中配置 video_config.public_base_url", ErrVideoReferenceImageURLRequired)
 	}
-	return models.VideoClipRequest{
+	req := models.VideoClipRequest{
 		SceneID:            timeline.SceneID,
 		VideoVersion:       timeline.VideoVersion,
 		ComicSnapshotID:    timeline.ComicSnapshot.ComicSnapshotID,
@@ -1784,7 +1916,18 @@ func (s *VideoService) buildClipRequest(timeline *models.VideoTimeline, clip mod
 		CameraMotion:       clip.CameraMotion,
 		TransitionHint:     clip.Transition,
 		ContinuityMode:     "comic_snapshot",
-	}, nil
+	}
+	// a crossfade blends into the next shot, so the clip depends on its neighbour
+	if normalizeVideoClipTransition(clip.Transition) == VideoClipTransitionCrossfade {
+		for i := range timeline.Clips {
+			if timeline.Clips[i].FrameID == clip.FrameID && i+1 < len(timeline.Clips) {
+				req.NextFramePath = timeline.Clips[i+1].ImagePath
+				req.NextCameraMotion = timeline.Clips[i+1].CameraMotion
+				break
+			}
+		}
+	}
+	return req, nil
 }
 
 func (s *VideoService) downloadProviderVideo(ctx context.Context, sceneID string, videoVersion string, frameID string, resultURL string) (string, error) {
@@ -1883,7 +2026,8 @@ func (s *VideoService) resolveReferenceImageURL(ctx context.Context, sceneID str
 
 func providerRequiresReferenceImage(providerName string) bool {
 	provider := strings.ToLower(strings.TrimSpace(providerName))
-	return provider != "" && provider != "mock"
+	// local renders from the comic frame on disk and needs no public image URL
+	return provider != "" && provider != "mock" && provider != "local"
 }
 
 func (s *VideoService) uploadReferenceImage(ctx context.Context, sceneID string, frameID string, modelName string, imageContent []byte) (string, error) {
@@ -2140,6 +2284,22 @@ func (s *VideoService) UpdateTimelineClip(sceneID string, frameID string, patch
 	if patch.ReferenceImageURL != nil {
 		timeline.Clips[clipIndex].ReferenceImageURL = strings.TrimSpace(*patch.ReferenceImageURL)
 	}
+	if patch.Narration != nil {
+		timeline.Clips[clipIndex].Narration = strings.TrimSpace(*patch.Narration)
+	}
+	if patch.CameraMotion != nil {
+		timeline.Clips[clipIndex].CameraMotion = strings.TrimSpace(*patch.CameraMotion)
+	}
+	if patch.Transition != nil {
+		// stored as written; the local provider folds it into cut/fade/crossfade when rendering
+		timeline.Clips[clipIndex].Transition = strings.TrimSpace(*patch.Transition)
+	}
+	if patch.DurationSec != nil {
+		if *patch.DurationSec <= 0 || *patch.DurationSec > videoClipMaxDurationSec {
+			return nil, fmt.Errorf("%w: duration_sec must be within (0, %g]", ErrVideoClipPatchInvalid, videoClipMaxDurationSec)
+		}
+		timeline.Clips[clipIndex].DurationSec = *patch.DurationSec
+	}
 	timeline.Clips[clipIndex].PromptOptions = applyVideoPromptOptionsPatch(timeline.Clips[clipIndex].PromptOptions, patch.PromptOptions)
 	frameDescription := s.lookupFrameDescription(sceneID, frameID)
 	promptBase := strings.TrimSpace(timeline.Clips[clipIndex].PromptBase)
@@ -0,0 +1,305 @@
+// internal/services/video_tts.go
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/models"
+)
+
+// ErrTTSVoiceNotAllowed is returned for a requested voice outside tts_voices / tts_voice_dir.
+var ErrTTSVoiceNotAllowed = errors.New("tts voice not allowed")
+
+// TTSProvider synthesizes clip narration for the video composer, mirroring VideoProvider.
+type TTSProvider interface {
+	SynthesizeNarration(ctx context.Context, req models.VideoNarrationRequest) (*models.VideoNarrationResult, error)
+}
+
+// TTSVoiceResolver is implemented by TTS providers that restrict which voices a timeline may
+// request; the video service uses it to reject a bad narration_voice before a job starts.
+type TTSVoiceResolver interface {
+	ResolveVoice(voice string) (string, error)
+}
+
+// MockTTSProvider returns silent WAV audio whose length follows the text, so the
+// voice-over pipeline (mixing, subtitles, caching) can run without a speech engine.
+type MockTTSProvider struct {
+	SampleRate int
+}
+
+func NewMockTTSProvider() *MockTTSProvider {
+	return &MockTTSProvider{SampleRate: 16000}
+}
+
+func (p *MockTTSProvider) SynthesizeNarration(ctx context.Context, req models.VideoNarrationRequest) (*models.VideoNarrationResult, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, fmt.Errorf("narration text is empty")
+	}
+	rate := p.SampleRate
+	if rate <= 0 {
+		rate = 16000
+	}
+	duration := estimateNarrationDurationSec(text)
+	return &models.VideoNarrationResult{
+		Audio:       buildSilentWAV(rate, duration),
+		Format:      "wav",
+		DurationSec: duration,
+		Provider:    "mock",
+	}, nil
+}
+
+// CommandTTSProvider runs a local speech engine (piper, espeak-ng, ...) once per clip.
+// Args may contain the placeholders {text}, {text_file}, {output} and {voice}; the
+// engine must write a WAV file to {output}. With Stdin the text is also piped to stdin.
+// Voice is the configured default; a timeline may only pick another voice listed in
+// Voices or a model file inside VoiceDir.
+type CommandTTSProvider struct {
+	Name          string
+	Binary        string
+	Args          []string
+	Voice         string
+	Voices        []string
+	VoiceDir      string
+	Stdin         bool
+	Timeout       time.Duration
+	CommandRunner func(ctx context.Context, name string, args ...string) *exec.Cmd
+}
+
+// NewCommandTTSProvider builds a command-line TTS backend. preset is piper, espeak or
+// command; binary/args override the preset defaults.
+func NewCommandTTSProvider(preset string, binary string, args []string, voice string) (*CommandTTSProvider, error) {
+	p := &CommandTTSProvider{
+		Name:    strings.ToLower(strings.TrimSpace(preset)),
+		Binary:  strings.TrimSpace(binary),
+		Args:    args,
+		Voice:   strings.TrimSpace(voice),
+		Timeout: 60 * time.Second,
+	}
+	switch p.Name {
+	case "piper":
+		if p.Binary == "" {
+			p.Binary = "piper"
+		}
+		if len(p.Args) == 0 {
+			p.Args = []string{"--model", "{voice}", "--output_file", "{output}"}
+			p.Stdin = true
+		}
+		if p.Voice == "" {
+			return nil, fmt.Errorf("piper tts requires a voice model path (tts_voice)")
+		}
+	case "espeak", "espeak-ng":
+		if p.Binary == "" {
+			p.Binary = "espeak-ng"
+		}
+		if len(p.Args) == 0 {
+			p.Args = []string{"-v", "{voice}", "-w", "{output}", "-f", "{text_file}"}
+		}
+		if p.Voice == "" {
+			p.Voice = "en"
+		}
+	case "command":
+		if p.Binary == "" || len(p.Args) == 0 {
+			return nil, fmt.Errorf("command tts requires tts_command and tts_args")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported tts provider: %s", preset)
+	}
+	hasOutput := false
+	for _, a := range p.Args {
+		if strings.Contains(a, "{output}") {
+			hasOutput = true
+			break
+		}
+	}
+	if !hasOutput {
+		return nil, fmt.Errorf("tts_args must contain the {output} placeholder")
+	}
+	return p, nil
+}
+
+func (p *CommandTTSProvider) SynthesizeNarration(ctx context.Context, req models.VideoNarrationRequest) (*models.VideoNarrationResult, error) {
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, fmt.Errorf("narration text is empty")
+	}
+	binary, err := exec.LookPath(p.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("tts command %q not found: %w", p.Binary, err)
+	}
+	voice, err := p.ResolveVoice(req.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.MkdirTemp("", "scene-intruder-tts-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+	textFile := filepath.Join(workDir, "narration.txt")
+	outputFile := filepath.Join(workDir, "narration.wav")
+	if err := os.WriteFile(textFile, []byte(text), 0600); err != nil {
+		return nil, err
+	}
+
+	replacer := strings.NewReplacer("{text}", text, "{text_file}", textFile, "{output}", outputFile, "{voice}", voice)
+	args := make([]string, 0, len(p.Args))
+	for _, a := range p.Args {
+		args = append(args, replacer.Replace(a))
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	runner := p.CommandRunner
+	if runner == nil {
+		runner = exec.CommandContext
+	}
+	cmd := runner(runCtx, binary, args...)
+	if p.Stdin {
+		cmd.Stdin = strings.NewReader(text + "\n")
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("tts command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	audio, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("tts command produced no audio: %w", err)
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("tts command produced empty audio")
+	}
+	name := p.Name
+	if name == "" {
+		name = "command"
+	}
+	return &models.VideoNarrationResult{
+		Audio:       audio,
+		Format:      "wav",
+		DurationSec: wavDurationSec(audio),
+		Provider:    name,
+	}, nil
+}
+
+// ResolveVoice maps a requested voice to the value substituted for {voice}. The configured
+// default is always allowed. Any other voice must be a plain name (no path separators, no
+// leading dash) that is listed in Voices or names a model file inside VoiceDir.
+func (p *CommandTTSProvider) ResolveVoice(voice string) (string, error) {
+	voice = strings.TrimSpace(voice)
+	if voice == "" || voice == p.Voice {
+		return p.Voice, nil
+	}
+	if !validTTSVoiceName(voice) {
+		return "", fmt.Errorf("%w: %q", ErrTTSVoiceNotAllowed, voice)
+	}
+	if p.VoiceDir != "" {
+		for _, name := range []string{voice, voice + ".onnx"} {
+			path := filepath.Join(p.VoiceDir, name)
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return path, nil
+			}
+		}
+	}
+	for _, allowed := range p.Voices {
+		if allowed == voice {
+			return voice, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrTTSVoiceNotAllowed, voice)
+}
+
+// validTTSVoiceName rejects voices that could leave the voice directory or be parsed as a flag.
+func validTTSVoiceName(voice string) bool {
+	return voice != "" && !strings.ContainsAny(voice, `/\`) && !strings.Contains(voice, "..") && !strings.HasPrefix(voice, "-")
+}
+
+// estimateNarrationDurationSec approximates speaking time: ~4 CJK characters or
+// ~2.5 words per second, clamped to 1..30 seconds.
+func estimateNarrationDurationSec(text string) float64 {
+	cjk := 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		}
+	}
+	words := len(strings.Fields(text))
+	sec := float64(cjk)/4.0 + float64(words)/2.5
+	if sec < 1 {
+		sec = 1
+	}
+	if sec > 30 {
+		sec = 30
+	}
+	return sec
+}
+
+// buildSilentWAV encodes mono 16-bit PCM silence.
+func buildSilentWAV(sampleRate int, durationSec float64) []byte {
+	samples := int(float64(sampleRate) * durationSec)
+	dataLen := samples * 2
+	var buf bytes.Buffer
+	buf.Grow(44 + dataLen)
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+dataLen))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // mono
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(dataLen))
+	buf.Write(make([]byte, dataLen))
+	return buf.Bytes()
+}
+
+// wavDurationSec reads the duration from a RIFF/WAVE header; 0 if it cannot be determined.
+func wavDurationSec(data []byte) float64 {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0
+	}
+	byteRate := uint32(0)
+	for off := 12; off+8 <= len(data); {
+		id := string(data[off : off+4])
+		size := binary.LittleEndian.Uint32(data[off+4 : off+8])
+		body := off + 8
+		switch id {
+		case "fmt ":
+			if body+12 <= len(data) {
+				byteRate = binary.LittleEndian.Uint32(data[body+8 : body+12])
+			}
+		case "data":
+			if byteRate == 0 {
+				return 0
+			}
+			// streamed WAV output may leave the data size as 0 or 0xFFFFFFFF
+			if size == 0 || int(size) > len(data)-body {
+				size = uint32(len(data) - body)
+			}
+			return float64(size) / float64(byteRate)
+		}
+		off = body + int(size) + int(size&1)
+	}
+	return 0
+}
@@ -4,10 +4,14 @@ package services
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
 	"github.com/Corphon/SceneIntruderMCP/internal/config"
+	"github.com/Corphon/SceneIntruderMCP/internal/vision"
 	"github.com/Corphon/SceneIntruderMCP/internal/vision/providers"
 )
 
@@ -45,6 +49,47 @@ func ApplyVisionConfig(svc *VisionService, cfg *config.AppConfig) error {
 			return fmt.Errorf("sdwebui endpoint missing")
 		}
 		svc.RegisterProvider("sdwebui", providers.NewSDWebUIProvider(endpoint))
+	case "comfyui":
+		endpoint := ""
+		workflow := ""
+		workflowImg2Img := ""
+		checkpoint := ""
+		outputNode := ""
+		timeoutSec := 0
+		if cfg.VisionConfig != nil {
+			endpoint = strings.TrimSpace(cfg.VisionConfig["endpoint"])
+			if endpoint == "" {
+				endpoint = strings.TrimSpace(cfg.VisionConfig["base_url"])
+			}
+			workflow = strings.TrimSpace(cfg.VisionConfig["workflow"])
+			workflowImg2Img = strings.TrimSpace(cfg.VisionConfig["workflow_img2img"])
+			checkpoint = strings.TrimSpace(cfg.VisionConfig["checkpoint"])
+			outputNode = strings.TrimSpace(cfg.VisionConfig["output_node"])
+			if v := strings.TrimSpace(cfg.VisionConfig["timeout_sec"]); v != "" {
+				if n, err := strconv.Atoi(v); err == nil {
+					timeoutSec = n
+				}
+			}
+		}
+		if endpoint == "" {
+			return fmt.Errorf("comfyui endpoint missing")
+		}
+		if err := providers.ValidateComfyUIWorkflow(workflow); err != nil {
+			return err
+		}
+		if workflowImg2Img != "" {
+			if err := providers.ValidateComfyUIWorkflow(workflowImg2Img); err != nil {
+				return fmt.Errorf("workflow_img2img: %w", err)
+			}
+		}
+		cp := providers.NewComfyUIProvider(endpoint, workflow)
+		cp.WorkflowImg2Img = workflowImg2Img
+		cp.Checkpoint = checkpoint
+		cp.OutputNode = outputNode
+		if timeoutSec > 0 {
+			cp.Timeout = time.Duration(timeoutSec) * time.Second
+		}
+		svc.RegisterProvider("comfyui", cp)
 	case "dashscope":
 		endpoint := ""
 		apiKey := ""
@@ -258,6 +303,77 @@ func ApplyVisionConfig(svc *VisionService, cfg *config.AppConfig) error {
 			gp.SizeKeyOverrides["glm-image"] = sizeGLMImage
 		}
 		svc.RegisterProvider("glm", gp)
+	case "replay":
+		cassettePath := ""
+		modeName := ""
+		upstreamName := ""
+		upstreamModel := ""
+		ignorePatterns := ""
+		if cfg.VisionConfig != nil {
+			cassettePath = strings.TrimSpace(cfg.VisionConfig["cassette"])
+			modeName = cfg.VisionConfig["mode"]
+			upstreamName = strings.TrimSpace(cfg.VisionConfig["upstream"])
+			upstreamModel = strings.TrimSpace(cfg.VisionConfig["upstream_model"])
+			ignorePatterns = cfg.VisionConfig["ignore_patterns"]
+		}
+		dataDir := cfg.DataDir
+		if dataDir == "" {
+			dataDir = "data"
+		}
+		cassetteDir := filepath.Join(dataDir, "cassettes")
+		if cassettePath == "" {
+			cassettePath = filepath.Join(cassetteDir, "vision.json")
+		} else {
+			// recording rewrites the whole file, so only relative paths under $DATA_DIR/cassettes are allowed
+			resolved, err := cassette.ResolvePath(cassetteDir, cassettePath)
+			if err != nil {
+				return err
+			}
+			cassettePath = resolved
+		}
+		mode, err := cassette.ParseMode(modeName)
+		if err != nil {
+			return err
+		}
+		patterns, err := cassette.ParsePatterns(ignorePatterns)
+		if err != nil {
+			return err
+		}
+		normalizer, err := cassette.NewNormalizer(patterns)
+		if err != nil {
+			return err
+		}
+		if upstreamName == "replay" {
+			return fmt.Errorf("replay upstream cannot be replay")
+		}
+
+		// The upstream is built from the same vision_config, as if it were the configured provider.
+		// Strict mode never calls it, so it needs no endpoint or credentials.
+		var upstream vision.VisionProvider
+		if upstreamName != "" && mode != cassette.ModeStrict {
+			upstreamCfg := *cfg
+			upstreamCfg.VisionProvider = upstreamName
+			upstreamCfg.VisionDefaultModel = ""
+			upstreamCfg.VisionModelProviders = nil
+			scratch := NewVisionService(nil)
+			if err := ApplyVisionConfig(scratch, &upstreamCfg); err != nil {
+				return fmt.Errorf("replay upstream %s: %w", upstreamName, err)
+			}
+			upstream = scratch.Providers[upstreamName]
+			if upstreamModel == "" {
+				upstreamModel = scratch.DefaultModel
+			}
+		}
+
+		c, err := cassette.Open(cassettePath, "vision")
+		if err != nil {
+			return err
+		}
+		rp, err := providers.NewReplayVisionProvider(c, mode, upstream, upstreamModel, normalizer)
+		if err != nil {
+			return err
+		}
+		svc.RegisterProvider("replay", rp)
 	default:
 		return fmt.Errorf("unsupported vision provider: %s", provider)
 	}
@@ -267,6 +383,8 @@ func ApplyVisionConfig(svc *VisionService, cfg *config.AppConfig) error {
 	if defaultModel == "" {
 		if provider == "sdwebui" {
 			defaultModel = "sd"
+		} else if provider == "comfyui" {
+			defaultModel = "comfyui"
 		} else if provider == "dashscope" {
 			defaultModel = "qwen-image-max"
 		} else if provider == "gemini" {
@@ -277,6 +395,8 @@ func ApplyVisionConfig(svc *VisionService, cfg *config.AppConfig) error {
 			defaultModel = "gpt-image-1.5"
 		} else if provider == "glm" {
 			defaultModel = "glm-image"
+		} else if provider == "replay" {
+			defaultModel = "replay"
 		} else {
 			defaultModel = "placeholder"
 		}
@@ -10,3 +10,4 @@ import "github.com/Corphon/SceneIntruderMCP/internal/vision"
 type VisionProvider = vision.VisionProvider
 type VisionGenerateOptions = vision.VisionGenerateOptions
 type VisionImage = vision.VisionImage
+type InpaintingProvider = vision.InpaintingProvider
@@ -9,6 +9,7 @@ import (
 	"image/png"
 	"time"
 
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
 	"github.com/Corphon/SceneIntruderMCP/internal/utils"
 	"github.com/Corphon/SceneIntruderMCP/internal/vision/providers"
 )
@@ -107,7 +108,7 @@ func isRetryableVisionError(err error) bool {
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false
 	}
-	if errors.Is(err, ErrVisionProviderNotFound) {
+	if errors.Is(err, ErrVisionProviderNotFound) || errors.Is(err, cassette.ErrNoMatch) {
 		return false
 	}
 	return true
@@ -137,6 +138,23 @@ func (s *VisionService) providerForModel(modelKey string) string {
 	return s.ModelProviders[modelKey]
 }
 
+// SupportsInpainting reports whether the provider serving modelKey can repaint a masked region.
+func (s *VisionService) SupportsInpainting(modelKey string) bool {
+	if modelKey == "" {
+		modelKey = s.DefaultModel
+	}
+	provider := s.providerForModel(modelKey)
+	if provider == "" {
+		provider = s.DefaultProvider
+	}
+	p, ok := s.Providers[provider]
+	if !ok {
+		return false
+	}
+	ip, ok := p.(InpaintingProvider)
+	return ok && ip.SupportsInpainting()
+}
+
 func (s *VisionService) GenerateImage(ctx context.Context, provider string, prompt string, opts VisionGenerateOptions) (*VisionImage, error) {
 	if opts.Model == "" {
 		opts.Model = s.DefaultModel
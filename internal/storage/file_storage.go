@@ -19,7 +19,8 @@ type FileStorage struct {
 	BaseDir string
 
 	// 并发控制
-	fileLocks sync.Map // 文件级别锁 path -> *sync.RWMutex
+	fileLocks sync.Map   // 文件级别锁 path -> *sync.RWMutex
+	txMutex   sync.Mutex // 串行化 WithTx 的提交
 
 	// 简单缓存
 	cache        map[string]*CacheEntry
@@ -57,6 +58,11 @@ func NewFileStorage(baseDir string) (*FileStorage, error) {
 	return fs, nil
 }
 
+// Root 返回存储根目录
+func (fs *FileStorage) Root() string {
+	return fs.BaseDir
+}
+
 // 获取文件锁
 func (fs *FileStorage) getFileLock(fullPath string) *sync.RWMutex {
 	value, _ := fs.fileLocks.LoadOrStore(fullPath, &sync.RWMutex{})
@@ -299,6 +305,25 @@ func (fs *FileStorage) ListDirs(dirPath string) ([]string, error) {
 	return dirs, nil
 }
 
+// ListFiles 列出目录下的所有文件（忽略写入中的临时文件）
+func (fs *FileStorage) ListFiles(dirPath string) ([]string, error) {
+	fullPath := filepath.Join(fs.BaseDir, dirPath)
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+
+	return files, nil
+}
+
 // 开始缓存清理
 func (fs *FileStorage) StartCacheCleanup() {
 	go func() {
@@ -0,0 +1,123 @@
+// internal/storage/file_tx.go
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// fileTxOp 事务中缓冲的一次写操作
+type fileTxOp struct {
+	dir       string
+	name      string
+	content   []byte
+	deleteDir bool
+	delete    bool
+}
+
+// fileTx 文件后端的事务：写操作先缓冲在内存中，fn 成功返回后再按顺序落盘。
+// 文件系统没有跨文件的原子提交，落盘中途失败时已写入的文件不会回滚（尽力而为）。
+type fileTx struct {
+	fs  *FileStorage
+	ops []fileTxOp
+}
+
+// WithTx 在事务中执行 fn
+func (fs *FileStorage) WithTx(fn func(tx Tx) error) error {
+	fs.txMutex.Lock()
+	defer fs.txMutex.Unlock()
+
+	tx := &fileTx{fs: fs}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	for _, op := range tx.ops {
+		var err error
+		switch {
+		case op.deleteDir:
+			err = fs.DeleteDir(op.dir)
+		case op.delete:
+			err = fs.DeleteFile(op.dir, op.name)
+		default:
+			err = fs.SaveTextFile(op.dir, op.name, op.content)
+		}
+		if err != nil {
+			return fmt.Errorf("提交事务失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (tx *fileTx) SaveTextFile(dirPath, filename string, content []byte) error {
+	data := make([]byte, len(content))
+	copy(data, content)
+	tx.ops = append(tx.ops, fileTxOp{dir: dirPath, name: filename, content: data})
+	return nil
+}
+
+func (tx *fileTx) SaveJSONFile(dirPath, filename string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化JSON失败: %w", err)
+	}
+	return tx.SaveTextFile(dirPath, filename, content)
+}
+
+// LoadTextFile 优先读取事务内尚未提交的写入
+func (tx *fileTx) LoadTextFile(dirPath, filename string) ([]byte, error) {
+	key := cleanKey(filepath.Join(dirPath, filename))
+	for i := len(tx.ops) - 1; i >= 0; i-- {
+		op := tx.ops[i]
+		if op.deleteDir {
+			prefix := cleanKey(op.dir)
+			if prefix == "" || strings.HasPrefix(key, prefix+"/") {
+				return nil, notExistError(key)
+			}
+			continue
+		}
+		if cleanKey(filepath.Join(op.dir, op.name)) != key {
+			continue
+		}
+		if op.delete {
+			return nil, notExistError(key)
+		}
+		return op.content, nil
+	}
+	return tx.fs.LoadTextFile(dirPath, filename)
+}
+
+func (tx *fileTx) LoadJSONFile(dirPath, filename string, v interface{}) error {
+	content, err := tx.LoadTextFile(dirPath, filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析JSON失败: %w", err)
+	}
+	return nil
+}
+
+func (tx *fileTx) DeleteFile(dirPath, filename string) error {
+	if _, err := tx.LoadTextFile(dirPath, filename); err != nil {
+		return fmt.Errorf("文件不存在: %s", filepath.Join(dirPath, filename))
+	}
+	tx.ops = append(tx.ops, fileTxOp{dir: dirPath, name: filename, delete: true})
+	return nil
+}
+
+func (tx *fileTx) DeleteDir(dirPath string) error {
+	if !tx.fs.DirExists(dirPath) {
+		return fmt.Errorf("目录不存在: %s", dirPath)
+	}
+	tx.ops = append(tx.ops, fileTxOp{dir: dirPath, deleteDir: true})
+	return nil
+}
+
+// notExistError 构造与 os 包兼容的「不存在」错误（os.IsNotExist / errors.Is 均可识别）
+func notExistError(path string) error {
+	return fmt.Errorf("读取文件失败: %w", &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist})
+}
@@ -0,0 +1,377 @@
+// internal/storage/sqlite_storage.go
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	dir        TEXT    NOT NULL,
+	name       TEXT    NOT NULL,
+	content    BLOB    NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (dir, name)
+);
+CREATE INDEX IF NOT EXISTS idx_entries_dir ON entries (dir);
+`
+
+// SQLiteStorage 基于嵌入式 SQLite 的存储实现。
+// 所有服务共用一个数据库文件，各自的 baseDir 映射为命名空间（entries.dir 的前缀），
+// 因此列目录、按目录删除都是索引上的范围查询，且多文件写入可以放在同一个事务里。
+type SQLiteStorage struct {
+	db        *sql.DB
+	path      string
+	namespace string
+}
+
+var (
+	sqliteMutex sync.Mutex
+	sqliteDBs   = make(map[string]*sql.DB) // 数据库文件路径 -> 共享连接池
+)
+
+// OpenSQLiteStorage 打开（或复用）dbPath 对应的数据库，并返回 namespace 下的存储视图
+func OpenSQLiteStorage(dbPath, namespace string) (*SQLiteStorage, error) {
+	db, err := openSQLiteDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStorage{db: db, path: dbPath, namespace: cleanKey(namespace)}, nil
+}
+
+func openSQLiteDB(dbPath string) (*sql.DB, error) {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		absPath = filepath.Clean(dbPath)
+	}
+
+	sqliteMutex.Lock()
+	defer sqliteMutex.Unlock()
+
+	if db, ok := sqliteDBs[absPath]; ok {
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	// WAL 允许读写并发；_txlock=immediate 让事务一开始就拿写锁，避免升级锁时的死锁
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate", filepath.ToSlash(absPath))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开SQLite数据库失败: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化SQLite数据库失败: %w", err)
+	}
+
+	sqliteDBs[absPath] = db
+	return db, nil
+}
+
+// CloseSQLiteStorages 关闭所有已打开的数据库连接（进程退出或迁移完成时调用）
+func CloseSQLiteStorages() error {
+	sqliteMutex.Lock()
+	defer sqliteMutex.Unlock()
+
+	var errs []error
+	for key, db := range sqliteDBs {
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(sqliteDBs, key)
+	}
+	return errors.Join(errs...)
+}
+
+// Root 返回逻辑根路径（数据库文件#命名空间），仅用于日志与比较
+func (s *SQLiteStorage) Root() string {
+	return s.path + "#" + s.namespace
+}
+
+// dirKey 将服务内的相对目录换算为 entries.dir 的值
+func (s *SQLiteStorage) dirKey(dirPath string) string {
+	return cleanKey(path.Join(s.namespace, filepath.ToSlash(dirPath)))
+}
+
+// splitKey 将 (dirPath, filename) 规范化；filename 可以包含子目录（如 characters/a.json）
+func (s *SQLiteStorage) splitKey(dirPath, filename string) (string, string) {
+	full := s.dirKey(path.Join(filepath.ToSlash(dirPath), filepath.ToSlash(filename)))
+	dir, name := path.Split(full)
+	return strings.TrimSuffix(dir, "/"), name
+}
+
+// sqlQueryer 是 *sql.DB 与 *sql.Tx 的公共子集，使读写逻辑可以在两者间复用
+type sqlQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *SQLiteStorage) save(q sqlQueryer, dirPath, filename string, content []byte) error {
+	dir, name := s.splitKey(dirPath, filename)
+	if name == "" {
+		return fmt.Errorf("文件名不能为空")
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	_, err := q.Exec(`INSERT INTO entries (dir, name, content, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(dir, name) DO UPDATE SET content = excluded.content, updated_at = excluded.updated_at`,
+		dir, name, content, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("保存文件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) load(q sqlQueryer, dirPath, filename string) ([]byte, error) {
+	dir, name := s.splitKey(dirPath, filename)
+	var content []byte
+	err := q.QueryRow(`SELECT content FROM entries WHERE dir = ? AND name = ?`, dir, name).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notExistError(path.Join(dir, name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	return content, nil
+}
+
+func (s *SQLiteStorage) deleteFile(q sqlQueryer, dirPath, filename string) error {
+	dir, name := s.splitKey(dirPath, filename)
+	res, err := q.Exec(`DELETE FROM entries WHERE dir = ? AND name = ?`, dir, name)
+	if err != nil {
+		return fmt.Errorf("删除文件失败: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("文件不存在: %s", path.Join(dir, name))
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) deleteDir(q sqlQueryer, dirPath string) error {
+	dir := s.dirKey(dirPath)
+	where, args := subtreeCondition(dir)
+	res, err := q.Exec(`DELETE FROM entries WHERE `+where, args...)
+	if err != nil {
+		return fmt.Errorf("删除目录失败: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("目录不存在: %s", dir)
+	}
+	return nil
+}
+
+// subtreeCondition 生成「dir 本身及其全部子目录」的条件。
+// 用 [prefix/, prefix0) 的范围比较代替 LIKE，既能走索引又不必转义 '_' 和 '%'（'0' 紧跟在 '/' 之后）。
+func subtreeCondition(dir string) (string, []interface{}) {
+	if dir == "" {
+		return "1 = 1", nil
+	}
+	return "(dir = ? OR (dir >= ? AND dir < ?))", []interface{}{dir, dir + "/", dir + "0"}
+}
+
+// SaveTextFile 保存文本文件
+func (s *SQLiteStorage) SaveTextFile(dirPath, filename string, content []byte) error {
+	return s.save(s.db, dirPath, filename, content)
+}
+
+// SaveJSONFile 保存JSON文件
+func (s *SQLiteStorage) SaveJSONFile(dirPath, filename string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化JSON失败: %w", err)
+	}
+	return s.save(s.db, dirPath, filename, content)
+}
+
+// LoadTextFile 读取文本文件；不存在时返回可被 IsNotExist 识别的错误
+func (s *SQLiteStorage) LoadTextFile(dirPath, filename string) ([]byte, error) {
+	return s.load(s.db, dirPath, filename)
+}
+
+// LoadJSONFile 读取并解析JSON文件
+func (s *SQLiteStorage) LoadJSONFile(dirPath, filename string, v interface{}) error {
+	content, err := s.load(s.db, dirPath, filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析JSON失败: %w", err)
+	}
+	return nil
+}
+
+// FileExists 检查文件是否存在
+func (s *SQLiteStorage) FileExists(dirPath, filename string) bool {
+	dir, name := s.splitKey(dirPath, filename)
+	var one int
+	err := s.db.QueryRow(`SELECT 1 FROM entries WHERE dir = ? AND name = ?`, dir, name).Scan(&one)
+	return err == nil
+}
+
+// DirExists 检查目录是否存在（SQLite 中没有空目录：目录下至少有一个文件才算存在）
+func (s *SQLiteStorage) DirExists(dirPath string) bool {
+	where, args := subtreeCondition(s.dirKey(dirPath))
+	var one int
+	err := s.db.QueryRow(`SELECT 1 FROM entries WHERE `+where+` LIMIT 1`, args...).Scan(&one)
+	return err == nil
+}
+
+// ListDirs 列出目录下的所有子目录
+func (s *SQLiteStorage) ListDirs(dirPath string) ([]string, error) {
+	dir := s.dirKey(dirPath)
+	where, args := subtreeCondition(dir)
+	rows, err := s.db.Query(`SELECT DISTINCT dir FROM entries WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	defer rows.Close()
+
+	found := false
+	seen := make(map[string]bool)
+	var dirs []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, fmt.Errorf("读取目录失败: %w", err)
+		}
+		found = true
+		rest := d
+		if dir != "" {
+			if d == dir {
+				continue
+			}
+			rest = strings.TrimPrefix(d, dir+"/")
+		}
+		if rest == "" {
+			continue
+		}
+		child := strings.SplitN(rest, "/", 2)[0]
+		if !seen[child] {
+			seen[child] = true
+			dirs = append(dirs, child)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	if !found && dir != s.namespace {
+		return nil, fmt.Errorf("读取目录失败: %w", notExistError(dir))
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// ListFiles 列出目录下的所有文件
+func (s *SQLiteStorage) ListFiles(dirPath string) ([]string, error) {
+	dir := s.dirKey(dirPath)
+	rows, err := s.db.Query(`SELECT name FROM entries WHERE dir = ? ORDER BY name`, dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("读取目录失败: %w", err)
+		}
+		files = append(files, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	if len(files) == 0 && dir != s.namespace && !s.DirExists(dirPath) {
+		return nil, fmt.Errorf("读取目录失败: %w", notExistError(dir))
+	}
+	return files, nil
+}
+
+// DeleteFile 删除文件
+func (s *SQLiteStorage) DeleteFile(dirPath, filename string) error {
+	return s.deleteFile(s.db, dirPath, filename)
+}
+
+// DeleteDir 删除目录及其内容
+func (s *SQLiteStorage) DeleteDir(dirPath string) error {
+	return s.deleteDir(s.db, dirPath)
+}
+
+// WithTx 在数据库事务中执行 fn
+func (s *SQLiteStorage) WithTx(fn func(tx Tx) error) error {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+
+	if err := fn(&sqliteTx{store: s, tx: sqlTx}); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("回滚事务失败: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// sqliteTx 绑定到单个 *sql.Tx 的 Tx 实现
+type sqliteTx struct {
+	store *SQLiteStorage
+	tx    *sql.Tx
+}
+
+func (t *sqliteTx) SaveTextFile(dirPath, filename string, content []byte) error {
+	return t.store.save(t.tx, dirPath, filename, content)
+}
+
+func (t *sqliteTx) SaveJSONFile(dirPath, filename string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化JSON失败: %w", err)
+	}
+	return t.store.save(t.tx, dirPath, filename, content)
+}
+
+func (t *sqliteTx) LoadTextFile(dirPath, filename string) ([]byte, error) {
+	return t.store.load(t.tx, dirPath, filename)
+}
+
+func (t *sqliteTx) LoadJSONFile(dirPath, filename string, v interface{}) error {
+	content, err := t.store.load(t.tx, dirPath, filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析JSON失败: %w", err)
+	}
+	return nil
+}
+
+func (t *sqliteTx) DeleteFile(dirPath, filename string) error {
+	return t.store.deleteFile(t.tx, dirPath, filename)
+}
+
+func (t *sqliteTx) DeleteDir(dirPath string) error {
+	return t.store.deleteDir(t.tx, dirPath)
+}
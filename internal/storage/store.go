@@ -0,0 +1,152 @@
+// internal/storage/store.go
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store 存储后端接口：以「目录 + 文件名」寻址的键值存储。
+// FileStorage 将其映射为磁盘文件，SQLiteStorage 映射为数据库中的行。
+type Store interface {
+	// Root 返回该存储实例对应的根目录（文件后端为真实路径，SQLite 后端为逻辑路径）
+	Root() string
+
+	SaveTextFile(dirPath, filename string, content []byte) error
+	SaveJSONFile(dirPath, filename string, data interface{}) error
+	LoadTextFile(dirPath, filename string) ([]byte, error)
+	LoadJSONFile(dirPath, filename string, v interface{}) error
+
+	FileExists(dirPath, filename string) bool
+	DirExists(dirPath string) bool
+	// ListDirs 列出 dirPath 下的直接子目录名
+	ListDirs(dirPath string) ([]string, error)
+	// ListFiles 列出 dirPath 下的直接文件名（不含子目录）
+	ListFiles(dirPath string) ([]string, error)
+
+	DeleteFile(dirPath, filename string) error
+	DeleteDir(dirPath string) error
+
+	// WithTx 在事务中执行 fn：fn 返回错误时所有写入都会被丢弃。
+	// fn 内必须通过 tx 访问存储，不能再调用 Store 自身的方法。
+	WithTx(fn func(tx Tx) error) error
+}
+
+// Tx 事务内可用的读写操作
+type Tx interface {
+	SaveTextFile(dirPath, filename string, content []byte) error
+	SaveJSONFile(dirPath, filename string, data interface{}) error
+	LoadTextFile(dirPath, filename string) ([]byte, error)
+	LoadJSONFile(dirPath, filename string, v interface{}) error
+	DeleteFile(dirPath, filename string) error
+	DeleteDir(dirPath string) error
+}
+
+var (
+	_ Store = (*FileStorage)(nil)
+	_ Store = (*SQLiteStorage)(nil)
+)
+
+// 存储后端类型
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+// Options 全局存储后端配置
+type Options struct {
+	// Backend 为 "file"（默认）或 "sqlite"
+	Backend string
+	// DataDir 数据根目录；SQLite 后端据此把各服务的 baseDir 换算为命名空间
+	DataDir string
+	// SQLitePath 数据库文件路径，默认 <DataDir>/scene_intruder.db
+	SQLitePath string
+}
+
+var (
+	optionsMutex   sync.RWMutex
+	currentOptions = Options{Backend: BackendFile, DataDir: "data"}
+)
+
+// Configure 设置全局存储后端；需在创建各服务之前调用
+func Configure(opts Options) error {
+	opts.Backend = strings.ToLower(strings.TrimSpace(opts.Backend))
+	if opts.Backend == "" {
+		opts.Backend = BackendFile
+	}
+	if opts.Backend != BackendFile && opts.Backend != BackendSQLite {
+		return fmt.Errorf("不支持的存储后端: %s", opts.Backend)
+	}
+	if strings.TrimSpace(opts.DataDir) == "" {
+		opts.DataDir = "data"
+	}
+	if strings.TrimSpace(opts.SQLitePath) == "" {
+		opts.SQLitePath = filepath.Join(opts.DataDir, "scene_intruder.db")
+	}
+
+	optionsMutex.Lock()
+	defer optionsMutex.Unlock()
+	currentOptions = opts
+	return nil
+}
+
+// CurrentOptions 返回当前的全局存储配置
+func CurrentOptions() Options {
+	optionsMutex.RLock()
+	defer optionsMutex.RUnlock()
+	return currentOptions
+}
+
+// Open 按全局配置为 baseDir 创建存储实例
+func Open(baseDir string) (Store, error) {
+	opts := CurrentOptions()
+	// 失败时显式返回 nil 接口，避免调用方拿到「非 nil 接口包裹 nil 指针」
+	if opts.Backend == BackendSQLite {
+		store, err := OpenSQLiteStorage(opts.SQLitePath, namespaceFor(opts.DataDir, baseDir))
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	store, err := NewFileStorage(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// IsNotExist 判断错误是否表示文件/目录不存在（两种后端通用）
+func IsNotExist(err error) bool {
+	return err != nil && errors.Is(err, fs.ErrNotExist)
+}
+
+// namespaceFor 将 baseDir 换算为相对数据根目录的命名空间，例如 data/scenes -> scenes
+func namespaceFor(dataDir, baseDir string) string {
+	base := filepath.Clean(baseDir)
+	if rel, err := filepath.Rel(filepath.Clean(dataDir), base); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return cleanKey(rel)
+	}
+	if abs, err := filepath.Abs(base); err == nil {
+		if absData, err := filepath.Abs(dataDir); err == nil {
+			if rel, err := filepath.Rel(absData, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return cleanKey(rel)
+			}
+		}
+		return cleanKey(abs)
+	}
+	return cleanKey(base)
+}
+
+// cleanKey 将路径统一为不带首尾斜杠的 "/" 分隔形式；根目录返回空串
+func cleanKey(p string) string {
+	p = filepath.ToSlash(filepath.Clean(filepath.FromSlash(p)))
+	p = strings.Trim(p, "/")
+	if p == "." {
+		return ""
+	}
+	return p
+}
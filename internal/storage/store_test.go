@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openTestStores(t *testing.T) map[string]Store {
+	t.Helper()
+	dir := t.TempDir()
+
+	fileStore, err := NewFileStorage(filepath.Join(dir, "files"))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	sqliteStore, err := OpenSQLiteStorage(filepath.Join(dir, "test.db"), "scenes")
+	if err != nil {
+		t.Skipf("sqlite unavailable (CGO disabled?): %v", err)
+	}
+	t.Cleanup(func() { CloseSQLiteStorages() })
+
+	return map[string]Store{"file": fileStore, "sqlite": sqliteStore}
+}
+
+func TestStoreContract(t *testing.T) {
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.SaveJSONFile("scene_1", "scene.json", map[string]string{"id": "scene_1"}); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+			if err := store.SaveJSONFile(filepath.Join("scene_1", "characters"), "c1.json", map[string]string{"id": "c1"}); err != nil {
+				t.Fatalf("save nested: %v", err)
+			}
+			if err := store.SaveTextFile("scene_2", "original.txt", []byte("hello")); err != nil {
+				t.Fatalf("save text: %v", err)
+			}
+
+			var got map[string]string
+			if err := store.LoadJSONFile("scene_1", filepath.Join("characters", "c1.json"), &got); err != nil || got["id"] != "c1" {
+				t.Fatalf("load nested = %v, %v", got, err)
+			}
+			if _, err := store.LoadTextFile("scene_1", "missing.json"); !IsNotExist(err) {
+				t.Fatalf("expected not-exist error, got %v", err)
+			}
+
+			dirs, err := store.ListDirs("")
+			if err != nil || !reflect.DeepEqual(dirs, []string{"scene_1", "scene_2"}) {
+				t.Fatalf("ListDirs = %v, %v", dirs, err)
+			}
+			files, err := store.ListFiles("scene_1")
+			if err != nil || !reflect.DeepEqual(files, []string{"scene.json"}) {
+				t.Fatalf("ListFiles = %v, %v", files, err)
+			}
+			if !store.DirExists(filepath.Join("scene_1", "characters")) || store.DirExists("scene_3") {
+				t.Fatal("DirExists mismatch")
+			}
+
+			if err := store.DeleteDir("scene_1"); err != nil {
+				t.Fatalf("DeleteDir: %v", err)
+			}
+			if store.FileExists("scene_1", "scene.json") || store.DirExists("scene_1") {
+				t.Fatal("scene_1 should be gone")
+			}
+			if err := store.DeleteFile("scene_2", "missing.txt"); err == nil {
+				t.Fatal("expected error deleting missing file")
+			}
+		})
+	}
+}
+
+func TestStoreWithTx(t *testing.T) {
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			rollback := errors.New("rollback")
+			err := store.WithTx(func(tx Tx) error {
+				if err := tx.SaveTextFile("a", "1.txt", []byte("one")); err != nil {
+					return err
+				}
+				content, err := tx.LoadTextFile("a", "1.txt")
+				if err != nil || string(content) != "one" {
+					t.Fatalf("read-your-writes = %q, %v", content, err)
+				}
+				return rollback
+			})
+			if !errors.Is(err, rollback) {
+				t.Fatalf("WithTx error = %v", err)
+			}
+			if store.FileExists("a", "1.txt") {
+				t.Fatal("rolled back write must not be visible")
+			}
+
+			err = store.WithTx(func(tx Tx) error {
+				if err := tx.SaveTextFile("a", "1.txt", []byte("one")); err != nil {
+					return err
+				}
+				return tx.SaveTextFile("a", "2.txt", []byte("two"))
+			})
+			if err != nil {
+				t.Fatalf("commit: %v", err)
+			}
+			files, _ := store.ListFiles("a")
+			if !reflect.DeepEqual(files, []string{"1.txt", "2.txt"}) {
+				t.Fatalf("committed files = %v", files)
+			}
+		})
+	}
+}
+
+func TestSQLiteNamespacesAreIsolated(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	scenes, err := OpenSQLiteStorage(dbPath, "scenes")
+	if err != nil {
+		t.Skipf("sqlite unavailable (CGO disabled?): %v", err)
+	}
+	t.Cleanup(func() { CloseSQLiteStorages() })
+	scenesLike, _ := OpenSQLiteStorage(dbPath, "scenes_archive")
+
+	if err := scenes.SaveTextFile("s1", "scene.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenesLike.SaveTextFile("s2", "scene.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := scenes.ListDirs("")
+	if err != nil || !reflect.DeepEqual(dirs, []string{"s1"}) {
+		t.Fatalf("ListDirs = %v, %v", dirs, err)
+	}
+	if err := scenes.DeleteDir(""); err != nil {
+		t.Fatal(err)
+	}
+	if !scenesLike.FileExists("s2", "scene.json") {
+		t.Fatal("deleting one namespace must not touch a sibling with a shared prefix")
+	}
+}
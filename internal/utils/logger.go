@@ -3,6 +3,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -26,6 +27,7 @@ const (
 type Logger struct {
 	mu       sync.Mutex
 	file     *os.File
+	console  io.Writer
 	level    LogLevel
 	enabled  bool
 }
@@ -50,6 +52,7 @@ var (
 func GetLogger() *Logger {
 	loggerOnce.Do(func() {
 		globalLogger = &Logger{
+			console: os.Stdout,
 			level:   INFO,
 			enabled: true,
 		}
@@ -92,6 +95,14 @@ func (l *Logger) SetLogLevel(level LogLevel) {
 	l.level = level
 }
 
+// SetConsoleOutput redirects console log output; nil disables it.
+// The MCP stdio transport uses this to keep stdout free for protocol messages.
+func (l *Logger) SetConsoleOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.console = w
+}
+
 // Enable enables or disables logging
 func (l *Logger) Enable(enabled bool) {
 	l.mu.Lock()
@@ -164,8 +175,10 @@ func (l *Logger) log(level LogLevel, message string, fields map[string]interface
 		l.file.Sync() // Ensure immediate write
 	}
 
-	// Always write to stdout
-	os.Stdout.WriteString(logLine)
+	// Write to console (stdout by default)
+	if l.console != nil {
+		io.WriteString(l.console, logLine)
+	}
 
 	// For fatal errors, exit
 	if level == FATAL {
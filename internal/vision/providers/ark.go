@@ -133,6 +133,14 @@ func (p *ArkImagesProvider) GenerateImage(ctx context.Context, prompt string, op
 	if size != "" {
 		reqBody["size"] = size
 	}
+	// Seedream image-to-image accepts several reference images as data URLs.
+	if mimeTypes, encoded := encodeReferenceImages(opts.ReferenceImages); len(encoded) > 0 {
+		images := make([]string, 0, len(encoded))
+		for i := range encoded {
+			images = append(images, "data:"+mimeTypes[i]+";base64,"+encoded[i])
+		}
+		reqBody["image"] = images
+	}
 
 	payload, err := json.Marshal(reqBody)
 	if err != nil {
@@ -68,3 +68,42 @@ func TestArkImagesProvider_GenerateImage_DownloadsURL(t *testing.T) {
 		t.Fatalf("expected image content-type, got=%q", img.ContentType)
 	}
 }
+
+func TestArkImagesProvider_GenerateImage_SendsReferenceImages(t *testing.T) {
+	var gotBody map[string]interface{}
+
+	imageSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A})
+	}))
+	defer imageSrv.Close()
+
+	arkSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &gotBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"url":"` + imageSrv.URL + `"}]}`))
+	}))
+	defer arkSrv.Close()
+
+	p := NewArkImagesProvider(arkSrv.URL+"/api/v3", "test-key")
+	p.GenerationPath = "/images/generations"
+
+	png := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+	_, err := p.GenerateImage(context.Background(), "a cat", vision.VisionGenerateOptions{
+		ReferenceImages: [][]byte{png, nil, png},
+	})
+	if err != nil {
+		t.Fatalf("GenerateImage error: %v", err)
+	}
+	images, ok := gotBody["image"].([]interface{})
+	if !ok || len(images) != 2 {
+		t.Fatalf("expected 2 reference images, got=%v", gotBody["image"])
+	}
+	for _, img := range images {
+		if s, _ := img.(string); !strings.HasPrefix(s, "data:image/png;base64,") {
+			t.Fatalf("expected png data url, got=%q", s)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package providers
 import (
 	"encoding/base64"
 	"errors"
+	"net/http"
 	"strings"
 )
 
@@ -25,3 +26,16 @@ func decodeBase64Image(s string) ([]byte, error) {
 	}
 	return b, nil
 }
+
+// encodeReferenceImages returns the non-empty reference images with their sniffed
+// MIME type and base64 payload, in order.
+func encodeReferenceImages(images [][]byte) (mimeTypes []string, encoded []string) {
+	for _, img := range images {
+		if len(img) == 0 {
+			continue
+		}
+		mimeTypes = append(mimeTypes, http.DetectContentType(img))
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(img))
+	}
+	return mimeTypes, encoded
+}
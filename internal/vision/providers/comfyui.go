@@ -0,0 +1,507 @@
+// internal/vision/providers/comfyui.go
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/vision"
+)
+
+var (
+	ErrComfyUIEndpointRequired = errors.New("comfyui endpoint required")
+	ErrComfyUIWorkflowRequired = errors.New("comfyui workflow template required")
+	ErrComfyUIReferenceMissing = errors.New("comfyui workflow expects a reference image that was not supplied")
+)
+
+// comfyUIPlaceholder matches template slots such as {{prompt}} or {{reference_image_2}}.
+var comfyUIPlaceholder = regexp.MustCompile(`\{\{\s*([a-z0-9_]+)\s*\}\}`)
+
+// ComfyUIProvider runs a user-supplied ComfyUI workflow (API format JSON).
+//
+// String values in the template may contain placeholders that are filled per request:
+// {{prompt}}, {{negative_prompt}}, {{seed}}, {{width}}, {{height}}, {{steps}}, {{cfg}},
+// {{sampler}}, {{denoise}}, {{model}}, {{reference_image}} and {{reference_image_N}}.
+// A value that is exactly one numeric placeholder (e.g. "{{seed}}") becomes a JSON number.
+//
+// Flow:
+// - reference images: POST {endpoint}/upload/image, the returned name fills the slot
+// - queue:            POST {endpoint}/prompt
+// - poll:             GET  {endpoint}/history/{prompt_id}
+// - download:         GET  {endpoint}/view?filename=...&subfolder=...&type=...
+type ComfyUIProvider struct {
+	Endpoint string
+	// Workflow is used for text-to-image; WorkflowImg2Img (optional) is used instead
+	// when reference images are supplied.
+	Workflow        string
+	WorkflowImg2Img string
+	// Checkpoint fills {{model}} when the request model is empty or the generic "comfyui" key.
+	Checkpoint string
+	// OutputNode selects the node whose images are returned; empty picks the first output image.
+	OutputNode   string
+	ClientID     string
+	PollInterval time.Duration
+	Timeout      time.Duration
+	Client       *http.Client
+}
+
+func NewComfyUIProvider(endpoint string, workflow string) *ComfyUIProvider {
+	return &ComfyUIProvider{
+		Endpoint:     strings.TrimRight(strings.TrimSpace(endpoint), "/"),
+		Workflow:     strings.TrimSpace(workflow),
+		ClientID:     "scene-intruder",
+		PollInterval: time.Second,
+		Timeout:      10 * time.Minute,
+	}
+}
+
+// ValidateComfyUIWorkflow checks that a template is a JSON object (ComfyUI API format).
+func ValidateComfyUIWorkflow(workflow string) error {
+	if strings.TrimSpace(workflow) == "" {
+		return ErrComfyUIWorkflowRequired
+	}
+	var graph map[string]interface{}
+	if err := json.Unmarshal([]byte(workflow), &graph); err != nil {
+		return fmt.Errorf("comfyui workflow is not a JSON object: %w", err)
+	}
+	if len(graph) == 0 {
+		return fmt.Errorf("comfyui workflow has no nodes")
+	}
+	return nil
+}
+
+func (p *ComfyUIProvider) httpClient() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return &http.Client{Timeout: 60 * time.Second}
+}
+
+func (p *ComfyUIProvider) GenerateImage(ctx context.Context, prompt string, opts vision.VisionGenerateOptions) (*vision.VisionImage, error) {
+	if strings.TrimSpace(p.Endpoint) == "" {
+		return nil, ErrComfyUIEndpointRequired
+	}
+
+	refs := make([][]byte, 0, 1+len(opts.ReferenceImages))
+	if len(opts.ReferenceImage) > 0 {
+		refs = append(refs, opts.ReferenceImage)
+	}
+	for _, img := range opts.ReferenceImages {
+		if len(img) > 0 {
+			refs = append(refs, img)
+		}
+	}
+	workflow := p.Workflow
+	if len(refs) > 0 && strings.TrimSpace(p.WorkflowImg2Img) != "" {
+		workflow = p.WorkflowImg2Img
+	}
+	if err := ValidateComfyUIWorkflow(workflow); err != nil {
+		return nil, err
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	w, h := defaultImageDimensions(opts.Width, opts.Height, 512, 512)
+	values, err := p.placeholderValues(ctx, workflow, prompt, opts, refs, w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(workflow))
+	dec.UseNumber()
+	var graph interface{}
+	if err := dec.Decode(&graph); err != nil {
+		return nil, fmt.Errorf("comfyui workflow is not a JSON object: %w", err)
+	}
+	graph = fillComfyUIPlaceholders(graph, values)
+
+	promptID, err := p.queuePrompt(ctx, graph)
+	if err != nil {
+		return nil, err
+	}
+	image, err := p.waitForOutput(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+	data, contentType, err := p.download(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return &vision.VisionImage{
+		Format:      inferImageFormat(contentType),
+		ContentType: contentType,
+		Data:        data,
+		Width:       w,
+		Height:      h,
+	}, nil
+}
+
+// placeholderValues resolves every slot used by the template; reference images are
+// uploaded only when the template asks for them.
+func (p *ComfyUIProvider) placeholderValues(ctx context.Context, workflow string, prompt string, opts vision.VisionGenerateOptions, refs [][]byte, w int, h int) (map[string]interface{}, error) {
+	seed := opts.Seed
+	if seed <= 0 {
+		seed = rand.Int63n(1 << 48)
+	}
+	steps := opts.Steps
+	if steps <= 0 {
+		steps = 20
+	}
+	cfg := opts.CFGScale
+	if cfg <= 0 {
+		cfg = 7
+	}
+	sampler := strings.TrimSpace(opts.Sampler)
+	if sampler == "" {
+		sampler = "euler"
+	}
+	denoise := 1.0
+	if opts.DenoisingStrength > 0 {
+		denoise = opts.DenoisingStrength
+	}
+	model := strings.TrimSpace(opts.Model)
+	if model == "" || model == "comfyui" {
+		model = strings.TrimSpace(p.Checkpoint)
+	}
+
+	values := map[string]interface{}{
+		"prompt":          prompt,
+		"negative_prompt": opts.NegativePrompt,
+		"seed":            seed,
+		"width":           w,
+		"height":          h,
+		"steps":           steps,
+		"cfg":             cfg,
+		"sampler":         sampler,
+		"denoise":         denoise,
+	}
+	if model != "" {
+		values["model"] = model
+	}
+
+	for _, m := range comfyUIPlaceholder.FindAllStringSubmatch(workflow, -1) {
+		key := m[1]
+		if _, done := values[key]; done {
+			continue
+		}
+		slot := -1
+		switch {
+		case key == "model":
+			return nil, fmt.Errorf("comfyui workflow uses {{model}} but no checkpoint is configured")
+		case key == "reference_image":
+			slot = 0
+		case strings.HasPrefix(key, "reference_image_"):
+			n, err := strconv.Atoi(strings.TrimPrefix(key, "reference_image_"))
+			if err != nil || n <= 0 {
+				continue
+			}
+			slot = n - 1
+		default:
+			continue
+		}
+		if slot >= len(refs) {
+			return nil, fmt.Errorf("%w: {{%s}}", ErrComfyUIReferenceMissing, key)
+		}
+		name, err := p.uploadImage(ctx, refs[slot], slot+1)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = name
+	}
+	return values, nil
+}
+
+func fillComfyUIPlaceholders(node interface{}, values map[string]interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = fillComfyUIPlaceholders(child, values)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = fillComfyUIPlaceholders(child, values)
+		}
+		return v
+	case string:
+		if m := comfyUIPlaceholder.FindStringSubmatch(v); m != nil && m[0] == strings.TrimSpace(v) {
+			if val, ok := values[m[1]]; ok {
+				return val
+			}
+			return v
+		}
+		return comfyUIPlaceholder.ReplaceAllStringFunc(v, func(token string) string {
+			key := comfyUIPlaceholder.FindStringSubmatch(token)[1]
+			if val, ok := values[key]; ok {
+				return fmt.Sprint(val)
+			}
+			return token
+		})
+	default:
+		return v
+	}
+}
+
+func (p *ComfyUIProvider) uploadImage(ctx context.Context, data []byte, slot int) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	ext := inferImageFormat(http.DetectContentType(data))
+	part, err := mw.CreateFormFile("image", fmt.Sprintf("scene_intruder_ref_%d_%d.%s", time.Now().UnixNano(), slot, ext))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	_ = mw.WriteField("type", "input")
+	_ = mw.WriteField("overwrite", "true")
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/upload/image", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", httpStatusError("comfyui", "upload", resp.StatusCode, respBody)
+	}
+	var parsed struct {
+		Name      string `json:"name"`
+		Subfolder string `json:"subfolder"`
+	}
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(parsed.Name) == "" {
+		return "", errors.New("comfyui upload returned no file name")
+	}
+	if sub := strings.TrimSpace(parsed.Subfolder); sub != "" {
+		return sub + "/" + parsed.Name, nil
+	}
+	return parsed.Name, nil
+}
+
+func (p *ComfyUIProvider) queuePrompt(ctx context.Context, graph interface{}) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{"prompt": graph, "client_id": p.ClientID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/prompt", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", httpStatusError("comfyui", "queue prompt", resp.StatusCode, body)
+	}
+	var parsed struct {
+		PromptID   string                 `json:"prompt_id"`
+		NodeErrors map[string]interface{} `json:"node_errors"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", err
+	}
+	if len(parsed.NodeErrors) > 0 {
+		return "", httpStatusError("comfyui", "queue prompt", resp.StatusCode, body)
+	}
+	if strings.TrimSpace(parsed.PromptID) == "" {
+		return "", errors.New("comfyui returned no prompt_id")
+	}
+	return parsed.PromptID, nil
+}
+
+type comfyUIImageRef struct {
+	Filename  string `json:"filename"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+type comfyUIHistoryEntry struct {
+	Outputs map[string]struct {
+		Images []comfyUIImageRef `json:"images"`
+	} `json:"outputs"`
+	Status struct {
+		StatusStr string            `json:"status_str"`
+		Completed bool              `json:"completed"`
+		Messages  []json.RawMessage `json:"messages"`
+	} `json:"status"`
+}
+
+func (p *ComfyUIProvider) waitForOutput(ctx context.Context, promptID string) (comfyUIImageRef, error) {
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	historyURL := p.Endpoint + "/history/" + url.PathEscape(promptID)
+	for {
+		entry, err := p.fetchHistory(ctx, historyURL, promptID)
+		if err != nil {
+			return comfyUIImageRef{}, err
+		}
+		if entry != nil {
+			if entry.Status.StatusStr == "error" {
+				return comfyUIImageRef{}, fmt.Errorf("comfyui execution failed: %s", comfyUIExecutionError(entry))
+			}
+			if img, ok := p.pickOutputImage(entry); ok {
+				return img, nil
+			}
+			if entry.Status.Completed {
+				return comfyUIImageRef{}, errors.New("comfyui finished without output images")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return comfyUIImageRef{}, fmt.Errorf("comfyui prompt %s did not finish: %w", promptID, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (p *ComfyUIProvider) fetchHistory(ctx context.Context, historyURL string, promptID string) (*comfyUIHistoryEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, historyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, httpStatusError("comfyui", "history", resp.StatusCode, body)
+	}
+	var history map[string]comfyUIHistoryEntry
+	if err := json.Unmarshal(body, &history); err != nil {
+		return nil, err
+	}
+	entry, ok := history[promptID]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (p *ComfyUIProvider) pickOutputImage(entry *comfyUIHistoryEntry) (comfyUIImageRef, bool) {
+	if node := strings.TrimSpace(p.OutputNode); node != "" {
+		out, ok := entry.Outputs[node]
+		if !ok || len(out.Images) == 0 {
+			return comfyUIImageRef{}, false
+		}
+		return out.Images[0], true
+	}
+	nodeIDs := make([]string, 0, len(entry.Outputs))
+	for id := range entry.Outputs {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	var preview *comfyUIImageRef
+	for _, id := range nodeIDs {
+		for i, img := range entry.Outputs[id].Images {
+			if img.Type == "output" {
+				return img, true
+			}
+			if preview == nil {
+				preview = &entry.Outputs[id].Images[i]
+			}
+		}
+	}
+	// Only fall back to preview/temp images once the run has finished.
+	if preview != nil && entry.Status.Completed {
+		return *preview, true
+	}
+	return comfyUIImageRef{}, false
+}
+
+func comfyUIExecutionError(entry *comfyUIHistoryEntry) string {
+	for _, raw := range entry.Status.Messages {
+		var msg []json.RawMessage
+		if err := json.Unmarshal(raw, &msg); err != nil || len(msg) < 2 {
+			continue
+		}
+		var kind string
+		if err := json.Unmarshal(msg[0], &kind); err != nil || kind != "execution_error" {
+			continue
+		}
+		var detail struct {
+			NodeType         string `json:"node_type"`
+			ExceptionMessage string `json:"exception_message"`
+		}
+		if err := json.Unmarshal(msg[1], &detail); err == nil {
+			return strings.TrimSpace(detail.NodeType + ": " + detail.ExceptionMessage)
+		}
+	}
+	return "unknown error"
+}
+
+func (p *ComfyUIProvider) download(ctx context.Context, img comfyUIImageRef) ([]byte, string, error) {
+	q := url.Values{}
+	q.Set("filename", img.Filename)
+	q.Set("subfolder", img.Subfolder)
+	q.Set("type", img.Type)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Endpoint+"/view?"+q.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", httpStatusError("comfyui", "download", resp.StatusCode, data)
+	}
+	if len(data) == 0 {
+		return nil, "", errors.New("comfyui returned an empty image")
+	}
+	contentType := strings.TrimSpace(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
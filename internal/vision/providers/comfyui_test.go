@@ -0,0 +1,150 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/vision"
+)
+
+const testComfyWorkflow = `{
+  "3": {"class_type": "KSampler", "inputs": {"seed": "{{seed}}", "steps": "{{steps}}", "cfg": "{{cfg}}", "denoise": "{{denoise}}", "model": ["4", 0]}},
+  "4": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "{{model}}"}},
+  "5": {"class_type": "EmptyLatentImage", "inputs": {"width": "{{width}}", "height": "{{height}}", "batch_size": 1}},
+  "6": {"class_type": "CLIPTextEncode", "inputs": {"text": "masterpiece, {{prompt}}"}},
+  "7": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{negative_prompt}}"}},
+  "10": {"class_type": "LoadImage", "inputs": {"image": "{{reference_image}}"}},
+  "9": {"class_type": "SaveImage", "inputs": {"filename_prefix": "comic"}}
+}`
+
+func TestComfyUIProvider_GenerateImage_FillsWorkflowAndPollsHistory(t *testing.T) {
+	png := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+
+	var mu sync.Mutex
+	var queued map[string]interface{}
+	var uploads int
+	var historyCalls int
+	var viewQuery string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/image":
+			if _, _, err := r.FormFile("image"); err != nil {
+				t.Errorf("upload without image part: %v", err)
+			}
+			uploads++
+			_, _ = w.Write([]byte(`{"name":"ref.png","subfolder":"","type":"input"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/prompt":
+			b, _ := io.ReadAll(r.Body)
+			var body map[string]interface{}
+			_ = json.Unmarshal(b, &body)
+			queued, _ = body["prompt"].(map[string]interface{})
+			_, _ = w.Write([]byte(`{"prompt_id":"p-1","number":1,"node_errors":{}}`))
+		case r.URL.Path == "/history/p-1":
+			historyCalls++
+			if historyCalls == 1 {
+				_, _ = w.Write([]byte(`{}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"p-1":{"outputs":{"9":{"images":[{"filename":"comic_0001.png","subfolder":"","type":"output"}]}},"status":{"status_str":"success","completed":true}}}`))
+		case r.URL.Path == "/view":
+			viewQuery = r.URL.RawQuery
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewComfyUIProvider(srv.URL, testComfyWorkflow)
+	p.Checkpoint = "sdxl.safetensors"
+	p.PollInterval = 10 * time.Millisecond
+
+	img, err := p.GenerateImage(context.Background(), "a cat", vision.VisionGenerateOptions{
+		Width:          768,
+		Height:         512,
+		Seed:           42,
+		NegativePrompt: "blurry",
+		ReferenceImage: png,
+	})
+	if err != nil {
+		t.Fatalf("GenerateImage error: %v", err)
+	}
+	if img == nil || len(img.Data) == 0 || img.ContentType != "image/png" {
+		t.Fatalf("unexpected image: %+v", img)
+	}
+
+	input := func(node string) map[string]interface{} {
+		n, _ := queued[node].(map[string]interface{})
+		in, _ := n["inputs"].(map[string]interface{})
+		return in
+	}
+	if got := input("3")["seed"]; got != float64(42) {
+		t.Fatalf("expected numeric seed 42, got=%v", got)
+	}
+	if got := input("5")["width"]; got != float64(768) {
+		t.Fatalf("expected numeric width 768, got=%v", got)
+	}
+	if got := input("4")["ckpt_name"]; got != "sdxl.safetensors" {
+		t.Fatalf("expected checkpoint, got=%v", got)
+	}
+	if got := input("6")["text"]; got != "masterpiece, a cat" {
+		t.Fatalf("expected prompt inside text, got=%v", got)
+	}
+	if got := input("7")["text"]; got != "blurry" {
+		t.Fatalf("expected negative prompt, got=%v", got)
+	}
+	if got := input("10")["image"]; got != "ref.png" {
+		t.Fatalf("expected uploaded reference name, got=%v", got)
+	}
+	if uploads != 1 || historyCalls < 2 {
+		t.Fatalf("expected 1 upload and repeated polling, got uploads=%d history=%d", uploads, historyCalls)
+	}
+	if viewQuery != "filename=comic_0001.png&subfolder=&type=output" {
+		t.Fatalf("unexpected view query: %q", viewQuery)
+	}
+}
+
+func TestComfyUIProvider_GenerateImage_MissingReference(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	p := NewComfyUIProvider(srv.URL, testComfyWorkflow)
+	p.Checkpoint = "sdxl.safetensors"
+	_, err := p.GenerateImage(context.Background(), "a cat", vision.VisionGenerateOptions{})
+	if !errors.Is(err, ErrComfyUIReferenceMissing) {
+		t.Fatalf("expected ErrComfyUIReferenceMissing, got=%v", err)
+	}
+}
+
+func TestComfyUIProvider_GenerateImage_ExecutionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prompt":
+			_, _ = w.Write([]byte(`{"prompt_id":"p-2","node_errors":{}}`))
+		case "/history/p-2":
+			_, _ = w.Write([]byte(`{"p-2":{"outputs":{},"status":{"status_str":"error","completed":false,"messages":[["execution_error",{"node_type":"KSampler","exception_message":"out of memory"}]]}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewComfyUIProvider(srv.URL, `{"1":{"class_type":"SaveImage","inputs":{"text":"{{prompt}}"}}}`)
+	_, err := p.GenerateImage(context.Background(), "a cat", vision.VisionGenerateOptions{})
+	if err == nil || err.Error() != "comfyui execution failed: KSampler: out of memory" {
+		t.Fatalf("expected execution error, got=%v", err)
+	}
+}
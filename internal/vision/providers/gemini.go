@@ -108,11 +108,19 @@ func (p *GeminiProvider) GenerateImage(ctx context.Context, prompt string, opts
 
 	w, h := defaultImageDimensions(opts.Width, opts.Height, 1024, 1024)
 
+	parts := []interface{}{map[string]interface{}{"text": prompt}}
+	mimeTypes, encoded := encodeReferenceImages(opts.ReferenceImages)
+	for i := range encoded {
+		parts = append(parts, map[string]interface{}{
+			"inlineData": map[string]interface{}{"mimeType": mimeTypes[i], "data": encoded[i]},
+		})
+	}
+
 	requestBody := map[string]interface{}{
 		"contents": []interface{}{
 			map[string]interface{}{
 				"role":  "user",
-				"parts": []interface{}{map[string]interface{}{"text": prompt}},
+				"parts": parts,
 			},
 		},
 		"generationConfig": map[string]interface{}{
@@ -0,0 +1,56 @@
+// internal/vision/providers/inpaint_mask.go
+package providers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ErrInpaintSourceRequired is returned when a mask is given without the image to edit.
+var ErrInpaintSourceRequired = errors.New("inpainting requires a reference image")
+
+// ensurePNG re-encodes JPEG/WEBP input as PNG; PNG input is returned unchanged.
+func ensurePNG(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		return data, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// alphaMaskFromLuminance converts a white-on-black mask into the transparent-means-edit
+// PNG that the OpenAI edits API expects: white (edit) becomes fully transparent.
+func alphaMaskFromLuminance(mask []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(mask))
+	if err != nil {
+		return nil, fmt.Errorf("decode mask: %w", err)
+	}
+	b := src.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gray := color.GrayModel.Convert(src.At(x, y)).(color.Gray)
+			out.SetNRGBA(x-b.Min.X, y-b.Min.Y, color.NRGBA{A: 255 - gray.Y})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -8,7 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"path"
 	"strings"
@@ -22,11 +24,12 @@ var (
 	ErrOpenAIAPIKeyRequired   = errors.New("openai api key required")
 )
 
-// OpenAIImagesProvider implements OpenAI Images API (generations).
+// OpenAIImagesProvider implements OpenAI Images API (generations, and edits when a mask is set).
 type OpenAIImagesProvider struct {
 	Endpoint       string
 	APIKey         string
 	GenerationPath string
+	EditPath       string
 
 	ModelOverride     string
 	ModelKeyOverrides map[string]string
@@ -43,9 +46,13 @@ func NewOpenAIImagesProvider(endpoint string, apiKey string) *OpenAIImagesProvid
 		Endpoint:       strings.TrimRight(strings.TrimSpace(endpoint), "/"),
 		APIKey:         strings.TrimSpace(apiKey),
 		GenerationPath: "/images/generations",
+		EditPath:       "/images/edits",
 	}
 }
 
+// SupportsInpainting reports that masked edits go through the images/edits endpoint.
+func (p *OpenAIImagesProvider) SupportsInpainting() bool { return true }
+
 func (p *OpenAIImagesProvider) httpClient() *http.Client {
 	if p.Client != nil {
 		return p.Client
@@ -96,6 +103,14 @@ func (p *OpenAIImagesProvider) resolveSize(modelKey string, w int, h int) string
 }
 
 func (p *OpenAIImagesProvider) generateURL() (string, error) {
+	return p.endpointURL(p.GenerationPath, "/images/generations")
+}
+
+func (p *OpenAIImagesProvider) editURL() (string, error) {
+	return p.endpointURL(p.EditPath, "/images/edits")
+}
+
+func (p *OpenAIImagesProvider) endpointURL(configured string, fallback string) (string, error) {
 	base := strings.TrimSpace(p.Endpoint)
 	if base == "" {
 		return "", ErrOpenAIEndpointRequired
@@ -104,9 +119,9 @@ func (p *OpenAIImagesProvider) generateURL() (string, error) {
 	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
 		return "", ErrOpenAIEndpointRequired
 	}
-	gp := strings.TrimSpace(p.GenerationPath)
+	gp := strings.TrimSpace(configured)
 	if gp == "" {
-		gp = "/images/generations"
+		gp = fallback
 	}
 	u.Path = path.Join(u.Path, gp)
 	return u.String(), nil
@@ -116,6 +131,9 @@ func (p *OpenAIImagesProvider) GenerateImage(ctx context.Context, prompt string,
 	if strings.TrimSpace(p.APIKey) == "" {
 		return nil, ErrOpenAIAPIKeyRequired
 	}
+	if len(opts.Mask) > 0 {
+		return p.editImage(ctx, prompt, opts)
+	}
 	genURL, err := p.generateURL()
 	if err != nil {
 		return nil, err
@@ -147,6 +165,70 @@ func (p *OpenAIImagesProvider) GenerateImage(ctx context.Context, prompt string,
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.APIKey)
 
+	return p.doImageRequest(ctx, req, w, h)
+}
+
+// editImage repaints the masked region of opts.ReferenceImage via POST {endpoint}/images/edits.
+func (p *OpenAIImagesProvider) editImage(ctx context.Context, prompt string, opts vision.VisionGenerateOptions) (*vision.VisionImage, error) {
+	if len(opts.ReferenceImage) == 0 {
+		return nil, ErrInpaintSourceRequired
+	}
+	editURL, err := p.editURL()
+	if err != nil {
+		return nil, err
+	}
+	source, err := ensurePNG(opts.ReferenceImage)
+	if err != nil {
+		return nil, err
+	}
+	mask, err := alphaMaskFromLuminance(opts.Mask)
+	if err != nil {
+		return nil, err
+	}
+
+	model := p.resolveModel(opts.Model)
+	w, h := defaultImageDimensions(opts.Width, opts.Height, 1024, 1024)
+	size := p.resolveSize(opts.Model, w, h)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("model", model)
+	_ = mw.WriteField("prompt", prompt)
+	_ = mw.WriteField("n", "1")
+	if strings.TrimSpace(size) != "" {
+		_ = mw.WriteField("size", size)
+	}
+	for _, f := range []struct {
+		field string
+		name  string
+		data  []byte
+	}{{"image", "image.png", source}, {"mask", "mask.png", mask}} {
+		hdr := textproto.MIMEHeader{}
+		hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, f.field, f.name))
+		hdr.Set("Content-Type", "image/png")
+		part, err := mw.CreatePart(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, editURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	return p.doImageRequest(ctx, req, w, h)
+}
+
+func (p *OpenAIImagesProvider) doImageRequest(ctx context.Context, req *http.Request, w int, h int) (*vision.VisionImage, error) {
 	resp, err := p.httpClient().Do(req)
 	if err != nil {
 		return nil, err
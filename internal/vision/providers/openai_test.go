@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/vision"
+)
+
+func TestOpenAIImagesProvider_GenerateImage_MaskUsesEdits(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return buf.Bytes()
+	}
+	source := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	mask := image.NewGray(image.Rect(0, 0, 2, 1))
+	mask.SetGray(0, 0, color.Gray{Y: 255}) // edit left pixel, keep right pixel
+
+	var gotPath string
+	var gotPrompt string
+	var gotMask image.Image
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart: %v", err)
+		}
+		gotPrompt = r.FormValue("prompt")
+		if f, _, err := r.FormFile("mask"); err == nil {
+			gotMask, _ = png.Decode(f)
+			_ = f.Close()
+		}
+		if _, _, err := r.FormFile("image"); err != nil {
+			t.Errorf("missing image part: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"b64_json":"` + base64.StdEncoding.EncodeToString(encode(source)) + `"}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIImagesProvider(srv.URL+"/v1", "test-key")
+	img, err := p.GenerateImage(context.Background(), "add a hat", vision.VisionGenerateOptions{
+		Width:          2,
+		Height:         1,
+		ReferenceImage: encode(source),
+		Mask:           encode(mask),
+	})
+	if err != nil {
+		t.Fatalf("GenerateImage error: %v", err)
+	}
+	if img == nil || len(img.Data) == 0 {
+		t.Fatalf("expected non-empty image")
+	}
+	if gotPath != "/v1/images/edits" {
+		t.Fatalf("expected edits path, got=%q", gotPath)
+	}
+	if gotPrompt != "add a hat" {
+		t.Fatalf("expected prompt, got=%q", gotPrompt)
+	}
+	if gotMask == nil {
+		t.Fatalf("expected png mask part")
+	}
+	if _, _, _, a := gotMask.At(0, 0).RGBA(); a != 0 {
+		t.Fatalf("expected white mask pixel to become transparent, alpha=%d", a)
+	}
+	if _, _, _, a := gotMask.At(1, 0).RGBA(); a != 0xffff {
+		t.Fatalf("expected black mask pixel to stay opaque, alpha=%d", a)
+	}
+}
@@ -0,0 +1,192 @@
+// internal/vision/providers/replay.go
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
+	"github.com/Corphon/SceneIntruderMCP/internal/vision"
+)
+
+var ErrReplayUpstreamRequired = errors.New("replay record mode requires an upstream vision provider")
+
+// ReplayVisionProvider serves images recorded in a cassette file, keyed by a hash of the
+// normalized request. Misses are forwarded to Upstream and recorded (mode "replay"), always
+// re-recorded (mode "record") or rejected with cassette.ErrNoMatch (mode "strict").
+//
+// Image bytes are kept as files in the cassette's asset directory so the JSON stays small.
+type ReplayVisionProvider struct {
+	Cassette *cassette.Cassette
+	Mode     cassette.Mode
+	Upstream vision.VisionProvider
+	// UpstreamModel replaces the "replay" placeholder model before a request is forwarded.
+	UpstreamModel string
+	Normalizer    *cassette.Normalizer
+}
+
+func NewReplayVisionProvider(c *cassette.Cassette, mode cassette.Mode, upstream vision.VisionProvider, upstreamModel string, normalizer *cassette.Normalizer) (*ReplayVisionProvider, error) {
+	if c == nil {
+		return nil, errors.New("replay cassette required")
+	}
+	if mode == "" {
+		mode = cassette.ModeReplay
+	}
+	if mode == cassette.ModeRecord && upstream == nil {
+		return nil, ErrReplayUpstreamRequired
+	}
+	if mode == cassette.ModeStrict && !c.Exists() {
+		return nil, fmt.Errorf("replay cassette %s not found (strict mode)", c.Path())
+	}
+	return &ReplayVisionProvider{
+		Cassette:      c,
+		Mode:          mode,
+		Upstream:      upstream,
+		UpstreamModel: strings.TrimSpace(upstreamModel),
+		Normalizer:    normalizer,
+	}, nil
+}
+
+// SupportsInpainting defers to the upstream provider. Without one it reports whether the
+// cassette holds any masked request, so recorded inpainting runs replay offline.
+func (p *ReplayVisionProvider) SupportsInpainting() bool {
+	if p.Upstream != nil {
+		ip, ok := p.Upstream.(vision.InpaintingProvider)
+		return ok && ip.SupportsInpainting()
+	}
+	for _, it := range p.Cassette.Interactions() {
+		var req replayImageRequest
+		if err := json.Unmarshal(it.Request, &req); err == nil && req.Mask != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type replayImageRequest struct {
+	Prompt            string   `json:"prompt"`
+	Model             string   `json:"model,omitempty"`
+	Width             int      `json:"width,omitempty"`
+	Height            int      `json:"height,omitempty"`
+	NegativePrompt    string   `json:"negative_prompt,omitempty"`
+	Steps             int      `json:"steps,omitempty"`
+	CFGScale          float64  `json:"cfg_scale,omitempty"`
+	Sampler           string   `json:"sampler,omitempty"`
+	Seed              int64    `json:"seed,omitempty"`
+	ClipSkip          int      `json:"clip_skip,omitempty"`
+	Eta               float64  `json:"eta,omitempty"`
+	Tiling            bool     `json:"tiling,omitempty"`
+	Stylize           int      `json:"stylize,omitempty"`
+	Chaos             int      `json:"chaos,omitempty"`
+	PromptExtend      bool     `json:"prompt_extend,omitempty"`
+	ReferenceImage    string   `json:"reference_image,omitempty"`
+	ReferenceImages   []string `json:"reference_images,omitempty"`
+	DenoisingStrength float64  `json:"denoising_strength,omitempty"`
+	Mask              string   `json:"mask,omitempty"`
+}
+
+type replayImageResponse struct {
+	Format      string `json:"format"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Asset       string `json:"asset"`
+}
+
+func (p *ReplayVisionProvider) GenerateImage(ctx context.Context, prompt string, opts vision.VisionGenerateOptions) (*vision.VisionImage, error) {
+	// The key uses the model as requested, so a cassette replays without the upstream configured.
+	req := p.normalize(prompt, opts)
+	key, err := cassette.Key(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Mode != cassette.ModeRecord {
+		if data, ok := p.Cassette.Lookup(key); ok {
+			return p.load(data)
+		}
+		if p.Mode == cassette.ModeStrict || p.Upstream == nil {
+			return nil, fmt.Errorf("%w (cassette=%s, prompt=%q)", cassette.ErrNoMatch, p.Cassette.Path(), cassette.Describe(req.Prompt, 80))
+		}
+	}
+	if p.Upstream == nil {
+		return nil, ErrReplayUpstreamRequired
+	}
+
+	if p.UpstreamModel != "" && (opts.Model == "" || opts.Model == "replay") {
+		opts.Model = p.UpstreamModel
+	}
+	img, err := p.Upstream.GenerateImage(ctx, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil || len(img.Data) == 0 {
+		return nil, errors.New("upstream vision provider returned an empty image")
+	}
+	asset, err := p.Cassette.WriteAsset(img.Data, img.Format)
+	if err != nil {
+		return nil, err
+	}
+	resp := replayImageResponse{
+		Format:      img.Format,
+		ContentType: img.ContentType,
+		Width:       img.Width,
+		Height:      img.Height,
+		Asset:       asset,
+	}
+	if err := p.Cassette.Record(key, req, resp); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func (p *ReplayVisionProvider) normalize(prompt string, opts vision.VisionGenerateOptions) replayImageRequest {
+	req := replayImageRequest{
+		Prompt:            p.Normalizer.Text(prompt),
+		Model:             opts.Model,
+		Width:             opts.Width,
+		Height:            opts.Height,
+		NegativePrompt:    p.Normalizer.Text(opts.NegativePrompt),
+		Steps:             opts.Steps,
+		CFGScale:          opts.CFGScale,
+		Sampler:           opts.Sampler,
+		ClipSkip:          opts.ClipSkip,
+		Eta:               opts.Eta,
+		Tiling:            opts.Tiling,
+		Stylize:           opts.Stylize,
+		Chaos:             opts.Chaos,
+		PromptExtend:      opts.PromptExtend,
+		ReferenceImage:    cassette.HashBytes(opts.ReferenceImage),
+		DenoisingStrength: opts.DenoisingStrength,
+		Mask:              cassette.HashBytes(opts.Mask),
+	}
+	// A random seed differs on every render, so keying on it would make replays always miss.
+	if !opts.RandomSeed {
+		req.Seed = opts.Seed
+	}
+	for _, ref := range opts.ReferenceImages {
+		req.ReferenceImages = append(req.ReferenceImages, cassette.HashBytes(ref))
+	}
+	return req
+}
+
+func (p *ReplayVisionProvider) load(data json.RawMessage) (*vision.VisionImage, error) {
+	var resp replayImageResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode replay image response: %w", err)
+	}
+	imgData, err := p.Cassette.ReadAsset(resp.Asset)
+	if err != nil {
+		return nil, err
+	}
+	return &vision.VisionImage{
+		Format:      resp.Format,
+		ContentType: resp.ContentType,
+		Data:        imgData,
+		Width:       resp.Width,
+		Height:      resp.Height,
+	}, nil
+}
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Corphon/SceneIntruderMCP/internal/cassette"
+	"github.com/Corphon/SceneIntruderMCP/internal/vision"
+)
+
+type countingVisionProvider struct {
+	inner vision.VisionProvider
+	calls int
+	model string
+}
+
+func (c *countingVisionProvider) GenerateImage(ctx context.Context, prompt string, opts vision.VisionGenerateOptions) (*vision.VisionImage, error) {
+	c.calls++
+	c.model = opts.Model
+	return c.inner.GenerateImage(ctx, prompt, opts)
+}
+
+func TestReplayVisionProvider_RecordThenStrictReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vision.json")
+	upstream := &countingVisionProvider{inner: NewPlaceholderVisionProvider()}
+
+	rec, err := cassette.Open(path, "vision")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	recorder, err := NewReplayVisionProvider(rec, cassette.ModeRecord, upstream, "sd-xl", nil)
+	if err != nil {
+		t.Fatalf("NewReplayVisionProvider error: %v", err)
+	}
+
+	opts := vision.VisionGenerateOptions{Model: "replay", Width: 32, Height: 24, Seed: 7, ReferenceImage: []byte("ref")}
+	recorded, err := recorder.GenerateImage(context.Background(), "a  castle at dusk", opts)
+	if err != nil {
+		t.Fatalf("record GenerateImage error: %v", err)
+	}
+	if upstream.calls != 1 || upstream.model != "sd-xl" {
+		t.Fatalf("expected one upstream call with upstream model, calls=%d model=%q", upstream.calls, upstream.model)
+	}
+
+	play, err := cassette.Open(path, "vision")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	strict, err := NewReplayVisionProvider(play, cassette.ModeStrict, nil, "sd-xl", nil)
+	if err != nil {
+		t.Fatalf("NewReplayVisionProvider(strict) error: %v", err)
+	}
+
+	replayed, err := strict.GenerateImage(context.Background(), "a castle  at dusk", opts)
+	if err != nil {
+		t.Fatalf("replay GenerateImage error: %v", err)
+	}
+	if !bytes.Equal(replayed.Data, recorded.Data) || replayed.Width != 32 || replayed.ContentType != "image/png" {
+		t.Fatalf("replayed image differs: %+v", replayed)
+	}
+
+	opts.ReferenceImage = []byte("another ref")
+	if _, err := strict.GenerateImage(context.Background(), "a castle at dusk", opts); !errors.Is(err, cassette.ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch for a different reference image, got %v", err)
+	}
+	if upstream.calls != 1 {
+		t.Fatalf("strict mode must not call upstream, calls=%d", upstream.calls)
+	}
+	if strict.SupportsInpainting() {
+		t.Fatalf("cassette without masked requests should not report inpainting")
+	}
+}
+
+// renderComic mimics a comic generate run: every frame gets a fresh random seed and uses
+// the previous frame's image as its img2img reference.
+func renderComic(t *testing.T, p *ReplayVisionProvider, seedBase int64) [][]byte {
+	t.Helper()
+	var out [][]byte
+	var prev []byte
+	for i, prompt := range []string{"frame one: a harbor", "frame two: the ship leaves", "frame three: open sea"} {
+		opts := vision.VisionGenerateOptions{Model: "replay", Width: 32, Height: 24, Seed: seedBase + int64(i), RandomSeed: true}
+		if prev != nil {
+			opts.ReferenceImage = prev
+			opts.DenoisingStrength = 0.35
+		}
+		img, err := p.GenerateImage(context.Background(), prompt, opts)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		out = append(out, img.Data)
+		prev = img.Data
+	}
+	return out
+}
+
+func TestReplayVisionProvider_ComicGenerateTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vision.json")
+	upstream := &countingVisionProvider{inner: NewPlaceholderVisionProvider()}
+
+	rec, err := cassette.Open(path, "vision")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	recorder, err := NewReplayVisionProvider(rec, cassette.ModeReplay, upstream, "sd-xl", nil)
+	if err != nil {
+		t.Fatalf("NewReplayVisionProvider error: %v", err)
+	}
+	first := renderComic(t, recorder, 1000)
+
+	play, err := cassette.Open(path, "vision")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	strict, err := NewReplayVisionProvider(play, cassette.ModeStrict, nil, "", nil)
+	if err != nil {
+		t.Fatalf("NewReplayVisionProvider(strict) error: %v", err)
+	}
+	second := renderComic(t, strict, 5000)
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("frame %d differs between runs", i)
+		}
+	}
+	if upstream.calls != len(first) {
+		t.Fatalf("expected %d upstream calls, got %d", len(first), upstream.calls)
+	}
+
+	// A seed from a fixed strategy stays part of the key.
+	opts := vision.VisionGenerateOptions{Model: "replay", Width: 32, Height: 24, Seed: 42}
+	if _, err := strict.GenerateImage(context.Background(), "frame one: a harbor", opts); !errors.Is(err, cassette.ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch for a fixed seed, got %v", err)
+	}
+}
+
+func TestReplayVisionProvider_ConfigErrors(t *testing.T) {
+	c, err := cassette.Open(filepath.Join(t.TempDir(), "vision.json"), "vision")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if _, err := NewReplayVisionProvider(c, cassette.ModeRecord, nil, "", nil); !errors.Is(err, ErrReplayUpstreamRequired) {
+		t.Fatalf("expected ErrReplayUpstreamRequired, got %v", err)
+	}
+	if _, err := NewReplayVisionProvider(c, cassette.ModeStrict, nil, "", nil); err == nil {
+		t.Fatalf("expected error for strict mode without cassette file")
+	}
+}
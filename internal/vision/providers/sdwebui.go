@@ -22,6 +22,7 @@ var ErrSDWebUIEndpointRequired = errors.New("sdwebui endpoint required")
 // It supports:
 // - text2img: POST {endpoint}/sdapi/v1/txt2img
 // - img2img: POST {endpoint}/sdapi/v1/img2img (when opts.ReferenceImage is set)
+// - inpainting: img2img with a mask (when opts.Mask is set as well)
 type SDWebUIProvider struct {
 	Endpoint string
 	Client   *http.Client
@@ -31,6 +32,9 @@ func NewSDWebUIProvider(endpoint string) *SDWebUIProvider {
 	return &SDWebUIProvider{Endpoint: strings.TrimRight(strings.TrimSpace(endpoint), "/")}
 }
 
+// SupportsInpainting reports that masked img2img is available.
+func (p *SDWebUIProvider) SupportsInpainting() bool { return true }
+
 func (p *SDWebUIProvider) httpClient() *http.Client {
 	if p.Client != nil {
 		return p.Client
@@ -43,6 +47,10 @@ func (p *SDWebUIProvider) GenerateImage(ctx context.Context, prompt string, opts
 		return nil, ErrSDWebUIEndpointRequired
 	}
 
+	if len(opts.Mask) > 0 && len(opts.ReferenceImage) == 0 {
+		return nil, ErrInpaintSourceRequired
+	}
+
 	w, h := defaultImageDimensions(opts.Width, opts.Height, 512, 512)
 
 	txt2imgURL := p.Endpoint + "/sdapi/v1/txt2img"
@@ -95,6 +103,17 @@ func (p *SDWebUIProvider) GenerateImage(ctx context.Context, prompt string, opts
 		if opts.DenoisingStrength > 0 {
 			reqBody["denoising_strength"] = opts.DenoisingStrength
 		}
+		if len(opts.Mask) > 0 {
+			// White mask pixels are repainted; the rest keeps the original content.
+			reqBody["mask"] = base64.StdEncoding.EncodeToString(opts.Mask)
+			reqBody["mask_blur"] = 4
+			reqBody["inpainting_fill"] = 1
+			reqBody["inpaint_full_res"] = true
+			reqBody["inpainting_mask_invert"] = 0
+			if opts.DenoisingStrength <= 0 {
+				reqBody["denoising_strength"] = 0.75
+			}
+		}
 	}
 
 	payload, err := json.Marshal(reqBody)
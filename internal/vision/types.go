@@ -28,11 +28,27 @@ type VisionGenerateOptions struct {
 	Chaos          int
 	PromptExtend   bool
 
+	// RandomSeed marks Seed as picked at random for this render only (no fixed seed strategy).
+	// Providers that key on the request, such as replay, leave such a seed out of the key.
+	RandomSeed bool
+
 	// ReferenceImage enables img2img for providers that support it.
 	// Providers should treat empty bytes as “no reference”.
 	ReferenceImage []byte
+	// ReferenceImages carries additional references (e.g. character sheets) for
+	// providers that accept several images; others may ignore it.
+	ReferenceImages [][]byte
 	// DenoisingStrength is commonly used by img2img backends (0..1).
 	DenoisingStrength float64
+	// Mask selects the region of ReferenceImage to repaint (white = edit, black = keep).
+	// Only providers implementing InpaintingProvider honour it.
+	Mask []byte
+}
+
+// InpaintingProvider is implemented by providers that can repaint a masked region
+// of VisionGenerateOptions.ReferenceImage.
+type InpaintingProvider interface {
+	SupportsInpainting() bool
 }
 
 type VisionImage struct {